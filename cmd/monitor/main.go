@@ -2,12 +2,19 @@ package main
 
 import (
 	"log"
+	"os"
 	"schnorarr/internal/app"
 )
 
-const Port = "8080"
-
 func main() {
+	// Maintenance commands
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		os.Exit(app.RunDoctor(os.Args[2:], os.Stdin, os.Stdout))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(app.RunValidate(os.Stdout))
+	}
+
 	// Initialize Application
 	application, err := app.New()
 	if err != nil {
@@ -15,7 +22,7 @@ func main() {
 	}
 
 	// Start Application
-	if err := application.Start(Port); err != nil {
+	if err := application.Start(); err != nil {
 		log.Fatalf("Application failed: %v", err)
 	}
 }
\ No newline at end of file
@@ -0,0 +1,25 @@
+// Command wsschema writes the TypeScript definitions of the dashboard
+// WebSocket messages: go run ./cmd/wsschema <file>
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+
+	_ "schnorarr/internal/app" // Declares the sender's topics
+	"schnorarr/internal/monitor/websocket"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		log.Fatal("usage: wsschema <output.d.ts>")
+	}
+	var buf bytes.Buffer
+	if err := websocket.WriteTypeScript(&buf); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(os.Args[1], buf.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,330 @@
+// Package agent defines the RPC service a receiver exposes to senders:
+// manifest streaming, change journal, stat, delete, hash, search, directory
+// listing, health and batches of changes.
+//
+// Calls are versioned JSON messages POSTed to /agent/v<N>/<Method>; manifests
+// are streamed back as NDJSON. Adding fields to a message is backwards
+// compatible, removing or changing one requires a new protocol version.
+// Clients fall back to the legacy /api/* endpoints when a receiver predates
+// the agent protocol.
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Version is the agent protocol version spoken by this build
+const Version = 1
+
+// PathPrefix is where the service of this protocol version is mounted
+const PathPrefix = "/agent/v1/"
+
+// HeaderVersion carries the protocol version on every request and response
+const HeaderVersion = "X-Schnorarr-Agent"
+
+// ManifestNDJSON is the content type of streamed manifests
+const ManifestNDJSON = "application/x-ndjson"
+
+var (
+	// ErrInvalidPath rejects paths that escape the receiver root
+	ErrInvalidPath = errors.New("invalid path")
+	// ErrUnsupported is returned for calls a legacy receiver cannot answer
+	ErrUnsupported = errors.New("not supported by receiver")
+	// ErrReadOnly rejects changes while the receiver is write-protected
+	ErrReadOnly = errors.New("receiver is read-only")
+	// ErrNotEmpty rejects removing a directory that still has entries
+	ErrNotEmpty = errors.New("directory not empty")
+)
+
+// Error codes carried by OpResult for the errors above
+var errorCodes = map[string]error{
+	"invalid_path": ErrInvalidPath,
+	"unsupported":  ErrUnsupported,
+	"read_only":    ErrReadOnly,
+	"not_empty":    ErrNotEmpty,
+}
+
+// ManifestRequest asks for the manifest of Path, or of its subtree Sub
+type ManifestRequest struct {
+	Path            string `json:"path"`
+	Sub             string `json:"sub,omitempty"`
+	IfNoneMatch     string `json:"if_none_match,omitempty"`
+	IfModifiedSince string `json:"if_modified_since,omitempty"`
+}
+
+// ManifestResult is a receiver's answer to a ManifestRequest
+type ManifestResult struct {
+	ETag         string
+	LastModified time.Time
+	NotModified  bool
+	WriteTo      func(w io.Writer) error // Streams the manifest as NDJSON
+}
+
+// ManifestStream is the client side of a manifest call. Body is nil when NotModified.
+type ManifestStream struct {
+	Body         io.ReadCloser
+	ContentType  string
+	ETag         string
+	LastModified string
+	NotModified  bool
+}
+
+// ChangesRequest asks which subtrees of Path changed after cursor Since
+type ChangesRequest struct {
+	Path  string `json:"path"`
+	Since string `json:"since,omitempty"`
+}
+
+// ChangesResponse lists changed subtrees; Reset requires a full manifest
+type ChangesResponse struct {
+	Cursor  string   `json:"cursor"`
+	Reset   bool     `json:"reset"`
+	Changes []string `json:"changes"`
+}
+
+// StatRequest asks for the metadata of one file
+type StatRequest struct {
+	Path string `json:"path"`
+}
+
+// StatResponse describes a file on the receiver
+type StatResponse struct {
+	Exists  bool      `json:"exists"`
+	Size    int64     `json:"size"`
+	IsDir   bool      `json:"is_dir,omitempty"`
+	ModTime time.Time `json:"mod_time,omitempty"`
+}
+
+// DeleteRequest removes a file, or a directory tree when Dir is set. With
+// Empty only an empty directory is removed, otherwise ErrNotEmpty is returned.
+type DeleteRequest struct {
+	Path  string `json:"path"`
+	Dir   bool   `json:"dir,omitempty"`
+	Empty bool   `json:"empty,omitempty"`
+}
+
+// DeleteResponse reports whether anything was removed
+type DeleteResponse struct {
+	Deleted bool `json:"deleted"`
+}
+
+// MkdirRequest creates a directory with its parents
+type MkdirRequest struct {
+	Path string `json:"path"`
+}
+
+// MkdirResponse reports whether the directory was missing
+type MkdirResponse struct {
+	Created bool `json:"created"`
+}
+
+// MetadataRequest sets the modification time and permission bits of a
+// receiver file or directory; zero values leave them unchanged
+type MetadataRequest struct {
+	Path    string    `json:"path"`
+	ModTime time.Time `json:"mod_time,omitempty"`
+	Mode    uint32    `json:"mode,omitempty"` // Permission bits, e.g. 0755
+}
+
+// MetadataResponse reports whether the path exists
+type MetadataResponse struct {
+	Exists bool `json:"exists"`
+}
+
+// MoveRequest renames a file or directory below the receiver root. An
+// existing To is never replaced.
+type MoveRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// MoveResponse reports whether anything was moved
+type MoveResponse struct {
+	Moved bool `json:"moved"`
+}
+
+// Operations of a batch
+const (
+	OpDelete   = "delete"
+	OpMkdir    = "mkdir"
+	OpMove     = "move"
+	OpMetadata = "metadata"
+)
+
+// MaxBatchOps is the most operations a batch may hold
+const MaxBatchOps = 1000
+
+// Op is one operation of a batch with the fields of the matching call: Dir
+// and Empty for OpDelete, To for OpMove (Path is the source), ModTime and
+// Mode for OpMetadata
+type Op struct {
+	Op      string    `json:"op"`
+	Path    string    `json:"path"`
+	To      string    `json:"to,omitempty"`
+	Dir     bool      `json:"dir,omitempty"`
+	Empty   bool      `json:"empty,omitempty"`
+	ModTime time.Time `json:"mod_time,omitempty"`
+	Mode    uint32    `json:"mode,omitempty"`
+}
+
+// BatchRequest runs operations in order as one transaction: when one fails,
+// the ones before it are rolled back and the ones after it skipped
+type BatchRequest struct {
+	Ops []Op `json:"ops"`
+}
+
+// OpResult is the outcome of one operation of a batch. Changed is set when
+// it deleted, created, moved or updated something.
+type OpResult struct {
+	Changed bool   `json:"changed,omitempty"`
+	Skipped bool   `json:"skipped,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Code    string `json:"code,omitempty"` // Set for the errors of this package
+}
+
+// Err returns the error of a failed operation, one of this package's
+// errors where the code names it
+func (r OpResult) Err() error {
+	if r.Error == "" {
+		return nil
+	}
+	if err, ok := errorCodes[r.Code]; ok {
+		if r.Error == err.Error() {
+			return err
+		}
+		return fmt.Errorf("%w: %s", err, strings.TrimPrefix(r.Error, err.Error()+": "))
+	}
+	return errors.New(r.Error)
+}
+
+// ErrorCode returns the OpResult code of err, "" for other errors
+func ErrorCode(err error) string {
+	for code, e := range errorCodes {
+		if errors.Is(err, e) {
+			return code
+		}
+	}
+	return ""
+}
+
+// BatchResponse holds one result per operation. Nothing is applied unless
+// Committed; the result of the failed operation tells why.
+type BatchResponse struct {
+	Committed bool       `json:"committed"`
+	Results   []OpResult `json:"results"`
+}
+
+// HashRequest asks for the checksum of one file
+type HashRequest struct {
+	Path string `json:"path"`
+}
+
+// HashResponse is a file checksum
+type HashResponse struct {
+	Algorithm string `json:"algorithm"`
+	Sum       string `json:"sum"`
+	Size      int64  `json:"size"`
+}
+
+// SearchRequest looks for files below Path whose name matches Query, a
+// case-insensitive substring or a glob such as "*.mkv"
+type SearchRequest struct {
+	Path  string `json:"path"`
+	Query string `json:"query"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+// SearchMatch is a file found by a search, with Path relative to the search root
+type SearchMatch struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	IsDir   bool      `json:"is_dir,omitempty"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// SearchResponse lists matches; Truncated is set when Limit was reached
+type SearchResponse struct {
+	Matches   []SearchMatch `json:"matches"`
+	Truncated bool          `json:"truncated"`
+}
+
+// ListRequest asks for one page of the entries of directory Path
+type ListRequest struct {
+	Path   string `json:"path"`
+	Offset int    `json:"offset,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+}
+
+// ListEntry is a directory entry. Partial marks unfinished transfers: the
+// .tmp files of local copies and rsync's hidden temporary files.
+type ListEntry struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	IsDir   bool      `json:"is_dir,omitempty"`
+	ModTime time.Time `json:"mod_time"`
+	Partial bool      `json:"partial,omitempty"`
+}
+
+// ListResponse is a page of a directory listing, directories first
+type ListResponse struct {
+	Path    string      `json:"path"`
+	Entries []ListEntry `json:"entries"`
+	Total   int         `json:"total"`
+	Offset  int         `json:"offset"`
+	Limit   int         `json:"limit"`
+}
+
+// HealthResponse describes the receiver
+type HealthResponse struct {
+	Status   string    `json:"status"`
+	Protocol int       `json:"protocol"`
+	Journal  bool      `json:"journal"`
+	Time     time.Time `json:"time"`
+	// ReadOnly is set while the receiver refuses transfers and deletes
+	ReadOnly       bool   `json:"read_only,omitempty"`
+	ReadOnlyReason string `json:"read_only_reason,omitempty"`
+}
+
+// SuspendResponse confirms that the receiver is about to suspend
+type SuspendResponse struct {
+	Delay time.Duration `json:"delay"` // Time until the suspend command runs
+}
+
+// Service is implemented by receivers
+type Service interface {
+	Manifest(ctx context.Context, req *ManifestRequest) (*ManifestResult, error)
+	Changes(ctx context.Context, req *ChangesRequest) (*ChangesResponse, error)
+	Stat(ctx context.Context, req *StatRequest) (*StatResponse, error)
+	Delete(ctx context.Context, req *DeleteRequest) (*DeleteResponse, error)
+	Mkdir(ctx context.Context, req *MkdirRequest) (*MkdirResponse, error)
+	Move(ctx context.Context, req *MoveRequest) (*MoveResponse, error)
+	SetMetadata(ctx context.Context, req *MetadataRequest) (*MetadataResponse, error)
+	Batch(ctx context.Context, req *BatchRequest) (*BatchResponse, error)
+	Hash(ctx context.Context, req *HashRequest) (*HashResponse, error)
+	Search(ctx context.Context, req *SearchRequest) (*SearchResponse, error)
+	List(ctx context.Context, req *ListRequest) (*ListResponse, error)
+	Health(ctx context.Context) (*HealthResponse, error)
+	Suspend(ctx context.Context) (*SuspendResponse, error)
+}
+
+// NotModified evaluates If-None-Match, falling back to If-Modified-Since
+func NotModified(ifNoneMatch, ifModifiedSince, etag string, changed time.Time) bool {
+	if ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			if c := strings.TrimSpace(candidate); c == etag || c == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	if since, err := http.ParseTime(ifModifiedSince); err == nil {
+		return !changed.Truncate(time.Second).After(since)
+	}
+	return false
+}
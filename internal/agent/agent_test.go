@@ -0,0 +1,283 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeService struct {
+	deleted []string
+	created []string
+	moved   []string
+	touched []string
+}
+
+func (f *fakeService) Manifest(ctx context.Context, req *ManifestRequest) (*ManifestResult, error) {
+	if strings.Contains(req.Path, "..") {
+		return nil, ErrInvalidPath
+	}
+	etag := `"v1"`
+	return &ManifestResult{
+		ETag:        etag,
+		NotModified: NotModified(req.IfNoneMatch, req.IfModifiedSince, etag, time.Now()),
+		WriteTo: func(w io.Writer) error {
+			_, err := io.WriteString(w, `{"root":"`+req.Path+`","count":0}`+"\n")
+			return err
+		},
+	}, nil
+}
+
+func (f *fakeService) Changes(ctx context.Context, req *ChangesRequest) (*ChangesResponse, error) {
+	return &ChangesResponse{Cursor: "e-2", Changes: []string{"A"}}, nil
+}
+
+func (f *fakeService) Stat(ctx context.Context, req *StatRequest) (*StatResponse, error) {
+	return &StatResponse{Exists: req.Path == "a.mkv", Size: 42}, nil
+}
+
+func (f *fakeService) Delete(ctx context.Context, req *DeleteRequest) (*DeleteResponse, error) {
+	f.deleted = append(f.deleted, req.Path)
+	return &DeleteResponse{Deleted: true}, nil
+}
+
+func (f *fakeService) Mkdir(ctx context.Context, req *MkdirRequest) (*MkdirResponse, error) {
+	f.created = append(f.created, req.Path)
+	return &MkdirResponse{Created: true}, nil
+}
+
+func (f *fakeService) Move(ctx context.Context, req *MoveRequest) (*MoveResponse, error) {
+	f.moved = append(f.moved, req.From+" -> "+req.To)
+	return &MoveResponse{Moved: true}, nil
+}
+
+func (f *fakeService) SetMetadata(ctx context.Context, req *MetadataRequest) (*MetadataResponse, error) {
+	f.touched = append(f.touched, req.Path)
+	return &MetadataResponse{Exists: true}, nil
+}
+
+func (f *fakeService) Batch(ctx context.Context, req *BatchRequest) (*BatchResponse, error) {
+	resp := &BatchResponse{Committed: true}
+	for _, op := range req.Ops {
+		switch {
+		case !resp.Committed:
+			resp.Results = append(resp.Results, OpResult{Skipped: true})
+		case op.Path == "full":
+			resp.Committed = false
+			resp.Results = append(resp.Results, OpResult{Error: ErrNotEmpty.Error(), Code: ErrorCode(ErrNotEmpty)})
+		default:
+			resp.Results = append(resp.Results, OpResult{Changed: true})
+		}
+	}
+	return resp, nil
+}
+
+func (f *fakeService) Hash(ctx context.Context, req *HashRequest) (*HashResponse, error) {
+	return &HashResponse{Algorithm: "sha256", Sum: "abc", Size: 42}, nil
+}
+
+func (f *fakeService) Search(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
+	return &SearchResponse{Matches: []SearchMatch{{Path: "tv/" + req.Query, Size: 42}}}, nil
+}
+
+func (f *fakeService) List(ctx context.Context, req *ListRequest) (*ListResponse, error) {
+	return &ListResponse{Path: req.Path, Entries: []ListEntry{{Name: "a.mkv.tmp", Size: 42, Partial: true}}, Total: 1}, nil
+}
+
+func (f *fakeService) Health(ctx context.Context) (*HealthResponse, error) {
+	return &HealthResponse{Status: "healthy", Protocol: Version}, nil
+}
+
+func (f *fakeService) Suspend(ctx context.Context) (*SuspendResponse, error) {
+	return nil, ErrUnsupported
+}
+
+func TestClient_AgentProtocol(t *testing.T) {
+	svc := &fakeService{}
+	mux := http.NewServeMux()
+	mux.Handle(PathPrefix, NewHandler(svc))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := NewClient(srv.URL)
+	if stat, err := c.Stat(ctx, &StatRequest{Path: "a.mkv"}); err != nil || !stat.Exists || stat.Size != 42 {
+		t.Errorf("Unexpected stat: %+v, %v", stat, err)
+	}
+	if _, err := c.Delete(ctx, &DeleteRequest{Path: "old.mkv"}); err != nil || len(svc.deleted) != 1 {
+		t.Errorf("Delete failed: %v", err)
+	}
+	if _, err := c.Mkdir(ctx, &MkdirRequest{Path: "tv/Show/Season 2"}); err != nil || len(svc.created) != 1 {
+		t.Errorf("Mkdir failed: %v", err)
+	}
+	if _, err := c.Move(ctx, &MoveRequest{From: "tv/Show", To: "tv/Show (2020)"}); err != nil || len(svc.moved) != 1 {
+		t.Errorf("Move failed: %v", err)
+	}
+	if resp, err := c.SetMetadata(ctx, &MetadataRequest{Path: "tv/Show", ModTime: time.Now(), Mode: 0755}); err != nil || !resp.Exists || len(svc.touched) != 1 {
+		t.Errorf("SetMetadata failed: %+v, %v", resp, err)
+	}
+	batch, err := c.Batch(ctx, &BatchRequest{Ops: []Op{{Op: OpDelete, Path: "a.mkv"}, {Op: OpDelete, Path: "full", Dir: true, Empty: true}, {Op: OpMkdir, Path: "tv"}}})
+	if err != nil || batch.Committed || len(batch.Results) != 3 {
+		t.Fatalf("Batch = %+v, %v", batch, err)
+	}
+	if !errors.Is(batch.Results[1].Err(), ErrNotEmpty) || !batch.Results[2].Skipped {
+		t.Errorf("Batch results should carry the error and skip the rest: %+v", batch.Results)
+	}
+	if _, err := c.Batch(ctx, &BatchRequest{Ops: make([]Op, MaxBatchOps+1)}); err == nil {
+		t.Error("Batches over MaxBatchOps should be rejected")
+	}
+	if hash, err := c.Hash(ctx, &HashRequest{Path: "a.mkv"}); err != nil || hash.Sum != "abc" {
+		t.Errorf("Unexpected hash: %+v, %v", hash, err)
+	}
+	if found, err := c.Search(ctx, &SearchRequest{Query: "a.mkv"}); err != nil || len(found.Matches) != 1 || found.Matches[0].Path != "tv/a.mkv" {
+		t.Errorf("Unexpected search: %+v, %v", found, err)
+	}
+	if list, err := c.List(ctx, &ListRequest{Path: "tv"}); err != nil || list.Total != 1 || !list.Entries[0].Partial {
+		t.Errorf("Unexpected listing: %+v, %v", list, err)
+	}
+	if changes, err := c.Changes(ctx, &ChangesRequest{Path: "tv"}); err != nil || changes.Cursor != "e-2" {
+		t.Errorf("Unexpected changes: %+v, %v", changes, err)
+	}
+	if health, err := c.Health(ctx); err != nil || health.Protocol != Version {
+		t.Errorf("Unexpected health: %+v, %v", health, err)
+	}
+	if _, err := c.Suspend(ctx); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Receivers without a suspend command should return ErrUnsupported, got %v", err)
+	}
+
+	stream, err := c.Manifest(ctx, &ManifestRequest{Path: "tv"})
+	if err != nil {
+		t.Fatalf("Manifest failed: %v", err)
+	}
+	body, _ := io.ReadAll(stream.Body)
+	_ = stream.Body.Close()
+	if stream.ContentType != ManifestNDJSON || stream.ETag != `"v1"` || !strings.Contains(string(body), `"root":"tv"`) {
+		t.Errorf("Unexpected manifest stream: %+v %s", stream, body)
+	}
+	if stream, err := c.Manifest(ctx, &ManifestRequest{Path: "tv", IfNoneMatch: `"v1"`}); err != nil || !stream.NotModified {
+		t.Errorf("Expected Not Modified, got %+v, %v", stream, err)
+	}
+	if _, err := c.Manifest(ctx, &ManifestRequest{Path: "../etc"}); err == nil || !strings.Contains(err.Error(), "invalid path") {
+		t.Errorf("Expected invalid path error, got %v", err)
+	}
+	if c.Legacy() {
+		t.Error("Client should not fall back with an agent receiver")
+	}
+}
+
+func TestServer_RejectsNewerProtocol(t *testing.T) {
+	req := httptest.NewRequest("POST", PathPrefix+"Health", strings.NewReader("{}"))
+	req.Header.Set(HeaderVersion, "99")
+	w := httptest.NewRecorder()
+	NewHandler(&fakeService{}).ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a newer protocol, got %d", w.Code)
+	}
+}
+
+func TestClient_LegacyFallback(t *testing.T) {
+	var deletes int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/stat", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"size":7,"exists":true}`)
+	})
+	mux.HandleFunc("/api/delete", func(w http.ResponseWriter, r *http.Request) {
+		deletes++
+		if r.URL.Query().Get("dir") != "true" {
+			t.Errorf("Expected dir=true, got %s", r.URL.RawQuery)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := NewClient(srv.URL)
+	if stat, err := c.Stat(ctx, &StatRequest{Path: "a.mkv"}); err != nil || stat.Size != 7 {
+		t.Errorf("Legacy stat failed: %+v, %v", stat, err)
+	}
+	if !c.Legacy() {
+		t.Error("Client should remember the legacy receiver")
+	}
+	if resp, err := c.Delete(ctx, &DeleteRequest{Path: "dir", Dir: true}); err != nil || resp.Deleted || deletes != 1 {
+		t.Errorf("Legacy delete failed: %+v, %v", resp, err)
+	}
+	if _, err := c.Hash(ctx, &HashRequest{Path: "a.mkv"}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Legacy hash should be unsupported, got %v", err)
+	}
+}
+
+func TestReceiverURL(t *testing.T) {
+	if got := ReceiverURL("nas.lan"); got != "http://nas.lan:8080" {
+		t.Errorf("ReceiverURL = %q, want the default port", got)
+	}
+	t.Setenv("RECEIVER_PORT", "8090")
+	if got := ReceiverURL("fd00::2"); got != "http://[fd00::2]:8090" {
+		t.Errorf("ReceiverURL = %q, want RECEIVER_PORT and a bracketed IPv6 host", got)
+	}
+}
+
+func TestGuard(t *testing.T) {
+	svc := &fakeService{}
+	mux := http.NewServeMux()
+	mux.Handle(PathPrefix, NewHandler(svc))
+	srv := httptest.NewServer(Guard(mux, "s3cret", nil))
+	defer srv.Close()
+	ctx := context.Background()
+
+	if _, err := NewClient(srv.URL).Delete(ctx, &DeleteRequest{Path: "old.mkv"}); err == nil || len(svc.deleted) != 0 {
+		t.Errorf("Calls without the token should be rejected, got %v", err)
+	}
+	t.Setenv("AGENT_TOKEN", "s3cret")
+	if _, err := NewClient(srv.URL).Delete(ctx, &DeleteRequest{Path: "old.mkv"}); err != nil || len(svc.deleted) != 1 {
+		t.Errorf("Client should send AGENT_TOKEN, got %v", err)
+	}
+}
+
+func TestAllowPeers(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle(PathPrefix, NewHandler(&fakeService{}))
+	allowed, err := ParseAllowed("10.0.0.0/8, 192.168.1.5")
+	if err != nil || len(allowed) != 2 {
+		t.Fatalf("ParseAllowed = %v, %v", allowed, err)
+	}
+	if _, err := ParseAllowed("nas.lan"); err == nil {
+		t.Error("Host names should be rejected")
+	}
+	var denied []string
+	guarded := AllowPeers(mux, allowed, func(r *http.Request, reason string) { denied = append(denied, r.RemoteAddr) })
+	for remote, want := range map[string]int{
+		"10.1.2.3:5000":    http.StatusOK,
+		"192.168.1.5:5000": http.StatusOK,
+		"192.168.1.6:5000": http.StatusForbidden,
+		"@":                http.StatusOK, // Unix socket
+	} {
+		req := httptest.NewRequest("POST", PathPrefix+"Health", strings.NewReader("{}"))
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		guarded.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("Call from %s = %d, want %d", remote, w.Code, want)
+		}
+	}
+	if len(denied) != 1 || denied[0] != "192.168.1.6:5000" {
+		t.Errorf("The denied hook should see the rejected call, got %v", denied)
+	}
+}
+
+func TestClient_UnknownMethodUnsupported(t *testing.T) {
+	// A receiver from before Mkdir existed
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderVersion, "1")
+		writeError(w, http.StatusNotFound, "unknown method")
+	}))
+	defer srv.Close()
+	if _, err := NewClient(srv.URL).Mkdir(context.Background(), &MkdirRequest{Path: "tv/Show"}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Unknown methods should map to ErrUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ParseAllowed parses a comma-separated list of IPs and CIDR ranges
+func ParseAllowed(list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP %q", item)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q", item)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// AllowPeers lets only callers connecting from one of allowed through to
+// next (anyone when empty). Unix socket peers are local and pass. denied,
+// when set, is told about every rejected call.
+func AllowPeers(next http.Handler, allowed []*net.IPNet, denied func(r *http.Request, reason string)) http.Handler {
+	if len(allowed) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowedPeer(r.RemoteAddr, allowed) {
+			reject(w, r, http.StatusForbidden, "peer not allowed", denied)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func allowedPeer(remoteAddr string, allowed []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return true
+	}
+	for _, n := range allowed {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package agent
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Token is the shared secret of the agent API (AGENT_TOKEN). Clients send
+// it as a bearer token; receivers with one set reject calls without it.
+func Token() string {
+	return os.Getenv("AGENT_TOKEN")
+}
+
+// Guard is the middleware of the agent API, independent of the dashboard
+// login: callers must present token (not checked when empty). denied, when
+// set, is told about every rejected call.
+func Guard(next http.Handler, token string, denied func(r *http.Request, reason string)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" {
+			given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				reject(w, r, http.StatusUnauthorized, "agent token required", denied)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// reject answers a call the agent API middleware turned down
+func reject(w http.ResponseWriter, r *http.Request, status int, reason string, denied func(r *http.Request, reason string)) {
+	logger.Warn("Agent API call denied", "remote", r.RemoteAddr, "method", r.Method, "path", r.URL.Path, "reason", reason)
+	if denied != nil {
+		denied(r, reason)
+	}
+	writeError(w, status, reason)
+}
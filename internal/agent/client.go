@@ -0,0 +1,372 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	stdsync "sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultPort is the receiver's HTTP port
+const DefaultPort = 8080
+
+// ReceiverURL returns the base URL of a receiver's agent API. RECEIVER_PORT
+// overrides DefaultPort for receivers with their own AGENT_LISTEN_ADDR.
+func ReceiverURL(host string) string {
+	port := DefaultPort
+	if p, err := strconv.Atoi(os.Getenv("RECEIVER_PORT")); err == nil && p > 0 {
+		port = p
+	}
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// legacyRecheck is how long a client keeps using the legacy endpoints before
+// probing for the agent protocol again, so upgraded receivers are picked up
+var legacyRecheck = 10 * time.Minute
+
+// Client calls a receiver's agent service
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+
+	legacyUntil atomic.Int64 // Unix nanoseconds until which legacy endpoints are used
+}
+
+var (
+	clientsMu stdsync.Mutex
+	clients   = make(map[string]*Client)
+)
+
+// NewClient returns a client for the receiver at baseURL (e.g. http://host:8080)
+func NewClient(baseURL string) *Client {
+	return &Client{baseURL: baseURL, token: Token(), http: &http.Client{}}
+}
+
+// ForHost returns the shared client for a receiver host
+func ForHost(host string) *Client {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	c, ok := clients[host]
+	if !ok {
+		c = NewClient(ReceiverURL(host))
+		clients[host] = c
+	}
+	return c
+}
+
+// BaseURL returns the receiver URL
+func (c *Client) BaseURL() string { return c.baseURL }
+
+// do sends req with the agent token
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return c.http.Do(req)
+}
+
+// Legacy reports whether the receiver was found to predate the agent protocol
+func (c *Client) Legacy() bool {
+	return time.Now().UnixNano() < c.legacyUntil.Load()
+}
+
+// post performs an agent call. ok is false when the receiver has no agent
+// service and the caller should use the legacy endpoint.
+func (c *Client) post(ctx context.Context, method string, req interface{}, header http.Header) (resp *http.Response, ok bool, err error) {
+	if c.Legacy() {
+		return nil, false, nil
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, false, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+PathPrefix+method, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+	for k, v := range header {
+		httpReq.Header[k] = v
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(HeaderVersion, strconv.Itoa(Version))
+	resp, err = c.do(httpReq)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to contact receiver agent at %s: %w", httpReq.URL.Host, err)
+	}
+	if resp.Header.Get(HeaderVersion) == "" && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed) {
+		_ = resp.Body.Close()
+		c.legacyUntil.Store(time.Now().Add(legacyRecheck).UnixNano())
+		logger.Info("Receiver has no agent service, using legacy API", "receiver", c.baseURL)
+		return nil, false, nil
+	}
+	return resp, true, nil
+}
+
+// call performs a unary agent call, falling back to legacy when needed
+func (c *Client) call(ctx context.Context, method string, req, out interface{}, legacy func() error) error {
+	resp, ok, err := c.post(ctx, method, req, nil)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return legacy()
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if err := checkStatus(resp); err != nil {
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", method, err)
+	}
+	return nil
+}
+
+// checkStatus turns an error response into an error carrying its message
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	// Receivers predating a method answer it as unknown
+	if resp.StatusCode == http.StatusNotImplemented || (resp.StatusCode == http.StatusNotFound && resp.Header.Get(HeaderVersion) != "") {
+		return ErrUnsupported
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	decoded := json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&body) == nil && body.Error != ""
+	if resp.StatusCode == http.StatusLocked {
+		if decoded && body.Error != ErrReadOnly.Error() {
+			return fmt.Errorf("%w: %s", ErrReadOnly, strings.TrimPrefix(body.Error, ErrReadOnly.Error()+": "))
+		}
+		return ErrReadOnly
+	}
+	if resp.StatusCode == http.StatusConflict {
+		return ErrNotEmpty
+	}
+	if decoded {
+		return fmt.Errorf("receiver API returned status %s: %s", resp.Status, body.Error)
+	}
+	return fmt.Errorf("receiver API returned status %s", resp.Status)
+}
+
+// legacyGet performs a GET against a legacy endpoint and decodes the JSON answer
+func (c *Client) legacyGet(ctx context.Context, path string, query url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to contact receiver API at %s: %w", req.URL.Host, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if err := checkStatus(resp); err != nil {
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Manifest streams the manifest of a receiver path. The caller closes Body.
+func (c *Client) Manifest(ctx context.Context, req *ManifestRequest) (*ManifestStream, error) {
+	header := http.Header{}
+	header.Set("Accept", ManifestNDJSON+", application/json;q=0.5")
+	resp, ok, err := c.post(ctx, "Manifest", req, header)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if resp, err = c.legacyManifest(ctx, req, header); err != nil {
+			return nil, err
+		}
+	}
+	stream := &ManifestStream{
+		ContentType:  resp.Header.Get("Content-Type"),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		_ = resp.Body.Close()
+		stream.NotModified = true
+		return stream, nil
+	}
+	if err := checkStatus(resp); err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	stream.Body = resp.Body
+	return stream, nil
+}
+
+func (c *Client) legacyManifest(ctx context.Context, req *ManifestRequest, header http.Header) (*http.Response, error) {
+	query := url.Values{"path": {req.Path}}
+	if req.Sub != "" {
+		query.Set("sub", req.Sub)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/api/manifest?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header = header
+	if req.IfNoneMatch != "" {
+		httpReq.Header.Set("If-None-Match", req.IfNoneMatch)
+	}
+	if req.IfModifiedSince != "" {
+		httpReq.Header.Set("If-Modified-Since", req.IfModifiedSince)
+	}
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to contact receiver API at %s: %w", httpReq.URL.Host, err)
+	}
+	return resp, nil
+}
+
+// Changes asks the receiver's change journal what changed since a cursor
+func (c *Client) Changes(ctx context.Context, req *ChangesRequest) (*ChangesResponse, error) {
+	out := &ChangesResponse{}
+	err := c.call(ctx, "Changes", req, out, func() error {
+		return c.legacyGet(ctx, "/api/changes", url.Values{"path": {req.Path}, "since": {req.Since}}, out)
+	})
+	return out, err
+}
+
+// Stat returns the metadata of a receiver file
+func (c *Client) Stat(ctx context.Context, req *StatRequest) (*StatResponse, error) {
+	out := &StatResponse{}
+	err := c.call(ctx, "Stat", req, out, func() error {
+		return c.legacyGet(ctx, "/api/stat", url.Values{"path": {req.Path}}, out)
+	})
+	return out, err
+}
+
+// Delete removes a receiver file or directory tree. Legacy receivers return
+// ErrUnsupported for Empty, which they would ignore.
+func (c *Client) Delete(ctx context.Context, req *DeleteRequest) (*DeleteResponse, error) {
+	out := &DeleteResponse{}
+	err := c.call(ctx, "Delete", req, out, func() error {
+		if req.Empty {
+			return ErrUnsupported
+		}
+		query := url.Values{"path": {req.Path}, "dir": {strconv.FormatBool(req.Dir)}}
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/delete?"+query.Encode(), nil)
+		if err != nil {
+			return err
+		}
+		resp, err := c.do(httpReq)
+		if err != nil {
+			return fmt.Errorf("failed to contact receiver API: %w", err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		switch resp.StatusCode {
+		case http.StatusOK:
+			out.Deleted = true
+		case http.StatusNoContent:
+		case http.StatusLocked:
+			return checkStatus(resp)
+		default:
+			return fmt.Errorf("receiver API returned status %s", resp.Status)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// Mkdir creates a receiver directory with its parents. Legacy receivers,
+// and receivers predating the call, return ErrUnsupported.
+func (c *Client) Mkdir(ctx context.Context, req *MkdirRequest) (*MkdirResponse, error) {
+	out := &MkdirResponse{}
+	err := c.call(ctx, "Mkdir", req, out, func() error { return ErrUnsupported })
+	return out, err
+}
+
+// Move renames a receiver file or directory. Legacy receivers, and
+// receivers predating the call, return ErrUnsupported.
+func (c *Client) Move(ctx context.Context, req *MoveRequest) (*MoveResponse, error) {
+	out := &MoveResponse{}
+	err := c.call(ctx, "Move", req, out, func() error { return ErrUnsupported })
+	return out, err
+}
+
+// SetMetadata sets the modification time and permissions of a receiver file
+// or directory. Legacy receivers, and receivers predating the call, return
+// ErrUnsupported.
+func (c *Client) SetMetadata(ctx context.Context, req *MetadataRequest) (*MetadataResponse, error) {
+	out := &MetadataResponse{}
+	err := c.call(ctx, "SetMetadata", req, out, func() error { return ErrUnsupported })
+	return out, err
+}
+
+// Batch runs operations on the receiver as one transaction. Legacy
+// receivers, and receivers predating the call, return ErrUnsupported.
+func (c *Client) Batch(ctx context.Context, req *BatchRequest) (*BatchResponse, error) {
+	out := &BatchResponse{}
+	err := c.call(ctx, "Batch", req, out, func() error { return ErrUnsupported })
+	return out, err
+}
+
+// Hash returns the checksum of a receiver file. Legacy receivers return ErrUnsupported.
+func (c *Client) Hash(ctx context.Context, req *HashRequest) (*HashResponse, error) {
+	out := &HashResponse{}
+	err := c.call(ctx, "Hash", req, out, func() error { return ErrUnsupported })
+	return out, err
+}
+
+// Search looks for files on the receiver by name
+func (c *Client) Search(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
+	out := &SearchResponse{}
+	err := c.call(ctx, "Search", req, out, func() error {
+		query := url.Values{"path": {req.Path}, "q": {req.Query}}
+		if req.Limit > 0 {
+			query.Set("limit", strconv.Itoa(req.Limit))
+		}
+		return c.legacyGet(ctx, "/api/search", query, out)
+	})
+	return out, err
+}
+
+// List returns one page of a receiver directory
+func (c *Client) List(ctx context.Context, req *ListRequest) (*ListResponse, error) {
+	out := &ListResponse{}
+	err := c.call(ctx, "List", req, out, func() error {
+		query := url.Values{"path": {req.Path}, "offset": {strconv.Itoa(req.Offset)}}
+		if req.Limit > 0 {
+			query.Set("limit", strconv.Itoa(req.Limit))
+		}
+		return c.legacyGet(ctx, "/api/list", query, out)
+	})
+	return out, err
+}
+
+// Health checks the receiver. Legacy receivers report protocol 0.
+func (c *Client) Health(ctx context.Context) (*HealthResponse, error) {
+	out := &HealthResponse{}
+	err := c.call(ctx, "Health", struct{}{}, out, func() error {
+		var legacy struct {
+			Status string `json:"status"`
+		}
+		if err := c.legacyGet(ctx, "/health", nil, &legacy); err != nil {
+			return err
+		}
+		out.Status = legacy.Status
+		return nil
+	})
+	return out, err
+}
+
+// Suspend asks the receiver to run its suspend command. Receivers without
+// one, and legacy receivers, return ErrUnsupported.
+func (c *Client) Suspend(ctx context.Context) (*SuspendResponse, error) {
+	out := &SuspendResponse{}
+	err := c.call(ctx, "Suspend", struct{}{}, out, func() error { return ErrUnsupported })
+	return out, err
+}
@@ -0,0 +1,178 @@
+package agent
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"schnorarr/internal/monitor/logging"
+)
+
+var logger = logging.For("agent")
+
+// NewHandler serves svc under PathPrefix
+func NewHandler(svc Service) http.Handler {
+	return &server{svc: svc}
+}
+
+type server struct {
+	svc Service
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(HeaderVersion, strconv.Itoa(Version))
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if v, err := strconv.Atoi(r.Header.Get(HeaderVersion)); err == nil && v > Version {
+		writeError(w, http.StatusBadRequest, "unsupported agent protocol version "+strconv.Itoa(v))
+		return
+	}
+
+	ctx := r.Context()
+	switch strings.TrimPrefix(r.URL.Path, PathPrefix) {
+	case "Manifest":
+		var req ManifestRequest
+		if decode(w, r, &req) {
+			s.manifest(w, r, &req)
+		}
+	case "Changes":
+		var req ChangesRequest
+		if decode(w, r, &req) {
+			reply(w)(s.svc.Changes(ctx, &req))
+		}
+	case "Stat":
+		var req StatRequest
+		if decode(w, r, &req) {
+			reply(w)(s.svc.Stat(ctx, &req))
+		}
+	case "Delete":
+		var req DeleteRequest
+		if decode(w, r, &req) {
+			reply(w)(s.svc.Delete(ctx, &req))
+		}
+	case "Mkdir":
+		var req MkdirRequest
+		if decode(w, r, &req) {
+			reply(w)(s.svc.Mkdir(ctx, &req))
+		}
+	case "Move":
+		var req MoveRequest
+		if decode(w, r, &req) {
+			reply(w)(s.svc.Move(ctx, &req))
+		}
+	case "SetMetadata":
+		var req MetadataRequest
+		if decode(w, r, &req) {
+			reply(w)(s.svc.SetMetadata(ctx, &req))
+		}
+	case "Batch":
+		var req BatchRequest
+		if decode(w, r, &req) {
+			if len(req.Ops) > MaxBatchOps {
+				writeError(w, http.StatusBadRequest, "too many operations")
+				return
+			}
+			reply(w)(s.svc.Batch(ctx, &req))
+		}
+	case "Hash":
+		var req HashRequest
+		if decode(w, r, &req) {
+			reply(w)(s.svc.Hash(ctx, &req))
+		}
+	case "Search":
+		var req SearchRequest
+		if decode(w, r, &req) {
+			reply(w)(s.svc.Search(ctx, &req))
+		}
+	case "List":
+		var req ListRequest
+		if decode(w, r, &req) {
+			reply(w)(s.svc.List(ctx, &req))
+		}
+	case "Health":
+		reply(w)(s.svc.Health(ctx))
+	case "Suspend":
+		reply(w)(s.svc.Suspend(ctx))
+	default:
+		writeError(w, http.StatusNotFound, "unknown method")
+	}
+}
+
+func (s *server) manifest(w http.ResponseWriter, r *http.Request, req *ManifestRequest) {
+	res, err := s.svc.Manifest(r.Context(), req)
+	if err != nil {
+		fail(w, err)
+		return
+	}
+	if res.ETag != "" {
+		w.Header().Set("ETag", res.ETag)
+	}
+	if !res.LastModified.IsZero() {
+		w.Header().Set("Last-Modified", res.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if res.NotModified {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	var out io.Writer = w
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer func() { _ = gz.Close() }()
+		out = gz
+	}
+	w.Header().Set("Content-Type", ManifestNDJSON)
+	if err := res.WriteTo(out); err != nil {
+		logger.Error("Failed to stream manifest", "path", req.Path, "error", err)
+	}
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body")
+		return false
+	}
+	return true
+}
+
+// reply returns a function writing a call's result, so handlers can pass a
+// (response, error) pair straight through
+func reply(w http.ResponseWriter) func(interface{}, error) {
+	return func(resp interface{}, err error) {
+		if err != nil {
+			fail(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			logger.Error("Failed to encode agent response", "error", err)
+		}
+	}
+}
+
+func fail(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, ErrInvalidPath) {
+		status = http.StatusBadRequest
+	} else if errors.Is(err, ErrUnsupported) {
+		status = http.StatusNotImplemented
+	} else if errors.Is(err, ErrReadOnly) {
+		status = http.StatusLocked
+	} else if errors.Is(err, ErrNotEmpty) {
+		status = http.StatusConflict
+	}
+	writeError(w, status, err.Error())
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
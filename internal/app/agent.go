@@ -0,0 +1,254 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"schnorarr/internal/agent"
+	"schnorarr/internal/monitor/scheduler"
+	"schnorarr/internal/sync"
+)
+
+// agentService answers the agent protocol with the same path resolution as
+// the legacy /api/* handlers
+type agentService struct {
+	app *App
+}
+
+func (s agentService) Manifest(ctx context.Context, req *agent.ManifestRequest) (*agent.ManifestResult, error) {
+	if req.Path == "" {
+		return nil, agent.ErrInvalidPath
+	}
+	manifest, etag, changed, err := s.app.scanManifest(req.Path, req.Sub)
+	if err != nil {
+		return nil, err
+	}
+	return &agent.ManifestResult{
+		ETag:         etag,
+		LastModified: changed,
+		NotModified:  agent.NotModified(req.IfNoneMatch, req.IfModifiedSince, etag, changed),
+		WriteTo:      func(w io.Writer) error { return sync.WriteManifestNDJSON(w, manifest) },
+	}, nil
+}
+
+func (s agentService) Changes(ctx context.Context, req *agent.ChangesRequest) (*agent.ChangesResponse, error) {
+	if req.Path == "" {
+		return nil, agent.ErrInvalidPath
+	}
+	return s.app.changes(req.Path, req.Since)
+}
+
+func (s agentService) Stat(ctx context.Context, req *agent.StatRequest) (*agent.StatResponse, error) {
+	fullPath, err := resolveStatPath(req.Path)
+	if err != nil || req.Path == "" {
+		return nil, agent.ErrInvalidPath
+	}
+	info, err := os.Stat(fullPath)
+	if os.IsNotExist(err) {
+		return &agent.StatResponse{}, nil
+	}
+	if err != nil {
+		logger.Error("Stat failed", "path", fullPath, "error", err)
+		return nil, err
+	}
+	return &agent.StatResponse{Exists: true, Size: info.Size(), IsDir: info.IsDir(), ModTime: info.ModTime()}, nil
+}
+
+func (s agentService) Delete(ctx context.Context, req *agent.DeleteRequest) (*agent.DeleteResponse, error) {
+	fullPath, err := resolveDeletePath(req.Path)
+	if err != nil || req.Path == "" {
+		return nil, agent.ErrInvalidPath
+	}
+	if err := checkWritable(); err != nil {
+		logger.Warn("Delete refused", "path", req.Path, "error", err)
+		return nil, err
+	}
+	logger.Info("Delete requested", "path", req.Path, "is_dir", req.Dir, "empty", req.Empty, "resolved", fullPath)
+	var deleted bool
+	if req.Dir && req.Empty {
+		deleted, err = deleteEmptyDir(fullPath)
+	} else {
+		deleted, err = deletePath(fullPath, req.Dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &agent.DeleteResponse{Deleted: deleted}, nil
+}
+
+func (s agentService) Mkdir(ctx context.Context, req *agent.MkdirRequest) (*agent.MkdirResponse, error) {
+	fullPath, err := resolveMkdirPath(req.Path)
+	if err != nil || req.Path == "" {
+		return nil, agent.ErrInvalidPath
+	}
+	if err := checkWritable(); err != nil {
+		logger.Warn("Mkdir refused", "path", req.Path, "error", err)
+		return nil, err
+	}
+	if info, err := os.Stat(fullPath); err == nil && info.IsDir() {
+		return &agent.MkdirResponse{}, nil
+	}
+	if err := os.MkdirAll(fullPath, 0755); err != nil {
+		return nil, err
+	}
+	logger.Debug("Created directory", "path", req.Path, "resolved", fullPath)
+	return &agent.MkdirResponse{Created: true}, nil
+}
+
+func (s agentService) Move(ctx context.Context, req *agent.MoveRequest) (*agent.MoveResponse, error) {
+	from, err := resolveMkdirPath(req.From)
+	if err != nil || req.From == "" {
+		return nil, agent.ErrInvalidPath
+	}
+	to, err := resolveMkdirPath(req.To)
+	if err != nil || req.To == "" || from == to {
+		return nil, agent.ErrInvalidPath
+	}
+	if err := checkWritable(); err != nil {
+		logger.Warn("Move refused", "from", req.From, "to", req.To, "error", err)
+		return nil, err
+	}
+	if _, err := os.Lstat(from); os.IsNotExist(err) {
+		return &agent.MoveResponse{}, nil
+	}
+	if _, err := os.Lstat(to); err == nil {
+		return nil, fmt.Errorf("move target %s already exists", req.To)
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(from, to); err != nil {
+		logger.Error("Move failed", "from", from, "to", to, "error", err)
+		return nil, err
+	}
+	logger.Info("Moved", "from", req.From, "to", req.To)
+	return &agent.MoveResponse{Moved: true}, nil
+}
+
+func (s agentService) SetMetadata(ctx context.Context, req *agent.MetadataRequest) (*agent.MetadataResponse, error) {
+	fullPath, err := resolveMkdirPath(req.Path)
+	if err != nil || req.Path == "" {
+		return nil, agent.ErrInvalidPath
+	}
+	if err := checkWritable(); err != nil {
+		logger.Warn("Metadata change refused", "path", req.Path, "error", err)
+		return nil, err
+	}
+	if _, err := os.Lstat(fullPath); os.IsNotExist(err) {
+		return &agent.MetadataResponse{}, nil
+	}
+	if req.Mode != 0 {
+		if err := os.Chmod(fullPath, os.FileMode(req.Mode).Perm()); err != nil {
+			return nil, err
+		}
+	}
+	if !req.ModTime.IsZero() {
+		if err := os.Chtimes(fullPath, req.ModTime, req.ModTime); err != nil {
+			return nil, err
+		}
+	}
+	return &agent.MetadataResponse{Exists: true}, nil
+}
+
+func (s agentService) Hash(ctx context.Context, req *agent.HashRequest) (*agent.HashResponse, error) {
+	fullPath, err := resolveStatPath(req.Path)
+	if err != nil || req.Path == "" {
+		return nil, agent.ErrInvalidPath
+	}
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return nil, err
+	}
+	return &agent.HashResponse{Algorithm: "sha256", Sum: hex.EncodeToString(h.Sum(nil)), Size: n}, nil
+}
+
+func (s agentService) Search(ctx context.Context, req *agent.SearchRequest) (*agent.SearchResponse, error) {
+	if strings.TrimSpace(req.Query) == "" {
+		return nil, fmt.Errorf("%w: query required", agent.ErrInvalidPath)
+	}
+	root, err := resolveStatPath(req.Path)
+	if err != nil {
+		return nil, agent.ErrInvalidPath
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	resp, err := searchFiles(ctx, root, req.Query, limit)
+	if os.IsNotExist(err) {
+		return &agent.SearchResponse{Matches: []agent.SearchMatch{}}, nil
+	}
+	return resp, err
+}
+
+func (s agentService) List(ctx context.Context, req *agent.ListRequest) (*agent.ListResponse, error) {
+	dir, err := resolveStatPath(req.Path)
+	if err != nil || req.Offset < 0 {
+		return nil, agent.ErrInvalidPath
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+	entries, total, err := listDirectory(dir, req.Offset, limit)
+	if err != nil {
+		if os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR) {
+			return nil, fmt.Errorf("%w: not a directory", agent.ErrInvalidPath)
+		}
+		return nil, err
+	}
+	return &agent.ListResponse{Path: req.Path, Entries: entries, Total: total, Offset: min(req.Offset, total), Limit: limit}, nil
+}
+
+func (s agentService) Health(ctx context.Context) (*agent.HealthResponse, error) {
+	ro := scheduler.ReadOnlyAt(time.Now())
+	return &agent.HealthResponse{
+		Status:         "healthy",
+		Protocol:       agent.Version,
+		Journal:        s.app.journal != nil,
+		Time:           time.Now(),
+		ReadOnly:       ro.ReadOnly,
+		ReadOnlyReason: ro.Reason,
+	}, nil
+}
+
+// suspendDelay leaves time to answer the sender before the host goes down
+var suspendDelay = 5 * time.Second
+
+// Suspend runs SUSPEND_COMMAND so a receiver woken via Wake-on-LAN can go
+// back to sleep once the sender is idle
+func (s agentService) Suspend(ctx context.Context) (*agent.SuspendResponse, error) {
+	command := os.Getenv("SUSPEND_COMMAND")
+	if command == "" {
+		return nil, agent.ErrUnsupported
+	}
+	logger.Info("Suspend requested by sender", "delay", suspendDelay.String())
+	time.AfterFunc(suspendDelay, func() {
+		if out, err := exec.Command("sh", "-c", command).CombinedOutput(); err != nil {
+			logger.Error("Suspend command failed", "error", err, "output", string(out))
+		}
+	})
+	return &agent.SuspendResponse{Delay: suspendDelay}, nil
+}
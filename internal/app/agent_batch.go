@@ -0,0 +1,374 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"schnorarr/internal/agent"
+)
+
+// Batches keep a journal below the receiver root while they run, so a
+// restart rolls back one that was cut short. Entries a batch deletes are
+// renamed next to themselves until it commits.
+const (
+	batchJournalDir  = ".schnorarr-batches"
+	batchStagePrefix = ".schnorarr-batch-"
+)
+
+// batchStep is a journal entry: a change of a batch and how to revert it.
+// Steps are written before the change is made.
+type batchStep struct {
+	Kind    string    `json:"kind"` // staged, rmdir, mkdir, move, metadata or commit
+	Path    string    `json:"path,omitempty"`
+	To      string    `json:"to,omitempty"` // New place of a staged or moved entry
+	ModTime time.Time `json:"mod_time,omitempty"`
+	Mode    uint32    `json:"mode,omitempty"`
+}
+
+// batchTx is a running batch
+type batchTx struct {
+	id      string
+	journal *os.File
+	steps   []batchStep
+}
+
+func (s agentService) Batch(ctx context.Context, req *agent.BatchRequest) (*agent.BatchResponse, error) {
+	if err := checkWritable(); err != nil {
+		logger.Warn("Batch refused", "ops", len(req.Ops), "error", err)
+		return nil, err
+	}
+	resp := &agent.BatchResponse{Committed: true, Results: make([]agent.OpResult, len(req.Ops))}
+	if len(req.Ops) == 0 {
+		return resp, nil
+	}
+	tx, err := beginBatch()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start batch journal: %w", err)
+	}
+
+	changed := 0
+	for i, op := range req.Ops {
+		if !resp.Committed {
+			resp.Results[i].Skipped = true
+			continue
+		}
+		ok, err := tx.apply(i, op)
+		if err != nil {
+			logger.Warn("Batch operation failed, rolling back", "batch", tx.id, "op", op.Op, "path", op.Path, "error", err)
+			resp.Committed = false
+			resp.Results[i] = agent.OpResult{Error: err.Error(), Code: agent.ErrorCode(err)}
+			continue
+		}
+		resp.Results[i].Changed = ok
+		if ok {
+			changed++
+		}
+	}
+	if !resp.Committed {
+		tx.rollback()
+		for i := range resp.Results {
+			resp.Results[i].Changed = false
+		}
+		return resp, nil
+	}
+	if err := tx.commit(); err != nil {
+		return nil, err
+	}
+	logger.Info("Batch committed", "batch", tx.id, "ops", len(req.Ops), "changed", changed)
+	return resp, nil
+}
+
+// batchRoot is the receiver root the journal lives in
+func batchRoot() string {
+	if root := os.Getenv("SOURCE_DIR"); root != "" {
+		return root
+	}
+	return "/data"
+}
+
+func beginBatch() (*batchTx, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(batchRoot(), batchJournalDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	id := hex.EncodeToString(b)
+	f, err := os.OpenFile(filepath.Join(dir, id+".ndjson"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &batchTx{id: id, journal: f}, nil
+}
+
+// record journals a step before it is made. The step is synced to disk
+// first, so a crash never leaves a change the journal cannot revert.
+func (tx *batchTx) record(step batchStep) error {
+	line, err := json.Marshal(step)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.journal.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("batch journal: %w", err)
+	}
+	if err := tx.journal.Sync(); err != nil {
+		return fmt.Errorf("batch journal: %w", err)
+	}
+	tx.steps = append(tx.steps, step)
+	return nil
+}
+
+// apply runs operation i and reports whether it changed anything
+func (tx *batchTx) apply(i int, op agent.Op) (bool, error) {
+	switch op.Op {
+	case agent.OpDelete:
+		fullPath, err := resolveDeletePath(op.Path)
+		if err != nil || op.Path == "" {
+			return false, agent.ErrInvalidPath
+		}
+		return tx.delete(i, fullPath, op)
+	case agent.OpMkdir:
+		fullPath, err := resolveMkdirPath(op.Path)
+		if err != nil {
+			return false, agent.ErrInvalidPath
+		}
+		if info, err := os.Stat(fullPath); err == nil && info.IsDir() {
+			return false, nil
+		}
+		return true, tx.mkdirAll(fullPath)
+	case agent.OpMove:
+		from, err := resolveMkdirPath(op.Path)
+		if err != nil {
+			return false, agent.ErrInvalidPath
+		}
+		to, err := resolveMkdirPath(op.To)
+		if err != nil || from == to {
+			return false, agent.ErrInvalidPath
+		}
+		if _, err := os.Lstat(from); os.IsNotExist(err) {
+			return false, nil
+		}
+		if _, err := os.Lstat(to); err == nil {
+			return false, fmt.Errorf("move target %s already exists", op.To)
+		}
+		if err := tx.mkdirAll(filepath.Dir(to)); err != nil {
+			return false, err
+		}
+		if err := tx.record(batchStep{Kind: "move", Path: from, To: to}); err != nil {
+			return false, err
+		}
+		return true, os.Rename(from, to)
+	case agent.OpMetadata:
+		fullPath, err := resolveMkdirPath(op.Path)
+		if err != nil {
+			return false, agent.ErrInvalidPath
+		}
+		info, err := os.Lstat(fullPath)
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if err := tx.record(batchStep{Kind: "metadata", Path: fullPath, ModTime: info.ModTime(), Mode: uint32(info.Mode().Perm())}); err != nil {
+			return false, err
+		}
+		if op.Mode != 0 {
+			if err := os.Chmod(fullPath, os.FileMode(op.Mode).Perm()); err != nil {
+				return false, err
+			}
+		}
+		if !op.ModTime.IsZero() {
+			if err := os.Chtimes(fullPath, op.ModTime, op.ModTime); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	return false, fmt.Errorf("unknown operation %q", op.Op)
+}
+
+// delete removes an empty directory right away and moves anything else
+// aside until the batch commits
+func (tx *batchTx) delete(i int, fullPath string, op agent.Op) (bool, error) {
+	info, err := os.Lstat(fullPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if info.IsDir() && op.Empty {
+		if entries, err := os.ReadDir(fullPath); err != nil {
+			return false, err
+		} else if len(entries) > 0 {
+			return false, agent.ErrNotEmpty
+		}
+		if err := tx.record(batchStep{Kind: "rmdir", Path: fullPath, ModTime: info.ModTime(), Mode: uint32(info.Mode().Perm())}); err != nil {
+			return false, err
+		}
+		return true, os.Remove(fullPath)
+	}
+	if info.IsDir() && !op.Dir {
+		return false, fmt.Errorf("%s is a directory", op.Path)
+	}
+	staged := filepath.Join(filepath.Dir(fullPath), batchStagePrefix+tx.id+"-"+strconv.Itoa(i))
+	if err := tx.record(batchStep{Kind: "staged", Path: fullPath, To: staged}); err != nil {
+		return false, err
+	}
+	return true, os.Rename(fullPath, staged)
+}
+
+// mkdirAll creates dir with its missing parents, journaling each
+func (tx *batchTx) mkdirAll(dir string) error {
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Lstat(d); err == nil || d == filepath.Dir(d) {
+			break
+		}
+		missing = append(missing, d)
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err := tx.record(batchStep{Kind: "mkdir", Path: missing[i]}); err != nil {
+			return err
+		}
+		if err := os.Mkdir(missing[i], 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commit journals the commit, then removes what the batch deleted. A
+// commit that cannot be journaled rolls the batch back.
+func (tx *batchTx) commit() error {
+	if err := tx.record(batchStep{Kind: "commit"}); err != nil {
+		tx.rollback()
+		return err
+	}
+	if err := tx.journal.Close(); err != nil {
+		tx.steps = tx.steps[:len(tx.steps)-1]
+		tx.rollback()
+		return fmt.Errorf("batch journal: %w", err)
+	}
+	finishBatch(tx.journal.Name(), tx.steps)
+	return nil
+}
+
+// rollback reverts the steps made so far, newest first
+func (tx *batchTx) rollback() {
+	_ = tx.journal.Close()
+	revertBatch(tx.id, tx.steps)
+	_ = os.Remove(tx.journal.Name())
+}
+
+// finishBatch removes the staged entries of a committed batch and its journal
+func finishBatch(journal string, steps []batchStep) {
+	for _, step := range steps {
+		if step.Kind != "staged" {
+			continue
+		}
+		if err := os.RemoveAll(step.To); err != nil {
+			logger.Error("Delete failed", "path", step.Path, "error", err)
+			return // The journal stays, the next start retries
+		}
+		logger.Info("Deleted", "path", step.Path)
+	}
+	_ = os.Remove(journal)
+}
+
+func revertBatch(id string, steps []batchStep) {
+	for i := len(steps) - 1; i >= 0; i-- {
+		step := steps[i]
+		var err error
+		switch step.Kind {
+		case "staged", "move":
+			if _, statErr := os.Lstat(step.To); statErr == nil {
+				err = os.Rename(step.To, step.Path)
+			}
+		case "rmdir":
+			if _, statErr := os.Lstat(step.Path); os.IsNotExist(statErr) {
+				if err = os.Mkdir(step.Path, os.FileMode(step.Mode)); err == nil {
+					err = os.Chtimes(step.Path, step.ModTime, step.ModTime)
+				}
+			}
+		case "mkdir":
+			err = os.Remove(step.Path)
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		case "metadata":
+			if err = os.Chmod(step.Path, os.FileMode(step.Mode)); err == nil {
+				err = os.Chtimes(step.Path, step.ModTime, step.ModTime)
+			}
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		}
+		if err != nil {
+			logger.Error("Failed to roll back batch step", "batch", id, "step", step.Kind, "path", step.Path, "error", err)
+		}
+	}
+}
+
+// recoverBatches finishes the batches a restart interrupted: committed ones
+// are cleaned up, the others rolled back
+func recoverBatches() {
+	dir := filepath.Join(batchRoot(), batchJournalDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".ndjson")
+		if !ok {
+			continue
+		}
+		journal := filepath.Join(dir, e.Name())
+		steps, err := readBatchJournal(journal)
+		if err != nil {
+			logger.Error("Failed to read batch journal", "batch", id, "error", err)
+			continue
+		}
+		if len(steps) > 0 && steps[len(steps)-1].Kind == "commit" {
+			logger.Info("Finishing interrupted batch", "batch", id)
+			finishBatch(journal, steps)
+			continue
+		}
+		logger.Warn("Rolling back interrupted batch", "batch", id, "steps", len(steps))
+		revertBatch(id, steps)
+		_ = os.Remove(journal)
+	}
+}
+
+// readBatchJournal reads the steps of a journal. A torn last line, written
+// when the receiver stopped, is ignored.
+func readBatchJournal(path string) ([]batchStep, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	var steps []batchStep
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		var step batchStep
+		if err := json.Unmarshal(sc.Bytes(), &step); err != nil {
+			break
+		}
+		steps = append(steps, step)
+	}
+	return steps, sc.Err()
+}
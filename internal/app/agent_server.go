@@ -0,0 +1,105 @@
+package app
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	stdsync "sync"
+	"time"
+
+	"schnorarr/internal/agent"
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/monitor/notification"
+)
+
+// nodeRoute is an endpoint the other node calls
+type nodeRoute struct {
+	path    string
+	handler http.Handler
+}
+
+// nodeRoutes are the endpoints the other node calls. agentHandler serves
+// them and Start mounts the same paths, so every route listed is guarded.
+func (a *App) nodeRoutes() []nodeRoute {
+	return []nodeRoute{
+		{agent.PathPrefix, agent.NewHandler(agentService{app: a})},
+		{"/api/manifest", http.HandlerFunc(a.ManifestHandler)},
+		{"/api/changes", http.HandlerFunc(a.ChangesHandler)},
+		{"/api/delete", http.HandlerFunc(a.DeleteHandler)},
+		{"/api/stat", http.HandlerFunc(a.StatHandler)},
+		{"/api/search", http.HandlerFunc(a.SearchHandler)},
+		{"/api/list", http.HandlerFunc(a.ListHandler)},
+	}
+}
+
+// agentHandler returns the agent API with its own middleware, apart from the
+// dashboard: AGENT_TOKEN and AGENT_ALLOWED_IPS instead of the login
+func (a *App) agentHandler() (http.Handler, error) {
+	allowed, err := agent.ParseAllowed(os.Getenv("AGENT_ALLOWED_IPS"))
+	if err != nil {
+		return nil, fmt.Errorf("AGENT_ALLOWED_IPS: %w", err)
+	}
+	token := agent.Token()
+	if token == "" && len(allowed) == 0 && os.Getenv("MODE") != "sender" {
+		logger.Warn("The agent API is open to anyone who can reach it, set AGENT_TOKEN or AGENT_ALLOWED_IPS")
+	}
+
+	mux := http.NewServeMux()
+	for _, route := range a.nodeRoutes() {
+		mux.Handle(route.path, route.handler)
+	}
+	denied := newDenyAlerter(a.Notifier).denied
+	return agent.AllowPeers(agent.Guard(mux, token, denied), allowed, denied), nil
+}
+
+// denyAlerter records rejected agent calls in the audit trail and notifies,
+// at most once per peer every AGENT_DENIED_NOTIFY_MINUTES (default 15, 0 = off)
+type denyAlerter struct {
+	notifier *notification.Service
+	every    time.Duration
+
+	mu   stdsync.Mutex
+	last map[string]time.Time // By peer IP
+}
+
+func newDenyAlerter(notifier *notification.Service) *denyAlerter {
+	every := 15 * time.Minute
+	if val, err := strconv.Atoi(os.Getenv("AGENT_DENIED_NOTIFY_MINUTES")); err == nil && val >= 0 {
+		every = time.Duration(val) * time.Minute
+	}
+	return &denyAlerter{notifier: notifier, every: every, last: make(map[string]time.Time)}
+}
+
+func (d *denyAlerter) denied(r *http.Request, reason string) {
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peer = r.RemoteAddr
+	}
+	// The audit trail is throttled the same way when notifications are off
+	window := d.every
+	if window == 0 {
+		window = 15 * time.Minute
+	}
+	d.mu.Lock()
+	due := time.Since(d.last[peer]) >= window
+	if due {
+		for p, t := range d.last {
+			if time.Since(t) >= window {
+				delete(d.last, p)
+			}
+		}
+		d.last[peer] = time.Now()
+	}
+	d.mu.Unlock()
+	if !due {
+		return
+	}
+
+	msg := fmt.Sprintf("%s %s (%s)", r.Method, r.URL.Path, reason)
+	_ = database.LogSystemEvent(peer, "Agent Call Denied", msg)
+	if d.notifier != nil && d.every > 0 {
+		go d.notifier.Send(notification.Render(notification.EventAgentDenied, notification.Vars{From: peer, Message: msg}), "WARNING")
+	}
+}
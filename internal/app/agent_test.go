@@ -0,0 +1,358 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"schnorarr/internal/agent"
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/sync"
+)
+
+func TestAgentService(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("SOURCE_DIR", root)
+	t.Setenv("RSYNC_MODULE_PATH", root)
+	if err := os.MkdirAll(filepath.Join(root, "movies", "A"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "movies", "A", "a.mkv"), []byte("movie"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(agent.NewHandler(agentService{app: &App{}}))
+	defer srv.Close()
+	c := agent.NewClient(srv.URL)
+	ctx := context.Background()
+
+	stream, err := c.Manifest(ctx, &agent.ManifestRequest{Path: "movies", Sub: "A"})
+	if err != nil {
+		t.Fatalf("Manifest failed: %v", err)
+	}
+	manifest, err := sync.ReadManifestNDJSON(stream.Body)
+	_ = stream.Body.Close()
+	if err != nil || !manifest.HasFile("A/a.mkv") {
+		t.Errorf("Unexpected subtree manifest: %v, %v", manifest, err)
+	}
+
+	if stat, err := c.Stat(ctx, &agent.StatRequest{Path: "movies/A/a.mkv"}); err != nil || !stat.Exists || stat.Size != 5 {
+		t.Errorf("Unexpected stat: %+v, %v", stat, err)
+	}
+	hash, err := c.Hash(ctx, &agent.HashRequest{Path: "movies/A/a.mkv"})
+	if err != nil || hash.Sum != "8a6ba32c9bed6ce703f999f9af6ec23686d44e144e4da572d94c8daca4a9cbab" || hash.Size != 5 {
+		t.Errorf("Unexpected hash: %+v, %v", hash, err)
+	}
+	found, err := c.Search(ctx, &agent.SearchRequest{Path: "movies", Query: "A.MKV"})
+	if err != nil || len(found.Matches) != 1 || found.Matches[0].Path != "A/a.mkv" || found.Matches[0].Size != 5 {
+		t.Errorf("Unexpected search: %+v, %v", found, err)
+	}
+	if found, err := c.Search(ctx, &agent.SearchRequest{Query: "*", Limit: 1}); err != nil || len(found.Matches) != 1 || !found.Truncated {
+		t.Errorf("Search should stop at the limit: %+v, %v", found, err)
+	}
+	if _, err := c.Search(ctx, &agent.SearchRequest{Query: " "}); err == nil {
+		t.Error("Empty searches should be rejected")
+	}
+	for _, name := range []string{"b.mkv.tmp", ".c.mkv.Ab12Cd"} {
+		if err := os.WriteFile(filepath.Join(root, "movies", name), []byte("part"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list, err := c.List(ctx, &agent.ListRequest{Path: "movies", Limit: 2})
+	if err != nil || list.Total != 3 || len(list.Entries) != 2 || list.Entries[0].Name != "A" || !list.Entries[0].IsDir {
+		t.Fatalf("Unexpected first page: %+v, %v", list, err)
+	}
+	if e := list.Entries[1]; e.Name != ".c.mkv.Ab12Cd" || !e.Partial || e.Size != 4 {
+		t.Errorf("rsync temporary files should be partial: %+v", e)
+	}
+	list, err = c.List(ctx, &agent.ListRequest{Path: "movies", Offset: 2, Limit: 2})
+	if err != nil || len(list.Entries) != 1 || !list.Entries[0].Partial || list.Offset != 2 {
+		t.Errorf("Unexpected second page: %+v, %v", list, err)
+	}
+	if _, err := c.List(ctx, &agent.ListRequest{Path: "movies/A/a.mkv"}); err == nil {
+		t.Error("Listing a file should fail")
+	}
+	if _, err := c.Stat(ctx, &agent.StatRequest{Path: "../etc/passwd"}); err == nil {
+		t.Error("Paths outside the root should be rejected")
+	}
+	if _, err := c.Delete(ctx, &agent.DeleteRequest{Path: "movies/A", Dir: true, Empty: true}); !errors.Is(err, agent.ErrNotEmpty) {
+		t.Errorf("Removing a non-empty directory with Empty = %v, want ErrNotEmpty", err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "movies", "Empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := c.Delete(ctx, &agent.DeleteRequest{Path: "movies/Empty", Dir: true, Empty: true}); err != nil || !resp.Deleted {
+		t.Errorf("Removing an empty directory failed: %+v, %v", resp, err)
+	}
+	if resp, err := c.Delete(ctx, &agent.DeleteRequest{Path: "movies/A", Dir: true}); err != nil || !resp.Deleted {
+		t.Errorf("Delete failed: %+v, %v", resp, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "movies", "A")); !os.IsNotExist(err) {
+		t.Error("Directory should be deleted")
+	}
+}
+
+func TestAgentService_ReadOnly(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("SOURCE_DIR", root)
+	t.Setenv("READ_ONLY_FLAG", filepath.Join(root, "read-only"))
+	database.DBPath = filepath.Join(t.TempDir(), "test.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+	if err := os.WriteFile(filepath.Join(root, "a.mkv"), []byte("movie"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(agent.NewHandler(agentService{app: &App{}}))
+	defer srv.Close()
+	c := agent.NewClient(srv.URL)
+	ctx := context.Background()
+
+	if err := database.StartReadOnly(0, "fsck", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	applyReadOnly()
+	if flag, err := os.ReadFile(filepath.Join(root, "read-only")); err != nil || string(flag) != "fsck\n" {
+		t.Errorf("Expected the rsync flag file with the reason, got %q, %v", flag, err)
+	}
+	if health, err := c.Health(ctx); err != nil || !health.ReadOnly || health.ReadOnlyReason != "fsck" {
+		t.Errorf("Health should report the write protection: %+v, %v", health, err)
+	}
+	if _, err := c.Delete(ctx, &agent.DeleteRequest{Path: "a.mkv"}); !errors.Is(err, agent.ErrReadOnly) {
+		t.Errorf("Deletes should be refused with ErrReadOnly, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "a.mkv")); err != nil {
+		t.Error("The file should survive a refused delete")
+	}
+
+	if err := database.StopReadOnly("admin"); err != nil {
+		t.Fatal(err)
+	}
+	applyReadOnly()
+	if _, err := os.Stat(filepath.Join(root, "read-only")); !os.IsNotExist(err) {
+		t.Error("The flag file should be removed once writable")
+	}
+	if resp, err := c.Delete(ctx, &agent.DeleteRequest{Path: "a.mkv"}); err != nil || !resp.Deleted {
+		t.Errorf("Delete failed after the write protection was lifted: %+v, %v", resp, err)
+	}
+}
+
+func TestAgentHandler_AllowList(t *testing.T) {
+	database.DBPath = filepath.Join(t.TempDir(), "test.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+	t.Setenv("AGENT_ALLOWED_IPS", "100.64.0.0/10")
+	api, err := (&App{}).agentHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("POST", "/api/delete?path=a.mkv", nil)
+		req.RemoteAddr = "192.168.1.66:40000"
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, req)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("Delete from outside the allow-list = %d, want 403", w.Code)
+		}
+	}
+	var n int
+	_ = database.DB.QueryRow("SELECT COUNT(*) FROM history WHERE action = 'Agent Call Denied'").Scan(&n)
+	if n != 1 {
+		t.Errorf("Denied calls should be audited once per peer and window, got %d", n)
+	}
+
+	req := httptest.NewRequest("GET", "/api/stat?path=a.mkv", nil)
+	req.RemoteAddr = "100.101.102.103:40000"
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, req)
+	if w.Code == http.StatusForbidden {
+		t.Error("The sender's Tailscale address should get through")
+	}
+
+	t.Setenv("AGENT_ALLOWED_IPS", "sender.ts.net")
+	if _, err := (&App{}).agentHandler(); err == nil {
+		t.Error("An invalid allow-list should fail instead of leaving the API open")
+	}
+}
+
+func TestAgentService_Mkdir(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("SOURCE_DIR", root)
+	t.Setenv("READ_ONLY_FLAG", filepath.Join(root, "read-only"))
+	svc := agentService{app: &App{}}
+	ctx := context.Background()
+
+	resp, err := svc.Mkdir(ctx, &agent.MkdirRequest{Path: "tv/Show/Season 2"})
+	if err != nil || !resp.Created {
+		t.Fatalf("Mkdir = %+v, %v", resp, err)
+	}
+	if info, err := os.Stat(filepath.Join(root, "tv", "Show", "Season 2")); err != nil || !info.IsDir() {
+		t.Error("Directory should be created with its parents below the root")
+	}
+	if resp, err := svc.Mkdir(ctx, &agent.MkdirRequest{Path: "tv/Show/Season 2"}); err != nil || resp.Created {
+		t.Errorf("An existing directory should not count as created: %+v, %v", resp, err)
+	}
+	if _, err := svc.Mkdir(ctx, &agent.MkdirRequest{Path: "../outside"}); !errors.Is(err, agent.ErrInvalidPath) {
+		t.Errorf("Paths leaving the root should be rejected, got %v", err)
+	}
+}
+
+func TestAgentService_SetMetadata(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("SOURCE_DIR", root)
+	t.Setenv("READ_ONLY_FLAG", filepath.Join(root, "read-only"))
+	svc := agentService{app: &App{}}
+	ctx := context.Background()
+	dir := filepath.Join(root, "tv", "Show")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	mtime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	resp, err := svc.SetMetadata(ctx, &agent.MetadataRequest{Path: "tv/Show", ModTime: mtime, Mode: 0750})
+	if err != nil || !resp.Exists {
+		t.Fatalf("SetMetadata = %+v, %v", resp, err)
+	}
+	if info, err := os.Stat(dir); err != nil || info.Mode().Perm() != 0750 || !info.ModTime().Equal(mtime) {
+		t.Errorf("Directory should get the mode and time, got %v, %v", info.Mode(), info.ModTime())
+	}
+	if resp, err := svc.SetMetadata(ctx, &agent.MetadataRequest{Path: "tv/Missing", ModTime: mtime}); err != nil || resp.Exists {
+		t.Errorf("A missing path should be reported, not fail: %+v, %v", resp, err)
+	}
+	if _, err := svc.SetMetadata(ctx, &agent.MetadataRequest{Path: "../outside", ModTime: mtime}); !errors.Is(err, agent.ErrInvalidPath) {
+		t.Errorf("Paths leaving the root should be rejected, got %v", err)
+	}
+}
+
+func TestAgentService_Move(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("SOURCE_DIR", root)
+	t.Setenv("READ_ONLY_FLAG", filepath.Join(root, "read-only"))
+	svc := agentService{app: &App{}}
+	ctx := context.Background()
+	if err := os.MkdirAll(filepath.Join(root, "movies", "Old", "Extras"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "movies", "Taken"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := svc.Move(ctx, &agent.MoveRequest{From: "movies/Old", To: "archive/New"})
+	if err != nil || !resp.Moved {
+		t.Fatalf("Move = %+v, %v", resp, err)
+	}
+	if info, err := os.Stat(filepath.Join(root, "archive", "New", "Extras")); err != nil || !info.IsDir() {
+		t.Error("The directory should be moved with its contents, creating missing parents")
+	}
+	if resp, err := svc.Move(ctx, &agent.MoveRequest{From: "movies/Old", To: "movies/Other"}); err != nil || resp.Moved {
+		t.Errorf("A missing source should not count as moved: %+v, %v", resp, err)
+	}
+	if _, err := svc.Move(ctx, &agent.MoveRequest{From: "archive/New", To: "movies/Taken"}); err == nil {
+		t.Error("An existing target should not be replaced")
+	}
+	if _, err := svc.Move(ctx, &agent.MoveRequest{From: "archive/New", To: "../outside"}); !errors.Is(err, agent.ErrInvalidPath) {
+		t.Errorf("Paths leaving the root should be rejected, got %v", err)
+	}
+}
+
+func TestAgentService_Batch(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("SOURCE_DIR", root)
+	t.Setenv("READ_ONLY_FLAG", filepath.Join(root, "read-only"))
+	svc := agentService{app: &App{}}
+	ctx := context.Background()
+	for _, f := range []string{"tv/Old/e01.mkv", "tv/Gone/e01.mkv", "tv/Full/keep.nfo"} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(f)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, f), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exists := func(rel string) bool {
+		_, err := os.Lstat(filepath.Join(root, rel))
+		return err == nil
+	}
+
+	// A failing operation rolls back the ones before it
+	resp, err := svc.Batch(ctx, &agent.BatchRequest{Ops: []agent.Op{
+		{Op: agent.OpDelete, Path: "tv/Gone/e01.mkv"},
+		{Op: agent.OpMkdir, Path: "tv/New/Season 1"},
+		{Op: agent.OpDelete, Path: "tv/Full", Dir: true, Empty: true},
+		{Op: agent.OpDelete, Path: "tv/Old/e01.mkv"},
+	}})
+	if err != nil || resp.Committed || len(resp.Results) != 4 {
+		t.Fatalf("Batch = %+v, %v", resp, err)
+	}
+	if !errors.Is(resp.Results[2].Err(), agent.ErrNotEmpty) || !resp.Results[3].Skipped {
+		t.Errorf("Unexpected results: %+v", resp.Results)
+	}
+	if !exists("tv/Gone/e01.mkv") || exists("tv/New") || !exists("tv/Old/e01.mkv") {
+		t.Error("A rolled back batch should leave the tree as it was")
+	}
+
+	mtime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	resp, err = svc.Batch(ctx, &agent.BatchRequest{Ops: []agent.Op{
+		{Op: agent.OpDelete, Path: "tv/Gone", Dir: true},
+		{Op: agent.OpMove, Path: "tv/Old", To: "archive/Renamed"},
+		{Op: agent.OpMkdir, Path: "tv/New/Season 1"},
+		{Op: agent.OpMetadata, Path: "tv/New", ModTime: mtime, Mode: 0750},
+		{Op: agent.OpDelete, Path: "tv/Missing.mkv"},
+	}})
+	if err != nil || !resp.Committed {
+		t.Fatalf("Batch = %+v, %v", resp, err)
+	}
+	if !resp.Results[0].Changed || resp.Results[4].Changed {
+		t.Errorf("Unexpected results: %+v", resp.Results)
+	}
+	if exists("tv/Gone") || !exists("archive/Renamed/e01.mkv") || !exists("tv/New/Season 1") {
+		t.Error("A committed batch should apply every operation")
+	}
+	if info, err := os.Stat(filepath.Join(root, "tv", "New")); err != nil || info.Mode().Perm() != 0750 || !info.ModTime().Equal(mtime) {
+		t.Error("Metadata should be applied")
+	}
+	if entries, _ := os.ReadDir(filepath.Join(root, "tv")); len(entries) != 2 {
+		t.Errorf("Staged deletions should be gone after the commit: %v", entries)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(root, batchJournalDir)); len(entries) != 0 {
+		t.Errorf("Journals should be removed: %v", entries)
+	}
+}
+
+func TestRecoverBatches(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("SOURCE_DIR", root)
+	dir := filepath.Join(root, batchJournalDir)
+	if err := os.MkdirAll(filepath.Join(root, "tv"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	original, staged := filepath.Join(root, "tv", "e01.mkv"), filepath.Join(root, "tv", batchStagePrefix+"a-0")
+	if err := os.WriteFile(staged, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	journal := `{"kind":"staged","path":"` + original + `","to":"` + staged + `"}` + "\n" + `{"kind":"mkdir","path":"` + filepath.Join(root, "tv", "New") + `"}` + "\n" + `{"kind":"mkd`
+	if err := os.WriteFile(filepath.Join(dir, "a.ndjson"), []byte(journal), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	recoverBatches()
+	if _, err := os.Stat(original); err != nil {
+		t.Error("An interrupted batch should be rolled back on start")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("The journal should be removed: %v", entries)
+	}
+}
@@ -3,18 +3,23 @@ package app
 import (
 	"fmt"
 	"io"
-	"log"
-	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"schnorarr/internal/agent"
+	"schnorarr/internal/discovery"
+	"schnorarr/internal/monitor/alerting"
 	"schnorarr/internal/monitor/config"
 	"schnorarr/internal/monitor/database"
 	"schnorarr/internal/monitor/handlers"
 	"schnorarr/internal/monitor/health"
+	"schnorarr/internal/monitor/logging"
 	"schnorarr/internal/monitor/notification"
+	"schnorarr/internal/monitor/scheduler"
+	"schnorarr/internal/monitor/smart"
 	"schnorarr/internal/monitor/tailer"
 	ws "schnorarr/internal/monitor/websocket"
 	syncpkg "schnorarr/internal/sync"
@@ -22,6 +27,8 @@ import (
 	"sync"
 )
 
+var logger = logging.For("app")
+
 type App struct {
 	Config      *config.Config
 	HealthState *health.State
@@ -29,40 +36,85 @@ type App struct {
 	Notifier    *notification.Service
 	SyncEngines []*syncpkg.Engine
 	engineMu    sync.RWMutex
+
+	manifestVersions manifestVersions
+	journal          *syncpkg.ChangeJournal // Receiver tree changes served by /api/changes
+	advertiser       *discovery.Advertiser
+	scheduler        *scheduler.Scheduler // Bandwidth schedule, started in sender mode
+	alerts           *alerting.Manager    // Applies the alert rules to sync errors
+	latency          *latencyMonitor      // Receiver latency and loss, measured in sender mode
 }
 
 func New() (*App, error) {
+	setupTimezone()
 	cfg := config.Load()
 	if err := database.Init(); err != nil {
 		return nil, fmt.Errorf("db init failed: %w", err)
 	}
+	convertLegacyHistory()
 	app := &App{
 		Config: cfg, HealthState: health.New(), WSHub: ws.New(),
-		Notifier: notification.New(cfg.DiscordWebhook, cfg.TelegramToken, cfg.TelegramChatID),
+		Notifier:  notification.New(cfg.DiscordWebhook, cfg.TelegramToken, cfg.TelegramChatID),
+		scheduler: scheduler.New(cfg), latency: newLatencyMonitor(),
+	}
+	app.Notifier.SetMute(database.InMaintenance)
+	progressAfter := 5 * time.Minute
+	if env := os.Getenv("DISCORD_PROGRESS_MINUTES"); env != "" {
+		if val, err := strconv.Atoi(env); err == nil && val >= 0 {
+			progressAfter = time.Duration(val) * time.Minute
+		}
 	}
+	app.Notifier.SetDiscordProgress(progressAfter)
+	app.alerts = alerting.New(app.Notifier.SendTo)
+	app.HealthState.SetAlerter(app.alerts)
 
 	// Load persisted settings
 	override := database.GetSetting("sender_override", "false")
 	app.HealthState.SetSenderOverride(override == "true")
 
-	// Setup structured logging
+	// Setup structured logging; standard log calls go through the same handler
 	wsWriter := ws.NewLogWriter(app.WSHub)
-	multiWriter := io.MultiWriter(os.Stdout, wsWriter)
-	logger := slog.New(slog.NewJSONHandler(multiWriter, nil))
-	slog.SetDefault(logger)
-	log.SetOutput(multiWriter) // Keep standard log output redirected for legacy calls
+	logging.Setup(io.MultiWriter(os.Stdout, wsWriter), os.Getenv("LOG_FORMAT"))
+	if err := logging.Configure(os.Getenv("LOG_LEVEL"), os.Getenv("LOG_LEVELS")); err != nil {
+		logger.Warn("Invalid log level configuration", "error", err)
+	}
 	return app, nil
 }
 
-func (a *App) Start(port string) error {
+// Start runs the services and the web server on the configured address
+func (a *App) Start() error {
+	addr, agentAddr := a.Config.Listen(), a.Config.AgentListen()
+	a.startupCheck()
 	database.StartTrafficManager()
 	a.startLogTailer()
 	go a.startHousekeeping()
+	go a.alerts.Run()
 	if os.Getenv("MODE") == "sender" {
 		go a.startSenderServices()
 	}
 
 	h := handlers.New(a.Config, a.HealthState, a.WSHub, database.DB, a.Notifier, a.GetSyncEngines)
+	h.SetBandwidthScheduler(a.scheduler)
+	h.SetLatencyProvider(a.latency.Current)
+	h.SetConfigValidator(validateConfig)
+	go h.RunSessionCleanup(time.Hour)
+	h.SetAlertManager(a.alerts)
+	if os.Getenv("MODE") != "sender" {
+		if m := startDiskMonitor(); m != nil {
+			h.SetDiskProvider(m.Disks)
+		}
+		recoverBatches()
+		a.journal = startChangeJournal()
+		go startReadOnlyWatch()
+		go startIntegrityReporter()
+		h.SetReadOnlyHook(applyReadOnly)
+		if agentAddr != "" {
+			a.advertiser = startAdvertiser(listenPort(agentAddr))
+		} else {
+			a.advertiser = startAdvertiser(listenPort(addr))
+		}
+	}
+	a.startTelegramBot(h)
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", h.Index)
 	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(ui.StaticFS))))
@@ -89,45 +141,156 @@ func (a *App) Start(port string) error {
 	})
 	mux.HandleFunc("/logout", h.Logout)
 
-	// Engine API
-	mux.HandleFunc("/api/manifest", a.ManifestHandler)
-	mux.HandleFunc("/api/delete", a.DeleteHandler)
-	mux.HandleFunc("/api/stat", a.StatHandler)
+	// Agent API, on its own listener with AGENT_LISTEN_ADDR
+	api, err := a.agentHandler()
+	if err != nil {
+		return err
+	}
+	node := mux
+	if agentAddr != "" {
+		node = http.NewServeMux()
+		node.HandleFunc("/health", h.Health)
+		node.HandleFunc("/api/integrity", h.Integrity)
+	}
+	for _, route := range a.nodeRoutes() {
+		node.Handle(route.path, api)
+	}
 	mux.HandleFunc("/api/engines/bulk", h.BulkAction)
+	mux.HandleFunc("/api/groups", h.Groups)
+	mux.HandleFunc("/api/groups/", h.Groups)
+	mux.HandleFunc("/api/discovery", h.DiscoverReceivers)
+	mux.HandleFunc("/api/locks", h.LockStats)
+	mux.HandleFunc("/api/bandwidth/schedule", h.BandwidthSchedule)
+	mux.HandleFunc("/api/alerts/rules", h.AlertRules)
+	mux.HandleFunc("/api/status", h.Status)
+	mux.HandleFunc("/api/locale", h.Locale)
+	mux.HandleFunc("/api/notifications/templates", h.NotificationTemplates)
+	mux.HandleFunc("/api/notifications/templates/preview", h.NotificationPreview)
+	mux.HandleFunc("/api/maintenance", h.Maintenance)
+	mux.HandleFunc("/api/read-only", h.ReadOnly)
+	mux.HandleFunc("/api/integrity", h.Integrity)
+	mux.HandleFunc("/api/ha/sensors", h.HASensors)
+	mux.HandleFunc("/api/calendar.ics", h.Calendar)
+	mux.HandleFunc("/api/ha/switch/", h.HASwitch)
+	mux.HandleFunc("/api/diagnostics", h.Diagnostics)
+	mux.HandleFunc("/api/v1/system", h.SystemMetrics)
+	mux.HandleFunc("/api/latency", h.Latency)
+	mux.HandleFunc("/api/v1/query", h.Query)
+	mux.HandleFunc("/api/admin/doctor", h.Doctor)
+	mux.HandleFunc("/api/config/validate", h.ValidateConfig)
+	mux.HandleFunc("/api/admin/sessions", h.Sessions)
+	mux.HandleFunc("/api/admin/requests", h.Requests)
+	mux.HandleFunc("/metrics", h.Metrics)
+	mux.HandleFunc("/api/admin/log-levels", h.LogLevels)
+	mux.HandleFunc("/api/traffic", h.Traffic)
+	mux.HandleFunc("/api/file-history", h.FileHistory)
+	mux.HandleFunc("/api/remote/search", h.RemoteSearch)
+	mux.HandleFunc("/api/remote/list", h.RemoteList)
+	mux.HandleFunc("/api/cycles", h.Cycles)
+	mux.HandleFunc("/api/cycles/", h.Cycles)
 	mux.HandleFunc("/api/engine/", func(w http.ResponseWriter, r *http.Request) {
 		if strings.HasSuffix(r.URL.Path, "/preview") {
 			h.EnginePreview(w, r)
+		} else if strings.HasSuffix(r.URL.Path, "/preview/diff") {
+			h.EnginePreviewDiff(w, r)
+		} else if strings.HasSuffix(r.URL.Path, "/explain") {
+			h.EngineExplain(w, r)
+		} else if strings.HasSuffix(r.URL.Path, "/simulate") {
+			h.EngineSimulate(w, r)
+		} else if strings.HasSuffix(r.URL.Path, "/undo-last-cycle") {
+			h.EngineUndo(w, r)
+		} else if strings.HasSuffix(r.URL.Path, "/benchmark") {
+			h.EngineBenchmark(w, r)
 		} else if strings.HasSuffix(r.URL.Path, "/alias") {
 			h.EngineAlias(w, r)
+		} else if strings.HasSuffix(r.URL.Path, "/incidents") {
+			h.EngineIncidents(w, r)
+		} else if strings.HasSuffix(r.URL.Path, "/media") {
+			h.EngineMedia(w, r)
 		} else {
 			h.EngineAction(w, r)
 		}
 	})
 
-	log.Printf("Monitor starting on port %s", port)
-	return http.ListenAndServe(":"+port, mux)
+	if agentAddr != "" {
+		ln, err := listen(agentAddr)
+		if err != nil {
+			return fmt.Errorf("agent API: %w", err)
+		}
+		logger.Info("Agent API listening", "addr", agentAddr)
+		go func() {
+			if err := http.Serve(ln, h.LogRequests(node)); err != nil {
+				logger.Error("Agent API server failed", "addr", agentAddr, "error", err)
+			}
+		}()
+	}
+	logger.Info("Monitor starting", "addr", addr, "base_path", handlers.BasePath)
+	return serve(addr, handlers.WithBasePath(h.LogRequests(mux)), agentAddr != "")
 }
 
 func (a *App) startLogTailer() {
 	logTailer := tailer.New(func(ts, act, p string, sz int64) {
-		_ = database.LogEvent(ts, act, p, sz, "Legacy")
-		item := database.HistoryItem{Time: ts, Action: act, Path: p, Size: database.FormatBytes(sz)}
-		a.WSHub.Broadcast("history", item)
-		a.WSHub.Broadcast("stats", database.GetTrafficStats())
-		a.WSHub.Broadcast("daily", database.GetDailyTraffic(7))
+		// rsync logs local time
+		if t, err := time.ParseInLocation("2006/01/02 15:04:05", ts, time.Local); err == nil {
+			ts = database.Timestamp(t)
+		}
+		_ = database.LogEvent(ts, act, p, sz, "Legacy", "")
+		item := database.HistoryItem{Time: database.LocalTime(ts), Action: act, Path: p, Size: database.FormatBytes(sz)}
+		topicHistory.Broadcast(a.WSHub, item)
+		topicStats.Broadcast(a.WSHub, database.GetTrafficStats())
+		topicDaily.Broadcast(a.WSHub, database.GetDailyTraffic(7))
 		a.HealthState.ReportSuccess(a.Notifier.Send)
-	}, func(msg string) { a.HealthState.ReportError(msg, a.Notifier.Send) })
+	}, func(msg string) {
+		if !database.InMaintenance("") {
+			a.HealthState.ReportError(msg, a.Notifier.Send)
+		}
+	})
 	go logTailer.Start()
 }
 
+// startTelegramBot answers bot commands when TELEGRAM_COMMANDS is on. Only
+// the chats in TELEGRAM_ALLOWED_CHAT_IDS (default: TELEGRAM_CHAT_ID) may use it.
+func (a *App) startTelegramBot(h *handlers.Handlers) {
+	if a.Config.TelegramToken == "" || os.Getenv("TELEGRAM_COMMANDS") != "true" {
+		return
+	}
+	allowed := notification.ParseChatIDs(os.Getenv("TELEGRAM_ALLOWED_CHAT_IDS"))
+	if len(allowed) == 0 {
+		allowed = notification.ParseChatIDs(a.Config.TelegramChatID)
+	}
+	if len(allowed) == 0 {
+		logger.Warn("Telegram commands need TELEGRAM_ALLOWED_CHAT_IDS or TELEGRAM_CHAT_ID, bot disabled")
+		return
+	}
+	bot := &notification.TelegramBot{BotToken: a.Config.TelegramToken, Allowed: allowed, Handle: h.BotCommand}
+	go bot.Run()
+}
+
 func (a *App) startHousekeeping() {
 	if err := database.PruneHistory(30); err != nil {
-		log.Printf("Housekeeping error: %v", err)
+		logger.Error("Housekeeping failed", "error", err)
 	}
-	ticker := time.NewTicker(24 * time.Hour)
-	defer ticker.Stop()
-	for range ticker.C {
-		_ = database.PruneHistory(30)
+	if err := database.RollupTraffic(database.GetTrafficRetention()); err != nil {
+		logger.Error("Traffic rollup failed", "error", err)
+	}
+	_ = database.PruneLatencyStats(latencyRetentionDays())
+	prune := time.NewTicker(24 * time.Hour)
+	defer prune.Stop()
+	maintenance := time.NewTicker(time.Minute)
+	defer maintenance.Stop()
+	for {
+		select {
+		case <-prune.C:
+			_ = database.PruneHistory(30)
+			if err := database.RollupTraffic(database.GetTrafficRetention()); err != nil {
+				logger.Error("Traffic rollup failed", "error", err)
+			}
+			_ = database.PruneLatencyStats(latencyRetentionDays())
+		case <-maintenance.C:
+			if err := database.ExpireMaintenance(); err != nil {
+				logger.Error("Failed to expire maintenance", "error", err)
+			}
+		}
 	}
 }
 
@@ -136,3 +299,94 @@ func (a *App) GetSyncEngines() []*syncpkg.Engine {
 	defer a.engineMu.RUnlock()
 	return a.SyncEngines
 }
+
+// startChangeJournal watches the receiver tree so senders can fetch deltas
+// instead of full manifests
+func startChangeJournal() *syncpkg.ChangeJournal {
+	if os.Getenv("CHANGE_JOURNAL") == "false" {
+		return nil
+	}
+	rootDir := os.Getenv("SOURCE_DIR")
+	if rootDir == "" {
+		rootDir = "/data"
+	}
+	capacity, _ := strconv.Atoi(os.Getenv("CHANGE_JOURNAL_SIZE"))
+	j, err := syncpkg.NewChangeJournal(rootDir, capacity)
+	if err != nil {
+		logger.Warn("Change journal unavailable, senders will fetch full manifests", "error", err)
+		return nil
+	}
+	return j
+}
+
+// startAdvertiser announces this receiver and its rsync modules via mDNS
+func startAdvertiser(port int) *discovery.Advertiser {
+	if os.Getenv("MDNS_ADVERTISE") != "true" {
+		return nil
+	}
+	if port == 0 {
+		logger.Warn("mDNS needs a TCP port, not advertising a Unix socket")
+		return nil
+	}
+	var modules []string
+	for _, m := range strings.Split(os.Getenv("MDNS_MODULES"), ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			modules = append(modules, m)
+		}
+	}
+	if len(modules) == 0 {
+		confPath := os.Getenv("RSYNC_CONFIG")
+		if confPath == "" {
+			confPath = "/scripts/rsyncd.conf"
+		}
+		if data, err := os.ReadFile(confPath); err == nil {
+			modules = discovery.ParseRsyncModules(string(data))
+		} else {
+			logger.Warn("Could not read rsync modules for mDNS", "path", confPath, "error", err)
+		}
+	}
+	adv, err := discovery.Advertise(discovery.Service{
+		Instance: os.Getenv("MDNS_NAME"),
+		Port:     port,
+		Modules:  modules,
+		Protocol: agent.Version,
+	})
+	if err != nil {
+		logger.Warn("mDNS advertisement unavailable", "error", err)
+		return nil
+	}
+	return adv
+}
+
+// startDiskMonitor collects SMART data for the disks backing the receiver's data path.
+// It returns nil when disabled or smartctl is not installed.
+func startDiskMonitor() *smart.Monitor {
+	if os.Getenv("SMART_ENABLED") == "false" {
+		return nil
+	}
+	if !smart.Available() {
+		logger.Warn("smartctl not found, disk health reporting disabled")
+		return nil
+	}
+	rootDir := os.Getenv("RSYNC_MODULE_PATH")
+	if rootDir == "" {
+		rootDir = "/data"
+	}
+	var devices []string
+	for _, d := range strings.Split(os.Getenv("SMART_DEVICES"), ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			devices = append(devices, d)
+		}
+	}
+	tempWarn := smart.DefaultTempWarn
+	if val, err := strconv.Atoi(os.Getenv("SMART_TEMP_WARN")); err == nil && val > 0 {
+		tempWarn = val
+	}
+	interval := 10 * time.Minute
+	if val, err := strconv.Atoi(os.Getenv("SMART_INTERVAL")); err == nil && val > 0 {
+		interval = time.Duration(val) * time.Second
+	}
+	m := smart.NewMonitor(rootDir, devices, tempWarn)
+	go m.Start(interval)
+	return m
+}
@@ -0,0 +1,288 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	stdsync "sync"
+	"time"
+
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/sync"
+)
+
+// dockerRetryInterval is how long the watcher waits before reconnecting to the Docker socket
+var dockerRetryInterval = 10 * time.Second
+
+// errNoContainer is returned by inspect for containers Docker does not know
+var errNoContainer = errors.New("no such container")
+
+// dockerEvent is the part of a Docker engine event the watcher uses
+type dockerEvent struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+	Actor  struct {
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+}
+
+// dockerWatcher pauses engines while the containers they depend on (e.g. the
+// downloader writing into the source) are stopped, restarting or unhealthy,
+// and resumes them once the containers run and report healthy again.
+type dockerWatcher struct {
+	engines    []*sync.Engine
+	containers map[string][]string // Engine ID -> containers it waits for
+	client     *http.Client
+	// inspect reports whether a container is running and healthy (or has no
+	// health check)
+	inspect func(name string) (bool, error)
+
+	mu     stdsync.Mutex
+	down   map[string]string // Container -> why it is down
+	paused map[string]bool   // Engines paused by the watcher
+}
+
+// newDockerWatcher reads DOCKER_PAUSE_CONTAINERS, SYNC_N_DOCKER_CONTAINERS and
+// DOCKER_SOCKET; nil when no engine waits for a container
+func newDockerWatcher(engines []*sync.Engine) *dockerWatcher {
+	global := splitPatterns(os.Getenv("DOCKER_PAUSE_CONTAINERS"))
+	containers := make(map[string][]string)
+	for _, e := range engines {
+		id := e.GetConfig().ID
+		names := global
+		// Per-engine lists replace the global one
+		if env := engineEnv(engineEnvKey(id), "DOCKER_CONTAINERS"); env != "" {
+			names = splitPatterns(env)
+		}
+		if len(names) > 0 {
+			containers[id] = names
+		}
+	}
+	if len(containers) == 0 {
+		return nil
+	}
+	socket := os.Getenv("DOCKER_SOCKET")
+	if socket == "" {
+		socket = "/var/run/docker.sock"
+	}
+	w := &dockerWatcher{
+		engines: engines, containers: containers, down: make(map[string]string), paused: make(map[string]bool),
+		client: &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		}},
+	}
+	w.inspect = w.inspectContainer
+	logger.Info("Docker container watch enabled", "socket", socket, "containers", strings.Join(w.watched(), ","))
+	return w
+}
+
+// watched returns every container some engine waits for
+func (w *dockerWatcher) watched() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, list := range w.containers {
+		for _, n := range list {
+			if !seen[n] {
+				seen[n] = true
+				names = append(names, n)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// run follows the Docker events, reconnecting when the socket goes away.
+// Every (re)connect inspects the containers to catch up on missed events.
+func (w *dockerWatcher) run() {
+	for {
+		w.refresh()
+		err := w.stream()
+		logger.Warn("Docker event stream ended, reconnecting", "error", err, "retry", dockerRetryInterval.String())
+		time.Sleep(dockerRetryInterval)
+	}
+}
+
+// refresh sets the state of every watched container from an inspection.
+// Unknown containers keep their state, so a name that does not exist never
+// pauses an engine on its own.
+func (w *dockerWatcher) refresh() {
+	for _, name := range w.watched() {
+		healthy, err := w.inspect(name)
+		switch {
+		case errors.Is(err, errNoContainer):
+			logger.Warn("Watched container not found", "container", name)
+		case err != nil:
+			logger.Warn("Failed to inspect container", "container", name, "error", err)
+		case healthy:
+			w.setDown(name, "")
+		default:
+			w.setDown(name, "not running or not healthy")
+		}
+	}
+}
+
+// stream reads container events until the connection fails
+func (w *dockerWatcher) stream() error {
+	filters := url.QueryEscape(`{"type":["container"]}`)
+	resp, err := w.client.Get("http://docker/events?filters=" + filters)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("docker events: %s", resp.Status)
+	}
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var ev dockerEvent
+		if err := dec.Decode(&ev); err != nil {
+			return err
+		}
+		w.handle(ev)
+	}
+}
+
+// handle updates the container state from an event
+func (w *dockerWatcher) handle(ev dockerEvent) {
+	if ev.Type != "" && ev.Type != "container" {
+		return
+	}
+	name := w.containerName(ev.Actor.Attributes)
+	if name == "" {
+		return
+	}
+	switch action := strings.TrimSpace(ev.Action); action {
+	case "stop", "kill", "die", "destroy", "health_status: unhealthy":
+		w.setDown(name, action)
+	case "start":
+		// With a health check the container is only back once it reports healthy
+		if healthy, err := w.inspect(name); err == nil && healthy {
+			w.setDown(name, "")
+		} else if err == nil {
+			w.setDown(name, "waiting for health check")
+		}
+	case "health_status: healthy":
+		w.setDown(name, "")
+	}
+}
+
+// containerName returns the watched name an event refers to: the container
+// name or its compose service
+func (w *dockerWatcher) containerName(attrs map[string]string) string {
+	for _, n := range w.watched() {
+		if attrs["name"] == n || attrs["com.docker.compose.service"] == n {
+			return n
+		}
+	}
+	return ""
+}
+
+// setDown marks a container down with a reason, or up when reason is empty,
+// and pauses or resumes the engines waiting for it
+func (w *dockerWatcher) setDown(name, reason string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if reason == "" {
+		if _, ok := w.down[name]; !ok {
+			return
+		}
+		delete(w.down, name)
+		logger.Info("Watched container is back", "container", name)
+	} else {
+		if _, ok := w.down[name]; !ok {
+			logger.Warn("Watched container is down", "container", name, "reason", reason)
+		}
+		w.down[name] = reason
+	}
+
+	for _, e := range w.engines {
+		id := e.GetConfig().ID
+		waiting := w.waitingFor(id)
+		switch {
+		case len(waiting) > 0 && !w.paused[id]:
+			if e.IsPaused() {
+				continue // Paused by the user, leave it to them
+			}
+			e.Pause()
+			w.paused[id] = true
+			msg := fmt.Sprintf("Engine %s paused while container %s restarts", id, strings.Join(waiting, ", "))
+			logger.Warn(msg)
+			_ = database.LogSystemEvent("system", "Container Pause", msg)
+		case len(waiting) == 0 && w.paused[id]:
+			delete(w.paused, id)
+			// The user may have paused the engine in the meantime
+			if database.GetSetting("engine_paused_"+id, "false") == "true" {
+				continue
+			}
+			e.Resume()
+			_ = database.LogSystemEvent("system", "Container Resume", fmt.Sprintf("Engine %s resumed, its containers are healthy again", id))
+		}
+	}
+}
+
+// waitingFor returns the down containers engine id waits for. The caller holds w.mu.
+func (w *dockerWatcher) waitingFor(id string) []string {
+	var names []string
+	for _, n := range w.containers[id] {
+		if _, ok := w.down[n]; ok {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// PausedFor returns the containers an engine paused by the watcher waits for
+func (w *dockerWatcher) PausedFor(id string) string {
+	if w == nil {
+		return ""
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.paused[id] {
+		return ""
+	}
+	return strings.Join(w.waitingFor(id), ", ")
+}
+
+// inspectContainer asks Docker whether a container runs and is healthy
+func (w *dockerWatcher) inspectContainer(name string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/containers/"+url.PathEscape(name)+"/json", nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusNotFound {
+		return false, errNoContainer
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("docker inspect: %s", resp.Status)
+	}
+	var info struct {
+		State struct {
+			Running bool `json:"Running"`
+			Health  *struct {
+				Status string `json:"Status"`
+			} `json:"Health"`
+		} `json:"State"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return false, err
+	}
+	return info.State.Running && (info.State.Health == nil || info.State.Health.Status == "healthy"), nil
+}
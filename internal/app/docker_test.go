@@ -0,0 +1,69 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/sync"
+)
+
+func TestDockerWatcher_PausesWhileContainerRestarts(t *testing.T) {
+	database.DBPath = filepath.Join(t.TempDir(), "docker.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+
+	e1 := sync.NewEngine(sync.SyncConfig{ID: "1", SourceDir: t.TempDir(), TargetDir: t.TempDir()})
+	e2 := sync.NewEngine(sync.SyncConfig{ID: "2", SourceDir: t.TempDir(), TargetDir: t.TempDir()})
+	healthy := map[string]bool{"qbittorrent": true}
+	w := &dockerWatcher{
+		engines:    []*sync.Engine{e1, e2},
+		containers: map[string][]string{"1": {"qbittorrent"}},
+		inspect:    func(name string) (bool, error) { return healthy[name], nil },
+		down:       make(map[string]string),
+		paused:     make(map[string]bool),
+	}
+	event := func(action string, attrs map[string]string) dockerEvent {
+		ev := dockerEvent{Type: "container", Action: action}
+		ev.Actor.Attributes = attrs
+		return ev
+	}
+	byName := map[string]string{"name": "qbittorrent"}
+
+	w.handle(event("die", map[string]string{"name": "other"}))
+	if e1.IsPaused() {
+		t.Fatal("Containers nobody waits for must not pause engines")
+	}
+
+	w.handle(event("die", byName))
+	if !e1.IsPaused() || e2.IsPaused() {
+		t.Fatal("Only the engine waiting for the container should pause")
+	}
+	if got := w.PausedFor("1"); got != "qbittorrent" {
+		t.Errorf("PausedFor(1) = %q", got)
+	}
+
+	// Started but its health check has not passed yet
+	healthy["qbittorrent"] = false
+	w.handle(event("start", byName))
+	if !e1.IsPaused() {
+		t.Fatal("The engine should wait for the health check")
+	}
+
+	// Matched through the compose service of a recreated container
+	w.handle(event("health_status: healthy", map[string]string{"name": "media-qbittorrent-1", "com.docker.compose.service": "qbittorrent"}))
+	if e1.IsPaused() || w.PausedFor("1") != "" {
+		t.Fatal("The engine should resume once the container is healthy")
+	}
+	waitIdle(e1)
+
+	// An engine the user paused is left alone
+	e1.Pause()
+	w.handle(event("stop", byName))
+	w.handle(event("health_status: healthy", byName))
+	if !e1.IsPaused() {
+		t.Error("An engine paused by the user must stay paused")
+	}
+}
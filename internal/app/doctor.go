@@ -0,0 +1,103 @@
+package app
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/monitor/doctor"
+	"schnorarr/internal/monitor/logging"
+)
+
+var doctorLog = logging.For("doctor")
+
+// configuredEngines returns the engines defined through SYNC_N_SOURCE/SYNC_N_TARGET
+func configuredEngines() []doctor.Engine {
+	var engines []doctor.Engine
+	for _, spec := range engineSpecs() {
+		engines = append(engines, doctor.Engine{ID: spec.ID, SourceDir: spec.Source, TargetDir: resolveTarget(spec.Target)})
+	}
+	return engines
+}
+
+// doctorEngines returns the engines the doctor validates; receivers run none
+func doctorEngines() []doctor.Engine {
+	if os.Getenv("MODE") != "sender" {
+		return nil
+	}
+	return configuredEngines()
+}
+
+// startupCheck logs consistency problems without repairing them
+func (a *App) startupCheck() {
+	report := doctor.Run(doctorEngines())
+	for _, f := range report.Findings {
+		if f.Severity == doctor.SeverityError {
+			doctorLog.Error(f.Message, "finding", f.ID)
+		} else {
+			doctorLog.Warn(f.Message, "finding", f.ID)
+		}
+	}
+	if len(report.Findings) > 0 {
+		doctorLog.Warn("Consistency problems found; run `monitor doctor` or POST /api/admin/doctor to repair", "count", len(report.Findings))
+	}
+}
+
+// RunDoctor implements the `doctor` command. Repairable findings are confirmed
+// interactively unless -repair or -check is given. It returns the exit code.
+func RunDoctor(args []string, in io.Reader, out io.Writer) int {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	fs.SetOutput(out)
+	repairAll := fs.Bool("repair", false, "repair all repairable findings without asking")
+	checkOnly := fs.Bool("check", false, "only report findings")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	logging.Setup(io.Discard, "") // keep migration logging out of the report
+	if err := database.Init(); err != nil {
+		_, _ = fmt.Fprintf(out, "Failed to open database %s: %v\n", database.DBPath, err)
+		return 1
+	}
+	defer func() { _ = database.DB.Close() }()
+
+	report := doctor.Run(doctorEngines())
+	if len(report.Findings) == 0 {
+		_, _ = fmt.Fprintln(out, "No problems found")
+		return 0
+	}
+	for _, f := range report.Findings {
+		_, _ = fmt.Fprintf(out, "[%s] %s\n", strings.ToUpper(f.Severity), f.Message)
+		if f.Fix != "" {
+			_, _ = fmt.Fprintf(out, "        fix: %s\n", f.Fix)
+		}
+	}
+
+	if !*checkOnly {
+		reader := bufio.NewReader(in)
+		repaired := report.Repair(func(f *doctor.Finding) bool {
+			if *repairAll {
+				return true
+			}
+			_, _ = fmt.Fprintf(out, "%s -> %s? [y/N] ", f.Message, f.Fix)
+			answer, _ := reader.ReadString('\n')
+			answer = strings.ToLower(strings.TrimSpace(answer))
+			return answer == "y" || answer == "yes"
+		})
+		for _, f := range report.Findings {
+			if f.RepairError != "" {
+				_, _ = fmt.Fprintf(out, "Repair of %s failed: %s\n", f.ID, f.RepairError)
+			}
+		}
+		_, _ = fmt.Fprintf(out, "Repaired %d of %d findings\n", repaired, len(report.Findings))
+	}
+
+	if !report.Healthy() {
+		return 1
+	}
+	return 0
+}
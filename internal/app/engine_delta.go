@@ -0,0 +1,101 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+)
+
+// materialPercent is the change of a percentage worth sending
+const materialPercent = 0.5
+
+// engineTracker remembers what clients were last sent per engine, so the
+// broadcaster only sends fields that changed materially
+type engineTracker struct {
+	ids  []string
+	sent map[string]map[string]json.RawMessage
+}
+
+func newEngineTracker() *engineTracker {
+	return &engineTracker{sent: make(map[string]map[string]json.RawMessage)}
+}
+
+// update compares engines with what was sent before. It returns the deltas to
+// broadcast and whether the set of engines changed, in which case a new
+// snapshot has to be sent instead.
+func (t *engineTracker) update(engines []EngineProgress) ([]EngineDelta, bool) {
+	changedSet := len(engines) != len(t.ids)
+	for i, e := range engines {
+		if !changedSet && t.ids[i] != e.ID {
+			changedSet = true
+		}
+	}
+	if changedSet {
+		t.ids = t.ids[:0]
+		t.sent = make(map[string]map[string]json.RawMessage)
+		for _, e := range engines {
+			t.ids = append(t.ids, e.ID)
+			t.sent[e.ID] = engineFields(e)
+		}
+		return nil, true
+	}
+
+	var deltas []EngineDelta
+	for _, e := range engines {
+		prev := t.sent[e.ID]
+		fields := engineFields(e)
+		changes := make(map[string]json.RawMessage)
+		for key, v := range fields {
+			if old, ok := prev[key]; ok && !materialChange(key, old, v) {
+				continue
+			}
+			changes[key] = v
+			prev[key] = v
+		}
+		// Fields dropped by omitempty went back to their zero value
+		for key, old := range prev {
+			if _, ok := fields[key]; !ok {
+				changes[key] = zeroLike(old)
+				delete(prev, key)
+			}
+		}
+		if len(changes) > 0 {
+			deltas = append(deltas, EngineDelta{ID: e.ID, Changes: changes})
+		}
+	}
+	return deltas, false
+}
+
+// engineFields splits the JSON of an engine into its fields
+func engineFields(e EngineProgress) map[string]json.RawMessage {
+	data, _ := json.Marshal(e)
+	var fields map[string]json.RawMessage
+	_ = json.Unmarshal(data, &fields)
+	delete(fields, "id")
+	return fields
+}
+
+// materialChange ignores small percentage moves; everything else counts
+func materialChange(key string, old, v json.RawMessage) bool {
+	if key == "percent" || key == "quota_percent" {
+		var a, b float64
+		if json.Unmarshal(old, &a) == nil && json.Unmarshal(v, &b) == nil {
+			// Reaching 0 or 100 is always shown
+			return math.Abs(a-b) >= materialPercent || (a != b && (b == 0 || b == 100))
+		}
+	}
+	return !bytes.Equal(old, v)
+}
+
+// zeroLike returns the JSON zero value of the kind of v
+func zeroLike(v json.RawMessage) json.RawMessage {
+	switch {
+	case len(v) > 0 && v[0] == '"':
+		return json.RawMessage(`""`)
+	case len(v) > 0 && (v[0] == '[' || v[0] == '{' || v[0] == 'n'):
+		return json.RawMessage(`null`)
+	case bytes.Equal(v, []byte("true")) || bytes.Equal(v, []byte("false")):
+		return json.RawMessage(`false`)
+	}
+	return json.RawMessage(`0`)
+}
@@ -0,0 +1,39 @@
+package app
+
+import (
+	"testing"
+)
+
+func TestEngineTracker(t *testing.T) {
+	tracker := newEngineTracker()
+	engines := []EngineProgress{{ID: "1", Speed: "0 B/s"}, {ID: "2", Speed: "0 B/s", Cycle: "c1"}}
+	if _, snapshot := tracker.update(engines); !snapshot {
+		t.Fatal("The first update should ask for a snapshot")
+	}
+	if deltas, snapshot := tracker.update(engines); snapshot || len(deltas) != 0 {
+		t.Errorf("Unchanged engines should send nothing, got %+v", deltas)
+	}
+
+	engines[0].Percent = 0.3 // Not material
+	engines[1].Speed = "5 MB/s"
+	engines[1].Cycle = "" // Dropped by omitempty
+	deltas, _ := tracker.update(engines)
+	if len(deltas) != 1 || deltas[0].ID != "2" || len(deltas[0].Changes) != 2 ||
+		string(deltas[0].Changes["speed"]) != `"5 MB/s"` || string(deltas[0].Changes["cycle"]) != `""` {
+		t.Errorf("Expected speed and the cleared cycle of engine 2, got %+v", deltas)
+	}
+
+	engines[0].Percent = 0.9 // Material against what was sent (0)
+	deltas, _ = tracker.update(engines)
+	if len(deltas) != 1 || string(deltas[0].Changes["percent"]) != "0.9" {
+		t.Errorf("Expected the accumulated percent change, got %+v", deltas)
+	}
+	engines[0].Percent = 1.0
+	if deltas, _ := tracker.update(engines); len(deltas) != 0 {
+		t.Errorf("Expected no delta below the threshold, got %+v", deltas)
+	}
+
+	if _, snapshot := tracker.update(engines[:1]); !snapshot {
+		t.Error("A removed engine should ask for a snapshot")
+	}
+}
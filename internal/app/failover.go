@@ -0,0 +1,57 @@
+package app
+
+// receiverFailover picks the receiver from the prioritized DEST_HOST list.
+// The active host is abandoned after threshold failed checks for the first
+// healthy host in priority order, and the primary is taken back after it
+// passed threshold checks in a row.
+type receiverFailover struct {
+	hosts     []string
+	active    int
+	threshold int
+	failures  int // Consecutive failed checks of the active host
+	recovered int // Consecutive passed checks of the primary while failed over
+}
+
+func newReceiverFailover(hosts []string, threshold int) *receiverFailover {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &receiverFailover{hosts: hosts, threshold: threshold}
+}
+
+// Active returns the host engines should sync to
+func (f *receiverFailover) Active() string {
+	return f.hosts[f.active]
+}
+
+// observe records the result of checking the active host and returns the
+// host to switch to, if any. probe checks any other host on demand.
+func (f *receiverFailover) observe(activeHealthy bool, probe func(host string) bool) (string, bool) {
+	if activeHealthy {
+		f.failures = 0
+		if f.active == 0 {
+			return "", false
+		}
+		if !probe(f.hosts[0]) {
+			f.recovered = 0
+			return "", false
+		}
+		if f.recovered++; f.recovered < f.threshold {
+			return "", false
+		}
+		f.active, f.recovered = 0, 0
+		return f.hosts[0], true
+	}
+
+	f.recovered = 0
+	if f.failures++; f.failures < f.threshold {
+		return "", false
+	}
+	for i, host := range f.hosts {
+		if i != f.active && probe(host) {
+			f.active, f.failures = i, 0
+			return host, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,40 @@
+package app
+
+import "testing"
+
+func TestReceiverFailover(t *testing.T) {
+	up := map[string]bool{"nas1": true, "nas2": true, "nas3": true}
+	probe := func(host string) bool { return up[host] }
+	f := newReceiverFailover([]string{"nas1", "nas2", "nas3"}, 2)
+
+	up["nas1"], up["nas2"] = false, false
+	if _, switched := f.observe(false, probe); switched {
+		t.Fatal("A single failed check should not fail over")
+	}
+	if host, switched := f.observe(false, probe); !switched || host != "nas3" {
+		t.Fatalf("Expected failover to the first healthy host nas3, got %q %v", host, switched)
+	}
+	if f.Active() != "nas3" {
+		t.Errorf("Expected nas3 active, got %s", f.Active())
+	}
+
+	up["nas1"] = true
+	if _, switched := f.observe(true, probe); switched {
+		t.Fatal("The primary should pass several checks before failing back")
+	}
+	up["nas1"] = false
+	if _, switched := f.observe(true, probe); switched {
+		t.Fatal("A flapping primary should reset the recovery count")
+	}
+	up["nas1"] = true
+	f.observe(true, probe)
+	if host, switched := f.observe(true, probe); !switched || host != "nas1" {
+		t.Fatalf("Expected failback to nas1, got %q %v", host, switched)
+	}
+
+	up["nas2"], up["nas3"] = false, false
+	f.observe(false, probe)
+	if _, switched := f.observe(false, probe); switched || f.Active() != "nas1" {
+		t.Error("Without a healthy alternative the active host should stay")
+	}
+}
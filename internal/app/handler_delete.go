@@ -1,11 +1,13 @@
 package app
 
 import (
-	"log"
+	"errors"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"schnorarr/internal/agent"
 )
 
 // DeleteHandler handles requests to delete files or directories
@@ -23,6 +25,91 @@ func (a *App) DeleteHandler(w http.ResponseWriter, r *http.Request) {
 
 	isDir := r.URL.Query().Get("dir") == "true"
 
+	fullPath, err := resolveDeletePath(queryPath)
+	if err != nil {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+
+	if err := checkWritable(); err != nil {
+		logger.Warn("Delete refused", "path", queryPath, "error", err)
+		http.Error(w, err.Error(), http.StatusLocked)
+		return
+	}
+
+	logger.Info("Delete requested", "path", queryPath, "is_dir", isDir, "resolved", fullPath)
+
+	deleted, err := deletePath(fullPath, isDir)
+	if err != nil {
+		http.Error(w, "Delete failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// deletePath removes a file or directory tree. A missing target is not an error.
+func deletePath(fullPath string, isDir bool) (bool, error) {
+	var err error
+	if isDir {
+		err = os.RemoveAll(fullPath)
+	} else {
+		err = os.Remove(fullPath)
+	}
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			logger.Info("Delete target does not exist", "path", fullPath)
+			return false, nil
+		}
+		logger.Error("Delete failed", "path", fullPath, "error", err)
+		return false, err
+	}
+
+	logger.Info("Deleted", "path", fullPath)
+	return true, nil
+}
+
+// deleteEmptyDir removes fullPath only if it is an empty directory
+func deleteEmptyDir(fullPath string) (bool, error) {
+	entries, err := os.ReadDir(fullPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if len(entries) > 0 {
+		logger.Info("Directory not empty, keeping it", "path", fullPath, "entries", len(entries))
+		return false, agent.ErrNotEmpty
+	}
+	if err := os.Remove(fullPath); err != nil {
+		logger.Error("Delete failed", "path", fullPath, "error", err)
+		return false, err
+	}
+	logger.Info("Deleted", "path", fullPath)
+	return true, nil
+}
+
+// resolveMkdirPath maps a directory to create to its place below the
+// receiver root
+func resolveMkdirPath(queryPath string) (string, error) {
+	rootDir := os.Getenv("SOURCE_DIR")
+	if rootDir == "" {
+		rootDir = "/data"
+	}
+	cleanPath := filepath.Clean("/" + queryPath)
+	if cleanPath == "/" || strings.Contains(queryPath, "..") {
+		return "", errors.New("invalid path")
+	}
+	return filepath.Join(rootDir, cleanPath), nil
+}
+
+// resolveDeletePath maps a sender-supplied path to a file on this receiver
+func resolveDeletePath(queryPath string) (string, error) {
 	rootDir := os.Getenv("SOURCE_DIR")
 	if rootDir == "" {
 		rootDir = "/data"
@@ -31,8 +118,7 @@ func (a *App) DeleteHandler(w http.ResponseWriter, r *http.Request) {
 	// Sanitize path to prevent traversal
 	cleanPath := filepath.Clean(queryPath)
 	if strings.Contains(cleanPath, "..") {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
-		return
+		return "", errors.New("invalid path")
 	}
 
 	fullPath := filepath.Join(rootDir, cleanPath)
@@ -47,26 +133,5 @@ func (a *App) DeleteHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	log.Printf("[DeleteHandler] Request to delete %s (isDir=%v) resolved to %s", queryPath, isDir, fullPath)
-
-	var err error
-	if isDir {
-		err = os.RemoveAll(fullPath)
-	} else {
-		err = os.Remove(fullPath)
-	}
-
-	if err != nil {
-		if os.IsNotExist(err) {
-			log.Printf("[DeleteHandler] Path does not exist: %s", fullPath)
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
-		log.Printf("[DeleteHandler] Delete failed for %s: %v", fullPath, err)
-		http.Error(w, "Delete failed: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	log.Printf("[DeleteHandler] Successfully deleted %s", fullPath)
-	w.WriteHeader(http.StatusOK)
+	return fullPath, nil
 }
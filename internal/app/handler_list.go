@@ -0,0 +1,91 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"schnorarr/internal/agent"
+)
+
+const (
+	defaultListLimit = 200
+	maxListLimit     = 1000
+)
+
+// rsyncTempName matches the hidden files rsync writes before renaming them
+// into place, e.g. ".movie.mkv.Ab12Cd"
+var rsyncTempName = regexp.MustCompile(`^\..+\.[A-Za-z0-9]{6}$`)
+
+// ListHandler returns a page of a receiver directory: ?path= below
+// RSYNC_MODULE_PATH, ?offset= and ?limit=
+func (a *App) ListHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	resp, err := agentService{app: a}.List(r.Context(), &agent.ListRequest{
+		Path:   r.URL.Query().Get("path"),
+		Offset: offset,
+		Limit:  limit,
+	})
+	if errors.Is(err, agent.ErrInvalidPath) {
+		http.Error(w, "invalid path", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logger.Error("Listing failed", "path", r.URL.Query().Get("path"), "error", err)
+		http.Error(w, "listing failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logger.Error("Failed to encode listing", "error", err)
+	}
+}
+
+// listDirectory reads dir and returns the page starting at offset,
+// directories first, then by name
+func listDirectory(dir string, offset, limit int) ([]agent.ListEntry, int, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	sort.SliceStable(dirEntries, func(i, j int) bool {
+		if dirEntries[i].IsDir() != dirEntries[j].IsDir() {
+			return dirEntries[i].IsDir()
+		}
+		return strings.ToLower(dirEntries[i].Name()) < strings.ToLower(dirEntries[j].Name())
+	})
+	total := len(dirEntries)
+	if offset > total {
+		offset = total
+	}
+	page := dirEntries[offset:min(offset+limit, total)]
+
+	entries := make([]agent.ListEntry, 0, len(page))
+	for _, d := range page {
+		entry := agent.ListEntry{Name: d.Name(), IsDir: d.IsDir(), Partial: !d.IsDir() && isPartialName(d.Name())}
+		if info, err := d.Info(); err == nil {
+			entry.ModTime = info.ModTime()
+			if !d.IsDir() {
+				entry.Size = info.Size()
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, total, nil
+}
+
+// isPartialName reports whether name is a transfer still in progress
+func isPartialName(name string) bool {
+	return filepath.Ext(name) == ".tmp" || rsyncTempName.MatchString(name)
+}
@@ -1,13 +1,19 @@
 package app
 
 import (
+	"compress/gzip"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	stdsync "sync"
+	"time"
 
+	"schnorarr/internal/agent"
 	"schnorarr/internal/sync"
 )
 
@@ -28,6 +34,134 @@ func (a *App) ManifestHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	manifest, etag, changed, err := a.scanManifest(queryPath, r.URL.Query().Get("sub"))
+	if errors.Is(err, agent.ErrInvalidPath) {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Scan failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", changed.UTC().Format(http.TimeFormat))
+	w.Header().Set("Vary", "Accept, Accept-Encoding")
+	if agent.NotModified(r.Header.Get("If-None-Match"), r.Header.Get("If-Modified-Since"), etag, changed) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	var out io.Writer = w
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer func() { _ = gz.Close() }()
+		out = gz
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), sync.ManifestNDJSON) {
+		w.Header().Set("Content-Type", sync.ManifestNDJSON)
+		if err := sync.WriteManifestNDJSON(out, manifest); err != nil {
+			logger.Error("Failed to stream manifest", "error", err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(out).Encode(manifest); err != nil {
+		logger.Error("Failed to encode manifest", "error", err)
+	}
+}
+
+// ChangesHandler reports which subtrees of a path changed since a cursor:
+// GET /api/changes?path=<path>&since=<cursor>. A reset answer (or 404 when the
+// journal is disabled) tells the sender to fetch the full manifest.
+func (a *App) ChangesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	queryPath := r.URL.Query().Get("path")
+	if queryPath == "" {
+		http.Error(w, "Missing path parameter", http.StatusBadRequest)
+		return
+	}
+	resp, err := a.changes(queryPath, r.URL.Query().Get("since"))
+	if errors.Is(err, errNoJournal) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logger.Error("Failed to encode changes", "error", err)
+	}
+}
+
+// errNoJournal is returned when changes cannot be served from the journal
+var errNoJournal = errors.New("change journal disabled")
+
+// changes reads the journal entries below a receiver path since a cursor
+func (a *App) changes(queryPath, since string) (*agent.ChangesResponse, error) {
+	if a.journal == nil {
+		return nil, errNoJournal
+	}
+	fullPath, err := resolveManifestPath(queryPath)
+	if err != nil {
+		return nil, agent.ErrInvalidPath
+	}
+	rel, err := filepath.Rel(a.journal.Root(), fullPath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return nil, fmt.Errorf("%w: path outside journal", errNoJournal)
+	}
+	changes, cursor, reset := a.journal.Changes(rel, since)
+	if changes == nil {
+		changes = []string{}
+	}
+	return &agent.ChangesResponse{Cursor: cursor, Reset: reset, Changes: changes}, nil
+}
+
+// scanManifest scans a receiver path, or only its subtree sub, and returns the
+// manifest with its ETag and when this receiver first served that ETag
+func (a *App) scanManifest(queryPath, sub string) (*sync.Manifest, string, time.Time, error) {
+	fullPath, err := resolveManifestPath(queryPath)
+	if err != nil {
+		return nil, "", time.Time{}, agent.ErrInvalidPath
+	}
+
+	// sub narrows the scan to one subtree, used by senders patching a cached
+	// manifest from /api/changes
+	if sub != "" && strings.Contains(filepath.Clean(sub), "..") {
+		return nil, "", time.Time{}, agent.ErrInvalidPath
+	}
+
+	// Scan!
+	sync.AcquireScanLock()
+	scanner := sync.NewScanner()
+	scanner.ExcludePatterns = []string{batchJournalDir, batchStagePrefix + "*"}
+	var manifest *sync.Manifest
+	if sub != "" {
+		manifest, err = scanner.ScanSubtree(fullPath, filepath.Clean(sub))
+	} else {
+		manifest, err = scanner.ScanLocal(fullPath)
+	}
+	sync.ReleaseScanLock()
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+
+	// The tree digest doubles as ETag; Last-Modified is when this receiver
+	// first served the current digest for the path
+	etag := `"` + manifest.Digest() + `"`
+	changed := a.manifestVersions.observe(fullPath+"\x00"+sub, etag)
+	return manifest, etag, changed, nil
+}
+
+// resolveManifestPath maps a sender-supplied path to a directory on this receiver
+func resolveManifestPath(queryPath string) (string, error) {
 	// Resolve the path based on modules or default data dir
 	// Assumption: queryPath is relative to /data or is a module path
 	// E.g. path=video-sync/movies
@@ -47,8 +181,7 @@ func (a *App) ManifestHandler(w http.ResponseWriter, r *http.Request) {
 	// Sanitize path to prevent traversal
 	cleanPath := filepath.Clean(queryPath)
 	if strings.Contains(cleanPath, "..") {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
-		return
+		return "", errors.New("invalid path")
 	}
 
 	// If the path is absolute, check if it starts with rootDir?
@@ -93,18 +226,31 @@ func (a *App) ManifestHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Scan!
-	sync.AcquireScanLock()
-	scanner := sync.NewScanner()
-	manifest, err := scanner.ScanLocal(fullPath)
-	sync.ReleaseScanLock()
-	if err != nil {
-		http.Error(w, "Scan failed: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
+	return fullPath, nil
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(manifest); err != nil {
-		log.Printf("Failed to encode manifest: %v", err)
+// manifestVersions remembers the digest of every served path and when it last changed
+type manifestVersions struct {
+	mu       stdsync.Mutex
+	versions map[string]manifestVersion
+}
+
+type manifestVersion struct {
+	etag    string
+	changed time.Time
+}
+
+// observe records the current digest of a path and returns when it changed
+func (v *manifestVersions) observe(path, etag string) time.Time {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.versions == nil {
+		v.versions = make(map[string]manifestVersion)
+	}
+	if prev, ok := v.versions[path]; ok && prev.etag == etag {
+		return prev.changed
 	}
+	now := time.Now()
+	v.versions[path] = manifestVersion{etag: etag, changed: now}
+	return now
 }
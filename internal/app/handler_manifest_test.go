@@ -0,0 +1,114 @@
+package app
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"schnorarr/internal/sync"
+)
+
+func TestManifestHandler_StreamingAndETag(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("SOURCE_DIR", root)
+	if err := os.MkdirAll(filepath.Join(root, "movies", "A"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "movies", "A", "a.mkv"), []byte("movie"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &App{}
+	get := func(header http.Header) *http.Response {
+		req := httptest.NewRequest("GET", "/api/manifest?path=movies", nil)
+		req.Header = header
+		w := httptest.NewRecorder()
+		a.ManifestHandler(w, req)
+		return w.Result()
+	}
+
+	resp := get(http.Header{"Accept": {sync.ManifestNDJSON}, "Accept-Encoding": {"gzip"}})
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != sync.ManifestNDJSON || resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip NDJSON, got %d %v", resp.StatusCode, resp.Header)
+	}
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := sync.ReadManifestNDJSON(gz)
+	if err != nil {
+		t.Fatalf("Failed to read streamed manifest: %v", err)
+	}
+	if !manifest.HasFile("A/a.mkv") || !manifest.HasDir("A") {
+		t.Errorf("Unexpected manifest: %v", manifest.Files)
+	}
+
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if etag == "" || lastModified == "" {
+		t.Fatal("Expected ETag and Last-Modified headers")
+	}
+	if resp := get(http.Header{"If-None-Match": {etag}}); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("Unchanged tree should return 304, got %d", resp.StatusCode)
+	}
+	if resp := get(http.Header{"If-Modified-Since": {lastModified}}); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("Unchanged tree should return 304 for If-Modified-Since, got %d", resp.StatusCode)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "movies", "A", "b.mkv"), []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	resp = get(http.Header{"If-None-Match": {etag}})
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") == etag {
+		t.Errorf("Changed tree should return a new manifest, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Clients without NDJSON support should get JSON, got %s", resp.Header.Get("Content-Type"))
+	}
+}
+
+func TestChangesHandler(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("SOURCE_DIR", root)
+	if err := os.MkdirAll(filepath.Join(root, "movies"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/changes?path=movies", nil)
+	w := httptest.NewRecorder()
+	(&App{}).ChangesHandler(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Disabled journal should return 404, got %d", w.Code)
+	}
+
+	j, err := sync.NewChangeJournal(root, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = j.Close() }()
+	a := &App{journal: j}
+	w = httptest.NewRecorder()
+	a.ChangesHandler(w, req)
+	var body struct {
+		Cursor string `json:"cursor"`
+		Reset  bool   `json:"reset"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || !body.Reset || body.Cursor == "" {
+		t.Errorf("First request should reset with a cursor, got %d %+v", w.Code, body)
+	}
+
+	w = httptest.NewRecorder()
+	a.ChangesHandler(w, httptest.NewRequest("GET", "/api/changes?path=movies&since="+body.Cursor, nil))
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Reset {
+		t.Error("A current cursor should not reset")
+	}
+}
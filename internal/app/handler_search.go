@@ -0,0 +1,112 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"schnorarr/internal/agent"
+)
+
+const (
+	defaultSearchLimit = 100
+	maxSearchLimit     = 1000
+)
+
+// errSearchDone stops the walk once the limit is reached
+var errSearchDone = errors.New("search limit reached")
+
+// SearchHandler finds files on the receiver by name: ?q= is a
+// case-insensitive substring or a glob, ?path= the directory below
+// RSYNC_MODULE_PATH to search and ?limit= the maximum number of matches
+func (a *App) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query().Get("q")
+	if strings.TrimSpace(query) == "" {
+		http.Error(w, "q parameter required", http.StatusBadRequest)
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	resp, err := agentService{app: a}.Search(r.Context(), &agent.SearchRequest{
+		Path:  r.URL.Query().Get("path"),
+		Query: query,
+		Limit: limit,
+	})
+	if errors.Is(err, agent.ErrInvalidPath) {
+		http.Error(w, "invalid path", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logger.Error("Search failed", "query", query, "error", err)
+		http.Error(w, "search failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logger.Error("Failed to encode search response", "error", err)
+	}
+}
+
+// searchFiles walks root for names matching query. Unreadable directories are
+// skipped rather than failing the search.
+func searchFiles(ctx context.Context, root, query string, limit int) (*agent.SearchResponse, error) {
+	match := nameMatcher(query)
+	resp := &agent.SearchResponse{Matches: []agent.SearchMatch{}}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if path == root || !match(d.Name()) {
+			return nil
+		}
+		if len(resp.Matches) >= limit {
+			resp.Truncated = true
+			return errSearchDone
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		resp.Matches = append(resp.Matches, agent.SearchMatch{
+			Path:    filepath.ToSlash(rel),
+			Size:    info.Size(),
+			IsDir:   d.IsDir(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil && !errors.Is(err, errSearchDone) {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// nameMatcher matches file names against a glob when query contains glob
+// characters, otherwise against a substring, ignoring case either way
+func nameMatcher(query string) func(string) bool {
+	query = strings.ToLower(query)
+	if strings.ContainsAny(query, "*?[") {
+		if _, err := filepath.Match(query, ""); err == nil {
+			return func(name string) bool {
+				ok, _ := filepath.Match(query, strings.ToLower(name))
+				return ok
+			}
+		}
+	}
+	return func(name string) bool { return strings.Contains(strings.ToLower(name), query) }
+}
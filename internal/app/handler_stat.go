@@ -2,7 +2,7 @@ package app
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -28,21 +28,12 @@ func (a *App) StatHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Sanitize the path
-	cleanPath := filepath.Clean(queryPath)
-	if strings.HasPrefix(cleanPath, "..") {
+	fullPath, err := resolveStatPath(queryPath)
+	if err != nil {
 		http.Error(w, "invalid path", http.StatusBadRequest)
 		return
 	}
 
-	// Get the root directory from environment
-	rootDir := os.Getenv("RSYNC_MODULE_PATH")
-	if rootDir == "" {
-		rootDir = "/data"
-	}
-
-	fullPath := filepath.Join(rootDir, cleanPath)
-
 	// Get file info
 	info, err := os.Stat(fullPath)
 	response := StatResponse{}
@@ -52,7 +43,7 @@ func (a *App) StatHandler(w http.ResponseWriter, r *http.Request) {
 			response.Exists = false
 			response.Size = 0
 		} else {
-			log.Printf("[StatHandler] Error stating file %s: %v", fullPath, err)
+			logger.Error("Stat failed", "path", fullPath, "error", err)
 			http.Error(w, "failed to stat file", http.StatusInternalServerError)
 			return
 		}
@@ -63,6 +54,22 @@ func (a *App) StatHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("[StatHandler] Error encoding response: %v", err)
+		logger.Error("Failed to encode stat response", "error", err)
+	}
+}
+
+// resolveStatPath maps a sender-supplied path below RSYNC_MODULE_PATH
+func resolveStatPath(queryPath string) (string, error) {
+	// Sanitize the path
+	cleanPath := filepath.Clean(queryPath)
+	if strings.HasPrefix(cleanPath, "..") {
+		return "", errors.New("invalid path")
+	}
+
+	// Get the root directory from environment
+	rootDir := os.Getenv("RSYNC_MODULE_PATH")
+	if rootDir == "" {
+		rootDir = "/data"
 	}
+	return filepath.Join(rootDir, cleanPath), nil
 }
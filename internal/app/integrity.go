@@ -0,0 +1,124 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"schnorarr/internal/sync"
+)
+
+// integrityInterval returns how often the receiver reports its tree
+// summaries to the sender (INTEGRITY_REPORT_MINUTES, 0 disables)
+func integrityInterval() time.Duration {
+	minutes := 60
+	if v, err := strconv.Atoi(os.Getenv("INTEGRITY_REPORT_MINUTES")); err == nil && v >= 0 {
+		minutes = v
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// integrityPaths returns the receiver paths to report: INTEGRITY_PATHS or
+// every top-level directory of the data root
+func integrityPaths() []string {
+	if env := os.Getenv("INTEGRITY_PATHS"); env != "" {
+		var paths []string
+		for _, p := range strings.Split(env, ",") {
+			if p = strings.Trim(strings.TrimSpace(p), "/"); p != "" {
+				paths = append(paths, p)
+			}
+		}
+		return paths
+	}
+	root, err := resolveManifestPath(".")
+	if err != nil {
+		return nil
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		logger.Warn("Failed to list integrity report paths", "root", root, "error", err)
+		return nil
+	}
+	var paths []string
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			paths = append(paths, e.Name())
+		}
+	}
+	return paths
+}
+
+// startIntegrityReporter periodically pushes tree summaries to SENDER_URL,
+// so the sender notices changes made on the target between sync cycles
+func startIntegrityReporter() {
+	senderURL := strings.TrimRight(os.Getenv("SENDER_URL"), "/")
+	interval := integrityInterval()
+	if senderURL == "" || interval == 0 {
+		return
+	}
+	logger.Info("Integrity reports enabled", "sender", senderURL, "interval", interval.String())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := pushIntegrityReport(senderURL, buildIntegrityReport()); err != nil {
+			logger.Warn("Failed to push integrity report", "sender", senderURL, "error", err)
+		}
+	}
+}
+
+// buildIntegrityReport scans every report path like a manifest request would
+func buildIntegrityReport() sync.IntegrityReport {
+	host, _ := os.Hostname()
+	report := sync.IntegrityReport{Receiver: host, Targets: []sync.TargetSummary{}}
+	for _, p := range integrityPaths() {
+		fullPath, err := resolveManifestPath(p)
+		if err != nil {
+			continue
+		}
+		// Scanned is when the scan started, so a sync cycle overlapping it is
+		// never compared against a newer expectation
+		started := time.Now()
+		sync.AcquireScanLock()
+		manifest, err := sync.NewScanner().ScanLocal(fullPath)
+		sync.ReleaseScanLock()
+		if err != nil {
+			logger.Warn("Integrity scan failed", "path", p, "error", err)
+			continue
+		}
+		report.Targets = append(report.Targets, sync.Summarize(p, manifest, started))
+	}
+	return report
+}
+
+// pushIntegrityReport posts a report to the sender's /api/integrity
+func pushIntegrityReport(senderURL string, report sync.IntegrityReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", senderURL+"/api/integrity", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token := os.Getenv("INTEGRITY_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("sender returned status %s", resp.Status)
+	}
+	return nil
+}
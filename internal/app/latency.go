@@ -0,0 +1,123 @@
+package app
+
+import (
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	stdsync "sync"
+	"time"
+
+	"schnorarr/internal/monitor/database"
+)
+
+// latencyBucket is the period receiver latency is stored for
+const latencyBucket = 5 * time.Minute
+
+// latencyWindow is the number of checks the live stats cover (10 minutes at 15s)
+const latencyWindow = 40
+
+// latencySample is one health check of the receiver; lost checks have no latency
+type latencySample struct {
+	ms   float64
+	lost bool
+}
+
+// latencyMonitor keeps rolling latency and loss statistics of the receiver
+// health checks: live ones over the last latencyWindow checks and one row
+// per latencyBucket in the database, so slow links can be told apart from
+// slow disks after the fact.
+type latencyMonitor struct {
+	mu     stdsync.Mutex
+	recent []latencySample // The last latencyWindow checks
+	host   string
+	start  time.Time // Start of the bucket being filled
+	bucket []latencySample
+}
+
+func newLatencyMonitor() *latencyMonitor {
+	return &latencyMonitor{}
+}
+
+// record adds a check of host; lost checks failed or timed out
+func (m *latencyMonitor) record(host string, latency time.Duration, lost bool, now time.Time) {
+	s := latencySample{ms: float64(latency.Microseconds()) / 1000, lost: lost}
+	start := now.Truncate(latencyBucket)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.bucket) > 0 && (!start.Equal(m.start) || host != m.host) {
+		m.flush()
+	}
+	if len(m.bucket) == 0 {
+		m.start = start
+	}
+	m.host = host
+	m.bucket = append(m.bucket, s)
+	m.recent = append(m.recent, s)
+	if len(m.recent) > latencyWindow {
+		m.recent = m.recent[len(m.recent)-latencyWindow:]
+	}
+}
+
+// flush stores the bucket being filled; m.mu must be held
+func (m *latencyMonitor) flush() {
+	stats := summarizeLatency(m.bucket)
+	stats.Time, stats.Host = m.start, m.host
+	if err := database.SaveLatencyStats(stats); err != nil {
+		logger.Warn("Failed to save latency stats", "error", err)
+	}
+	m.bucket = nil
+}
+
+// Current returns the stats of the last checks, nil before the first one
+func (m *latencyMonitor) Current() *database.LatencyStats {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.recent) == 0 {
+		return nil
+	}
+	stats := summarizeLatency(m.recent)
+	stats.Host = m.host
+	return &stats
+}
+
+// summarizeLatency computes min, average, 95th percentile, max and loss
+func summarizeLatency(samples []latencySample) database.LatencyStats {
+	stats := database.LatencyStats{Samples: len(samples)}
+	var ms []float64
+	for _, s := range samples {
+		if s.lost {
+			stats.Lost++
+			continue
+		}
+		ms = append(ms, s.ms)
+	}
+	if stats.Samples > 0 {
+		stats.Loss = float64(stats.Lost) * 100 / float64(stats.Samples)
+	}
+	if len(ms) == 0 {
+		return stats
+	}
+	sort.Float64s(ms)
+	var sum float64
+	for _, v := range ms {
+		sum += v
+	}
+	round := func(v float64) float64 { return math.Round(v*10) / 10 }
+	stats.MinMs, stats.MaxMs = round(ms[0]), round(ms[len(ms)-1])
+	stats.AvgMs = round(sum / float64(len(ms)))
+	stats.P95Ms = round(ms[int(math.Ceil(0.95*float64(len(ms))))-1]) // Nearest rank
+	return stats
+}
+
+// latencyRetentionDays is how long latency stats are kept (LATENCY_DAYS)
+func latencyRetentionDays() int {
+	if days, err := strconv.Atoi(os.Getenv("LATENCY_DAYS")); err == nil && days > 0 {
+		return days
+	}
+	return 30
+}
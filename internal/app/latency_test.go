@@ -0,0 +1,65 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"schnorarr/internal/monitor/database"
+)
+
+func TestSummarizeLatency(t *testing.T) {
+	var samples []latencySample
+	for i := 1; i <= 20; i++ {
+		samples = append(samples, latencySample{ms: float64(i)})
+	}
+	samples = append(samples, latencySample{lost: true}, latencySample{lost: true})
+
+	s := summarizeLatency(samples)
+	if s.Samples != 22 || s.Lost != 2 {
+		t.Fatalf("Expected 22 samples with 2 lost, got %+v", s)
+	}
+	if s.MinMs != 1 || s.MaxMs != 20 || s.AvgMs != 10.5 || s.P95Ms != 19 {
+		t.Errorf("Unexpected stats %+v", s)
+	}
+	if s.Loss < 9 || s.Loss > 9.1 {
+		t.Errorf("Expected 9.1%% loss, got %v", s.Loss)
+	}
+
+	if s := summarizeLatency([]latencySample{{lost: true}}); s.Loss != 100 || s.AvgMs != 0 {
+		t.Errorf("Only lost checks should be all loss, got %+v", s)
+	}
+}
+
+func TestLatencyMonitor_FlushesBuckets(t *testing.T) {
+	database.DBPath = filepath.Join(t.TempDir(), "latency.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+
+	var m *latencyMonitor
+	if m.Current() != nil {
+		t.Fatal("A receiver mode app has no latency stats")
+	}
+	m = newLatencyMonitor()
+	start := time.Now().Add(-time.Hour).Truncate(latencyBucket)
+	m.record("receiver", 10*time.Millisecond, false, start)
+	m.record("receiver", 30*time.Millisecond, false, start.Add(time.Minute))
+	m.record("receiver", 5*time.Second, true, start.Add(2*time.Minute))
+	if got, err := database.GetLatencyStats(start.Add(-time.Hour)); err != nil || len(got) != 0 {
+		t.Fatalf("The bucket is stored once it is over, got %+v", got)
+	}
+
+	m.record("receiver", 20*time.Millisecond, false, start.Add(latencyBucket))
+	got, err := database.GetLatencyStats(start.Add(-time.Hour))
+	if err != nil || len(got) != 1 {
+		t.Fatalf("Expected one stored bucket, got %+v", got)
+	}
+	if b := got[0]; !b.Time.Equal(start) || b.Host != "receiver" || b.Samples != 3 || b.Lost != 1 || b.AvgMs != 20 {
+		t.Errorf("Unexpected bucket %+v", b)
+	}
+	if cur := m.Current(); cur == nil || cur.Samples != 4 || cur.Lost != 1 {
+		t.Errorf("The live stats cover every check, got %+v", cur)
+	}
+}
@@ -0,0 +1,47 @@
+package app
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listen opens addr: host:port, :port or unix:/path/to.sock. A socket file
+// left behind by an earlier run is replaced; SOCKET_MODE sets the file mode
+// (default 0660) so a reverse proxy in the same group can connect.
+func listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, "unix:")
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		_ = os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	mode := os.FileMode(0660)
+	if val, err := strconv.ParseUint(os.Getenv("SOCKET_MODE"), 8, 32); err == nil {
+		mode = os.FileMode(val)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// listenPort returns the TCP port of addr, 0 for Unix sockets
+func listenPort(addr string) int {
+	if strings.HasPrefix(addr, "unix:") {
+		return 0
+	}
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(port)
+	return n
+}
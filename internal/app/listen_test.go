@@ -0,0 +1,50 @@
+package app
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListen_UnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schnorarr.sock")
+	for i := 0; i < 2; i++ {
+		// The second round replaces the socket file left behind by the first
+		ln, err := listen("unix:" + path)
+		if err != nil {
+			t.Fatalf("Round %d: %v", i, err)
+		}
+		// A crash leaves the socket file behind
+		ln.(*net.UnixListener).SetUnlinkOnClose(false)
+		srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+		go func() { _ = srv.Serve(ln) }()
+
+		client := &http.Client{Transport: &http.Transport{
+			Dial: func(string, string) (net.Conn, error) { return net.Dial("unix", path) },
+		}}
+		resp, err := client.Get("http://schnorarr/health")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		_ = srv.Close()
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0660 {
+		t.Errorf("Socket should be left with mode 0660, got %v, %v", info, err)
+	}
+}
+
+func TestListenPort(t *testing.T) {
+	for addr, want := range map[string]int{
+		":8080":            8080,
+		"127.0.0.1:9443":   9443,
+		"[::1]:8090":       8090,
+		"unix:/run/s.sock": 0,
+	} {
+		if got := listenPort(addr); got != want {
+			t.Errorf("listenPort(%q) = %d, want %d", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/monitor/health"
+	"schnorarr/internal/mqtt"
+	"schnorarr/internal/sync"
+)
+
+// mqttPublisher mirrors engine states, transfer progress and health to an
+// MQTT broker, with Home Assistant discovery so the entities appear by themselves
+type mqttPublisher struct {
+	opts            mqtt.Options
+	prefix          string // Topic prefix, "schnorarr" by default
+	discoveryPrefix string // "" disables Home Assistant discovery
+	engines         []*sync.Engine
+	health          *health.State
+
+	client         *mqtt.Client
+	lastSync       map[string]time.Time // Last sync seen per engine, to detect finished syncs
+	receiverOnline *bool
+}
+
+// mqttEngineState is the payload of <prefix>/engine/<id>/state
+type mqttEngineState struct {
+	ID       string   `json:"id"`
+	Alias    string   `json:"alias"`
+	State    string   `json:"state"` // sync.State* values
+	File     string   `json:"file"`
+	Percent  float64  `json:"percent"`
+	Speed    int64    `json:"speed"`       // Bytes per second
+	ETA      int64    `json:"eta_seconds"` // Of the current file
+	Pending  int      `json:"pending_approval"`
+	Today    int64    `json:"today_bytes"`
+	Total    int64    `json:"total_bytes"`
+	LastSync string   `json:"last_sync"`
+	Backlog  int      `json:"backlog"`
+	Groups   []string `json:"groups,omitempty"`
+}
+
+// startMQTTPublisher publishes to MQTT_BROKER every MQTT_INTERVAL seconds (default 10)
+func startMQTTPublisher(engines []*sync.Engine, healthState *health.State) {
+	broker := os.Getenv("MQTT_BROKER")
+	if broker == "" {
+		return
+	}
+	p := newMQTTPublisher(broker, engines, healthState)
+	interval := 10 * time.Second
+	if val, err := strconv.Atoi(os.Getenv("MQTT_INTERVAL")); err == nil && val > 0 {
+		interval = time.Duration(val) * time.Second
+	}
+	// Every publish keeps the connection alive; the broker drops a client
+	// silent for 1.5 keep-alives and publishes the offline will
+	p.opts.KeepAlive = max(60*time.Second, min(2*interval, math.MaxUint16*time.Second))
+	go p.run(interval)
+}
+
+func newMQTTPublisher(broker string, engines []*sync.Engine, healthState *health.State) *mqttPublisher {
+	prefix := strings.TrimSuffix(os.Getenv("MQTT_TOPIC_PREFIX"), "/")
+	if prefix == "" {
+		prefix = "schnorarr"
+	}
+	discoveryPrefix := "homeassistant"
+	if env, ok := os.LookupEnv("MQTT_DISCOVERY_PREFIX"); ok {
+		discoveryPrefix = strings.TrimSuffix(env, "/")
+	}
+	clientID := os.Getenv("MQTT_CLIENT_ID")
+	if clientID == "" {
+		host, _ := os.Hostname()
+		clientID = "schnorarr-" + host
+	}
+	return &mqttPublisher{
+		opts: mqtt.Options{
+			Broker: broker, ClientID: clientID, Username: os.Getenv("MQTT_USER"), Password: os.Getenv("MQTT_PASSWORD"),
+			WillTopic: prefix + "/status", WillPayload: []byte("offline"), WillRetain: true,
+		},
+		prefix: prefix, discoveryPrefix: discoveryPrefix, engines: engines, health: healthState,
+		lastSync: make(map[string]time.Time),
+	}
+}
+
+func (p *mqttPublisher) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := p.publishOnce(); err != nil {
+			logger.Warn("MQTT publish failed, reconnecting next time", "broker", p.opts.Broker, "error", err)
+			if p.client != nil {
+				_ = p.client.Close()
+				p.client = nil
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// publishOnce connects when needed and publishes the current state
+func (p *mqttPublisher) publishOnce() error {
+	if p.client == nil {
+		client, err := mqtt.Connect(p.opts)
+		if err != nil {
+			return err
+		}
+		p.client = client
+		logger.Info("Connected to MQTT broker", "broker", p.opts.Broker, "prefix", p.prefix)
+		if err := p.client.Publish(p.prefix+"/status", []byte("online"), true); err != nil {
+			return err
+		}
+		if p.discoveryPrefix != "" {
+			if err := p.publishDiscovery(); err != nil {
+				return err
+			}
+		}
+	}
+
+	healthy, lastErr := p.health.GetStatus()
+	receiverOnline, _, _, _ := p.health.GetReceiverStatus()
+	if err := p.publishJSON(p.prefix+"/health", map[string]interface{}{
+		"healthy": healthy, "error": lastErr, "receiver_online": receiverOnline, "receiver_host": p.health.GetReceiverHost(),
+	}, true); err != nil {
+		return err
+	}
+	if p.receiverOnline != nil && *p.receiverOnline != receiverOnline {
+		event := "receiver_offline"
+		if receiverOnline {
+			event = "receiver_online"
+		}
+		if err := p.publishJSON(p.prefix+"/event", map[string]string{"event": event, "host": p.health.GetReceiverHost(), "time": time.Now().Format(time.RFC3339)}, false); err != nil {
+			return err
+		}
+	}
+	p.receiverOnline = &receiverOnline
+
+	for _, e := range p.engines {
+		st := engineMQTTState(e)
+		if err := p.publishJSON(p.engineTopic(st.ID, "state"), st, true); err != nil {
+			return err
+		}
+		last := e.GetLastSyncTime()
+		if prev, seen := p.lastSync[st.ID]; seen && last.After(prev) {
+			if err := p.publishJSON(p.engineTopic(st.ID, "event"), map[string]string{"event": "sync_finished", "engine": st.ID, "time": last.Format(time.RFC3339)}, false); err != nil {
+				return err
+			}
+		}
+		p.lastSync[st.ID] = last
+	}
+	return nil
+}
+
+func engineMQTTState(e *sync.Engine) mqttEngineState {
+	cfg := e.GetConfig()
+	file, done, total, speed, _, _ := e.GetTransferStatsExtended()
+	stats := database.GetEngineTrafficStats(cfg.ID)
+	backlog := e.GetBacklogStats()
+	st := mqttEngineState{
+		ID: cfg.ID, Alias: e.GetAlias(), State: e.State(), File: file, Speed: speed, Pending: len(e.GetPendingDeletions()),
+		Today: stats.Today, Total: stats.Total, Backlog: backlog.Paths, Groups: cfg.Groups,
+	}
+	if last := e.GetLastSyncTime(); !last.IsZero() {
+		st.LastSync = last.Format(time.RFC3339)
+	}
+	if total > 0 {
+		st.Percent = float64(done) / float64(total) * 100
+		if speed > 0 && total > done {
+			st.ETA = (total - done) / speed
+		}
+	}
+	return st
+}
+
+func (p *mqttPublisher) engineTopic(id, leaf string) string {
+	return fmt.Sprintf("%s/engine/%s/%s", p.prefix, id, leaf)
+}
+
+func (p *mqttPublisher) publishJSON(topic string, v interface{}, retain bool) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return p.client.Publish(topic, data, retain)
+}
+
+// publishDiscovery announces the entities to Home Assistant
+func (p *mqttPublisher) publishDiscovery() error {
+	// Discovery topics only allow letters, digits, _ and -
+	nodeID := strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+			return r
+		}
+		return '_'
+	}, p.opts.ClientID)
+	device := map[string]interface{}{"identifiers": []string{nodeID}, "name": "schnorarr", "manufacturer": "schnorarr"}
+	announce := func(component, objectID string, cfg map[string]interface{}) error {
+		cfg["unique_id"] = nodeID + "_" + objectID
+		cfg["object_id"] = "schnorarr_" + objectID
+		cfg["availability_topic"] = p.prefix + "/status"
+		cfg["device"] = device
+		return p.publishJSON(fmt.Sprintf("%s/%s/%s/%s/config", p.discoveryPrefix, component, nodeID, objectID), cfg, true)
+	}
+	if err := announce("binary_sensor", "receiver", map[string]interface{}{
+		"name": "Receiver", "device_class": "connectivity", "state_topic": p.prefix + "/health",
+		"value_template": "{{ 'ON' if value_json.receiver_online else 'OFF' }}",
+	}); err != nil {
+		return err
+	}
+	if err := announce("binary_sensor", "problem", map[string]interface{}{
+		"name": "Sync problem", "device_class": "problem", "state_topic": p.prefix + "/health",
+		"value_template": "{{ 'OFF' if value_json.healthy else 'ON' }}", "json_attributes_topic": p.prefix + "/health",
+	}); err != nil {
+		return err
+	}
+	for _, e := range p.engines {
+		id := e.GetConfig().ID
+		name := e.GetAlias()
+		topic := p.engineTopic(id, "state")
+		sensors := []struct {
+			objectID string
+			cfg      map[string]interface{}
+		}{
+			{"state", map[string]interface{}{"name": name + " state", "value_template": "{{ value_json.state }}", "json_attributes_topic": topic}},
+			{"progress", map[string]interface{}{"name": name + " progress", "value_template": "{{ value_json.percent | round(1) }}", "unit_of_measurement": "%"}},
+			{"speed", map[string]interface{}{"name": name + " speed", "value_template": "{{ value_json.speed }}", "unit_of_measurement": "B/s", "device_class": "data_rate"}},
+			{"today", map[string]interface{}{"name": name + " traffic today", "value_template": "{{ value_json.today_bytes }}", "unit_of_measurement": "B", "device_class": "data_size", "state_class": "total_increasing"}},
+		}
+		for _, s := range sensors {
+			s.cfg["state_topic"] = topic
+			if err := announce("sensor", "engine_"+id+"_"+s.objectID, s.cfg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	stdsync "sync"
+	"testing"
+	"time"
+
+	"schnorarr/internal/monitor/health"
+	"schnorarr/internal/sync"
+)
+
+// fakeBroker accepts one MQTT connection and records the published messages by topic
+type fakeBroker struct {
+	ln       net.Listener
+	mu       stdsync.Mutex
+	messages map[string][]string
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeBroker{ln: ln, messages: make(map[string][]string)}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		for {
+			var hdr [2]byte
+			if _, err := io.ReadFull(conn, hdr[:1]); err != nil {
+				return
+			}
+			length, mult := 0, 1
+			for {
+				if _, err := io.ReadFull(conn, hdr[1:]); err != nil {
+					return
+				}
+				length += int(hdr[1]&0x7F) * mult
+				if hdr[1]&0x80 == 0 {
+					break
+				}
+				mult *= 128
+			}
+			body := make([]byte, length)
+			if _, err := io.ReadFull(conn, body); err != nil {
+				return
+			}
+			switch hdr[0] & 0xF0 {
+			case 0x10:
+				_, _ = conn.Write([]byte{0x20, 2, 0, 0})
+			case 0x30:
+				n := int(binary.BigEndian.Uint16(body))
+				b.mu.Lock()
+				b.messages[string(body[2:2+n])] = append(b.messages[string(body[2:2+n])], string(body[2+n:]))
+				b.mu.Unlock()
+			}
+		}
+	}()
+	return b
+}
+
+// waitFor returns the messages of topic once count have arrived
+func (b *fakeBroker) waitFor(t *testing.T, topic string, count int) []string {
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		b.mu.Lock()
+		msgs := b.messages[topic]
+		b.mu.Unlock()
+		if len(msgs) >= count {
+			return msgs
+		}
+	}
+	t.Fatalf("Expected %d messages on %s", count, topic)
+	return nil
+}
+
+func TestMQTTPublisher(t *testing.T) {
+	broker := newFakeBroker(t)
+	defer func() { _ = broker.ln.Close() }()
+	t.Setenv("MQTT_CLIENT_ID", "schnorarr-nas.local")
+
+	engine := sync.NewEngine(sync.SyncConfig{ID: "1", SourceDir: t.TempDir(), TargetDir: t.TempDir()})
+	p := newMQTTPublisher(broker.ln.Addr().String(), []*sync.Engine{engine}, health.New())
+	if err := p.publishOnce(); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.RunSync(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.publishOnce(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = p.client.Close() }()
+
+	if status := broker.waitFor(t, "schnorarr/status", 1); status[0] != "online" {
+		t.Errorf("Expected online status, got %v", status)
+	}
+	config := broker.waitFor(t, "homeassistant/sensor/schnorarr-nas_local/engine_1_progress/config", 1)
+	if !strings.Contains(config[0], `"state_topic":"schnorarr/engine/1/state"`) {
+		t.Errorf("Unexpected discovery config %s", config[0])
+	}
+	states := broker.waitFor(t, "schnorarr/engine/1/state", 2)
+	var st mqttEngineState
+	if err := json.Unmarshal([]byte(states[1]), &st); err != nil || st.State != "idle" || st.LastSync == "" {
+		t.Errorf("Unexpected state %s (%v)", states[1], err)
+	}
+	if events := broker.waitFor(t, "schnorarr/engine/1/event", 1); !strings.Contains(events[0], "sync_finished") {
+		t.Errorf("Expected a sync_finished event, got %v", events)
+	}
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	stdsync "sync"
+
+	"schnorarr/internal/bandwidth"
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/monitor/system"
+	"schnorarr/internal/sync"
+)
+
+// pacingCalmChecks is how many health checks in a row the receiver must be
+// calm before transfers run at full speed again (one minute at 15s)
+const pacingCalmChecks = 4
+
+// receiverPacer slows the engines down while the receiver is busy with local
+// work, e.g. someone streaming from it (RECEIVER_PACING). Busy means IO
+// pressure or load per CPU at or above their thresholds; transfers are then
+// capped to RECEIVER_PACING_LIMIT and RECEIVER_PACING_STREAMS until the
+// receiver has been calm for pacingCalmChecks health checks.
+type receiverPacer struct {
+	ioThreshold   float64 // PSI "some avg10" percent
+	loadThreshold float64 // Load average per CPU
+	limit         bandwidth.Rate
+	streams       int
+	engines       func() []*sync.Engine
+
+	mu     stdsync.Mutex
+	reason string // Why the receiver counts as busy, "" when it does not
+	calm   int    // Calm checks since the receiver was busy
+}
+
+// newReceiverPacer reads RECEIVER_PACING, RECEIVER_PACING_IO,
+// RECEIVER_PACING_LOAD, RECEIVER_PACING_LIMIT and RECEIVER_PACING_STREAMS;
+// nil unless pacing is enabled
+func newReceiverPacer(engines func() []*sync.Engine) *receiverPacer {
+	if os.Getenv("RECEIVER_PACING") != "true" {
+		return nil
+	}
+	p := &receiverPacer{ioThreshold: 40, loadThreshold: 2, limit: bandwidth.FromMbps(20), streams: 1, engines: engines}
+	if env := os.Getenv("RECEIVER_PACING_IO"); env != "" {
+		if val, err := strconv.ParseFloat(env, 64); err == nil && val > 0 && val <= 100 {
+			p.ioThreshold = val
+		} else {
+			logger.Warn("Invalid RECEIVER_PACING_IO, using the default", "value", env, "default", p.ioThreshold)
+		}
+	}
+	if env := os.Getenv("RECEIVER_PACING_LOAD"); env != "" {
+		if val, err := strconv.ParseFloat(env, 64); err == nil && val > 0 {
+			p.loadThreshold = val
+		} else {
+			logger.Warn("Invalid RECEIVER_PACING_LOAD, using the default", "value", env, "default", p.loadThreshold)
+		}
+	}
+	if env := os.Getenv("RECEIVER_PACING_LIMIT"); env != "" {
+		if limit, err := bandwidth.Parse(env); err == nil {
+			p.limit = limit
+		} else {
+			logger.Warn("Invalid RECEIVER_PACING_LIMIT, using the default", "error", err, "default", p.limit.String())
+		}
+	}
+	if env := os.Getenv("RECEIVER_PACING_STREAMS"); env != "" {
+		if val, err := strconv.Atoi(env); err == nil && val >= 0 {
+			p.streams = val
+		} else {
+			logger.Warn("Invalid RECEIVER_PACING_STREAMS, using the default", "value", env, "default", p.streams)
+		}
+	}
+	logger.Info("Receiver pacing enabled", "io_pressure", p.ioThreshold, "load_per_cpu", p.loadThreshold, "limit", p.limit.String(), "streams", p.streams)
+	return p
+}
+
+// busy returns why a load counts as busy, "" when it does not
+func (p *receiverPacer) busy(load *system.Load) string {
+	if load.IOPressure >= p.ioThreshold {
+		return fmt.Sprintf("IO pressure %.0f%%", load.IOPressure)
+	}
+	if load.NumCPU > 0 && load.Load1/float64(load.NumCPU) >= p.loadThreshold {
+		return fmt.Sprintf("load %.2f on %d CPUs", load.Load1, load.NumCPU)
+	}
+	return ""
+}
+
+// observe paces the engines by the load of a health check; nil (an older
+// receiver or an unreachable one) counts as calm. It returns why transfers
+// are paced, "" when they run at full speed.
+func (p *receiverPacer) observe(load *system.Load) string {
+	if p == nil {
+		return ""
+	}
+	reason := ""
+	if load != nil {
+		reason = p.busy(load)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case reason != "":
+		if p.reason == "" {
+			msg := fmt.Sprintf("Receiver busy (%s), transfers capped to %s", reason, p.limit)
+			logger.Info(msg)
+			_ = database.LogSystemEvent("system", "Receiver Busy", msg)
+			p.apply(int64(p.limit), p.streams)
+		}
+		p.reason, p.calm = reason, 0
+	case p.reason != "":
+		p.calm++
+		if p.calm < pacingCalmChecks {
+			break
+		}
+		logger.Info("Receiver calm again, transfers at full speed", "was", p.reason)
+		_ = database.LogSystemEvent("system", "Receiver Calm", "Transfers at full speed again, was "+p.reason)
+		p.apply(0, 0)
+		p.reason, p.calm = "", 0
+	}
+	return p.reason
+}
+
+func (p *receiverPacer) apply(limit int64, streams int) {
+	for _, e := range p.engines() {
+		e.SetPacing(limit, streams)
+	}
+}
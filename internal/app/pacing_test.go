@@ -0,0 +1,48 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+
+	"schnorarr/internal/bandwidth"
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/monitor/system"
+	"schnorarr/internal/sync"
+)
+
+func TestReceiverPacer_PacesWhileBusy(t *testing.T) {
+	database.DBPath = filepath.Join(t.TempDir(), "pacing.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+
+	e := sync.NewEngine(sync.SyncConfig{ID: "1", SourceDir: t.TempDir(), TargetDir: t.TempDir(), BandwidthLimit: int64(bandwidth.FromMbps(100))})
+	p := &receiverPacer{ioThreshold: 40, loadThreshold: 2, limit: bandwidth.FromMbps(20), streams: 1, engines: func() []*sync.Engine { return []*sync.Engine{e} }}
+
+	calm := &system.Load{Load1: 1, NumCPU: 4, IOPressure: 5}
+	if reason := p.observe(calm); reason != "" || e.EffectiveBandwidthLimit() != int64(bandwidth.FromMbps(100)) {
+		t.Fatalf("A calm receiver should not pace, got %q at %d", reason, e.EffectiveBandwidthLimit())
+	}
+	if reason := p.observe(&system.Load{Load1: 1, NumCPU: 4, IOPressure: 75}); reason == "" || e.EffectiveBandwidthLimit() != int64(bandwidth.FromMbps(20)) {
+		t.Fatalf("IO pressure should pace, got %q at %d", reason, e.EffectiveBandwidthLimit())
+	}
+	if reason := p.observe(&system.Load{Load1: 9, NumCPU: 4, IOPressure: -1}); reason == "" {
+		t.Error("Load above the threshold per CPU should keep pacing")
+	}
+
+	// Full speed only after the receiver stayed calm for a while
+	for i := 1; i < pacingCalmChecks; i++ {
+		if p.observe(calm) == "" {
+			t.Fatalf("Pacing ended after %d calm checks", i)
+		}
+	}
+	if p.observe(nil) != "" || e.EffectiveBandwidthLimit() != int64(bandwidth.FromMbps(100)) {
+		t.Errorf("Pacing should end after %d calm checks, limit %d", pacingCalmChecks, e.EffectiveBandwidthLimit())
+	}
+
+	var nilPacer *receiverPacer
+	if nilPacer.observe(&system.Load{IOPressure: 100}) != "" {
+		t.Error("Without RECEIVER_PACING nothing is paced")
+	}
+}
@@ -0,0 +1,230 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	stdsync "sync"
+	"time"
+
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/monitor/notification"
+	"schnorarr/internal/sync"
+)
+
+// quotaCheckInterval is how often usage is compared with the quotas
+var quotaCheckInterval = 30 * time.Second
+
+// quotaGlobal is the key of the quota covering all engines
+const quotaGlobal = ""
+
+// trafficQuota pauses engines whose transfers exhausted their quota for the
+// current period (QUOTA_GB globally, SYNC_N_QUOTA_GB per engine) or the
+// monthly cost ceiling (COST_CEILING at COST_PER_GB) and resumes them when
+// the period rolls over or the limit is raised.
+type trafficQuota struct {
+	period      string // "month" or "week"
+	resetDay    int    // Day of the month the period starts on
+	limits      map[string]int64
+	costCeiling float64 // Monthly cost ceiling, 0 = none
+	engines     []*sync.Engine
+	notify      func(msg, level string)
+	usage       func(engineID string, since time.Time) int64
+
+	mu     stdsync.Mutex
+	start  time.Time       // Start of the period the pauses belong to
+	paused map[string]bool // Engines paused by the quota
+	status []quotaStatus
+	cost   *quotaStatus // Cost ceiling usage, nil without COST_CEILING
+}
+
+// quotaStatus is the usage of one quota, shown on the dashboard
+type quotaStatus struct {
+	EngineID  string  `json:"engine_id"` // Empty for the global quota
+	Used      int64   `json:"used"`
+	Limit     int64   `json:"limit"`
+	Percent   float64 `json:"percent"`
+	Exhausted bool    `json:"exhausted"`
+	Resets    string  `json:"resets"` // RFC3339 start of the next period
+	Label     string  `json:"label"`  // "used / limit"
+}
+
+// newTrafficQuota reads QUOTA_GB, SYNC_N_QUOTA_GB, QUOTA_PERIOD,
+// QUOTA_RESET_DAY and COST_CEILING; nil when no limit is configured
+func newTrafficQuota(engines []*sync.Engine, notify func(msg, level string)) *trafficQuota {
+	limits := make(map[string]int64)
+	if gb := parseQuotaGB(os.Getenv("QUOTA_GB")); gb > 0 {
+		limits[quotaGlobal] = gb
+	}
+	for _, e := range engines {
+		id := e.GetConfig().ID
+		if gb := parseQuotaGB(engineEnv(engineEnvKey(id), "QUOTA_GB")); gb > 0 {
+			limits[id] = gb
+		}
+	}
+	ceiling, _ := strconv.ParseFloat(os.Getenv("COST_CEILING"), 64)
+	if ceiling > 0 && database.CostPerGB() <= 0 {
+		logger.Warn("COST_CEILING ignored, COST_PER_GB is not set")
+		ceiling = 0
+	}
+	if len(limits) == 0 && ceiling <= 0 {
+		return nil
+	}
+	q := &trafficQuota{
+		period: "month", resetDay: 1, limits: limits, costCeiling: ceiling, engines: engines, notify: notify,
+		usage: database.GetTrafficSince, paused: make(map[string]bool),
+	}
+	if strings.EqualFold(os.Getenv("QUOTA_PERIOD"), "week") {
+		q.period = "week"
+	}
+	if day, err := strconv.Atoi(os.Getenv("QUOTA_RESET_DAY")); err == nil && day >= 1 && day <= 28 {
+		q.resetDay = day
+	}
+	logger.Info("Traffic quotas enabled", "period", q.period, "quotas", len(limits), "cost_ceiling", ceiling)
+	return q
+}
+
+// parseQuotaGB reads a quota in GB (decimal, 1 TB = 1000) as bytes
+func parseQuotaGB(value string) int64 {
+	val, err := strconv.ParseFloat(value, 64)
+	if err != nil || val <= 0 {
+		return 0
+	}
+	return int64(val * 1e9)
+}
+
+// periodBounds returns the start of the period ("month" or "week") containing
+// now and the start of the next one
+func (q *trafficQuota) periodBounds(period string, now time.Time) (time.Time, time.Time) {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if period == "week" {
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7)) // Weeks start on Monday
+		return start, start.AddDate(0, 0, 7)
+	}
+	start := time.Date(now.Year(), now.Month(), q.resetDay, 0, 0, 0, 0, now.Location())
+	if start.After(now) {
+		start = start.AddDate(0, -1, 0)
+	}
+	return start, start.AddDate(0, 1, 0)
+}
+
+// check compares usage with every quota, pausing engines over their quota
+// and resuming the ones it paused once they are below it again
+func (q *trafficQuota) check(now time.Time) {
+	start, next := q.periodBounds(q.period, now)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !start.Equal(q.start) {
+		if !q.start.IsZero() && len(q.paused) > 0 {
+			q.notify(notification.Render(notification.EventQuotaReset, notification.Vars{Message: strconv.Itoa(len(q.paused))}), "INFO")
+		}
+		q.start = start
+	}
+
+	var status []quotaStatus
+	exhausted := make(map[string]string) // Engine -> reason
+	if limit, ok := q.limits[quotaGlobal]; ok {
+		s := q.observe(quotaGlobal, limit, start, next)
+		status = append(status, s)
+		if s.Exhausted {
+			for _, e := range q.engines {
+				exhausted[e.GetConfig().ID] = fmt.Sprintf("global traffic quota exhausted (%s)", s.Label)
+			}
+		}
+	}
+	for _, e := range q.engines {
+		id := e.GetConfig().ID
+		limit, ok := q.limits[id]
+		if !ok {
+			continue
+		}
+		s := q.observe(id, limit, start, next)
+		status = append(status, s)
+		if s.Exhausted {
+			exhausted[id] = fmt.Sprintf("traffic quota of engine %s exhausted (%s)", id, s.Label)
+		}
+	}
+	q.status = status
+
+	if q.costCeiling > 0 {
+		// The ceiling always covers a month, whatever QUOTA_PERIOD says
+		monthStart, monthNext := q.periodBounds("month", now)
+		s := q.observe(quotaGlobal, int64(q.costCeiling/database.CostPerGB()*1e9), monthStart, monthNext)
+		s.Label = database.FormatCost(database.EstimateCost(s.Used)) + " / " + database.FormatCost(q.costCeiling)
+		q.cost = &s
+		if s.Exhausted {
+			for _, e := range q.engines {
+				if _, over := exhausted[e.GetConfig().ID]; !over {
+					exhausted[e.GetConfig().ID] = fmt.Sprintf("monthly cost ceiling reached (%s)", s.Label)
+				}
+			}
+			next = monthNext
+		}
+	}
+
+	for _, e := range q.engines {
+		id := e.GetConfig().ID
+		reason, over := exhausted[id]
+		switch {
+		case over && !q.paused[id]:
+			if e.IsPaused() {
+				continue // Paused by the user, leave it to them
+			}
+			e.Pause()
+			q.paused[id] = true
+			msg := fmt.Sprintf("Engine %s paused: %s, resumes %s", id, reason, next.Format("2006-01-02"))
+			logger.Warn(msg)
+			_ = database.LogSystemEvent("system", "Quota Exhausted", msg)
+			q.notify(notification.Render(notification.EventQuotaExhausted, notification.Vars{Engine: id, Error: reason, Until: next.Format("2006-01-02")}), "WARNING")
+		case !over && q.paused[id]:
+			delete(q.paused, id)
+			e.Resume()
+			_ = database.LogSystemEvent("system", "Quota Resumed", fmt.Sprintf("Engine %s resumed, traffic quota available again", id))
+		}
+	}
+}
+
+func (q *trafficQuota) observe(engineID string, limit int64, start, next time.Time) quotaStatus {
+	used := q.usage(engineID, start)
+	return quotaStatus{
+		EngineID: engineID, Used: used, Limit: limit, Percent: float64(used) / float64(limit) * 100,
+		Exhausted: used >= limit, Resets: next.Format(time.RFC3339),
+		Label: database.FormatBytes(used) + " / " + database.FormatBytes(limit),
+	}
+}
+
+// Status returns the usage of every quota as of the last check
+func (q *trafficQuota) Status() []quotaStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]quotaStatus(nil), q.status...)
+}
+
+// CostStatus returns the cost ceiling usage as of the last check, nil without COST_CEILING
+func (q *trafficQuota) CostStatus() *quotaStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.cost == nil {
+		return nil
+	}
+	s := *q.cost
+	return &s
+}
+
+// PausedByQuota reports whether the quota paused an engine
+func (q *trafficQuota) PausedByQuota(engineID string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.paused[engineID]
+}
+
+func (q *trafficQuota) run() {
+	q.check(time.Now())
+	ticker := time.NewTicker(quotaCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		q.check(time.Now())
+	}
+}
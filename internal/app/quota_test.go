@@ -0,0 +1,142 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/sync"
+)
+
+func TestTrafficQuota_PeriodBounds(t *testing.T) {
+	now := time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC) // Sunday
+	q := &trafficQuota{period: "month", resetDay: 1}
+	if start, next := q.periodBounds(q.period, now); !start.Equal(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)) || !next.Equal(time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected month bounds %v - %v", start, next)
+	}
+	q.resetDay = 20
+	if start, _ := q.periodBounds(q.period, now); !start.Equal(time.Date(2026, 9, 20, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Before the reset day the period started last month, got %v", start)
+	}
+	q.period = "week"
+	if start, next := q.periodBounds(q.period, now); !start.Equal(time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)) || !next.Equal(time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected week bounds %v - %v", start, next)
+	}
+}
+
+func TestTrafficQuota_PauseAndResume(t *testing.T) {
+	database.DBPath = filepath.Join(t.TempDir(), "quota.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+
+	e1 := sync.NewEngine(sync.SyncConfig{ID: "1", SourceDir: t.TempDir(), TargetDir: t.TempDir()})
+	e2 := sync.NewEngine(sync.SyncConfig{ID: "2", SourceDir: t.TempDir(), TargetDir: t.TempDir()})
+	used := map[string]int64{"1": 600, "2": 100}
+	var notes []string
+	q := &trafficQuota{
+		period: "month", resetDay: 1, limits: map[string]int64{"1": 500, quotaGlobal: 1000},
+		engines: []*sync.Engine{e1, e2}, paused: make(map[string]bool),
+		notify: func(msg, level string) { notes = append(notes, level+": "+msg) },
+		usage: func(id string, since time.Time) int64 {
+			if id == quotaGlobal {
+				return used["1"] + used["2"]
+			}
+			return used[id]
+		},
+	}
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	q.check(now)
+	if !e1.IsPaused() || e2.IsPaused() || !q.PausedByQuota("1") {
+		t.Fatalf("Only engine 1 should be paused (1: %v, 2: %v)", e1.IsPaused(), e2.IsPaused())
+	}
+	if len(notes) != 1 {
+		t.Errorf("Expected one notification, got %v", notes)
+	}
+
+	used["2"] = 500 // Global quota exhausted too
+	q.check(now)
+	if !e2.IsPaused() {
+		t.Error("Exhausted global quota should pause every engine")
+	}
+	status := q.Status()
+	if len(status) != 2 || status[0].EngineID != quotaGlobal || !status[0].Exhausted {
+		t.Errorf("Unexpected status %+v", status)
+	}
+
+	// New month: usage starts over
+	used["1"], used["2"] = 0, 0
+	q.check(time.Date(2026, 11, 1, 0, 1, 0, 0, time.UTC))
+	if e1.IsPaused() || e2.IsPaused() || q.PausedByQuota("1") {
+		t.Error("Engines should resume after the rollover")
+	}
+	waitIdle(e1)
+	waitIdle(e2)
+}
+
+func TestTrafficQuota_LeavesUserPauseAlone(t *testing.T) {
+	e := sync.NewEngine(sync.SyncConfig{ID: "1", SourceDir: t.TempDir(), TargetDir: t.TempDir()})
+	e.Pause()
+	used := int64(10)
+	q := &trafficQuota{
+		period: "week", limits: map[string]int64{"1": 5}, engines: []*sync.Engine{e}, paused: make(map[string]bool),
+		notify: func(msg, level string) {}, usage: func(string, time.Time) int64 { return used },
+	}
+	now := time.Now()
+	q.check(now)
+	used = 0
+	q.check(now)
+	if !e.IsPaused() {
+		t.Error("An engine paused by the user must stay paused")
+	}
+}
+
+// waitIdle waits for the sync a resumed or started engine runs in the background
+func waitIdle(e *sync.Engine) {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if !e.IsBusy() && !e.IsScanning() {
+			return
+		}
+	}
+}
+
+func TestTrafficQuota_CostCeiling(t *testing.T) {
+	database.DBPath = filepath.Join(t.TempDir(), "quota.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+	t.Setenv("COST_PER_GB", "0.5")
+
+	e := sync.NewEngine(sync.SyncConfig{ID: "1", SourceDir: t.TempDir(), TargetDir: t.TempDir()})
+	used := int64(30e9) // €15
+	q := &trafficQuota{
+		period: "week", resetDay: 1, limits: map[string]int64{}, costCeiling: 20, engines: []*sync.Engine{e},
+		paused: make(map[string]bool), notify: func(msg, level string) {},
+		usage: func(string, time.Time) int64 { return used },
+	}
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	q.check(now)
+	if e.IsPaused() {
+		t.Fatal("Engine paused below the cost ceiling")
+	}
+	if c := q.CostStatus(); c == nil || c.Label != "€15.00 / €20.00" {
+		t.Errorf("Unexpected cost status %+v", c)
+	}
+
+	used = 40e9 // €20
+	q.check(now)
+	if !e.IsPaused() || !q.PausedByQuota("1") {
+		t.Error("Reaching the cost ceiling should pause the engine")
+	}
+
+	used = 0
+	q.check(time.Date(2026, 11, 1, 0, 1, 0, 0, time.UTC))
+	if e.IsPaused() {
+		t.Error("Engine should resume in the next month")
+	}
+	waitIdle(e)
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"schnorarr/internal/agent"
+	"schnorarr/internal/monitor/scheduler"
+)
+
+// readOnlyFlag is the file the rsync daemon's pre-xfer script checks; it
+// holds the reason while the receiver is read-only
+func readOnlyFlag() string {
+	if path := os.Getenv("READ_ONLY_FLAG"); path != "" {
+		return path
+	}
+	return "/config/read-only"
+}
+
+// checkWritable returns agent.ErrReadOnly with the reason while the receiver
+// is write-protected
+func checkWritable() error {
+	if st := scheduler.ReadOnlyAt(time.Now()); st.ReadOnly {
+		return fmt.Errorf("%w: %s", agent.ErrReadOnly, st.Reason)
+	}
+	return nil
+}
+
+// startReadOnlyWatch keeps the flag file in line with the read-only switch and schedule
+func startReadOnlyWatch() {
+	applyReadOnly()
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		applyReadOnly()
+	}
+}
+
+// applyReadOnly writes or removes the flag file and logs transitions
+func applyReadOnly() {
+	path := readOnlyFlag()
+	st := scheduler.ReadOnlyAt(time.Now())
+	current, err := os.ReadFile(path)
+	exists := err == nil
+	if !st.ReadOnly {
+		if exists {
+			if err := os.Remove(path); err != nil {
+				logger.Error("Failed to remove read-only flag", "path", path, "error", err)
+				return
+			}
+			logger.Info("Receiver writable again")
+		}
+		return
+	}
+	if exists && string(current) == st.Reason+"\n" {
+		return
+	}
+	if err := os.WriteFile(path, []byte(st.Reason+"\n"), 0644); err != nil {
+		logger.Error("Failed to write read-only flag", "path", path, "error", err)
+		return
+	}
+	if !exists {
+		logger.Info("Receiver read-only, refusing transfers and deletes", "reason", st.Reason)
+	}
+}
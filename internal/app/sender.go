@@ -6,69 +6,249 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
 
+	"schnorarr/internal/agent"
+	"schnorarr/internal/bandwidth"
 	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/monitor/errclass"
 	"schnorarr/internal/monitor/health"
+	"schnorarr/internal/monitor/i18n"
 	"schnorarr/internal/monitor/notification"
+	"schnorarr/internal/monitor/smart"
+	"schnorarr/internal/monitor/system"
 	"schnorarr/internal/monitor/websocket"
 	"schnorarr/internal/sync"
+	"schnorarr/internal/sync/pool"
 )
 
 func (a *App) startSenderServices() {
 	// Shared latency variable
 	var latency int64
-	engines := startSyncEngines(a.WSHub, a.HealthState, a.Notifier)
+	waker := newReceiverWaker(func() string {
+		if host := a.HealthState.GetReceiverHost(); host != "" {
+			return host
+		}
+		if hosts := sync.DestHosts(); len(hosts) > 0 {
+			return hosts[0]
+		}
+		return ""
+	})
+	logConfigReport(validateConfig())
+	engines := startSyncEngines(a.WSHub, a.HealthState, a.Notifier, waker)
 
 	a.engineMu.Lock()
 	a.SyncEngines = engines
 	a.engineMu.Unlock()
 
-	go startSyncStatusBroadcaster(a.WSHub, engines, a.HealthState, &latency)
-	go checkReceiverHealth(a.HealthState, engines, &latency)
+	// Time-of-day bandwidth profiles from the schedule table
+	a.scheduler.SetLimitFunc(func(limit bandwidth.Rate) {
+		for _, e := range a.GetSyncEngines() {
+			e.SetBandwidthLimit(int64(limit))
+		}
+	})
+	go a.scheduler.Start()
+
+	applyIOMax(engines)
+
+	quota := newTrafficQuota(engines, a.Notifier.Send)
+	if quota != nil {
+		go quota.run()
+	}
+
+	if floor := newSpeedFloor(engines, a.HealthState, a.Notifier.Send); floor != nil {
+		go floor.run()
+	}
+
+	docker := newDockerWatcher(engines)
+	if docker != nil {
+		go docker.run()
+	}
+
+	go startSyncStatusBroadcaster(a.WSHub, engines, a.HealthState, a.Notifier, &latency, quota, docker, a.latency)
+	go checkReceiverHealth(a.HealthState, a.Notifier, engines, &latency, waker, newReceiverPacer(a.GetSyncEngines), a.latency)
+	startMQTTPublisher(engines, a.HealthState)
+	if waker != nil {
+		go waker.suspendLoop(engines)
+	}
 }
 
-func startSyncEngines(wsHub *websocket.Hub, healthState *health.State, notifier *notification.Service) []*sync.Engine {
-	var engines []*sync.Engine
-	for i := 1; i <= 10; i++ {
-		id := strconv.Itoa(i) // Capture loop variable
-		prefix := "SYNC_" + id
-		src, tgt, rule := os.Getenv(prefix+"_SOURCE"), os.Getenv(prefix+"_TARGET"), os.Getenv(prefix+"_RULE")
-		if src == "" || tgt == "" {
+// engineIDPattern is what may stand between SYNC_ and _SOURCE in an engine's
+// variables, and what SYNC_N_NAME may hold
+var engineIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// engineKeys returns the N of every engine configured through SYNC_N_SOURCE
+// and SYNC_N_TARGET. N is a number or a name such as "movies"; numbers sort
+// first in numeric order, names alphabetically after them.
+func engineKeys() []string {
+	var keys []string
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		key, ok := strings.CutPrefix(name, "SYNC_")
+		if !ok || value == "" || strings.HasPrefix(key, templatePrefix) {
 			continue
 		}
-		var resolvedTgt string
-		destHost := os.Getenv("DEST_HOST")
-		destModule := os.Getenv("DEST_MODULE")
-
-		if destHost != "" {
-			// Check if target is already a full rsync URI
-			if strings.Contains(tgt, "::") || strings.HasPrefix(tgt, "rsync://") {
-				resolvedTgt = sync.UpdateTargetHost(tgt, destHost)
-			} else if destModule != "" {
-				// Construct Rsync URI: user@host::module/path
-				// e.g. syncuser@192.168.1.50::video-sync/movies
-				rsyncUser := os.Getenv("RSYNC_USER")
-				if rsyncUser == "" {
-					rsyncUser = "syncuser" // Default
-				}
-				// Using rsync:// syntax is sometimes safer for parsing, but :: is standard for daemon
-				resolvedTgt = fmt.Sprintf("%s@%s::%s/%s", rsyncUser, destHost, destModule, tgt)
-			}
-		} else {
-			// Local fallback (for testing or local-only mode)
-			resolvedTgt = sync.ResolveTargetPath(tgt, "", "")
+		if key, ok = strings.CutSuffix(key, "_SOURCE"); !ok || !engineIDPattern.MatchString(key) {
+			continue
+		}
+		if os.Getenv("SYNC_"+key+"_TARGET") == "" {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, errA := strconv.Atoi(keys[i])
+		b, errB := strconv.Atoi(keys[j])
+		switch {
+		case errA == nil && errB == nil:
+			return a < b
+		case errA == nil || errB == nil:
+			return errA == nil
 		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
 
-		bwlimitBytes := int64(0)
-		if bwStr := os.Getenv("BWLIMIT_MBPS"); bwStr != "" {
-			if bw, err := strconv.ParseInt(bwStr, 10, 64); err == nil {
-				bwlimitBytes = bw * 125000
-			}
+// engineID returns the ID engine key runs under: SYNC_N_NAME when set, so
+// renumbering the variables keeps aliases, pause state and stats, or N itself
+func engineID(key string) string {
+	if name := strings.TrimSpace(os.Getenv("SYNC_" + key + "_NAME")); name != "" {
+		if engineIDPattern.MatchString(name) {
+			return name
+		}
+		logger.Warn("Invalid engine name, using the variable number", "engine", key, "name", name)
+	}
+	return key
+}
+
+// engineEnvKey returns the N of the SYNC_N_* variables configuring engine id
+func engineEnvKey(id string) string {
+	for _, spec := range engineSpecs() {
+		if spec.ID == id {
+			return spec.Key
+		}
+	}
+	return id
+}
+
+// migrateEngineNames moves the data stored under the numbers of engines that
+// gained a SYNC_N_NAME to their names
+func migrateEngineNames() {
+	for _, key := range engineKeys() {
+		id := engineID(key)
+		if id == key {
+			continue
+		}
+		moved, err := database.RenameEngine(key, id)
+		if err != nil {
+			logger.Error("Failed to move engine data to its name", "engine", key, "name", id, "error", err)
+		} else if moved {
+			logger.Info("Moved engine data to its name", "engine", key, "name", id)
+		}
+	}
+}
+
+// engineLockGroup resolves the scan/transfer lock group for engine key from
+// SYNC_LOCK_MODE (global, engine, disk) and the per-engine SYNC_N_LOCK_GROUP
+// and SYNC_N_DISK_GROUP overrides.
+func engineLockGroup(key string) string {
+	return sync.ResolveLockGroup(os.Getenv("SYNC_LOCK_MODE"), engineID(key), engineEnv(key, "LOCK_GROUP"),
+		engineEnv(key, "DISK_GROUP"), engineEnv(key, "SOURCE"))
+}
+
+// configureTransferPool sizes the shared transfer pool so that engines in
+// different lock groups can transfer in parallel. SYNC_MAX_TRANSFERS overrides it.
+func configureTransferPool() {
+	groups := make(map[string]bool)
+	for _, key := range engineKeys() {
+		groups[engineLockGroup(key)] = true
+	}
+	size := len(groups)
+	if env := os.Getenv("SYNC_MAX_TRANSFERS"); env != "" {
+		if val, err := strconv.Atoi(env); err == nil && val > 0 {
+			size = val
+		}
+	}
+	pool.SetSize(size)
+}
+
+// splitPatterns splits a comma separated pattern list, dropping empty entries
+func splitPatterns(list string) []string {
+	var patterns []string
+	for _, p := range strings.Split(list, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// resolveTarget turns a SYNC_N_TARGET value into the engine target,
+// an rsync daemon URI when DEST_HOST is set or a local path otherwise
+func resolveTarget(tgt string) string {
+	var destHost string
+	if hosts := sync.DestHosts(); len(hosts) > 0 {
+		destHost = hosts[0] // Engines start on the primary receiver
+	}
+	destModule := os.Getenv("DEST_MODULE")
+
+	if destHost == "" {
+		// Local fallback (for testing or local-only mode)
+		return sync.ResolveTargetPath(tgt, "", "")
+	}
+	// Check if target is already a full rsync URI
+	if strings.Contains(tgt, "::") || strings.HasPrefix(tgt, "rsync://") {
+		return sync.UpdateTargetHost(tgt, destHost)
+	}
+	if destModule != "" {
+		// Construct Rsync URI: user@host::module/path
+		// e.g. syncuser@192.168.1.50::video-sync/movies
+		rsyncUser := os.Getenv("RSYNC_USER")
+		if rsyncUser == "" {
+			rsyncUser = "syncuser" // Default
+		}
+		// Using rsync:// syntax is sometimes safer for parsing, but :: is standard for daemon
+		return fmt.Sprintf("%s@%s::%s/%s", rsyncUser, destHost, destModule, tgt)
+	}
+	return ""
+}
+
+func startSyncEngines(wsHub *websocket.Hub, healthState *health.State, notifier *notification.Service, waker *receiverWaker) []*sync.Engine {
+	var engines []*sync.Engine
+	configureTransferPool()
+	// Stagger the first full scans so a large config does not hit every disk at boot
+	scanConcurrency := 2
+	if env := os.Getenv("STARTUP_SCAN_CONCURRENCY"); env != "" {
+		if val, err := strconv.Atoi(env); err == nil && val >= 0 {
+			scanConcurrency = val
+		}
+	}
+	sync.SetInitialScanConcurrency(scanConcurrency)
+	migrateEngineNames()
+	bwlimit, _, err := bandwidth.FromEnv()
+	if err != nil {
+		logger.Error("Invalid bandwidth limit, transfers are unlimited", "error", err)
+	}
+	started := make(map[string]bool)
+	for _, spec := range engineSpecs() {
+		key, id := spec.Key, spec.ID
+		if started[id] {
+			logger.Error("Engine ID used twice, skipping engine", "engine", key, "id", id)
+			continue
+		}
+		started[id] = true
+		src, tgt, rule := spec.Source, spec.Target, engineEnv(key, "RULE")
+		if src == "" || tgt == "" {
+			continue
 		}
+		resolvedTgt := resolveTarget(tgt)
 
 		// Determine include patterns
 		// 1. Default
@@ -78,7 +258,7 @@ func startSyncEngines(wsHub *websocket.Hub, healthState *health.State, notifier
 			includePatterns = strings.Split(env, ",")
 		}
 		// 3. Per-Engine Override
-		if env := os.Getenv(prefix + "_INCLUDE"); env != "" {
+		if env := engineEnv(key, "INCLUDE"); env != "" {
 			includePatterns = strings.Split(env, ",")
 		}
 		// Clean up patterns
@@ -86,6 +266,128 @@ func startSyncEngines(wsHub *websocket.Hub, healthState *health.State, notifier
 			includePatterns[i] = strings.TrimSpace(includePatterns[i])
 		}
 
+		// Protected paths: per-engine lists replace the global ones
+		neverDelete, neverOverwrite := os.Getenv("NEVER_DELETE"), os.Getenv("NEVER_OVERWRITE")
+		if env := engineEnv(key, "NEVER_DELETE"); env != "" {
+			neverDelete = env
+		}
+		if env := engineEnv(key, "NEVER_OVERWRITE"); env != "" {
+			neverOverwrite = env
+		}
+
+		// Settings: per-engine override, then global default. Invalid values
+		// are ignored; validateConfig reports them.
+		number := func(name string) (float64, bool) {
+			val, ok, _ := engineNumber(key, name)
+			return val, ok
+		}
+		setting := func(name string) string {
+			value, _ := engineSetting(key, name)
+			return value
+		}
+
+		// IO priority
+		ioClassStr, ioLevelStr := setting("IO_CLASS"), setting("IO_LEVEL")
+		ioClass, err := sync.ParseIOClass(ioClassStr)
+		if err != nil {
+			logger.Warn("Invalid IO class, leaving IO priority unchanged", "engine", id, "error", err)
+		}
+		ioLevel, err := sync.ParseIOLevel(ioLevelStr)
+		if err != nil {
+			logger.Warn("Invalid IO level, using default level", "engine", id, "error", err)
+			ioLevel = 4
+		}
+
+		maxDeletePercent, _ := number("MAX_DELETE_PERCENT")
+
+		// Delete deferral window
+		deferScans, _ := number("DELETE_DEFER_SCANS")
+		deleteDeferScans := int(deferScans)
+		deferHours, _ := number("DELETE_DEFER_HOURS")
+		deleteDeferAge := time.Duration(deferHours * float64(time.Hour))
+
+		// Change detection for files on both ends
+		mtimePolicy, err := sync.ParseMtimePolicy(setting("MTIME_POLICY"))
+		if err != nil {
+			logger.Warn("Invalid mtime policy, using default", "engine", id, "error", err)
+		}
+		toleranceSeconds, _ := number("MTIME_TOLERANCE_SECONDS")
+		mtimeTolerance := time.Duration(toleranceSeconds * float64(time.Second))
+
+		// Scan hook run against every file before it is published
+		scanCommand := setting("SCAN_COMMAND")
+		var scanTimeout time.Duration
+		if val, err := strconv.Atoi(os.Getenv("SCAN_TIMEOUT_SECONDS")); err == nil && val > 0 {
+			scanTimeout = time.Duration(val) * time.Second
+		}
+
+		// ffprobe validation of source media before it is sent
+		mediaValidation, err := sync.ParseMediaValidation(setting("MEDIA_VALIDATION"))
+		if err != nil {
+			logger.Warn("Invalid media validation mode, not validating", "engine", id, "error", err)
+		}
+
+		// Checksum sidecars or per-directory manifests on the target
+		checksums, err := sync.ParseChecksums(setting("CHECKSUMS"))
+		if err != nil {
+			logger.Warn("Invalid checksum mode, not writing checksums", "engine", id, "error", err)
+		}
+
+		// PAR2 parity for completed target directories, in percent
+		parity, _ := number("PARITY_REDUNDANCY")
+		parityRedundancy := int(parity)
+
+		// Split approval: additions run while deletions, renames and conflicts wait
+		splitApproval := setting("SPLIT_APPROVAL") == "true"
+
+		// Directory skeleton: create source directories on remote targets even
+		// when no file in them is transferred
+		mirrorDirs := setting("MIRROR_DIRS") != "false"
+
+		// Flat targets keep directories removed from the source unless asked
+		flatDeleteDirs := setting("FLAT_DELETE_DIRS") == "true"
+
+		// Actions only logged while the others run, e.g. "delete" to trial deletions
+		var dryRunActions []string
+		for _, a := range splitPatterns(strings.ToLower(setting("DRY_RUN_ACTIONS"))) {
+			if slices.Contains(sync.DryRunActions, a) {
+				dryRunActions = append(dryRunActions, a)
+			} else {
+				logger.Warn("Ignoring unknown DRY_RUN_ACTIONS entry", "engine", id, "action", a)
+			}
+		}
+
+		undoHours, _ := number("UNDO_RETENTION_HOURS")
+		undoRetention := time.Duration(undoHours * float64(time.Hour))
+
+		backlogLimit, _ := strconv.Atoi(os.Getenv("OFFLINE_BACKLOG_LIMIT"))
+
+		// Circuit breaker: consecutive target errors that stop the engine (0 disables it)
+		breakerThreshold := 20
+		if val, ok := number("CIRCUIT_BREAKER_ERRORS"); ok {
+			breakerThreshold = int(val)
+		}
+		breakerProbe := sync.DefaultBreakerProbeInterval
+		if env := os.Getenv("CIRCUIT_BREAKER_PROBE_MINUTES"); env != "" {
+			if val, err := strconv.Atoi(env); err == nil && val > 0 {
+				breakerProbe = time.Duration(val) * time.Minute
+			}
+		}
+
+		moveAfterDays, _ := number("MOVE_AFTER_DAYS")
+		moveAfter := time.Duration(moveAfterDays * float64(24*time.Hour))
+
+		evictAbovePercent, _ := number("EVICT_ABOVE_PERCENT")
+		evictToPercent, _ := number("EVICT_TO_PERCENT")
+
+		// Transfer tuning: explicit settings pin the engine, otherwise benchmark results apply
+		streams, _ := number("STREAMS")
+		chunk, _ := number("CHUNK_KB")
+		numStreams, chunkKB := int(streams), int(chunk)
+		compressStr := setting("COMPRESS")
+		compress := compressStr == "true"
+		autoTune := os.Getenv("AUTO_TUNE") != "false" && setting("STREAMS") == "" && setting("CHUNK_KB") == "" && compressStr == ""
+
 		pollInterval := 60 * time.Second
 		if env := os.Getenv("POLL_INTERVAL"); env != "" {
 			if val, err := strconv.Atoi(env); err == nil && val > 0 {
@@ -100,22 +402,71 @@ func startSyncEngines(wsHub *websocket.Hub, healthState *health.State, notifier
 			}
 		}
 
+		watchFallbackInterval := sync.DefaultWatchFallbackInterval
+		if env := os.Getenv("WATCH_FALLBACK_INTERVAL"); env != "" {
+			if val, err := strconv.Atoi(env); err == nil && val > 0 {
+				watchFallbackInterval = time.Duration(val) * time.Second
+			}
+		}
+
+		var wake func() error
+		if waker != nil {
+			wake = waker.Wake
+		}
+
 		engine := sync.NewEngine(sync.SyncConfig{
-			ID: id, SourceDir: src, TargetDir: resolvedTgt, Rule: rule,
+			ID: id, SourceDir: src, TargetDir: resolvedTgt, Rule: rule, Groups: spec.groups(),
 			ExcludePatterns: []string{".git", ".DS_Store", "Thumbs.db"},
 			IncludePatterns: includePatterns,
-			BandwidthLimit:  bwlimitBytes,
-			PollInterval:    pollInterval, WatchInterval: watchInterval, AutoApproveDeletions: database.GetSetting("auto_approve", "off") == "on",
-			DryRunFunc: func() bool { return database.GetSetting("sync_mode", "dry") == "dry" },
-			OnSyncEvent: func(ts, act, p string, sz int64) {
-				_ = database.LogEvent(ts, act, p, sz, id)
-				item := database.HistoryItem{Time: ts, Action: act, Path: p, Size: database.FormatBytes(sz)}
-				wsHub.Broadcast("history", item)
-				wsHub.Broadcast("stats", database.GetTrafficStats())
-				wsHub.Broadcast("daily", database.GetDailyTraffic(7))
+			BandwidthLimit:  int64(bwlimit),
+			LockGroup:       engineLockGroup(key),
+			IOClass:         ioClass, IOLevel: ioLevel,
+			NumStreams: numStreams, ChunkSize: chunkKB * 1024, Compress: compress, AutoTune: autoTune,
+			PollInterval: pollInterval, WatchInterval: watchInterval, WatchFallbackInterval: watchFallbackInterval, AutoApproveDeletions: database.GetSetting("auto_approve", "off") == "on", SplitApproval: splitApproval,
+			NeverDeletePatterns: splitPatterns(neverDelete), NeverOverwritePatterns: splitPatterns(neverOverwrite), FlatDeleteDirs: flatDeleteDirs,
+			MtimePolicy: mtimePolicy, MtimeTolerance: mtimeTolerance,
+			ScanCommand: scanCommand, ScanTimeout: scanTimeout, MediaValidation: mediaValidation,
+			Checksums: checksums, ParityRedundancy: parityRedundancy, MirrorDirs: mirrorDirs,
+			MaxDeletePercent: maxDeletePercent, DeleteDeferScans: deleteDeferScans, DeleteDeferAge: deleteDeferAge, MoveAfter: moveAfter, BacklogLimit: backlogLimit, UndoRetention: undoRetention,
+			BreakerThreshold: breakerThreshold, BreakerProbeInterval: breakerProbe,
+			EvictAbovePercent: evictAbovePercent, EvictToPercent: evictToPercent,
+			DryRunFunc:    func() bool { return database.GetSetting("sync_mode", "dry") == "dry" },
+			DryRunActions: dryRunActions,
+			OnSyncEvent: func(ts, act, p string, sz int64, cycle string) {
+				if recorded, err := database.RecordEvent(ts, act, p, sz, id, cycle); err == nil && recorded == database.ActionRetried {
+					act, sz = recorded, 0
+				}
+				item := database.HistoryItem{Time: database.LocalTime(ts), Action: act, Path: p, Size: database.FormatBytes(sz), Cycle: cycle}
+				topicHistory.Broadcast(wsHub, item)
+				topicStats.Broadcast(wsHub, database.GetTrafficStats())
+				topicDaily.Broadcast(wsHub, database.GetDailyTraffic(7))
 				healthState.ReportSuccess(notifier.Send)
 			},
-			OnError: func(msg string) { healthState.ReportError(msg, notifier.Send) },
+			OnError: func(msg string) {
+				if !database.InMaintenance(id) {
+					healthState.ReportError(msg, notifier.Send)
+				}
+			},
+			OnBreaker: func(stats sync.BreakerStats) {
+				// Engines in maintenance only log the breaker
+				muted := database.InMaintenance(id)
+				if stats.Open {
+					msg := fmt.Sprintf("Engine %s failed after %d consecutive target errors: %s", id, stats.Failures, stats.LastError)
+					_ = database.LogSystemEvent("system", "Engine Failed", msg)
+					if !muted {
+						notifier.Send(notification.Render(notification.EventCircuitOpen, notification.Vars{
+							Engine: id, Failures: stats.Failures, Error: stats.LastError, Duration: breakerProbe.String(),
+						}), "CRITICAL")
+					}
+					return
+				}
+				failedFor := time.Since(stats.Since).Round(time.Second).String()
+				_ = database.LogSystemEvent("system", "Engine Recovered", fmt.Sprintf("Engine %s: target works again after %s", id, failedFor))
+				if !muted {
+					notifier.Send(notification.Render(notification.EventCircuitClosed, notification.Vars{Engine: id, Duration: failedFor}), "SUCCESS")
+				}
+			},
+			WakeReceiver: wake,
 		})
 
 		if err := engine.Start(); err == nil {
@@ -126,38 +477,113 @@ func startSyncEngines(wsHub *websocket.Hub, healthState *health.State, notifier
 				engine.Pause()
 			}
 		} else {
-			fmt.Printf("Failed to start engine %s: %v\n", id, err)
+			logger.Error("Failed to start engine", "engine", id, "error", err)
 		}
 	}
+	warnOverlaps(engines)
 	return engines
 }
 
-func startSyncStatusBroadcaster(wsHub *websocket.Hub, syncEngines []*sync.Engine, healthState *health.State, latency *int64) {
-	ticker := time.NewTicker(3 * time.Second)
-	defer ticker.Stop()
-	for range ticker.C {
+// warnOverlaps logs engines whose paths overlap. They keep running: writes
+// to the same destination file are serialised by the per-path locks.
+func warnOverlaps(engines []*sync.Engine) {
+	configs := make([]sync.SyncConfig, 0, len(engines))
+	for _, e := range engines {
+		configs = append(configs, e.GetConfig())
+	}
+	for _, o := range sync.FindOverlaps(configs) {
+		logger.Warn("Overlapping engine paths: "+o.String(), "kind", o.Kind, "engine", o.A, "other", o.B)
+		_ = database.LogSystemEvent("system", "Engine Overlap", o.String())
+	}
+}
+
+// applyIOMax sets the IO_MAX cgroup io.max limit on the disks behind the
+// engines' local paths. The cgroup is the container's, so the limit covers
+// all of its IO on those disks, not single engines.
+func applyIOMax(engines []*sync.Engine) {
+	spec := os.Getenv("IO_MAX")
+	if spec == "" {
+		return
+	}
+	for _, e := range engines {
+		cfg := e.GetConfig()
+		paths := []string{cfg.SourceDir}
+		if !sync.IsRemotePath(cfg.TargetDir) {
+			paths = append(paths, cfg.TargetDir)
+		}
+		for _, p := range paths {
+			if err := sync.ApplyIOMax(p, spec); err != nil {
+				logger.Warn("Failed to apply io.max", "engine", cfg.ID, "path", p, "error", err)
+			}
+		}
+	}
+}
+
+// statusInterval is how often the dashboard is updated unless a client asks
+// for another interval
+const statusInterval = 3 * time.Second
+
+// notifyProgress reports the transfer of an engine to the progress notifiers
+// and returns its ETA
+func notifyProgress(notifier *notification.Service, id, file string, transferred, total, speed int64) string {
+	percent := 0.0
+	progressFile := ""
+	if total > 0 {
+		percent = float64(transferred) / float64(total) * 100
+		progressFile = filepath.Base(file)
+	}
+	eta := "Done"
+	if total > transferred {
+		eta = formatETA(total-transferred, speed)
+	}
+	notifier.Progress(notification.Progress{Engine: id, File: progressFile, Percent: percent,
+		Size: database.FormatBytes(total), Speed: database.FormatBytes(speed) + "/s", ETA: eta})
+	return eta
+}
+
+// formatETA renders the time to transfer remaining bytes at speed, "Done"
+// when nothing is left or nothing moves
+func formatETA(remaining, speed int64) string {
+	if speed <= 0 || remaining <= 0 {
+		return "Done"
+	}
+	sec := remaining / speed
+	if sec > 3600 {
+		return fmt.Sprintf("%dh %dm", sec/3600, (sec%3600)/60)
+	} else if sec > 60 {
+		return fmt.Sprintf("%dm %ds", sec/60, sec%60)
+	}
+	return fmt.Sprintf("%ds", sec)
+}
+
+func startSyncStatusBroadcaster(wsHub *websocket.Hub, syncEngines []*sync.Engine, healthState *health.State, notifier *notification.Service, latency *int64, quota *trafficQuota, docker *dockerWatcher, latencyStats *latencyMonitor) {
+	tracker := newEngineTracker()
+	for {
+		time.Sleep(wsHub.Interval(statusInterval))
+		if wsHub.ClientCount() == 0 {
+			// No dashboard open: only keep the progress notifications going
+			for _, engine := range syncEngines {
+				file, transferred, total, speed, _, _ := engine.GetTransferStatsExtended()
+				notifyProgress(notifier, engine.GetConfig().ID, file, transferred, total, speed)
+			}
+			continue
+		}
 		var totalSpeed int64
 		var totalRemaining int64
 		allPaused := true
 		atomicLatency := atomic.LoadInt64(latency)
-		type EngineProgress struct {
-			ID                string  `json:"id"`
-			File              string  `json:"file"`
-			Percent           float64 `json:"percent"`
-			Speed             string  `json:"speed"`
-			Today             string  `json:"today"`
-			Total             string  `json:"total"`
-			IsActive          bool    `json:"is_active"`
-			ETA               string  `json:"eta"`
-			QueueCount        int     `json:"queue_count"`
-			IsScanning        bool    `json:"is_scanning"`
-			AvgSpeed          string  `json:"avg_speed"`
-			Elapsed           string  `json:"elapsed"`
-			SpeedHistory      []int64 `json:"speed_history"`
-			IsPaused          bool    `json:"is_paused"`
-			LastSync          string  `json:"last_sync"`
-			IsRemoteScan      bool    `json:"is_remote_scan"`
-			IsWaitingApproval bool    `json:"is_waiting_approval"`
+		quotas := make(map[string]quotaStatus)
+		var costCeiling *quotaStatus
+		if quota != nil {
+			for _, q := range quota.Status() {
+				quotas[q.EngineID] = q
+			}
+			costCeiling = quota.CostStatus()
+		}
+		maintenance, _ := database.GetMaintenance()
+		inMaintenance := make(map[string]bool)
+		for _, m := range maintenance {
+			inMaintenance[m.EngineID] = true
 		}
 		engineStats := make([]EngineProgress, 0)
 		for _, engine := range syncEngines {
@@ -189,96 +615,155 @@ func startSyncStatusBroadcaster(wsHub *websocket.Hub, syncEngines []*sync.Engine
 				percent = float64(transferredBytes) / float64(totalBytes) * 100
 			}
 			stats := database.GetEngineTrafficStats(engine.GetConfig().ID)
-			etaStr := "Done"
-			if speed > 0 && totalBytes > transferredBytes {
-				rem := totalBytes - transferredBytes
-				sec := rem / speed
-				if sec > 3600 {
-					etaStr = fmt.Sprintf("%dh %dm", sec/3600, (sec%3600)/60)
-				} else if sec > 60 {
-					etaStr = fmt.Sprintf("%dm %ds", sec/60, sec%60)
-				} else {
-					etaStr = fmt.Sprintf("%ds", sec)
-				}
-			}
+			etaStr := notifyProgress(notifier, engine.GetConfig().ID, file, transferredBytes, totalBytes, speed)
+			backlog := engine.GetBacklogStats()
 			engineStats = append(engineStats, EngineProgress{
 				ID: engine.GetConfig().ID, File: filepath.Base(file), Percent: percent, Speed: database.FormatBytes(speed) + "/s", Today: database.FormatBytes(stats.Today), Total: database.FormatBytes(stats.Total), IsActive: speed > 0, ETA: etaStr, QueueCount: queuedCount, IsScanning: engine.IsScanning(),
 				AvgSpeed: database.FormatBytes(avgSpeed) + "/s", Elapsed: elapsedStr, SpeedHistory: engine.GetSpeedHistory(), IsPaused: isPaused, LastSync: engine.GetLastSyncTime().Format(time.RFC3339), IsRemoteScan: engine.IsRemoteScan(),
-				IsWaitingApproval: engine.IsWaitingForApproval(),
+				IsWaitingApproval: engine.IsWaitingForApproval(), Cycle: engine.CurrentCycle(),
+				IsOffline: backlog.Offline, IsReadOnly: backlog.ReadOnly, TargetDiverged: engine.TargetDiverged(), Backlog: backlog.Paths, BacklogSize: database.FormatBytes(backlog.Bytes), BacklogOverflow: backlog.Overflow,
+				BandwidthLimit: bandwidthLimit(engine.EffectiveBandwidthLimit()),
 			})
+			if breaker := engine.GetBreakerStats(); breaker.Open {
+				engineStats[len(engineStats)-1].Breaker = &breaker
+				engineStats[len(engineStats)-1].BreakerError = errclass.Classify(breaker.LastError)
+			}
+			if q, ok := quotas[engine.GetConfig().ID]; ok {
+				engineStats[len(engineStats)-1].Quota, engineStats[len(engineStats)-1].QuotaPercent = q.Label, q.Percent
+			}
+			if quota != nil {
+				engineStats[len(engineStats)-1].QuotaPaused = quota.PausedByQuota(engine.GetConfig().ID)
+			}
+			engineStats[len(engineStats)-1].ContainerPaused = docker.PausedFor(engine.GetConfig().ID)
+			engineStats[len(engineStats)-1].InMaintenance = inMaintenance[""] || inMaintenance[engine.GetConfig().ID]
+			engineStats[len(engineStats)-1].Groups = engine.GetConfig().Groups
+			engineStats[len(engineStats)-1].SpeedFloor = healthState.GetSpeedFloor(engine.GetConfig().ID)
+			engineStats[len(engineStats)-1].Cleanup = engine.GetPhaseProgress()
 		}
 		state := "ACTIVE"
-		progress := "Monitoring..."
+		progress := i18n.T("status.monitoring")
 		if len(syncEngines) > 0 {
 			if allPaused {
 				state = "PAUSED"
-				progress = "Sync Paused"
+				progress = i18n.T("status.paused")
 			} else if totalSpeed > 0 {
 				state = "SYNCING"
-				progress = "Transferring..."
-			}
-		}
-		globalEta := "Done"
-		if totalSpeed > 0 && totalRemaining > 0 {
-			sec := totalRemaining / totalSpeed
-			if sec > 3600 {
-				globalEta = fmt.Sprintf("%dh %dm", sec/3600, (sec%3600)/60)
-			} else if sec > 60 {
-				globalEta = fmt.Sprintf("%dm %ds", sec/60, sec%60)
-			} else {
-				globalEta = fmt.Sprintf("%ds", sec)
+				progress = i18n.T("status.transferring")
 			}
 		}
+		globalEta := formatETA(totalRemaining, totalSpeed)
 
 		latency := atomicLatency
 
 		receiverHealthy, receiverMsg, receiverVersion, receiverUptime := healthState.GetReceiverStatus()
+		receiverLoad, receiverBusy := healthState.GetReceiverLoad()
 		traffic := database.GetTrafficStats()
-		wsHub.Broadcast("progress", map[string]interface{}{
-			"speed": database.FormatBytes(totalSpeed) + "/s", "state": state, "engines": engineStats, "eta": globalEta, "latency": latency,
-			"top_files":        database.GetTopFiles(),
-			"receiver_healthy": receiverHealthy,
-			"receiver_msg":     receiverMsg,
-			"receiver_version": receiverVersion,
-			"receiver_uptime":  receiverUptime,
-			"traffic_today":    database.FormatBytes(traffic.Today),
-			"traffic_total":    database.FormatBytes(traffic.Total),
-		})
-		wsHub.Broadcast("sync_status", map[string]interface{}{"status": progress, "engines": len(syncEngines)})
+		status := Progress{
+			Speed: database.FormatBytes(totalSpeed) + "/s", State: state, ETA: globalEta, Latency: latency,
+			TopFiles:        database.GetTopFiles(),
+			ReceiverHealthy: receiverHealthy,
+			ReceiverMsg:     receiverMsg,
+			ReceiverVersion: receiverVersion,
+			ReceiverUptime:  receiverUptime,
+			ReceiverHost:    healthState.GetReceiverHost(),
+			ReceiverDisks:   healthState.GetReceiverDisks(),
+			System:          system.Collect(),
+			ReceiverSystem:  healthState.GetReceiverSystem(),
+			ReceiverLoad:    receiverLoad,
+			ReceiverBusy:    receiverBusy,
+			LatencyStats:    latencyStats.Current(),
+			TrafficToday:    database.FormatBytes(traffic.Today),
+			TrafficTotal:    database.FormatBytes(traffic.Total),
+			Quota:           quotas[quotaGlobal],
+			CostToday:       database.CostLabel(traffic.Today),
+			CostCeiling:     costCeiling,
+			Maintenance:     maintenance,
+			Groups:          sync.SummarizeGroups(syncEngines),
+		}
+		if !receiverHealthy {
+			status.ReceiverError = errclass.Classify(receiverMsg)
+		}
+		if healthy, lastErr := healthState.GetStatus(); !healthy && lastErr != "" {
+			status.LastError, status.LastErrorClass = lastErr, errclass.Classify(lastErr)
+		}
+		topicProgress.Retain(wsHub, status)
+		topicProgress.Broadcast(wsHub, status)
+		topicSyncStatus.Broadcast(wsHub, SyncStatus{Status: progress, Engines: len(syncEngines)})
+		topicEngines.Retain(wsHub, engineStats)
+		if deltas, snapshot := tracker.update(engineStats); snapshot {
+			topicEngines.Broadcast(wsHub, engineStats)
+		} else if len(deltas) > 0 {
+			topicEngineDelta.Broadcast(wsHub, deltas)
+		}
 	}
 }
 
-func checkReceiverHealth(healthState *health.State, engines []*sync.Engine, latency *int64) {
-	destHost := os.Getenv("DEST_HOST")
-	if destHost == "" {
+func checkReceiverHealth(healthState *health.State, notifier *notification.Service, engines []*sync.Engine, latency *int64, waker *receiverWaker, pacer *receiverPacer, latencyStats *latencyMonitor) {
+	hosts := sync.DestHosts()
+	if len(hosts) == 0 {
 		return
 	}
-	targetURL := fmt.Sprintf("http://%s:8080/health", destHost)
+	threshold := 2
+	if val, err := strconv.Atoi(os.Getenv("FAILOVER_THRESHOLD")); err == nil && val > 0 {
+		threshold = val
+	}
+	failover := newReceiverFailover(hosts, threshold)
+	healthState.SetReceiverHost(failover.Active())
+
 	client := http.Client{Timeout: 5 * time.Second}
+	probe := func(host string) bool {
+		resp, err := client.Get(agent.ReceiverURL(host) + "/health")
+		if err != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}
 	ticker := time.NewTicker(15 * time.Second)
 	defer ticker.Stop()
 	for range ticker.C {
+		destHost := failover.Active()
+		// Probing a suspended receiver could wake it (wake on unicast) or fail it over
+		if waker != nil && waker.Asleep() {
+			healthState.ReportReceiverStatus(false, fmt.Sprintf("Asleep (%s, woken before the next sync)", destHost), "", "")
+			continue
+		}
 		start := time.Now()
-		resp, err := client.Get(targetURL)
+		resp, err := client.Get(agent.ReceiverURL(destHost) + "/health")
+		took := time.Since(start)
 		if err == nil {
-			atomic.StoreInt64(latency, time.Since(start).Milliseconds())
+			atomic.StoreInt64(latency, took.Milliseconds())
 		}
+		latencyStats.record(destHost, took, err != nil, start)
 		var version, uptime string
+		var receiverSystem *system.Metrics
+		var receiverLoad *system.Load
 		healthy := false
 		msg := ""
 		if err == nil {
 			var data struct {
-				Status  string `json:"status"`
-				Version string `json:"version"`
-				Uptime  string `json:"uptime"`
+				Status  string          `json:"status"`
+				Version string          `json:"version"`
+				Uptime  string          `json:"uptime"`
+				Disks   []smart.Disk    `json:"disks"`
+				System  *system.Metrics `json:"system"`
+				Load    *system.Load    `json:"load"`
 			}
 			if err := json.NewDecoder(resp.Body).Decode(&data); err == nil {
 				healthy = true
 				version = data.Version
 				uptime = data.Uptime
+				healthState.ReportReceiverDisks(data.Disks, notifier.Send)
+				receiverSystem = data.System
+				receiverLoad = data.Load
 			}
 			if err := resp.Body.Close(); err != nil {
-				fmt.Printf("Error closing receiver health body: %v\n", err)
+				logger.Warn("Error closing receiver health body", "error", err)
+			}
+		}
+		if len(hosts) > 1 {
+			if host, switched := failover.observe(healthy, probe); switched {
+				retargetEngines(engines, healthState, notifier, destHost, host, host == hosts[0])
 			}
 		}
 		if !healthy && len(engines) > 0 {
@@ -291,5 +776,34 @@ func checkReceiverHealth(healthState *health.State, engines []*sync.Engine, late
 			msg = fmt.Sprintf("Agent Unreachable (%s)", destHost)
 		}
 		healthState.ReportReceiverStatus(healthy, msg, version, uptime)
+		healthState.ReportReceiverSystem(receiverSystem)
+		healthState.ReportReceiverLoad(receiverLoad, pacer.observe(receiverLoad))
+	}
+}
+
+// retargetEngines points every remote engine at another receiver host
+func retargetEngines(engines []*sync.Engine, healthState *health.State, notifier *notification.Service, from, to string, failback bool) {
+	for _, e := range engines {
+		e.SetTargetHost(to)
+	}
+	healthState.SetReceiverHost(to)
+	action, msg := "Receiver Failover", fmt.Sprintf("Receiver %s is down, engines switched to %s", from, to)
+	if failback {
+		action, msg = "Receiver Failback", fmt.Sprintf("Primary receiver %s recovered, engines switched back from %s", to, from)
+	}
+	logger.Warn(msg, "from", from, "to", to)
+	_ = database.LogSystemEvent("system", action, msg)
+	event := notification.EventFailover
+	if failback {
+		event = notification.EventFailback
+	}
+	notifier.Send(notification.Render(event, notification.Vars{From: from, To: to}), "WARNING")
+}
+
+// bandwidthLimit formats an engine's limit for the dashboard, "" when unlimited
+func bandwidthLimit(bytesPerSec int64) string {
+	if bytesPerSec <= 0 {
+		return ""
 	}
+	return bandwidth.Rate(bytesPerSec).String()
 }
@@ -47,12 +47,13 @@ func TestStartSyncEngines_LoopCapture(t *testing.T) {
 	healthState := &health.State{}
 
 	// We pass nil for wsHub and notifier as they are only used in callbacks
-	engines := startSyncEngines(nil, healthState, nil)
+	engines := startSyncEngines(nil, healthState, nil, nil)
 
-	// Cleanup engines (stop watchers)
+	// Cleanup engines (stop watchers, let the initial sync finish before later tests swap the DB)
 	defer func() {
 		for _, e := range engines {
 			e.Stop()
+			waitIdle(e)
 		}
 	}()
 
@@ -87,3 +88,61 @@ func TestStartSyncEngines_LoopCapture(t *testing.T) {
 	// Give some time for background goroutines to settle if needed, though not strictly required for this test
 	time.Sleep(10 * time.Millisecond)
 }
+
+func TestEngineKeys(t *testing.T) {
+	for _, kv := range [][2]string{
+		{"SYNC_10_SOURCE", "/s"}, {"SYNC_10_TARGET", "t"},
+		{"SYNC_2_SOURCE", "/s"}, {"SYNC_2_TARGET", "t"},
+		{"SYNC_movies_4k_SOURCE", "/s"}, {"SYNC_movies_4k_TARGET", "t"},
+		{"SYNC_anime_SOURCE", "/s"}, {"SYNC_anime_TARGET", "t"},
+		{"SYNC_nosource_TARGET", "t"},
+		{"SYNC_notarget_SOURCE", "/s"},
+		{"SYNC__SOURCE", "/s"}, {"SYNC__TARGET", "t"},
+	} {
+		t.Setenv(kv[0], kv[1])
+	}
+	got := engineKeys()
+	want := []string{"2", "10", "anime", "movies_4k"}
+	if len(got) != len(want) {
+		t.Fatalf("engineKeys() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("engineKeys() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestEngineID(t *testing.T) {
+	t.Setenv("SYNC_3_SOURCE", "/s")
+	t.Setenv("SYNC_3_TARGET", "t")
+	t.Setenv("SYNC_3_NAME", "movies")
+	t.Setenv("SYNC_4_NAME", "bad name")
+
+	if got := engineID("3"); got != "movies" {
+		t.Errorf("engineID(3) = %q, want movies", got)
+	}
+	if got := engineID("4"); got != "4" {
+		t.Errorf("Invalid names should fall back to the number, got %q", got)
+	}
+	if got := engineEnvKey("movies"); got != "3" {
+		t.Errorf("engineEnvKey(movies) = %q, want 3", got)
+	}
+}
+
+func TestFormatETA(t *testing.T) {
+	for _, c := range []struct {
+		remaining, speed int64
+		want             string
+	}{
+		{0, 100, "Done"},
+		{100, 0, "Done"},
+		{500, 10, "50s"},
+		{1000, 10, "1m 40s"},
+		{7200 * 10, 10, "2h 0m"},
+	} {
+		if got := formatETA(c.remaining, c.speed); got != c.want {
+			t.Errorf("%d bytes at %d/s: expected %s, got %s", c.remaining, c.speed, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	stdsync "sync"
+	"time"
+
+	"schnorarr/internal/bandwidth"
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/monitor/health"
+	"schnorarr/internal/monitor/notification"
+	"schnorarr/internal/sync"
+)
+
+// speedFloorInterval is how often transfer speeds are sampled
+var speedFloorInterval = 10 * time.Second
+
+// speedFloor alerts when an engine transfers below a floor for a sustained
+// time (SPEED_FLOOR for SPEED_FLOOR_MINUTES, SYNC_N_SPEED_FLOOR per engine),
+// which usually means a dying disk or a saturated link. Only time spent on
+// transfers counts: gaps between the files of a cycle are skipped, idle
+// and paused engines reset the window.
+type speedFloor struct {
+	floors  map[string]bandwidth.Rate // Per engine
+	window  time.Duration
+	engines []*sync.Engine
+	health  *health.State
+	notify  func(msg, level string)
+	stats   func(e *sync.Engine) (file string, speed int64, syncing bool) // The current transfer and whether a cycle runs
+
+	mu       stdsync.Mutex
+	samples  map[string][]speedSample
+	breached map[string]bool
+}
+
+type speedSample struct {
+	at    time.Time
+	speed int64
+}
+
+// newSpeedFloor reads SPEED_FLOOR, SYNC_N_SPEED_FLOOR and
+// SPEED_FLOOR_MINUTES; nil when no engine has a floor
+func newSpeedFloor(engines []*sync.Engine, healthState *health.State, notify func(msg, level string)) *speedFloor {
+	global := parseSpeedFloor("SPEED_FLOOR", os.Getenv("SPEED_FLOOR"))
+	floors := make(map[string]bandwidth.Rate)
+	for _, e := range engines {
+		id := e.GetConfig().ID
+		floor := global
+		if v := engineEnv(engineEnvKey(id), "SPEED_FLOOR"); v != "" {
+			floor = parseSpeedFloor("SYNC_"+engineEnvKey(id)+"_SPEED_FLOOR", v)
+		}
+		if floor > 0 {
+			floors[id] = floor
+		}
+	}
+	if len(floors) == 0 {
+		return nil
+	}
+	window := 10 * time.Minute
+	if env := os.Getenv("SPEED_FLOOR_MINUTES"); env != "" {
+		if val, err := strconv.Atoi(env); err == nil && val > 0 {
+			window = time.Duration(val) * time.Minute
+		} else {
+			logger.Warn("Invalid SPEED_FLOOR_MINUTES, using the default", "value", env, "default", window.String())
+		}
+	}
+	logger.Info("Speed floor alerts enabled", "engines", len(floors), "window", window.String())
+	return &speedFloor{
+		floors: floors, window: window, engines: engines, health: healthState, notify: notify,
+		samples: make(map[string][]speedSample), breached: make(map[string]bool), stats: transferSpeed,
+	}
+}
+
+func transferSpeed(e *sync.Engine) (string, int64, bool) {
+	file, _, _, speed, _, _ := e.GetTransferStatsExtended()
+	return file, speed, e.IsBusy()
+}
+
+// parseSpeedFloor reads a floor with the units of BWLIMIT, 0 when unset or invalid
+func parseSpeedFloor(name, value string) bandwidth.Rate {
+	if value == "" {
+		return 0
+	}
+	floor, err := bandwidth.Parse(value)
+	if err != nil {
+		logger.Warn("Invalid speed floor, ignoring it", "variable", name, "error", err)
+		return 0
+	}
+	return floor
+}
+
+// check samples every engine and alerts on floors breached for the whole
+// window. An engine limited below its floor is not checked.
+func (s *speedFloor) check(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.engines {
+		id := e.GetConfig().ID
+		floor, ok := s.floors[id]
+		if !ok {
+			continue
+		}
+		file, speed, syncing := s.stats(e)
+		limit := bandwidth.Rate(e.EffectiveBandwidthLimit())
+		if !syncing || e.IsPaused() || (limit > 0 && limit < floor) {
+			delete(s.samples, id)
+			if s.breached[id] {
+				s.clear(id, "")
+			}
+			continue
+		}
+		if file == "" {
+			continue // Between two files of the cycle
+		}
+
+		samples := append(s.samples[id], speedSample{at: now, speed: speed})
+		for len(samples) > 0 && now.Sub(samples[0].at) > s.window {
+			samples = samples[1:]
+		}
+		s.samples[id] = samples
+		if now.Sub(samples[0].at) < s.window-speedFloorInterval {
+			continue // Not transferring for the whole window yet
+		}
+		var sum int64
+		for _, smp := range samples {
+			sum += smp.speed
+		}
+		avg := bandwidth.Rate(sum / int64(len(samples)))
+		switch {
+		case avg < floor && !s.breached[id]:
+			s.breached[id] = true
+			detail := fmt.Sprintf("%s/s on average, floor %s", database.FormatBytes(int64(avg)), floor)
+			msg := fmt.Sprintf("Engine %s transferred below its speed floor for %s: %s", id, s.window, detail)
+			logger.Warn(msg, "file", file)
+			_ = database.LogSystemEvent("system", "Speed Floor Breached", msg)
+			s.health.ReportSpeedFloor(id, detail)
+			s.notify(notification.Render(notification.EventSpeedFloor, notification.Vars{
+				Engine: id, File: filepath.Base(file), Duration: s.window.String(), Message: detail,
+			}), "WARNING")
+		case avg >= floor && s.breached[id]:
+			s.clear(id, fmt.Sprintf("%s/s on average", database.FormatBytes(int64(avg))))
+		}
+	}
+}
+
+// clear ends a breach; detail is the recovered speed, "" when the engine
+// stopped transferring, which is not notified
+func (s *speedFloor) clear(id, detail string) {
+	delete(s.breached, id)
+	s.health.ReportSpeedFloor(id, "")
+	if detail == "" {
+		return
+	}
+	logger.Info("Engine transfers above its speed floor again", "engine", id, "speed", detail)
+	_ = database.LogSystemEvent("system", "Speed Floor Recovered", fmt.Sprintf("Engine %s: %s", id, detail))
+	s.notify(notification.Render(notification.EventSpeedRecovered, notification.Vars{Engine: id, Message: detail}), "SUCCESS")
+}
+
+func (s *speedFloor) run() {
+	ticker := time.NewTicker(speedFloorInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.check(time.Now())
+	}
+}
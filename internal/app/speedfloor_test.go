@@ -0,0 +1,84 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"schnorarr/internal/bandwidth"
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/monitor/health"
+	"schnorarr/internal/sync"
+)
+
+func TestSpeedFloor_BreachAndRecovery(t *testing.T) {
+	database.DBPath = filepath.Join(t.TempDir(), "floor.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+
+	e := sync.NewEngine(sync.SyncConfig{ID: "1", SourceDir: t.TempDir(), TargetDir: t.TempDir()})
+	file, speed, syncing := "movie.mkv", int64(100<<10), true
+	var notes []string
+	state := health.New()
+	s := &speedFloor{
+		floors: map[string]bandwidth.Rate{"1": 1 << 20}, window: time.Minute, engines: []*sync.Engine{e}, health: state,
+		notify:  func(msg, level string) { notes = append(notes, level+": "+msg) },
+		stats:   func(*sync.Engine) (string, int64, bool) { return file, speed, syncing },
+		samples: make(map[string][]speedSample), breached: make(map[string]bool),
+	}
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tick := func(n int) {
+		for i := 0; i < n; i++ {
+			s.check(now)
+			now = now.Add(speedFloorInterval)
+		}
+	}
+	tick(3)
+	if len(notes) != 0 {
+		t.Fatalf("A slow start should not alert before the window passed, got %v", notes)
+	}
+	tick(5)
+	if len(notes) != 1 || state.GetSpeedFloor("1") == "" {
+		t.Fatalf("A minute below the floor should alert once, got %v", notes)
+	}
+	tick(3)
+	if len(notes) != 1 {
+		t.Errorf("A breach should be notified once, got %v", notes)
+	}
+
+	speed = 10 << 20
+	tick(7)
+	if len(notes) != 2 || state.GetSpeedFloor("1") != "" {
+		t.Fatalf("Recovering should clear the breach and notify, got %v", notes)
+	}
+
+	// Idle time does not count: the window starts again with the next cycle
+	speed, syncing = 100<<10, false
+	tick(10)
+	syncing = true
+	tick(3)
+	if len(notes) != 2 {
+		t.Errorf("Idle time should not count towards a breach, got %v", notes)
+	}
+
+	// Gaps between the files of a cycle keep the window
+	file = ""
+	tick(2)
+	file = "next.mkv"
+	tick(3)
+	if len(notes) != 3 {
+		t.Errorf("A slow cycle should alert across its files, got %v", notes)
+	}
+	speed = 10 << 20
+	tick(7)
+
+	// A bandwidth limit below the floor is not a breach
+	e.SetBandwidthLimit(512 << 10)
+	tick(10)
+	if len(notes) != 4 {
+		t.Errorf("An engine limited below its floor should not alert, got %v", notes)
+	}
+}
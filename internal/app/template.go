@@ -0,0 +1,103 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"schnorarr/internal/sync"
+)
+
+// templatePrefix marks SYNC_TEMPLATE_<name>_* variables: settings shared by
+// the engines that name the template in SYNC_N_TEMPLATE
+const templatePrefix = "TEMPLATE_"
+
+// engineOwnSettings are the SYNC_N_* variables a template cannot provide
+var engineOwnSettings = map[string]bool{"SOURCE": true, "TARGET": true, "NAME": true, "TEMPLATE": true}
+
+// engineEnv returns setting name of engine key: SYNC_N_<name>, or when unset
+// SYNC_TEMPLATE_<template>_<name> of the template in SYNC_N_TEMPLATE
+func engineEnv(key, name string) string {
+	if v := os.Getenv("SYNC_" + key + "_" + name); v != "" || engineOwnSettings[name] {
+		return v
+	}
+	if tpl := strings.TrimSpace(os.Getenv("SYNC_" + key + "_TEMPLATE")); tpl != "" {
+		return os.Getenv("SYNC_" + templatePrefix + tpl + "_" + name)
+	}
+	return ""
+}
+
+// engineSpec is an engine to start. Every setting comes from the SYNC_N_*
+// variables of Key, so the sub-engines of an expanded engine share them.
+type engineSpec struct {
+	Key    string
+	ID     string
+	Source string
+	Target string // SYNC_N_TARGET form, before resolveTarget
+	Parent string // ID of the expanded engine, empty for plain engines
+}
+
+// groups returns the engine's groups; sub-engines are also tagged with their
+// parent so the group actions apply to all of them
+func (s engineSpec) groups() []string {
+	return sync.ParseGroups(s.Parent + "," + engineEnv(s.Key, "GROUPS"))
+}
+
+// invalidIDChars are replaced when a directory name becomes part of an engine ID
+var invalidIDChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// engineSpecs returns the engines to start. SYNC_N_EXPAND=dirs turns engine
+// N into one sub-engine per top-level directory of SYNC_N_SOURCE, with the ID
+// <id>-<dir> and the same directory below SYNC_N_TARGET as its target.
+// Directories are listed when the engines start; new ones need a restart.
+func engineSpecs() []engineSpec {
+	var specs []engineSpec
+	for _, key := range engineKeys() {
+		id := engineID(key)
+		src, tgt := engineEnv(key, "SOURCE"), engineEnv(key, "TARGET")
+		switch mode := strings.ToLower(strings.TrimSpace(engineEnv(key, "EXPAND"))); mode {
+		case "", "false", "off":
+			specs = append(specs, engineSpec{Key: key, ID: id, Source: src, Target: tgt})
+		case "dirs":
+			specs = append(specs, expandEngine(key, id, src, tgt)...)
+		default:
+			logger.Warn("Unknown SYNC_N_EXPAND mode, running as a single engine", "engine", id, "mode", mode)
+			specs = append(specs, engineSpec{Key: key, ID: id, Source: src, Target: tgt})
+		}
+	}
+	return specs
+}
+
+// expandEngine lists the sub-engines of an expanded engine. Hidden
+// directories are skipped.
+func expandEngine(key, id, src, tgt string) []engineSpec {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		logger.Error("Failed to list the directories of an expanded engine", "engine", id, "source", src, "error", err)
+		return nil
+	}
+	var specs []engineSpec
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		slug := strings.Trim(invalidIDChars.ReplaceAllString(name, "_"), "_")
+		if slug == "" {
+			logger.Warn("Skipping a directory without a usable engine name", "engine", id, "dir", name)
+			continue
+		}
+		specs = append(specs, engineSpec{
+			Key:    key,
+			ID:     id + "-" + slug,
+			Source: filepath.Join(src, name),
+			Target: strings.TrimSuffix(tgt, "/") + "/" + name,
+			Parent: id,
+		})
+	}
+	if len(specs) == 0 {
+		logger.Warn("Expanded engine has no directories to sync", "engine", id, "source", src)
+	}
+	return specs
+}
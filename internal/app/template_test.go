@@ -0,0 +1,77 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEngineEnv_Template(t *testing.T) {
+	t.Setenv("SYNC_TEMPLATE_shows_RULE", "series")
+	t.Setenv("SYNC_TEMPLATE_shows_NEVER_DELETE", "*.nfo")
+	t.Setenv("SYNC_TEMPLATE_shows_SOURCE", "/ignored")
+	t.Setenv("SYNC_TEMPLATE_shows_TARGET", "ignored")
+	t.Setenv("SYNC_1_SOURCE", "/data/a")
+	t.Setenv("SYNC_1_TARGET", "a")
+	t.Setenv("SYNC_1_TEMPLATE", "shows")
+	t.Setenv("SYNC_1_NEVER_DELETE", "*.srt")
+
+	if got := engineEnv("1", "RULE"); got != "series" {
+		t.Errorf("RULE = %q, want the template's series", got)
+	}
+	if got := engineEnv("1", "NEVER_DELETE"); got != "*.srt" {
+		t.Errorf("NEVER_DELETE = %q, the engine's own setting should win", got)
+	}
+	if got := engineEnv("1", "SOURCE"); got != "/data/a" {
+		t.Errorf("SOURCE = %q, templates cannot provide the source", got)
+	}
+	if got := engineEnv("2", "RULE"); got != "" {
+		t.Errorf("Engines without a template should not inherit settings, got %q", got)
+	}
+	for _, key := range engineKeys() {
+		if key == "TEMPLATE_shows" {
+			t.Error("Template variables must not define an engine")
+		}
+	}
+}
+
+func TestEngineSpecs_Expand(t *testing.T) {
+	src := t.TempDir()
+	for _, d := range []string{"Show A", "Show.B", ".hidden"} {
+		if err := os.Mkdir(filepath.Join(src, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(src, "file.mkv"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SYNC_tv_SOURCE", src)
+	t.Setenv("SYNC_tv_TARGET", "tv/")
+	t.Setenv("SYNC_tv_EXPAND", "dirs")
+	t.Setenv("SYNC_tv_GROUPS", "media")
+
+	var specs []engineSpec
+	for _, s := range engineSpecs() {
+		if s.Key == "tv" {
+			specs = append(specs, s)
+		}
+	}
+	if len(specs) != 2 {
+		t.Fatalf("engineSpecs() = %+v, want one engine per visible directory", specs)
+	}
+	want := []engineSpec{
+		{Key: "tv", ID: "tv-Show_A", Source: filepath.Join(src, "Show A"), Target: "tv/Show A", Parent: "tv"},
+		{Key: "tv", ID: "tv-Show_B", Source: filepath.Join(src, "Show.B"), Target: "tv/Show.B", Parent: "tv"},
+	}
+	for i := range want {
+		if specs[i] != want[i] {
+			t.Errorf("spec %d = %+v, want %+v", i, specs[i], want[i])
+		}
+	}
+	if g := specs[0].groups(); len(g) != 2 || g[0] != "tv" || g[1] != "media" {
+		t.Errorf("Sub-engine groups = %v, want [tv media]", g)
+	}
+	if got := engineEnvKey("tv-Show_B"); got != "tv" {
+		t.Errorf("engineEnvKey(tv-Show_B) = %q, want tv", got)
+	}
+}
@@ -0,0 +1,47 @@
+package app
+
+import (
+	"os"
+	"time"
+	_ "time/tzdata" // Zone names resolve in images without tzdata
+
+	"schnorarr/internal/monitor/database"
+)
+
+// setupTimezone makes TZ (e.g. "Europe/Berlin") the local zone for the
+// scheduler, traffic days and displayed timestamps. Containers default to UTC.
+func setupTimezone() {
+	name := os.Getenv("TZ")
+	if name == "" {
+		return
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		logger.Warn("Unknown time zone, using UTC", "tz", name, "error", err)
+		time.Local = time.UTC
+		return
+	}
+	time.Local = loc
+	logger.Info("Using time zone", "tz", loc.String())
+}
+
+// convertLegacyHistory fills in the epoch times of history written by older
+// versions, reading their local times in HISTORY_LEGACY_TZ (default: the
+// local zone)
+func convertLegacyHistory() {
+	from := time.Local
+	if name := os.Getenv("HISTORY_LEGACY_TZ"); name != "" {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			logger.Warn("Unknown HISTORY_LEGACY_TZ, using the local zone", "tz", name, "error", err)
+		} else {
+			from = loc
+		}
+	}
+	converted, err := database.MigrateHistoryTimestamps(from)
+	if err != nil {
+		logger.Error("Converting history timestamps failed", "error", err)
+	} else if converted > 0 {
+		logger.Info("Converted history timestamps to UTC epoch", "rows", converted, "from", from.String())
+	}
+}
@@ -0,0 +1,224 @@
+package app
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	stdsync "sync"
+	"time"
+)
+
+// certCheckInterval is how often the certificate files are checked for
+// renewals, e.g. by certbot
+const certCheckInterval = time.Minute
+
+// tlsDir holds the generated self-signed certificate (TLS_DIR)
+func tlsDir() string {
+	if dir := os.Getenv("TLS_DIR"); dir != "" {
+		return dir
+	}
+	return "/config/tls"
+}
+
+// tlsConfig returns the TLS configuration of the web server from TLS_CERT
+// and TLS_KEY or, with TLS_SELF_SIGNED, a generated certificate. It returns
+// nil when TLS is off.
+func tlsConfig() (*tls.Config, error) {
+	certFile, keyFile := os.Getenv("TLS_CERT"), os.Getenv("TLS_KEY")
+	switch {
+	case certFile != "" && keyFile != "":
+	case certFile != "" || keyFile != "":
+		return nil, fmt.Errorf("TLS needs both TLS_CERT and TLS_KEY")
+	case os.Getenv("TLS_SELF_SIGNED") == "true":
+		var err error
+		if certFile, keyFile, err = ensureSelfSigned(tlsDir(), tlsHosts()); err != nil {
+			return nil, fmt.Errorf("self-signed certificate: %w", err)
+		}
+	default:
+		return nil, nil
+	}
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: r.GetCertificate}, nil
+}
+
+// tlsHosts are the names of the self-signed certificate: the hostname,
+// localhost and TLS_HOSTS
+func tlsHosts() []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if name, err := os.Hostname(); err == nil && name != "" {
+		hosts = append(hosts, name)
+	}
+	for _, h := range strings.Split(os.Getenv("TLS_HOSTS"), ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// certReloader serves the certificate files and picks up renewed files
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      stdsync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+func (r *certReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+	info, err := os.Stat(r.certFile)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.cert, r.modTime, r.checked = &cert, info.ModTime(), time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+// GetCertificate returns the certificate, reloading it when the file changed
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	due := time.Since(r.checked) > certCheckInterval
+	if due {
+		r.checked = time.Now()
+	}
+	cert, modTime := r.cert, r.modTime
+	r.mu.Unlock()
+
+	if due {
+		if info, err := os.Stat(r.certFile); err == nil && !info.ModTime().Equal(modTime) {
+			if err := r.load(); err != nil {
+				logger.Error("Failed to reload TLS certificate, keeping the old one", "error", err)
+			} else {
+				logger.Info("Reloaded TLS certificate", "cert", r.certFile)
+				r.mu.Lock()
+				cert = r.cert
+				r.mu.Unlock()
+			}
+		}
+	}
+	return cert, nil
+}
+
+// ensureSelfSigned returns the self-signed certificate in dir, generating it
+// on first use. It is kept so browser exceptions survive restarts.
+func ensureSelfSigned(dir string, hosts []string) (certFile, keyFile string, err error) {
+	certFile, keyFile = filepath.Join(dir, "selfsigned.crt"), filepath.Join(dir, "selfsigned.key")
+	if _, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil {
+		return certFile, keyFile, nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", "", err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return "", "", err
+	}
+	tmpl := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"schnorarr"}, CommonName: hosts[0]},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(0, 0, 825), // The longest browsers accept
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		return "", "", err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return "", "", err
+	}
+	logger.Info("Generated self-signed TLS certificate", "cert", certFile, "hosts", strings.Join(hosts, ","))
+	return certFile, keyFile, nil
+}
+
+// httpsRedirect sends plain HTTP requests to the HTTPS port
+func httpsRedirect(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// serve runs the web server on addr, over HTTPS (and HTTP/2) when TLS is
+// configured. HTTP_REDIRECT_PORT then redirects plain HTTP to it.
+// separateAgent tells whether the agent API has a listener of its own.
+func serve(addr string, handler http.Handler, separateAgent bool) error {
+	cfg, err := tlsConfig()
+	if err != nil {
+		return fmt.Errorf("TLS: %w", err)
+	}
+	ln, err := listen(addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: handler, TLSConfig: cfg}
+	if cfg == nil {
+		return srv.Serve(ln)
+	}
+	if os.Getenv("MODE") != "sender" && !separateAgent {
+		logger.Warn("TLS is on: senders reach this receiver's API over plain HTTP and will fail to connect, set AGENT_LISTEN_ADDR")
+	}
+	if redirectPort := os.Getenv("HTTP_REDIRECT_PORT"); redirectPort != "" {
+		httpsPort := "443"
+		if port := listenPort(addr); port != 0 {
+			httpsPort = strconv.Itoa(port)
+		}
+		go func() {
+			logger.Info("Redirecting HTTP to HTTPS", "port", redirectPort)
+			if err := http.ListenAndServe(":"+redirectPort, httpsRedirect(httpsPort)); err != nil {
+				logger.Error("HTTP redirect server failed", "port", redirectPort, "error", err)
+			}
+		}()
+	}
+	logger.Info("Serving HTTPS", "addr", addr)
+	return srv.ServeTLS(ln, "", "")
+}
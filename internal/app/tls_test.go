@@ -0,0 +1,112 @@
+package app
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTLSConfig_SelfSigned(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TLS_SELF_SIGNED", "true")
+	t.Setenv("TLS_DIR", dir)
+	t.Setenv("TLS_HOSTS", "nas.lan")
+
+	cfg, err := tlsConfig()
+	if err != nil || cfg == nil {
+		t.Fatalf("tlsConfig = %v, %v", cfg, err)
+	}
+	first, _ := os.ReadFile(filepath.Join(dir, "selfsigned.crt"))
+	if _, err := tlsConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := os.ReadFile(filepath.Join(dir, "selfsigned.crt")); !bytes.Equal(first, again) {
+		t.Error("The self-signed certificate should be reused")
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), TLSConfig: cfg}
+	go func() { _ = srv.ServeTLS(ln, "", "") }()
+	defer func() { _ = srv.Close() }()
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true, ServerName: "nas.lan"},
+		ForceAttemptHTTP2: true,
+	}}
+	resp, err := client.Get("https://" + ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("Expected HTTP/2, got %s", resp.Proto)
+	}
+	if err := resp.TLS.PeerCertificates[0].VerifyHostname("nas.lan"); err != nil {
+		t.Errorf("Certificate should cover TLS_HOSTS: %v", err)
+	}
+}
+
+func TestTLSConfig_Off(t *testing.T) {
+	if cfg, err := tlsConfig(); cfg != nil || err != nil {
+		t.Errorf("TLS should be off by default, got %v, %v", cfg, err)
+	}
+	t.Setenv("TLS_CERT", "/tmp/cert.pem")
+	if _, err := tlsConfig(); err == nil {
+		t.Error("TLS_CERT without TLS_KEY should fail")
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir, other := t.TempDir(), t.TempDir()
+	certFile, keyFile, err := ensureSelfSigned(dir, []string{"old.lan"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.load(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A renewal replaces the files
+	newCert, newKey, err := ensureSelfSigned(other, []string{"new.lan"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for src, dst := range map[string]string{newCert: certFile, newKey: keyFile} {
+		data, _ := os.ReadFile(src)
+		if err := os.WriteFile(dst, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	later := time.Now().Add(time.Hour)
+	_ = os.Chtimes(certFile, later, later)
+
+	r.checked = time.Now().Add(-2 * certCheckInterval)
+	cert, _ := r.GetCertificate(nil)
+	if cert.Leaf == nil || cert.Leaf.DNSNames[0] != "new.lan" {
+		t.Error("Renewed certificate should be picked up")
+	}
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	for port, want := range map[string]string{
+		"8443": "https://nas.lan:8443/history?page=2",
+		"443":  "https://nas.lan/history?page=2",
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://nas.lan:8080/history?page=2", nil)
+		httpsRedirect(port).ServeHTTP(w, req)
+		if loc := w.Header().Get("Location"); loc != want {
+			t.Errorf("Redirect to port %s = %q, want %q", port, loc, want)
+		}
+	}
+}
@@ -0,0 +1,395 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"schnorarr/internal/bandwidth"
+	"schnorarr/internal/monitor/config"
+	"schnorarr/internal/sync"
+)
+
+// knownRules are the values of SYNC_N_RULE; "" and "standard" mirror the tree
+var knownRules = map[string]bool{"": true, "standard": true, "series": true, "flat": true, sync.RuleMove: true}
+
+// settingGlobals are the global variables of engine settings named
+// differently than SYNC_N_<name>
+var settingGlobals = map[string]string{
+	"STREAMS":  "TRANSFER_STREAMS",
+	"CHUNK_KB": "TRANSFER_CHUNK_KB",
+	"COMPRESS": "RSYNC_COMPRESS",
+}
+
+// engineSetting returns the value of setting name of engine key and the
+// variable it came from: SYNC_N_<name> or its template, then the global one
+func engineSetting(key, name string) (value, variable string) {
+	if v := engineEnv(key, name); v != "" {
+		return v, "SYNC_" + key + "_" + name
+	}
+	global := name
+	if g, ok := settingGlobals[name]; ok {
+		global = g
+	}
+	return os.Getenv(global), global
+}
+
+// numberSetting is the valid range of a numeric engine setting
+type numberSetting struct {
+	name     string
+	min, max float64
+	integer  bool
+}
+
+// numberSettings are the numeric engine settings, parsed by the sender and
+// checked by validateConfig
+var numberSettings = []numberSetting{
+	{"MAX_DELETE_PERCENT", 0, 100, false},
+	{"DELETE_DEFER_SCANS", 0, 1e6, true},
+	{"DELETE_DEFER_HOURS", 0, 1e6, false},
+	{"MTIME_TOLERANCE_SECONDS", 0, 1e6, false},
+	{"PARITY_REDUNDANCY", 0, 100, true},
+	{"UNDO_RETENTION_HOURS", 0, 1e6, false},
+	{"STREAMS", 0, 1e6, true},
+	{"CHUNK_KB", 0, 1e6, true},
+	{"CIRCUIT_BREAKER_ERRORS", 0, 1e6, true},
+	{"MOVE_AFTER_DAYS", 0, 1e6, false},
+	{"EVICT_ABOVE_PERCENT", 0, 100, false},
+	{"EVICT_TO_PERCENT", 0, 100, false},
+}
+
+// engineNumber returns numeric setting name of engine key. ok is false when
+// it is unset or invalid; err explains an invalid value, which is ignored.
+func engineNumber(key, name string) (val float64, ok bool, err error) {
+	opt := lookupNumberSetting(name)
+	value, _ := engineSetting(key, name)
+	if value == "" {
+		return 0, false, nil
+	}
+	if opt.integer {
+		var n int
+		n, err = strconv.Atoi(value)
+		val = float64(n)
+	} else {
+		val, err = strconv.ParseFloat(value, 64)
+	}
+	if err != nil || val < opt.min || val > opt.max {
+		return 0, false, fmt.Errorf("invalid value %q", value)
+	}
+	return val, true, nil
+}
+
+func lookupNumberSetting(name string) numberSetting {
+	i := slices.IndexFunc(numberSettings, func(n numberSetting) bool { return n.name == name })
+	if i < 0 {
+		panic("unknown number setting " + name)
+	}
+	return numberSettings[i]
+}
+
+// validateConfig checks the sender configuration in the environment: engine
+// paths, target URIs, option values and options that conflict. Engines that
+// fail would be skipped or misbehave at runtime.
+func validateConfig() *config.Report {
+	r := config.NewReport()
+	if mode := os.Getenv("MODE"); mode != "" && mode != "sender" && mode != "receiver" {
+		r.Add(config.SeverityWarning, "MODE", fmt.Sprintf("Unknown mode %q, running as receiver", mode), "Set MODE=sender or MODE=receiver")
+	}
+	if _, _, err := bandwidth.FromEnv(); err != nil {
+		r.Add(config.SeverityError, "BWLIMIT", err.Error()+", transfers run unlimited", "Use e.g. 25mbit, 4MB/s or unlimited")
+	}
+	if os.Getenv("MODE") != "sender" {
+		return r // Receivers run no engines
+	}
+	validateEngineVariables(r)
+
+	specs := engineSpecs()
+	if len(specs) == 0 {
+		r.Add(config.SeverityWarning, "SYNC_N_SOURCE", "No engines configured", "Set SYNC_1_SOURCE and SYNC_1_TARGET")
+	}
+	seen := make(map[string]string)
+	for _, spec := range specs {
+		e := r.Engine(spec.ID, spec.Key, spec.Source, spec.Target)
+		if other, dup := seen[spec.ID]; dup {
+			e.Add(config.SeverityError, "SYNC_"+spec.Key+"_NAME", fmt.Sprintf("Engine ID %s is also used by SYNC_%s, this engine is skipped", spec.ID, other), "Give one of them another SYNC_N_NAME")
+			continue
+		}
+		seen[spec.ID] = spec.Key
+		validateEngine(e, spec)
+	}
+	return r.Finish()
+}
+
+// validateEngineVariables reports SYNC_N_* variables that define no engine
+func validateEngineVariables(r *config.Report) {
+	var problems []config.Problem
+	add := func(variable, message, fix string) {
+		problems = append(problems, config.Problem{Variable: variable, Severity: config.SeverityError, Message: message, Fix: fix})
+	}
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		key, ok := strings.CutPrefix(name, "SYNC_")
+		if !ok || value == "" || strings.HasPrefix(key, templatePrefix) {
+			continue
+		}
+		if k, ok := strings.CutSuffix(key, "_SOURCE"); ok {
+			switch {
+			case !engineIDPattern.MatchString(k):
+				add(name, fmt.Sprintf("%s is ignored: %q is no valid engine number or name", name, k), "Use letters, digits, _ and - only")
+			case os.Getenv("SYNC_"+k+"_TARGET") == "":
+				add(name, fmt.Sprintf("Engine %s is skipped: SYNC_%s_TARGET is not set", k, k), "Set SYNC_"+k+"_TARGET")
+			}
+		}
+		if k, ok := strings.CutSuffix(key, "_TARGET"); ok && engineIDPattern.MatchString(k) && os.Getenv("SYNC_"+k+"_SOURCE") == "" {
+			add(name, fmt.Sprintf("Engine %s is skipped: SYNC_%s_SOURCE is not set", k, k), "Set SYNC_"+k+"_SOURCE")
+		}
+	}
+	// os.Environ has no stable order
+	sort.Slice(problems, func(i, j int) bool { return problems[i].Variable < problems[j].Variable })
+	for _, p := range problems {
+		r.Add(p.Severity, p.Variable, p.Message, p.Fix)
+	}
+}
+
+// validateEngine checks the paths and options of one engine
+func validateEngine(e *config.EngineReport, spec engineSpec) {
+	key := spec.Key
+	if name := strings.TrimSpace(os.Getenv("SYNC_" + key + "_NAME")); name != "" && !engineIDPattern.MatchString(name) {
+		e.Add(config.SeverityWarning, "SYNC_"+key+"_NAME", fmt.Sprintf("Invalid engine name %q, the engine runs as %s", name, key), "Use letters, digits, _ and - only")
+	}
+	if tpl := strings.TrimSpace(os.Getenv("SYNC_" + key + "_TEMPLATE")); tpl != "" && !templateExists(tpl) {
+		e.Add(config.SeverityError, "SYNC_"+key+"_TEMPLATE", fmt.Sprintf("Template %q has no SYNC_TEMPLATE_%s_* variables", tpl, tpl), "Check the template name")
+	}
+	switch mode := strings.ToLower(strings.TrimSpace(engineEnv(key, "EXPAND"))); mode {
+	case "", "false", "off", "dirs":
+	default:
+		e.Add(config.SeverityWarning, "SYNC_"+key+"_EXPAND", fmt.Sprintf("Unknown expand mode %q, running as a single engine", mode), "Use dirs or leave it unset")
+	}
+
+	validateSource(e, spec)
+	target := validateTarget(e, spec)
+	if _, err := sync.ResolvePathOverlap(spec.Source, target); err != nil {
+		e.Add(config.SeverityError, "SYNC_"+key+"_TARGET", err.Error(), "Use separate directories for source and target")
+	}
+	validateOptions(e, key)
+}
+
+func templateExists(tpl string) bool {
+	prefix := "SYNC_" + templatePrefix + tpl + "_"
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateSource(e *config.EngineReport, spec engineSpec) {
+	variable := "SYNC_" + spec.Key + "_SOURCE"
+	info, err := os.Stat(spec.Source)
+	switch {
+	case err != nil:
+		e.Add(config.SeverityError, variable, fmt.Sprintf("Source %s is not accessible: %v", spec.Source, err), "Check the volume mount and "+variable)
+		return
+	case !info.IsDir():
+		e.Add(config.SeverityError, variable, fmt.Sprintf("Source %s is not a directory", spec.Source), "")
+		return
+	}
+	f, err := os.Open(spec.Source)
+	if err == nil {
+		_, err = f.Readdirnames(1)
+		_ = f.Close()
+	}
+	if err != nil && err != io.EOF {
+		e.Add(config.SeverityError, variable, fmt.Sprintf("Source %s is not readable: %v", spec.Source, err), "Check PUID/PGID and the permissions of the directory")
+	}
+}
+
+// validateTarget checks the target and returns it resolved, "" when it cannot be
+func validateTarget(e *config.EngineReport, spec engineSpec) string {
+	variable := "SYNC_" + spec.Key + "_TARGET"
+	target := resolveTarget(spec.Target)
+	switch {
+	case target == "":
+		e.Add(config.SeverityError, variable, fmt.Sprintf("Target %s is a path on the receiver, but DEST_MODULE is not set", spec.Target), "Set DEST_MODULE or use a full rsync URI (user@host::module/path)")
+	case sync.IsRemotePath(target):
+		if err := validateRsyncURI(target); err != nil {
+			e.Add(config.SeverityError, variable, fmt.Sprintf("Invalid rsync URI %s: %v", target, err), "Use user@host::module/path or rsync://host/module/path")
+		}
+	default:
+		info, err := os.Stat(target)
+		switch {
+		case os.IsNotExist(err):
+			e.Add(config.SeverityWarning, variable, fmt.Sprintf("Local target %s does not exist yet", target), "Create it, or check the volume mount")
+		case err != nil:
+			e.Add(config.SeverityError, variable, fmt.Sprintf("Target %s is not accessible: %v", target, err), "Check the volume mount and "+variable)
+		case !info.IsDir():
+			e.Add(config.SeverityError, variable, fmt.Sprintf("Target %s is not a directory", target), "")
+		default:
+			if err := dirWritable(target); err != nil {
+				e.Add(config.SeverityError, variable, fmt.Sprintf("Target %s is not writable: %v", target, err), "Check PUID/PGID, the permissions and whether the disk is mounted read-only")
+			}
+		}
+	}
+	return target
+}
+
+// validateRsyncURI checks user@host::module/path and rsync://[user@]host[:port]/module/path
+func validateRsyncURI(uri string) error {
+	var hostPart, modulePath string
+	if rest, ok := strings.CutPrefix(uri, "rsync://"); ok {
+		var found bool
+		if hostPart, modulePath, found = strings.Cut(rest, "/"); !found {
+			return fmt.Errorf("no module")
+		}
+		if h, port, hasPort := strings.Cut(hostPart[strings.LastIndex(hostPart, "@")+1:], ":"); hasPort {
+			if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+				return fmt.Errorf("invalid port %q", port)
+			}
+			hostPart = h
+		}
+	} else {
+		hostPart, modulePath, _ = strings.Cut(uri, "::")
+	}
+	host := hostPart[strings.LastIndex(hostPart, "@")+1:]
+	if host == "" || strings.ContainsAny(host, " /") {
+		return fmt.Errorf("invalid host %q", host)
+	}
+	if module, _, _ := strings.Cut(modulePath, "/"); module == "" {
+		return fmt.Errorf("no module")
+	}
+	return nil
+}
+
+// validateOptions checks option values and options that conflict
+func validateOptions(e *config.EngineReport, key string) {
+	rule := engineEnv(key, "RULE")
+	if !knownRules[rule] {
+		e.Add(config.SeverityWarning, "SYNC_"+key+"_RULE", fmt.Sprintf("Unknown rule %q, synced like standard", rule), "Use standard, series, flat or move")
+	}
+
+	for _, opt := range numberSettings {
+		if _, _, err := engineNumber(key, opt.name); err != nil {
+			value, variable := engineSetting(key, opt.name)
+			e.Add(config.SeverityWarning, variable, fmt.Sprintf("Invalid value %q, ignored", value), fmt.Sprintf("Use a number from %g to %g", opt.min, opt.max))
+		}
+	}
+
+	for _, opt := range []struct {
+		name  string
+		parse func(string) error
+	}{
+		{"IO_CLASS", func(v string) error { _, err := sync.ParseIOClass(v); return err }},
+		{"IO_LEVEL", func(v string) error { _, err := sync.ParseIOLevel(v); return err }},
+		{"MTIME_POLICY", func(v string) error { _, err := sync.ParseMtimePolicy(v); return err }},
+		{"MEDIA_VALIDATION", func(v string) error { _, err := sync.ParseMediaValidation(v); return err }},
+		{"CHECKSUMS", func(v string) error { _, err := sync.ParseChecksums(v); return err }},
+		{"SPEED_FLOOR", func(v string) error { _, err := bandwidth.Parse(v); return err }},
+	} {
+		if value, variable := engineSetting(key, opt.name); value != "" {
+			if err := opt.parse(value); err != nil {
+				e.Add(config.SeverityWarning, variable, err.Error()+", ignored", "")
+			}
+		}
+	}
+	if engineEnv(key, "IO_MAX") != "" {
+		e.Add(config.SeverityWarning, "SYNC_"+key+"_IO_MAX", "io.max limits the whole container, not single engines, ignored", "Set the global IO_MAX instead")
+	}
+
+	// Options of the move rule do nothing for other rules
+	moveAfter, moveAfterSet, _ := engineNumber(key, "MOVE_AFTER_DAYS")
+	evictAbove, evictAboveSet, _ := engineNumber(key, "EVICT_ABOVE_PERCENT")
+	evictTo, evictToSet, _ := engineNumber(key, "EVICT_TO_PERCENT")
+	if rule != sync.RuleMove {
+		if moveAfterSet && moveAfter > 0 {
+			_, variable := engineSetting(key, "MOVE_AFTER_DAYS")
+			e.Add(config.SeverityWarning, variable, "Only the move rule removes files from the source, ignored for rule "+ruleName(rule), "Set SYNC_"+key+"_RULE=move or remove it")
+		}
+		if evictAboveSet && evictAbove > 0 {
+			_, variable := engineSetting(key, "EVICT_ABOVE_PERCENT")
+			e.Add(config.SeverityWarning, variable, "Eviction needs the move rule, ignored for rule "+ruleName(rule), "Set SYNC_"+key+"_RULE=move or remove it")
+		}
+	}
+	if evictAboveSet && evictToSet && evictTo > evictAbove {
+		_, variable := engineSetting(key, "EVICT_TO_PERCENT")
+		e.Add(config.SeverityError, variable, fmt.Sprintf("Eviction stops at %g%%, above where it starts (%g%%)", evictTo, evictAbove), "Set EVICT_TO_PERCENT below EVICT_ABOVE_PERCENT")
+	}
+}
+
+func ruleName(rule string) string {
+	if rule == "" {
+		return "standard"
+	}
+	return rule
+}
+
+// logConfigReport logs the validation report at startup
+func logConfigReport(r *config.Report) {
+	for _, p := range r.Global {
+		logProblem(p, "")
+	}
+	for _, e := range r.Engines {
+		for _, p := range e.Problems {
+			logProblem(p, e.ID)
+		}
+		if e.OK {
+			logger.Info("Engine configuration OK", "engine", e.ID, "warnings", len(e.Problems))
+		} else {
+			logger.Error("Engine configuration FAILED, see the problems above", "engine", e.ID, "source", e.Source, "target", e.Target)
+		}
+	}
+	if !r.OK {
+		logger.Error("Configuration has errors; GET /api/config/validate or run `monitor validate` for the full report")
+	}
+}
+
+func logProblem(p config.Problem, engine string) {
+	args := []any{"variable", p.Variable}
+	if engine != "" {
+		args = append(args, "engine", engine)
+	}
+	if p.Fix != "" {
+		args = append(args, "fix", p.Fix)
+	}
+	if p.Severity == config.SeverityError {
+		logger.Error(p.Message, args...)
+	} else {
+		logger.Warn(p.Message, args...)
+	}
+}
+
+// RunValidate implements the `validate` command: it prints the report of the
+// configuration in the environment and returns 1 when it has errors
+func RunValidate(out io.Writer) int {
+	r := validateConfig()
+	for _, p := range r.Global {
+		printProblem(out, "", p)
+	}
+	for _, e := range r.Engines {
+		status := "PASS"
+		if !e.OK {
+			status = "FAIL"
+		}
+		_, _ = fmt.Fprintf(out, "[%s] Engine %s: %s -> %s\n", status, e.ID, e.Source, e.Target)
+		for _, p := range e.Problems {
+			printProblem(out, "    ", p)
+		}
+	}
+	if !r.OK {
+		_, _ = fmt.Fprintln(out, "Configuration has errors")
+		return 1
+	}
+	_, _ = fmt.Fprintln(out, "Configuration OK")
+	return 0
+}
+
+func printProblem(out io.Writer, indent string, p config.Problem) {
+	_, _ = fmt.Fprintf(out, "%s%s: %s: %s\n", indent, strings.ToUpper(p.Severity), p.Variable, p.Message)
+	if p.Fix != "" {
+		_, _ = fmt.Fprintf(out, "%s    fix: %s\n", indent, p.Fix)
+	}
+}
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"schnorarr/internal/monitor/config"
+)
+
+func findProblem(problems []config.Problem, variable string) *config.Problem {
+	for i := range problems {
+		if problems[i].Variable == variable {
+			return &problems[i]
+		}
+	}
+	return nil
+}
+
+func TestValidateConfig(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	t.Setenv("MODE", "sender")
+	t.Setenv("SYNC_1_SOURCE", src)
+	t.Setenv("SYNC_1_TARGET", dst)
+	t.Setenv("SYNC_2_SOURCE", filepath.Join(src, "missing"))
+	t.Setenv("SYNC_2_TARGET", "user@::module/path")
+	t.Setenv("SYNC_2_RULE", "move")
+	t.Setenv("SYNC_2_EVICT_ABOVE_PERCENT", "80")
+	t.Setenv("SYNC_2_EVICT_TO_PERCENT", "90")
+	t.Setenv("SYNC_3_SOURCE", src)
+	t.Setenv("SYNC_3_TARGET", src)
+	t.Setenv("SYNC_3_MOVE_AFTER_DAYS", "7")
+	t.Setenv("SYNC_3_IO_MAX", "wbps=52428800")
+	t.Setenv("SYNC_4_SOURCE", src)
+	t.Setenv("TRANSFER_STREAMS", "many")
+
+	r := validateConfig()
+	if r.OK {
+		t.Fatal("Report should fail")
+	}
+	if p := findProblem(r.Global, "SYNC_4_SOURCE"); p == nil || p.Severity != config.SeverityError {
+		t.Errorf("SOURCE without TARGET should be reported, got %+v", r.Global)
+	}
+	if len(r.Engines) != 3 {
+		t.Fatalf("Got %d engine reports, want 3", len(r.Engines))
+	}
+
+	byID := make(map[string]*config.EngineReport)
+	for _, e := range r.Engines {
+		byID[e.ID] = e
+	}
+	if e := byID["1"]; !e.OK || len(e.Problems) != 1 || e.Problems[0].Variable != "TRANSFER_STREAMS" {
+		t.Errorf("Engine 1 should pass with the global TRANSFER_STREAMS warning, got %+v", e.Problems)
+	}
+
+	e2 := byID["2"]
+	if e2.OK {
+		t.Error("Engine 2 should fail")
+	}
+	for _, variable := range []string{"SYNC_2_SOURCE", "SYNC_2_TARGET", "SYNC_2_EVICT_TO_PERCENT"} {
+		if p := findProblem(e2.Problems, variable); p == nil || p.Severity != config.SeverityError {
+			t.Errorf("%s should be an error, got %+v", variable, e2.Problems)
+		}
+	}
+
+	e3 := byID["3"]
+	if p := findProblem(e3.Problems, "SYNC_3_TARGET"); p == nil || p.Severity != config.SeverityError {
+		t.Errorf("Source and target being the same should be an error, got %+v", e3.Problems)
+	}
+	if p := findProblem(e3.Problems, "SYNC_3_MOVE_AFTER_DAYS"); p == nil || p.Severity != config.SeverityWarning {
+		t.Errorf("MOVE_AFTER_DAYS without the move rule should be a warning, got %+v", e3.Problems)
+	}
+	if p := findProblem(e3.Problems, "SYNC_3_IO_MAX"); p == nil || p.Severity != config.SeverityWarning {
+		t.Errorf("A per-engine IO_MAX should be a warning, got %+v", e3.Problems)
+	}
+
+	var out bytes.Buffer
+	if code := RunValidate(&out); code != 1 {
+		t.Errorf("RunValidate = %d, want 1", code)
+	}
+	for _, want := range []string{"[PASS] Engine 1", "[FAIL] Engine 2", "fix: "} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Output misses %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestValidateConfig_Receiver(t *testing.T) {
+	for _, mode := range []string{"receiver", ""} {
+		t.Setenv("MODE", mode)
+		t.Setenv("SYNC_1_SOURCE", "/does/not/exist")
+		if r := validateConfig(); !r.OK || len(r.Engines) != 0 || len(r.Global) != 0 {
+			t.Errorf("MODE=%q: receivers run no engines, got %+v", mode, r)
+		}
+	}
+}
+
+func TestEngineNumber(t *testing.T) {
+	t.Setenv("TRANSFER_CHUNK_KB", "512")
+	t.Setenv("SYNC_1_CHUNK_KB", "1024")
+	t.Setenv("MAX_DELETE_PERCENT", "150")
+	if val, ok, err := engineNumber("1", "CHUNK_KB"); !ok || err != nil || val != 1024 {
+		t.Errorf("Engine setting should win, got %v %v %v", val, ok, err)
+	}
+	if val, ok, _ := engineNumber("2", "CHUNK_KB"); !ok || val != 512 {
+		t.Errorf("TRANSFER_CHUNK_KB should apply to other engines, got %v %v", val, ok)
+	}
+	if _, ok, err := engineNumber("1", "MAX_DELETE_PERCENT"); ok || err == nil {
+		t.Error("A value out of range should be rejected")
+	}
+	if _, ok, err := engineNumber("1", "DELETE_DEFER_SCANS"); ok || err != nil {
+		t.Error("An unset setting is no error")
+	}
+}
+
+func TestValidateRsyncURI(t *testing.T) {
+	for uri, ok := range map[string]bool{
+		"user@host::module/path":           true,
+		"host::module":                     true,
+		"rsync://host/module/path":         true,
+		"rsync://user@host:873/module":     true,
+		"rsync://host:99999/module":        false,
+		"rsync://host":                     false,
+		"user@::module/path":               false,
+		"user@host::":                      false,
+		"user@my host::module":             false,
+		"rsync://user@host:abc/module/dir": false,
+	} {
+		if err := validateRsyncURI(uri); (err == nil) != ok {
+			t.Errorf("validateRsyncURI(%q) = %v, want ok=%v", uri, err, ok)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	stdsync "sync"
+	"time"
+
+	"schnorarr/internal/agent"
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/sync"
+	"schnorarr/internal/wol"
+)
+
+var (
+	// wakePollInterval is how often a waking receiver is checked for /health
+	wakePollInterval = 5 * time.Second
+	// wakeResendInterval is how often the magic packet is repeated while waiting
+	wakeResendInterval = 30 * time.Second
+	// suspendCheckInterval is how often the sender checks whether the receiver may sleep again
+	suspendCheckInterval = time.Minute
+)
+
+// receiverWaker wakes a sleeping receiver via Wake-on-LAN before sync cycles
+// and, with WOL_SUSPEND_AFTER, asks it to suspend again once every engine
+// has been idle that long.
+type receiverWaker struct {
+	mac, broadcast string
+	timeout        time.Duration
+	suspendAfter   time.Duration
+	host           func() string
+	probe          func(host string) error
+	send           func(mac, broadcast string) error
+	suspend        func(host string) error
+
+	mu stdsync.Mutex // Serializes wake-ups across engines
+
+	stateMu     stdsync.Mutex
+	asleep      bool
+	suspendedAt time.Time
+	lastActive  time.Time
+}
+
+// newReceiverWaker reads the WOL_* settings; nil when WOL_MAC is not set
+func newReceiverWaker(host func() string) *receiverWaker {
+	mac := os.Getenv("WOL_MAC")
+	if mac == "" {
+		return nil
+	}
+	if _, err := wol.MagicPacket(mac); err != nil {
+		logger.Warn("Invalid WOL_MAC, Wake-on-LAN disabled", "error", err)
+		return nil
+	}
+	w := &receiverWaker{
+		mac: mac, broadcast: os.Getenv("WOL_BROADCAST"), timeout: 3 * time.Minute, host: host,
+		probe: func(host string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, err := agent.ForHost(host).Health(ctx)
+			return err
+		},
+		send: wol.Send,
+		suspend: func(host string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			_, err := agent.ForHost(host).Suspend(ctx)
+			return err
+		},
+		lastActive: time.Now(),
+	}
+	if val, err := strconv.Atoi(os.Getenv("WOL_TIMEOUT")); err == nil && val > 0 {
+		w.timeout = time.Duration(val) * time.Second
+	}
+	if val, err := strconv.ParseFloat(os.Getenv("WOL_SUSPEND_AFTER"), 64); err == nil && val > 0 {
+		w.suspendAfter = time.Duration(val * float64(time.Minute))
+	}
+	logger.Info("Wake-on-LAN enabled", "mac", mac, "timeout", w.timeout.String(), "suspend_after", w.suspendAfter.String())
+	return w
+}
+
+// Wake returns once the receiver answers /health, sending magic packets
+// until it does or the timeout passes
+func (w *receiverWaker) Wake() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	host := w.host()
+	if w.probe(host) == nil {
+		w.touch()
+		return nil
+	}
+
+	logger.Info("Receiver not answering, sending Wake-on-LAN", "host", host, "mac", w.mac)
+	start := time.Now()
+	var lastSent time.Time
+	for time.Since(start) < w.timeout {
+		if time.Since(lastSent) >= wakeResendInterval {
+			if err := w.send(w.mac, w.broadcast); err != nil {
+				return err
+			}
+			lastSent = time.Now()
+		}
+		time.Sleep(wakePollInterval)
+		if w.probe(host) == nil {
+			w.touch()
+			logger.Info("Receiver woke up", "host", host, "after", time.Since(start).Round(time.Second).String())
+			return nil
+		}
+	}
+	return fmt.Errorf("receiver %s did not answer within %s after Wake-on-LAN", host, w.timeout)
+}
+
+// Asleep reports whether the receiver was suspended by this sender and not woken since
+func (w *receiverWaker) Asleep() bool {
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+	return w.asleep
+}
+
+func (w *receiverWaker) touch() {
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+	w.asleep = false
+	w.lastActive = time.Now()
+}
+
+// checkIdle suspends the receiver once every engine has been idle for suspendAfter
+func (w *receiverWaker) checkIdle(engines []*sync.Engine) {
+	for _, e := range engines {
+		if e.IsBusy() || e.IsScanning() {
+			w.touch()
+			return
+		}
+	}
+	w.stateMu.Lock()
+	idle := !w.asleep && time.Since(w.lastActive) >= w.suspendAfter
+	w.stateMu.Unlock()
+	if !idle || !w.mu.TryLock() {
+		return // Awake and in use, already asleep, or being woken
+	}
+	defer w.mu.Unlock()
+
+	host := w.host()
+	if err := w.suspend(host); err != nil {
+		if errors.Is(err, agent.ErrUnsupported) {
+			logger.Warn("Receiver cannot suspend, set SUSPEND_COMMAND on the receiver", "host", host)
+		} else {
+			logger.Warn("Failed to suspend receiver", "host", host, "error", err)
+		}
+		w.touch() // Try again after another idle period
+		return
+	}
+	w.stateMu.Lock()
+	w.asleep = true
+	w.suspendedAt = time.Now()
+	w.stateMu.Unlock()
+	_ = database.LogSystemEvent("system", "Receiver Suspended", fmt.Sprintf("Receiver %s suspended after %s idle", host, w.suspendAfter))
+}
+
+// suspendLoop runs checkIdle until the process exits; no-op without WOL_SUSPEND_AFTER
+func (w *receiverWaker) suspendLoop(engines []*sync.Engine) {
+	if w.suspendAfter <= 0 {
+		return
+	}
+	ticker := time.NewTicker(suspendCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		w.checkIdle(engines)
+	}
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"schnorarr/internal/monitor/database"
+)
+
+func TestReceiverWaker_Wake(t *testing.T) {
+	prevPoll := wakePollInterval
+	wakePollInterval = time.Millisecond
+	defer func() { wakePollInterval = prevPoll }()
+
+	probes, sent := 0, 0
+	w := &receiverWaker{
+		mac: "00:11:22:33:44:55", timeout: time.Second, host: func() string { return "nas" },
+		probe: func(host string) error {
+			if probes++; probes < 4 {
+				return errors.New("connection refused")
+			}
+			return nil
+		},
+		send: func(mac, broadcast string) error { sent++; return nil },
+	}
+	if err := w.Wake(); err != nil {
+		t.Fatalf("Wake failed: %v", err)
+	}
+	if sent != 1 || probes != 4 {
+		t.Errorf("Expected one magic packet and four probes, got %d and %d", sent, probes)
+	}
+	if err := w.Wake(); err != nil || sent != 1 {
+		t.Errorf("An awake receiver should not get another packet (err %v, sent %d)", err, sent)
+	}
+
+	w.timeout = 20 * time.Millisecond
+	w.probe = func(host string) error { return errors.New("down") }
+	if err := w.Wake(); err == nil {
+		t.Error("Expected a timeout for a receiver that never answers")
+	}
+}
+
+func TestReceiverWaker_SuspendWhenIdle(t *testing.T) {
+	database.DBPath = filepath.Join(t.TempDir(), "wake.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+
+	var suspended []string
+	w := &receiverWaker{
+		suspendAfter: time.Minute, host: func() string { return "nas" },
+		probe:   func(host string) error { return nil },
+		suspend: func(host string) error { suspended = append(suspended, host); return nil },
+	}
+	w.lastActive = time.Now()
+	w.checkIdle(nil)
+	if len(suspended) != 0 || w.Asleep() {
+		t.Fatal("Receiver should stay awake before the idle period passed")
+	}
+
+	w.lastActive = time.Now().Add(-2 * time.Minute)
+	w.checkIdle(nil)
+	if len(suspended) != 1 || !w.Asleep() {
+		t.Fatalf("Idle receiver should be suspended, got %v", suspended)
+	}
+	w.checkIdle(nil)
+	if len(suspended) != 1 {
+		t.Error("A suspended receiver should not be suspended again")
+	}
+
+	if err := w.Wake(); err != nil || w.Asleep() {
+		t.Errorf("Wake should clear the asleep state (err %v)", err)
+	}
+}
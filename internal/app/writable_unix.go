@@ -0,0 +1,14 @@
+//go:build !windows
+
+package app
+
+import "syscall"
+
+// accessWrite is W_OK of access(2)
+const accessWrite = 0x2
+
+// dirWritable checks that dir can be written to without writing to it; it
+// also fails on read-only mounts
+func dirWritable(dir string) error {
+	return syscall.Access(dir, accessWrite)
+}
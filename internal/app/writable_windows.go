@@ -0,0 +1,20 @@
+//go:build windows
+
+package app
+
+import (
+	"fmt"
+	"os"
+)
+
+// dirWritable checks the write permission of dir without writing to it
+func dirWritable(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if info.Mode().Perm()&0200 == 0 {
+		return fmt.Errorf("read-only directory")
+	}
+	return nil
+}
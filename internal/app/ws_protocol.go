@@ -0,0 +1,111 @@
+package app
+
+//go:generate go run ../../cmd/wsschema ../ui/web/static/js/protocol.d.ts
+
+import (
+	"encoding/json"
+
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/monitor/errclass"
+	"schnorarr/internal/monitor/smart"
+	"schnorarr/internal/monitor/system"
+	"schnorarr/internal/monitor/websocket"
+	"schnorarr/internal/sync"
+)
+
+// Topics the dashboard subscribes to. Payload changes must be reflected in
+// protocol.d.ts (go generate ./internal/app) and, when incompatible, bump
+// websocket.ProtocolVersion.
+var (
+	topicHistory    = websocket.NewTopic[database.HistoryItem]("history")
+	topicStats      = websocket.NewTopic[database.TrafficStats]("stats")
+	topicDaily      = websocket.NewTopic[[]database.DailyTraffic]("daily")
+	topicProgress   = websocket.NewTopic[Progress]("progress")
+	topicSyncStatus = websocket.NewTopic[SyncStatus]("sync_status")
+	// Snapshot of every engine, sent on connect and when engines come or go
+	topicEngines = websocket.NewTopic[[]EngineProgress]("engines")
+	// Fields of engines that changed since the last snapshot or delta
+	topicEngineDelta = websocket.NewTopic[[]EngineDelta]("engine_delta")
+)
+
+// Progress is the dashboard state the status broadcaster sends every 3 seconds
+type Progress struct {
+	Speed           string                 `json:"speed"`
+	State           string                 `json:"state"` // ACTIVE, PAUSED or SYNCING
+	ETA             string                 `json:"eta"`
+	Latency         int64                  `json:"latency"`
+	LatencyStats    *database.LatencyStats `json:"latency_stats"` // Over the last checks, nil before the first
+	TopFiles        []database.HistoryItem `json:"top_files"`
+	ReceiverHealthy bool                   `json:"receiver_healthy"`
+	ReceiverMsg     string                 `json:"receiver_msg"`
+	ReceiverError   *errclass.Class        `json:"receiver_error,omitempty"` // ReceiverMsg classified, when it matches a known failure
+	ReceiverVersion string                 `json:"receiver_version"`
+	ReceiverUptime  string                 `json:"receiver_uptime"`
+	ReceiverHost    string                 `json:"receiver_host"`
+	ReceiverDisks   []smart.Disk           `json:"receiver_disks"`
+	System          system.Metrics         `json:"system"`
+	ReceiverSystem  *system.Metrics        `json:"receiver_system"`
+	ReceiverLoad    *system.Load           `json:"receiver_load"`
+	ReceiverBusy    string                 `json:"receiver_busy,omitempty"` // Why transfers are paced for the receiver
+	TrafficToday    string                 `json:"traffic_today"`
+	TrafficTotal    string                 `json:"traffic_total"`
+	Quota           quotaStatus            `json:"quota"`
+	CostToday       string                 `json:"cost_today"`
+	CostCeiling     *quotaStatus           `json:"cost_ceiling"`
+	Maintenance     []database.Maintenance `json:"maintenance"`
+	Groups          []sync.GroupSummary    `json:"groups"`
+	LastError       string                 `json:"last_error,omitempty"` // The last sync error, until a sync succeeds
+	LastErrorClass  *errclass.Class        `json:"last_error_class,omitempty"`
+}
+
+// EngineProgress is the state of one engine in Progress
+type EngineProgress struct {
+	ID                string              `json:"id"`
+	File              string              `json:"file"`
+	Percent           float64             `json:"percent"`
+	Speed             string              `json:"speed"`
+	Today             string              `json:"today"`
+	Total             string              `json:"total"`
+	IsActive          bool                `json:"is_active"`
+	ETA               string              `json:"eta"`
+	QueueCount        int                 `json:"queue_count"`
+	IsScanning        bool                `json:"is_scanning"`
+	AvgSpeed          string              `json:"avg_speed"`
+	Elapsed           string              `json:"elapsed"`
+	SpeedHistory      []int64             `json:"speed_history"`
+	IsPaused          bool                `json:"is_paused"`
+	LastSync          string              `json:"last_sync"`
+	IsRemoteScan      bool                `json:"is_remote_scan"`
+	IsWaitingApproval bool                `json:"is_waiting_approval"`
+	Cycle             string              `json:"cycle,omitempty"`
+	IsOffline         bool                `json:"is_offline"`
+	IsReadOnly        bool                `json:"is_read_only"`    // Offline because the receiver is write-protected
+	TargetDiverged    bool                `json:"target_diverged"` // The receiver's integrity report does not match the last sync
+	Backlog           int                 `json:"backlog"`
+	BacklogSize       string              `json:"backlog_size"`
+	BacklogOverflow   bool                `json:"backlog_overflow"`
+	Quota             string              `json:"quota,omitempty"`
+	QuotaPercent      float64             `json:"quota_percent"`
+	QuotaPaused       bool                `json:"quota_paused"`
+	ContainerPaused   string              `json:"container_paused,omitempty"` // Containers a paused engine waits for
+	InMaintenance     bool                `json:"in_maintenance"`
+	Groups            []string            `json:"groups,omitempty"`
+	BandwidthLimit    string              `json:"bandwidth_limit,omitempty"` // Canonical applied limit, e.g. "25 Mbit/s"; omitted when unlimited
+	SpeedFloor        string              `json:"speed_floor,omitempty"`     // Set while the engine transfers below its speed floor
+	Breaker           *sync.BreakerStats  `json:"breaker,omitempty"`         // Set while the engine is FAILED by its circuit breaker
+	BreakerError      *errclass.Class     `json:"breaker_error,omitempty"`   // The error that tripped the breaker, classified
+	Cleanup           *sync.PhaseProgress `json:"cleanup,omitempty"`         // Set while the engine applies renames or deletions
+}
+
+// EngineDelta holds the changed fields of an engine, keyed like EngineProgress.
+// Fields omitted by EngineProgress are sent with their zero value.
+type EngineDelta struct {
+	ID      string                     `json:"id"`
+	Changes map[string]json.RawMessage `json:"changes"`
+}
+
+// SyncStatus is the headline status of the sender
+type SyncStatus struct {
+	Status  string `json:"status"`
+	Engines int    `json:"engines"`
+}
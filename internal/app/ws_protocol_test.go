@@ -0,0 +1,24 @@
+package app
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"schnorarr/internal/monitor/websocket"
+)
+
+// protocol.d.ts must match the payload types; run go generate ./internal/app
+func TestProtocolDefinitionsUpToDate(t *testing.T) {
+	var buf bytes.Buffer
+	if err := websocket.WriteTypeScript(&buf); err != nil {
+		t.Fatal(err)
+	}
+	current, err := os.ReadFile("../ui/web/static/js/protocol.d.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(current, buf.Bytes()) {
+		t.Error("protocol.d.ts is outdated, run go generate ./internal/app")
+	}
+}
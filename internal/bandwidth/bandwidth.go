@@ -0,0 +1,165 @@
+// Package bandwidth parses and formats transfer rate limits.
+package bandwidth
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Rate is a transfer rate in bytes per second; 0 means unlimited
+type Rate int64
+
+// Unlimited is the rate of an unlimited transfer
+const Unlimited Rate = 0
+
+// maxRate bounds parsed rates (1 TB/s) so typos fail instead of overflowing
+const maxRate = 1e12
+
+// prefixes are the multipliers of unit prefixes, decimal unless binary
+var prefixes = map[string]float64{
+	"": 1, "k": 1e3, "m": 1e6, "g": 1e9, "t": 1e12,
+	"ki": 1 << 10, "mi": 1 << 20, "gi": 1 << 30,
+}
+
+// Parse reads a rate such as "25mbit", "25 Mbit/s", "4MB/s", "512KiB/s" or
+// "unlimited". A lowercase b is a bit and an uppercase B a byte ("mbps" and
+// "Mbps" are megabits, "MBps" megabytes). Bare numbers are Mbit/s, the unit
+// of BWLIMIT_MBPS.
+func Parse(s string) (Rate, error) {
+	in := strings.TrimSpace(s)
+	switch strings.ToLower(in) {
+	case "":
+		return 0, fmt.Errorf("empty bandwidth limit")
+	case "unlimited", "none", "off", "0":
+		return Unlimited, nil
+	}
+	end := strings.LastIndexAny(in, "0123456789.") + 1
+	value, err := strconv.ParseFloat(in[:end], 64)
+	if err != nil || value < 0 || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("invalid bandwidth limit %q", s)
+	}
+	bits, mult, ok := unit(strings.TrimSpace(in[end:]))
+	if !ok {
+		return 0, fmt.Errorf("invalid bandwidth limit %q: unknown unit (use e.g. 25mbit, 4MB/s or unlimited)", s)
+	}
+	rate := value * mult
+	if bits {
+		rate /= 8
+	}
+	switch {
+	case value == 0:
+		return Unlimited, nil
+	case rate < 1:
+		return 0, fmt.Errorf("invalid bandwidth limit %q: below 1 byte/s", s)
+	case rate > maxRate:
+		return 0, fmt.Errorf("invalid bandwidth limit %q: above 1 TB/s", s)
+	}
+	return Rate(math.Round(rate)), nil
+}
+
+// unit returns whether u counts bits and the multiplier of its prefix
+func unit(u string) (bits bool, mult float64, ok bool) {
+	if u == "" {
+		return true, 1e6, true // Mbit/s
+	}
+	u = strings.TrimSuffix(strings.TrimSuffix(u, "/s"), "/S")
+	lower := strings.ToLower(u)
+	for _, word := range []struct {
+		suffix string
+		bits   bool
+	}{{"bits", true}, {"bit", true}, {"bytes", false}, {"byte", false}} {
+		if strings.HasSuffix(lower, word.suffix) {
+			mult, ok = prefixes[strings.TrimSpace(lower[:len(lower)-len(word.suffix)])]
+			return word.bits, mult, ok
+		}
+	}
+	if strings.HasSuffix(lower, "ps") {
+		u = u[:len(u)-2]
+	}
+	if u == "" {
+		return false, 0, false
+	}
+	switch u[len(u)-1] {
+	case 'b':
+		bits = true
+	case 'B':
+	default:
+		return false, 0, false
+	}
+	mult, ok = prefixes[strings.ToLower(strings.TrimSpace(u[:len(u)-1]))]
+	return bits, mult, ok
+}
+
+// FromMbps converts megabits per second
+func FromMbps(mbps float64) Rate {
+	return Rate(math.Round(mbps * 1e6 / 8))
+}
+
+// Mbps returns the rate in megabits per second
+func (r Rate) Mbps() float64 {
+	return float64(r) * 8 / 1e6
+}
+
+// String returns the canonical form: bits per second with a decimal
+// prefix, e.g. "25 Mbit/s", or "unlimited"
+func (r Rate) String() string {
+	if r <= 0 {
+		return "unlimited"
+	}
+	bits := float64(r) * 8
+	for _, u := range []struct {
+		name string
+		mult float64
+	}{{"Gbit/s", 1e9}, {"Mbit/s", 1e6}, {"kbit/s", 1e3}} {
+		if bits >= u.mult {
+			return strconv.FormatFloat(math.Round(bits/u.mult*100)/100, 'f', -1, 64) + " " + u.name
+		}
+	}
+	return strconv.FormatFloat(bits, 'f', -1, 64) + " bit/s"
+}
+
+// MarshalJSON writes the canonical form
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// UnmarshalJSON reads a string with units or, like older configs, a number of Mbit/s
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	var mbps float64
+	if err := json.Unmarshal(data, &mbps); err == nil {
+		if mbps < 0 {
+			return fmt.Errorf("invalid bandwidth limit %v", mbps)
+		}
+		*r = FromMbps(mbps)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid bandwidth limit %s", data)
+	}
+	rate, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*r = rate
+	return nil
+}
+
+// FromEnv reads the global limit from BWLIMIT, or the older BWLIMIT_MBPS.
+// Unset means unlimited; set tells whether either variable is set.
+func FromEnv() (rate Rate, set bool, err error) {
+	for _, key := range []string{"BWLIMIT", "BWLIMIT_MBPS"} {
+		if v := os.Getenv(key); v != "" {
+			rate, err = Parse(v)
+			if err != nil {
+				return Unlimited, true, fmt.Errorf("%s: %w", key, err)
+			}
+			return rate, true, nil
+		}
+	}
+	return Unlimited, false, nil
+}
@@ -0,0 +1,71 @@
+package bandwidth
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for in, want := range map[string]Rate{
+		"25mbit":     3125000,
+		"25 Mbit/s":  3125000,
+		"25Mbps":     3125000,
+		"25mbps":     3125000,
+		"4MB/s":      4000000,
+		"4MBps":      4000000,
+		"512KiB/s":   512 * 1024,
+		"1.5 Gbit":   187500000,
+		"800 kbit/s": 100000,
+		"100":        12500000, // Bare numbers are Mbit/s
+		"unlimited":  Unlimited,
+		"0":          Unlimited,
+		"0 MB/s":     Unlimited,
+		"OFF":        Unlimited,
+	} {
+		got, err := Parse(in)
+		if err != nil || got != want {
+			t.Errorf("Parse(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "fast", "-5mbit", "25 mph", "25 m", "4 megabytes", "0.000001 bit/s", "99999 Tbit"} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) should fail", in)
+		}
+	}
+}
+
+func TestRateString(t *testing.T) {
+	for r, want := range map[Rate]string{
+		Unlimited:      "unlimited",
+		3125000:        "25 Mbit/s",
+		4000000:        "32 Mbit/s",
+		10 * (1 << 20): "83.89 Mbit/s",
+		187500000:      "1.5 Gbit/s",
+		100000:         "800 kbit/s",
+		100:            "800 bit/s",
+	} {
+		if got := r.String(); got != want {
+			t.Errorf("Rate(%d).String() = %q, want %q", r, got, want)
+		}
+	}
+}
+
+func TestRateJSON(t *testing.T) {
+	var v struct {
+		Old Rate `json:"old"`
+		New Rate `json:"new"`
+	}
+	if err := json.Unmarshal([]byte(`{"old": 100, "new": "4MB/s"}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Old != FromMbps(100) || v.New != 4000000 {
+		t.Errorf("Unexpected rates %+v", v)
+	}
+	out, _ := json.Marshal(v)
+	if string(out) != `{"old":"100 Mbit/s","new":"32 Mbit/s"}` {
+		t.Errorf("Unexpected JSON %s", out)
+	}
+	if err := json.Unmarshal([]byte(`{"new": "lots"}`), &v); err == nil {
+		t.Error("Invalid limits should be rejected")
+	}
+}
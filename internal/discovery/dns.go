@@ -0,0 +1,204 @@
+package discovery
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+)
+
+// DNS record types and classes used by mDNS service discovery
+const (
+	typeA   = 1
+	typePTR = 12
+	typeTXT = 16
+	typeSRV = 33
+	typeANY = 255
+
+	classIN         = 1
+	classCacheFlush = 0x8000 // Set on unique records in responses
+	classUnicast    = 0x8000 // Set on questions that want a unicast answer
+)
+
+var errMalformed = errors.New("malformed DNS message")
+
+type question struct {
+	name  string
+	qtype uint16
+}
+
+type record struct {
+	name  string
+	rtype uint16
+	class uint16
+	ttl   uint32
+
+	// Decoded rdata, depending on rtype
+	target string   // PTR, SRV
+	port   uint16   // SRV
+	txt    []string // TXT
+	ip     net.IP   // A
+}
+
+type message struct {
+	response  bool
+	questions []question
+	records   []record // Answers and additional records
+}
+
+// pack encodes a message without name compression
+func (m *message) pack() []byte {
+	b := make([]byte, 12, 512)
+	if m.response {
+		binary.BigEndian.PutUint16(b[2:], 0x8400) // Response, authoritative
+	}
+	binary.BigEndian.PutUint16(b[4:], uint16(len(m.questions)))
+	binary.BigEndian.PutUint16(b[6:], uint16(len(m.records)))
+	for _, q := range m.questions {
+		b = appendName(b, q.name)
+		b = binary.BigEndian.AppendUint16(b, q.qtype)
+		b = binary.BigEndian.AppendUint16(b, classIN|classUnicast)
+	}
+	for _, r := range m.records {
+		b = appendName(b, r.name)
+		b = binary.BigEndian.AppendUint16(b, r.rtype)
+		b = binary.BigEndian.AppendUint16(b, r.class)
+		b = binary.BigEndian.AppendUint32(b, r.ttl)
+		lenAt := len(b)
+		b = append(b, 0, 0)
+		switch r.rtype {
+		case typePTR:
+			b = appendName(b, r.target)
+		case typeSRV:
+			b = append(b, 0, 0, 0, 0) // Priority, weight
+			b = binary.BigEndian.AppendUint16(b, r.port)
+			b = appendName(b, r.target)
+		case typeTXT:
+			for _, s := range r.txt {
+				if len(s) > 255 {
+					s = s[:255]
+				}
+				b = append(b, byte(len(s)))
+				b = append(b, s...)
+			}
+		case typeA:
+			b = append(b, r.ip.To4()...)
+		}
+		binary.BigEndian.PutUint16(b[lenAt:], uint16(len(b)-lenAt-2))
+	}
+	return b
+}
+
+func appendName(b []byte, name string) []byte {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		if len(label) > 63 {
+			label = label[:63]
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+// parse decodes a message, following name compression pointers
+func parse(msg []byte) (*message, error) {
+	if len(msg) < 12 {
+		return nil, errMalformed
+	}
+	m := &message{response: msg[2]&0x80 != 0}
+	qd := int(binary.BigEndian.Uint16(msg[4:]))
+	rrs := int(binary.BigEndian.Uint16(msg[6:])) + int(binary.BigEndian.Uint16(msg[8:])) + int(binary.BigEndian.Uint16(msg[10:]))
+	off := 12
+	for i := 0; i < qd; i++ {
+		name, next, err := readName(msg, off)
+		if err != nil || next+4 > len(msg) {
+			return nil, errMalformed
+		}
+		m.questions = append(m.questions, question{name: name, qtype: binary.BigEndian.Uint16(msg[next:])})
+		off = next + 4
+	}
+	for i := 0; i < rrs; i++ {
+		name, next, err := readName(msg, off)
+		if err != nil || next+10 > len(msg) {
+			return nil, errMalformed
+		}
+		r := record{
+			name:  name,
+			rtype: binary.BigEndian.Uint16(msg[next:]),
+			class: binary.BigEndian.Uint16(msg[next+2:]),
+			ttl:   binary.BigEndian.Uint32(msg[next+4:]),
+		}
+		start := next + 10
+		end := start + int(binary.BigEndian.Uint16(msg[next+8:]))
+		if end > len(msg) {
+			return nil, errMalformed
+		}
+		rdata := msg[start:end]
+		switch r.rtype {
+		case typePTR:
+			r.target, _, err = readName(msg, start)
+		case typeSRV:
+			if len(rdata) < 7 {
+				return nil, errMalformed
+			}
+			r.port = binary.BigEndian.Uint16(rdata[4:])
+			r.target, _, err = readName(msg, start+6)
+		case typeTXT:
+			for j := 0; j < len(rdata); {
+				n := int(rdata[j])
+				if j+1+n > len(rdata) {
+					return nil, errMalformed
+				}
+				r.txt = append(r.txt, string(rdata[j+1:j+1+n]))
+				j += 1 + n
+			}
+		case typeA:
+			if len(rdata) == 4 {
+				r.ip = net.IPv4(rdata[0], rdata[1], rdata[2], rdata[3])
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		m.records = append(m.records, r)
+		off = end
+	}
+	return m, nil
+}
+
+// readName decodes a possibly compressed name at off and returns the offset after it
+func readName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	next := -1
+	for jumps := 0; ; {
+		if off >= len(msg) {
+			return "", 0, errMalformed
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.Join(labels, ".") + ".", next, nil
+		case n&0xC0 == 0xC0:
+			if off+1 >= len(msg) || jumps > 10 {
+				return "", 0, errMalformed
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3FFF)
+			jumps++
+		default:
+			if off+1+n > len(msg) {
+				return "", 0, errMalformed
+			}
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
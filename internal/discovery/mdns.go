@@ -0,0 +1,278 @@
+// Package discovery advertises receivers on the LAN via mDNS/DNS-SD and lets
+// senders browse for them, so DEST_HOST and DEST_MODULE can be picked from a
+// list instead of typed by hand.
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"schnorarr/internal/monitor/logging"
+)
+
+var logger = logging.For("discovery")
+
+// ServiceType is the DNS-SD service receivers register
+const ServiceType = "_schnorarr._tcp.local."
+
+// recordTTL is the TTL of advertised records in seconds
+const recordTTL = 120
+
+var mdnsAddr = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// Service describes an advertised receiver
+type Service struct {
+	Instance string   // Instance name, the hostname by default
+	Host     string   // Host name without .local
+	Port     int      // HTTP port of the agent API
+	Modules  []string // Rsync modules offered
+	Protocol int      // Agent protocol version
+}
+
+// Receiver is a receiver found by Browse
+type Receiver struct {
+	Instance string   `json:"instance"`
+	Host     string   `json:"host"`
+	Addrs    []string `json:"addrs"`
+	Port     int      `json:"port"`
+	Modules  []string `json:"modules"`
+	Protocol int      `json:"protocol,omitempty"`
+}
+
+// Advertiser answers mDNS queries for a Service
+type Advertiser struct {
+	svc  Service
+	conn *net.UDPConn
+}
+
+// Advertise joins the mDNS group and answers queries for svc until Close
+func Advertise(svc Service) (*Advertiser, error) {
+	if svc.Host == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get hostname: %w", err)
+		}
+		svc.Host = strings.SplitN(host, ".", 2)[0]
+	}
+	if svc.Instance == "" {
+		svc.Instance = svc.Host
+	}
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to join mDNS group: %w", err)
+	}
+	a := &Advertiser{svc: svc, conn: conn}
+	// Announce once so running browsers pick the receiver up immediately
+	if _, err := conn.WriteToUDP(a.response(localAddrs()), mdnsAddr); err != nil {
+		logger.Debug("mDNS announcement failed", "error", err)
+	}
+	go a.serve()
+	logger.Info("Advertising receiver via mDNS", "instance", svc.Instance, "modules", svc.Modules)
+	return a, nil
+}
+
+// Close stops advertising
+func (a *Advertiser) Close() error { return a.conn.Close() }
+
+func (a *Advertiser) serve() {
+	buf := make([]byte, 9000)
+	for {
+		n, src, err := a.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		reply := a.answer(buf[:n])
+		if reply == nil {
+			continue
+		}
+		// Queries from other ports are one-shot (legacy unicast) and get a
+		// direct answer; everything else is answered on the group
+		dst := mdnsAddr
+		if src.Port != mdnsAddr.Port {
+			dst = src
+		}
+		if _, err := a.conn.WriteToUDP(reply, dst); err != nil {
+			logger.Debug("mDNS reply failed", "error", err)
+		}
+	}
+}
+
+// answer returns the response to a query packet, or nil if it is not for us
+func (a *Advertiser) answer(packet []byte) []byte {
+	m, err := parse(packet)
+	if err != nil || m.response {
+		return nil
+	}
+	for _, q := range m.questions {
+		name := strings.ToLower(q.name)
+		if ((q.qtype == typePTR || q.qtype == typeANY) && name == ServiceType) ||
+			name == strings.ToLower(a.instanceName()) {
+			return a.response(localAddrs())
+		}
+	}
+	return nil
+}
+
+func (a *Advertiser) instanceName() string {
+	return a.svc.Instance + "." + ServiceType
+}
+
+// response builds the PTR, SRV, TXT and A records of the service
+func (a *Advertiser) response(addrs []net.IP) []byte {
+	instance := a.instanceName()
+	hostName := a.svc.Host + ".local."
+	txt := []string{"modules=" + strings.Join(a.svc.Modules, ",")}
+	if a.svc.Protocol > 0 {
+		txt = append(txt, "agent="+strconv.Itoa(a.svc.Protocol))
+	}
+	m := &message{response: true, records: []record{
+		{name: ServiceType, rtype: typePTR, class: classIN, ttl: recordTTL, target: instance},
+		{name: instance, rtype: typeSRV, class: classIN | classCacheFlush, ttl: recordTTL, target: hostName, port: uint16(a.svc.Port)},
+		{name: instance, rtype: typeTXT, class: classIN | classCacheFlush, ttl: recordTTL, txt: txt},
+	}}
+	for _, ip := range addrs {
+		m.records = append(m.records, record{name: hostName, rtype: typeA, class: classIN | classCacheFlush, ttl: recordTTL, ip: ip})
+	}
+	return m.pack()
+}
+
+// localAddrs returns the IPv4 addresses of the up, non-loopback interfaces
+func localAddrs() []net.IP {
+	var ips []net.IP
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+				ips = append(ips, ipNet.IP.To4())
+			}
+		}
+	}
+	return ips
+}
+
+// Browse queries the LAN for receivers and collects answers until timeout
+func Browse(ctx context.Context, timeout time.Duration) ([]Receiver, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mDNS socket: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	query := (&message{questions: []question{{name: ServiceType, qtype: typePTR}}}).pack()
+	if _, err := conn.WriteToUDP(query, mdnsAddr); err != nil {
+		return nil, fmt.Errorf("failed to send mDNS query: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetReadDeadline(deadline)
+	var records []record
+	buf := make([]byte, 9000)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			break // Deadline reached
+		}
+		if m, err := parse(buf[:n]); err == nil && m.response {
+			records = append(records, m.records...)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return collect(records), nil
+}
+
+// collect assembles receivers from the records of all responses
+func collect(records []record) []Receiver {
+	srv := make(map[string]record)
+	txt := make(map[string][]string)
+	addrs := make(map[string][]string)
+	var instances []string
+	seen := make(map[string]bool)
+	for _, r := range records {
+		name := strings.ToLower(r.name)
+		switch r.rtype {
+		case typePTR:
+			if name == ServiceType && !seen[strings.ToLower(r.target)] {
+				seen[strings.ToLower(r.target)] = true
+				instances = append(instances, r.target)
+			}
+		case typeSRV:
+			srv[name] = r
+		case typeTXT:
+			txt[name] = r.txt
+		case typeA:
+			ip := r.ip.String()
+			if !containsString(addrs[name], ip) {
+				addrs[name] = append(addrs[name], ip)
+			}
+		}
+	}
+
+	var receivers []Receiver
+	for _, instance := range instances {
+		s, ok := srv[strings.ToLower(instance)]
+		if !ok {
+			continue
+		}
+		rcv := Receiver{
+			Instance: strings.TrimSuffix(instance, "."+ServiceType),
+			Host:     strings.TrimSuffix(strings.TrimSuffix(s.target, "."), ".local"),
+			Addrs:    addrs[strings.ToLower(s.target)],
+			Port:     int(s.port),
+		}
+		for _, kv := range txt[strings.ToLower(instance)] {
+			key, val, _ := strings.Cut(kv, "=")
+			switch key {
+			case "modules":
+				if val != "" {
+					rcv.Modules = strings.Split(val, ",")
+				}
+			case "agent":
+				rcv.Protocol, _ = strconv.Atoi(val)
+			}
+		}
+		receivers = append(receivers, rcv)
+	}
+	sort.Slice(receivers, func(i, j int) bool { return receivers[i].Instance < receivers[j].Instance })
+	return receivers
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRsyncModules returns the module names declared in an rsyncd.conf
+func ParseRsyncModules(data string) []string {
+	var modules []string
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			if name := strings.TrimSpace(line[1 : len(line)-1]); name != "" && name != "global" {
+				modules = append(modules, name)
+			}
+		}
+	}
+	return modules
+}
@@ -0,0 +1,66 @@
+package discovery
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestAdvertiser_AnswersBrowseQuery(t *testing.T) {
+	a := &Advertiser{svc: Service{Instance: "nas", Host: "nas", Port: 8080, Modules: []string{"video-sync", "music"}, Protocol: 1}}
+	query := (&message{questions: []question{{name: ServiceType, qtype: typePTR}}}).pack()
+	reply := a.answer(query)
+	if reply == nil {
+		t.Fatal("Expected an answer to a service browse query")
+	}
+	m, err := parse(reply)
+	if err != nil || !m.response {
+		t.Fatalf("Failed to parse reply: %v", err)
+	}
+
+	// The reply built from fixed addresses must round trip into a receiver
+	m, _ = parse(a.response([]net.IP{net.IPv4(192, 168, 1, 50)}))
+	got := collect(m.records)
+	want := []Receiver{{Instance: "nas", Host: "nas", Addrs: []string{"192.168.1.50"}, Port: 8080, Modules: []string{"video-sync", "music"}, Protocol: 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	other := (&message{questions: []question{{name: "_http._tcp.local.", qtype: typePTR}}}).pack()
+	if a.answer(other) != nil {
+		t.Error("Queries for other services should be ignored")
+	}
+	if a.answer(reply) != nil {
+		t.Error("Responses should never be answered")
+	}
+}
+
+func TestParse_CompressedNames(t *testing.T) {
+	// Header, one answer: PTR _schnorarr._tcp.local -> nas.<pointer to offset 12>
+	msg := []byte{0, 0, 0x84, 0, 0, 0, 0, 1, 0, 0, 0, 0}
+	msg = appendName(msg, ServiceType)
+	msg = append(msg, 0, typePTR, 0, classIN, 0, 0, 0, 120, 0, 6)
+	msg = append(msg, 3, 'n', 'a', 's', 0xC0, 12)
+	m, err := parse(msg)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(m.records) != 1 || m.records[0].target != "nas."+ServiceType {
+		t.Errorf("Unexpected records: %+v", m.records)
+	}
+
+	if _, err := parse(msg[:len(msg)-3]); err == nil {
+		t.Error("Truncated message should fail")
+	}
+	loop := []byte{0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0xC0, 12, 0, 12, 0, 1}
+	if _, err := parse(loop); err == nil {
+		t.Error("Pointer loops should fail")
+	}
+}
+
+func TestParseRsyncModules(t *testing.T) {
+	conf := "port = 873\n[global]\n[video-sync]\n    path = /data\n\n[ music ]\npath = /music\n"
+	if got := ParseRsyncModules(conf); !reflect.DeepEqual(got, []string{"video-sync", "music"}) {
+		t.Errorf("Unexpected modules: %v", got)
+	}
+}
@@ -0,0 +1,175 @@
+package alerting
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/monitor/logging"
+	"schnorarr/internal/monitor/notification"
+)
+
+var logger = logging.For("alerting")
+
+// SendFunc delivers a notification to a channel ("" = all channels)
+type SendFunc func(channel, msg, level string)
+
+// Manager turns sync errors into notifications according to the alert rules
+// in the database. Without rules every error notifies, as before rules existed.
+type Manager struct {
+	send  SendFunc
+	rules func() ([]database.AlertRule, error)
+	now   func() time.Time
+
+	mu    sync.Mutex
+	state map[string]*ruleState // By rule name
+}
+
+type ruleState struct {
+	rule      database.AlertRule // As of the last failure
+	failures  []time.Time
+	firing    time.Time // Zero while not firing
+	escalated bool
+	lastError string
+}
+
+// Status is the state of one rule
+type Status struct {
+	Rule      string     `json:"rule"`
+	Failures  int        `json:"failures"`
+	Firing    bool       `json:"firing"`
+	Since     *time.Time `json:"since,omitempty"`
+	Escalated bool       `json:"escalated"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// New creates a manager that notifies through send
+func New(send SendFunc) *Manager {
+	return &Manager{send: send, rules: database.GetAlertRules, now: time.Now, state: make(map[string]*ruleState)}
+}
+
+// Error records a failure and fires every rule whose threshold it reaches
+func (m *Manager) Error(msg string) {
+	rules, err := m.rules()
+	if err != nil {
+		logger.Error("Failed to load alert rules", "error", err)
+	}
+	if len(rules) == 0 {
+		go m.send("", notification.Render(notification.EventError, notification.Vars{Error: msg}), "CRITICAL")
+		return
+	}
+
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range rules {
+		if r.Match != "" && !strings.Contains(strings.ToLower(msg), strings.ToLower(r.Match)) {
+			continue
+		}
+		st := m.ruleState(r.Name)
+		st.rule, st.lastError = r, msg
+		st.failures = append(st.failures, now)
+		if r.WindowMinutes > 0 {
+			cutoff := now.Add(-time.Duration(r.WindowMinutes) * time.Minute)
+			for len(st.failures) > 0 && st.failures[0].Before(cutoff) {
+				st.failures = st.failures[1:]
+			}
+		}
+		threshold := max(r.Threshold, 1)
+		if !st.firing.IsZero() || len(st.failures) < threshold {
+			continue
+		}
+		st.firing = now
+		logger.Warn("Alert fired", "rule", r.Name, "failures", len(st.failures))
+		vars := notification.Vars{Rule: r.Name, Error: msg}
+		if threshold > 1 {
+			vars.Failures = len(st.failures)
+			if r.WindowMinutes > 0 {
+				vars.Window = fmt.Sprintf("%d min", r.WindowMinutes)
+			}
+		}
+		go m.send(r.Channel, notification.Render(notification.EventAlert, vars), "ERROR")
+	}
+}
+
+// Resolve ends every firing alert after a successful sync. Failures of rules
+// without a window only count until the next success.
+func (m *Manager) Resolve() {
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name, st := range m.state {
+		if st.firing.IsZero() {
+			if st.rule.WindowMinutes <= 0 {
+				delete(m.state, name)
+			}
+			continue
+		}
+		delete(m.state, name)
+		logger.Info("Alert resolved", "rule", name)
+		if !st.rule.NotifyResolve {
+			continue
+		}
+		text := notification.Render(notification.EventAlertResolved, notification.Vars{Rule: name, Duration: now.Sub(st.firing).Round(time.Second).String()})
+		go m.send(st.rule.Channel, text, "SUCCESS")
+		if st.escalated && st.rule.EscalateChannel != st.rule.Channel {
+			go m.send(st.rule.EscalateChannel, text, "SUCCESS")
+		}
+	}
+}
+
+// escalate notifies the escalation channel of alerts unresolved for too long
+func (m *Manager) escalate() {
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name, st := range m.state {
+		if st.firing.IsZero() || st.escalated || st.rule.EscalateAfterMinutes <= 0 {
+			continue
+		}
+		open := now.Sub(st.firing)
+		if open < time.Duration(st.rule.EscalateAfterMinutes)*time.Minute {
+			continue
+		}
+		st.escalated = true
+		logger.Warn("Alert escalated", "rule", name, "open", open.Round(time.Second).String())
+		text := notification.Render(notification.EventAlertEscalated, notification.Vars{Rule: name, Duration: open.Round(time.Minute).String(), Error: st.lastError})
+		go m.send(st.rule.EscalateChannel, text, "ERROR")
+	}
+}
+
+// Run checks for escalations until the process exits
+func (m *Manager) Run() {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.escalate()
+	}
+}
+
+// Status returns the state of every rule that saw failures
+func (m *Manager) Status() []Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status := make([]Status, 0, len(m.state))
+	for name, st := range m.state {
+		s := Status{Rule: name, Failures: len(st.failures), Firing: !st.firing.IsZero(), Escalated: st.escalated, LastError: st.lastError}
+		if s.Firing {
+			since := st.firing
+			s.Since = &since
+		}
+		status = append(status, s)
+	}
+	return status
+}
+
+func (m *Manager) ruleState(name string) *ruleState {
+	st, ok := m.state[name]
+	if !ok {
+		st = &ruleState{}
+		m.state[name] = st
+	}
+	return st
+}
@@ -0,0 +1,105 @@
+package alerting
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"schnorarr/internal/monitor/database"
+)
+
+type sent struct{ channel, msg, level string }
+
+func newTestManager(rules []database.AlertRule) (*Manager, chan sent, *time.Time) {
+	out := make(chan sent, 10)
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	m := New(func(channel, msg, level string) { out <- sent{channel, msg, level} })
+	m.rules = func() ([]database.AlertRule, error) { return rules, nil }
+	m.now = func() time.Time { return now }
+	return m, out, &now
+}
+
+func expect(t *testing.T, out chan sent, want string) sent {
+	t.Helper()
+	select {
+	case s := <-out:
+		if !strings.Contains(s.msg, want) {
+			t.Errorf("Expected notification containing %q, got %q", want, s.msg)
+		}
+		return s
+	case <-time.After(time.Second):
+		t.Fatalf("Expected notification containing %q", want)
+	}
+	return sent{}
+}
+
+func expectNone(t *testing.T, out chan sent) {
+	t.Helper()
+	select {
+	case s := <-out:
+		t.Errorf("Unexpected notification %q", s.msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestManager_WithoutRulesEveryErrorNotifies(t *testing.T) {
+	m, out, _ := newTestManager(nil)
+	m.Error("disk full")
+	if s := expect(t, out, "System Error: disk full"); s.channel != "" || s.level != "CRITICAL" {
+		t.Errorf("Unexpected notification %+v", s)
+	}
+}
+
+func TestManager_ThresholdWindow(t *testing.T) {
+	m, out, now := newTestManager([]database.AlertRule{{Name: "flaky", Threshold: 3, WindowMinutes: 10, Channel: "discord"}})
+
+	m.Error("timeout")
+	*now = now.Add(6 * time.Minute)
+	m.Error("timeout")
+	*now = now.Add(6 * time.Minute) // The first failure left the window
+	m.Error("timeout")
+	expectNone(t, out)
+
+	*now = now.Add(time.Minute)
+	m.Error("timeout")
+	if s := expect(t, out, `Alert "flaky": timeout (3 failures in 10 min)`); s.channel != "discord" {
+		t.Errorf("Expected the rule channel, got %q", s.channel)
+	}
+	m.Error("timeout")
+	expectNone(t, out) // Already firing
+}
+
+func TestManager_EscalateAndResolve(t *testing.T) {
+	m, out, now := newTestManager([]database.AlertRule{
+		{Name: "down", Match: "receiver", Channel: "discord", EscalateAfterMinutes: 60, EscalateChannel: "telegram", NotifyResolve: true},
+	})
+
+	m.Error("disk full") // Not matched
+	expectNone(t, out)
+	m.Error("Receiver unreachable")
+	expect(t, out, `Alert "down"`)
+
+	*now = now.Add(30 * time.Minute)
+	m.escalate()
+	expectNone(t, out)
+	*now = now.Add(31 * time.Minute)
+	m.escalate()
+	if s := expect(t, out, "ESCALATED"); s.channel != "telegram" {
+		t.Errorf("Expected escalation to telegram, got %q", s.channel)
+	}
+	m.escalate()
+	expectNone(t, out)
+
+	m.Resolve()
+	got := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		s := expect(t, out, `Resolved "down" after 1h1m0s`)
+		got[s.channel] = true
+	}
+	if !got["discord"] || !got["telegram"] {
+		t.Errorf("Expected the resolve message on both channels, got %v", got)
+	}
+	if len(m.Status()) != 0 {
+		t.Errorf("Expected no state after resolve, got %+v", m.Status())
+	}
+}
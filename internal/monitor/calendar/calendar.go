@@ -0,0 +1,108 @@
+// Package calendar writes iCalendar (RFC 5545) feeds so calendar apps can
+// subscribe to the sync schedule.
+package calendar
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Event is one calendar entry. Weekly events repeat on the given days.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	End         time.Time
+	// Floating events keep their wall clock time in every time zone, as the
+	// schedule is defined in the local time of the sender
+	Floating bool
+	Weekly   []time.Weekday // Repeat days, nil for a single event
+}
+
+var icalDays = [7]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// Write renders the events as a VCALENDAR named name
+func Write(w io.Writer, name string, events []Event, now time.Time) error {
+	b := &builder{}
+	b.line("BEGIN:VCALENDAR")
+	b.line("VERSION:2.0")
+	b.line("PRODID:-//schnorarr//sync schedule//EN")
+	b.line("CALSCALE:GREGORIAN")
+	b.line("X-WR-CALNAME:" + escape(name))
+	for _, e := range events {
+		b.line("BEGIN:VEVENT")
+		b.line("UID:" + e.UID)
+		b.line("DTSTAMP:" + now.UTC().Format("20060102T150405Z"))
+		b.line("DTSTART:" + formatTime(e.Start, e.Floating))
+		b.line("DTEND:" + formatTime(e.End, e.Floating))
+		if len(e.Weekly) > 0 {
+			days := append([]time.Weekday(nil), e.Weekly...)
+			sort.Slice(days, func(i, j int) bool { return days[i] < days[j] })
+			names := make([]string, len(days))
+			for i, d := range days {
+				names[i] = icalDays[d]
+			}
+			b.line("RRULE:FREQ=WEEKLY;BYDAY=" + strings.Join(names, ","))
+		}
+		b.line("SUMMARY:" + escape(e.Summary))
+		if e.Description != "" {
+			b.line("DESCRIPTION:" + escape(e.Description))
+		}
+		b.line("TRANSP:TRANSPARENT") // Informational, never blocks the day as busy
+		b.line("END:VEVENT")
+	}
+	b.line("END:VCALENDAR")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func formatTime(t time.Time, floating bool) string {
+	if floating {
+		return t.Format("20060102T150405")
+	}
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// escape quotes the characters RFC 5545 reserves in text values
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+type builder struct {
+	strings.Builder
+}
+
+// line writes a content line, folded at 75 octets as the RFC requires
+func (b *builder) line(s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && s[cut]&0xC0 == 0x80 { // Do not split a UTF-8 sequence
+			cut--
+		}
+		b.WriteString(s[:cut] + "\r\n ")
+		s = s[cut:]
+		limit = 74 // Continuation lines start with the folding space
+	}
+	b.WriteString(s + "\r\n")
+}
+
+// NextWeekly returns the first occurrence at or after the start of day of
+// from that falls on one of days, at hh:mm wall clock time
+func NextWeekly(from time.Time, days [7]bool, hm string) (time.Time, error) {
+	clock, err := time.Parse("15:04", hm)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", hm)
+	}
+	for i := 0; i < 7; i++ {
+		d := from.AddDate(0, 0, i)
+		if days[d.Weekday()] {
+			return time.Date(d.Year(), d.Month(), d.Day(), clock.Hour(), clock.Minute(), 0, 0, from.Location()), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no days selected")
+}
@@ -0,0 +1,64 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	start := time.Date(2024, 3, 4, 22, 0, 0, 0, time.Local)
+	var b strings.Builder
+	err := Write(&b, "Sync, schedule", []Event{
+		{UID: "w@x", Summary: "Full speed; night", Start: start, End: start.Add(8 * time.Hour), Floating: true, Weekly: []time.Weekday{time.Friday, time.Monday}},
+		{UID: "m@x", Summary: "Maintenance", Description: strings.Repeat("long text ", 20), Start: time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC), End: time.Date(2024, 3, 4, 11, 0, 0, 0, time.UTC)},
+	}, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"X-WR-CALNAME:Sync\\, schedule\r\n",
+		"DTSTART:20240304T220000\r\n",
+		"DTEND:20240305T060000\r\n",
+		"RRULE:FREQ=WEEKLY;BYDAY=MO,FR\r\n",
+		"SUMMARY:Full speed\\; night\r\n",
+		"DTSTART:20240304T100000Z\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Missing %q in\n%s", want, out)
+		}
+	}
+	for _, line := range strings.Split(out, "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("Line not folded: %q", line)
+		}
+	}
+	if !strings.Contains(out, "\r\n ") {
+		t.Error("Long description should be folded onto continuation lines")
+	}
+}
+
+func TestNextWeekly(t *testing.T) {
+	wed := time.Date(2024, 3, 6, 15, 0, 0, 0, time.UTC)
+	var weekend [7]bool
+	weekend[time.Saturday], weekend[time.Sunday] = true, true
+	got, err := NextWeekly(wed, weekend, "09:30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 3, 9, 9, 30, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	// Today counts even when the time already passed, the RRULE covers the rest
+	var daily [7]bool
+	for i := range daily {
+		daily[i] = true
+	}
+	if got, _ := NextWeekly(wed, daily, "01:00"); got.Day() != 6 {
+		t.Errorf("Expected today, got %v", got)
+	}
+	if _, err := NextWeekly(wed, [7]bool{}, "01:00"); err == nil {
+		t.Error("Expected an error without days")
+	}
+}
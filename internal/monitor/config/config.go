@@ -2,10 +2,15 @@ package config
 
 import (
 	"encoding/json"
-	"log"
 	"os"
+
+	"schnorarr/internal/bandwidth"
+	"schnorarr/internal/monitor/logging"
+	"schnorarr/internal/monitor/secretbox"
 )
 
+var logger = logging.For("config")
+
 const ConfigPath = "/config/config.json"
 
 // Config represents the application configuration
@@ -15,26 +20,43 @@ type Config struct {
 	TelegramChatID string `json:"telegram_chat_id"`
 
 	// Scheduler
-	SchedulerEnabled bool   `json:"scheduler_enabled"`
-	QuietStart       string `json:"quiet_start"`  // HH:MM
-	QuietEnd         string `json:"quiet_end"`    // HH:MM
-	QuietLimit       int    `json:"quiet_limit"`  // Mbps
-	NormalLimit      int    `json:"normal_limit"` // Mbps (Restore to this)
+	SchedulerEnabled bool           `json:"scheduler_enabled"`
+	QuietStart       string         `json:"quiet_start"`  // HH:MM
+	QuietEnd         string         `json:"quiet_end"`    // HH:MM
+	QuietLimit       bandwidth.Rate `json:"quiet_limit"`  // Numbers are Mbit/s, strings take units ("4MB/s")
+	NormalLimit      bandwidth.Rate `json:"normal_limit"` // Restore to this
 
 	// Sync
+
+	// Listening (see Listen and AgentListen)
+	ListenAddr      string `json:"listen_addr,omitempty"`       // host:port, :port or unix:/path
+	AgentListenAddr string `json:"agent_listen_addr,omitempty"` // Separate listener for the agent API
+
+	// Secrets sets credential variables (see SecretVars); values are literal,
+	// "file:/path" or "env:VAR"
+	Secrets map[string]string `json:"secrets,omitempty"`
 }
 
 // Load reads configuration from file and falls back to environment variables
 func Load() *Config {
 	cfg := &Config{}
+	secretbox.SetKey(settingsKey())
 
 	// Try to load from file
 	file, err := os.ReadFile(ConfigPath)
 	if err == nil {
 		if err := json.Unmarshal(file, cfg); err != nil {
-			log.Printf("Failed to unmarshal config: %v", err)
+			logger.Error("Failed to unmarshal config", "path", ConfigPath, "error", err)
+		} else if cfg.open() && secretbox.Enabled() {
+			// Plaintext from before SETTINGS_KEY was set
+			if err := cfg.Save(); err != nil {
+				logger.Error("Failed to encrypt settings", "path", ConfigPath, "error", err)
+			} else {
+				logger.Info("Encrypted sensitive settings", "path", ConfigPath)
+			}
 		}
 	}
+	resolveSecrets(cfg.Secrets)
 
 	// Fallback to Env if empty
 	if cfg.DiscordWebhook == "" {
@@ -50,11 +72,16 @@ func Load() *Config {
 	return cfg
 }
 
-// Save writes configuration to file
+// Save writes configuration to file, sensitive values encrypted when
+// SETTINGS_KEY is set
 func (c *Config) Save() error {
-	data, err := json.MarshalIndent(c, "", "  ")
+	sealed, err := c.sealed()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(sealed, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(ConfigPath, data, 0644)
+	return os.WriteFile(ConfigPath, data, 0600)
 }
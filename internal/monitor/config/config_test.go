@@ -2,7 +2,11 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
+
+	"schnorarr/internal/bandwidth"
+	"schnorarr/internal/monitor/secretbox"
 )
 
 func TestLoad(t *testing.T) {
@@ -39,10 +43,121 @@ func TestSave(t *testing.T) {
 	cfg := &Config{
 		DiscordWebhook:   "https://test.webhook",
 		SchedulerEnabled: true,
-		NormalLimit:      100,
+		NormalLimit:      bandwidth.FromMbps(100),
 	}
 
 	// Note: This test would need refactoring to inject the path
 	// For now, just test that Save doesn't panic
 	_ = cfg.Save() // Ignore error as /config may not exist in test environment
 }
+
+func TestResolveSecrets(t *testing.T) {
+	dir := t.TempDir()
+	passFile := filepath.Join(dir, "rsync_password")
+	if err := os.WriteFile(passFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tokenFile := filepath.Join(dir, "mqtt")
+	if err := os.WriteFile(tokenFile, []byte("mqtt-secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("RSYNC_PASSWORD", "plain")
+	t.Setenv("RSYNC_PASSWORD_FILE", passFile)
+	t.Setenv("MQTT_PASSWORD", "plain")
+	t.Setenv("HA_API_TOKEN", "")
+	t.Setenv("HASS_TOKEN", "indirect")
+	t.Setenv("CALENDAR_TOKEN", "plain-calendar")
+	t.Setenv("INTEGRITY_TOKEN", "")
+	t.Setenv("INTEGRITY_TOKEN_FILE", filepath.Join(dir, "missing"))
+	t.Setenv("CUSTOM_SECRET", "")
+
+	resolveSecrets(map[string]string{
+		"RSYNC_PASSWORD": "section",
+		"MQTT_PASSWORD":  "file:" + tokenFile,
+		"HA_API_TOKEN":   "env:HASS_TOKEN",
+		"CUSTOM_SECRET":  "literal",
+	})
+
+	for name, want := range map[string]string{
+		"RSYNC_PASSWORD":  "from-file", // _FILE wins, newline dropped
+		"MQTT_PASSWORD":   "mqtt-secret",
+		"HA_API_TOKEN":    "indirect",
+		"CALENDAR_TOKEN":  "plain-calendar",
+		"INTEGRITY_TOKEN": "", // Unreadable file, nothing else set
+		"CUSTOM_SECRET":   "literal",
+	} {
+		if got := os.Getenv(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSealedSettings(t *testing.T) {
+	secretbox.SetKey("master")
+	t.Cleanup(func() { secretbox.SetKey("") })
+
+	cfg := &Config{
+		DiscordWebhook: "https://hook",
+		TelegramChatID: "123",
+		Secrets:        map[string]string{"MQTT_PASSWORD": "pw", "HA_API_TOKEN": "env:HASS"},
+	}
+	sealed, err := cfg.sealed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !secretbox.IsSealed(sealed.DiscordWebhook) || !secretbox.IsSealed(sealed.Secrets["MQTT_PASSWORD"]) {
+		t.Errorf("Sensitive values should be sealed, got %+v", sealed)
+	}
+	if sealed.TelegramChatID != "123" || sealed.Secrets["HA_API_TOKEN"] != "env:HASS" || sealed.TelegramToken != "" {
+		t.Errorf("Other values should be kept as they are, got %+v", sealed)
+	}
+	if cfg.DiscordWebhook != "https://hook" || cfg.Secrets["MQTT_PASSWORD"] != "pw" {
+		t.Error("Sealing must not change the config in memory")
+	}
+
+	if sealed.open() {
+		t.Error("Sealed values are no plaintext")
+	}
+	if sealed.DiscordWebhook != "https://hook" || sealed.Secrets["MQTT_PASSWORD"] != "pw" {
+		t.Errorf("open should decrypt, got %+v", sealed)
+	}
+	if !cfg.open() {
+		t.Error("Plaintext values should be reported for encryption")
+	}
+
+	secretbox.SetKey("other")
+	again, _ := (&Config{DiscordWebhook: "https://hook"}).sealed()
+	secretbox.SetKey("master")
+	stored := again.DiscordWebhook
+	again.open()
+	if again.DiscordWebhook != stored {
+		t.Error("Values that do not decrypt should stay sealed")
+	}
+}
+
+func TestListen(t *testing.T) {
+	cfg := &Config{}
+	if addr := cfg.Listen(); addr != DefaultListenAddr {
+		t.Errorf("Default listen address = %q, want %q", addr, DefaultListenAddr)
+	}
+	t.Setenv("PORT", "9090")
+	if addr := cfg.Listen(); addr != ":9090" {
+		t.Errorf("PORT should set the port, got %q", addr)
+	}
+	t.Setenv("LISTEN_ADDR", "127.0.0.1:8081")
+	if addr := cfg.Listen(); addr != "127.0.0.1:8081" {
+		t.Errorf("LISTEN_ADDR should win over PORT, got %q", addr)
+	}
+	cfg.ListenAddr = "unix:/run/schnorarr.sock"
+	if addr := cfg.Listen(); addr != "unix:/run/schnorarr.sock" {
+		t.Errorf("The config file should win over the environment, got %q", addr)
+	}
+
+	if addr := cfg.AgentListen(); addr != "" {
+		t.Errorf("The agent API should share the listener by default, got %q", addr)
+	}
+	t.Setenv("AGENT_LISTEN_ADDR", ":8090")
+	if addr := cfg.AgentListen(); addr != ":8090" {
+		t.Errorf("AgentListen = %q, want :8090", addr)
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"strings"
+
+	"schnorarr/internal/monitor/logging"
+	"schnorarr/internal/monitor/secretbox"
+)
+
+// settingsKey returns the master key of the settings encryption
+// (SETTINGS_KEY_FILE or SETTINGS_KEY), masked in the log
+func settingsKey() string {
+	key, _ := secretValue("SETTINGS_KEY", nil)
+	logging.Redact(key)
+	return key
+}
+
+// sensitive returns the fields that are encrypted at rest
+func (c *Config) sensitive() map[string]*string {
+	return map[string]*string{"discord_webhook": &c.DiscordWebhook, "telegram_token": &c.TelegramToken}
+}
+
+// isReference reports whether a secrets value points elsewhere instead of
+// holding the secret
+func isReference(value string) bool {
+	return strings.HasPrefix(value, "file:") || strings.HasPrefix(value, "env:")
+}
+
+// open decrypts the sensitive values read from the config file and masks
+// them in the log. Values that do not decrypt stay sealed so a later Save
+// keeps them. It reports whether any sensitive value was stored in plaintext.
+func (c *Config) open() (plaintext bool) {
+	openValue := func(name string, value *string) {
+		if *value == "" || isReference(*value) {
+			return
+		}
+		if !secretbox.IsSealed(*value) {
+			plaintext = true
+			return
+		}
+		v, err := secretbox.Open(*value)
+		if err != nil {
+			logger.Error("Failed to decrypt setting, check SETTINGS_KEY", "setting", name, "error", err)
+			return
+		}
+		*value = v
+	}
+	for name, value := range c.sensitive() {
+		openValue(name, value)
+		if !secretbox.IsSealed(*value) && !isReference(*value) {
+			logging.Redact(*value)
+		}
+	}
+	for name, value := range c.Secrets {
+		openValue("secrets."+name, &value)
+		c.Secrets[name] = value
+	}
+	return plaintext
+}
+
+// sealed returns a copy of c with the sensitive values encrypted
+func (c *Config) sealed() (*Config, error) {
+	out := *c
+	for _, value := range out.sensitive() {
+		v, err := secretbox.Seal(*value)
+		if err != nil {
+			return nil, err
+		}
+		*value = v
+	}
+	if c.Secrets != nil {
+		out.Secrets = make(map[string]string, len(c.Secrets))
+		for name, value := range c.Secrets {
+			if !isReference(value) {
+				var err error
+				if value, err = secretbox.Seal(value); err != nil {
+					return nil, err
+				}
+			}
+			out.Secrets[name] = value
+		}
+	}
+	return &out, nil
+}
@@ -0,0 +1,31 @@
+package config
+
+import "os"
+
+// DefaultListenAddr is where the dashboard and the agent API listen by default
+const DefaultListenAddr = ":8080"
+
+// Listen returns the address of the web server: listen_addr, LISTEN_ADDR,
+// ":"+PORT or DefaultListenAddr. "unix:/path" listens on a Unix socket.
+// The environment is read on demand so a saved config keeps following it.
+func (c *Config) Listen() string {
+	if c.ListenAddr != "" {
+		return c.ListenAddr
+	}
+	if addr := os.Getenv("LISTEN_ADDR"); addr != "" {
+		return addr
+	}
+	if port := os.Getenv("PORT"); port != "" {
+		return ":" + port
+	}
+	return DefaultListenAddr
+}
+
+// AgentListen returns the separate address of the agent API (agent_listen_addr
+// or AGENT_LISTEN_ADDR), "" to serve it together with the dashboard
+func (c *Config) AgentListen() string {
+	if c.AgentListenAddr != "" {
+		return c.AgentListenAddr
+	}
+	return os.Getenv("AGENT_LISTEN_ADDR")
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"os"
+	"strings"
+
+	"schnorarr/internal/monitor/logging"
+	"schnorarr/internal/monitor/secretbox"
+)
+
+// SecretVars are the variables holding credentials. Each can also be read
+// from a file named by <VAR>_FILE (Docker secrets) or set in the "secrets"
+// section of the config file.
+var SecretVars = []string{
+	"RSYNC_PASSWORD", "ADMIN_PASS", "HA_API_TOKEN", "CALENDAR_TOKEN", "INTEGRITY_TOKEN", "AGENT_TOKEN", "METRICS_TOKEN",
+	"MQTT_PASSWORD", "TELEGRAM_BOT_TOKEN", "DISCORD_WEBHOOK_URL",
+}
+
+// publicDefaults are documented default values, masking them would only
+// garble the log
+var publicDefaults = map[string]string{"ADMIN_PASS": "schnorarr"}
+
+// resolveSecrets sets every secret variable from, in this order, its _FILE
+// variant, the secrets section of the config file or the environment, and
+// masks the values in the log. Section values can point elsewhere with
+// "file:/run/secrets/x" or "env:OTHER_VAR".
+func resolveSecrets(section map[string]string) {
+	names := append([]string(nil), SecretVars...)
+	for name := range section {
+		if !isSecretVar(name) {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		value, ok := secretValue(name, section)
+		if !ok {
+			continue
+		}
+		if err := os.Setenv(name, value); err != nil {
+			logger.Error("Failed to set secret", "variable", name, "error", err)
+			continue
+		}
+		if value != publicDefaults[name] {
+			logging.Redact(value)
+		}
+	}
+}
+
+func isSecretVar(name string) bool {
+	for _, v := range SecretVars {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+// secretValue looks up one secret; ok is false when none of the sources sets it
+func secretValue(name string, section map[string]string) (string, bool) {
+	if path := os.Getenv(name + "_FILE"); path != "" {
+		value, err := readSecretFile(path)
+		if err == nil {
+			return value, true
+		}
+		logger.Error("Failed to read secret file", "variable", name+"_FILE", "path", path, "error", err)
+	}
+	if ref, ok := section[name]; ok && ref != "" {
+		switch {
+		case secretbox.IsSealed(ref):
+			// Did not decrypt, logged by Config.open
+
+		case strings.HasPrefix(ref, "file:"):
+			path := strings.TrimPrefix(ref, "file:")
+			value, err := readSecretFile(path)
+			if err == nil {
+				return value, true
+			}
+			logger.Error("Failed to read secret file", "variable", name, "path", path, "error", err)
+		case strings.HasPrefix(ref, "env:"):
+			if value := os.Getenv(strings.TrimPrefix(ref, "env:")); value != "" {
+				return value, true
+			}
+			logger.Warn("Secret points to an empty variable", "variable", name, "ref", ref)
+		default:
+			return ref, true
+		}
+	}
+	value := os.Getenv(name)
+	return value, value != ""
+}
+
+// readSecretFile reads a secret; the trailing newline editors add is dropped
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
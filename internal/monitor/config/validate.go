@@ -0,0 +1,70 @@
+package config
+
+// Severity levels of a validation problem
+const (
+	SeverityError   = "error"   // The setting cannot work, e.g. a missing source
+	SeverityWarning = "warning" // The setting is ignored or falls back to a default
+)
+
+// Problem is one finding of the configuration validation
+type Problem struct {
+	Variable string `json:"variable,omitempty"` // Environment variable at fault
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Fix      string `json:"fix,omitempty"`
+}
+
+// EngineReport is the validation result of one engine
+type EngineReport struct {
+	ID       string    `json:"id"`
+	Key      string    `json:"key"` // N of the SYNC_N_* variables
+	Source   string    `json:"source"`
+	Target   string    `json:"target"`
+	OK       bool      `json:"ok"` // No errors; warnings may remain
+	Problems []Problem `json:"problems"`
+}
+
+// Report is the result of validating the configuration
+type Report struct {
+	OK      bool            `json:"ok"`
+	Global  []Problem       `json:"global"` // Problems not tied to one engine
+	Engines []*EngineReport `json:"engines"`
+}
+
+// NewReport returns an empty report
+func NewReport() *Report {
+	return &Report{OK: true, Global: []Problem{}, Engines: []*EngineReport{}}
+}
+
+// Add records a problem not tied to one engine
+func (r *Report) Add(severity, variable, message, fix string) {
+	r.Global = append(r.Global, Problem{Variable: variable, Severity: severity, Message: message, Fix: fix})
+	if severity == SeverityError {
+		r.OK = false
+	}
+}
+
+// Engine starts the report of an engine
+func (r *Report) Engine(id, key, source, target string) *EngineReport {
+	e := &EngineReport{ID: id, Key: key, Source: source, Target: target, OK: true, Problems: []Problem{}}
+	r.Engines = append(r.Engines, e)
+	return e
+}
+
+// Add records a problem of the engine
+func (e *EngineReport) Add(severity, variable, message, fix string) {
+	e.Problems = append(e.Problems, Problem{Variable: variable, Severity: severity, Message: message, Fix: fix})
+	if severity == SeverityError {
+		e.OK = false
+	}
+}
+
+// Finish updates OK from the engine reports
+func (r *Report) Finish() *Report {
+	for _, e := range r.Engines {
+		if !e.OK {
+			r.OK = false
+		}
+	}
+	return r
+}
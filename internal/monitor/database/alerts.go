@@ -0,0 +1,58 @@
+package database
+
+// AlertRule decides when sync errors turn into notifications
+type AlertRule struct {
+	Name                 string `json:"name"`
+	Match                string `json:"match"`                  // Only errors containing this text count ("" = all)
+	Threshold            int    `json:"threshold"`              // Failures needed to fire (default 1)
+	WindowMinutes        int    `json:"window_minutes"`         // Window the failures must fall into (0 = since the last resolve)
+	Channel              string `json:"channel"`                // Notification channel ("" = all)
+	EscalateAfterMinutes int    `json:"escalate_after_minutes"` // Escalate when still unresolved after this long (0 = never)
+	EscalateChannel      string `json:"escalate_channel"`       // Channel escalations go to ("" = all)
+	NotifyResolve        bool   `json:"notify_resolve"`         // Send a message when the next sync succeeds
+}
+
+// GetAlertRules returns the alert rules in the order they were saved
+func GetAlertRules() ([]AlertRule, error) {
+	if DB == nil {
+		return nil, nil
+	}
+	rows, err := DB.Query(`SELECT name, match, threshold, window_minutes, channel, escalate_after_minutes, escalate_channel, notify_resolve
+		FROM alert_rules ORDER BY position`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var rules []AlertRule
+	for rows.Next() {
+		var r AlertRule
+		if err := rows.Scan(&r.Name, &r.Match, &r.Threshold, &r.WindowMinutes, &r.Channel, &r.EscalateAfterMinutes, &r.EscalateChannel, &r.NotifyResolve); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// SaveAlertRules replaces the alert rules
+func SaveAlertRules(rules []AlertRule) error {
+	if DB == nil {
+		return nil
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.Exec(`DELETE FROM alert_rules`); err != nil {
+		return err
+	}
+	for i, r := range rules {
+		if _, err := tx.Exec(`INSERT INTO alert_rules (position, name, match, threshold, window_minutes, channel, escalate_after_minutes, escalate_channel, notify_resolve)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			i, r.Name, r.Match, r.Threshold, r.WindowMinutes, r.Channel, r.EscalateAfterMinutes, r.EscalateChannel, r.NotifyResolve); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Backlog holds the source changes an engine accumulated while its receiver was offline
+type Backlog struct {
+	Since    time.Time `json:"since"`
+	Paths    []string  `json:"paths"`
+	Bytes    int64     `json:"bytes"`
+	Overflow bool      `json:"overflow"` // More changes than the backlog keeps
+}
+
+// SaveBacklog stores an engine's offline backlog
+func SaveBacklog(engineID string, b *Backlog) error {
+	if DB == nil {
+		return nil
+	}
+	paths, err := json.Marshal(b.Paths)
+	if err != nil {
+		return err
+	}
+	_, err = DB.Exec(`INSERT OR REPLACE INTO engine_backlog (engine_id, offline_since, paths_json, bytes, overflow) VALUES (?, ?, ?, ?, ?)`,
+		engineID, b.Since.Unix(), string(paths), b.Bytes, b.Overflow)
+	return err
+}
+
+// LoadBacklog returns an engine's offline backlog, or nil if it has none
+func LoadBacklog(engineID string) (*Backlog, error) {
+	if DB == nil {
+		return nil, nil
+	}
+	var since int64
+	var paths string
+	b := &Backlog{}
+	err := DB.QueryRow(`SELECT offline_since, paths_json, bytes, overflow FROM engine_backlog WHERE engine_id = ?`, engineID).
+		Scan(&since, &paths, &b.Bytes, &b.Overflow)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	b.Since = time.Unix(since, 0)
+	if err := json.Unmarshal([]byte(paths), &b.Paths); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// ClearBacklog removes an engine's offline backlog after the catch-up sync
+func ClearBacklog(engineID string) error {
+	if DB == nil {
+		return nil
+	}
+	_, err := DB.Exec(`DELETE FROM engine_backlog WHERE engine_id = ?`, engineID)
+	return err
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"encoding/json"
+	"math"
+
+	"schnorarr/internal/bandwidth"
+)
+
+// BandwidthWindow is one row of the bandwidth schedule
+type BandwidthWindow struct {
+	Name  string         `json:"name"`
+	Days  string         `json:"days"`  // e.g. "mon-fri", "sat,sun", "weekend", "*"
+	Start string         `json:"start"` // HH:MM
+	End   string         `json:"end"`   // HH:MM, before Start for windows crossing midnight
+	Limit bandwidth.Rate `json:"limit"` // 0 = unlimited
+}
+
+// bandwidthWindowJSON also carries limit_mbps, the limit of older clients
+type bandwidthWindowJSON struct {
+	Name      string          `json:"name"`
+	Days      string          `json:"days"`
+	Start     string          `json:"start"`
+	End       string          `json:"end"`
+	Limit     *bandwidth.Rate `json:"limit,omitempty"`
+	LimitMbps *float64        `json:"limit_mbps,omitempty"`
+}
+
+// MarshalJSON writes the canonical limit and its value in Mbit/s
+func (w BandwidthWindow) MarshalJSON() ([]byte, error) {
+	mbps := math.Round(w.Limit.Mbps()*100) / 100
+	return json.Marshal(bandwidthWindowJSON{Name: w.Name, Days: w.Days, Start: w.Start, End: w.End, Limit: &w.Limit, LimitMbps: &mbps})
+}
+
+// UnmarshalJSON reads limit (with units) or the older limit_mbps
+func (w *BandwidthWindow) UnmarshalJSON(data []byte) error {
+	var in bandwidthWindowJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	*w = BandwidthWindow{Name: in.Name, Days: in.Days, Start: in.Start, End: in.End}
+	switch {
+	case in.Limit != nil:
+		w.Limit = *in.Limit
+	case in.LimitMbps != nil:
+		w.Limit = bandwidth.FromMbps(*in.LimitMbps)
+		if *in.LimitMbps < 0 {
+			w.Limit = -1 // Rejected by validation
+		}
+	}
+	return nil
+}
+
+// GetBandwidthSchedule returns the schedule in priority order
+func GetBandwidthSchedule() ([]BandwidthWindow, error) {
+	if DB == nil {
+		return nil, nil
+	}
+	rows, err := DB.Query(`SELECT name, days, start_time, end_time, limit_bytes FROM bandwidth_schedule ORDER BY position`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var windows []BandwidthWindow
+	for rows.Next() {
+		var w BandwidthWindow
+		if err := rows.Scan(&w.Name, &w.Days, &w.Start, &w.End, &w.Limit); err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, rows.Err()
+}
+
+// SaveBandwidthSchedule replaces the schedule
+func SaveBandwidthSchedule(windows []BandwidthWindow) error {
+	if DB == nil {
+		return nil
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.Exec(`DELETE FROM bandwidth_schedule`); err != nil {
+		return err
+	}
+	for i, w := range windows {
+		if _, err := tx.Exec(`INSERT INTO bandwidth_schedule (position, name, days, start_time, end_time, limit_mbps, limit_bytes) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			i, w.Name, w.Days, w.Start, w.End, int(math.Round(w.Limit.Mbps())), int64(w.Limit)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,88 @@
+package database
+
+import "database/sql"
+
+// BenchmarkResult is the measured throughput of one transfer setting combination
+type BenchmarkResult struct {
+	Streams    int     `json:"streams"`
+	ChunkSize  int     `json:"chunk_size"`
+	Compress   bool    `json:"compress"`
+	Bytes      int64   `json:"bytes"`
+	Seconds    float64 `json:"seconds"`
+	Throughput int64   `json:"throughput"` // Bytes per second
+	Error      string  `json:"error,omitempty"`
+	Best       bool    `json:"best"`
+}
+
+// BenchmarkRun holds the results of one benchmark of an engine
+type BenchmarkRun struct {
+	EngineID string            `json:"engine_id"`
+	RunAt    int64             `json:"run_at"`
+	Results  []BenchmarkResult `json:"results"`
+}
+
+// BestResult returns the result marked as best, or nil if every case failed
+func (r *BenchmarkRun) BestResult() *BenchmarkResult {
+	for i := range r.Results {
+		if r.Results[i].Best {
+			return &r.Results[i]
+		}
+	}
+	return nil
+}
+
+// SaveBenchmarkRun replaces the stored benchmark of an engine
+func SaveBenchmarkRun(run *BenchmarkRun) error {
+	if DB == nil {
+		return nil
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err = tx.Exec(`DELETE FROM benchmark_results WHERE engine_id = ?`, run.EngineID); err != nil {
+		return err
+	}
+	for _, r := range run.Results {
+		_, err = tx.Exec(`INSERT INTO benchmark_results (engine_id, run_at, streams, chunk_size, compress, bytes, seconds, throughput, error, best)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			run.EngineID, run.RunAt, r.Streams, r.ChunkSize, r.Compress, r.Bytes, r.Seconds, r.Throughput, r.Error, r.Best)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// LoadBenchmarkRun returns the stored benchmark of an engine, or nil if it was never benchmarked
+func LoadBenchmarkRun(engineID string) (*BenchmarkRun, error) {
+	if DB == nil {
+		return nil, nil
+	}
+	rows, err := DB.Query(`SELECT run_at, streams, chunk_size, compress, bytes, seconds, throughput, error, best
+		FROM benchmark_results WHERE engine_id = ? ORDER BY id`, engineID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	run := &BenchmarkRun{EngineID: engineID}
+	for rows.Next() {
+		var r BenchmarkResult
+		var errMsg sql.NullString
+		if err := rows.Scan(&run.RunAt, &r.Streams, &r.ChunkSize, &r.Compress, &r.Bytes, &r.Seconds, &r.Throughput, &errMsg, &r.Best); err != nil {
+			return nil, err
+		}
+		r.Error = errMsg.String
+		run.Results = append(run.Results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(run.Results) == 0 {
+		return nil, nil
+	}
+	return run, nil
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// FileChecksum is the SHA-256 of one version of a transferred file
+type FileChecksum struct {
+	Path    string
+	Size    int64
+	ModTime int64 // Unix seconds
+	SHA256  string
+}
+
+// SaveFileChecksum stores the checksum of a file, replacing the one of an earlier version
+func SaveFileChecksum(engineID string, c FileChecksum) error {
+	if DB == nil {
+		return nil
+	}
+	_, err := DB.Exec(`INSERT OR REPLACE INTO file_checksums (engine_id, path, size, mod_time, sha256) VALUES (?, ?, ?, ?, ?)`,
+		engineID, c.Path, c.Size, c.ModTime, c.SHA256)
+	return err
+}
+
+// GetFileChecksum returns the stored checksum of a file; nil when there is none
+func GetFileChecksum(engineID, path string) (*FileChecksum, error) {
+	if DB == nil {
+		return nil, nil
+	}
+	c := FileChecksum{Path: path}
+	err := DB.QueryRow(`SELECT size, mod_time, sha256 FROM file_checksums WHERE engine_id = ? AND path = ?`,
+		engineID, path).Scan(&c.Size, &c.ModTime, &c.SHA256)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// DeleteFileChecksum forgets the checksum of a file removed from the target
+func DeleteFileChecksum(engineID, path string) error {
+	if DB == nil {
+		return nil
+	}
+	_, err := DB.Exec(`DELETE FROM file_checksums WHERE engine_id = ? AND path = ?`, engineID, path)
+	return err
+}
+
+// RenameFileChecksum moves the checksum of a file renamed on the target
+func RenameFileChecksum(engineID, oldPath, newPath string) error {
+	if DB == nil {
+		return nil
+	}
+	_, err := DB.Exec(`UPDATE OR REPLACE file_checksums SET path = ? WHERE engine_id = ? AND path = ?`, newPath, engineID, oldPath)
+	return err
+}
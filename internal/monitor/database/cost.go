@@ -0,0 +1,39 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// CostPerGB is the price of one GB (decimal) transferred over a metered link,
+// read from COST_PER_GB; 0 disables cost estimates
+func CostPerGB() float64 {
+	rate, err := strconv.ParseFloat(os.Getenv("COST_PER_GB"), 64)
+	if err != nil || rate < 0 {
+		return 0
+	}
+	return rate
+}
+
+// EstimateCost returns the cost of transferring bytes at CostPerGB
+func EstimateCost(bytes int64) float64 {
+	return float64(bytes) / 1e9 * CostPerGB()
+}
+
+// FormatCost formats an amount in COST_CURRENCY (default €)
+func FormatCost(cost float64) string {
+	currency := os.Getenv("COST_CURRENCY")
+	if currency == "" {
+		currency = "€"
+	}
+	return fmt.Sprintf("%s%.2f", currency, cost)
+}
+
+// CostLabel is the formatted estimated cost of bytes, "" without COST_PER_GB
+func CostLabel(bytes int64) string {
+	if CostPerGB() <= 0 {
+		return ""
+	}
+	return FormatCost(EstimateCost(bytes))
+}
@@ -0,0 +1,205 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// tableMigrations maps every table to the migrations that create and extend it
+var tableMigrations = map[string][]int{
+	"history":                {1, 6, 11, 19},
+	"settings":               {1},
+	"traffic":                {1},
+	"engine_stats":           {3},
+	"engine_pending_actions": {4},
+	"engine_conflicts":       {4},
+	"engine_queue":           {4, 8},
+	"engine_state":           {4},
+	"engine_missing_paths":   {5},
+	"benchmark_results":      {7},
+	"engine_backlog":         {9},
+	"bandwidth_schedule":     {10, 29},
+	"cycle_traffic":          {12, 20},
+	"engine_outcomes":        {13},
+	"engine_incidents":       {13},
+	"alert_rules":            {14},
+	"maintenance":            {15},
+	"plan_snapshots":         {16},
+	"undo_actions":           {17},
+	"engine_renames":         {18},
+	"traffic_hourly":         {21},
+	"traffic_monthly":        {22},
+	"read_only_schedule":     {23},
+	"engine_target_cache":    {24},
+	"engine_synced_manifest": {25},
+	"media_validation":       {26},
+	"file_checksums":         {27},
+	"parity_sets":            {28},
+	"latency_stats":          {30},
+	"sessions":               {31, 32},
+}
+
+// EngineStateTables hold per-engine runtime state that is meaningless once the engine is gone
+var EngineStateTables = []string{"engine_state", "engine_pending_actions", "engine_conflicts", "engine_queue", "engine_missing_paths", "benchmark_results", "engine_backlog", "engine_outcomes", "engine_incidents", "plan_snapshots", "undo_actions", "engine_target_cache", "engine_synced_manifest", "media_validation", "file_checksums", "parity_sets"}
+
+// IntegrityCheck runs PRAGMA integrity_check and returns the reported problems
+func IntegrityCheck() ([]string, error) {
+	if DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	rows, err := DB.Query(`PRAGMA integrity_check`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, err
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	return problems, rows.Err()
+}
+
+// MissingTables returns the expected tables that do not exist
+func MissingTables() ([]string, error) {
+	if DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	var missing []string
+	for table := range tableMigrations {
+		var name string
+		err := DB.QueryRow(`SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&name)
+		if err != nil {
+			missing = append(missing, table)
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
+
+// RepairSchema re-runs the migrations that create the given tables
+func RepairSchema(tables []string) error {
+	if DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	for _, table := range tables {
+		for _, version := range tableMigrations[table] {
+			if _, err := DB.Exec(`DELETE FROM schema_migrations WHERE version = ?`, version); err != nil {
+				return err
+			}
+		}
+	}
+	return runMigrations()
+}
+
+// EngineIDs returns the distinct engine IDs that have rows in table
+func EngineIDs(table string) ([]string, error) {
+	if DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	rows, err := DB.Query(fmt.Sprintf(`SELECT DISTINCT engine_id FROM %s ORDER BY engine_id`, table))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// DeleteEngineRows removes all rows of an engine from an engine state table
+func DeleteEngineRows(table, engineID string) error {
+	if DB == nil {
+		return nil
+	}
+	_, err := DB.Exec(fmt.Sprintf(`DELETE FROM %s WHERE engine_id = ?`, table), engineID)
+	return err
+}
+
+// InvalidQueues returns the engines whose queued manifest cannot be decoded.
+// Encoded manifests are checked against their gzip checksum.
+func InvalidQueues() ([]string, error) {
+	if DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	rows, err := DB.Query(`SELECT engine_id, COALESCE(manifest_json, ''), manifest_blob FROM engine_queue ORDER BY engine_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		var manifest, blob []byte
+		if err := rows.Scan(&id, &manifest, &blob); err != nil {
+			return nil, err
+		}
+		if len(blob) > 0 {
+			if !validGzip(blob) {
+				ids = append(ids, id)
+			}
+		} else if !json.Valid(manifest) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, rows.Err()
+}
+
+func validGzip(data []byte) bool {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return false
+	}
+	_, err = io.Copy(io.Discard, zr)
+	return err == nil
+}
+
+// StaleApprovals returns engines flagged as waiting for approval without any pending deletions
+func StaleApprovals() ([]string, error) {
+	if DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	rows, err := DB.Query(`SELECT engine_id FROM engine_state s WHERE waiting_for_approval = 1
+		AND NOT EXISTS (SELECT 1 FROM engine_pending_actions p WHERE p.engine_id = s.engine_id) ORDER BY engine_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ResetApproval clears the waiting-for-approval flag of an engine
+func ResetApproval(engineID string) error {
+	if DB == nil {
+		return nil
+	}
+	_, err := DB.Exec(`UPDATE engine_state SET waiting_for_approval = 0 WHERE engine_id = ?`, engineID)
+	return err
+}
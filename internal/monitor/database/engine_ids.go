@@ -0,0 +1,116 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// engineSettingPrefixes are the settings stored per engine as prefix + ID
+var engineSettingPrefixes = []string{"engine_paused_", "alias_"}
+
+// RenameEngine moves everything stored under engine oldID (history, traffic,
+// state tables, maintenance and settings) to newID and records the mapping.
+// It does nothing when oldID was renamed before, so a number later reused by
+// another engine keeps its own data, or when newID already has data of its
+// own. It reports whether rows were moved.
+func RenameEngine(oldID, newID string) (bool, error) {
+	if DB == nil || oldID == newID {
+		return false, nil
+	}
+	var mapped string
+	err := DB.QueryRow(`SELECT new_id FROM engine_renames WHERE old_id = ?`, oldID).Scan(&mapped)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	tables, err := engineIDTables()
+	if err != nil {
+		return false, err
+	}
+	used, err := engineHasData(tables, newID)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	moved := false
+	if !used {
+		for _, table := range tables {
+			res, err := tx.Exec(fmt.Sprintf(`UPDATE %s SET engine_id = ? WHERE engine_id = ?`, table), newID, oldID)
+			if err != nil {
+				return false, fmt.Errorf("%s: %w", table, err)
+			}
+			if n, _ := res.RowsAffected(); n > 0 {
+				moved = true
+			}
+		}
+		if _, err := tx.Exec(`UPDATE maintenance SET scope = ? WHERE scope = ?`, newID, oldID); err != nil {
+			return false, err
+		}
+		for _, prefix := range engineSettingPrefixes {
+			if _, err := tx.Exec(`UPDATE settings SET key = ? WHERE key = ?`, prefix+newID, prefix+oldID); err != nil {
+				return false, err
+			}
+		}
+	}
+	if _, err := tx.Exec(`INSERT INTO engine_renames (old_id, new_id, renamed) VALUES (?, ?, ?)`, oldID, newID, time.Now().Unix()); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	if used {
+		logger.Warn("Engine name already has data, leaving the numeric engine's data in place", "old_id", oldID, "new_id", newID)
+	}
+	return moved, nil
+}
+
+// engineIDTables returns the tables with an engine_id column
+func engineIDTables() ([]string, error) {
+	rows, err := DB.Query(`SELECT m.name FROM sqlite_master m, pragma_table_info(m.name) c
+		WHERE m.type = 'table' AND c.name = 'engine_id' ORDER BY m.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+func engineHasData(tables []string, engineID string) (bool, error) {
+	for _, table := range tables {
+		var n int
+		if err := DB.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE engine_id = ?`, table), engineID).Scan(&n); err != nil {
+			return false, err
+		}
+		if n > 0 {
+			return true, nil
+		}
+	}
+	for _, prefix := range engineSettingPrefixes {
+		var n int
+		if err := DB.QueryRow(`SELECT COUNT(*) FROM settings WHERE key = ?`, prefix+engineID).Scan(&n); err != nil {
+			return false, err
+		}
+		if n > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenameEngine(t *testing.T) {
+	setupMigratedDB(t)
+
+	if err := LogEvent("2024-01-01 10:00:00", "Added", "/a", 10, "1", ""); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = DB.Exec("INSERT INTO traffic (date, engine_id, bytes_sent) VALUES ('2024/01/01', '1', 100)")
+	_ = SaveSetting("alias_1", "Movies")
+	_ = SaveSetting("engine_paused_1", "true")
+	if err := StartMaintenance("1", time.Hour, "", "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	moved, err := RenameEngine("1", "movies")
+	if err != nil || !moved {
+		t.Fatalf("Expected the data to move, got %v (%v)", moved, err)
+	}
+	if GetSetting("alias_movies", "") != "Movies" || GetSetting("engine_paused_movies", "") != "true" {
+		t.Error("Settings were not moved to the new ID")
+	}
+	if GetSetting("alias_1", "") != "" {
+		t.Error("Old alias setting still present")
+	}
+	if stats := GetEngineTrafficStats("movies"); stats.Total != 100 {
+		t.Errorf("Expected traffic moved to the new ID, got %+v", stats)
+	}
+	if n, _ := GetEngineHistoryCount("", []string{"movies"}); n != 1 {
+		t.Errorf("Expected history moved to the new ID, got %d rows", n)
+	}
+	if !InMaintenance("movies") {
+		t.Error("Maintenance window was not moved")
+	}
+
+	// A new engine reusing number 1 keeps its own data
+	_ = SaveSetting("alias_1", "Other")
+	if moved, err := RenameEngine("1", "movies"); err != nil || moved {
+		t.Errorf("A recorded rename must not run again, got %v (%v)", moved, err)
+	}
+	if GetSetting("alias_1", "") != "Other" {
+		t.Error("Data of the reused number was touched")
+	}
+
+	// Names that already have data are not merged into
+	_ = SaveSetting("alias_2", "Shows")
+	_ = SaveSetting("alias_tv", "TV")
+	if moved, err := RenameEngine("2", "tv"); err != nil || moved {
+		t.Errorf("Expected no move onto a used name, got %v (%v)", moved, err)
+	}
+	if GetSetting("alias_2", "") != "Shows" || GetSetting("alias_tv", "") != "TV" {
+		t.Error("Settings changed although the name was in use")
+	}
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+)
+
+// FileEvent is one entry of the timeline of a file
+type FileEvent struct {
+	Time    string `json:"time"`
+	Engine  string `json:"engine"`
+	Action  string `json:"action"` // As recorded, e.g. "DRY-Added"
+	Kind    string `json:"kind"`   // Lower case action without the DRY- prefix: added, retried, renamed, deleted, ...
+	DryRun  bool   `json:"dry_run"`
+	Path    string `json:"path"` // "old -> new" for renames
+	Bytes   int64  `json:"bytes"`
+	Size    string `json:"size"`
+	Cycle   string `json:"cycle,omitempty"`
+	Retries int    `json:"retries,omitempty"`
+}
+
+// maxFileNames bounds how many names of a file are followed through renames
+const maxFileNames = 20
+
+// renameSep separates the old and new path of rename events
+const renameSep = " -> "
+
+// GetFileHistory returns the events of path on every engine, oldest first.
+// path matches the whole recorded path or its tail ("Show/S01E05.mkv" finds
+// "TV/Show/S01E05.mkv"). Renames are followed both ways, so the timeline
+// includes what happened under the file's other names. It returns the names
+// followed and at most limit events.
+func GetFileHistory(path string, limit int) ([]string, []FileEvent, error) {
+	if DB == nil {
+		return nil, nil, nil
+	}
+	names := []string{path}
+	seenName := map[string]bool{path: true}
+	seenRow := make(map[int64]bool)
+	var events []fileEventRow
+
+	for i := 0; i < len(names); i++ {
+		rows, err := fileEvents(names[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, r := range rows {
+			if seenRow[r.id] {
+				continue
+			}
+			seenRow[r.id] = true
+			events = append(events, r)
+			if from, to, ok := strings.Cut(r.event.Path, renameSep); ok {
+				for _, name := range []string{from, to} {
+					if !seenName[name] && len(names) < maxFileNames {
+						seenName[name] = true
+						names = append(names, name)
+					}
+				}
+			}
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].ts != events[j].ts {
+			return events[i].ts < events[j].ts
+		}
+		return events[i].id < events[j].id
+	})
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	out := make([]FileEvent, len(events))
+	for i, r := range events {
+		out[i] = r.event
+	}
+	return names, out, nil
+}
+
+type fileEventRow struct {
+	id    int64
+	ts    int64
+	event FileEvent
+}
+
+// fileEvents returns the events whose path, or either side of a rename, is
+// name or ends in /name
+func fileEvents(name string) ([]fileEventRow, error) {
+	rows, err := DB.Query(`SELECT id, ts, engine_id, action, file_path, size_bytes, COALESCE(cycle_id, ''), COALESCE(retries, 0) FROM history
+		WHERE engine_id != 'SYSTEM' AND file_path LIKE ? ESCAPE '\'`, "%"+escapeLike(name)+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []fileEventRow
+	for rows.Next() {
+		var r fileEventRow
+		var ts sql.NullInt64
+		e := &r.event
+		if err := rows.Scan(&r.id, &ts, &e.Engine, &e.Action, &e.Path, &e.Bytes, &e.Cycle, &e.Retries); err != nil {
+			return nil, err
+		}
+		if !fileEventMatches(e.Path, name) {
+			continue
+		}
+		r.ts = ts.Int64
+		e.Time = displayTime(ts, "")
+		e.Size = FormatBytes(e.Bytes)
+		e.DryRun = strings.HasPrefix(e.Action, "DRY-")
+		e.Kind = strings.ToLower(strings.TrimPrefix(e.Action, "DRY-"))
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// fileEventMatches checks a recorded path against name; the LIKE pattern
+// only narrows the rows down
+func fileEventMatches(recorded, name string) bool {
+	match := func(p string) bool { return p == name || strings.HasSuffix(p, "/"+name) }
+	if from, to, ok := strings.Cut(recorded, renameSep); ok {
+		return match(from) || match(to)
+	}
+	return match(recorded)
+}
+
+// escapeLike escapes the LIKE wildcards of s for ESCAPE '\'
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestGetFileHistory(t *testing.T) {
+	setupMigratedDB(t)
+	events := []struct {
+		ts, action, path, engine, cycle string
+	}{
+		{"2024-05-01 10:00:00", "DRY-Added", "TV/Show/S01E05_draft.mkv", "1", "a"},
+		{"2024-05-01 11:00:00", "Added", "TV/Show/S01E05_draft.mkv", "1", "b"},
+		{"2024-05-01 11:00:01", "Added", "TV/Show/S01E05_draft.mkv", "1", "b"},
+		{"2024-05-02 09:00:00", "Renamed", "TV/Show/S01E05_draft.mkv -> TV/Show/S01E05.mkv", "1", "c"},
+		{"2024-05-02 09:30:00", "Added", "TV/Show/S01E05.mkv", "2", "d"},
+		{"2024-05-03 08:00:00", "Deleted", "TV/Show/S01E05.mkv", "1", "e"},
+		{"2024-05-03 08:00:00", "Added", "TV/Show/S01E05.mkv.bak", "1", "e"},
+		{"2024-05-03 08:00:00", "Added", "TV/Show/S01E05xmkv", "1", "e"},
+	}
+	for _, e := range events {
+		if err := LogEvent(e.ts, e.action, e.path, 10, e.engine, e.cycle); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = LogSystemEvent("admin", "Paused", "TV/Show/S01E05.mkv")
+
+	names, got, err := GetFileHistory("Show/S01E05.mkv", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 3 {
+		t.Errorf("Expected the name, its full path and the old name, got %v", names)
+	}
+	kinds := ""
+	for _, e := range got {
+		kinds += e.Kind + " "
+	}
+	if kinds != "added added retried renamed added deleted " {
+		t.Errorf("Unexpected timeline %q: %+v", kinds, got)
+	}
+	if !got[0].DryRun || got[2].Retries != 1 || got[4].Engine != "2" {
+		t.Errorf("Expected dry run, retry count and engine, got %+v", got)
+	}
+
+	if _, got, _ := GetFileHistory("Show/S01E05.mkv", 2); len(got) != 2 || got[1].Kind != "deleted" {
+		t.Errorf("The limit should keep the latest events, got %+v", got)
+	}
+}
@@ -1,8 +1,9 @@
 package database
 
 import (
-	"log"
+	"database/sql"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -12,33 +13,106 @@ type HistoryItem struct {
 	Action string `json:"action"`
 	Path   string `json:"path"`
 	Size   string `json:"size"`
+	Cycle  string `json:"cycle,omitempty"`
 }
 
-// LogEvent saves a sync event to the database
-func LogEvent(timestamp, action, path string, size int64, engineID string) error {
-	_, err := DB.Exec("INSERT INTO history (timestamp, action, file_path, size_bytes, engine_id) VALUES (?, ?, ?, ?, ?)",
-		timestamp, action, path, size, engineID)
+// CycleSummary describes the history of one sync cycle. Bytes is the data
+// actually transferred, PlannedBytes the sum of the file sizes it logged;
+// they differ for resumed, retried and dry-run transfers.
+type CycleSummary struct {
+	Cycle        string `json:"cycle"`
+	EngineID     string `json:"engine_id"`
+	Started      string `json:"started"`
+	Finished     string `json:"finished"`
+	Events       int    `json:"events"`
+	Bytes        int64  `json:"bytes"`
+	PlannedBytes int64  `json:"planned_bytes"`
+}
+
+// ActionRetried is recorded instead of an event that already happened in the same cycle
+const ActionRetried = "Retried"
+
+// LogEvent saves a sync event to the database. cycleID is empty for events outside a sync cycle.
+func LogEvent(timestamp, action, path string, size int64, engineID, cycleID string) error {
+	_, err := RecordEvent(timestamp, action, path, size, engineID, cycleID)
 	return err
 }
 
+// RecordEvent saves a sync event and returns the action it was recorded as.
+// timestamp is UTC in any layout ParseTimestamp reads; the current time is
+// used when it does not parse. Events are unique per cycle, engine, action
+// and path: repeating one records a single ActionRetried row without size
+// (counting the retries) so that statistics summing history sizes are not
+// inflated.
+func RecordEvent(timestamp, action, path string, size int64, engineID, cycleID string) (string, error) {
+	t, ok := ParseTimestamp(timestamp, time.UTC)
+	if !ok {
+		t = time.Now()
+	}
+	iso, epoch := Timestamp(t), t.Unix()
+	if cycleID == "" {
+		_, err := DB.Exec("INSERT INTO history (timestamp, ts, action, file_path, size_bytes, engine_id, cycle_id) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			iso, epoch, action, path, size, engineID, cycleID)
+		return action, err
+	}
+	res, err := DB.Exec(`INSERT INTO history (timestamp, ts, action, file_path, size_bytes, engine_id, cycle_id) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (engine_id, cycle_id, action, file_path) WHERE cycle_id != '' DO NOTHING`,
+		iso, epoch, action, path, size, engineID, cycleID)
+	if err != nil {
+		return action, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return action, err
+	}
+	_, err = DB.Exec(`INSERT INTO history (timestamp, ts, action, file_path, size_bytes, engine_id, cycle_id, retries) VALUES (?, ?, ?, ?, 0, ?, ?, 1)
+		ON CONFLICT (engine_id, cycle_id, action, file_path) WHERE cycle_id != '' DO UPDATE SET retries = retries + 1, timestamp = excluded.timestamp, ts = excluded.ts`,
+		iso, epoch, ActionRetried, path, engineID, cycleID)
+	return ActionRetried, err
+}
+
 // LogSystemEvent saves a system/admin event to the database
 func LogSystemEvent(user, action, details string) error {
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	log.Printf("[SYSTEM] %s: %s (%s)", user, action, details)
-	_, err := DB.Exec("INSERT INTO history (timestamp, action, file_path, size_bytes, engine_id) VALUES (?, ?, ?, ?, ?)",
-		timestamp, action, details, 0, "SYSTEM")
+	now := time.Now()
+	logger.Info(action, "user", user, "details", details)
+	_, err := DB.Exec("INSERT INTO history (timestamp, ts, action, file_path, size_bytes, engine_id) VALUES (?, ?, ?, ?, ?, ?)",
+		Timestamp(now), now.Unix(), action, details, 0, "SYSTEM")
 	return err
 }
 
 // GetHistory retrieves recent sync history with pagination
 func GetHistory(limit, offset int, query string) ([]HistoryItem, error) {
-	q := "SELECT timestamp, action, file_path, size_bytes FROM history"
-	args := []interface{}{}
+	return GetEngineHistory(limit, offset, query, nil)
+}
 
+// historyFilter builds the WHERE clause for a path search limited to
+// engineIDs (nil = all engines, empty = none)
+func historyFilter(query string, engineIDs []string) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
 	if query != "" {
-		q += " WHERE file_path LIKE ?"
+		conds = append(conds, "file_path LIKE ?")
 		args = append(args, "%"+query+"%")
 	}
+	if engineIDs != nil {
+		if len(engineIDs) == 0 {
+			conds = append(conds, "1 = 0")
+		} else {
+			conds = append(conds, "engine_id IN (?"+strings.Repeat(", ?", len(engineIDs)-1)+")")
+			for _, id := range engineIDs {
+				args = append(args, id)
+			}
+		}
+	}
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// GetEngineHistory retrieves recent sync history of some engines (nil = all) with pagination
+func GetEngineHistory(limit, offset int, query string, engineIDs []string) ([]HistoryItem, error) {
+	where, args := historyFilter(query, engineIDs)
+	q := "SELECT ts, COALESCE(timestamp, ''), action, file_path, size_bytes, COALESCE(cycle_id, '') FROM history" + where
 
 	q += " ORDER BY id DESC"
 
@@ -53,7 +127,7 @@ func GetHistory(limit, offset int, query string) ([]HistoryItem, error) {
 	}
 	defer func() {
 		if err := rows.Close(); err != nil {
-			log.Printf("Error closing history rows: %v", err)
+			logger.Warn("Error closing history rows", "error", err)
 		}
 	}()
 
@@ -61,25 +135,89 @@ func GetHistory(limit, offset int, query string) ([]HistoryItem, error) {
 	for rows.Next() {
 		var i HistoryItem
 		var sizeBytes int64
-		if err := rows.Scan(&i.Time, &i.Action, &i.Path, &sizeBytes); err != nil {
-			log.Printf("History Scan Error: %v", err)
+		var ts sql.NullInt64
+		if err := rows.Scan(&ts, &i.Time, &i.Action, &i.Path, &sizeBytes, &i.Cycle); err != nil {
+			logger.Error("History scan failed", "error", err)
 			continue
 		}
+		i.Time = displayTime(ts, i.Time)
 		i.Size = FormatBytes(sizeBytes)
 		items = append(items, i)
 	}
 	return items, nil
 }
 
-// GetHistoryCount returns the total number of history items matching the query
-func GetHistoryCount(query string) (int, error) {
-	q := "SELECT COUNT(*) FROM history"
+// GetCycleHistory returns all history rows of a sync cycle, oldest first
+func GetCycleHistory(cycleID string) ([]HistoryItem, error) {
+	rows, err := DB.Query("SELECT ts, COALESCE(timestamp, ''), action, file_path, size_bytes FROM history WHERE cycle_id = ? ORDER BY id", cycleID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Warn("Error closing history rows", "error", err)
+		}
+	}()
+
+	items := make([]HistoryItem, 0)
+	for rows.Next() {
+		i := HistoryItem{Cycle: cycleID}
+		var sizeBytes int64
+		var ts sql.NullInt64
+		if err := rows.Scan(&ts, &i.Time, &i.Action, &i.Path, &sizeBytes); err != nil {
+			return nil, err
+		}
+		i.Time = displayTime(ts, i.Time)
+		i.Size = FormatBytes(sizeBytes)
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+// GetRecentCycles returns the latest sync cycles that recorded history, optionally for one engine
+func GetRecentCycles(engineID string, limit int) ([]CycleSummary, error) {
+	q := `SELECT h.cycle_id, h.engine_id, MIN(h.ts), MAX(h.ts), COUNT(*), SUM(h.size_bytes), COALESCE(MAX(t.bytes_sent), 0)
+		FROM history h LEFT JOIN cycle_traffic t ON t.cycle_id = h.cycle_id AND t.engine_id = h.engine_id WHERE h.cycle_id != ''`
 	args := []interface{}{}
+	if engineID != "" {
+		q += " AND h.engine_id = ?"
+		args = append(args, engineID)
+	}
+	q += " GROUP BY h.cycle_id, h.engine_id ORDER BY MAX(h.id) DESC LIMIT ?"
+	args = append(args, limit)
 
-	if query != "" {
-		q += " WHERE file_path LIKE ?"
-		args = append(args, "%"+query+"%")
+	rows, err := DB.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Warn("Error closing cycle rows", "error", err)
+		}
+	}()
+
+	cycles := make([]CycleSummary, 0)
+	for rows.Next() {
+		var c CycleSummary
+		var started, finished sql.NullInt64
+		if err := rows.Scan(&c.Cycle, &c.EngineID, &started, &finished, &c.Events, &c.PlannedBytes, &c.Bytes); err != nil {
+			return nil, err
+		}
+		c.Started, c.Finished = displayTime(started, ""), displayTime(finished, "")
+		cycles = append(cycles, c)
 	}
+	return cycles, rows.Err()
+}
+
+// GetHistoryCount returns the total number of history items matching the query
+func GetHistoryCount(query string) (int, error) {
+	return GetEngineHistoryCount(query, nil)
+}
+
+// GetEngineHistoryCount counts the history rows of some engines (nil = all) matching query
+func GetEngineHistoryCount(query string, engineIDs []string) (int, error) {
+	where, args := historyFilter(query, engineIDs)
+	q := "SELECT COUNT(*) FROM history" + where
 
 	var count int
 	err := DB.QueryRow(q, args...).Scan(&count)
@@ -88,14 +226,14 @@ func GetHistoryCount(query string) (int, error) {
 
 // GetTopFiles returns the largest files synced in the last 24 hours
 func GetTopFiles() []HistoryItem {
-	q := "SELECT timestamp, action, file_path, size_bytes FROM history WHERE action='Added' AND timestamp > datetime('now', '-1 day') ORDER BY size_bytes DESC LIMIT 5"
-	rows, err := DB.Query(q)
+	q := "SELECT ts, action, file_path, size_bytes FROM history WHERE action='Added' AND ts > ? ORDER BY size_bytes DESC LIMIT 5"
+	rows, err := DB.Query(q, time.Now().Add(-24*time.Hour).Unix())
 	if err != nil {
 		return nil
 	}
 	defer func() {
 		if err := rows.Close(); err != nil {
-			log.Printf("Error closing top files rows: %v", err)
+			logger.Warn("Error closing top files rows", "error", err)
 		}
 	}()
 
@@ -103,7 +241,9 @@ func GetTopFiles() []HistoryItem {
 	for rows.Next() {
 		var i HistoryItem
 		var sz int64
-		_ = rows.Scan(&i.Time, &i.Action, &i.Path, &sz)
+		var ts sql.NullInt64
+		_ = rows.Scan(&ts, &i.Action, &i.Path, &sz)
+		i.Time = displayTime(ts, "")
 		i.Size = FormatBytes(sz)
 		items = append(items, i)
 	}
@@ -112,8 +252,11 @@ func GetTopFiles() []HistoryItem {
 
 // PruneHistory deletes history items older than the specified retention period
 func PruneHistory(days int) error {
-	cutoff := "-" + FormatInt(days) + " days"
-	_, err := DB.Exec("DELETE FROM history WHERE timestamp < date('now', ?)", cutoff)
+	cutoff := time.Now().AddDate(0, 0, -days).Unix()
+	if _, err := DB.Exec("DELETE FROM history WHERE ts < ?", cutoff); err != nil {
+		return err
+	}
+	_, err := DB.Exec("DELETE FROM cycle_traffic WHERE finished_ts < ?", cutoff)
 	return err
 }
 
@@ -20,10 +20,22 @@ func setupTestDB(t *testing.T) {
 	_, err = DB.Exec(`CREATE TABLE IF NOT EXISTS history (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     timestamp TEXT,
+    ts INTEGER,
     action TEXT,
     file_path TEXT,
     size_bytes INTEGER DEFAULT 0,
-    engine_id TEXT DEFAULT ''
+    engine_id TEXT DEFAULT '',
+    cycle_id TEXT DEFAULT '',
+    retries INTEGER DEFAULT 0
+	);
+	CREATE UNIQUE INDEX idx_history_event ON history (engine_id, cycle_id, action, file_path) WHERE cycle_id != '';
+	CREATE TABLE cycle_traffic (
+    cycle_id TEXT NOT NULL,
+    engine_id TEXT NOT NULL,
+    bytes_sent INTEGER DEFAULT 0,
+    finished TEXT,
+    finished_ts INTEGER,
+    PRIMARY KEY (cycle_id, engine_id)
 	);`)
 	if err != nil {
 		t.Fatalf("Failed to create history table: %v", err)
@@ -34,7 +46,7 @@ func TestLogEvent(t *testing.T) {
 	setupTestDB(t)
 	defer func() { _ = DB.Close() }()
 
-	err := LogEvent("2023-01-01 10:00:00", "Sync", "/path/to/file", 1234, "engine1")
+	err := LogEvent("2023-01-01 10:00:00", "Sync", "/path/to/file", 1234, "engine1", "")
 	if err != nil {
 		t.Errorf("LogEvent failed: %v", err)
 	}
@@ -58,17 +70,83 @@ func TestLogEvent(t *testing.T) {
 	}
 }
 
+func TestCycleHistory(t *testing.T) {
+	setupTestDB(t)
+	defer func() { _ = DB.Close() }()
+
+	events := []struct{ ts, path, engine, cycle string }{
+		{"2023-01-01 10:00:00", "/a", "1", "c1"},
+		{"2023-01-01 10:00:05", "/b", "1", "c1"},
+		{"2023-01-01 10:01:00", "/c", "2", "c2"},
+		{"2023-01-01 10:02:00", "/d", "1", ""},
+	}
+	for _, ev := range events {
+		if err := LogEvent(ev.ts, "Added", ev.path, 100, ev.engine, ev.cycle); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	items, err := GetCycleHistory("c1")
+	if err != nil {
+		t.Fatalf("GetCycleHistory failed: %v", err)
+	}
+	if len(items) != 2 || items[0].Path != "/a" || items[1].Path != "/b" || items[0].Cycle != "c1" {
+		t.Errorf("Unexpected cycle history: %+v", items)
+	}
+
+	cycles, err := GetRecentCycles("", 10)
+	if err != nil {
+		t.Fatalf("GetRecentCycles failed: %v", err)
+	}
+	if len(cycles) != 2 || cycles[0].Cycle != "c2" || cycles[1].Events != 2 || cycles[1].PlannedBytes != 200 || cycles[1].Bytes != 0 {
+		t.Errorf("Unexpected cycles: %+v", cycles)
+	}
+	if cycles[1].Started != "2023-01-01 10:00:00" || cycles[1].Finished != "2023-01-01 10:00:05" {
+		t.Errorf("Unexpected cycle span: %+v", cycles[1])
+	}
+
+	cycles, err = GetRecentCycles("1", 10)
+	if err != nil || len(cycles) != 1 || cycles[0].EngineID != "1" {
+		t.Errorf("Expected one cycle for engine 1, got %+v (%v)", cycles, err)
+	}
+}
+
+func TestEngineHistoryFilter(t *testing.T) {
+	setupTestDB(t)
+	defer func() { _ = DB.Close() }()
+
+	for _, ev := range []struct{ path, engine string }{{"/movies/a", "1"}, {"/movies/b", "2"}, {"/tv/c", "3"}} {
+		if err := LogEvent("2023-01-01 10:00:00", "Added", ev.path, 1, ev.engine, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	items, err := GetEngineHistory(10, 0, "movies", []string{"2", "3"})
+	if err != nil || len(items) != 1 || items[0].Path != "/movies/b" {
+		t.Errorf("Expected only /movies/b, got %+v (%v)", items, err)
+	}
+	if n, _ := GetEngineHistoryCount("", []string{"1", "3"}); n != 2 {
+		t.Errorf("Expected 2 rows for engines 1 and 3, got %d", n)
+	}
+	if n, _ := GetEngineHistoryCount("", []string{}); n != 0 {
+		t.Errorf("An empty engine list should match nothing, got %d", n)
+	}
+	if n, _ := GetHistoryCount(""); n != 3 {
+		t.Errorf("Unfiltered count should be 3, got %d", n)
+	}
+}
+
 func TestPruneHistory(t *testing.T) {
 	setupTestDB(t)
 	defer func() { _ = DB.Close() }()
 
 	// Insert old record (10 days ago)
-	_, err := DB.Exec("INSERT INTO history (timestamp, action, file_path) VALUES (date('now', '-10 days'), 'Old', '/old/file')")
+	_, err := DB.Exec("INSERT INTO history (ts, action, file_path) VALUES (strftime('%s', 'now', '-10 days'), 'Old', '/old/file')")
 	if err != nil {
 		t.Fatal(err)
 	}
 	// Insert new record (1 day ago)
-	_, err = DB.Exec("INSERT INTO history (timestamp, action, file_path) VALUES (date('now', '-1 day'), 'New', '/new/file')")
+	_, err = DB.Exec("INSERT INTO history (ts, action, file_path) VALUES (strftime('%s', 'now', '-1 day'), 'New', '/new/file')")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -97,3 +175,54 @@ func TestPruneHistory(t *testing.T) {
 		t.Errorf("Expected remaining row to be 'New', got '%s'", action)
 	}
 }
+
+func TestRecordEvent_Idempotent(t *testing.T) {
+	setupTestDB(t)
+	defer func() { _ = DB.Close() }()
+
+	for i := 0; i < 3; i++ {
+		action, err := RecordEvent("2023-01-01 10:00:00", "Added", "/a", 100, "1", "c1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := map[bool]string{true: "Added", false: ActionRetried}[i == 0]; action != want {
+			t.Errorf("Attempt %d recorded as %q, want %q", i+1, action, want)
+		}
+	}
+	// Outside a cycle and in another cycle the same path is a new event
+	_ = LogEvent("2023-01-01 10:01:00", "Added", "/a", 100, "1", "")
+	_ = LogEvent("2023-01-01 10:02:00", "Added", "/a", 100, "1", "c2")
+
+	var added, bytes, retries int64
+	_ = DB.QueryRow("SELECT COUNT(*), SUM(size_bytes) FROM history WHERE action = 'Added'").Scan(&added, &bytes)
+	_ = DB.QueryRow("SELECT retries FROM history WHERE action = ?", ActionRetried).Scan(&retries)
+	if added != 3 || bytes != 300 {
+		t.Errorf("Expected 3 Added rows with 300 bytes, got %d rows with %d bytes", added, bytes)
+	}
+	if retries != 2 {
+		t.Errorf("Expected 2 retries, got %d", retries)
+	}
+}
+
+func TestCycleTraffic_ReconcilesPlannedBytes(t *testing.T) {
+	setupTestDB(t)
+	defer func() { _ = DB.Close() }()
+
+	_ = LogEvent("2023-01-01 10:00:00", "Added", "/a", 1000, "1", "c1")
+	_ = LogEvent("2023-01-01 10:00:05", "Added", "/b", 1000, "1", "c1")
+	// /b was resumed: only half of it crossed the wire
+	if err := SaveCycleTraffic("1", "c1", 1200); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveCycleTraffic("1", "c1", 300); err != nil {
+		t.Fatal(err)
+	}
+
+	cycles, err := GetRecentCycles("1", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cycles) != 1 || cycles[0].PlannedBytes != 2000 || cycles[0].Bytes != 1500 {
+		t.Errorf("Unexpected cycles: %+v", cycles)
+	}
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"database/sql"
+	"math"
+	"time"
+)
+
+var (
+	// HealthWindow is how far back the health score looks
+	HealthWindow = 7 * 24 * time.Hour
+	// HealthHalfLife is the age at which a sync outcome counts half
+	HealthHalfLife = 24 * time.Hour
+)
+
+// Incident is a period of consecutive failed syncs of an engine
+type Incident struct {
+	ID       int64      `json:"id"`
+	EngineID string     `json:"engine_id"`
+	Started  time.Time  `json:"started"`
+	Ended    *time.Time `json:"ended,omitempty"` // nil while ongoing
+	Cause    string     `json:"cause"`           // Error that opened the incident
+	Errors   int        `json:"errors"`
+}
+
+// UptimeDay is one bar of an engine's uptime timeline
+type UptimeDay struct {
+	Date      string  `json:"date"`
+	Uptime    float64 `json:"uptime"` // Percent of the day outside incidents
+	Incidents int     `json:"incidents"`
+	Level     string  `json:"level"` // "ok", "degraded" or "down"
+}
+
+func recordOutcome(id string, ok bool, msg string) {
+	now := time.Now()
+	_, _ = DB.Exec("INSERT INTO engine_outcomes (engine_id, timestamp, ok) VALUES (?, ?, ?)", id, now.Unix(), ok)
+	_, _ = DB.Exec("DELETE FROM engine_outcomes WHERE engine_id = ? AND timestamp < ?", id, now.Add(-HealthWindow).Unix())
+
+	var open int64
+	err := DB.QueryRow("SELECT id FROM engine_incidents WHERE engine_id = ? AND ended IS NULL ORDER BY id DESC LIMIT 1", id).Scan(&open)
+	switch {
+	case ok && err == nil:
+		_, _ = DB.Exec("UPDATE engine_incidents SET ended = ? WHERE id = ?", now.Unix(), open)
+	case !ok && err == nil:
+		_, _ = DB.Exec("UPDATE engine_incidents SET errors = errors + 1 WHERE id = ?", open)
+	case !ok && err == sql.ErrNoRows:
+		_, _ = DB.Exec("INSERT INTO engine_incidents (engine_id, started, cause) VALUES (?, ?, ?)", id, now.Unix(), msg)
+	}
+}
+
+// GetEngineHealthScore returns the success rate of the engine's syncs within
+// HealthWindow, each weighted by 0.5^(age/HealthHalfLife) so that old
+// failures fade out, and the number of syncs it is based on
+func GetEngineHealthScore(id string) (float64, int) {
+	if DB == nil {
+		return 0, 0
+	}
+	now := time.Now()
+	rows, err := DB.Query("SELECT timestamp, ok FROM engine_outcomes WHERE engine_id = ? AND timestamp >= ?", id, now.Add(-HealthWindow).Unix())
+	if err != nil {
+		return 0, 0
+	}
+	defer func() { _ = rows.Close() }()
+
+	var weighted, total float64
+	samples := 0
+	for rows.Next() {
+		var ts int64
+		var ok bool
+		if err := rows.Scan(&ts, &ok); err != nil {
+			continue
+		}
+		age := now.Sub(time.Unix(ts, 0))
+		w := math.Pow(0.5, age.Hours()/HealthHalfLife.Hours())
+		total += w
+		if ok {
+			weighted += w
+		}
+		samples++
+	}
+	if total == 0 {
+		return 0, samples
+	}
+	return weighted / total, samples
+}
+
+// GetEngineIncidents returns the incidents of an engine that overlap the time since since, newest first
+func GetEngineIncidents(id string, since time.Time) ([]Incident, error) {
+	if DB == nil {
+		return nil, nil
+	}
+	rows, err := DB.Query(`SELECT id, engine_id, started, ended, COALESCE(cause, ''), errors FROM engine_incidents
+		WHERE engine_id = ? AND (ended IS NULL OR ended >= ?) ORDER BY started DESC`, id, since.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	incidents := make([]Incident, 0)
+	for rows.Next() {
+		var inc Incident
+		var started int64
+		var ended sql.NullInt64
+		if err := rows.Scan(&inc.ID, &inc.EngineID, &started, &ended, &inc.Cause, &inc.Errors); err != nil {
+			return nil, err
+		}
+		inc.Started = time.Unix(started, 0)
+		if ended.Valid {
+			t := time.Unix(ended.Int64, 0)
+			inc.Ended = &t
+		}
+		incidents = append(incidents, inc)
+	}
+	return incidents, rows.Err()
+}
+
+// UptimeTimeline splits the last days (including today up to now) into
+// per-day uptime bars, oldest first
+func UptimeTimeline(incidents []Incident, days int, now time.Time) []UptimeDay {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	timeline := make([]UptimeDay, 0, days)
+	for i := days - 1; i >= 0; i-- {
+		dayStart := today.AddDate(0, 0, -i)
+		dayEnd := dayStart.AddDate(0, 0, 1)
+		if dayEnd.After(now) {
+			dayEnd = now
+		}
+		day := UptimeDay{Date: dayStart.Format("2006-01-02"), Uptime: 100, Level: "ok"}
+		var down time.Duration
+		for _, inc := range incidents {
+			end := now
+			if inc.Ended != nil {
+				end = *inc.Ended
+			}
+			if !inc.Started.Before(dayEnd) || end.Before(dayStart) {
+				continue
+			}
+			from, to := inc.Started, end
+			if from.Before(dayStart) {
+				from = dayStart
+			}
+			if to.After(dayEnd) {
+				to = dayEnd
+			}
+			down += to.Sub(from)
+			day.Incidents++
+		}
+		if span := dayEnd.Sub(dayStart); span > 0 {
+			day.Uptime = math.Max(0, 100*(1-down.Seconds()/span.Seconds()))
+		}
+		switch {
+		case day.Uptime < 90:
+			day.Level = "down"
+		case day.Incidents > 0:
+			day.Level = "degraded"
+		}
+		timeline = append(timeline, day)
+	}
+	return timeline
+}
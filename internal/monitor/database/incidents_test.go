@@ -0,0 +1,88 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func setupMigratedDB(t *testing.T) {
+	DBPath = filepath.Join(t.TempDir(), "test.db")
+	if err := Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = DB.Close(); DB = nil })
+}
+
+func TestEngineHealth_RecoversAfterOldFailures(t *testing.T) {
+	setupMigratedDB(t)
+
+	// A bad stretch five days ago followed by recent successes
+	old := time.Now().Add(-5 * 24 * time.Hour).Unix()
+	for i := 0; i < 10; i++ {
+		_, _ = DB.Exec("INSERT INTO engine_outcomes (engine_id, timestamp, ok) VALUES ('1', ?, 0)", old)
+	}
+	for i := 0; i < 3; i++ {
+		ReportEngineSuccess("1")
+	}
+	score, samples := GetEngineHealthScore("1")
+	if samples != 13 || score < 0.7 {
+		t.Errorf("Expected old failures to fade out, got score %.2f over %d syncs", score, samples)
+	}
+	// A lifetime ratio would still be 3/13
+	if grade, _ := GetEngineHealth("1"); grade == "F" || grade == "D" {
+		t.Errorf("Unexpected grade %s", grade)
+	}
+
+	// Outcomes beyond the window do not count at all
+	_, _ = DB.Exec("INSERT INTO engine_outcomes (engine_id, timestamp, ok) VALUES ('1', ?, 0)", time.Now().Add(-8*24*time.Hour).Unix())
+	if _, samples := GetEngineHealthScore("1"); samples != 13 {
+		t.Errorf("Expected outcomes outside the window to be ignored, got %d", samples)
+	}
+	if grade, _ := GetEngineHealth("2"); grade != "N/A" {
+		t.Errorf("Expected N/A without syncs, got %s", grade)
+	}
+}
+
+func TestEngineIncidents(t *testing.T) {
+	setupMigratedDB(t)
+
+	ReportEngineError("1", "disk full")
+	ReportEngineError("1", "disk still full")
+	incidents, err := GetEngineIncidents("1", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(incidents) != 1 || incidents[0].Ended != nil || incidents[0].Cause != "disk full" || incidents[0].Errors != 2 {
+		t.Fatalf("Expected one ongoing incident, got %+v", incidents)
+	}
+
+	ReportEngineSuccess("1")
+	ReportEngineSuccess("1")
+	incidents, _ = GetEngineIncidents("1", time.Now().Add(-time.Hour))
+	if len(incidents) != 1 || incidents[0].Ended == nil {
+		t.Errorf("Expected the incident to end with the next success, got %+v", incidents)
+	}
+}
+
+func TestUptimeTimeline(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	ended := time.Date(2026, 10, 17, 6, 0, 0, 0, time.UTC)
+	incidents := []Incident{
+		{Started: time.Date(2026, 10, 16, 18, 0, 0, 0, time.UTC), Ended: &ended}, // 6h on the 16th, 6h on the 17th
+		{Started: time.Date(2026, 10, 18, 11, 0, 0, 0, time.UTC)},                // Ongoing: 1h of today's 12h
+	}
+	timeline := UptimeTimeline(incidents, 4, now)
+	if len(timeline) != 4 || timeline[0].Date != "2026-10-15" || timeline[3].Date != "2026-10-18" {
+		t.Fatalf("Unexpected days %+v", timeline)
+	}
+	want := []float64{100, 75, 75, 100 - 100.0/12}
+	for i, day := range timeline {
+		if diff := day.Uptime - want[i]; diff > 0.01 || diff < -0.01 {
+			t.Errorf("%s: expected %.2f%% uptime, got %.2f%%", day.Date, want[i], day.Uptime)
+		}
+	}
+	if timeline[0].Level != "ok" || timeline[1].Level != "down" || timeline[3].Level != "degraded" {
+		t.Errorf("Unexpected levels %+v", timeline)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"database/sql"
 	"embed"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -12,8 +11,12 @@ import (
 	"time"
 
 	_ "modernc.org/sqlite"
+
+	"schnorarr/internal/monitor/logging"
 )
 
+var logger = logging.For("database")
+
 //go:embed migrations/*.sql
 var migrationFS embed.FS
 
@@ -47,7 +50,7 @@ func Init() error {
 	for i := 0; i < maxRetries; i++ {
 		if i > 0 {
 			backoff := time.Duration(100*(1<<uint(i-1))) * time.Millisecond
-			log.Printf("Database init retry %d/%d after %v", i+1, maxRetries, backoff)
+			logger.Warn("Database init retry", "attempt", i+1, "max_retries", maxRetries, "backoff", backoff.String())
 			time.Sleep(backoff)
 		}
 
@@ -65,26 +68,26 @@ func Init() error {
 
 		if _, err = DB.Exec("PRAGMA journal_mode=WAL"); err != nil {
 			if cerr := DB.Close(); cerr != nil {
-				log.Printf("[Database] Error closing DB: %v", cerr)
+				logger.Error("Error closing DB", "error", cerr)
 			}
 			continue
 		}
 
 		if _, err = DB.Exec("PRAGMA busy_timeout=5000"); err != nil {
 			if cerr := DB.Close(); cerr != nil {
-				log.Printf("[Database] Error closing DB: %v", cerr)
+				logger.Error("Error closing DB", "error", cerr)
 			}
 			continue
 		}
 
 		if err := runMigrations(); err != nil {
 			if cerr := DB.Close(); cerr != nil {
-				log.Printf("[Database] Error closing DB: %v", cerr)
+				logger.Error("Error closing DB", "error", cerr)
 			}
 			continue
 		}
 
-		log.Println("Database initialized successfully")
+		logger.Info("Database initialized", "path", DBPath)
 		return nil
 	}
 
@@ -108,12 +111,12 @@ func runMigrations() error {
 		// Parse version from filename (e.g., "001_init.sql" -> 1)
 		parts := strings.SplitN(file.Name(), "_", 2)
 		if len(parts) < 2 {
-			log.Printf("[Database] Skipping invalid migration file: %s", file.Name())
+			logger.Warn("Skipping invalid migration file", "file", file.Name())
 			continue
 		}
 		version, err := strconv.Atoi(parts[0])
 		if err != nil {
-			log.Printf("[Database] Skipping invalid migration version: %s", file.Name())
+			logger.Warn("Skipping invalid migration version", "file", file.Name())
 			continue
 		}
 
@@ -124,7 +127,7 @@ func runMigrations() error {
 			continue
 		}
 
-		log.Printf("[Database] Running migration %d: %s", version, file.Name())
+		logger.Info("Running migration", "version", version, "file", file.Name())
 		content, err := migrationFS.ReadFile("migrations/" + file.Name())
 		if err != nil {
 			return err
@@ -138,7 +141,7 @@ func runMigrations() error {
 
 		if _, err := tx.Exec(string(content)); err != nil {
 			if rerr := tx.Rollback(); rerr != nil {
-				log.Printf("[Database] Error rolling back migration: %v", rerr)
+				logger.Error("Error rolling back migration", "error", rerr)
 			}
 			// If it's an "already exists" error on create table, we might want to ignore it if we are sure,
 			// but for safety we fail. The user can manually fix if needed.
@@ -147,7 +150,7 @@ func runMigrations() error {
 
 		if _, err := tx.Exec("INSERT INTO schema_migrations (version) VALUES (?)", version); err != nil {
 			if rerr := tx.Rollback(); rerr != nil {
-				log.Printf("[Database] Error rolling back migration record: %v", rerr)
+				logger.Error("Error rolling back migration record", "error", rerr)
 			}
 			return fmt.Errorf("failed to record migration version: %w", err)
 		}
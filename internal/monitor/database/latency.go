@@ -0,0 +1,61 @@
+package database
+
+import "time"
+
+// LatencyStats summarizes the health checks of the receiver over a period
+type LatencyStats struct {
+	Time    time.Time `json:"time"` // Start of the period
+	Host    string    `json:"host"`
+	Samples int       `json:"samples"` // Checks, lost ones included
+	Lost    int       `json:"lost"`
+	Loss    float64   `json:"loss"` // Lost checks in percent
+	MinMs   float64   `json:"min_ms"`
+	AvgMs   float64   `json:"avg_ms"`
+	P95Ms   float64   `json:"p95_ms"`
+	MaxMs   float64   `json:"max_ms"`
+}
+
+// SaveLatencyStats stores the stats of a bucket, replacing an earlier save of it
+func SaveLatencyStats(s LatencyStats) error {
+	if DB == nil {
+		return nil
+	}
+	_, err := DB.Exec(`INSERT OR REPLACE INTO latency_stats (bucket, host, samples, lost, min_ms, avg_ms, p95_ms, max_ms) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.Time.Unix(), s.Host, s.Samples, s.Lost, s.MinMs, s.AvgMs, s.P95Ms, s.MaxMs)
+	return err
+}
+
+// GetLatencyStats returns the buckets since a time, oldest first
+func GetLatencyStats(since time.Time) ([]LatencyStats, error) {
+	if DB == nil {
+		return nil, nil
+	}
+	rows, err := DB.Query(`SELECT bucket, host, samples, lost, min_ms, avg_ms, p95_ms, max_ms FROM latency_stats WHERE bucket >= ? ORDER BY bucket, host`, since.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var stats []LatencyStats
+	for rows.Next() {
+		var s LatencyStats
+		var bucket int64
+		if err := rows.Scan(&bucket, &s.Host, &s.Samples, &s.Lost, &s.MinMs, &s.AvgMs, &s.P95Ms, &s.MaxMs); err != nil {
+			return nil, err
+		}
+		s.Time = time.Unix(bucket, 0)
+		if s.Samples > 0 {
+			s.Loss = float64(s.Lost) * 100 / float64(s.Samples)
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// PruneLatencyStats deletes buckets older than days
+func PruneLatencyStats(days int) error {
+	if DB == nil || days <= 0 {
+		return nil
+	}
+	_, err := DB.Exec(`DELETE FROM latency_stats WHERE bucket < ?`, time.Now().AddDate(0, 0, -days).Unix())
+	return err
+}
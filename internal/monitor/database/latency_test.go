@@ -0,0 +1,29 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyStats_PerHost(t *testing.T) {
+	setupMigratedDB(t)
+
+	bucket := time.Now().Truncate(5 * time.Minute)
+	// A failover inside a bucket saves it for both receivers
+	for _, s := range []LatencyStats{
+		{Time: bucket, Host: "primary", Samples: 10, Lost: 5, AvgMs: 40},
+		{Time: bucket, Host: "backup", Samples: 20, AvgMs: 12},
+		{Time: bucket, Host: "primary", Samples: 12, Lost: 6, AvgMs: 42},
+	} {
+		if err := SaveLatencyStats(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats, err := GetLatencyStats(bucket.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 || stats[0].Host != "backup" || stats[1].Host != "primary" || stats[1].Samples != 12 {
+		t.Errorf("Expected one bucket per host with the latest save, got %+v", stats)
+	}
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// Maintenance is an active maintenance window. While it lasts, errors of the
+// covered engines neither notify nor degrade health.
+type Maintenance struct {
+	EngineID string     `json:"engine_id"` // Empty for global maintenance
+	Started  time.Time  `json:"started"`
+	Until    *time.Time `json:"until,omitempty"` // nil until switched off
+	Reason   string     `json:"reason"`
+	User     string     `json:"user"`
+}
+
+// StartMaintenance puts an engine ("" = everything) into maintenance for d (0 = until stopped)
+func StartMaintenance(engineID string, d time.Duration, reason, user string) error {
+	if DB == nil {
+		return nil
+	}
+	now := time.Now()
+	var until int64
+	if d > 0 {
+		until = now.Add(d).Unix()
+	}
+	_, err := DB.Exec(`INSERT INTO maintenance (scope, started, until, reason, user) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(scope) DO UPDATE SET until = excluded.until, reason = excluded.reason, user = excluded.user`,
+		engineID, now.Unix(), until, reason, user)
+	if err != nil {
+		return err
+	}
+	details := maintenanceScope(engineID)
+	if d > 0 {
+		details += " for " + d.String()
+	}
+	if reason != "" {
+		details += ": " + reason
+	}
+	return LogSystemEvent(user, "Maintenance Started", details)
+}
+
+// StopMaintenance ends the maintenance of an engine ("" = global)
+func StopMaintenance(engineID, user string) error {
+	if DB == nil {
+		return nil
+	}
+	res, err := DB.Exec(`DELETE FROM maintenance WHERE scope = ?`, engineID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+	return LogSystemEvent(user, "Maintenance Ended", maintenanceScope(engineID))
+}
+
+// InMaintenance reports whether an engine is covered by its own or the global
+// maintenance; an empty engineID only checks the global one
+func InMaintenance(engineID string) bool {
+	if DB == nil {
+		return false
+	}
+	var n int
+	_ = DB.QueryRow(`SELECT COUNT(*) FROM maintenance WHERE scope IN ('', ?) AND (until = 0 OR until > ?)`, engineID, time.Now().Unix()).Scan(&n)
+	return n > 0
+}
+
+// GetMaintenance returns the active maintenance windows
+func GetMaintenance() ([]Maintenance, error) {
+	if DB == nil {
+		return nil, nil
+	}
+	rows, err := DB.Query(`SELECT scope, started, until, reason, user FROM maintenance WHERE until = 0 OR until > ? ORDER BY scope`, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	windows := make([]Maintenance, 0)
+	for rows.Next() {
+		var m Maintenance
+		var started, until int64
+		if err := rows.Scan(&m.EngineID, &started, &until, &m.Reason, &m.User); err != nil {
+			return nil, err
+		}
+		m.Started = time.Unix(started, 0)
+		if until > 0 {
+			t := time.Unix(until, 0)
+			m.Until = &t
+		}
+		windows = append(windows, m)
+	}
+	return windows, rows.Err()
+}
+
+// ExpireMaintenance removes maintenance windows whose duration ran out and
+// records their end in the audit trail
+func ExpireMaintenance() error {
+	if DB == nil {
+		return nil
+	}
+	rows, err := DB.Query(`SELECT scope FROM maintenance WHERE until > 0 AND until <= ?`, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	var expired []string
+	for rows.Next() {
+		var scope string
+		if err := rows.Scan(&scope); err == nil {
+			expired = append(expired, scope)
+		}
+	}
+	_ = rows.Close()
+	for _, scope := range expired {
+		if _, err := DB.Exec(`DELETE FROM maintenance WHERE scope = ?`, scope); err != nil {
+			return err
+		}
+		_ = LogSystemEvent("system", "Maintenance Expired", maintenanceScope(scope))
+	}
+	return nil
+}
+
+func maintenanceScope(engineID string) string {
+	if engineID == "" {
+		return "Global maintenance"
+	}
+	return fmt.Sprintf("Maintenance of engine %s", engineID)
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaintenance_ScopesAndExpiry(t *testing.T) {
+	setupMigratedDB(t)
+
+	if err := StartMaintenance("1", time.Hour, "reorganizing movies", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if !InMaintenance("1") || InMaintenance("2") || InMaintenance("") {
+		t.Error("Engine maintenance should only cover that engine")
+	}
+
+	if err := StartMaintenance("", 0, "", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if !InMaintenance("2") {
+		t.Error("Global maintenance should cover every engine")
+	}
+	if err := StopMaintenance("", "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Errors during maintenance don't open incidents
+	ReportEngineError("1", "file vanished")
+	if incidents, _ := GetEngineIncidents("1", time.Now().Add(-time.Hour)); len(incidents) != 0 {
+		t.Errorf("Expected no incidents during maintenance, got %d", len(incidents))
+	}
+
+	// Let the engine window run out
+	_, _ = DB.Exec("UPDATE maintenance SET until = ? WHERE scope = '1'", time.Now().Add(-time.Minute).Unix())
+	if InMaintenance("1") {
+		t.Error("Expired maintenance should no longer apply")
+	}
+	if err := ExpireMaintenance(); err != nil {
+		t.Fatal(err)
+	}
+	if windows, _ := GetMaintenance(); len(windows) != 0 {
+		t.Errorf("Expected no maintenance windows, got %+v", windows)
+	}
+
+	var events []string
+	rows, err := DB.Query("SELECT action FROM history WHERE engine_id = 'SYSTEM' ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var action string
+		_ = rows.Scan(&action)
+		events = append(events, action)
+	}
+	want := []string{"Maintenance Started", "Maintenance Started", "Maintenance Ended", "Maintenance Expired"}
+	if len(events) != len(want) {
+		t.Fatalf("Expected audit events %v, got %v", want, events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("Event %d: expected %q, got %q", i, want[i], events[i])
+		}
+	}
+}
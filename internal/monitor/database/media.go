@@ -0,0 +1,72 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// MediaValidation is the ffprobe result of one version of a source file
+type MediaValidation struct {
+	EngineID string  `json:"engine_id"`
+	Path     string  `json:"path"`
+	Size     int64   `json:"size"`
+	ModTime  int64   `json:"mod_time"` // Unix seconds
+	Valid    bool    `json:"valid"`
+	Duration float64 `json:"duration"` // Seconds
+	Error    string  `json:"error,omitempty"`
+	Checked  int64   `json:"checked"` // Unix seconds
+}
+
+// SaveMediaValidation stores the result for a file, replacing the result of
+// an earlier version
+func SaveMediaValidation(v MediaValidation) error {
+	if DB == nil {
+		return nil
+	}
+	if v.Checked == 0 {
+		v.Checked = time.Now().Unix()
+	}
+	_, err := DB.Exec(`INSERT OR REPLACE INTO media_validation (engine_id, path, size, mod_time, valid, duration, error, checked)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, v.EngineID, v.Path, v.Size, v.ModTime, v.Valid, v.Duration, v.Error, v.Checked)
+	return err
+}
+
+// GetMediaValidation returns the stored result for a file; nil when it was never checked
+func GetMediaValidation(engineID, path string) (*MediaValidation, error) {
+	if DB == nil {
+		return nil, nil
+	}
+	v := MediaValidation{EngineID: engineID, Path: path}
+	err := DB.QueryRow(`SELECT size, mod_time, valid, duration, error, checked FROM media_validation WHERE engine_id = ? AND path = ?`,
+		engineID, path).Scan(&v.Size, &v.ModTime, &v.Valid, &v.Duration, &v.Error, &v.Checked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// ListInvalidMedia returns the files of an engine that failed validation, newest first
+func ListInvalidMedia(engineID string) ([]MediaValidation, error) {
+	if DB == nil {
+		return nil, nil
+	}
+	rows, err := DB.Query(`SELECT path, size, mod_time, duration, error, checked FROM media_validation
+		WHERE engine_id = ? AND valid = 0 ORDER BY checked DESC, path`, engineID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var out []MediaValidation
+	for rows.Next() {
+		v := MediaValidation{EngineID: engineID}
+		if err := rows.Scan(&v.Path, &v.Size, &v.ModTime, &v.Duration, &v.Error, &v.Checked); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,9 @@
+-- Tracks when receiver files first went missing on the source (delete deferral window)
+
+CREATE TABLE IF NOT EXISTS engine_missing_paths (
+    engine_id TEXT,
+    path TEXT,
+    first_missing INTEGER,
+    scans INTEGER DEFAULT 0,
+    PRIMARY KEY (engine_id, path)
+);
@@ -0,0 +1,5 @@
+-- Correlates history rows with the sync cycle that produced them
+
+ALTER TABLE history ADD COLUMN cycle_id TEXT DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_history_cycle ON history (cycle_id);
@@ -0,0 +1,17 @@
+-- Results of transfer benchmarks; the best row of an engine's latest run drives auto-tuning
+
+CREATE TABLE IF NOT EXISTS benchmark_results (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    engine_id TEXT NOT NULL,
+    run_at INTEGER NOT NULL,
+    streams INTEGER,
+    chunk_size INTEGER,
+    compress INTEGER DEFAULT 0,
+    bytes INTEGER,
+    seconds REAL,
+    throughput INTEGER,
+    error TEXT DEFAULT '',
+    best INTEGER DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_benchmark_engine ON benchmark_results(engine_id);
@@ -0,0 +1,3 @@
+-- Queued manifests are stored in the compact binary encoding; manifest_json is only read for old rows
+
+ALTER TABLE engine_queue ADD COLUMN manifest_blob BLOB;
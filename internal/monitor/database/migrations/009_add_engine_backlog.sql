@@ -0,0 +1,9 @@
+-- Changes accumulated while an engine's receiver is offline, replayed as one catch-up sync on reconnect
+
+CREATE TABLE IF NOT EXISTS engine_backlog (
+    engine_id TEXT PRIMARY KEY,
+    offline_since INTEGER,
+    paths_json TEXT DEFAULT '[]',
+    bytes INTEGER DEFAULT 0,
+    overflow INTEGER DEFAULT 0
+);
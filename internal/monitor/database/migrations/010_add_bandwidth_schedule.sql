@@ -0,0 +1,11 @@
+-- Time-of-day bandwidth profiles; the first window matching the current time wins
+
+CREATE TABLE IF NOT EXISTS bandwidth_schedule (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    position INTEGER NOT NULL,
+    name TEXT DEFAULT '',
+    days TEXT NOT NULL,
+    start_time TEXT NOT NULL,
+    end_time TEXT NOT NULL,
+    limit_mbps INTEGER DEFAULT 0
+);
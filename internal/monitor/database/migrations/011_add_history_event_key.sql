@@ -0,0 +1,10 @@
+-- Makes sync events idempotent per cycle: a repeated event within the same
+-- cycle is counted as a retry instead of inserting a duplicate row
+
+DELETE FROM history WHERE cycle_id != '' AND id NOT IN (
+    SELECT MIN(id) FROM history WHERE cycle_id != '' GROUP BY engine_id, cycle_id, action, file_path
+);
+
+ALTER TABLE history ADD COLUMN retries INTEGER DEFAULT 0;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_history_event ON history (engine_id, cycle_id, action, file_path) WHERE cycle_id != '';
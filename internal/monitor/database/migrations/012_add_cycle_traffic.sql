@@ -0,0 +1,9 @@
+-- Bytes actually transferred per sync cycle, next to the file sizes history records
+
+CREATE TABLE IF NOT EXISTS cycle_traffic (
+    cycle_id TEXT NOT NULL,
+    engine_id TEXT NOT NULL,
+    bytes_sent INTEGER DEFAULT 0,
+    finished TEXT,
+    PRIMARY KEY (cycle_id, engine_id)
+);
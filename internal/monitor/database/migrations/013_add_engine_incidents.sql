@@ -0,0 +1,22 @@
+-- Recent sync outcomes for the decaying health score, and incidents
+-- (consecutive failures until the next success) for the uptime timeline
+
+CREATE TABLE IF NOT EXISTS engine_outcomes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    engine_id TEXT NOT NULL,
+    timestamp INTEGER NOT NULL,
+    ok INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_engine_outcomes ON engine_outcomes (engine_id, timestamp);
+
+CREATE TABLE IF NOT EXISTS engine_incidents (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    engine_id TEXT NOT NULL,
+    started INTEGER NOT NULL,
+    ended INTEGER,
+    cause TEXT,
+    errors INTEGER DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS idx_engine_incidents ON engine_incidents (engine_id, started);
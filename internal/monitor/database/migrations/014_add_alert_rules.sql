@@ -0,0 +1,14 @@
+-- Alerting rules: when sync errors notify, escalate and resolve
+
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    position INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    match TEXT DEFAULT '',
+    threshold INTEGER DEFAULT 1,
+    window_minutes INTEGER DEFAULT 0,
+    channel TEXT DEFAULT '',
+    escalate_after_minutes INTEGER DEFAULT 0,
+    escalate_channel TEXT DEFAULT '',
+    notify_resolve INTEGER DEFAULT 0
+);
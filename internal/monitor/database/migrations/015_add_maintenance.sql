@@ -0,0 +1,9 @@
+-- Maintenance windows silencing alerts, globally (scope '') or per engine
+
+CREATE TABLE IF NOT EXISTS maintenance (
+    scope TEXT PRIMARY KEY,
+    started INTEGER NOT NULL,
+    until INTEGER DEFAULT 0,
+    reason TEXT DEFAULT '',
+    user TEXT DEFAULT ''
+);
@@ -0,0 +1,7 @@
+-- Last previewed sync plan of each engine, diffed against the current plan
+
+CREATE TABLE IF NOT EXISTS plan_snapshots (
+    engine_id TEXT PRIMARY KEY,
+    taken INTEGER NOT NULL,
+    plan_json TEXT NOT NULL
+);
@@ -0,0 +1,14 @@
+-- Deletions and renames of recent cycles, kept to undo a cycle within the retention window
+
+CREATE TABLE IF NOT EXISTS undo_actions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    engine_id TEXT NOT NULL,
+    cycle_id TEXT NOT NULL,
+    timestamp INTEGER NOT NULL,
+    action TEXT NOT NULL,
+    path TEXT NOT NULL,
+    new_path TEXT DEFAULT '',
+    trash_path TEXT DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_undo_engine_cycle ON undo_actions (engine_id, cycle_id);
@@ -0,0 +1,7 @@
+-- Numeric engine IDs moved to stable names, so a number reused by another engine is not migrated again
+
+CREATE TABLE IF NOT EXISTS engine_renames (
+    old_id TEXT PRIMARY KEY,
+    new_id TEXT NOT NULL,
+    renamed INTEGER NOT NULL
+);
@@ -0,0 +1,7 @@
+-- History times as indexed epoch seconds (UTC). The timestamp column keeps an
+-- ISO-8601 copy; existing rows are filled in at startup, as older versions
+-- wrote them in local time.
+
+ALTER TABLE history ADD COLUMN ts INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_history_ts ON history (ts);
@@ -0,0 +1,3 @@
+-- Cycle finish times as epoch seconds (UTC), filled in at startup like history
+
+ALTER TABLE cycle_traffic ADD COLUMN finished_ts INTEGER;
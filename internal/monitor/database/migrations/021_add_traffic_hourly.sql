@@ -0,0 +1,8 @@
+-- Traffic per engine and hour (epoch of the hour's start), kept for TRAFFIC_HOURLY_DAYS
+
+CREATE TABLE IF NOT EXISTS traffic_hourly (
+    hour INTEGER NOT NULL,
+    engine_id TEXT NOT NULL,
+    bytes_sent INTEGER DEFAULT 0,
+    PRIMARY KEY (hour, engine_id)
+);
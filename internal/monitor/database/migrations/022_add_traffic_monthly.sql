@@ -0,0 +1,8 @@
+-- Daily traffic older than TRAFFIC_DAILY_DAYS, rolled up per engine and month ("2006/01")
+
+CREATE TABLE IF NOT EXISTS traffic_monthly (
+    month TEXT NOT NULL,
+    engine_id TEXT NOT NULL,
+    bytes_sent INTEGER DEFAULT 0,
+    PRIMARY KEY (month, engine_id)
+);
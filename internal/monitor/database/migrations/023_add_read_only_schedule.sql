@@ -0,0 +1,10 @@
+-- Weekly windows during which a receiver refuses transfers and deletes
+
+CREATE TABLE IF NOT EXISTS read_only_schedule (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    position INTEGER NOT NULL,
+    name TEXT DEFAULT '',
+    days TEXT NOT NULL,
+    start_time TEXT NOT NULL,
+    end_time TEXT NOT NULL
+);
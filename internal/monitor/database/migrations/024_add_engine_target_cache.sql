@@ -0,0 +1,7 @@
+-- Target manifest as the last sync cycle left it, to spot changes made on the target outside of sync
+
+CREATE TABLE IF NOT EXISTS engine_target_cache (
+    engine_id TEXT PRIMARY KEY,
+    manifest_blob BLOB NOT NULL,
+    timestamp INTEGER NOT NULL
+);
@@ -0,0 +1,7 @@
+-- Paths known to be in sync on both ends, the base of the three-way comparison
+
+CREATE TABLE IF NOT EXISTS engine_synced_manifest (
+    engine_id TEXT PRIMARY KEY,
+    manifest_blob BLOB NOT NULL,
+    timestamp INTEGER NOT NULL
+);
@@ -0,0 +1,13 @@
+-- ffprobe results of source media, per engine and file version
+
+CREATE TABLE IF NOT EXISTS media_validation (
+    engine_id TEXT NOT NULL,
+    path TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    mod_time INTEGER NOT NULL,
+    valid INTEGER NOT NULL,
+    duration REAL DEFAULT 0,
+    error TEXT DEFAULT '',
+    checked INTEGER NOT NULL,
+    PRIMARY KEY (engine_id, path)
+);
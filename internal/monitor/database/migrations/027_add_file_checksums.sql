@@ -0,0 +1,10 @@
+-- SHA-256 of transferred files, per engine and file version, for checksum sidecars
+
+CREATE TABLE IF NOT EXISTS file_checksums (
+    engine_id TEXT NOT NULL,
+    path TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    mod_time INTEGER NOT NULL,
+    sha256 TEXT NOT NULL,
+    PRIMARY KEY (engine_id, path)
+);
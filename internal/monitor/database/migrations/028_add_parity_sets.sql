@@ -0,0 +1,10 @@
+-- PAR2 parity sets on the target, per engine and directory
+
+CREATE TABLE IF NOT EXISTS parity_sets (
+    engine_id TEXT NOT NULL,
+    dir TEXT NOT NULL,
+    digest TEXT NOT NULL,
+    files TEXT NOT NULL,
+    created INTEGER NOT NULL,
+    PRIMARY KEY (engine_id, dir)
+);
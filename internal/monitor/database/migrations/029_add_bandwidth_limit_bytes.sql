@@ -0,0 +1,5 @@
+-- Bandwidth windows store their limit in bytes per second, so limits like
+-- "4MB/s" or "1.5mbit" keep their exact value; limit_mbps stays for older readers
+
+ALTER TABLE bandwidth_schedule ADD COLUMN limit_bytes INTEGER DEFAULT 0;
+UPDATE bandwidth_schedule SET limit_bytes = limit_mbps * 125000;
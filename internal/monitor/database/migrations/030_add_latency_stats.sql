@@ -0,0 +1,13 @@
+-- Receiver latency and loss per 5 minute bucket, measured by the sender's health checks
+
+CREATE TABLE IF NOT EXISTS latency_stats (
+    bucket INTEGER NOT NULL,
+    host TEXT NOT NULL,
+    samples INTEGER NOT NULL,
+    lost INTEGER NOT NULL,
+    min_ms REAL NOT NULL,
+    avg_ms REAL NOT NULL,
+    p95_ms REAL NOT NULL,
+    max_ms REAL NOT NULL,
+    PRIMARY KEY (bucket, host)
+);
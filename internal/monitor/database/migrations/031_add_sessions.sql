@@ -0,0 +1,11 @@
+-- Login sessions, so restarts keep users logged in. Tokens are stored as
+-- SHA-256 hashes; the database alone does not grant a login.
+
+CREATE TABLE IF NOT EXISTS sessions (
+    token_hash TEXT PRIMARY KEY,
+    user TEXT NOT NULL,
+    created INTEGER NOT NULL,
+    expires INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_expires ON sessions(expires);
@@ -0,0 +1,3 @@
+-- Remember-me sessions get a longer lifetime and a persistent cookie
+
+ALTER TABLE sessions ADD COLUMN remember INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"strings"
+	"time"
+)
+
+// ParitySet is the PAR2 parity of one target directory
+type ParitySet struct {
+	Dir string
+	// Digest identifies the directory contents the parity was created for
+	Digest string
+	// Files are the parity files, relative to Dir
+	Files   []string
+	Created time.Time
+}
+
+// SaveParitySet stores the parity of a directory, replacing the previous set
+func SaveParitySet(engineID string, p ParitySet) error {
+	if DB == nil {
+		return nil
+	}
+	if p.Created.IsZero() {
+		p.Created = time.Now()
+	}
+	_, err := DB.Exec(`INSERT OR REPLACE INTO parity_sets (engine_id, dir, digest, files, created) VALUES (?, ?, ?, ?, ?)`,
+		engineID, p.Dir, p.Digest, strings.Join(p.Files, "\n"), p.Created.Unix())
+	return err
+}
+
+// LoadParitySets returns the parity sets of an engine by directory
+func LoadParitySets(engineID string) (map[string]ParitySet, error) {
+	sets := make(map[string]ParitySet)
+	if DB == nil {
+		return sets, nil
+	}
+	rows, err := DB.Query(`SELECT dir, digest, files, created FROM parity_sets WHERE engine_id = ?`, engineID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var p ParitySet
+		var files string
+		var created int64
+		if err := rows.Scan(&p.Dir, &p.Digest, &files, &created); err != nil {
+			return nil, err
+		}
+		if files != "" {
+			p.Files = strings.Split(files, "\n")
+		}
+		p.Created = time.Unix(created, 0)
+		sets[p.Dir] = p
+	}
+	return sets, rows.Err()
+}
+
+// DeleteParitySet forgets the parity of a directory
+func DeleteParitySet(engineID, dir string) error {
+	if DB == nil {
+		return nil
+	}
+	_, err := DB.Exec(`DELETE FROM parity_sets WHERE engine_id = ? AND dir = ?`, engineID, dir)
+	return err
+}
@@ -2,7 +2,6 @@ package database
 
 import (
 	"database/sql"
-	"encoding/json"
 	"time"
 )
 
@@ -20,6 +19,13 @@ type ConflictPersistence struct {
 	ReceiverTime int64
 }
 
+// MissingPath tracks a target path that is missing on the source
+type MissingPath struct {
+	Path         string
+	FirstMissing int64
+	Scans        int
+}
+
 type QueuedSync struct {
 	ManifestJSON string
 	Timestamp    int64
@@ -115,16 +121,58 @@ func LoadEngineState(engineID string) (*PersistentState, error) {
 	return state, nil
 }
 
-func SaveEngineQueue(engineID string, manifest interface{}) error {
+// SaveMissingPaths replaces the tracked missing paths of an engine
+func SaveMissingPaths(engineID string, paths []MissingPath) error {
 	if DB == nil {
 		return nil
 	}
-	data, err := json.Marshal(manifest)
+	tx, err := DB.Begin()
 	if err != nil {
 		return err
 	}
-	_, err = DB.Exec(`INSERT OR REPLACE INTO engine_queue (engine_id, manifest_json, timestamp) VALUES (?, ?, ?)`,
-		engineID, string(data), time.Now().Unix())
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err = tx.Exec(`DELETE FROM engine_missing_paths WHERE engine_id = ?`, engineID); err != nil {
+		return err
+	}
+	for _, p := range paths {
+		_, err = tx.Exec(`INSERT INTO engine_missing_paths (engine_id, path, first_missing, scans) VALUES (?, ?, ?, ?)`,
+			engineID, p.Path, p.FirstMissing, p.Scans)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// LoadMissingPaths returns the tracked missing paths of an engine
+func LoadMissingPaths(engineID string) ([]MissingPath, error) {
+	if DB == nil {
+		return nil, nil
+	}
+	rows, err := DB.Query(`SELECT path, first_missing, scans FROM engine_missing_paths WHERE engine_id = ?`, engineID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var paths []MissingPath
+	for rows.Next() {
+		var p MissingPath
+		if err := rows.Scan(&p.Path, &p.FirstMissing, &p.Scans); err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, rows.Err()
+}
+
+// SaveEngineQueue stores the encoded manifest of an engine's queued sync
+func SaveEngineQueue(engineID string, manifest []byte) error {
+	if DB == nil {
+		return nil
+	}
+	_, err := DB.Exec(`INSERT OR REPLACE INTO engine_queue (engine_id, manifest_json, manifest_blob, timestamp) VALUES (?, '', ?, ?)`,
+		engineID, manifest, time.Now().Unix())
 	return err
 }
 
@@ -136,14 +184,109 @@ func ClearEngineQueue(engineID string) error {
 	return err
 }
 
-func LoadEngineQueue(engineID string) (string, error) {
+// LoadEngineQueue returns the queued manifest of an engine: the encoded
+// manifest, or the JSON manifest of rows written by older versions
+func LoadEngineQueue(engineID string) (legacyJSON string, manifest []byte, err error) {
+	if DB == nil {
+		return "", nil, nil
+	}
+	var jsonStr sql.NullString
+	err = DB.QueryRow(`SELECT manifest_json, manifest_blob FROM engine_queue WHERE engine_id = ?`, engineID).Scan(&jsonStr, &manifest)
+	if err == sql.ErrNoRows {
+		return "", nil, nil
+	}
+	return jsonStr.String, manifest, err
+}
+
+// SaveEngineTargetCache stores the encoded target manifest an engine
+// compares the next scan of its target with
+func SaveEngineTargetCache(engineID string, manifest []byte) error {
+	if DB == nil {
+		return nil
+	}
+	_, err := DB.Exec(`INSERT OR REPLACE INTO engine_target_cache (engine_id, manifest_blob, timestamp) VALUES (?, ?, ?)`,
+		engineID, manifest, time.Now().Unix())
+	return err
+}
+
+// LoadEngineTargetCache returns the stored target manifest of an engine, nil
+// when there is none
+func LoadEngineTargetCache(engineID string) ([]byte, error) {
 	if DB == nil {
-		return "", nil
+		return nil, nil
 	}
-	var jsonStr string
-	err := DB.QueryRow(`SELECT manifest_json FROM engine_queue WHERE engine_id = ?`, engineID).Scan(&jsonStr)
+	var manifest []byte
+	err := DB.QueryRow(`SELECT manifest_blob FROM engine_target_cache WHERE engine_id = ?`, engineID).Scan(&manifest)
 	if err == sql.ErrNoRows {
-		return "", nil
+		return nil, nil
+	}
+	return manifest, err
+}
+
+// ClearEngineTargetCache removes the stored target manifest of an engine
+func ClearEngineTargetCache(engineID string) error {
+	if DB == nil {
+		return nil
+	}
+	_, err := DB.Exec(`DELETE FROM engine_target_cache WHERE engine_id = ?`, engineID)
+	return err
+}
+
+// SaveEngineSyncedManifest stores the encoded manifest of the paths an
+// engine last saw in sync on both ends
+func SaveEngineSyncedManifest(engineID string, manifest []byte) error {
+	if DB == nil {
+		return nil
+	}
+	_, err := DB.Exec(`INSERT OR REPLACE INTO engine_synced_manifest (engine_id, manifest_blob, timestamp) VALUES (?, ?, ?)`,
+		engineID, manifest, time.Now().Unix())
+	return err
+}
+
+// LoadEngineSyncedManifest returns the stored synced manifest of an engine,
+// nil when there is none
+func LoadEngineSyncedManifest(engineID string) ([]byte, error) {
+	if DB == nil {
+		return nil, nil
+	}
+	var manifest []byte
+	err := DB.QueryRow(`SELECT manifest_blob FROM engine_synced_manifest WHERE engine_id = ?`, engineID).Scan(&manifest)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return manifest, err
+}
+
+// ClearEngineSyncedManifest removes the stored synced manifest of an engine
+func ClearEngineSyncedManifest(engineID string) error {
+	if DB == nil {
+		return nil
+	}
+	_, err := DB.Exec(`DELETE FROM engine_synced_manifest WHERE engine_id = ?`, engineID)
+	return err
+}
+
+// SavePlanSnapshot replaces the stored preview plan of an engine
+func SavePlanSnapshot(engineID string, planJSON []byte) error {
+	if DB == nil {
+		return nil
+	}
+	_, err := DB.Exec(`INSERT OR REPLACE INTO plan_snapshots (engine_id, taken, plan_json) VALUES (?, ?, ?)`,
+		engineID, time.Now().Unix(), string(planJSON))
+	return err
+}
+
+// LoadPlanSnapshot returns the stored preview plan of an engine and when it
+// was taken; sql.ErrNoRows when the engine was never previewed
+func LoadPlanSnapshot(engineID string) ([]byte, time.Time, error) {
+	if DB == nil {
+		return nil, time.Time{}, sql.ErrNoRows
+	}
+	var planJSON string
+	var taken int64
+	err := DB.QueryRow(`SELECT plan_json, taken FROM plan_snapshots WHERE engine_id = ?`, engineID).Scan(&planJSON, &taken)
+	if err != nil {
+		return nil, time.Time{}, err
 	}
-	return jsonStr, err
+	return []byte(planJSON), time.Unix(taken, 0), nil
 }
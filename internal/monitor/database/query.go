@@ -0,0 +1,261 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidQuery is returned for queries naming unknown resources, fields or
+// operators, or values that do not fit their field
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Query selects rows of a resource for /api/v1/query
+type Query struct {
+	Resource string
+	Fields   []string // Projection, empty for every field
+	Filters  []QueryFilter
+	Sort     []string // Field names, "-" prefix for descending
+	Limit    int
+	Offset   int
+}
+
+// QueryFilter is one condition: Field Op Value. In takes "|"-separated
+// values, like matches a substring.
+type QueryFilter struct {
+	Field string
+	Op    string
+	Value string
+}
+
+// QueryResult is a page of rows and the number of rows matching the filters
+type QueryResult struct {
+	Resource string                   `json:"resource"`
+	Total    int                      `json:"total"`
+	Limit    int                      `json:"limit"`
+	Offset   int                      `json:"offset"`
+	Items    []map[string]interface{} `json:"items"`
+}
+
+const (
+	queryDefaultLimit = 50
+	queryMaxLimit     = 1000
+)
+
+type fieldKind int
+
+const (
+	kindText fieldKind = iota
+	kindInt
+	kindTime // Epoch seconds, filtered and returned as RFC 3339
+)
+
+type queryResource struct {
+	source string // Subquery exposing the fields as columns
+	fields map[string]fieldKind
+	order  []string // Field order of the output and default projection
+	sort   []string
+}
+
+var queryResources = map[string]queryResource{
+	"history": {
+		source: `SELECT id, ts AS time, action, file_path AS path, size_bytes AS size, engine_id AS engine, cycle_id AS cycle, retries FROM history`,
+		fields: map[string]fieldKind{"id": kindInt, "time": kindTime, "action": kindText, "path": kindText, "size": kindInt, "engine": kindText, "cycle": kindText, "retries": kindInt},
+		order:  []string{"id", "time", "action", "path", "size", "engine", "cycle", "retries"},
+		sort:   []string{"-id"},
+	},
+	"traffic": {
+		source: `SELECT date, engine_id AS engine, bytes_sent AS bytes FROM traffic`,
+		fields: map[string]fieldKind{"date": kindText, "engine": kindText, "bytes": kindInt},
+		order:  []string{"date", "engine", "bytes"},
+		sort:   []string{"-date", "engine"},
+	},
+	"runs": {
+		source: `SELECT h.cycle_id AS cycle, h.engine_id AS engine, MIN(h.ts) AS started, MAX(h.ts) AS finished, COUNT(*) AS events,
+			SUM(h.size_bytes) AS planned_bytes, COALESCE(MAX(t.bytes_sent), 0) AS bytes
+			FROM history h LEFT JOIN cycle_traffic t ON t.cycle_id = h.cycle_id AND t.engine_id = h.engine_id
+			WHERE h.cycle_id != '' GROUP BY h.cycle_id, h.engine_id`,
+		fields: map[string]fieldKind{"cycle": kindText, "engine": kindText, "started": kindTime, "finished": kindTime, "events": kindInt, "planned_bytes": kindInt, "bytes": kindInt},
+		order:  []string{"cycle", "engine", "started", "finished", "events", "planned_bytes", "bytes"},
+		sort:   []string{"-finished"},
+	},
+	"failures": {
+		source: `SELECT id, engine_id AS engine, started, ended, cause, errors FROM engine_incidents`,
+		fields: map[string]fieldKind{"id": kindInt, "engine": kindText, "started": kindTime, "ended": kindTime, "cause": kindText, "errors": kindInt},
+		order:  []string{"id", "engine", "started", "ended", "cause", "errors"},
+		sort:   []string{"-started"},
+	},
+}
+
+var queryOps = map[string]string{"eq": "=", "ne": "!=", "gt": ">", "gte": ">=", "lt": "<", "lte": "<="}
+
+// QueryResources lists the resources RunQuery serves
+func QueryResources() []string {
+	names := make([]string, 0, len(queryResources))
+	for name := range queryResources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RunQuery returns the page of q's resource matching its filters. Field
+// names are checked against the resource, values are bound as parameters.
+func RunQuery(q Query) (*QueryResult, error) {
+	if DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	res, ok := queryResources[q.Resource]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown resource %q", ErrInvalidQuery, q.Resource)
+	}
+
+	fields := q.Fields
+	if len(fields) == 0 {
+		fields = res.order
+	}
+	for _, f := range fields {
+		if _, ok := res.fields[f]; !ok {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidQuery, f)
+		}
+	}
+
+	var conds []string
+	var args []interface{}
+	for _, f := range q.Filters {
+		cond, values, err := res.condition(f)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+		args = append(args, values...)
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	sortBy := q.Sort
+	if len(sortBy) == 0 {
+		sortBy = res.sort
+	}
+	var order []string
+	for _, s := range sortBy {
+		dir := "ASC"
+		if strings.HasPrefix(s, "-") {
+			dir, s = "DESC", s[1:]
+		}
+		if _, ok := res.fields[s]; !ok {
+			return nil, fmt.Errorf("%w: cannot sort by %q", ErrInvalidQuery, s)
+		}
+		order = append(order, s+" "+dir)
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = queryDefaultLimit
+	}
+	if limit > queryMaxLimit {
+		limit = queryMaxLimit
+	}
+	offset := max(q.Offset, 0)
+
+	from := " FROM (" + res.source + ")" + where
+	result := &QueryResult{Resource: q.Resource, Limit: limit, Offset: offset, Items: []map[string]interface{}{}}
+	if err := DB.QueryRow("SELECT COUNT(*)"+from, args...).Scan(&result.Total); err != nil {
+		return nil, err
+	}
+	rows, err := DB.Query("SELECT "+strings.Join(fields, ", ")+from+" ORDER BY "+strings.Join(order, ", ")+" LIMIT ? OFFSET ?",
+		append(args, limit, offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		values := make([]interface{}, len(fields))
+		ptrs := make([]interface{}, len(fields))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		item := make(map[string]interface{}, len(fields))
+		for i, f := range fields {
+			item[f] = res.output(f, values[i])
+		}
+		result.Items = append(result.Items, item)
+	}
+	return result, rows.Err()
+}
+
+// condition builds the SQL condition of a filter and its arguments
+func (res queryResource) condition(f QueryFilter) (string, []interface{}, error) {
+	kind, ok := res.fields[f.Field]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: cannot filter by %q", ErrInvalidQuery, f.Field)
+	}
+	switch f.Op {
+	case "like":
+		if kind != kindText {
+			return "", nil, fmt.Errorf("%w: like needs a text field, %q is not", ErrInvalidQuery, f.Field)
+		}
+		return f.Field + " LIKE ?", []interface{}{"%" + f.Value + "%"}, nil
+	case "in":
+		parts := strings.Split(f.Value, "|")
+		args := make([]interface{}, 0, len(parts))
+		for _, p := range parts {
+			v, err := queryValue(kind, p)
+			if err != nil {
+				return "", nil, fmt.Errorf("%w: %s: %v", ErrInvalidQuery, f.Field, err)
+			}
+			args = append(args, v)
+		}
+		return f.Field + " IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ") + ")", args, nil
+	}
+	op, ok := queryOps[f.Op]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: unknown operator %q", ErrInvalidQuery, f.Op)
+	}
+	v, err := queryValue(kind, f.Value)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %s: %v", ErrInvalidQuery, f.Field, err)
+	}
+	return f.Field + " " + op + " ?", []interface{}{v}, nil
+}
+
+// queryValue converts a filter value to what the field stores. Times are
+// RFC 3339, a local date or epoch seconds.
+func queryValue(kind fieldKind, v string) (interface{}, error) {
+	switch kind {
+	case kindInt:
+		return strconv.ParseInt(v, 10, 64)
+	case kindTime:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n, nil
+		}
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t.Unix(), nil
+		}
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a time", v)
+		}
+		return t.Unix(), nil
+	}
+	return v, nil
+}
+
+// output converts a scanned column for JSON
+func (res queryResource) output(field string, v interface{}) interface{} {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	if n, ok := v.(int64); ok && res.fields[field] == kindTime {
+		return time.Unix(n, 0).In(time.Local).Format(time.RFC3339)
+	}
+	return v
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunQuery(t *testing.T) {
+	setupMigratedDB(t)
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, e := range []struct {
+		engine, action, path string
+		size                 int64
+	}{
+		{"1", "Added", "a.mkv", 100},
+		{"1", "Deleted", "b.mkv", 0},
+		{"2", "Added", "c.srt", 5},
+		{"2", "Added", "d.mkv", 300},
+	} {
+		if _, err := RecordEvent(Timestamp(base.Add(time.Duration(i)*time.Hour)), e.action, e.path, e.size, e.engine, "c"+e.engine); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = SaveCycleTraffic("1", "c1", 90)
+	ReportEngineError("2", "disk full")
+
+	res, err := RunQuery(Query{
+		Resource: "history",
+		Fields:   []string{"path", "size"},
+		Filters:  []QueryFilter{{"action", "eq", "Added"}, {"path", "like", ".mkv"}},
+		Sort:     []string{"-size"},
+		Limit:    1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 2 || len(res.Items) != 1 || res.Items[0]["path"] != "d.mkv" || len(res.Items[0]) != 2 {
+		t.Errorf("Expected d.mkv as the first of 2 with two fields, got %+v", res)
+	}
+	res, _ = RunQuery(Query{Resource: "history", Fields: []string{"path"}, Filters: []QueryFilter{{"action", "eq", "Added"}, {"path", "like", ".mkv"}}, Sort: []string{"-size"}, Limit: 1, Offset: 1})
+	if len(res.Items) != 1 || res.Items[0]["path"] != "a.mkv" {
+		t.Errorf("Expected a.mkv on the second page, got %+v", res.Items)
+	}
+
+	res, _ = RunQuery(Query{Resource: "history", Fields: []string{"time"}, Filters: []QueryFilter{{"time", "gte", "2024-05-01T14:00:00Z"}, {"engine", "in", "1|2"}}})
+	if res.Total != 2 || res.Items[0]["time"] != base.Add(3*time.Hour).In(time.Local).Format(time.RFC3339) {
+		t.Errorf("Expected the two latest rows with RFC 3339 times, got %+v", res)
+	}
+
+	res, _ = RunQuery(Query{Resource: "runs", Filters: []QueryFilter{{"engine", "eq", "1"}}})
+	if res.Total != 1 || res.Items[0]["events"] != int64(2) || res.Items[0]["bytes"] != int64(90) {
+		t.Errorf("Expected one run of engine 1, got %+v", res.Items)
+	}
+	res, _ = RunQuery(Query{Resource: "failures"})
+	if res.Total != 1 || res.Items[0]["cause"] != "disk full" {
+		t.Errorf("Expected the open incident, got %+v", res.Items)
+	}
+
+	for _, q := range []Query{
+		{Resource: "users"},
+		{Resource: "history", Fields: []string{"password"}},
+		{Resource: "history", Sort: []string{"size; DROP TABLE history"}},
+		{Resource: "history", Filters: []QueryFilter{{"size", "gt", "big"}}},
+		{Resource: "history", Filters: []QueryFilter{{"size", "like", "1"}}},
+		{Resource: "traffic", Filters: []QueryFilter{{"bytes", "regex", "1"}}},
+	} {
+		if _, err := RunQuery(q); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("%+v: expected ErrInvalidQuery, got %v", q, err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// readOnlyKey is the setting holding the manual read-only switch
+const readOnlyKey = "read_only"
+
+// ReadOnly is the manual write protection of a receiver, e.g. while its
+// filesystem is checked
+type ReadOnly struct {
+	Started time.Time  `json:"started"`
+	Until   *time.Time `json:"until,omitempty"` // nil until switched off
+	Reason  string     `json:"reason"`
+	User    string     `json:"user"`
+}
+
+// ReadOnlyWindow is one row of the read-only schedule
+type ReadOnlyWindow struct {
+	Name  string `json:"name"`
+	Days  string `json:"days"`  // e.g. "sun", "mon-fri", "*"
+	Start string `json:"start"` // HH:MM
+	End   string `json:"end"`   // HH:MM, before Start for windows crossing midnight
+}
+
+// StartReadOnly switches the receiver to read-only for d (0 = until stopped)
+func StartReadOnly(d time.Duration, reason, user string) error {
+	if DB == nil {
+		return nil
+	}
+	ro := ReadOnly{Started: time.Now(), Reason: reason, User: user}
+	details := "Receiver read-only"
+	if d > 0 {
+		until := ro.Started.Add(d)
+		ro.Until = &until
+		details += " for " + d.String()
+	}
+	if reason != "" {
+		details += ": " + reason
+	}
+	data, err := json.Marshal(ro)
+	if err != nil {
+		return err
+	}
+	if err := SaveSetting(readOnlyKey, string(data)); err != nil {
+		return err
+	}
+	return LogSystemEvent(user, "Read-Only Started", details)
+}
+
+// StopReadOnly lifts the manual write protection
+func StopReadOnly(user string) error {
+	if DB == nil {
+		return nil
+	}
+	res, err := DB.Exec(`DELETE FROM settings WHERE key = ?`, readOnlyKey)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+	return LogSystemEvent(user, "Read-Only Ended", "Receiver writable")
+}
+
+// GetReadOnly returns the manual write protection, or nil when it is off or expired
+func GetReadOnly() *ReadOnly {
+	value := GetSetting(readOnlyKey, "")
+	if value == "" {
+		return nil
+	}
+	var ro ReadOnly
+	if err := json.Unmarshal([]byte(value), &ro); err != nil {
+		return nil
+	}
+	if ro.Until != nil && !time.Now().Before(*ro.Until) {
+		return nil
+	}
+	return &ro
+}
+
+// GetReadOnlySchedule returns the read-only windows in order
+func GetReadOnlySchedule() ([]ReadOnlyWindow, error) {
+	if DB == nil {
+		return nil, nil
+	}
+	rows, err := DB.Query(`SELECT name, days, start_time, end_time FROM read_only_schedule ORDER BY position`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var windows []ReadOnlyWindow
+	for rows.Next() {
+		var w ReadOnlyWindow
+		if err := rows.Scan(&w.Name, &w.Days, &w.Start, &w.End); err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, rows.Err()
+}
+
+// SaveReadOnlySchedule replaces the read-only schedule
+func SaveReadOnlySchedule(windows []ReadOnlyWindow) error {
+	if DB == nil {
+		return nil
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.Exec(`DELETE FROM read_only_schedule`); err != nil {
+		return err
+	}
+	for i, w := range windows {
+		if _, err := tx.Exec(`INSERT INTO read_only_schedule (position, name, days, start_time, end_time) VALUES (?, ?, ?, ?, ?)`,
+			i, w.Name, w.Days, w.Start, w.End); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReadOnly_SwitchAndSchedule(t *testing.T) {
+	setupMigratedDB(t)
+
+	if GetReadOnly() != nil {
+		t.Fatal("Receivers start writable")
+	}
+	if err := StartReadOnly(time.Hour, "fsck", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	ro := GetReadOnly()
+	if ro == nil || ro.Reason != "fsck" || ro.User != "admin" || ro.Until == nil {
+		t.Fatalf("Unexpected read-only state %+v", ro)
+	}
+	if err := StopReadOnly("admin"); err != nil {
+		t.Fatal(err)
+	}
+	if GetReadOnly() != nil {
+		t.Error("Stopping should lift the write protection")
+	}
+
+	// An expired switch no longer counts
+	if err := StartReadOnly(time.Nanosecond, "", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if GetReadOnly() != nil {
+		t.Error("Expired switches should be ignored")
+	}
+
+	windows := []ReadOnlyWindow{{Name: "scrub", Days: "sun", Start: "02:00", End: "05:00"}}
+	if err := SaveReadOnlySchedule(windows); err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetReadOnlySchedule()
+	if err != nil || len(got) != 1 || got[0] != windows[0] {
+		t.Errorf("Unexpected schedule %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// StoredSession is a login session; the token itself is never stored
+type StoredSession struct {
+	TokenHash string    `json:"-"`
+	User      string    `json:"user"`
+	Created   time.Time `json:"created"`
+	Expires   time.Time `json:"expires"`
+	Remember  bool      `json:"remember"` // Remember-me login
+}
+
+// SaveSession stores a session
+func SaveSession(s StoredSession) error {
+	if DB == nil {
+		return nil
+	}
+	_, err := DB.Exec(`INSERT OR REPLACE INTO sessions (token_hash, user, created, expires, remember) VALUES (?, ?, ?, ?, ?)`,
+		s.TokenHash, s.User, s.Created.Unix(), s.Expires.Unix(), s.Remember)
+	return err
+}
+
+// ExtendSession moves the expiry of a session
+func ExtendSession(tokenHash string, expires time.Time) error {
+	if DB == nil {
+		return nil
+	}
+	_, err := DB.Exec(`UPDATE sessions SET expires = ? WHERE token_hash = ?`, expires.Unix(), tokenHash)
+	return err
+}
+
+// GetSession returns the unexpired session with the token hash, nil if there is none
+func GetSession(tokenHash string) (*StoredSession, error) {
+	if DB == nil {
+		return nil, nil
+	}
+	s := StoredSession{TokenHash: tokenHash}
+	var created, expires int64
+	err := DB.QueryRow(`SELECT user, created, expires, remember FROM sessions WHERE token_hash = ? AND expires > ?`, tokenHash, time.Now().Unix()).
+		Scan(&s.User, &created, &expires, &s.Remember)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.Created, s.Expires = time.Unix(created, 0), time.Unix(expires, 0)
+	return &s, nil
+}
+
+// GetSessions returns the unexpired sessions, newest first
+func GetSessions() ([]StoredSession, error) {
+	if DB == nil {
+		return nil, nil
+	}
+	rows, err := DB.Query(`SELECT token_hash, user, created, expires, remember FROM sessions WHERE expires > ? ORDER BY created DESC`, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var sessions []StoredSession
+	for rows.Next() {
+		var s StoredSession
+		var created, expires int64
+		if err := rows.Scan(&s.TokenHash, &s.User, &created, &expires, &s.Remember); err != nil {
+			return nil, err
+		}
+		s.Created, s.Expires = time.Unix(created, 0), time.Unix(expires, 0)
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// DeleteSession removes a session
+func DeleteSession(tokenHash string) error {
+	if DB == nil {
+		return nil
+	}
+	_, err := DB.Exec(`DELETE FROM sessions WHERE token_hash = ?`, tokenHash)
+	return err
+}
+
+// DeleteAllSessions removes every session and returns how many were active
+func DeleteAllSessions() (int64, error) {
+	if DB == nil {
+		return 0, nil
+	}
+	res, err := DB.Exec(`DELETE FROM sessions WHERE expires > ?`, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	_, err = DB.Exec(`DELETE FROM sessions`)
+	return n, err
+}
+
+// PruneSessions deletes expired sessions and returns how many
+func PruneSessions() (int64, error) {
+	if DB == nil {
+		return 0, nil
+	}
+	res, err := DB.Exec(`DELETE FROM sessions WHERE expires <= ?`, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
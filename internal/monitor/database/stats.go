@@ -15,6 +15,7 @@ type DailyTraffic struct {
 	Date          string
 	Bytes         int64
 	Size          string
+	Cost          string // Estimated transfer cost, "" without COST_PER_GB
 	HeightPercent int
 }
 
@@ -23,7 +24,7 @@ func GetTrafficStats() TrafficStats {
 	if DB == nil {
 		return s
 	}
-	_ = DB.QueryRow("SELECT COALESCE(SUM(bytes_sent), 0) FROM traffic").Scan(&s.Total)
+	_ = DB.QueryRow("SELECT (SELECT COALESCE(SUM(bytes_sent), 0) FROM traffic) + (SELECT COALESCE(SUM(bytes_sent), 0) FROM traffic_monthly)").Scan(&s.Total)
 
 	// Fix: Use LIKE to ensure we match the date prefix correctly
 	todayPrefix := time.Now().Format("2006/01/02") + "%"
@@ -54,7 +55,7 @@ func GetEngineTrafficStats(engineID string) TrafficStats {
 	if DB == nil {
 		return s
 	}
-	_ = DB.QueryRow("SELECT COALESCE(SUM(bytes_sent), 0) FROM traffic WHERE engine_id=?", engineID).Scan(&s.Total)
+	_ = DB.QueryRow("SELECT (SELECT COALESCE(SUM(bytes_sent), 0) FROM traffic WHERE engine_id=?) + (SELECT COALESCE(SUM(bytes_sent), 0) FROM traffic_monthly WHERE engine_id=?)", engineID, engineID).Scan(&s.Total)
 
 	todayPrefix := time.Now().Format("2006/01/02") + "%"
 	_ = DB.QueryRow("SELECT COALESCE(SUM(bytes_sent), 0) FROM traffic WHERE engine_id=? AND date LIKE ?", engineID, todayPrefix).Scan(&s.Today)
@@ -86,6 +87,7 @@ func GetDailyTraffic(days int) []DailyTraffic {
 			continue
 		}
 		d.Size = FormatBytes(d.Bytes)
+		d.Cost = CostLabel(d.Bytes)
 		if d.Bytes > maxBytes {
 			maxBytes = d.Bytes
 		}
@@ -105,42 +107,75 @@ func GetDailyTraffic(days int) []DailyTraffic {
 	return results
 }
 
+// ReportEngineSuccess records a successful sync and ends the engine's open incident
 func ReportEngineSuccess(id string) {
 	if DB == nil {
 		return
 	}
 	_, _ = DB.Exec("INSERT INTO engine_stats (engine_id, success_count) VALUES (?, 1) ON CONFLICT(engine_id) DO UPDATE SET success_count = success_count + 1", id)
+	recordOutcome(id, true, "")
 }
 
+// ReportEngineError records a failed sync and opens an incident unless one is
+// open. Errors during maintenance do not count against the engine's health.
 func ReportEngineError(id string, msg string) {
-	if DB == nil {
+	if DB == nil || InMaintenance(id) {
 		return
 	}
 	_, _ = DB.Exec("INSERT INTO engine_stats (engine_id, error_count, last_error_msg) VALUES (?, 1, ?) ON CONFLICT(engine_id) DO UPDATE SET error_count = error_count + 1, last_error_msg = ?", id, msg, msg)
+	recordOutcome(id, false, msg)
 }
 
+// GetEngineHealth grades the decaying success rate of the engine's recent syncs
 func GetEngineHealth(id string) (grade string, color string) {
-	if DB == nil {
-		return "N/A", "#94a3b8"
-	}
-	var succ, err int
-	_ = DB.QueryRow("SELECT success_count, error_count FROM engine_stats WHERE engine_id=?", id).Scan(&succ, &err)
-	total := succ + err
-	if total == 0 {
+	score, samples := GetEngineHealthScore(id)
+	if samples == 0 {
 		return "N/A", "#94a3b8"
 	}
-	ratio := float64(succ) / float64(total)
-	if ratio >= 0.95 {
+	if score >= 0.95 {
 		return "A", "#00ffad"
 	}
-	if ratio >= 0.85 {
+	if score >= 0.85 {
 		return "B", "#0079d3"
 	}
-	if ratio >= 0.70 {
+	if score >= 0.70 {
 		return "C", "#ffb300"
 	}
-	if ratio >= 0.50 {
+	if score >= 0.50 {
 		return "D", "#f97316"
 	}
 	return "F", "#ff3d00"
 }
+
+// GetTrafficSince returns the bytes sent from the day of since onwards, for
+// one engine or, with an empty engineID, for all engines. Days already rolled
+// up count with their whole month.
+func GetTrafficSince(engineID string, since time.Time) int64 {
+	if DB == nil {
+		return 0
+	}
+	var total int64
+	from := since.Format("2006/01/02")
+	if engineID == "" {
+		_ = DB.QueryRow("SELECT COALESCE(SUM(bytes_sent), 0) FROM traffic WHERE date >= ?", from).Scan(&total)
+	} else {
+		_ = DB.QueryRow("SELECT COALESCE(SUM(bytes_sent), 0) FROM traffic WHERE engine_id = ? AND date >= ?", engineID, from).Scan(&total)
+	}
+	var rolledUp int64
+	month := since.Format("2006/01")
+	if engineID == "" {
+		_ = DB.QueryRow("SELECT COALESCE(SUM(bytes_sent), 0) FROM traffic_monthly WHERE month >= ?", month).Scan(&rolledUp)
+	} else {
+		_ = DB.QueryRow("SELECT COALESCE(SUM(bytes_sent), 0) FROM traffic_monthly WHERE engine_id = ? AND month >= ?", engineID, month).Scan(&rolledUp)
+	}
+	total += rolledUp
+
+	trafficMu.Lock()
+	for id, b := range unflushedBytes {
+		if engineID == "" || id == engineID {
+			total += b
+		}
+	}
+	trafficMu.Unlock()
+	return total
+}
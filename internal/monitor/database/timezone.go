@@ -0,0 +1,115 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// History times are stored as epoch seconds (history.ts, cycle_traffic.finished_ts)
+// with an ISO-8601 UTC copy in the text column, and shown in the configured
+// time zone (TZ).
+const (
+	// TimestampLayout is the ISO-8601 layout of the stored text copy
+	TimestampLayout = "2006-01-02T15:04:05Z"
+	// DisplayLayout is how times are shown
+	DisplayLayout = "2006-01-02 15:04:05"
+)
+
+// timestampLayouts are read by ParseTimestamp: ISO-8601 and what older
+// versions and the rsync log wrote
+var timestampLayouts = []string{time.RFC3339, DisplayLayout, "2006/01/02 15:04:05"}
+
+// historyUTCKey marks a database whose text timestamps are in UTC. Before,
+// they were written in local time.
+const historyUTCKey = "history_timestamps_utc"
+
+// Timestamp formats t for storage
+func Timestamp(t time.Time) string {
+	return t.UTC().Format(TimestampLayout)
+}
+
+// ParseTimestamp reads a timestamp in any layout schnorarr wrote, in loc
+// unless it names its zone
+func ParseTimestamp(ts string, loc *time.Location) (time.Time, bool) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.ParseInLocation(layout, ts, loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// LocalTime converts a timestamp event callbacks pass around (UTC) to the
+// configured time zone for display. Values that do not parse are returned
+// unchanged.
+func LocalTime(ts string) string {
+	t, ok := ParseTimestamp(ts, time.UTC)
+	if !ok {
+		return ts
+	}
+	return t.In(time.Local).Format(DisplayLayout)
+}
+
+// displayTime shows a stored epoch in the configured time zone
+func displayTime(ts sql.NullInt64, fallback string) string {
+	if !ts.Valid {
+		return fallback
+	}
+	return time.Unix(ts.Int64, 0).In(time.Local).Format(DisplayLayout)
+}
+
+// MigrateHistoryTimestamps fills in the epoch columns of rows written before
+// they existed and rewrites their text copy as ISO-8601 UTC. Text written
+// before timestamps were kept in UTC is read in legacy. It returns the
+// number of rows converted.
+func MigrateHistoryTimestamps(legacy *time.Location) (int, error) {
+	if DB == nil {
+		return 0, nil
+	}
+	loc := time.UTC
+	if GetSetting(historyUTCKey, "") != "true" {
+		loc = legacy
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	converted := 0
+	for _, table := range []struct{ name, key, text, epoch string }{
+		{"history", "id", "timestamp", "ts"},
+		{"cycle_traffic", "rowid", "finished", "finished_ts"},
+	} {
+		rows, err := tx.Query("SELECT " + table.key + ", COALESCE(" + table.text + ", '') FROM " + table.name + " WHERE " + table.epoch + " IS NULL")
+		if err != nil {
+			return 0, err
+		}
+		updates := make(map[int64]time.Time)
+		for rows.Next() {
+			var key int64
+			var ts string
+			if err := rows.Scan(&key, &ts); err != nil {
+				_ = rows.Close()
+				return 0, err
+			}
+			if t, ok := ParseTimestamp(ts, loc); ok {
+				updates[key] = t
+			}
+		}
+		_ = rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+		for key, t := range updates {
+			if _, err := tx.Exec("UPDATE "+table.name+" SET "+table.text+" = ?, "+table.epoch+" = ? WHERE "+table.key+" = ?", Timestamp(t), t.Unix(), key); err != nil {
+				return 0, err
+			}
+		}
+		converted += len(updates)
+	}
+	if _, err := tx.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, 'true')", historyUTCKey); err != nil {
+		return 0, err
+	}
+	return converted, tx.Commit()
+}
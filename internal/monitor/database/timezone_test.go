@@ -0,0 +1,69 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistoryTimestamps(t *testing.T) {
+	setupMigratedDB(t)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("No tzdata:", err)
+	}
+	local := time.Local
+	time.Local = berlin
+	defer func() { time.Local = local }()
+
+	// Rows of older versions: Berlin wall clock time as text, no epoch
+	_, _ = DB.Exec("INSERT INTO history (timestamp, action, file_path, engine_id) VALUES ('2024-07-01 12:00:00', 'Added', 'old.mkv', '1')")
+	_, _ = DB.Exec("INSERT INTO history (timestamp, action, file_path, engine_id) VALUES ('2024/01/10 08:30:00', 'Added', 'legacy.mkv', 'Legacy')")
+	_, _ = DB.Exec("INSERT INTO cycle_traffic (cycle_id, engine_id, bytes_sent, finished) VALUES ('c1', '1', 10, '2024-01-10 08:30:00')")
+	// Written by this version: UTC
+	if err := LogEvent("2024-07-01 11:00:00", "Added", "new.mkv", 1, "1", "c2"); err != nil {
+		t.Fatal(err)
+	}
+
+	converted, err := MigrateHistoryTimestamps(berlin)
+	if err != nil || converted != 3 {
+		t.Fatalf("Expected 3 converted rows, got %d, %v", converted, err)
+	}
+	var text string
+	var ts, finished int64
+	_ = DB.QueryRow("SELECT timestamp, ts FROM history WHERE file_path = 'old.mkv'").Scan(&text, &ts)
+	_ = DB.QueryRow("SELECT finished_ts FROM cycle_traffic").Scan(&finished)
+	if text != "2024-07-01T10:00:00Z" || ts != time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC).Unix() {
+		t.Errorf("Expected ISO UTC with summer offset, got %s / %d", text, ts)
+	}
+	if finished != time.Date(2024, 1, 10, 7, 30, 0, 0, time.UTC).Unix() {
+		t.Errorf("Expected the winter offset for the cycle, got %d", finished)
+	}
+	if again, _ := MigrateHistoryTimestamps(berlin); again != 0 {
+		t.Error("Converted rows must not be converted again")
+	}
+
+	// Reads show the configured zone
+	items, _ := GetHistory(10, 0, ".mkv")
+	want := map[string]string{"old.mkv": "2024-07-01 12:00:00", "legacy.mkv": "2024-01-10 08:30:00", "new.mkv": "2024-07-01 13:00:00"}
+	for _, i := range items {
+		if i.Time != want[i.Path] {
+			t.Errorf("%s: expected %s, got %s", i.Path, want[i.Path], i.Time)
+		}
+	}
+	if got := LocalTime("2024-07-01 11:00:00"); got != "2024-07-01 13:00:00" {
+		t.Errorf("LocalTime should convert UTC to Berlin, got %s", got)
+	}
+	if got := LocalTime("not a time"); got != "not a time" {
+		t.Errorf("Unparseable values should pass through, got %q", got)
+	}
+
+	// Range queries use the epoch
+	_ = LogSystemEvent("admin", "Recent", "")
+	if err := PruneHistory(30); err != nil {
+		t.Fatal(err)
+	}
+	items, _ = GetHistory(10, 0, "")
+	if len(items) != 1 || items[0].Action != "Recent" {
+		t.Errorf("Pruning should keep only the recent row, got %+v", items)
+	}
+}
@@ -1,7 +1,6 @@
 package database
 
 import (
-	"log"
 	"sync"
 	"time"
 )
@@ -30,7 +29,7 @@ func StartTrafficManager() {
 	go func() {
 		for range ticker.C {
 			if err := FlushTraffic(); err != nil {
-				log.Printf("[Database] Traffic flush failed: %v", err)
+				logger.Error("Traffic flush failed", "error", err)
 			}
 		}
 	}()
@@ -52,7 +51,9 @@ func FlushTraffic() error {
 	}
 	trafficMu.Unlock()
 
-	today := time.Now().Format("2006/01/02")
+	now := time.Now()
+	today := now.Format("2006/01/02")
+	hour := now.Truncate(time.Hour).Unix()
 	
 	tx, err := DB.Begin()
 	if err != nil {
@@ -64,9 +65,14 @@ func FlushTraffic() error {
 			VALUES (?, ?, ?) 
 			ON CONFLICT(date, engine_id) DO UPDATE SET bytes_sent = bytes_sent + ?`, 
 			today, id, bytes, bytes)
+		if err == nil {
+			_, err = tx.Exec(`INSERT INTO traffic_hourly (hour, engine_id, bytes_sent) VALUES (?, ?, ?)
+				ON CONFLICT(hour, engine_id) DO UPDATE SET bytes_sent = bytes_sent + excluded.bytes_sent`,
+				hour, id, bytes)
+		}
 		if err != nil {
 			if rbErr := tx.Rollback(); rbErr != nil {
-				log.Printf("[Database] Rollback failed: %v", rbErr)
+				logger.Error("Rollback failed", "error", rbErr)
 			}
 			// Put bytes back on failure
 			trafficMu.Lock()
@@ -83,3 +89,15 @@ func FlushTraffic() error {
 	// Vacuum occasionally or on demand? For now just log and continue
 	return nil
 }
+
+// SaveCycleTraffic records the bytes a sync cycle actually transferred
+func SaveCycleTraffic(engineID, cycleID string, bytes int64) error {
+	if DB == nil || cycleID == "" {
+		return nil
+	}
+	now := time.Now()
+	_, err := DB.Exec(`INSERT INTO cycle_traffic (cycle_id, engine_id, bytes_sent, finished, finished_ts) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(cycle_id, engine_id) DO UPDATE SET bytes_sent = bytes_sent + excluded.bytes_sent, finished = excluded.finished, finished_ts = excluded.finished_ts`,
+		cycleID, engineID, bytes, Timestamp(now), now.Unix())
+	return err
+}
@@ -0,0 +1,180 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Traffic is kept at three resolutions: hourly rows for TRAFFIC_HOURLY_DAYS,
+// daily rows (the traffic table) for TRAFFIC_DAILY_DAYS, then monthly rows
+// for TRAFFIC_MONTHLY_MONTHS (0 keeps them forever).
+const (
+	ResolutionHour  = "hour"
+	ResolutionDay   = "day"
+	ResolutionMonth = "month"
+)
+
+// TrafficRetention is how long each resolution is kept
+type TrafficRetention struct {
+	HourlyDays    int
+	DailyDays     int
+	MonthlyMonths int
+}
+
+// TrafficPoint is the traffic of one bucket of a series
+type TrafficPoint struct {
+	Start time.Time `json:"start"`
+	Bytes int64     `json:"bytes"`
+	Size  string    `json:"size"`
+}
+
+// GetTrafficRetention reads the retention from the environment
+func GetTrafficRetention() TrafficRetention {
+	return TrafficRetention{
+		HourlyDays:    envDays("TRAFFIC_HOURLY_DAYS", 7),
+		DailyDays:     envDays("TRAFFIC_DAILY_DAYS", 365),
+		MonthlyMonths: envDays("TRAFFIC_MONTHLY_MONTHS", 0),
+	}
+}
+
+func envDays(key string, def int) int {
+	n, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || n < 0 {
+		return def
+	}
+	return n
+}
+
+// hourlyCutoff is the first hour still kept at hourly resolution
+func (r TrafficRetention) hourlyCutoff(now time.Time) time.Time {
+	return now.AddDate(0, 0, -r.HourlyDays).Truncate(time.Hour)
+}
+
+// dailyCutoff is the first day still kept at daily resolution. Only whole
+// months are rolled up, so a month is never split across the two tables.
+func (r TrafficRetention) dailyCutoff(now time.Time) time.Time {
+	d := now.AddDate(0, 0, -r.DailyDays)
+	return time.Date(d.Year(), d.Month(), 1, 0, 0, 0, 0, time.Local)
+}
+
+// RollupTraffic drops hourly rows past their retention (the daily rows
+// already count them), moves daily rows of whole months past theirs into
+// traffic_monthly and expires monthly rows
+func RollupTraffic(r TrafficRetention) error {
+	if DB == nil {
+		return nil
+	}
+	now := time.Now()
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM traffic_hourly WHERE hour < ?`, r.hourlyCutoff(now).Unix()); err != nil {
+		return err
+	}
+	day := r.dailyCutoff(now).Format("2006/01/02")
+	if _, err := tx.Exec(`INSERT INTO traffic_monthly (month, engine_id, bytes_sent)
+		SELECT substr(date, 1, 7), engine_id, SUM(bytes_sent) FROM traffic WHERE date < ? GROUP BY substr(date, 1, 7), engine_id
+		ON CONFLICT(month, engine_id) DO UPDATE SET bytes_sent = bytes_sent + excluded.bytes_sent`, day); err != nil {
+		return err
+	}
+	res, err := tx.Exec(`DELETE FROM traffic WHERE date < ?`, day)
+	if err != nil {
+		return err
+	}
+	if r.MonthlyMonths > 0 {
+		month := now.AddDate(0, -r.MonthlyMonths, 0).Format("2006/01")
+		if _, err := tx.Exec(`DELETE FROM traffic_monthly WHERE month < ?`, month); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		logger.Info("Rolled up daily traffic into months", "rows", n, "before", day)
+	}
+	return nil
+}
+
+// TrafficResolution picks the finest resolution that is still kept for from
+// and gives a manageable number of points for the range
+func TrafficResolution(from, to time.Time, r TrafficRetention) string {
+	now := time.Now()
+	span := to.Sub(from)
+	if span <= 72*time.Hour && !from.Before(r.hourlyCutoff(now)) {
+		return ResolutionHour
+	}
+	if span <= 400*24*time.Hour && !from.Before(r.dailyCutoff(now)) {
+		return ResolutionDay
+	}
+	return ResolutionMonth
+}
+
+// GetTrafficSeries returns the traffic from from up to to at the given
+// resolution, for one engine or, with an empty engineID, for all engines.
+// Buckets without traffic are left out.
+func GetTrafficSeries(engineID string, from, to time.Time, resolution string) ([]TrafficPoint, error) {
+	if DB == nil {
+		return nil, nil
+	}
+	var query string
+	var args []interface{}
+	switch resolution {
+	case ResolutionHour:
+		query = `SELECT hour, SUM(bytes_sent) FROM traffic_hourly WHERE hour >= ? AND hour < ?`
+		args = []interface{}{from.Truncate(time.Hour).Unix(), to.Unix()}
+	case ResolutionDay:
+		query = `SELECT date, SUM(bytes_sent) FROM traffic WHERE date >= ? AND date <= ?`
+		args = []interface{}{from.Format("2006/01/02"), to.Format("2006/01/02")}
+	case ResolutionMonth:
+		// Months still in the daily table are summed up on the fly
+		query = `SELECT month, SUM(bytes_sent) FROM (
+			SELECT month, engine_id, bytes_sent FROM traffic_monthly
+			UNION ALL SELECT substr(date, 1, 7), engine_id, bytes_sent FROM traffic
+		) WHERE month >= ? AND month <= ?`
+		args = []interface{}{from.Format("2006/01"), to.Format("2006/01")}
+	default:
+		return nil, fmt.Errorf("unknown resolution %q", resolution)
+	}
+	if engineID != "" {
+		query += ` AND engine_id = ?`
+		args = append(args, engineID)
+	}
+	query += ` GROUP BY 1 ORDER BY 1`
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	points := []TrafficPoint{}
+	for rows.Next() {
+		var bucket interface{}
+		var p TrafficPoint
+		if err := rows.Scan(&bucket, &p.Bytes); err != nil {
+			return nil, err
+		}
+		switch b := bucket.(type) {
+		case int64:
+			p.Start = time.Unix(b, 0).In(time.Local)
+		case string:
+			layout := "2006/01/02"
+			if resolution == ResolutionMonth {
+				layout = "2006/01"
+			}
+			t, err := time.ParseInLocation(layout, b, time.Local)
+			if err != nil {
+				continue
+			}
+			p.Start = t
+		}
+		p.Size = FormatBytes(p.Bytes)
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRollupTraffic(t *testing.T) {
+	setupMigratedDB(t)
+	now := time.Now()
+	old := now.AddDate(-2, 0, 0)
+	oldMonth := time.Date(old.Year(), old.Month(), 1, 0, 0, 0, 0, time.Local)
+
+	for _, d := range []time.Time{oldMonth, oldMonth.AddDate(0, 0, 1), now.AddDate(0, 0, -1)} {
+		_, _ = DB.Exec("INSERT INTO traffic (date, engine_id, bytes_sent) VALUES (?, '1', 100)", d.Format("2006/01/02"))
+	}
+	_, _ = DB.Exec("INSERT INTO traffic (date, engine_id, bytes_sent) VALUES (?, '2', 50)", oldMonth.Format("2006/01/02"))
+	_, _ = DB.Exec("INSERT INTO traffic_hourly (hour, engine_id, bytes_sent) VALUES (?, '1', 10)", now.AddDate(0, 0, -30).Unix())
+	if err := AddTraffic("1", 7); err != nil {
+		t.Fatal(err)
+	}
+	if err := FlushTraffic(); err != nil {
+		t.Fatal(err)
+	}
+	before := GetTrafficStats().Total
+
+	if err := RollupTraffic(TrafficRetention{HourlyDays: 7, DailyDays: 365}); err != nil {
+		t.Fatal(err)
+	}
+	var days, hours, months int
+	_ = DB.QueryRow("SELECT COUNT(*) FROM traffic").Scan(&days)
+	_ = DB.QueryRow("SELECT COUNT(*) FROM traffic_hourly").Scan(&hours)
+	_ = DB.QueryRow("SELECT COUNT(*) FROM traffic_monthly").Scan(&months)
+	if days != 2 || hours != 1 || months != 2 {
+		t.Errorf("Expected 2 days, 1 hour and 2 months left, got %d, %d, %d", days, hours, months)
+	}
+	if after := GetTrafficStats().Total; after != before {
+		t.Errorf("Rollups must keep the total, got %d before and %d after", before, after)
+	}
+	if got := GetEngineTrafficStats("1").Total; got != 307 {
+		t.Errorf("Expected 307 bytes for engine 1, got %d", got)
+	}
+
+	points, err := GetTrafficSeries("1", oldMonth, now, ResolutionMonth)
+	if err != nil || len(points) != 2 || points[0].Bytes != 200 || !points[0].Start.Equal(oldMonth) {
+		t.Errorf("Expected the rolled up month first, got %+v, %v", points, err)
+	}
+	points, _ = GetTrafficSeries("", now.Add(-time.Hour), now, ResolutionHour)
+	if len(points) != 1 || points[0].Bytes != 7 {
+		t.Errorf("Expected this hour's flush, got %+v", points)
+	}
+	if _, err := GetTrafficSeries("", oldMonth, now, "week"); err == nil {
+		t.Error("Unknown resolutions should be rejected")
+	}
+
+	r := GetTrafficRetention()
+	for _, c := range []struct {
+		from time.Time
+		want string
+	}{
+		{now.Add(-24 * time.Hour), ResolutionHour},
+		{now.AddDate(0, 0, -30), ResolutionDay},
+		{oldMonth, ResolutionMonth},
+	} {
+		if got := TrafficResolution(c.from, now, r); got != c.want {
+			t.Errorf("From %s: expected %s, got %s", c.from, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package database
+
+import "time"
+
+// Undoable actions of a sync cycle
+const (
+	UndoDelete = "delete" // Path was moved to TrashPath
+	UndoRmdir  = "rmdir"  // Empty directory Path was removed
+	UndoRename = "rename" // Path was renamed to NewPath
+)
+
+// UndoAction records how to reverse one change of a sync cycle
+type UndoAction struct {
+	ID        int64
+	EngineID  string
+	CycleID   string
+	Timestamp int64
+	Action    string
+	Path      string
+	NewPath   string
+	TrashPath string
+}
+
+// RecordUndoAction stores how to reverse a change
+func RecordUndoAction(a UndoAction) error {
+	if DB == nil {
+		return nil
+	}
+	_, err := DB.Exec(`INSERT INTO undo_actions (engine_id, cycle_id, timestamp, action, path, new_path, trash_path) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		a.EngineID, a.CycleID, time.Now().Unix(), a.Action, a.Path, a.NewPath, a.TrashPath)
+	return err
+}
+
+// GetLastUndoCycle returns the actions of the engine's most recent undoable
+// cycle newer than since, in the order they happened; "" when there is none
+func GetLastUndoCycle(engineID string, since time.Time) (string, []UndoAction, error) {
+	if DB == nil {
+		return "", nil, nil
+	}
+	var cycle string
+	err := DB.QueryRow(`SELECT cycle_id FROM undo_actions WHERE engine_id = ? AND timestamp >= ? ORDER BY id DESC LIMIT 1`,
+		engineID, since.Unix()).Scan(&cycle)
+	if err != nil {
+		return "", nil, nil
+	}
+	rows, err := DB.Query(`SELECT id, engine_id, cycle_id, timestamp, action, path, new_path, trash_path FROM undo_actions
+		WHERE engine_id = ? AND cycle_id = ? ORDER BY id`, engineID, cycle)
+	if err != nil {
+		return "", nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var actions []UndoAction
+	for rows.Next() {
+		var a UndoAction
+		if err := rows.Scan(&a.ID, &a.EngineID, &a.CycleID, &a.Timestamp, &a.Action, &a.Path, &a.NewPath, &a.TrashPath); err != nil {
+			return "", nil, err
+		}
+		actions = append(actions, a)
+	}
+	return cycle, actions, rows.Err()
+}
+
+// ExpiredUndoCycles returns the engine's cycles whose last change is older than before
+func ExpiredUndoCycles(engineID string, before time.Time) ([]string, error) {
+	if DB == nil {
+		return nil, nil
+	}
+	rows, err := DB.Query(`SELECT cycle_id FROM undo_actions WHERE engine_id = ? GROUP BY cycle_id HAVING MAX(timestamp) < ?`,
+		engineID, before.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var cycles []string
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err == nil {
+			cycles = append(cycles, c)
+		}
+	}
+	return cycles, rows.Err()
+}
+
+// DeleteUndoActions forgets the undo records of a cycle
+func DeleteUndoActions(engineID, cycleID string) error {
+	if DB == nil {
+		return nil
+	}
+	_, err := DB.Exec(`DELETE FROM undo_actions WHERE engine_id = ? AND cycle_id = ?`, engineID, cycleID)
+	return err
+}
+
+// DeleteUndoAction forgets one undo record
+func DeleteUndoAction(id int64) error {
+	if DB == nil {
+		return nil
+	}
+	_, err := DB.Exec(`DELETE FROM undo_actions WHERE id = ?`, id)
+	return err
+}
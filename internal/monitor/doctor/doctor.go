@@ -0,0 +1,210 @@
+package doctor
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/sync"
+)
+
+// Severity levels of a finding
+const (
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+)
+
+// maxIntegrityLines caps how many integrity_check lines are reported
+const maxIntegrityLines = 10
+
+// Engine is the configuration of a sync engine that is validated against the filesystem
+type Engine struct {
+	ID        string
+	SourceDir string
+	TargetDir string
+}
+
+// Finding is a single problem detected by the doctor
+type Finding struct {
+	ID          string `json:"id"`
+	Check       string `json:"check"`
+	Severity    string `json:"severity"`
+	Message     string `json:"message"`
+	Fix         string `json:"fix,omitempty"`
+	Repairable  bool   `json:"repairable"`
+	Repaired    bool   `json:"repaired"`
+	RepairError string `json:"repair_error,omitempty"`
+	repair      func() error
+}
+
+// Report is the result of a doctor run
+type Report struct {
+	Findings []*Finding `json:"findings"`
+}
+
+func (r *Report) add(f *Finding) {
+	f.Repairable = f.repair != nil
+	r.Findings = append(r.Findings, f)
+}
+
+// Healthy reports whether no error is left unrepaired
+func (r *Report) Healthy() bool {
+	for _, f := range r.Findings {
+		if f.Severity == SeverityError && !f.Repaired {
+			return false
+		}
+	}
+	return true
+}
+
+// Repair fixes the repairable findings accepted by choose and returns how many were repaired
+func (r *Report) Repair(choose func(*Finding) bool) int {
+	repaired := 0
+	for _, f := range r.Findings {
+		if f.repair == nil || f.Repaired || !choose(f) {
+			continue
+		}
+		if err := f.repair(); err != nil {
+			f.RepairError = err.Error()
+			continue
+		}
+		f.Repaired = true
+		f.RepairError = ""
+		repaired++
+	}
+	return repaired
+}
+
+// Run checks the database and the engine configuration. Rows of engines that are
+// not in engines are reported as orphaned; the check is skipped when engines is empty.
+func Run(engines []Engine) *Report {
+	r := &Report{Findings: make([]*Finding, 0)}
+	missing := checkSchema(r)
+	checkEngines(r, engines)
+	if len(engines) > 0 {
+		checkOrphans(r, engines, missing)
+	}
+	checkQueues(r, missing)
+	return r
+}
+
+// checkSchema runs the integrity check and returns the set of missing tables
+func checkSchema(r *Report) map[string]bool {
+	problems, err := database.IntegrityCheck()
+	if err != nil {
+		r.add(&Finding{ID: "integrity", Check: "database", Severity: SeverityError, Message: fmt.Sprintf("Integrity check failed: %v", err)})
+	} else if len(problems) > 0 {
+		if len(problems) > maxIntegrityLines {
+			problems = append(problems[:maxIntegrityLines], fmt.Sprintf("... and %d more", len(problems)-maxIntegrityLines))
+		}
+		r.add(&Finding{ID: "integrity", Check: "database", Severity: SeverityError,
+			Message: "Database is corrupt: " + strings.Join(problems, "; "),
+			Fix:     "Stop the container and restore history.db from a backup"})
+	}
+
+	missing := make(map[string]bool)
+	tables, err := database.MissingTables()
+	if err != nil {
+		r.add(&Finding{ID: "schema", Check: "database", Severity: SeverityError, Message: fmt.Sprintf("Schema check failed: %v", err)})
+		return missing
+	}
+	if len(tables) == 0 {
+		return missing
+	}
+	for _, t := range tables {
+		missing[t] = true
+	}
+	r.add(&Finding{ID: "schema", Check: "database", Severity: SeverityError,
+		Message: "Missing tables: " + strings.Join(tables, ", "),
+		Fix:     "Re-run the migrations that create them",
+		repair:  func() error { return database.RepairSchema(tables) }})
+	return missing
+}
+
+// checkEngines validates source and target directories of every engine
+func checkEngines(r *Report, engines []Engine) {
+	for _, e := range engines {
+		info, err := os.Stat(e.SourceDir)
+		switch {
+		case err != nil:
+			r.add(&Finding{ID: "source:" + e.ID, Check: "engine", Severity: SeverityError,
+				Message: fmt.Sprintf("Engine %s: source %s is not accessible: %v", e.ID, e.SourceDir, err),
+				Fix:     "Check the volume mount and SYNC_" + e.ID + "_SOURCE"})
+		case !info.IsDir():
+			r.add(&Finding{ID: "source:" + e.ID, Check: "engine", Severity: SeverityError,
+				Message: fmt.Sprintf("Engine %s: source %s is not a directory", e.ID, e.SourceDir)})
+		}
+
+		if e.TargetDir == "" || sync.IsRemotePath(e.TargetDir) {
+			continue
+		}
+		if _, err := os.Stat(e.TargetDir); os.IsNotExist(err) {
+			target := e.TargetDir
+			r.add(&Finding{ID: "target:" + e.ID, Check: "engine", Severity: SeverityWarning,
+				Message: fmt.Sprintf("Engine %s: local target %s does not exist", e.ID, target),
+				Fix:     "Create the target directory",
+				repair:  func() error { return os.MkdirAll(target, 0755) }})
+		}
+		if _, err := sync.ResolvePathOverlap(e.SourceDir, e.TargetDir); err != nil {
+			r.add(&Finding{ID: "overlap:" + e.ID, Check: "engine", Severity: SeverityError,
+				Message: fmt.Sprintf("Engine %s: %v", e.ID, err)})
+		}
+	}
+}
+
+// checkOrphans finds state rows of engines that are no longer configured
+func checkOrphans(r *Report, engines []Engine, missing map[string]bool) {
+	known := make(map[string]bool, len(engines))
+	for _, e := range engines {
+		known[e.ID] = true
+	}
+	for _, table := range database.EngineStateTables {
+		if missing[table] {
+			continue
+		}
+		ids, err := database.EngineIDs(table)
+		if err != nil {
+			r.add(&Finding{ID: "orphan:" + table, Check: "state", Severity: SeverityError, Message: fmt.Sprintf("Failed to read %s: %v", table, err)})
+			continue
+		}
+		for _, id := range ids {
+			if known[id] {
+				continue
+			}
+			r.add(&Finding{ID: "orphan:" + table + ":" + id, Check: "state", Severity: SeverityWarning,
+				Message: fmt.Sprintf("%s has rows for unconfigured engine %q", table, id),
+				Fix:     "Delete the rows",
+				repair:  func() error { return database.DeleteEngineRows(table, id) }})
+		}
+	}
+}
+
+// checkQueues finds queued syncs that cannot be resumed and stale approval flags
+func checkQueues(r *Report, missing map[string]bool) {
+	if !missing["engine_queue"] {
+		ids, err := database.InvalidQueues()
+		if err != nil {
+			r.add(&Finding{ID: "queue", Check: "state", Severity: SeverityError, Message: fmt.Sprintf("Failed to read queued syncs: %v", err)})
+		}
+		for _, id := range ids {
+			r.add(&Finding{ID: "queue:" + id, Check: "state", Severity: SeverityError,
+				Message: fmt.Sprintf("Engine %s: queued sync has an unreadable manifest", id),
+				Fix:     "Reset the queue; the next scan plans the sync again",
+				repair:  func() error { return database.ClearEngineQueue(id) }})
+		}
+	}
+
+	if !missing["engine_state"] && !missing["engine_pending_actions"] {
+		ids, err := database.StaleApprovals()
+		if err != nil {
+			r.add(&Finding{ID: "approval", Check: "state", Severity: SeverityError, Message: fmt.Sprintf("Failed to read engine state: %v", err)})
+		}
+		for _, id := range ids {
+			r.add(&Finding{ID: "approval:" + id, Check: "state", Severity: SeverityWarning,
+				Message: fmt.Sprintf("Engine %s: waiting for approval without pending deletions", id),
+				Fix:     "Reset the approval flag",
+				repair:  func() error { return database.ResetApproval(id) }})
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package doctor
+
+import (
+	"path/filepath"
+	"testing"
+
+	"schnorarr/internal/monitor/database"
+)
+
+func setupDB(t *testing.T) {
+	t.Helper()
+	database.DBPath = filepath.Join(t.TempDir(), "doctor.db")
+	if err := database.Init(); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := database.DB.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	})
+}
+
+func findingIDs(r *Report) map[string]*Finding {
+	ids := make(map[string]*Finding)
+	for _, f := range r.Findings {
+		ids[f.ID] = f
+	}
+	return ids
+}
+
+func TestRun_CleanDatabase(t *testing.T) {
+	setupDB(t)
+	source := t.TempDir()
+	target := t.TempDir()
+	if err := database.SaveEngineState("1", false, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	report := Run([]Engine{{ID: "1", SourceDir: source, TargetDir: target}})
+	if len(report.Findings) != 0 {
+		t.Fatalf("Expected no findings, got %+v", report.Findings[0])
+	}
+	if !report.Healthy() {
+		t.Error("Clean database should be healthy")
+	}
+}
+
+func TestRun_StateRowsRepair(t *testing.T) {
+	setupDB(t)
+	source := t.TempDir()
+
+	// Engine 9 was removed from the configuration
+	if err := database.SaveEngineState("9", true, []string{"old.mkv"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	// Engine 1 is stuck waiting without anything to approve and has a broken queue
+	if err := database.SaveEngineState("1", true, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.DB.Exec(`INSERT INTO engine_queue (engine_id, manifest_json, timestamp) VALUES ('1', '{"files":', 0)`); err != nil {
+		t.Fatal(err)
+	}
+
+	engines := []Engine{{ID: "1", SourceDir: source, TargetDir: filepath.Join(t.TempDir(), "missing")}}
+	report := Run(engines)
+	found := findingIDs(report)
+	for _, id := range []string{"orphan:engine_state:9", "orphan:engine_pending_actions:9", "approval:1", "queue:1", "target:1"} {
+		f, ok := found[id]
+		if !ok {
+			t.Errorf("Expected finding %s, got %v", id, found)
+			continue
+		}
+		if !f.Repairable {
+			t.Errorf("Finding %s should be repairable", id)
+		}
+	}
+	if report.Healthy() {
+		t.Error("Unreadable queue should make the report unhealthy")
+	}
+
+	if n := report.Repair(func(*Finding) bool { return true }); n != len(report.Findings) {
+		t.Errorf("Expected all %d findings repaired, got %d", len(report.Findings), n)
+	}
+	if !report.Healthy() {
+		t.Error("Report should be healthy after repair")
+	}
+	if again := Run(engines); len(again.Findings) != 0 {
+		t.Errorf("Expected no findings after repair, got %+v", again.Findings[0])
+	}
+}
+
+func TestRun_MissingSourceNotRepairable(t *testing.T) {
+	setupDB(t)
+
+	report := Run([]Engine{{ID: "1", SourceDir: filepath.Join(t.TempDir(), "gone"), TargetDir: "syncuser@host::module/tv"}})
+	f, ok := findingIDs(report)["source:1"]
+	if !ok {
+		t.Fatal("Expected a finding for the missing source")
+	}
+	if f.Repairable || f.Severity != SeverityError {
+		t.Errorf("Missing source should be a non-repairable error, got %+v", f)
+	}
+	if report.Repair(func(*Finding) bool { return true }) != 0 || report.Healthy() {
+		t.Error("Missing source must stay unhealthy")
+	}
+}
+
+func TestRun_SchemaRepair(t *testing.T) {
+	setupDB(t)
+	if _, err := database.DB.Exec(`DROP TABLE engine_queue`); err != nil {
+		t.Fatal(err)
+	}
+
+	report := Run(nil)
+	f, ok := findingIDs(report)["schema"]
+	if !ok || !f.Repairable {
+		t.Fatalf("Expected a repairable schema finding, got %+v", report.Findings)
+	}
+	report.Repair(func(*Finding) bool { return true })
+	if !f.Repaired {
+		t.Fatalf("Schema repair failed: %s", f.RepairError)
+	}
+	if err := database.SaveEngineQueue("1", nil); err != nil {
+		t.Errorf("engine_queue should exist after repair: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+// Package errclass recognizes common failure signatures in error messages
+// and maps them to a code with a remediation hint, so users read what went
+// wrong and what to do about it instead of raw Go and rsync errors.
+package errclass
+
+import (
+	"strings"
+
+	"schnorarr/internal/monitor/i18n"
+)
+
+// Error codes
+const (
+	PermissionDenied  = "permission_denied"
+	DiskFull          = "disk_full"
+	ReadOnly          = "read_only"
+	AuthFailed        = "auth_failed"
+	ConnectionRefused = "connection_refused"
+	Unreachable       = "unreachable"
+	MissingPath       = "missing_path"
+)
+
+// Class is a classified error. Problem and Hint are texts of the i18n
+// catalog (error.<code> and error.<code>.hint).
+type Class struct {
+	Code    string `json:"code"`
+	Problem string `json:"problem"`
+	Hint    string `json:"hint"`
+}
+
+// signatures are matched in order against the lower-cased message, so more
+// specific ones come first: rsync reports a refused login as an error of the
+// connection, and a read-only file system as a failed write.
+var signatures = []struct {
+	code     string
+	patterns []string
+}{
+	{AuthFailed, []string{"auth failed", "authentication failed", "password mismatch", "unauthorized", "status 401"}},
+	{ReadOnly, []string{"read-only file system", "receiver is read-only"}},
+	{DiskFull, []string{"no space left on device", "disk quota exceeded", "enospc"}},
+	{PermissionDenied, []string{"permission denied", "operation not permitted", "access denied", "access is denied"}},
+	{ConnectionRefused, []string{"connection refused"}},
+	{Unreachable, []string{"no route to host", "network is unreachable", "i/o timeout", "connection timed out", "connection reset", "no such host", "deadline exceeded"}},
+	{MissingPath, []string{"no such file or directory", "cannot find the path"}},
+}
+
+// Code returns the code of a message, "" when no signature matches
+func Code(msg string) string {
+	lower := strings.ToLower(msg)
+	for _, s := range signatures {
+		for _, p := range s.patterns {
+			if strings.Contains(lower, p) {
+				return s.code
+			}
+		}
+	}
+	return ""
+}
+
+// Classify classifies a message in the configured locale, nil when no
+// signature matches
+func Classify(msg string) *Class {
+	return ClassifyIn(i18n.Current(), msg)
+}
+
+// ClassifyIn classifies a message in locale, nil when no signature matches
+func ClassifyIn(locale, msg string) *Class {
+	code := Code(msg)
+	if code == "" {
+		return nil
+	}
+	return &Class{Code: code, Problem: i18n.In(locale, "error."+code), Hint: i18n.In(locale, "error."+code+".hint")}
+}
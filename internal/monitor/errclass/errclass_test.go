@@ -0,0 +1,34 @@
+package errclass
+
+import "testing"
+
+func TestCode(t *testing.T) {
+	for msg, want := range map[string]string{
+		"Failed to copy a.mkv: open /mnt/tv/a.mkv: permission denied":                            PermissionDenied,
+		"write /mnt/tv/a.mkv: no space left on device":                                           DiskFull,
+		"Failed to create dir x: mkdir /mnt/tv/x: read-only file system":                         ReadOnly,
+		"@ERROR: auth failed on module video-sync":                                               AuthFailed,
+		"rsync: failed to connect to 10.0.0.2 (10.0.0.2): Connection refused (111)":              ConnectionRefused,
+		"Get \"http://receiver:8080/health\": dial tcp 10.0.0.2:8080: connect: no route to host": Unreachable,
+		"failed to open source file: open /data/a.mkv: no such file or directory":                MissingPath,
+		"rsync exited with code 23":                                                              "",
+	} {
+		if got := Code(msg); got != want {
+			t.Errorf("%q: expected %q, got %q", msg, want, got)
+		}
+	}
+}
+
+func TestClassifyIn(t *testing.T) {
+	if c := ClassifyIn("en", "something odd"); c != nil {
+		t.Errorf("Unknown errors are not classified, got %+v", c)
+	}
+	for _, sig := range signatures {
+		for _, locale := range []string{"en", "de"} {
+			c := ClassifyIn(locale, sig.patterns[0])
+			if c == nil || c.Code != sig.code || c.Problem == "error."+sig.code || c.Hint == "error."+sig.code+".hint" {
+				t.Errorf("%s/%s: missing catalog texts, got %+v", locale, sig.code, c)
+			}
+		}
+	}
+}
@@ -1,155 +1,1217 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	pathpkg "path"
+	"strconv"
 	"strings"
 	"time"
 
+	"schnorarr/internal/agent"
+	"schnorarr/internal/bandwidth"
+	"schnorarr/internal/discovery"
+	"schnorarr/internal/monitor/config"
 	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/monitor/doctor"
+	"schnorarr/internal/monitor/i18n"
+	"schnorarr/internal/monitor/logging"
+	"schnorarr/internal/monitor/notification"
+	"schnorarr/internal/monitor/scheduler"
+	"schnorarr/internal/monitor/system"
 	"schnorarr/internal/sync"
 )
 
 func (h *Handlers) Health(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	status := "healthy"
-	_ = json.NewEncoder(w).Encode(map[string]string{"status": status, "time": time.Now().String()})
+	resp := map[string]interface{}{"status": status, "time": time.Now().String(), "system": system.Collect(), "load": system.CollectLoad()}
+	if h.diskProvider != nil {
+		resp["disks"] = h.diskProvider()
+	}
+	if h.healthState != nil {
+		if floors := h.healthState.SpeedFloors(); len(floors) > 0 {
+			resp["speed_floor"] = floors
+		}
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// SystemMetrics returns process resource usage of this instance and, on a sender, of the receiver
+func (h *Handlers) SystemMetrics(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"local":    system.Collect(),
+			"receiver": h.healthState.GetReceiverSystem(),
+		})
+	})(w, r)
+}
+
+// Latency returns the receiver latency and loss: live stats of the last
+// checks and the stored 5 minute buckets of the last ?hours= (default 24)
+func (h *Handlers) Latency(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		hours := 24
+		if v := r.URL.Query().Get("hours"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 24*90 {
+				http.Error(w, "hours must be between 1 and 2160", http.StatusBadRequest)
+				return
+			}
+			hours = n
+		}
+		history, err := database.GetLatencyStats(time.Now().Add(-time.Duration(hours) * time.Hour))
+		if err != nil {
+			http.Error(w, "Failed to load latency stats", http.StatusInternalServerError)
+			return
+		}
+		if history == nil {
+			history = []database.LatencyStats{}
+		}
+		resp := map[string]interface{}{"history": history, "current": nil}
+		if h.latency != nil {
+			resp["current"] = h.latency()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})(w, r)
+}
+
+// ValidateConfig checks the configuration in the environment and returns the
+// report with the pass/fail state of every engine
+func (h *Handlers) ValidateConfig(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		report := config.NewReport()
+		if h.validator != nil {
+			report = h.validator()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(report)
+	})(w, r)
+}
+
+// Doctor runs the consistency checks. POST repairs the findings listed in
+// "repair" (or all of them with "repair_all") and returns the updated report.
+func (h *Handlers) Doctor(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Repair    []string `json:"repair"`
+			RepairAll bool     `json:"repair_all"`
+		}
+		switch r.Method {
+		case "GET":
+		case "POST":
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid body", 400)
+				return
+			}
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var engines []doctor.Engine
+		for _, e := range h.engineProvider() {
+			cfg := e.GetConfig()
+			engines = append(engines, doctor.Engine{ID: cfg.ID, SourceDir: cfg.SourceDir, TargetDir: cfg.TargetDir})
+		}
+		report := doctor.Run(engines)
+		if req.RepairAll || len(req.Repair) > 0 {
+			selected := make(map[string]bool, len(req.Repair))
+			for _, id := range req.Repair {
+				selected[id] = true
+			}
+			repaired := report.Repair(func(f *doctor.Finding) bool { return req.RepairAll || selected[f.ID] })
+			if repaired > 0 {
+				_ = database.LogSystemEvent(h.GetUser(r), "Doctor Repair", fmt.Sprintf("Repaired %d findings", repaired))
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"healthy":  report.Healthy(),
+			"findings": report.Findings,
+		})
+	})(w, r)
+}
+
+func (h *Handlers) GetProgressInfo() (progress, speed, eta string, queued int, status string) {
+	return h.progressInfo(i18n.Current())
+}
+
+// progressInfo is GetProgressInfo with the progress label in locale
+func (h *Handlers) progressInfo(locale string) (progress, speed, eta string, queued int, status string) {
+	var totalSpeed int64
+	var totalRemaining int64
+	allPaused := true
+	var sb strings.Builder
+	for _, engine := range h.engineProvider() {
+		sb.WriteString(engine.GetStatus() + "\n")
+		if !engine.IsPaused() {
+			allPaused = false
+		}
+		_, transferredBytes, _, currentSpeed := engine.GetTransferStats()
+		totalSpeed += currentSpeed
+		totalRemaining += (engine.GetPlanRemainingBytes() - transferredBytes)
+		queuedCount, queuedSize := engine.GetQueuedStats()
+		totalRemaining += queuedSize
+		queued += queuedCount
+	}
+	status = sb.String()
+	speed = database.FormatBytes(totalSpeed) + "/s"
+	if totalSpeed > 0 && totalRemaining > 0 {
+		if totalRemaining < 0 {
+			totalRemaining = 0
+		}
+		sec := totalRemaining / totalSpeed
+		if sec > 3600 {
+			eta = fmt.Sprintf("%dh %dm", sec/3600, (sec%3600)/60)
+		} else if sec > 60 {
+			eta = fmt.Sprintf("%dm %ds", sec/60, sec%60)
+		} else {
+			eta = fmt.Sprintf("%ds", sec)
+		}
+	} else {
+		eta = "Done"
+	}
+	progress = i18n.In(locale, "status.monitoring")
+	if allPaused && len(h.engineProvider()) > 0 {
+		progress = i18n.In(locale, "status.paused")
+	} else if totalSpeed > 0 {
+		progress = i18n.In(locale, "status.transferring")
+	}
+	return progress, speed, eta, queued, status
+}
+
+func (h *Handlers) ManualSync(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		for _, e := range h.engineProvider() {
+			e.Resume()
+			_ = database.SaveSetting("engine_paused_"+e.GetConfig().ID, "false")
+		}
+		redirect(w, r, "/")
+	})(w, r)
+}
+
+func (h *Handlers) GlobalPause(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		for _, e := range h.engineProvider() {
+			e.Pause()
+			_ = database.SaveSetting("engine_paused_"+e.GetConfig().ID, "true")
+		}
+		_ = database.LogSystemEvent(h.GetUser(r), "Paused All", "User paused all engines")
+		if r.Header.Get("Accept") == "application/json" {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+			return
+		}
+		redirect(w, r, "/")
+	})(w, r)
+}
+
+func (h *Handlers) GlobalResume(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		for _, e := range h.engineProvider() {
+			e.Resume()
+			_ = database.SaveSetting("engine_paused_"+e.GetConfig().ID, "false")
+		}
+		_ = database.LogSystemEvent(h.GetUser(r), "Resumed All", "User resumed all engines")
+		if r.Header.Get("Accept") == "application/json" {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+			return
+		}
+		redirect(w, r, "/")
+	})(w, r)
+}
+
+func (h *Handlers) BulkAction(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			IDs    []string `json:"ids"`
+			Action string   `json:"action"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid body", 400)
+			return
+		}
+		for _, id := range req.IDs {
+			var engine *sync.Engine
+			for _, e := range h.engineProvider() {
+				if e.GetConfig().ID == id {
+					engine = e
+					break
+				}
+			}
+			if engine == nil {
+				continue
+			}
+			applyEngineAction(engine, req.Action)
+		}
+		_ = database.LogSystemEvent(h.GetUser(r), "Bulk "+req.Action, fmt.Sprintf("Action on %d engines", len(req.IDs)))
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	})(w, r)
+}
+
+// applyEngineAction runs sync, pause or resume on one engine and persists its
+// pause state; the HTTP API, group actions and bot commands share it
+func applyEngineAction(engine *sync.Engine, action string) {
+	id := engine.GetConfig().ID
+	switch action {
+	case "sync":
+		if !engine.IsBusy() {
+			engine.Resume()
+			_ = database.SaveSetting("engine_paused_"+id, "false")
+		}
+	case "pause":
+		engine.Pause()
+		_ = database.SaveSetting("engine_paused_"+id, "true")
+	case "resume":
+		engine.Resume()
+		_ = database.SaveSetting("engine_paused_"+id, "false")
+	}
+}
+
+// Groups serves /api/groups: GET lists every engine group with its aggregated
+// state and traffic, POST /api/groups/{name}/{sync|pause|resume} acts on all
+// engines of a group
+func (h *Handlers) Groups(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/groups"), "/")
+		if rest == "" {
+			if r.Method != "GET" {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(sync.SummarizeGroups(h.engineProvider()))
+			return
+		}
+		if r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		slash := strings.LastIndex(rest, "/")
+		if slash <= 0 {
+			http.Error(w, "Invalid", 400)
+			return
+		}
+		group, action := rest[:slash], rest[slash+1:]
+		if action != "sync" && action != "pause" && action != "resume" {
+			http.Error(w, "Invalid action", 400)
+			return
+		}
+		members := sync.EnginesInGroup(h.engineProvider(), group)
+		if len(members) == 0 {
+			http.Error(w, "Not found", 404)
+			return
+		}
+		for _, e := range members {
+			applyEngineAction(e, action)
+		}
+		_ = database.LogSystemEvent(h.GetUser(r), "Group "+action, fmt.Sprintf("Group %s: action on %d engines", group, len(members)))
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "engines": len(members)})
+	})(w, r)
+}
+
+// Cycles lists recent sync cycles (optionally ?engine=ID). /api/cycles/{id} returns the
+// history rows and the retained log records of a single cycle.
+func (h *Handlers) Cycles(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/cycles"), "/")
+		w.Header().Set("Content-Type", "application/json")
+		if id == "" {
+			cycles, err := database.GetRecentCycles(r.URL.Query().Get("engine"), 50)
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(cycles)
+			return
+		}
+
+		history, err := database.GetCycleHistory(id)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		logs := logging.CycleLogs(id)
+		if len(history) == 0 && len(logs) == 0 {
+			http.Error(w, "Not found", 404)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"cycle":   id,
+			"history": history,
+			"logs":    logs,
+		})
+	})(w, r)
+}
+
+// Traffic returns the traffic between ?from and ?to (RFC 3339 or
+// 2006-01-02, default: the last 7 days) for ?engine or all engines.
+// ?resolution=hour|day|month overrides the resolution picked for the range.
+func (h *Handlers) Traffic(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		to := time.Now()
+		if v := q.Get("to"); v != "" {
+			t, err := parseTimeParam(v)
+			if err != nil {
+				http.Error(w, "Invalid to", 400)
+				return
+			}
+			to = t
+		}
+		from := to.AddDate(0, 0, -7)
+		if v := q.Get("from"); v != "" {
+			t, err := parseTimeParam(v)
+			if err != nil {
+				http.Error(w, "Invalid from", 400)
+				return
+			}
+			from = t
+		}
+		if !from.Before(to) {
+			http.Error(w, "from must be before to", 400)
+			return
+		}
+		resolution := q.Get("resolution")
+		if resolution == "" {
+			resolution = database.TrafficResolution(from, to, database.GetTrafficRetention())
+		}
+		points, err := database.GetTrafficSeries(q.Get("engine"), from, to, resolution)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"from":       from,
+			"to":         to,
+			"resolution": resolution,
+			"points":     points,
+		})
+	})(w, r)
+}
+
+// parseTimeParam reads an RFC 3339 time or a date in the local zone
+func parseTimeParam(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", v, time.Local)
+}
+
+// Query serves /api/v1/query?resource=history|traffic|runs|failures with
+// ?fields=a,b (projection), repeated ?filter=field:op:value (eq, ne, gt,
+// gte, lt, lte, like, in with "|"-separated values), ?sort=-field,field and
+// ?limit / ?offset pagination
+func (h *Handlers) Query(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		v := r.URL.Query()
+		q := database.Query{Resource: v.Get("resource")}
+		if q.Resource == "" {
+			http.Error(w, "resource must be one of "+strings.Join(database.QueryResources(), ", "), 400)
+			return
+		}
+		q.Fields = splitList(v.Get("fields"))
+		q.Sort = splitList(v.Get("sort"))
+		for _, f := range v["filter"] {
+			parts := strings.SplitN(f, ":", 3)
+			if len(parts) != 3 {
+				http.Error(w, "Invalid filter "+f+", expected field:op:value", 400)
+				return
+			}
+			q.Filters = append(q.Filters, database.QueryFilter{Field: parts[0], Op: parts[1], Value: parts[2]})
+		}
+		q.Limit, _ = strconv.Atoi(v.Get("limit"))
+		q.Offset, _ = strconv.Atoi(v.Get("offset"))
+
+		result, err := database.RunQuery(q)
+		if errors.Is(err, database.ErrInvalidQuery) {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(result)
+	})(w, r)
+}
+
+// splitList splits a comma-separated parameter, dropping empty entries
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// FileHistory returns everything that happened to ?path on any engine as one
+// timeline, oldest first, following renames (?limit, default 500)
+func (h *Handlers) FileHistory(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		path := strings.Trim(r.URL.Query().Get("path"), "/")
+		if path == "" {
+			http.Error(w, "path is required", 400)
+			return
+		}
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit <= 0 {
+			limit = 500
+		}
+		names, events, err := database.GetFileHistory(path, limit)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if events == nil {
+			events = []database.FileEvent{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"path":   path,
+			"names":  names,
+			"events": events,
+		})
+	})(w, r)
+}
+
+// remoteAgentTimeout bounds how long a receiver may walk or list its target
+const remoteAgentTimeout = 30 * time.Second
+
+// RemoteSearch searches the targets of remote engines for a file name through
+// their receiver agents: ?q= is a substring or glob, ?engine= limits the
+// search to one engine and ?limit= caps the matches per engine
+func (h *Handlers) RemoteSearch(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		query := strings.TrimSpace(q.Get("q"))
+		if query == "" {
+			http.Error(w, "q is required", 400)
+			return
+		}
+		engineID := q.Get("engine")
+		if engineID != "" && !h.hasEngine(engineID) {
+			http.Error(w, "Engine not found", http.StatusNotFound)
+			return
+		}
+		limit, _ := strconv.Atoi(q.Get("limit"))
+
+		type engineResult struct {
+			Engine    string              `json:"engine"`
+			Host      string              `json:"host"`
+			Path      string              `json:"path"`
+			Matches   []agent.SearchMatch `json:"matches"`
+			Truncated bool                `json:"truncated"`
+			Error     string              `json:"error,omitempty"`
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), remoteAgentTimeout)
+		defer cancel()
+		results := []engineResult{}
+		for _, e := range h.engineProvider() {
+			cfg := e.GetConfig()
+			if engineID != "" && cfg.ID != engineID {
+				continue
+			}
+			host, remotePath := sync.ParseRemoteDestination(cfg.TargetDir)
+			if host == "" {
+				continue
+			}
+			res := engineResult{Engine: cfg.ID, Host: host, Path: remotePath, Matches: []agent.SearchMatch{}}
+			found, err := agent.ForHost(host).Search(ctx, &agent.SearchRequest{Path: remotePath, Query: query, Limit: limit})
+			if err != nil {
+				res.Error = err.Error()
+			} else {
+				res.Matches, res.Truncated = found.Matches, found.Truncated
+			}
+			results = append(results, res)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"query": query, "results": results})
+	})(w, r)
+}
+
+// RemoteList browses the target of a remote engine through its receiver
+// agent: ?engine= is required, ?path= is relative to the engine's target and
+// ?offset=/?limit= page through large directories
+func (h *Handlers) RemoteList(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		var target string
+		for _, e := range h.engineProvider() {
+			if cfg := e.GetConfig(); cfg.ID == q.Get("engine") {
+				target = cfg.TargetDir
+			}
+		}
+		if target == "" {
+			http.Error(w, "Engine not found", http.StatusNotFound)
+			return
+		}
+		host, remotePath := sync.ParseRemoteDestination(target)
+		if host == "" {
+			http.Error(w, "Engine target is not remote", 400)
+			return
+		}
+		sub := pathpkg.Clean("/" + q.Get("path"))
+		offset, _ := strconv.Atoi(q.Get("offset"))
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		if offset < 0 {
+			http.Error(w, "offset must not be negative", 400)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), remoteAgentTimeout)
+		defer cancel()
+		list, err := agent.ForHost(host).List(ctx, &agent.ListRequest{
+			Path:   strings.TrimPrefix(pathpkg.Join(remotePath, sub), "/"),
+			Offset: offset,
+			Limit:  limit,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		// Report the path relative to the engine target, like it was requested
+		list.Path = strings.TrimPrefix(sub, "/")
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(list)
+	})(w, r)
+}
+
+// DiscoverReceivers browses the LAN via mDNS and lists the receivers and their
+// rsync modules, as candidates for DEST_HOST and DEST_MODULE. ?timeout=<ms>
+// sets how long to wait for answers (default 2s, at most 10s).
+func (h *Handlers) DiscoverReceivers(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		timeout := 2 * time.Second
+		if ms, err := strconv.Atoi(r.URL.Query().Get("timeout")); err == nil && ms > 0 {
+			timeout = time.Duration(ms) * time.Millisecond
+		}
+		if timeout > 10*time.Second {
+			timeout = 10 * time.Second
+		}
+		receivers, err := discovery.Browse(r.Context(), timeout)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if receivers == nil {
+			receivers = []discovery.Receiver{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(receivers)
+	})(w, r)
+}
+
+// LogLevels reports the log level of every module. POST {"module": "sync", "level": "debug"}
+// changes a module at runtime; an empty level makes the module follow the default again.
+func (h *Handlers) LogLevels(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+		case "POST":
+			var req struct {
+				Module string `json:"module"`
+				Level  string `json:"level"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Module == "" {
+				http.Error(w, "Invalid body", 400)
+				return
+			}
+			if req.Level == "" && req.Module != logging.DefaultModule {
+				logging.ResetLevel(req.Module)
+			} else {
+				level, err := logging.ParseLevel(req.Level)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				logging.SetLevel(req.Module, level)
+			}
+			logger.Info("Log level changed", "target_module", req.Module, "level", req.Level, "user", h.GetUser(r))
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(logging.Levels())
+	})(w, r)
+}
+
+// BandwidthSchedule reads (GET) or replaces (PUT) the time-of-day bandwidth
+// schedule. Windows are matched in order, the first covering the current
+// time sets the limit.
+func (h *Handlers) BandwidthSchedule(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+		case "PUT":
+			var req struct {
+				Windows []database.BandwidthWindow `json:"windows"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid body: "+err.Error(), 400)
+				return
+			}
+			for _, win := range req.Windows {
+				if err := scheduler.Validate(win); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			if err := database.SaveBandwidthSchedule(req.Windows); err != nil {
+				http.Error(w, "Failed to save schedule", http.StatusInternalServerError)
+				return
+			}
+			_ = database.LogSystemEvent(h.GetUser(r), "Bandwidth Schedule Updated", fmt.Sprintf("%d windows", len(req.Windows)))
+			if h.bandwidth != nil {
+				h.bandwidth.Refresh()
+			}
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		windows, err := database.GetBandwidthSchedule()
+		if err != nil {
+			http.Error(w, "Failed to load schedule", http.StatusInternalServerError)
+			return
+		}
+		if windows == nil {
+			windows = []database.BandwidthWindow{}
+		}
+		resp := map[string]interface{}{"windows": windows}
+		if h.bandwidth != nil {
+			resp["active"] = h.bandwidth.Status()
+		}
+		// The limit each engine applies to the transfers it starts next
+		limits := make(map[string]bandwidth.Rate)
+		for _, e := range h.engineProvider() {
+			limits[e.GetConfig().ID] = bandwidth.Rate(e.GetConfig().BandwidthLimit)
+		}
+		resp["engines"] = limits
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})(w, r)
+}
+
+// AlertRules serves GET/PUT /api/alerts/rules: {"rules": [...]} replaces the
+// rules deciding when sync errors notify, escalate and resolve
+func (h *Handlers) AlertRules(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+		case "PUT":
+			var req struct {
+				Rules []database.AlertRule `json:"rules"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid body", 400)
+				return
+			}
+			if err := h.validateAlertRules(req.Rules); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := database.SaveAlertRules(req.Rules); err != nil {
+				http.Error(w, "Failed to save alert rules", http.StatusInternalServerError)
+				return
+			}
+			_ = database.LogSystemEvent(h.GetUser(r), "Alert Rules Updated", fmt.Sprintf("%d rules", len(req.Rules)))
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		rules, err := database.GetAlertRules()
+		if err != nil {
+			http.Error(w, "Failed to load alert rules", http.StatusInternalServerError)
+			return
+		}
+		if rules == nil {
+			rules = []database.AlertRule{}
+		}
+		resp := map[string]interface{}{"rules": rules, "channels": h.notifier.Channels()}
+		if h.alerts != nil {
+			resp["status"] = h.alerts.Status()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})(w, r)
+}
+
+func (h *Handlers) validateAlertRules(rules []database.AlertRule) error {
+	channels := map[string]bool{"": true}
+	for _, c := range h.notifier.Channels() {
+		channels[c] = true
+	}
+	names := make(map[string]bool)
+	for _, rule := range rules {
+		if rule.Name == "" || names[rule.Name] {
+			return fmt.Errorf("rule names must be set and unique (%q)", rule.Name)
+		}
+		names[rule.Name] = true
+		if rule.Threshold < 0 || rule.WindowMinutes < 0 || rule.EscalateAfterMinutes < 0 {
+			return fmt.Errorf("rule %q: negative values are not allowed", rule.Name)
+		}
+		for _, c := range []string{rule.Channel, rule.EscalateChannel} {
+			if !channels[c] {
+				return fmt.Errorf("rule %q: unknown channel %q, configured: %v", rule.Name, c, h.notifier.Channels())
+			}
+		}
+	}
+	return nil
+}
+
+// Maintenance serves /api/maintenance: GET lists the active windows, POST
+// {"engine_id", "minutes", "reason"} starts one (empty engine_id = global,
+// minutes 0 = until stopped) and DELETE ?engine=ID stops it
+func (h *Handlers) Maintenance(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+		case "POST":
+			var req struct {
+				EngineID string `json:"engine_id"`
+				Minutes  int    `json:"minutes"`
+				Reason   string `json:"reason"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Minutes < 0 {
+				http.Error(w, "Invalid body", 400)
+				return
+			}
+			if req.EngineID != "" && !h.hasEngine(req.EngineID) {
+				http.Error(w, "Not found", 404)
+				return
+			}
+			if err := database.StartMaintenance(req.EngineID, time.Duration(req.Minutes)*time.Minute, req.Reason, h.GetUser(r)); err != nil {
+				http.Error(w, "Failed to start maintenance", http.StatusInternalServerError)
+				return
+			}
+		case "DELETE":
+			if err := database.StopMaintenance(r.URL.Query().Get("engine"), h.GetUser(r)); err != nil {
+				http.Error(w, "Failed to stop maintenance", http.StatusInternalServerError)
+				return
+			}
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		windows, err := database.GetMaintenance()
+		if err != nil {
+			http.Error(w, "Failed to load maintenance", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"maintenance": windows})
+	})(w, r)
+}
+
+// ReadOnly serves the receiver's write protection: POST {"minutes", "reason"}
+// switches it on (minutes 0 until stopped), DELETE switches it off and PUT
+// {"windows": [...]} replaces the weekly schedule. Senders see the state on the
+// agent health check and pause their engines until the receiver is writable.
+func (h *Handlers) ReadOnly(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+		case "POST":
+			var req struct {
+				Minutes int    `json:"minutes"`
+				Reason  string `json:"reason"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Minutes < 0 {
+				http.Error(w, "Invalid body", 400)
+				return
+			}
+			if err := database.StartReadOnly(time.Duration(req.Minutes)*time.Minute, req.Reason, h.GetUser(r)); err != nil {
+				http.Error(w, "Failed to switch to read-only", http.StatusInternalServerError)
+				return
+			}
+		case "DELETE":
+			if err := database.StopReadOnly(h.GetUser(r)); err != nil {
+				http.Error(w, "Failed to switch off read-only", http.StatusInternalServerError)
+				return
+			}
+		case "PUT":
+			var req struct {
+				Windows []database.ReadOnlyWindow `json:"windows"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid body", 400)
+				return
+			}
+			for _, win := range req.Windows {
+				if err := scheduler.ValidateReadOnly(win); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			if err := database.SaveReadOnlySchedule(req.Windows); err != nil {
+				http.Error(w, "Failed to save schedule", http.StatusInternalServerError)
+				return
+			}
+			_ = database.LogSystemEvent(h.GetUser(r), "Read-Only Schedule Updated", fmt.Sprintf("%d windows", len(req.Windows)))
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Method != "GET" && h.readOnlyHook != nil {
+			h.readOnlyHook()
+		}
+		windows, err := database.GetReadOnlySchedule()
+		if err != nil {
+			http.Error(w, "Failed to load schedule", http.StatusInternalServerError)
+			return
+		}
+		if windows == nil {
+			windows = []database.ReadOnlyWindow{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"state":   scheduler.ReadOnlyAt(time.Now()),
+			"manual":  database.GetReadOnly(),
+			"windows": windows,
+		})
+	})(w, r)
+}
+
+func (h *Handlers) hasEngine(id string) bool {
+	for _, e := range h.engineProvider() {
+		if e.GetConfig().ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// LockStats reports queue depth and wait times for every scan/transfer lock group
+func (h *Handlers) LockStats(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		type lockView struct {
+			sync.LockStats
+			AvgWaitMs   int64 `json:"avg_wait_ms"`
+			MaxWaitMs   int64 `json:"max_wait_ms"`
+			TotalWaitMs int64 `json:"total_wait_ms"`
+		}
+		stats := sync.GetLockStats()
+		views := make([]lockView, 0, len(stats))
+		for _, s := range stats {
+			views = append(views, lockView{
+				LockStats: s, AvgWaitMs: s.AvgWait().Milliseconds(),
+				MaxWaitMs: s.MaxWait.Milliseconds(), TotalWaitMs: s.TotalWait.Milliseconds(),
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(views)
+	})(w, r)
+}
+
+// Diagnostics reports watcher and lock internals useful when debugging large libraries
+func (h *Handlers) Diagnostics(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		watchers := make([]sync.WatchStats, 0)
+		for _, e := range h.engineProvider() {
+			watchers = append(watchers, e.GetWatchStats())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"watchers": watchers,
+			"locks":    sync.GetLockStats(),
+		})
+	})(w, r)
+}
+
+func (h *Handlers) EnginePreview(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/engine/"), "/preview")
+		var engine *sync.Engine
+		for _, e := range h.engineProvider() {
+			if e.GetConfig().ID == id {
+				engine = e
+				break
+			}
+		}
+		if engine == nil {
+			http.Error(w, "Not found", 404)
+			return
+		}
+		plan, err := engine.PreviewSync()
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		preview := planPreview{SyncPlan: plan}
+		for _, f := range plan.FilesToSync {
+			preview.TransferBytes += f.Size
+		}
+		preview.EstimatedCost = database.CostLabel(preview.TransferBytes)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(preview)
+	})(w, r)
 }
 
-func (h *Handlers) GetProgressInfo() (progress, speed, eta string, queued int, status string) {
-	var totalSpeed int64
-	var totalRemaining int64
-	allPaused := true
-	var sb strings.Builder
-	for _, engine := range h.engineProvider() {
-		sb.WriteString(engine.GetStatus() + "\n")
-		if !engine.IsPaused() {
-			allPaused = false
+// EnginePreviewDiff serves GET /api/engine/{id}/preview/diff: what changed in
+// the plan since the engine was last previewed
+func (h *Handlers) EnginePreviewDiff(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/engine/"), "/preview/diff")
+		var engine *sync.Engine
+		for _, e := range h.engineProvider() {
+			if e.GetConfig().ID == id {
+				engine = e
+				break
+			}
 		}
-		_, transferredBytes, _, currentSpeed := engine.GetTransferStats()
-		totalSpeed += currentSpeed
-		totalRemaining += (engine.GetPlanRemainingBytes() - transferredBytes)
-		queuedCount, queuedSize := engine.GetQueuedStats()
-		totalRemaining += queuedSize
-		queued += queuedCount
-	}
-	status = sb.String()
-	speed = database.FormatBytes(totalSpeed) + "/s"
-	if totalSpeed > 0 && totalRemaining > 0 {
-		if totalRemaining < 0 {
-			totalRemaining = 0
+		if engine == nil {
+			http.Error(w, "Not found", 404)
+			return
 		}
-		sec := totalRemaining / totalSpeed
-		if sec > 3600 {
-			eta = fmt.Sprintf("%dh %dm", sec/3600, (sec%3600)/60)
-		} else if sec > 60 {
-			eta = fmt.Sprintf("%dm %ds", sec/60, sec%60)
-		} else {
-			eta = fmt.Sprintf("%ds", sec)
+		diff, err := engine.PreviewDiff()
+		if errors.Is(err, sync.ErrNoPreview) {
+			http.Error(w, "No previous preview, open the preview first", 404)
+			return
 		}
-	} else {
-		eta = "Done"
-	}
-	progress = "Monitoring..."
-	if allPaused && len(h.engineProvider()) > 0 {
-		progress = "Sync Paused"
-	} else if totalSpeed > 0 {
-		progress = "Transferring..."
-	}
-	return progress, speed, eta, queued, status
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(diff)
+	})(w, r)
 }
 
-func (h *Handlers) ManualSync(w http.ResponseWriter, r *http.Request) {
+// planPreview is a sync plan annotated with the bytes it would transfer and their estimated cost
+type planPreview struct {
+	*sync.SyncPlan
+	TransferBytes int64  `json:"transferBytes"`
+	EstimatedCost string `json:"estimatedCost,omitempty"` // "" without COST_PER_GB
+}
+
+// EngineIncidents returns an engine's health score, its incidents and a per-day
+// uptime timeline over the last ?days=N days (default 30, at most 365)
+func (h *Handlers) EngineIncidents(w http.ResponseWriter, r *http.Request) {
 	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/engine/"), "/incidents")
+		found := false
 		for _, e := range h.engineProvider() {
-			e.Resume()
-			_ = database.SaveSetting("engine_paused_"+e.GetConfig().ID, "false")
+			if e.GetConfig().ID == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			http.Error(w, "Not found", 404)
+			return
+		}
+		days := 30
+		if val, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && val > 0 && val <= 365 {
+			days = val
+		}
+		now := time.Now()
+		since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, 1-days)
+		incidents, err := database.GetEngineIncidents(id, since)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
 		}
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		score, samples := database.GetEngineHealthScore(id)
+		grade, _ := database.GetEngineHealth(id)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"engine_id": id,
+			"score":     score,
+			"samples":   samples,
+			"grade":     grade,
+			"incidents": incidents,
+			"timeline":  database.UptimeTimeline(incidents, days, now),
+		})
 	})(w, r)
 }
 
-func (h *Handlers) GlobalPause(w http.ResponseWriter, r *http.Request) {
+// EngineMedia returns the source files of an engine that failed media validation
+func (h *Handlers) EngineMedia(w http.ResponseWriter, r *http.Request) {
 	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/engine/"), "/media")
+		found := false
 		for _, e := range h.engineProvider() {
-			e.Pause()
-			_ = database.SaveSetting("engine_paused_"+e.GetConfig().ID, "true")
+			if e.GetConfig().ID == id {
+				found = true
+				break
+			}
 		}
-		_ = database.LogSystemEvent(h.GetUser(r), "Paused All", "User paused all engines")
-		if r.Header.Get("Accept") == "application/json" {
-			w.Header().Set("Content-Type", "application/json")
-			_ = json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+		if !found {
+			http.Error(w, "Not found", 404)
+			return
+		}
+		invalid, err := database.ListInvalidMedia(id)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
 			return
 		}
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		if invalid == nil {
+			invalid = []database.MediaValidation{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(invalid)
 	})(w, r)
 }
 
-func (h *Handlers) GlobalResume(w http.ResponseWriter, r *http.Request) {
+// EngineExplain reports how rules and the current plan treat a single path
+func (h *Handlers) EngineExplain(w http.ResponseWriter, r *http.Request) {
 	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/engine/"), "/explain")
+		path := r.URL.Query().Get("path")
+		if path == "" {
+			http.Error(w, "Missing path parameter", http.StatusBadRequest)
+			return
+		}
+		var engine *sync.Engine
 		for _, e := range h.engineProvider() {
-			e.Resume()
-			_ = database.SaveSetting("engine_paused_"+e.GetConfig().ID, "false")
+			if e.GetConfig().ID == id {
+				engine = e
+				break
+			}
 		}
-		_ = database.LogSystemEvent(h.GetUser(r), "Resumed All", "User resumed all engines")
-		if r.Header.Get("Accept") == "application/json" {
-			w.Header().Set("Content-Type", "application/json")
-			_ = json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+		if engine == nil {
+			http.Error(w, "Not found", 404)
+			return
+		}
+		explanation, err := engine.Explain(path)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
 			return
 		}
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(explanation)
 	})(w, r)
 }
 
-func (h *Handlers) BulkAction(w http.ResponseWriter, r *http.Request) {
+// EngineSimulate serves GET /api/engine/{id}/simulate?mode=auto&auto_approve=on:
+// the current plan split into changes that would run automatically and changes
+// that would wait for approval under that mode (default: the current settings)
+func (h *Handlers) EngineSimulate(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/engine/"), "/simulate")
+		var engine *sync.Engine
+		for _, e := range h.engineProvider() {
+			if e.GetConfig().ID == id {
+				engine = e
+				break
+			}
+		}
+		if engine == nil {
+			http.Error(w, "Not found", 404)
+			return
+		}
+		mode := r.URL.Query().Get("mode")
+		if mode == "" {
+			mode = database.GetSetting("sync_mode", "dry")
+		}
+		autoApprove := engine.GetConfig().AutoApproveDeletions
+		if val := r.URL.Query().Get("auto_approve"); val != "" {
+			autoApprove = val == "on" || val == "true"
+		}
+		sim, err := engine.Simulate(mode, autoApprove)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(sim)
+	})(w, r)
+}
+
+// EngineUndo serves POST /api/engine/{id}/undo-last-cycle: reverses the
+// deletions and renames of the engine's latest cycle and pauses the engine so
+// the next cycle does not repeat them
+func (h *Handlers) EngineUndo(w http.ResponseWriter, r *http.Request) {
 	h.auth(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
-		var req struct {
-			IDs    []string `json:"ids"`
-			Action string   `json:"action"`
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/engine/"), "/undo-last-cycle")
+		var engine *sync.Engine
+		for _, e := range h.engineProvider() {
+			if e.GetConfig().ID == id {
+				engine = e
+				break
+			}
 		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid body", 400)
+		if engine == nil {
+			http.Error(w, "Not found", 404)
 			return
 		}
-		for _, id := range req.IDs {
-			var engine *sync.Engine
-			for _, e := range h.engineProvider() {
-				if e.GetConfig().ID == id {
-					engine = e
-					break
-				}
-			}
-			if engine == nil {
-				continue
-			}
-			switch req.Action {
-			case "sync":
-				if !engine.IsBusy() {
-					engine.Resume()
-					_ = database.SaveSetting("engine_paused_"+id, "false")
-				}
-			case "pause":
-				engine.Pause()
-				_ = database.SaveSetting("engine_paused_"+id, "true")
-			case "resume":
-				engine.Resume()
-				_ = database.SaveSetting("engine_paused_"+id, "false")
-			}
+		res, err := engine.UndoLastCycle()
+		if errors.Is(err, sync.ErrNothingToUndo) {
+			http.Error(w, err.Error(), 404)
+			return
 		}
-		_ = database.LogSystemEvent(h.GetUser(r), "Bulk "+req.Action, fmt.Sprintf("Action on %d engines", len(req.IDs)))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		engine.Pause()
+		_ = database.SaveSetting("engine_paused_"+id, "true")
+		_ = database.LogSystemEvent(h.GetUser(r), "Engine Undo", fmt.Sprintf("Engine %s: undid cycle %s (%d restored, %d renames reversed, %d failed), engine paused",
+			id, res.Cycle, res.Restored, res.Renamed, len(res.Failed)))
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+		_ = json.NewEncoder(w).Encode(res)
 	})(w, r)
 }
 
-func (h *Handlers) EnginePreview(w http.ResponseWriter, r *http.Request) {
+// EngineBenchmark returns the stored benchmark of an engine (GET) or runs a new one (POST).
+// The POST body may set size_mb, streams and chunk_kb to override the tried values.
+func (h *Handlers) EngineBenchmark(w http.ResponseWriter, r *http.Request) {
 	h.auth(func(w http.ResponseWriter, r *http.Request) {
-		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/engine/"), "/preview")
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/engine/"), "/benchmark")
 		var engine *sync.Engine
 		for _, e := range h.engineProvider() {
 			if e.GetConfig().ID == id {
@@ -161,13 +1223,47 @@ func (h *Handlers) EnginePreview(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Not found", 404)
 			return
 		}
-		plan, err := engine.PreviewSync()
-		if err != nil {
-			http.Error(w, err.Error(), 500)
+
+		var run *database.BenchmarkRun
+		switch r.Method {
+		case "GET":
+			var err error
+			if run, err = database.LoadBenchmarkRun(id); err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			if run == nil {
+				http.Error(w, "No benchmark results", 404)
+				return
+			}
+		case "POST":
+			var req struct {
+				SizeMB  int64 `json:"size_mb"`
+				Streams []int `json:"streams"`
+				ChunkKB []int `json:"chunk_kb"`
+			}
+			if r.ContentLength != 0 {
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					http.Error(w, "Invalid body", 400)
+					return
+				}
+			}
+			opts := sync.BenchmarkOptions{Size: req.SizeMB * 1024 * 1024, Streams: req.Streams}
+			for _, kb := range req.ChunkKB {
+				opts.ChunkSizes = append(opts.ChunkSizes, kb*1024)
+			}
+			var err error
+			if run, err = engine.Benchmark(opts); run == nil {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			_ = database.LogSystemEvent(h.GetUser(r), "Engine Benchmark", "Engine "+id)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(plan)
+		_ = json.NewEncoder(w).Encode(run)
 	})(w, r)
 }
 
@@ -191,26 +1287,35 @@ func (h *Handlers) EngineAction(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		switch action {
-		case "pause":
-			engine.Pause()
-			_ = database.SaveSetting("engine_paused_"+id, "true")
-		case "resume":
-			engine.Resume()
-			_ = database.SaveSetting("engine_paused_"+id, "false")
-		case "sync":
-			if !engine.IsBusy() {
-				engine.Resume()
-				_ = database.SaveSetting("engine_paused_"+id, "false")
-			}
+		case "pause", "resume", "sync":
+			applyEngineAction(engine, action)
 		case "approve":
 			engine.ApproveDeletions()
 		case "approve-list":
 			var req struct {
 				Files []string `json:"files"`
+				Dirs  []string `json:"dirs"` // Approve every pending path under these directories
 			}
-			if err := json.NewDecoder(r.Body).Decode(&req); err == nil {
-				engine.ApproveSpecificChanges(req.Files)
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid body", 400)
+				return
+			}
+			// An empty list would approve the whole plan
+			paths := append(req.Files, engine.ResolvePendingDirs(req.Dirs)...)
+			if len(paths) == 0 {
+				http.Error(w, "No pending changes selected", 400)
+				return
 			}
+			engine.ApproveSpecificChanges(paths)
+		case "pending":
+			depth, _ := strconv.Atoi(r.URL.Query().Get("depth"))
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"waiting":     engine.IsWaitingForApproval(),
+				"paths":       engine.GetPendingDeletions(),
+				"directories": engine.PendingSummary(depth),
+			})
+			return
 		}
 		_ = database.LogSystemEvent(h.GetUser(r), "Engine "+action, "Engine "+id)
 		w.WriteHeader(200)
@@ -261,7 +1366,7 @@ func (h *Handlers) UpdateSyncMode(w http.ResponseWriter, r *http.Request) {
 			_ = json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 			return
 		}
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		redirect(w, r, "/")
 	})(w, r)
 }
 
@@ -278,7 +1383,7 @@ func (h *Handlers) UpdateAutoApprove(w http.ResponseWriter, r *http.Request) {
 			_ = json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 			return
 		}
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		redirect(w, r, "/")
 	})(w, r)
 }
 
@@ -305,8 +1410,146 @@ func (h *Handlers) UpdateSenderOverride(w http.ResponseWriter, r *http.Request)
 
 func (h *Handlers) TestNotify(w http.ResponseWriter, r *http.Request) {
 	h.auth(func(w http.ResponseWriter, r *http.Request) {
-		go h.notifier.Send("Test from Dashboard", "INFO")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		go h.notifier.Send(notification.Render(notification.EventTest, notification.Vars{}), "INFO")
+		redirect(w, r, "/")
+	})(w, r)
+}
+
+// Status serves GET /api/status: the overall progress and the state of
+// every engine, labelled in the locale of the request (?lang=,
+// Accept-Language, then the configured one)
+func (h *Handlers) Status(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		locale := i18n.FromRequest(r)
+		progress, speed, eta, queued, _ := h.progressInfo(locale)
+		type engineStatus struct {
+			ID    string `json:"id"`
+			Alias string `json:"alias"`
+			State string `json:"state"` // idle, syncing or paused
+			Label string `json:"label"` // State in the locale
+		}
+		engines := make([]engineStatus, 0)
+		for _, e := range h.engineProvider() {
+			state := "idle"
+			switch {
+			case e.IsPaused():
+				state = "paused"
+			case e.IsBusy():
+				state = "syncing"
+			}
+			engines = append(engines, engineStatus{ID: e.GetConfig().ID, Alias: e.GetAlias(), State: state, Label: i18n.In(locale, "state."+state)})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"locale": locale, "status": progress, "speed": speed, "eta": eta, "queued": queued, "engines": engines,
+		})
+	})(w, r)
+}
+
+// Locale serves /api/locale: GET returns the configured and the supported
+// locales, PUT {"locale": "de"} switches notifications, bot replies and
+// status labels (an empty locale goes back to LOCALE)
+func (h *Handlers) Locale(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+		case "PUT":
+			var req struct {
+				Locale string `json:"locale"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid body", 400)
+				return
+			}
+			if err := i18n.Set(req.Locale); err != nil {
+				http.Error(w, err.Error(), 400)
+				return
+			}
+			_ = database.LogSystemEvent(h.GetUser(r), "Locale Changed", "Locale: "+i18n.Current())
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"locale": i18n.Current(), "locales": i18n.Locales()})
+	})(w, r)
+}
+
+// NotificationTemplates serves /api/notifications/templates: GET lists the
+// message template of every event, PUT {event, template} replaces one (an
+// empty template restores the default)
+func (h *Handlers) NotificationTemplates(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+		case "PUT":
+			var req struct {
+				Event    string `json:"event"`
+				Template string `json:"template"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid body", 400)
+				return
+			}
+			if err := notification.SetTemplate(req.Event, req.Template); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			_ = database.LogSystemEvent(h.GetUser(r), "Notification Template Updated", req.Event)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"templates": notification.Templates(), "variables": notification.SampleVars})
+	})(w, r)
+}
+
+// NotificationPreview renders a template with sample variables (or the given
+// ones) without saving it; with "send": true the result goes out as a test
+func (h *Handlers) NotificationPreview(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			Event    string             `json:"event"`
+			Template string             `json:"template"` // Empty to render the event's current template
+			Vars     *notification.Vars `json:"vars"`
+			Send     bool               `json:"send"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid body", 400)
+			return
+		}
+		vars := notification.SampleVars
+		if req.Vars != nil {
+			vars = *req.Vars
+		}
+		var msg string
+		if req.Template != "" {
+			var err error
+			if msg, err = notification.RenderTemplate(req.Template, vars); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		} else {
+			if !notification.KnownEvent(req.Event) {
+				http.Error(w, fmt.Sprintf("unknown event %q", req.Event), http.StatusBadRequest)
+				return
+			}
+			msg = notification.Render(req.Event, vars)
+		}
+		if req.Send {
+			go h.notifier.Send(msg, "INFO")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"message": msg, "sent": req.Send})
 	})(w, r)
 }
 
@@ -314,7 +1557,7 @@ func (h *Handlers) SetScheduler(w http.ResponseWriter, r *http.Request) {
 	h.auth(func(w http.ResponseWriter, r *http.Request) {
 		h.config.QuietStart = r.FormValue("quiet_hours")
 		_ = h.config.Save()
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		redirect(w, r, "/")
 	})(w, r)
 }
 
@@ -322,7 +1565,7 @@ func (h *Handlers) SetNotifications(w http.ResponseWriter, r *http.Request) {
 	h.auth(func(w http.ResponseWriter, r *http.Request) {
 		h.config.DiscordWebhook = r.FormValue("webhook_url")
 		_ = h.config.Save()
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		redirect(w, r, "/")
 	})(w, r)
 }
 
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/sync"
+)
+
+func TestStatusAndLocale(t *testing.T) {
+	database.DBPath = filepath.Join(t.TempDir(), "test.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+
+	e := sync.NewEngine(sync.SyncConfig{ID: "1", SourceDir: t.TempDir(), TargetDir: t.TempDir()})
+	e.Pause()
+	h := New(nil, nil, nil, nil, nil, func() []*sync.Engine { return []*sync.Engine{e} })
+
+	status := func(lang string) map[string]interface{} {
+		req := httptest.NewRequest("GET", "/api/status", nil)
+		req.Header.Set("Accept-Language", lang)
+		w := httptest.NewRecorder()
+		h.Status(w, req)
+		var out map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	got := status("de-DE,de;q=0.9")
+	engines, _ := json.Marshal(got["engines"])
+	if got["status"] != "Sync pausiert" || !strings.Contains(string(engines), `"label":"pausiert"`) {
+		t.Errorf("Expected German labels, got %v", got)
+	}
+	if got := status(""); got["status"] != "Sync Paused" || got["locale"] != "en" {
+		t.Errorf("Expected English by default, got %v", got)
+	}
+
+	req := httptest.NewRequest("PUT", "/api/locale", strings.NewReader(`{"locale": "xx"}`))
+	rec := httptest.NewRecorder()
+	h.Locale(rec, req)
+	if rec.Code != 400 {
+		t.Errorf("Expected 400 for an unsupported locale, got %d", rec.Code)
+	}
+	req = httptest.NewRequest("PUT", "/api/locale", strings.NewReader(`{"locale": "de"}`))
+	rec = httptest.NewRecorder()
+	h.Locale(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("Switching the locale failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if got := status(""); got["status"] != "Sync pausiert" {
+		t.Errorf("The configured locale should apply without a header, got %v", got)
+	}
+	if reply := h.BotCommand("telegram:alice", "/pause 9"); reply != "unbekannte Engine 9" {
+		t.Errorf("Bot replies should follow the locale, got %q", reply)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	database.DBPath = filepath.Join(t.TempDir(), "test.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+	_ = database.LogSystemEvent("admin", "Paused", "")
+	h := New(nil, nil, nil, nil, nil, nil)
+
+	query := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.Query(rec, httptest.NewRequest("GET", target, nil))
+		return rec
+	}
+	rec := query("/api/v1/query?resource=history&fields=action,engine&filter=action:eq:Paused&sort=-time")
+	var out database.QueryResult
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Total != 1 || out.Items[0]["action"] != "Paused" {
+		t.Errorf("Expected the system event, got %+v", out)
+	}
+	for _, target := range []string{
+		"/api/v1/query",
+		"/api/v1/query?resource=history&filter=action",
+		"/api/v1/query?resource=history&sort=secret",
+	} {
+		if rec := query(target); rec.Code != 400 {
+			t.Errorf("%s: expected 400, got %d", target, rec.Code)
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"html/template"
-	"log"
 	"net/http"
 	"time"
 
@@ -22,39 +21,37 @@ func (h *Handlers) auth(next http.HandlerFunc) http.HandlerFunc {
 		// Check for session cookie
 		cookie, err := r.Cookie("schnorarr_session")
 		if err != nil {
-			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			redirect(w, r, "/login")
 			return
 		}
 
-		h.sessionMu.RLock()
-		session, exists := h.sessions[cookie.Value]
-		h.sessionMu.RUnlock()
-
-		if !exists || time.Now().After(session.Expires) {
-			http.Redirect(w, r, "/login", http.StatusSeeOther)
+		session, ok := h.session(cookie.Value)
+		if !ok {
+			redirect(w, r, "/login")
 			return
 		}
+		h.slideSession(w, r, cookie.Value, session)
 		next(w, r)
 	}
 }
 
 // LoginPage handler
 func (h *Handlers) LoginPage(w http.ResponseWriter, r *http.Request) {
-	data := struct{ Error string }{Error: ""}
+	data := struct{ Error, BasePath string }{Error: "", BasePath: BasePath}
 	t, err := template.ParseFS(ui.TemplateFS, "web/templates/login.html")
 	if err != nil {
 		http.Error(w, "Template Error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 	if err := t.Execute(w, data); err != nil {
-		log.Printf("LoginPage Execute Error: %v", err)
+		logger.Error("Login page template failed", "error", err)
 	}
 }
 
 // Login handler processes credentials
 func (h *Handlers) Login(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
-		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		redirect(w, r, "/login")
 		return
 	}
 
@@ -69,48 +66,40 @@ func (h *Handlers) Login(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		token := hex.EncodeToString(b)
-		expiry := time.Now().Add(24 * time.Hour)
-
-		h.sessionMu.Lock()
-		h.sessions[token] = Session{User: user, Expires: expiry}
-		h.sessionMu.Unlock()
+		remember := r.FormValue("remember") == "true"
+		session := Session{User: user, Expires: time.Now().Add(lifetime(remember)), Remember: remember}
 
-		// Cleanup expired sessions occasionally (simple probability check)
-		// ... implementation omitted for brevity, or done in background task
-
-		http.SetCookie(w, &http.Cookie{
-			Name:     "schnorarr_session",
-			Value:    token,
-			Path:     "/",
-			HttpOnly: true,
-			Secure:   true, // Require HTTPS
-			SameSite: http.SameSiteLaxMode,
-			Expires:  expiry,
-		})
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		h.startSession(token, session)
+		setSessionCookie(w, r, token, session)
+		redirect(w, r, "/")
 		return
 	}
 
 	// Re-render login with error
-	data := struct{ Error string }{Error: "Invalid credentials"}
+	data := struct{ Error, BasePath string }{Error: "Invalid credentials", BasePath: BasePath}
 	t, err := template.ParseFS(ui.TemplateFS, "web/templates/login.html")
 	if err != nil {
 		http.Error(w, "Template Error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 	if err := t.Execute(w, data); err != nil {
-		log.Printf("Login Execute Error: %v", err)
+		logger.Error("Login template failed", "error", err)
 	}
 }
 
 // Logout handler
 func (h *Handlers) Logout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie("schnorarr_session"); err == nil {
+		h.endSession(cookie.Value)
+	}
 	http.SetCookie(w, &http.Cookie{
 		Name:     "schnorarr_session",
 		Value:    "",
-		Path:     "/",
+		Path:     cookiePath(),
 		HttpOnly: true,
+		Secure:   secureCookie(r),
+		SameSite: CookieSameSite,
 		MaxAge:   -1,
 	})
-	http.Redirect(w, r, "/login", http.StatusSeeOther)
+	redirect(w, r, "/login")
 }
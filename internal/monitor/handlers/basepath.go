@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// BasePath is the path prefix the dashboard is served under behind a
+// reverse proxy (BASE_PATH), e.g. "/schnorarr"; "" serves it at the root
+var BasePath string
+
+// NormalizeBasePath turns "schnorarr", "/schnorarr/" and the like into
+// "/schnorarr"; "" and "/" mean no prefix
+func NormalizeBasePath(p string) string {
+	p = strings.Trim(strings.TrimSpace(p), "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+// WithBasePath serves next under BasePath. It stays reachable at the root
+// too, so proxies that strip the prefix, health checks and the other node
+// keep working.
+func WithBasePath(next http.Handler) http.Handler {
+	if BasePath == "" {
+		return next
+	}
+	mux := http.NewServeMux()
+	mux.Handle(BasePath+"/", http.StripPrefix(BasePath, next))
+	mux.HandleFunc(BasePath, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, BasePath+"/", http.StatusMovedPermanently)
+	})
+	mux.Handle("/", next)
+	return mux
+}
+
+// redirect sends the browser to a dashboard path
+func redirect(w http.ResponseWriter, r *http.Request, path string) {
+	http.Redirect(w, r, BasePath+path, http.StatusSeeOther)
+}
+
+// cookiePath is the Path of the session cookie
+func cookiePath() string {
+	return BasePath + "/"
+}
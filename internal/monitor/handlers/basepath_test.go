@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeBasePath(t *testing.T) {
+	for in, want := range map[string]string{
+		"":             "",
+		"/":            "",
+		"schnorarr":    "/schnorarr",
+		"/schnorarr/":  "/schnorarr",
+		" /apps/sync ": "/apps/sync",
+	} {
+		if got := NormalizeBasePath(in); got != want {
+			t.Errorf("NormalizeBasePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestBasePath(t *testing.T) {
+	t.Setenv("BASE_PATH", "/schnorarr/")
+	t.Setenv("AUTH_ENABLED", "true")
+	t.Setenv("ADMIN_USER", "admin")
+	t.Setenv("ADMIN_PASS", "password")
+	h := New(nil, nil, nil, nil, nil, nil)
+	t.Cleanup(func() { BasePath = "" })
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/ping", func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(r.URL.Path)) })
+	mux.HandleFunc("/login", h.LoginPage)
+	srv := WithBasePath(mux)
+
+	for _, path := range []string{"/schnorarr/api/ping", "/api/ping"} {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusOK || w.Body.String() != "/api/ping" {
+			t.Errorf("GET %s = %d %q, want the route without prefix", path, w.Code, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("GET", "/schnorarr", nil))
+	if loc := w.Header().Get("Location"); loc != "/schnorarr/" {
+		t.Errorf("The bare prefix should redirect to /schnorarr/, got %q", loc)
+	}
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("GET", "/schnorarr/login", nil))
+	if !strings.Contains(w.Body.String(), `action="/schnorarr/login"`) {
+		t.Error("Login form should post below the base path")
+	}
+
+	// Redirects and the session cookie carry the prefix
+	cookie := login(t, h)
+	if cookie.Path != "/schnorarr/" {
+		t.Errorf("Cookie path = %q, want /schnorarr/", cookie.Path)
+	}
+	w = httptest.NewRecorder()
+	h.auth(func(http.ResponseWriter, *http.Request) {})(w, httptest.NewRequest("GET", "/", nil))
+	if loc := w.Header().Get("Location"); loc != "/schnorarr/login" {
+		t.Errorf("Login redirect = %q, want /schnorarr/login", loc)
+	}
+}
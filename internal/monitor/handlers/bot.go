@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/monitor/i18n"
+	"schnorarr/internal/sync"
+)
+
+// BotCommand runs a chat command for user through the same engine controls
+// as the HTTP API and returns the reply in the configured locale. Actions go
+// to the audit trail.
+func (h *Handlers) BotCommand(user, text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return i18n.T("bot.help")
+	}
+	// Group chats address commands as /pause@botname
+	cmd, _, _ := strings.Cut(strings.ToLower(fields[0]), "@")
+	args := fields[1:]
+
+	switch cmd {
+	case "/status":
+		return h.botStatus()
+	case "/pause", "/resume", "/sync":
+		if len(args) != 1 {
+			return i18n.T("bot.usage", cmd)
+		}
+		action := strings.TrimPrefix(cmd, "/")
+		engines, err := h.botEngines(args[0])
+		if err != nil {
+			return err.Error()
+		}
+		for _, e := range engines {
+			applyEngineAction(e, action)
+		}
+		_ = database.LogSystemEvent(user, "Bot "+action, fmt.Sprintf("Engines: %s", args[0]))
+		return i18n.T("bot.applied", i18n.T("action."+action), len(engines))
+	case "/approve":
+		if len(args) != 1 || strings.EqualFold(args[0], "all") {
+			return i18n.T("bot.usage_approve")
+		}
+		engines, err := h.botEngines(args[0])
+		if err != nil {
+			return err.Error()
+		}
+		e := engines[0]
+		if !e.IsWaitingForApproval() {
+			return i18n.T("bot.nothing_pending", args[0])
+		}
+		pending := len(e.GetPendingDeletions())
+		e.ApproveDeletions()
+		_ = database.LogSystemEvent(user, "Bot approve", "Engine "+args[0])
+		return i18n.T("bot.approved", pending, args[0])
+	case "/help", "/start":
+		return i18n.T("bot.help")
+	}
+	return i18n.T("bot.unknown_command") + "\n" + i18n.T("bot.help")
+}
+
+// botEngines resolves an engine ID, or "all"
+func (h *Handlers) botEngines(id string) ([]*sync.Engine, error) {
+	engines := h.engineProvider()
+	if strings.EqualFold(id, "all") {
+		return engines, nil
+	}
+	for _, e := range engines {
+		if e.GetConfig().ID == id {
+			return []*sync.Engine{e}, nil
+		}
+	}
+	return nil, errors.New(i18n.T("bot.unknown_engine", id))
+}
+
+func (h *Handlers) botStatus() string {
+	engines := h.engineProvider()
+	if len(engines) == 0 {
+		return i18n.T("bot.no_engines")
+	}
+	var b strings.Builder
+	for _, e := range engines {
+		id := e.GetConfig().ID
+		state := i18n.T("state." + e.State())
+		fmt.Fprintf(&b, "%s", id)
+		if alias := e.GetAlias(); alias != "" {
+			fmt.Fprintf(&b, " (%s)", alias)
+		}
+		fmt.Fprintf(&b, ": %s", state)
+		if e.IsWaitingForApproval() {
+			b.WriteString(i18n.T("bot.waiting", len(e.GetPendingDeletions())))
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimSpace(b.String())
+}
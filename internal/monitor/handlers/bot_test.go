@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/sync"
+)
+
+func TestBotCommand(t *testing.T) {
+	database.DBPath = filepath.Join(t.TempDir(), "test.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+
+	// Resuming starts a sync, let it finish before the database closes
+	idle := func(e *sync.Engine) {
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if !e.IsBusy() && !e.IsScanning() {
+				return
+			}
+		}
+	}
+	e1 := sync.NewEngine(sync.SyncConfig{ID: "1", SourceDir: t.TempDir(), TargetDir: t.TempDir()})
+	e2 := sync.NewEngine(sync.SyncConfig{ID: "2", SourceDir: t.TempDir(), TargetDir: t.TempDir()})
+	h := New(nil, nil, nil, nil, nil, func() []*sync.Engine { return []*sync.Engine{e1, e2} })
+	defer func() { idle(e1); idle(e2) }()
+
+	if reply := h.BotCommand("telegram:alice", "/pause@schnorarr_bot 2"); !strings.Contains(reply, "1 engine") {
+		t.Errorf("Unexpected reply %q", reply)
+	}
+	if !e2.IsPaused() || e1.IsPaused() {
+		t.Error("Only engine 2 should be paused")
+	}
+	if database.GetSetting("engine_paused_2", "") != "true" {
+		t.Error("Pause state was not persisted")
+	}
+	if reply := h.BotCommand("telegram:alice", "/status"); !strings.Contains(reply, "Engine #2): paused") {
+		t.Errorf("Status should list engine 2 as paused, got %q", reply)
+	}
+
+	h.BotCommand("telegram:alice", "/resume all")
+	if e1.IsPaused() || e2.IsPaused() {
+		t.Error("All engines should be resumed")
+	}
+	if reply := h.BotCommand("telegram:alice", "/approve 1"); !strings.Contains(reply, "nothing waiting") {
+		t.Errorf("Unexpected approve reply %q", reply)
+	}
+	if reply := h.BotCommand("telegram:alice", "/pause 9"); !strings.Contains(reply, "unknown engine") {
+		t.Errorf("Unknown engines should be reported, got %q", reply)
+	}
+
+	items, _ := database.GetEngineHistory(10, 0, "", []string{"SYSTEM"})
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 audit entries, got %+v", items)
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"time"
+
+	"schnorarr/internal/monitor/calendar"
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/monitor/scheduler"
+)
+
+// Calendar serves /api/calendar.ics: the bandwidth windows and quiet hours
+// as weekly events and the maintenance windows, for calendar subscriptions.
+// Calendar apps cannot log in, so ?token=CALENDAR_TOKEN is accepted as well.
+func (h *Handlers) Calendar(w http.ResponseWriter, r *http.Request) {
+	serve := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		events, err := h.calendarEvents(time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("Content-Disposition", `inline; filename="schnorarr.ics"`)
+		_ = calendar.Write(w, "schnorarr sync schedule", events, time.Now())
+	}
+	token := r.URL.Query().Get("token")
+	if CalendarToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(CalendarToken)) == 1 {
+		serve(w, r)
+		return
+	}
+	h.auth(serve)(w, r)
+}
+
+func (h *Handlers) calendarEvents(now time.Time) ([]calendar.Event, error) {
+	var windows []database.BandwidthWindow
+	var err error
+	if h.bandwidth != nil {
+		windows, err = h.bandwidth.Windows()
+	} else {
+		windows, err = database.GetBandwidthSchedule()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]calendar.Event, 0, len(windows))
+	for i, win := range windows {
+		days, err := scheduler.ParseDays(win.Days)
+		if err != nil {
+			continue
+		}
+		start, err := calendar.NextWeekly(now, days, win.Start)
+		if err != nil {
+			continue
+		}
+		end, err := time.Parse("15:04", win.End)
+		if err != nil {
+			continue
+		}
+		stop := time.Date(start.Year(), start.Month(), start.Day(), end.Hour(), end.Minute(), 0, 0, start.Location())
+		if !stop.After(start) {
+			stop = stop.AddDate(0, 0, 1) // Crosses midnight
+		}
+		events = append(events, calendar.Event{
+			UID:         fmt.Sprintf("window-%d-%s@schnorarr", i, win.Name),
+			Summary:     windowSummary(win),
+			Description: fmt.Sprintf("Bandwidth window %q, %s %s-%s", win.Name, win.Days, win.Start, win.End),
+			Start:       start, End: stop, Floating: true, Weekly: weekdays(days),
+		})
+	}
+
+	maintenance, err := database.GetMaintenance()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range maintenance {
+		scope := "all engines"
+		if m.EngineID != "" {
+			scope = "engine " + m.EngineID
+		}
+		ev := calendar.Event{
+			UID:         fmt.Sprintf("maintenance-%s-%d@schnorarr", m.EngineID, m.Started.Unix()),
+			Summary:     "schnorarr maintenance (" + scope + ")",
+			Description: m.Reason,
+			Start:       m.Started,
+		}
+		if m.Until != nil {
+			ev.End = *m.Until
+		} else {
+			// Open-ended: shown until a day from now, the feed moves it along
+			ev.End = now.Add(24 * time.Hour)
+			ev.Description = "Until stopped. " + m.Reason
+		}
+		events = append(events, ev)
+	}
+	return events, nil
+}
+
+func windowSummary(w database.BandwidthWindow) string {
+	if w.Limit == 0 {
+		return fmt.Sprintf("schnorarr: full speed sync (%s)", w.Name)
+	}
+	if w.Name == "quiet" {
+		return fmt.Sprintf("schnorarr: quiet hours, %s", w.Limit)
+	}
+	return fmt.Sprintf("schnorarr: sync limited to %s (%s)", w.Limit, w.Name)
+}
+
+func weekdays(days [7]bool) []time.Weekday {
+	var out []time.Weekday
+	for d, on := range days {
+		if on {
+			out = append(out, time.Weekday(d))
+		}
+	}
+	return out
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"schnorarr/internal/bandwidth"
+	"schnorarr/internal/monitor/database"
+)
+
+func TestCalendarFeed(t *testing.T) {
+	database.DBPath = filepath.Join(t.TempDir(), "test.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+
+	_ = os.Setenv("AUTH_ENABLED", "true")
+	_ = os.Setenv("CALENDAR_TOKEN", "cal")
+	defer func() {
+		_ = os.Unsetenv("AUTH_ENABLED")
+		_ = os.Unsetenv("CALENDAR_TOKEN")
+	}()
+	h := New(nil, nil, nil, nil, nil, nil)
+
+	if err := database.SaveBandwidthSchedule([]database.BandwidthWindow{
+		{Name: "night", Days: "*", Start: "23:00", End: "06:00", Limit: 0},
+		{Name: "work", Days: "mon-fri", Start: "08:00", End: "18:00", Limit: bandwidth.FromMbps(20)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.StartMaintenance("2", time.Hour, "moving shows", "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	h.Calendar(w, httptest.NewRequest("GET", "/api/calendar.ics?token=wrong", nil))
+	if w.Code != http.StatusSeeOther {
+		t.Errorf("A wrong token should need a login, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.Calendar(w, httptest.NewRequest("GET", "/api/calendar.ics?token=cal", nil))
+	if w.Code != 200 || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/calendar") {
+		t.Fatalf("Unexpected response %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		"SUMMARY:schnorarr: full speed sync (night)",
+		"RRULE:FREQ=WEEKLY;BYDAY=SU,MO,TU,WE,TH,FR,SA",
+		"SUMMARY:schnorarr: sync limited to 20 Mbit/s (work)",
+		"RRULE:FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR",
+		"SUMMARY:schnorarr maintenance (engine 2)",
+		"DESCRIPTION:moving shows",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Missing %q in\n%s", want, body)
+		}
+	}
+}
@@ -10,17 +10,32 @@ import (
 
 	"github.com/gorilla/websocket"
 
+	"schnorarr/internal/monitor/alerting"
 	"schnorarr/internal/monitor/config"
+	"schnorarr/internal/monitor/database"
 	"schnorarr/internal/monitor/health"
+	"schnorarr/internal/monitor/logging"
 	"schnorarr/internal/monitor/notification"
+	"schnorarr/internal/monitor/scheduler"
+	"schnorarr/internal/monitor/smart"
 	ws "schnorarr/internal/monitor/websocket"
 	syncpkg "schnorarr/internal/sync"
 )
 
+var logger = logging.For("http")
+
 var (
 	AuthEnabled bool
 	AdminUser   string
 	AdminPass   string
+	// HAToken lets Home Assistant call the /api/ha endpoints without a login
+	HAToken string
+	// CalendarToken lets calendar apps subscribe to /api/calendar.ics without a login
+	CalendarToken string
+	// IntegrityToken lets receivers push integrity reports without a login
+	IntegrityToken string
+	// MetricsToken lets Prometheus scrape /metrics without a login
+	MetricsToken string
 )
 
 var upgrader = websocket.Upgrader{
@@ -28,8 +43,9 @@ var upgrader = websocket.Upgrader{
 }
 
 type Session struct {
-	User    string
-	Expires time.Time
+	User     string
+	Expires  time.Time
+	Remember bool // Remember-me login: longer lifetime, persistent cookie
 }
 
 // Handlers contains all HTTP route handlers
@@ -40,8 +56,15 @@ type Handlers struct {
 	db             *sql.DB
 	notifier       *notification.Service
 	engineProvider func() []*syncpkg.Engine
+	diskProvider   func() []smart.Disk
+	bandwidth      *scheduler.Scheduler
+	alerts         *alerting.Manager
+	readOnlyHook   func() // Applies read-only changes right away (receiver mode)
+	latency        func() *database.LatencyStats
+	validator      func() *config.Report
 	sessions       map[string]Session
 	sessionMu      sync.RWMutex
+	requests       *RequestLog
 }
 
 // New creates a new handlers instance
@@ -56,6 +79,12 @@ func New(cfg *config.Config, healthState *health.State, wsHub *ws.Hub, db *sql.D
 	if AdminPass == "" {
 		AdminPass = "schnorarr"
 	}
+	HAToken = os.Getenv("HA_API_TOKEN")
+	CalendarToken = os.Getenv("CALENDAR_TOKEN")
+	IntegrityToken = os.Getenv("INTEGRITY_TOKEN")
+	MetricsToken = os.Getenv("METRICS_TOKEN")
+	loadSessionSettings()
+	BasePath = NormalizeBasePath(os.Getenv("BASE_PATH"))
 
 	return &Handlers{
 		config:         cfg,
@@ -65,9 +94,40 @@ func New(cfg *config.Config, healthState *health.State, wsHub *ws.Hub, db *sql.D
 		notifier:       notifier,
 		engineProvider: engines,
 		sessions:       make(map[string]Session),
+		requests:       NewRequestLog(requestLogSize),
 	}
 }
 
+// SetDiskProvider exposes local SMART data (receiver mode) on the health endpoint
+func (h *Handlers) SetDiskProvider(provider func() []smart.Disk) {
+	h.diskProvider = provider
+}
+
+// SetBandwidthScheduler lets the schedule API apply edits right away
+func (h *Handlers) SetBandwidthScheduler(s *scheduler.Scheduler) {
+	h.bandwidth = s
+}
+
+// SetLatencyProvider exposes the live receiver latency stats (sender mode)
+func (h *Handlers) SetLatencyProvider(fn func() *database.LatencyStats) {
+	h.latency = fn
+}
+
+// SetConfigValidator exposes the configuration check on /api/config/validate
+func (h *Handlers) SetConfigValidator(fn func() *config.Report) {
+	h.validator = fn
+}
+
+// SetReadOnlyHook is called after the read-only switch or schedule changed
+func (h *Handlers) SetReadOnlyHook(fn func()) {
+	h.readOnlyHook = fn
+}
+
+// SetAlertManager exposes the alert state on the alert rules API
+func (h *Handlers) SetAlertManager(m *alerting.Manager) {
+	h.alerts = m
+}
+
 // GetUser returns the username for the current request
 func (h *Handlers) GetUser(r *http.Request) string {
 	cookie, err := r.Cookie("schnorarr_session")
@@ -75,20 +135,9 @@ func (h *Handlers) GetUser(r *http.Request) string {
 		return "unknown"
 	}
 
-	h.sessionMu.RLock()
-	session, ok := h.sessions[cookie.Value]
-	h.sessionMu.RUnlock()
-
+	session, ok := h.session(cookie.Value)
 	if !ok {
 		return "unknown"
 	}
-
-	if time.Now().After(session.Expires) {
-		h.sessionMu.Lock()
-		delete(h.sessions, cookie.Value)
-		h.sessionMu.Unlock()
-		return "unknown"
-	}
-
 	return session.User
 }
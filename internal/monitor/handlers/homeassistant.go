@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/sync"
+)
+
+// haSensors is the payload of /api/ha/sensors, shaped for Home Assistant
+// RESTful sensors (one request feeds every sensor through value_template)
+type haSensors struct {
+	State          string              `json:"state"` // syncing, idle or paused
+	Healthy        bool                `json:"healthy"`
+	Error          string              `json:"error"`
+	ReceiverOnline bool                `json:"receiver_online"`
+	Speed          int64               `json:"speed"` // Bytes per second, all engines
+	Today          int64               `json:"today_bytes"`
+	Total          int64               `json:"total_bytes"`
+	Engines        map[string]haEngine `json:"engines"`
+}
+
+type haEngine struct {
+	Alias   string  `json:"alias"`
+	State   string  `json:"state"` // sync.State* values
+	On      bool    `json:"is_on"` // Not paused
+	File    string  `json:"file"`
+	Percent float64 `json:"percent"`
+	Speed   int64   `json:"speed"`
+	Today   int64   `json:"today_bytes"`
+	Total   int64   `json:"total_bytes"`
+}
+
+// haAuth accepts the HA_API_TOKEN bearer token, as Home Assistant cannot log
+// in, and falls back to the session login otherwise
+func (h *Handlers) haAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if haBearer(r) {
+			next(w, r)
+			return
+		}
+		h.auth(next)(w, r)
+	}
+}
+
+func haBearer(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && HAToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(HAToken)) == 1
+}
+
+// HASensors serves GET /api/ha/sensors: overall state, health and traffic
+// plus the same per engine
+func (h *Handlers) HASensors(w http.ResponseWriter, r *http.Request) {
+	h.haAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		stats := database.GetTrafficStats()
+		out := haSensors{State: "idle", Today: stats.Today, Total: stats.Total, Engines: make(map[string]haEngine)}
+		if h.healthState != nil {
+			out.Healthy, out.Error = h.healthState.GetStatus()
+			out.ReceiverOnline, _, _, _ = h.healthState.GetReceiverStatus()
+		}
+		engines := h.engineProvider()
+		paused := 0
+		for _, e := range engines {
+			st := haEngineState(e)
+			out.Engines[e.GetConfig().ID] = st
+			out.Speed += st.Speed
+			switch st.State {
+			case sync.StateSyncing:
+				out.State = "syncing"
+			case sync.StatePaused:
+				paused++
+			}
+		}
+		if out.State == "idle" && paused > 0 && paused == len(engines) {
+			out.State = "paused"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(out)
+	})(w, r)
+}
+
+func haEngineState(e *sync.Engine) haEngine {
+	file, done, total, speed, _, _ := e.GetTransferStatsExtended()
+	stats := database.GetEngineTrafficStats(e.GetConfig().ID)
+	st := haEngine{Alias: e.GetAlias(), State: e.State(), On: !e.IsPaused(), File: file, Speed: speed, Today: stats.Today, Total: stats.Total}
+	if total > 0 {
+		st.Percent = float64(done) / float64(total) * 100
+	}
+	return st
+}
+
+// HASwitch serves /api/ha/switch/{id|all} for Home Assistant RESTful
+// switches: GET returns {"is_on": bool} (on = not paused), POST with the
+// body ON resumes and OFF pauses
+func (h *Handlers) HASwitch(w http.ResponseWriter, r *http.Request) {
+	h.haAuth(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/ha/switch"), "/")
+		engines, err := h.botEngines(id)
+		if id == "" || err != nil {
+			http.Error(w, "Not found", 404)
+			return
+		}
+		switch r.Method {
+		case "GET":
+		case "POST":
+			body, _ := io.ReadAll(io.LimitReader(r.Body, 1024))
+			action := ""
+			switch strings.ToUpper(strings.Trim(strings.TrimSpace(string(body)), `"`)) {
+			case "ON":
+				action = "resume"
+			case "OFF":
+				action = "pause"
+			default:
+				http.Error(w, "Invalid body", 400)
+				return
+			}
+			for _, e := range engines {
+				applyEngineAction(e, action)
+			}
+			user := h.GetUser(r)
+			if haBearer(r) {
+				user = "homeassistant"
+			}
+			_ = database.LogSystemEvent(user, "HA "+action, "Engines: "+id)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		on := true
+		for _, e := range engines {
+			on = on && !e.IsPaused()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]bool{"is_on": on})
+	})(w, r)
+}
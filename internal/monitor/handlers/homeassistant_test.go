@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/sync"
+)
+
+func TestHomeAssistantEndpoints(t *testing.T) {
+	database.DBPath = filepath.Join(t.TempDir(), "test.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+
+	_ = os.Setenv("AUTH_ENABLED", "true")
+	_ = os.Setenv("HA_API_TOKEN", "secret")
+	defer func() {
+		_ = os.Unsetenv("AUTH_ENABLED")
+		_ = os.Unsetenv("HA_API_TOKEN")
+	}()
+
+	e1 := sync.NewEngine(sync.SyncConfig{ID: "1", SourceDir: t.TempDir(), TargetDir: t.TempDir()})
+	e2 := sync.NewEngine(sync.SyncConfig{ID: "2", SourceDir: t.TempDir(), TargetDir: t.TempDir()})
+	h := New(nil, nil, nil, nil, nil, func() []*sync.Engine { return []*sync.Engine{e1, e2} })
+	// Resuming starts a sync, let it finish before the database closes
+	defer func() {
+		for _, e := range []*sync.Engine{e1, e2} {
+			for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline) && (e.IsBusy() || e.IsScanning()); {
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+	}()
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		if strings.HasPrefix(path, "/api/ha/switch") {
+			h.HASwitch(w, req)
+		} else {
+			h.HASensors(w, req)
+		}
+		return w
+	}
+
+	if w := do("GET", "/api/ha/sensors", "wrong", ""); w.Code != http.StatusSeeOther {
+		t.Errorf("A wrong token should need a login, got %d", w.Code)
+	}
+
+	if w := do("POST", "/api/ha/switch/2", "secret", "OFF"); w.Code != 200 || !strings.Contains(w.Body.String(), `"is_on":false`) {
+		t.Fatalf("Switching off failed: %d %s", w.Code, w.Body.String())
+	}
+	if !e2.IsPaused() || e1.IsPaused() {
+		t.Error("Only engine 2 should be paused")
+	}
+	if w := do("GET", "/api/ha/switch/all", "secret", ""); !strings.Contains(w.Body.String(), `"is_on":false`) {
+		t.Errorf("The all switch should be off while an engine is paused, got %s", w.Body.String())
+	}
+
+	var sensors haSensors
+	if err := json.NewDecoder(do("GET", "/api/ha/sensors", "secret", "").Body).Decode(&sensors); err != nil {
+		t.Fatal(err)
+	}
+	if sensors.Engines["2"].State != "paused" || sensors.Engines["2"].On || !sensors.Engines["1"].On {
+		t.Errorf("Unexpected engine sensors %+v", sensors.Engines)
+	}
+	if sensors.State == "paused" {
+		t.Error("Overall state is paused only when every engine is")
+	}
+
+	if w := do("POST", "/api/ha/switch/2", "secret", "ON"); !strings.Contains(w.Body.String(), `"is_on":true`) || e2.IsPaused() {
+		t.Errorf("Switching on failed: %s", w.Body.String())
+	}
+	if w := do("POST", "/api/ha/switch/2", "secret", "maybe"); w.Code != 400 {
+		t.Errorf("Expected 400 for an invalid body, got %d", w.Code)
+	}
+	if w := do("GET", "/api/ha/switch/9", "secret", ""); w.Code != 404 {
+		t.Errorf("Expected 404 for an unknown engine, got %d", w.Code)
+	}
+
+	items, _ := database.GetEngineHistory(10, 0, "", []string{"SYSTEM"})
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 audit entries, got %+v", items)
+	}
+}
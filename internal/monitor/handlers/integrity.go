@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/monitor/notification"
+	"schnorarr/internal/sync"
+)
+
+// Integrity receives the tree summaries receivers push (POST, authorized with
+// the INTEGRITY_TOKEN bearer token or a login) and compares them with the
+// targets the engines saw in their last cycle. GET returns the last result
+// of every remote engine.
+func (h *Handlers) Integrity(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" && integrityBearer(r) {
+		h.integrityReport(w, r)
+		return
+	}
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			h.integrityStatus(w)
+		case "POST":
+			h.integrityReport(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})(w, r)
+}
+
+func integrityBearer(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && IntegrityToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(IntegrityToken)) == 1
+}
+
+type integrityEngine struct {
+	Engine string                `json:"engine"`
+	Result *sync.IntegrityResult `json:"result"`
+}
+
+func (h *Handlers) integrityReport(w http.ResponseWriter, r *http.Request) {
+	var report sync.IntegrityReport
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		http.Error(w, "Invalid body", 400)
+		return
+	}
+	results := []integrityEngine{}
+	for _, target := range report.Targets {
+		for _, e := range h.engineProvider() {
+			if !e.CoversReport(target.Path) {
+				continue
+			}
+			prev := e.GetIntegrity()
+			res := e.CheckIntegrity(target)
+			results = append(results, integrityEngine{Engine: e.GetConfig().ID, Result: &res})
+			// Flag each divergent tree once, not on every report
+			if res.Status != sync.IntegrityDiverged || (prev != nil && prev.Status == sync.IntegrityDiverged && prev.Reported.Digest == target.Digest) {
+				continue
+			}
+			id := e.GetConfig().ID
+			details := fmt.Sprintf("Engine %s, receiver %s path %s: %s", id, report.Receiver, target.Path, res.Reason)
+			logger.Warn("Target changed outside of sync", "engine", id, "receiver", report.Receiver, "path", target.Path, "reason", res.Reason)
+			_ = database.LogSystemEvent("system", "Integrity Divergence", details)
+			if h.notifier != nil && !database.InMaintenance(id) {
+				go h.notifier.Send(notification.Render(notification.EventIntegrity, notification.Vars{Engine: id, Message: res.Reason}), "WARNING")
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+}
+
+func (h *Handlers) integrityStatus(w http.ResponseWriter) {
+	engines := []integrityEngine{}
+	for _, e := range h.engineProvider() {
+		if e.IsRemoteScan() {
+			engines = append(engines, integrityEngine{Engine: e.GetConfig().ID, Result: e.GetIntegrity()})
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"engines": engines})
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/sync"
+)
+
+func TestIntegrityReport(t *testing.T) {
+	database.DBPath = filepath.Join(t.TempDir(), "test.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+
+	_ = os.Setenv("AUTH_ENABLED", "true")
+	_ = os.Setenv("INTEGRITY_TOKEN", "secret")
+	defer func() {
+		_ = os.Unsetenv("AUTH_ENABLED")
+		_ = os.Unsetenv("INTEGRITY_TOKEN")
+	}()
+
+	e := sync.NewEngine(sync.SyncConfig{ID: "tv", SourceDir: t.TempDir(), TargetDir: "127.0.0.1::video-sync/tv"})
+	h := New(nil, nil, nil, nil, nil, func() []*sync.Engine { return []*sync.Engine{e} })
+
+	body, _ := json.Marshal(sync.IntegrityReport{Receiver: "nas", Targets: []sync.TargetSummary{
+		{Path: "tv", Digest: "abc", Files: 1, Bytes: 10, Scanned: time.Now()},
+		{Path: "movies", Digest: "def"},
+	}})
+	post := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/integrity", strings.NewReader(string(body)))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.Integrity(w, req)
+		return w
+	}
+
+	if w := post("wrong"); w.Code == http.StatusOK {
+		t.Error("A wrong token should not be accepted")
+	}
+	w := post("secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Report failed: %d %s", w.Code, w.Body.String())
+	}
+	var out struct {
+		Results []integrityEngine `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	// The engine has not synced yet, so there is nothing to compare with
+	if len(out.Results) != 1 || out.Results[0].Engine != "tv" || out.Results[0].Result.Status != sync.IntegrityUnknown {
+		t.Errorf("Only the tv target should be checked, got %s", w.Body.String())
+	}
+	if res := e.GetIntegrity(); res == nil || res.Reported.Digest != "abc" {
+		t.Errorf("The engine should keep the result, got %+v", res)
+	}
+}
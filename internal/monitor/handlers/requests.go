@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requestLogSize is how many recent requests are kept for /api/admin/requests
+const requestLogSize = 500
+
+// RequestEntry is one handled request
+type RequestEntry struct {
+	Time         time.Time `json:"time"`
+	Method       string    `json:"method"`
+	Path         string    `json:"path"`
+	Route        string    `json:"route"` // Mux pattern the request matched
+	Status       int       `json:"status"`
+	DurationMs   float64   `json:"duration_ms"`
+	ClientIP     string    `json:"client_ip"`
+	ForwardedFor string    `json:"forwarded_for,omitempty"` // As sent by the client or proxy, not verified
+	UserAgent    string    `json:"user_agent,omitempty"`
+}
+
+type requestKey struct {
+	method, route string
+	status        int
+}
+
+type requestStat struct {
+	count   int64
+	seconds float64
+}
+
+// RequestLog keeps the most recent requests and per-route counters
+type RequestLog struct {
+	mu     sync.Mutex
+	recent []RequestEntry // Ring buffer
+	next   int
+	stats  map[requestKey]*requestStat
+}
+
+// NewRequestLog returns a log keeping the last size requests
+func NewRequestLog(size int) *RequestLog {
+	return &RequestLog{recent: make([]RequestEntry, 0, size), stats: make(map[requestKey]*requestStat)}
+}
+
+// Middleware records every request handled by next. Wrap the mux itself,
+// not a prefix-stripping wrapper, so the matched route is known.
+func (l *RequestLog) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		entry := RequestEntry{
+			Time:         start,
+			Method:       requestMethod(r.Method),
+			Path:         r.URL.Path,
+			Route:        route,
+			Status:       rec.status,
+			DurationMs:   float64(time.Since(start).Microseconds()) / 1000,
+			ClientIP:     clientIP(r),
+			ForwardedFor: r.Header.Get("X-Forwarded-For"),
+			UserAgent:    r.UserAgent(),
+		}
+		l.add(entry)
+		logger.Debug("Request", "method", entry.Method, "path", entry.Path, "status", entry.Status,
+			"duration_ms", entry.DurationMs, "client_ip", entry.ClientIP)
+	})
+}
+
+func (l *RequestLog) add(e RequestEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.recent) < cap(l.recent) {
+		l.recent = append(l.recent, e)
+	} else {
+		l.recent[l.next] = e
+	}
+	l.next = (l.next + 1) % cap(l.recent)
+
+	key := requestKey{method: e.Method, route: e.Route, status: e.Status}
+	st, ok := l.stats[key]
+	if !ok {
+		st = &requestStat{}
+		l.stats[key] = st
+	}
+	st.count++
+	st.seconds += e.DurationMs / 1000
+}
+
+// Recent returns the logged requests, newest first, that match method and
+// start with pathPrefix ("" matches all)
+func (l *RequestLog) Recent(method, pathPrefix string) []RequestEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := []RequestEntry{}
+	for i := 1; i <= len(l.recent); i++ {
+		e := l.recent[(l.next-i+len(l.recent))%len(l.recent)]
+		if (method == "" || strings.EqualFold(e.Method, method)) && strings.HasPrefix(e.Path, pathPrefix) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// WriteMetrics writes the request counters in the Prometheus text format
+func (l *RequestLog) WriteMetrics(w *bufio.Writer) {
+	l.mu.Lock()
+	keys := make([]requestKey, 0, len(l.stats))
+	stats := make(map[requestKey]requestStat, len(l.stats))
+	for k, st := range l.stats {
+		keys = append(keys, k)
+		stats[k] = *st
+	}
+	l.mu.Unlock()
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].status < keys[j].status
+	})
+
+	_, _ = fmt.Fprintln(w, "# HELP schnorarr_http_requests_total HTTP requests by method, route and status.")
+	_, _ = fmt.Fprintln(w, "# TYPE schnorarr_http_requests_total counter")
+	for _, k := range keys {
+		_, _ = fmt.Fprintf(w, "schnorarr_http_requests_total{method=\"%s\",route=\"%s\",status=\"%d\"} %d\n", labelValue(k.method), labelValue(k.route), k.status, stats[k].count)
+	}
+	_, _ = fmt.Fprintln(w, "# HELP schnorarr_http_request_duration_seconds_total Time spent answering HTTP requests.")
+	_, _ = fmt.Fprintln(w, "# TYPE schnorarr_http_request_duration_seconds_total counter")
+	for _, k := range keys {
+		_, _ = fmt.Fprintf(w, "schnorarr_http_request_duration_seconds_total{method=\"%s\",route=\"%s\",status=\"%d\"} %g\n", labelValue(k.method), labelValue(k.route), k.status, stats[k].seconds)
+	}
+}
+
+// requestMethod is the method as logged: anything but the standard methods
+// is "other", so clients cannot grow the counters without bound
+func requestMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "other"
+}
+
+// labelValueEscaper escapes a Prometheus label value; the text format only
+// knows these three escapes
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labelValue(v string) string {
+	return labelValueEscaper.Replace(v)
+}
+
+// clientIP is the address of the peer; X-Forwarded-For is logged apart as
+// anyone can send it
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	if r.RemoteAddr == "" || r.RemoteAddr == "@" {
+		return "unix"
+	}
+	return r.RemoteAddr
+}
+
+// statusRecorder captures the status code. It passes Flush and Hijack
+// through for streamed manifests and the WebSocket.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if !s.wroteHeader {
+		s.status, s.wroteHeader = code, true
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	s.wroteHeader = true
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("hijacking not supported")
+	}
+	s.status, s.wroteHeader = http.StatusSwitchingProtocols, true
+	return hj.Hijack()
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// LogRequests records the requests handled by next for the request log and
+// the metrics
+func (h *Handlers) LogRequests(next http.Handler) http.Handler {
+	return h.requests.Middleware(next)
+}
+
+// Requests serves GET /api/admin/requests: the most recent requests, newest
+// first, filtered by ?method= and ?path= (prefix)
+func (h *Handlers) Requests(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		entries := h.requests.Recent(q.Get("method"), q.Get("path"))
+		if v := q.Get("limit"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n >= 0 && n < len(entries) {
+				entries = entries[:n]
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"requests": entries})
+	})(w, r)
+}
+
+// Metrics serves /metrics in the Prometheus text format. Scrapers pass
+// METRICS_TOKEN as a bearer token; a logged in session works too.
+func (h *Handlers) Metrics(w http.ResponseWriter, r *http.Request) {
+	serve := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		bw := bufio.NewWriter(w)
+		h.requests.WriteMetrics(bw)
+		_ = bw.Flush()
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if ok && MetricsToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(MetricsToken)) == 1 {
+		serve(w, r)
+		return
+	}
+	h.auth(serve)(w, r)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestLog(t *testing.T) {
+	t.Setenv("METRICS_TOKEN", "scrape")
+	h := New(nil, nil, nil, nil, nil, nil)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/delete", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusLocked) })
+	mux.HandleFunc("/api/admin/requests", h.Requests)
+	mux.HandleFunc("/metrics", h.Metrics)
+	srv := h.LogRequests(mux)
+
+	for _, path := range []string{"/api/delete?path=a.mkv", "/api/delete?path=b.mkv", "/nothing"} {
+		req := httptest.NewRequest("POST", path, nil)
+		req.RemoteAddr = "192.168.1.7:40000"
+		srv.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	// Made up methods share one counter
+	for _, method := range []string{"FOO", "BREW"} {
+		srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/api/delete", nil))
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("GET", "/api/admin/requests?path=/api/delete&method=post", nil))
+	var out struct {
+		Requests []RequestEntry `json:"requests"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Requests) != 2 {
+		t.Fatalf("Expected the two deletes, got %+v", out.Requests)
+	}
+	if e := out.Requests[0]; e.Status != http.StatusLocked || e.ClientIP != "192.168.1.7" || e.Route != "/api/delete" {
+		t.Errorf("Unexpected entry: %+v", e)
+	}
+
+	// An unmatched path is counted under the catch-all, not its own route
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer scrape")
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	body := w.Body.String()
+	if !strings.Contains(body, `schnorarr_http_requests_total{method="POST",route="/api/delete",status="423"} 2`) {
+		t.Errorf("Metrics should count the deletes:\n%s", body)
+	}
+	if !strings.Contains(body, `schnorarr_http_requests_total{method="other",route="/api/delete",status="423"} 2`) || strings.Contains(body, "FOO") {
+		t.Errorf("Unknown methods should be counted as other:\n%s", body)
+	}
+	if strings.Contains(body, "/nothing") {
+		t.Errorf("Unknown paths should not become routes:\n%s", body)
+	}
+}
+
+func TestRequestLog_Ring(t *testing.T) {
+	l := NewRequestLog(2)
+	for _, p := range []string{"/a", "/b", "/c"} {
+		l.add(RequestEntry{Method: "GET", Path: p})
+	}
+	got := l.Recent("", "")
+	if len(got) != 2 || got[0].Path != "/c" || got[1].Path != "/b" {
+		t.Errorf("Expected the newest two, newest first, got %+v", got)
+	}
+}
+
+func TestLabelValue(t *testing.T) {
+	if got := labelValue("a\\b\"c\nd\u00e9"); got != `a\\b\"c\nd`+"\u00e9" {
+		t.Errorf("labelValue = %q", got)
+	}
+}
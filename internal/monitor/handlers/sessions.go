@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"schnorarr/internal/monitor/database"
+)
+
+var (
+	// SessionLifetime is how long a login lasts (SESSION_HOURS)
+	SessionLifetime = 24 * time.Hour
+	// RememberLifetime is how long a remember-me login lasts (SESSION_REMEMBER_DAYS)
+	RememberLifetime = 30 * 24 * time.Hour
+	// SlidingSessions extends sessions on activity (SESSION_SLIDING)
+	SlidingSessions = true
+	// CookieSecure is "true", "false" or "auto": Secure only for HTTPS
+	// requests, also behind a proxy setting X-Forwarded-Proto (COOKIE_SECURE)
+	CookieSecure = "true"
+	// CookieSameSite is the SameSite attribute of the cookie (COOKIE_SAMESITE)
+	CookieSameSite = http.SameSiteLaxMode
+)
+
+// loadSessionSettings reads the session settings from the environment
+func loadSessionSettings() {
+	SessionLifetime, RememberLifetime = 24*time.Hour, 30*24*time.Hour
+	if hours, err := strconv.ParseFloat(os.Getenv("SESSION_HOURS"), 64); err == nil && hours > 0 {
+		SessionLifetime = time.Duration(hours * float64(time.Hour))
+	}
+	if days, err := strconv.ParseFloat(os.Getenv("SESSION_REMEMBER_DAYS"), 64); err == nil && days > 0 {
+		RememberLifetime = time.Duration(days * float64(24*time.Hour))
+	}
+	SlidingSessions = os.Getenv("SESSION_SLIDING") != "false"
+
+	CookieSecure = strings.ToLower(strings.TrimSpace(os.Getenv("COOKIE_SECURE")))
+	switch CookieSecure {
+	case "true", "false", "auto":
+	case "":
+		CookieSecure = "true"
+	default:
+		logger.Warn("Invalid COOKIE_SECURE, using true", "value", CookieSecure)
+		CookieSecure = "true"
+	}
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("COOKIE_SAMESITE"))) {
+	case "strict":
+		CookieSameSite = http.SameSiteStrictMode
+	case "none":
+		CookieSameSite = http.SameSiteNoneMode
+		if CookieSecure == "false" {
+			logger.Warn("Browsers reject SameSite=None cookies without Secure, logins will not stick")
+		}
+	default:
+		CookieSameSite = http.SameSiteLaxMode
+	}
+}
+
+// lifetime returns the lifetime of a new or extended session
+func lifetime(remember bool) time.Duration {
+	if remember {
+		return RememberLifetime
+	}
+	return SessionLifetime
+}
+
+// secureCookie reports whether the session cookie of r gets the Secure flag
+func secureCookie(r *http.Request) bool {
+	switch CookieSecure {
+	case "false":
+		return false
+	case "auto":
+		return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+	}
+	return true
+}
+
+// setSessionCookie sends the session cookie. Only remember-me logins get
+// an expiry; other cookies end with the browser session.
+func setSessionCookie(w http.ResponseWriter, r *http.Request, token string, session Session) {
+	cookie := &http.Cookie{
+		Name:     "schnorarr_session",
+		Value:    token,
+		Path:     cookiePath(),
+		HttpOnly: true,
+		Secure:   secureCookie(r),
+		SameSite: CookieSameSite,
+	}
+	if session.Remember {
+		cookie.Expires = session.Expires
+	}
+	http.SetCookie(w, cookie)
+}
+
+// slideSession extends an active session once less than half of its
+// lifetime is left, so the database is not written on every request
+func (h *Handlers) slideSession(w http.ResponseWriter, r *http.Request, token string, session Session) {
+	if !SlidingSessions {
+		return
+	}
+	life := lifetime(session.Remember)
+	if time.Until(session.Expires) > life/2 {
+		return
+	}
+	session.Expires = time.Now().Add(life)
+	h.sessionMu.Lock()
+	h.sessions[token] = session
+	h.sessionMu.Unlock()
+	if err := database.ExtendSession(hashToken(token), session.Expires); err != nil {
+		logger.Error("Failed to extend session", "error", err)
+	}
+	setSessionCookie(w, r, token, session)
+}
+
+// hashToken is the key of a session in the database
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// session returns the unexpired session of a cookie token. Sessions from
+// before a restart are loaded from the database on first use.
+func (h *Handlers) session(token string) (Session, bool) {
+	h.sessionMu.RLock()
+	session, ok := h.sessions[token]
+	h.sessionMu.RUnlock()
+	if !ok {
+		stored, err := database.GetSession(hashToken(token))
+		if err != nil {
+			logger.Error("Failed to load session", "error", err)
+		}
+		if stored == nil {
+			return Session{}, false
+		}
+		session = Session{User: stored.User, Expires: stored.Expires, Remember: stored.Remember}
+		h.sessionMu.Lock()
+		h.sessions[token] = session
+		h.sessionMu.Unlock()
+	}
+	if time.Now().After(session.Expires) {
+		h.endSession(token)
+		return Session{}, false
+	}
+	return session, true
+}
+
+// startSession stores a new session in memory and in the database
+func (h *Handlers) startSession(token string, session Session) {
+	h.sessionMu.Lock()
+	h.sessions[token] = session
+	h.sessionMu.Unlock()
+	err := database.SaveSession(database.StoredSession{
+		TokenHash: hashToken(token), User: session.User, Created: time.Now(), Expires: session.Expires, Remember: session.Remember,
+	})
+	if err != nil {
+		logger.Error("Failed to save session, it ends with a restart", "error", err)
+	}
+}
+
+// endSession removes a session
+func (h *Handlers) endSession(token string) {
+	h.sessionMu.Lock()
+	delete(h.sessions, token)
+	h.sessionMu.Unlock()
+	if err := database.DeleteSession(hashToken(token)); err != nil {
+		logger.Error("Failed to delete session", "error", err)
+	}
+}
+
+// PruneSessions removes expired sessions from memory and the database
+func (h *Handlers) PruneSessions() {
+	now := time.Now()
+	h.sessionMu.Lock()
+	for token, session := range h.sessions {
+		if now.After(session.Expires) {
+			delete(h.sessions, token)
+		}
+	}
+	h.sessionMu.Unlock()
+	n, err := database.PruneSessions()
+	if err != nil {
+		logger.Error("Failed to prune sessions", "error", err)
+	} else if n > 0 {
+		logger.Debug("Pruned expired sessions", "count", n)
+	}
+}
+
+// RunSessionCleanup prunes expired sessions every interval
+func (h *Handlers) RunSessionCleanup(interval time.Duration) {
+	h.PruneSessions()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		h.PruneSessions()
+	}
+}
+
+// Sessions lists the active sessions; DELETE logs out all of them, the
+// caller's included
+func (h *Handlers) Sessions(w http.ResponseWriter, r *http.Request) {
+	h.auth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			sessions, err := database.GetSessions()
+			if err != nil {
+				http.Error(w, "Failed to load sessions", http.StatusInternalServerError)
+				return
+			}
+			if sessions == nil {
+				sessions = []database.StoredSession{}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"sessions": sessions})
+		case "DELETE":
+			user := h.GetUser(r)
+			h.sessionMu.Lock()
+			active := len(h.sessions)
+			h.sessions = make(map[string]Session)
+			h.sessionMu.Unlock()
+			n, err := database.DeleteAllSessions()
+			if err != nil {
+				http.Error(w, "Failed to delete sessions", http.StatusInternalServerError)
+				return
+			}
+			if int(n) > active {
+				active = int(n)
+			}
+			logger.Info("Logged out all sessions", "user", user, "sessions", active)
+			_ = database.LogSystemEvent(user, "Logged Out All", fmt.Sprintf("Ended %d sessions", active))
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"logged_out": active})
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})(w, r)
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"schnorarr/internal/monitor/database"
+)
+
+func login(t *testing.T, h *Handlers) *http.Cookie {
+	t.Helper()
+	form := url.Values{"username": {"admin"}, "password": {"password"}}
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	h.Login(w, req)
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "schnorarr_session" {
+			return c
+		}
+	}
+	t.Fatal("Login set no session cookie")
+	return nil
+}
+
+func TestSessions_Persisted(t *testing.T) {
+	database.DBPath = filepath.Join(t.TempDir(), "test.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+	t.Setenv("AUTH_ENABLED", "true")
+	t.Setenv("ADMIN_USER", "admin")
+	t.Setenv("ADMIN_PASS", "password")
+
+	cookie := login(t, New(nil, nil, nil, nil, nil, nil))
+	stored, _ := database.GetSessions()
+	if len(stored) != 1 || stored[0].TokenHash == cookie.Value {
+		t.Fatalf("Expected one session stored by hash, got %+v", stored)
+	}
+
+	// A restart keeps the login
+	h := New(nil, nil, nil, nil, nil, nil)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookie)
+	if user := h.GetUser(req); user != "admin" {
+		t.Errorf("Session should survive a restart, got user %q", user)
+	}
+
+	// Expired sessions are pruned from memory and the database
+	_ = database.SaveSession(database.StoredSession{TokenHash: hashToken("old"), User: "admin", Created: time.Now().Add(-48 * time.Hour), Expires: time.Now().Add(-time.Hour)})
+	h.sessions["old"] = Session{User: "admin", Expires: time.Now().Add(-time.Hour)}
+	h.PruneSessions()
+	if _, ok := h.sessions["old"]; ok {
+		t.Error("Expired session should be pruned from memory")
+	}
+	var n int
+	_ = database.DB.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&n)
+	if n != 1 {
+		t.Errorf("Expected the expired session to be pruned, %d left", n)
+	}
+
+	// Log out all sessions
+	req = httptest.NewRequest("DELETE", "/api/admin/sessions", nil)
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	h.Sessions(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"logged_out":1`) {
+		t.Fatalf("Logout all = %d %s", w.Code, w.Body.String())
+	}
+	if _, ok := h.session(cookie.Value); ok {
+		t.Error("Session should be gone after logging out all sessions")
+	}
+	if _, ok := New(nil, nil, nil, nil, nil, nil).session(cookie.Value); ok {
+		t.Error("Session should be gone from the database too")
+	}
+}
+
+func TestLogout_EndsSession(t *testing.T) {
+	t.Setenv("AUTH_ENABLED", "true")
+	t.Setenv("ADMIN_USER", "admin")
+	t.Setenv("ADMIN_PASS", "password")
+	h := New(nil, nil, nil, nil, nil, nil)
+	cookie := login(t, h)
+
+	req := httptest.NewRequest("GET", "/logout", nil)
+	req.AddCookie(cookie)
+	h.Logout(httptest.NewRecorder(), req)
+	if _, ok := h.session(cookie.Value); ok {
+		t.Error("Logout should end the session, not only clear the cookie")
+	}
+}
+
+func TestSessionSettings(t *testing.T) {
+	t.Setenv("AUTH_ENABLED", "true")
+	t.Setenv("ADMIN_USER", "admin")
+	t.Setenv("ADMIN_PASS", "password")
+	t.Setenv("SESSION_HOURS", "2")
+	t.Setenv("SESSION_REMEMBER_DAYS", "7")
+	t.Setenv("COOKIE_SECURE", "auto")
+	t.Setenv("COOKIE_SAMESITE", "strict")
+	h := New(nil, nil, nil, nil, nil, nil)
+
+	cookie := login(t, h)
+	if cookie.Secure || !cookie.Expires.IsZero() || cookie.SameSite != http.SameSiteStrictMode {
+		t.Errorf("Plain HTTP login should get a non-Secure browser session cookie, got %+v", cookie)
+	}
+	if s, _ := h.session(cookie.Value); time.Until(s.Expires) > 2*time.Hour || time.Until(s.Expires) < 119*time.Minute {
+		t.Errorf("Session should last SESSION_HOURS, expires %v", s.Expires)
+	}
+
+	form := url.Values{"username": {"admin"}, "password": {"password"}, "remember": {"true"}}
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w := httptest.NewRecorder()
+	h.Login(w, req)
+	remembered := w.Result().Cookies()[0]
+	if !remembered.Secure {
+		t.Error("HTTPS behind a proxy should get a Secure cookie with COOKIE_SECURE=auto")
+	}
+	if until := time.Until(remembered.Expires); until < 6*24*time.Hour || until > 7*24*time.Hour {
+		t.Errorf("Remember-me cookie should last SESSION_REMEMBER_DAYS, expires %v", remembered.Expires)
+	}
+}
+
+func TestSessionSliding(t *testing.T) {
+	t.Setenv("AUTH_ENABLED", "true")
+	t.Setenv("SESSION_HOURS", "10")
+	h := New(nil, nil, nil, nil, nil, nil)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	h.sessions["fresh"] = Session{User: "admin", Expires: time.Now().Add(9 * time.Hour)}
+	h.sessions["old"] = Session{User: "admin", Expires: time.Now().Add(time.Hour), Remember: true}
+	for _, token := range []string{"fresh", "old"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(&http.Cookie{Name: "schnorarr_session", Value: token})
+		h.auth(next)(httptest.NewRecorder(), req)
+	}
+	if until := time.Until(h.sessions["fresh"].Expires); until > 9*time.Hour {
+		t.Error("Sessions with more than half their lifetime left should not be extended")
+	}
+	if until := time.Until(h.sessions["old"].Expires); until < 29*24*time.Hour {
+		t.Errorf("Remember-me session should be extended by its lifetime, %v left", until)
+	}
+
+	t.Setenv("SESSION_SLIDING", "false")
+	h = New(nil, nil, nil, nil, nil, nil)
+	h.sessions["old"] = Session{User: "admin", Expires: time.Now().Add(time.Hour)}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "schnorarr_session", Value: "old"})
+	h.auth(next)(httptest.NewRecorder(), req)
+	if time.Until(h.sessions["old"].Expires) > time.Hour {
+		t.Error("SESSION_SLIDING=false should not extend sessions")
+	}
+}
@@ -1,8 +1,8 @@
 package handlers
 
 import (
+	"fmt"
 	"html/template"
-	"log"
 	"net/http"
 	"path/filepath"
 	"strconv"
@@ -10,9 +10,13 @@ import (
 	"time"
 
 	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/sync"
 	"schnorarr/internal/ui"
 )
 
+// uptimeDays is the number of days in the uptime bar of each engine card
+const uptimeDays = 30
+
 func (h *Handlers) Index(w http.ResponseWriter, r *http.Request) {
 	h.auth(func(w http.ResponseWriter, r *http.Request) {
 		healthy, lastErr := h.healthState.GetStatus()
@@ -40,6 +44,7 @@ func (h *Handlers) Index(w http.ResponseWriter, r *http.Request) {
 			LastSync                   string
 			TrafficToday, TrafficTotal string
 			Rule                       string
+			Groups                     []string
 			PendingDeletions           int
 			WaitingForApproval         bool
 			IsSyncing                  bool
@@ -50,7 +55,10 @@ func (h *Handlers) Index(w http.ResponseWriter, r *http.Request) {
 			SpeedHistory               string
 			Alias                      string
 			HealthGrade, HealthColor   string
+			HealthScore                string
+			Uptime                     []database.UptimeDay
 			IsRemoteScan               bool
+			Backlog                    int
 		}
 		var engineViews []EngineView
 		for _, engine := range h.engineProvider() {
@@ -68,14 +76,21 @@ func (h *Handlers) Index(w http.ResponseWriter, r *http.Request) {
 			}
 
 			grade, color := database.GetEngineHealth(cfg.ID)
+			healthScore := "no syncs in the last 7 days"
+			if score, samples := database.GetEngineHealthScore(cfg.ID); samples > 0 {
+				healthScore = fmt.Sprintf("%.0f%% of recent syncs succeeded", score*100)
+			}
+			now := time.Now()
+			incidents, _ := database.GetEngineIncidents(cfg.ID, now.AddDate(0, 0, -uptimeDays))
 
 			engineViews = append(engineViews, EngineView{
 				ID: cfg.ID, Source: cfg.SourceDir, Target: cfg.TargetDir, Status: engine.GetStatus(), State: "ACTIVE", IsPaused: engine.IsPaused(),
 				LastSync: engine.GetLastSyncTime().Format(time.RFC3339), TrafficToday: database.FormatBytes(stats.Today), TrafficTotal: database.FormatBytes(stats.Total),
-				Rule: cfg.Rule, PendingDeletions: len(engine.GetPendingDeletions()), WaitingForApproval: engine.IsWaitingForApproval(), IsSyncing: isSyncing,
+				Rule: cfg.Rule, Groups: cfg.Groups, PendingDeletions: len(engine.GetPendingDeletions()), WaitingForApproval: engine.IsWaitingForApproval(), IsSyncing: isSyncing,
 				CurrentFile: filepath.Base(file), CurrentPercent: percent, CurrentSpeed: database.FormatBytes(speed) + "/s", SpeedHistory: strings.Join(historyStrings, ","),
 				AvgSpeed: database.FormatBytes(avg) + "/s", Alias: engine.GetAlias(),
-				HealthGrade: grade, HealthColor: color, IsRemoteScan: engine.IsRemoteScan(),
+				HealthGrade: grade, HealthColor: color, HealthScore: healthScore, IsRemoteScan: engine.IsRemoteScan(),
+				Uptime: database.UptimeTimeline(incidents, uptimeDays, now),
 			})
 			if isSyncing {
 				engineViews[len(engineViews)-1].State = "SYNCING"
@@ -83,6 +98,16 @@ func (h *Handlers) Index(w http.ResponseWriter, r *http.Request) {
 			if engine.IsPaused() {
 				engineViews[len(engineViews)-1].State = "PAUSED"
 			}
+			if backlog := engine.GetBacklogStats(); backlog.Offline {
+				engineViews[len(engineViews)-1].State = "OFFLINE"
+				if backlog.ReadOnly {
+					engineViews[len(engineViews)-1].State = "READ-ONLY"
+				}
+				engineViews[len(engineViews)-1].Backlog = backlog.Paths
+			}
+			if engine.IsFailed() {
+				engineViews[len(engineViews)-1].State = "FAILED"
+			}
 			if engine.IsWaitingForApproval() {
 				engineViews[len(engineViews)-1].State = "WAITING_APPROVAL"
 			}
@@ -106,6 +131,7 @@ func (h *Handlers) Index(w http.ResponseWriter, r *http.Request) {
 			History                                    []database.HistoryItem
 			TrafficToday, TrafficTotal                 string
 			TrafficYesterday                           string
+			CostToday                                  string
 			TrafficDelta                               int
 			TrafficDeltaPositive                       bool
 			CurrentSpeed                               string
@@ -114,16 +140,19 @@ func (h *Handlers) Index(w http.ResponseWriter, r *http.Request) {
 			AutoApproveDeletions                       string
 			Engines                                    []EngineView
 			ReceiverVersion, ReceiverUptime            string
+			ReceiverHost                               string
 			SenderOverride                             bool
 			Timestamp                                  int64
+			BasePath                                   string
 		}{
 			Time: time.Now().Format("2006-01-02 15:04:05"), Healthy: healthy, State: state, LastErrorMsg: lastErr, Progress: progress, LsyncdStatus: status, Queued: queued, History: history,
 			TrafficToday: database.FormatBytes(traffic.Today), TrafficTotal: database.FormatBytes(traffic.Total), TrafficYesterday: database.FormatBytes(yesterday),
+			CostToday:    database.CostLabel(traffic.Today),
 			TrafficDelta: deltaPct, TrafficDeltaPositive: deltaPct >= 0,
 			CurrentSpeed: currentSpeed, ETA: eta, SyncMode: database.GetSetting("sync_mode", "dry"), AutoApproveDeletions: database.GetSetting("auto_approve", "off"),
 			Engines: engineViews, ReceiverHealthy: h_rec,
-			ReceiverVersion: rVer, ReceiverUptime: rUp, SenderOverride: h.healthState.IsOverrideEnabled(),
-			Timestamp: time.Now().Unix(),
+			ReceiverVersion: rVer, ReceiverUptime: rUp, ReceiverHost: h.healthState.GetReceiverHost(), SenderOverride: h.healthState.IsOverrideEnabled(),
+			Timestamp: time.Now().Unix(), BasePath: BasePath,
 		}
 
 		funcMap := template.FuncMap{"lower": strings.ToLower}
@@ -133,7 +162,7 @@ func (h *Handlers) Index(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		if err := t.Execute(w, data); err != nil {
-			log.Printf("Template Execute Error: %v", err)
+			logger.Error("Template execution failed", "error", err)
 		}
 	})(w, r)
 }
@@ -147,15 +176,30 @@ func (h *Handlers) History(w http.ResponseWriter, r *http.Request) {
 		}
 		limit := 50
 		offset := (page - 1) * limit
-		history, _ := database.GetHistory(limit, offset, query)
-		totalCount, _ := database.GetHistoryCount(query)
+		group := r.URL.Query().Get("group")
+		var engineIDs []string // nil = all engines
+		if group != "" {
+			engineIDs = []string{}
+			for _, e := range sync.EnginesInGroup(h.engineProvider(), group) {
+				engineIDs = append(engineIDs, e.GetConfig().ID)
+			}
+		}
+		history, _ := database.GetEngineHistory(limit, offset, query, engineIDs)
+		totalCount, _ := database.GetEngineHistoryCount(query, engineIDs)
 		totalPages := (totalCount + limit - 1) / limit
+		var groups []string
+		for _, g := range sync.SummarizeGroups(h.engineProvider()) {
+			groups = append(groups, g.Name)
+		}
 		data := struct {
 			History                                     []database.HistoryItem
-			Query                                       string
+			Query, Group                                string
+			Groups                                      []string
 			CurrentPage, TotalPages, PrevPage, NextPage int
+			BasePath                                    string
 		}{
-			History: history, Query: query, CurrentPage: page, TotalPages: totalPages, PrevPage: page - 1, NextPage: page + 1,
+			History: history, Query: query, Group: group, Groups: groups, CurrentPage: page, TotalPages: totalPages, PrevPage: page - 1, NextPage: page + 1,
+			BasePath: BasePath,
 		}
 		funcMap := template.FuncMap{"lower": strings.ToLower, "add": func(a, b int) int { return a + b }, "sub": func(a, b int) int { return a - b }}
 		t, err := template.New("history.html").Funcs(funcMap).ParseFS(ui.TemplateFS, "web/templates/history.html")
@@ -164,7 +208,7 @@ func (h *Handlers) History(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		if err := t.Execute(w, data); err != nil {
-			log.Printf("Template Error: %v", err)
+			logger.Error("Template execution failed", "error", err)
 		}
 	})(w, r)
 }
@@ -1,12 +1,20 @@
 package handlers
 
 import (
-	"log"
+	"encoding/json"
 	"net/http"
+	"strconv"
 	"time"
+
+	"github.com/gorilla/websocket"
+
+	ws "schnorarr/internal/monitor/websocket"
 )
 
-// WebSocket handler
+// WebSocket serves the dashboard stream. Clients pass the protocol version
+// they were built for as ?protocol=N; a version the server no longer (or not
+// yet) speaks gets an upgrade_required message and the connection is closed.
+// Clients may send subscribe with the refresh interval they want.
 func (h *Handlers) WebSocket(w http.ResponseWriter, r *http.Request) {
 	if AuthEnabled {
 		cookie, err := r.Cookie("schnorarr_session")
@@ -14,10 +22,7 @@ func (h *Handlers) WebSocket(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
-		h.sessionMu.RLock()
-		session, ok := h.sessions[cookie.Value]
-		h.sessionMu.RUnlock()
-		if !ok || time.Now().After(session.Expires) {
+		if _, ok := h.session(cookie.Value); !ok {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
@@ -25,24 +30,46 @@ func (h *Handlers) WebSocket(w http.ResponseWriter, r *http.Request) {
 
 	wsConn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Println(err)
+		logger.Error("WebSocket upgrade failed", "error", err)
+		return
+	}
+
+	version, _ := strconv.Atoi(r.URL.Query().Get("protocol"))
+	if !ws.CheckProtocol(version) {
+		logger.Warn("Rejecting WebSocket client with unsupported protocol", "client", version, "protocol", ws.ProtocolVersion)
+		_ = wsConn.WriteJSON(ws.Message{Type: ws.TopicUpgradeRequired.Name, Data: ws.UpgradeRequired{
+			Protocol: ws.ProtocolVersion, MinProtocol: ws.MinProtocolVersion, Client: version,
+			Reason: "dashboard and server versions differ, reload the page",
+		}})
+		_ = wsConn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "unsupported protocol"))
+		_ = wsConn.Close()
 		return
 	}
 
 	client := h.wsHub.RegisterClient(wsConn)
 	defer h.wsHub.UnregisterClient(client)
 
-	// Send initial state
-	client.SendDirect("init", "Connected")
-
 	// Keep alive / Read loop
 	wsConn.SetReadLimit(512)
 	_ = wsConn.SetReadDeadline(time.Now().Add(60 * time.Second))
 	wsConn.SetPongHandler(func(string) error { _ = wsConn.SetReadDeadline(time.Now().Add(60 * time.Second)); return nil })
 	for {
-		_, _, err := wsConn.ReadMessage()
+		_, data, err := wsConn.ReadMessage()
 		if err != nil {
 			break
 		}
+		var msg struct {
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+		if json.Unmarshal(data, &msg) != nil {
+			continue
+		}
+		if msg.Type == ws.TopicSubscribe.Name {
+			var sub ws.Subscribe
+			if json.Unmarshal(msg.Data, &sub) == nil && sub.IntervalMs >= 0 {
+				client.SetInterval(time.Duration(sub.IntervalMs) * time.Millisecond)
+			}
+		}
 	}
 }
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	ws "schnorarr/internal/monitor/websocket"
+)
+
+func TestWebSocketProtocol(t *testing.T) {
+	hub := ws.New()
+	hub.Retain("engines", []string{"snapshot"})
+	h := New(nil, nil, hub, nil, nil, nil)
+	srv := httptest.NewServer(http.HandlerFunc(h.WebSocket))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	for _, c := range []struct {
+		query string
+		want  string
+	}{
+		{"", "init"},
+		{"?protocol=" + strconv.Itoa(ws.ProtocolVersion), "init"},
+		{"?protocol=" + strconv.Itoa(ws.MinProtocolVersion-1), "upgrade_required"},
+		{"?protocol=99", "upgrade_required"},
+	} {
+		conn, _, err := websocket.DefaultDialer.Dial(url+c.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msg struct {
+			Type string `json:"type"`
+			Data struct {
+				Protocol int `json:"protocol"`
+			} `json:"data"`
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("%s: %v", c.query, err)
+		}
+		if msg.Type != c.want || msg.Data.Protocol != ws.ProtocolVersion {
+			t.Errorf("%s: expected %s with protocol %d, got %+v", c.query, c.want, ws.ProtocolVersion, msg)
+		}
+		if c.want == "init" {
+			var snapshot ws.Message
+			if err := conn.ReadJSON(&snapshot); err != nil || snapshot.Type != "engines" {
+				t.Errorf("Expected the retained snapshot after init, got %+v, %v", snapshot, err)
+			}
+		}
+		if c.want == "upgrade_required" {
+			if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+				t.Errorf("Expected the connection to be closed, got %v", err)
+			}
+		}
+		_ = conn.Close()
+	}
+}
+
+func TestWebSocketSubscribe(t *testing.T) {
+	hub := ws.New()
+	h := New(nil, nil, hub, nil, nil, nil)
+	srv := httptest.NewServer(http.HandlerFunc(h.WebSocket))
+	defer srv.Close()
+
+	if hub.ClientCount() != 0 || hub.Interval(3*time.Second) != 3*time.Second {
+		t.Fatal("Expected no clients and the default interval")
+	}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor := func(what string, ok func() bool) {
+		t.Helper()
+		for i := 0; i < 100 && !ok(); i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if !ok() {
+			t.Fatal(what)
+		}
+	}
+	waitFor("The client should be counted", func() bool { return hub.ClientCount() == 1 })
+
+	_ = conn.WriteJSON(map[string]interface{}{"type": "subscribe", "data": map[string]int{"interval_ms": 100}})
+	waitFor("Short intervals should be raised to the minimum", func() bool { return hub.Interval(3*time.Second) == ws.MinInterval })
+	_ = conn.WriteJSON(map[string]interface{}{"type": "subscribe", "data": map[string]int{"interval_ms": 10000}})
+	waitFor("Expected the requested interval", func() bool { return hub.Interval(3*time.Second) == 10*time.Second })
+
+	_ = conn.Close()
+	waitFor("Closed clients should not count", func() bool { return hub.ClientCount() == 0 })
+}
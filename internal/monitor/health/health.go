@@ -1,7 +1,13 @@
 package health
 
 import (
+	"fmt"
+	"maps"
 	"sync"
+
+	"schnorarr/internal/monitor/notification"
+	"schnorarr/internal/monitor/smart"
+	"schnorarr/internal/monitor/system"
 )
 
 type ReceiverStatus struct {
@@ -17,6 +23,20 @@ type State struct {
 	lastError       string
 	receiver        ReceiverStatus
 	senderOverride  bool
+	receiverDisks   []smart.Disk
+	diskStatus      map[string]string // Last known status per receiver disk, for alerting on changes
+	receiverSystem  *system.Metrics
+	receiverLoad    *system.Load
+	receiverBusy    string // Why transfers are paced, "" when the receiver is not busy
+	receiverHost    string // Receiver the engines currently sync to
+	speedFloor      map[string]string // Engines transferring below their speed floor, with details
+	alerts          Alerter
+}
+
+// Alerter decides which errors notify; without one every error does
+type Alerter interface {
+	Error(msg string)
+	Resolve()
 }
 
 func New() *State {
@@ -25,20 +45,34 @@ func New() *State {
 	}
 }
 
-func (s *State) ReportSuccess(notify func(string, string)) {
+// SetAlerter routes errors and recoveries through the alert rules
+func (s *State) SetAlerter(a Alerter) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.alerts = a
+}
+
+func (s *State) ReportSuccess(notify func(string, string)) {
+	s.mu.Lock()
 	s.healthy = true
 	s.lastError = ""
+	alerts := s.alerts
+	s.mu.Unlock()
+	if alerts != nil {
+		alerts.Resolve()
+	}
 }
 
 func (s *State) ReportError(msg string, notify func(string, string)) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 	s.healthy = false
 	s.lastError = msg
-	if notify != nil {
-		go notify("System Error: "+msg, "CRITICAL")
+	alerts := s.alerts
+	s.mu.Unlock()
+	if alerts != nil {
+		alerts.Error(msg)
+	} else if notify != nil {
+		go notify(notification.Render(notification.EventError, notification.Vars{Error: msg}), "CRITICAL")
 	}
 }
 
@@ -51,6 +85,114 @@ func (s *State) ReportReceiverStatus(healthy bool, msg string, version, uptime s
 	s.receiver.Uptime = uptime
 }
 
+// SetReceiverHost records which receiver host is active
+func (s *State) SetReceiverHost(host string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.receiverHost = host
+}
+
+// GetReceiverHost returns the active receiver host
+func (s *State) GetReceiverHost() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.receiverHost
+}
+
+// ReportReceiverDisks stores the receiver's SMART summaries and notifies when a
+// disk turns warning or failing.
+func (s *State) ReportReceiverDisks(disks []smart.Disk, notify func(string, string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.receiverDisks = disks
+	if s.diskStatus == nil {
+		s.diskStatus = make(map[string]string)
+	}
+	for _, d := range disks {
+		prev, known := s.diskStatus[d.Device]
+		s.diskStatus[d.Device] = d.Status
+		if notify == nil || prev == d.Status {
+			continue
+		}
+		switch d.Status {
+		case smart.StatusFailing:
+			go notify(notification.Render(notification.EventDiskFailing, notification.Vars{Device: d.Device, Model: d.Model}), "ERROR")
+		case smart.StatusWarning:
+			details := fmt.Sprintf("%d°C, %d reallocated, %d pending sectors, %d media errors", d.Temperature, d.Reallocated, d.Pending, d.MediaErrors)
+			go notify(notification.Render(notification.EventDiskWarning, notification.Vars{Device: d.Device, Model: d.Model, Message: details}), "WARNING")
+		case smart.StatusOK:
+			if known && (prev == smart.StatusWarning || prev == smart.StatusFailing) {
+				go notify(notification.Render(notification.EventDiskHealthy, notification.Vars{Device: d.Device, Model: d.Model}), "SUCCESS")
+			}
+		}
+	}
+}
+
+// ReportReceiverSystem stores the receiver's process metrics (nil when unavailable)
+func (s *State) ReportReceiverSystem(m *system.Metrics) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.receiverSystem = m
+}
+
+// ReportReceiverLoad stores the receiver's load (nil when unavailable) and
+// why the sender paces its transfers, "" when it does not
+func (s *State) ReportReceiverLoad(load *system.Load, busy string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.receiverLoad, s.receiverBusy = load, busy
+}
+
+// GetReceiverLoad returns the last load reported by the receiver and why transfers are paced
+func (s *State) GetReceiverLoad() (*system.Load, string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.receiverLoad, s.receiverBusy
+}
+
+// GetReceiverSystem returns the last process metrics reported by the receiver
+func (s *State) GetReceiverSystem() *system.Metrics {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.receiverSystem
+}
+
+// GetReceiverDisks returns the last SMART summaries reported by the receiver
+func (s *State) GetReceiverDisks() []smart.Disk {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]smart.Disk(nil), s.receiverDisks...)
+}
+
+// ReportSpeedFloor records that an engine transfers below its speed floor;
+// an empty detail clears it
+func (s *State) ReportSpeedFloor(engineID, detail string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if detail == "" {
+		delete(s.speedFloor, engineID)
+		return
+	}
+	if s.speedFloor == nil {
+		s.speedFloor = make(map[string]string)
+	}
+	s.speedFloor[engineID] = detail
+}
+
+// SpeedFloors returns the engines below their speed floor, with details
+func (s *State) SpeedFloors() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return maps.Clone(s.speedFloor)
+}
+
+// GetSpeedFloor returns why an engine is below its speed floor, "" when it is not
+func (s *State) GetSpeedFloor(engineID string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.speedFloor[engineID]
+}
+
 func (s *State) GetStatus() (bool, string) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -0,0 +1,78 @@
+package i18n
+
+var de = map[string]string{
+	"notify.error":              `Systemfehler: {{if .Problem}}{{.Problem}}. {{.Hint}} ({{.Error}}){{else}}{{.Error}}{{end}}`,
+	"notify.alert":              `Alarm {{printf "%q" .Rule}}: {{.Error}}{{if .Failures}} ({{.Failures}} Fehler{{if .Window}} in {{.Window}}{{end}}){{end}}{{if .Hint}}. {{.Problem}}: {{.Hint}}{{end}}`,
+	"notify.alert_resolved":     `{{printf "%q" .Rule}} nach {{.Duration}} behoben`,
+	"notify.alert_escalated":    `ESKALIERT: Alarm {{printf "%q" .Rule}} seit {{.Duration}} ungelöst: {{.Error}}{{if .Hint}}. {{.Problem}}: {{.Hint}}{{end}}`,
+	"notify.disk_failing":       `Empfänger-Festplatte {{.Device}} ({{.Model}}) besteht den SMART-Test NICHT. Bitte austauschen, bevor der Spiegel leidet.`,
+	"notify.disk_warning":       `Empfänger-Festplatte {{.Device}} ({{.Model}}) braucht Aufmerksamkeit: {{.Message}}`,
+	"notify.disk_healthy":       `Empfänger-Festplatte {{.Device}} ({{.Model}}) ist wieder in Ordnung`,
+	"notify.quota_exhausted":    `Engine {{.Engine}} pausiert: {{.Error}}, weiter ab {{.Until}}`,
+	"notify.quota_reset":        `Neuer Kontingent-Zeitraum, {{.Message}} Engine(s) laufen weiter`,
+	"notify.failover":           `Empfänger {{.From}} ist ausgefallen, Engines auf {{.To}} umgeschaltet`,
+	"notify.failback":           `Primärer Empfänger {{.To}} ist wieder da, Engines von {{.From}} zurückgeschaltet`,
+	"notify.integrity_diverged": `Ziel von Engine {{.Alias}} wurde außerhalb der Synchronisation geändert: {{.Message}}`,
+	"notify.speed_floor":        `Engine {{.Alias}} überträgt seit {{.Duration}} langsam ({{.Message}}) bei {{.File}}. Bitte Festplatten und Verbindung zum Empfänger prüfen.`,
+	"notify.speed_recovered":    `Engine {{.Alias}} überträgt wieder über der Mindestgeschwindigkeit ({{.Message}})`,
+	"notify.circuit_open":       `Engine {{.Alias}} FEHLGESCHLAGEN nach {{.Failures}} Zielfehlern in Folge ({{.Error}}). Übertragungen stoppen, das Ziel wird alle {{.Duration}} geprüft.{{if .Hint}} {{.Problem}}: {{.Hint}}{{end}}`,
+	"notify.circuit_closed":     `Engine {{.Alias}} hat sich erholt, das Ziel funktioniert nach {{.Duration}} wieder`,
+	"notify.agent_denied":       `Agent-API-Aufruf von {{.From}} blockiert: {{.Message}}`,
+	"notify.test":               `Test vom Dashboard`,
+
+	// Klassifizierte Fehler mit Lösungshinweis
+	"error.permission_denied":       "Zugriff verweigert",
+	"error.permission_denied.hint":  "Der Benutzer, unter dem schnorarr läuft, kann die Quelle nicht lesen oder das Ziel nicht schreiben. PUID/PGID sowie Besitzer und Rechte beider Verzeichnisse prüfen.",
+	"error.disk_full":               "Zielfestplatte voll",
+	"error.disk_full.hint":          "Platz auf dem Ziel freigeben (alte Dateien, Snapshots, Papierkorb) oder UNDO_RETENTION_HOURS verringern; Übertragungen laufen danach von selbst weiter.",
+	"error.read_only":               "Ziel ist schreibgeschützt",
+	"error.read_only.hint":          "Das Dateisystem des Ziels ist schreibgeschützt eingehängt, oft nach Festplattenfehlern, oder der Empfänger ist im Nur-Lesen-Modus. Festplatte prüfen, beschreibbar einhängen oder das Nur-Lesen-Fenster beenden.",
+	"error.auth_failed":             "Anmeldung fehlgeschlagen",
+	"error.auth_failed.hint":        "Der Empfänger hat die Zugangsdaten abgelehnt. RSYNC_PASSWORD muss auf Sender und Empfänger gleich sein.",
+	"error.connection_refused":      "Verbindung abgelehnt",
+	"error.connection_refused.hint": "Der Empfänger antwortet, aber auf dem Port lauscht nichts. Prüfen, ob der Empfänger-Container läuft und die Ports 873 und 8080 freigegeben sind.",
+	"error.unreachable":             "Empfänger nicht erreichbar",
+	"error.unreachable.hint":        "Der Empfänger antwortet nicht. Prüfen, ob er eingeschaltet ist, Netzwerk oder VPN laufen und DEST_HOST stimmt.",
+	"error.missing_path":            "Pfad nicht gefunden",
+	"error.missing_path.hint":       "Ein Quell- oder Zielverzeichnis fehlt, oft ein nicht eingehängtes Laufwerk. Mounts sowie die Pfade SYNC_N_SOURCE und SYNC_N_TARGET prüfen.",
+
+	"status.monitoring":   "Überwachung...",
+	"status.paused":       "Sync pausiert",
+	"status.transferring": "Übertragung...",
+
+	"state.idle":             "bereit",
+	"state.syncing":          "synchronisiert",
+	"state.paused":           "pausiert",
+	"state.failed":           "ausgefallen",
+	"state.maintenance":      "in Wartung",
+	"state.offline":          "Empfänger offline",
+	"state.waiting_approval": "angehalten",
+
+	"progress.running":  "Übertragung läuft",
+	"progress.finished": "Übertragung abgeschlossen",
+	"progress.engine":   "Engine",
+	"progress.size":     "Größe",
+	"progress.progress": "Fortschritt",
+	"progress.speed":    "Geschwindigkeit",
+	"progress.eta":      "Restzeit",
+	"progress.done":     "Fertig",
+
+	"bot.help": `Befehle:
+/status - Zustand aller Engines
+/pause <id|all> - Engines pausieren
+/resume <id|all> - Engines fortsetzen
+/sync <id|all> - Sync starten
+/approve <id> - ausstehende Änderungen einer Engine freigeben`,
+	"bot.unknown_command": "Unbekannter Befehl",
+	"bot.usage":           "Verwendung: %s <id|all>",
+	"bot.usage_approve":   "Verwendung: /approve <id>",
+	"bot.unknown_engine":  "unbekannte Engine %s",
+	"bot.no_engines":      "Keine Engines aktiv",
+	"bot.applied":         "%s: %d Engine(s)",
+	"bot.waiting":         ", %d Änderung(en) warten auf Freigabe",
+	"bot.nothing_pending": "Engine %s wartet auf keine Freigabe",
+	"bot.approved":        "%d ausstehende Änderung(en) von Engine %s freigegeben",
+	"action.sync":         "Sync",
+	"action.pause":        "Pause",
+	"action.resume":       "Fortsetzen",
+}
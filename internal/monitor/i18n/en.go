@@ -0,0 +1,83 @@
+package i18n
+
+var en = map[string]string{
+	// Default notification templates, one per event (Go template syntax)
+	"notify.error":              `System Error: {{if .Problem}}{{.Problem}}. {{.Hint}} ({{.Error}}){{else}}{{.Error}}{{end}}`,
+	"notify.alert":              `Alert {{printf "%q" .Rule}}: {{.Error}}{{if .Failures}} ({{.Failures}} failures{{if .Window}} in {{.Window}}{{end}}){{end}}{{if .Hint}}. {{.Problem}}: {{.Hint}}{{end}}`,
+	"notify.alert_resolved":     `Resolved {{printf "%q" .Rule}} after {{.Duration}}`,
+	"notify.alert_escalated":    `ESCALATED: alert {{printf "%q" .Rule}} unresolved for {{.Duration}}: {{.Error}}{{if .Hint}}. {{.Problem}}: {{.Hint}}{{end}}`,
+	"notify.disk_failing":       `Receiver disk {{.Device}} ({{.Model}}) is FAILING its SMART health check. Replace it before the mirror degrades.`,
+	"notify.disk_warning":       `Receiver disk {{.Device}} ({{.Model}}) needs attention: {{.Message}}`,
+	"notify.disk_healthy":       `Receiver disk {{.Device}} ({{.Model}}) is healthy again`,
+	"notify.quota_exhausted":    `Engine {{.Engine}} paused: {{.Error}}, resumes {{.Until}}`,
+	"notify.quota_reset":        `Traffic quota period rolled over, resuming {{.Message}} engine(s)`,
+	"notify.failover":           `Receiver {{.From}} is down, engines switched to {{.To}}`,
+	"notify.failback":           `Primary receiver {{.To}} recovered, engines switched back from {{.From}}`,
+	"notify.integrity_diverged": `Target of engine {{.Alias}} changed outside of sync: {{.Message}}`,
+	"notify.speed_floor":        `Engine {{.Alias}} has been transferring slowly for {{.Duration}} ({{.Message}}) on {{.File}}. Check the disks and the link to the receiver.`,
+	"notify.speed_recovered":    `Engine {{.Alias}} transfers above its speed floor again ({{.Message}})`,
+	"notify.circuit_open":       `Engine {{.Alias}} FAILED after {{.Failures}} target errors in a row ({{.Error}}). Transfers stop and the target is probed every {{.Duration}}.{{if .Hint}} {{.Problem}}: {{.Hint}}{{end}}`,
+	"notify.circuit_closed":     `Engine {{.Alias}} recovered, its target works again after {{.Duration}}`,
+	"notify.agent_denied":       `Blocked an agent API call from {{.From}}: {{.Message}}`,
+	"notify.test":               `Test from Dashboard`,
+
+	// Classified errors with a remediation hint
+	"error.permission_denied":       "Permission denied",
+	"error.permission_denied.hint":  "The user schnorarr runs as cannot read the source or write the target. Check PUID/PGID and the ownership and mode of both directories.",
+	"error.disk_full":               "Target disk full",
+	"error.disk_full.hint":          "Free up space on the target (old files, snapshots, the trash folder) or lower UNDO_RETENTION_HOURS; transfers resume on their own.",
+	"error.read_only":               "Target is read-only",
+	"error.read_only.hint":          "The target file system is mounted read-only, often after disk errors, or the receiver is in read-only mode. Check the disk, remount it writable or end the read-only window.",
+	"error.auth_failed":             "Authentication failed",
+	"error.auth_failed.hint":        "The receiver rejected the credentials. Make sure RSYNC_PASSWORD is the same on sender and receiver.",
+	"error.connection_refused":      "Connection refused",
+	"error.connection_refused.hint": "The receiver host answers but nothing listens on the port. Check that the receiver container is running and ports 873 and 8080 are published.",
+	"error.unreachable":             "Receiver unreachable",
+	"error.unreachable.hint":        "The receiver does not answer. Check that it is powered on, the network or VPN is up and DEST_HOST is right.",
+	"error.missing_path":            "Path not found",
+	"error.missing_path.hint":       "A source or target directory is missing, often an unmounted drive. Check the mounts and the SYNC_N_SOURCE and SYNC_N_TARGET paths.",
+
+	// Overall sync status
+	"status.monitoring":   "Monitoring...",
+	"status.paused":       "Sync Paused",
+	"status.transferring": "Transferring...",
+
+	// Engine states
+	"state.idle":             "idle",
+	"state.syncing":          "syncing",
+	"state.paused":           "paused",
+	"state.failed":           "failed",
+	"state.maintenance":      "in maintenance",
+	"state.offline":          "receiver offline",
+	"state.waiting_approval": "on hold",
+
+	// Live transfer progress messages
+	"progress.running":  "Transfer in progress",
+	"progress.finished": "Transfer finished",
+	"progress.engine":   "Engine",
+	"progress.size":     "Size",
+	"progress.progress": "Progress",
+	"progress.speed":    "Speed",
+	"progress.eta":      "ETA",
+	"progress.done":     "Done",
+
+	// Chat bot replies
+	"bot.help": `Commands:
+/status - state of every engine
+/pause <id|all> - pause engines
+/resume <id|all> - resume engines
+/sync <id|all> - start a sync
+/approve <id> - approve the pending changes of an engine`,
+	"bot.unknown_command": "Unknown command",
+	"bot.usage":           "Usage: %s <id|all>",
+	"bot.usage_approve":   "Usage: /approve <id>",
+	"bot.unknown_engine":  "unknown engine %s",
+	"bot.no_engines":      "No engines running",
+	"bot.applied":         "%s: %d engine(s)",
+	"bot.waiting":         ", %d change(s) waiting for approval",
+	"bot.nothing_pending": "Engine %s has nothing waiting for approval",
+	"bot.approved":        "Approved %d pending change(s) of engine %s",
+	"action.sync":         "sync",
+	"action.pause":        "pause",
+	"action.resume":       "resume",
+}
@@ -0,0 +1,107 @@
+// Package i18n holds the message catalog for texts users read outside the
+// dashboard: notifications, bot replies and status labels.
+package i18n
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"schnorarr/internal/monitor/database"
+)
+
+// Default is the locale used when none is configured, and for keys a
+// catalog lacks
+const Default = "en"
+
+// settingKey stores the locale chosen at runtime, overriding LOCALE
+const settingKey = "locale"
+
+var catalogs = map[string]map[string]string{
+	"en": en,
+	"de": de,
+}
+
+// Locales returns the supported locales, sorted
+func Locales() []string {
+	locales := make([]string, 0, len(catalogs))
+	for l := range catalogs {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Normalize maps a language tag such as "de-AT" or "de_DE.UTF-8" to a
+// supported locale, or "" when there is none
+func Normalize(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_."); i >= 0 {
+		tag = tag[:i]
+	}
+	if _, ok := catalogs[tag]; ok {
+		return tag
+	}
+	return ""
+}
+
+// Current returns the configured locale: the runtime setting, then the
+// LOCALE environment variable, then Default
+func Current() string {
+	if l := Normalize(database.GetSetting(settingKey, "")); l != "" {
+		return l
+	}
+	if l := Normalize(os.Getenv("LOCALE")); l != "" {
+		return l
+	}
+	return Default
+}
+
+// Set stores the locale; an empty one goes back to LOCALE
+func Set(locale string) error {
+	if locale == "" {
+		return database.SaveSetting(settingKey, "")
+	}
+	l := Normalize(locale)
+	if l == "" {
+		return fmt.Errorf("unsupported locale %q, expected one of %s", locale, strings.Join(Locales(), ", "))
+	}
+	return database.SaveSetting(settingKey, l)
+}
+
+// FromRequest picks the locale of an API response: ?lang=, then the
+// Accept-Language header, then the configured locale
+func FromRequest(r *http.Request) string {
+	if l := Normalize(r.URL.Query().Get("lang")); l != "" {
+		return l
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, _, _ := strings.Cut(part, ";")
+		if l := Normalize(tag); l != "" {
+			return l
+		}
+	}
+	return Current()
+}
+
+// T translates key into the configured locale
+func T(key string, args ...interface{}) string {
+	return In(Current(), key, args...)
+}
+
+// In translates key into locale, falling back to Default and then to the key
+// itself. Args fill the fmt verbs of the message.
+func In(locale, key string, args ...interface{}) string {
+	msg, ok := catalogs[locale][key]
+	if !ok {
+		if msg, ok = catalogs[Default][key]; !ok {
+			msg = key
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
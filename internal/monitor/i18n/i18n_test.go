@@ -0,0 +1,90 @@
+package i18n
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+
+	"schnorarr/internal/monitor/database"
+)
+
+// Every locale must translate exactly the keys of the default catalog, with
+// the same fmt verbs, and its templates must parse
+func TestCatalogsComplete(t *testing.T) {
+	verbs := func(s string) string {
+		var out []string
+		for i := 0; i < len(s)-1; i++ {
+			if s[i] == '%' {
+				out = append(out, s[i:i+2])
+				i++
+			}
+		}
+		return strings.Join(out, "")
+	}
+	for locale, catalog := range catalogs {
+		for key, msg := range catalogs[Default] {
+			tr, ok := catalog[key]
+			if !ok {
+				t.Errorf("%s: missing %s", locale, key)
+				continue
+			}
+			if verbs(tr) != verbs(msg) {
+				t.Errorf("%s: %s uses %q, expected %q", locale, key, verbs(tr), verbs(msg))
+			}
+			if strings.HasPrefix(key, "notify.") {
+				if _, err := template.New(key).Parse(tr); err != nil {
+					t.Errorf("%s: %s does not parse: %v", locale, key, err)
+				}
+			}
+		}
+		for key := range catalog {
+			if _, ok := catalogs[Default][key]; !ok {
+				t.Errorf("%s: %s is not in the default catalog", locale, key)
+			}
+		}
+	}
+}
+
+func TestLocaleSelection(t *testing.T) {
+	database.DBPath = filepath.Join(t.TempDir(), "test.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+
+	if got := T("status.paused"); got != "Sync Paused" {
+		t.Errorf("Expected English by default, got %q", got)
+	}
+	_ = os.Setenv("LOCALE", "de_DE.UTF-8")
+	defer func() { _ = os.Unsetenv("LOCALE") }()
+	if got := T("bot.unknown_engine", "7"); got != "unbekannte Engine 7" {
+		t.Errorf("Expected German from LOCALE, got %q", got)
+	}
+
+	if err := Set("fr"); err == nil {
+		t.Error("Unsupported locales should be rejected")
+	}
+	if err := Set("en-GB"); err != nil {
+		t.Fatal(err)
+	}
+	if Current() != "en" {
+		t.Errorf("The setting should override LOCALE, got %s", Current())
+	}
+
+	req := httptest.NewRequest("GET", "/api/status", nil)
+	req.Header.Set("Accept-Language", "fr-FR, de;q=0.8")
+	if got := FromRequest(req); got != "de" {
+		t.Errorf("Expected de from Accept-Language, got %s", got)
+	}
+	req = httptest.NewRequest("GET", "/api/status?lang=en", nil)
+	req.Header.Set("Accept-Language", "de")
+	if got := FromRequest(req); got != "en" {
+		t.Errorf("?lang should win, got %s", got)
+	}
+	if got := In("de", "no.such.key"); got != "no.such.key" {
+		t.Errorf("Unknown keys should fall back to the key, got %q", got)
+	}
+}
@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+)
+
+// cycleLogLimit caps how many records of sync cycles are kept in memory
+const cycleLogLimit = 5000
+
+// cycleRecorder keeps the most recent records that carry a "cycle" attribute
+type cycleRecorder struct {
+	mu      sync.Mutex
+	records []map[string]interface{}
+}
+
+var recorder = &cycleRecorder{}
+
+func (c *cycleRecorder) Write(p []byte) (int, error) {
+	var rec map[string]interface{}
+	if err := json.Unmarshal(p, &rec); err != nil {
+		return len(p), nil
+	}
+	if cycle, _ := rec["cycle"].(string); cycle == "" {
+		return len(p), nil
+	}
+	c.mu.Lock()
+	c.records = append(c.records, rec)
+	if len(c.records) > cycleLogLimit {
+		c.records = append([]map[string]interface{}(nil), c.records[len(c.records)-cycleLogLimit:]...)
+	}
+	c.mu.Unlock()
+	return len(p), nil
+}
+
+// CycleLogs returns the retained log records of a sync cycle, oldest first
+func CycleLogs(cycle string) []map[string]interface{} {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	records := make([]map[string]interface{}, 0)
+	for _, rec := range recorder.records {
+		if rec["cycle"] == cycle {
+			records = append(records, rec)
+		}
+	}
+	return records
+}
+
+// teeHandler forwards every record to all of its handlers
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	var first error
+	for _, h := range t {
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, r.Clone()); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(teeHandler, len(t))
+	for i, h := range t {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	out := make(teeHandler, len(t))
+	for i, h := range t {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
@@ -0,0 +1,169 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultModule is the name under which the default level is reported and set
+const DefaultModule = "default"
+
+var (
+	base         atomic.Pointer[slog.Handler]
+	defaultLevel = new(slog.LevelVar)
+	levelsMu     sync.RWMutex
+	moduleLevels = make(map[string]slog.Level)
+	modules      = make(map[string]bool)
+)
+
+func init() {
+	setBase(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: redactAttr}))
+}
+
+// setBase installs the output handler; records are also kept for CycleLogs
+func setBase(h slog.Handler) {
+	var tee slog.Handler = teeHandler{h, slog.NewJSONHandler(recorder, &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: redactAttr})}
+	base.Store(&tee)
+}
+
+// Setup writes all log output to w as JSON or, with format "text", as key=value lines.
+// Standard library log calls are routed through the same handler.
+func Setup(w io.Writer, format string) {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: redactAttr}
+	if strings.EqualFold(format, "text") {
+		setBase(slog.NewTextHandler(w, opts))
+	} else {
+		setBase(slog.NewJSONHandler(w, opts))
+	}
+	slog.SetDefault(For("app"))
+}
+
+// ParseLevel converts debug, info, warn(ing) or error to a slog level
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level %q", s)
+}
+
+// Configure applies the default level and a comma separated list of
+// module=level overrides, e.g. "sync=debug,database=warn"
+func Configure(level, overrides string) error {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	defaultLevel.Set(lvl)
+	for _, pair := range strings.Split(overrides, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		module, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid module level %q, expected module=level", pair)
+		}
+		lvl, err := ParseLevel(value)
+		if err != nil {
+			return err
+		}
+		SetLevel(strings.TrimSpace(module), lvl)
+	}
+	return nil
+}
+
+// SetLevel changes the level of a module at runtime. DefaultModule changes the default level.
+func SetLevel(module string, level slog.Level) {
+	if module == DefaultModule {
+		defaultLevel.Set(level)
+		return
+	}
+	levelsMu.Lock()
+	moduleLevels[module] = level
+	levelsMu.Unlock()
+}
+
+// ResetLevel makes a module follow the default level again
+func ResetLevel(module string) {
+	levelsMu.Lock()
+	delete(moduleLevels, module)
+	levelsMu.Unlock()
+}
+
+// Levels returns the default level and the effective level of every known module
+func Levels() map[string]string {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+	def := defaultLevel.Level()
+	levels := map[string]string{DefaultModule: strings.ToLower(def.String())}
+	for module := range modules {
+		levels[module] = strings.ToLower(def.String())
+	}
+	for module, lvl := range moduleLevels {
+		levels[module] = strings.ToLower(lvl.String())
+	}
+	return levels
+}
+
+func levelFor(module string) slog.Level {
+	levelsMu.RLock()
+	lvl, ok := moduleLevels[module]
+	levelsMu.RUnlock()
+	if ok {
+		return lvl
+	}
+	return defaultLevel.Level()
+}
+
+// For returns the logger of a module. Its level follows SetLevel at runtime
+// and every record carries a "module" attribute.
+func For(module string) *slog.Logger {
+	levelsMu.Lock()
+	modules[module] = true
+	levelsMu.Unlock()
+	return slog.New(&moduleHandler{module: module})
+}
+
+// moduleHandler filters records by the level of its module and forwards
+// them to the current base handler
+type moduleHandler struct {
+	module string
+	wrap   []func(slog.Handler) slog.Handler
+}
+
+func (h *moduleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= levelFor(h.module)
+}
+
+func (h *moduleHandler) Handle(ctx context.Context, r slog.Record) error {
+	target := (*base.Load()).WithAttrs([]slog.Attr{slog.String("module", h.module)})
+	for _, wrap := range h.wrap {
+		target = wrap(target)
+	}
+	return target.Handle(ctx, r)
+}
+
+func (h *moduleHandler) with(wrap func(slog.Handler) slog.Handler) *moduleHandler {
+	return &moduleHandler{module: h.module, wrap: append(append([]func(slog.Handler) slog.Handler(nil), h.wrap...), wrap)}
+}
+
+func (h *moduleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+func (h *moduleHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
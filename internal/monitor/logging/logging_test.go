@@ -0,0 +1,109 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	Setup(&buf, "json")
+	t.Cleanup(func() {
+		defaultLevel.Set(slog.LevelInfo)
+		levelsMu.Lock()
+		moduleLevels = make(map[string]slog.Level)
+		levelsMu.Unlock()
+	})
+	return &buf
+}
+
+func TestModuleLevels(t *testing.T) {
+	buf := captureLogs(t)
+	if err := Configure("warn", "sync=debug"); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+
+	For("sync").Debug("sync debug")
+	For("database").Info("database info")
+	For("database").Warn("database warn")
+
+	out := buf.String()
+	if !strings.Contains(out, "sync debug") {
+		t.Error("Debug record of a module set to debug should be written")
+	}
+	if strings.Contains(out, "database info") {
+		t.Error("Info record below the default level should be dropped")
+	}
+	if !strings.Contains(out, "database warn") {
+		t.Error("Warn record at the default level should be written")
+	}
+
+	// Runtime change applies to existing loggers
+	logger := For("database")
+	SetLevel("database", slog.LevelDebug)
+	logger.Debug("database debug")
+	ResetLevel("sync")
+	For("sync").Debug("sync dropped")
+	if !strings.Contains(buf.String(), "database debug") || strings.Contains(buf.String(), "sync dropped") {
+		t.Error("Level changes must apply to loggers created earlier")
+	}
+
+	levels := Levels()
+	if levels[DefaultModule] != "warn" || levels["database"] != "debug" || levels["sync"] != "warn" {
+		t.Errorf("Unexpected levels: %v", levels)
+	}
+}
+
+func TestRecordFields(t *testing.T) {
+	buf := captureLogs(t)
+	For("sync").With("engine", "1", "cycle", "abc").Info("Sync completed", "files", 3)
+
+	var rec map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("Expected one JSON record, got %q: %v", buf.String(), err)
+	}
+	for key, want := range map[string]interface{}{"module": "sync", "engine": "1", "cycle": "abc", "msg": "Sync completed", "files": 3.0, "level": "INFO"} {
+		if rec[key] != want {
+			t.Errorf("%s = %v, want %v", key, rec[key], want)
+		}
+	}
+}
+
+func TestConfigureInvalid(t *testing.T) {
+	captureLogs(t)
+	if err := Configure("loud", ""); err == nil {
+		t.Error("Unknown default level should fail")
+	}
+	if err := Configure("info", "sync"); err == nil {
+		t.Error("Override without level should fail")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	buf := captureLogs(t)
+	t.Cleanup(func() {
+		secretsMu.Lock()
+		secrets = nil
+		secretsMu.Unlock()
+	})
+	Redact("hunter2-secret")
+	Redact("abc") // Too short to mask safely
+
+	For("app").Error("login with hunter2-secret failed", "error", errors.New("bad password hunter2-secret"), "url", "https://x/hunter2-secret/y", "user", "abc")
+
+	out := buf.String()
+	if strings.Contains(out, "hunter2-secret") {
+		t.Errorf("Secret should be masked:\n%s", out)
+	}
+	if strings.Count(out, "***") != 3 {
+		t.Errorf("Message, error and url should be masked:\n%s", out)
+	}
+	if !strings.Contains(out, `"user":"abc"`) {
+		t.Errorf("Short values should not be masked:\n%s", out)
+	}
+}
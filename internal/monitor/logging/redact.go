@@ -0,0 +1,76 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// minSecretLen keeps very short values from masking unrelated log text
+const minSecretLen = 4
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// Redact masks value in all log output from now on, including messages,
+// attributes and errors that quote it
+func Redact(value string) {
+	if len(value) < minSecretLen {
+		return
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, s := range secrets {
+		if s == value {
+			return
+		}
+	}
+	secrets = append(secrets, value)
+}
+
+// redactString replaces registered secrets in s with "***"
+func redactString(s string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, "***")
+	}
+	return s
+}
+
+func hasSecrets() bool {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	return len(secrets) > 0
+}
+
+// redactAttr is the ReplaceAttr hook of the output handlers
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if !hasSecrets() {
+		return a
+	}
+	v := a.Value.Resolve()
+	var s string
+	switch v.Kind() {
+	case slog.KindString:
+		s = v.String()
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case error:
+			s = x.Error()
+		case fmt.Stringer:
+			s = x.String()
+		default:
+			return a
+		}
+	default:
+		return a
+	}
+	if masked := redactString(s); masked != s {
+		return slog.String(a.Key, masked)
+	}
+	return a
+}
@@ -0,0 +1,236 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"schnorarr/internal/monitor/i18n"
+)
+
+// discordEditInterval is the minimum time between edits of a progress message
+const discordEditInterval = 30 * time.Second
+
+// discordClient bounds webhook calls so a hung webhook cannot pile them up
+var discordClient = &http.Client{Timeout: 15 * time.Second}
+
+// Embed colors by severity
+const (
+	colorInfo    = 0x3498DB
+	colorSuccess = 0x2ECC71
+	colorWarning = 0xF39C12
+	colorError   = 0xE74C3C
+)
+
+// Discord notifier sending rich embeds through a webhook
+type Discord struct {
+	WebhookURL string
+	// ProgressAfter is how long a transfer runs before it gets a progress
+	// message that is edited in place (0 = no progress messages)
+	ProgressAfter time.Duration
+
+	mu       sync.Mutex
+	queued   map[string]Progress // Latest state by engine, not sent yet
+	wake     chan struct{}
+	progress map[string]*discordProgress // By engine, only used by sendProgress
+	now      func() time.Time
+}
+
+// discordProgress is the progress message of one engine's transfer
+type discordProgress struct {
+	file      string
+	started   time.Time
+	edited    time.Time
+	messageID string // "" until the message is posted
+	last      Progress
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color"`
+	Fields      []discordField `json:"fields,omitempty"`
+	Timestamp   string         `json:"timestamp,omitempty"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+func severityColor(msgType string) int {
+	switch msgType {
+	case "ERROR", "CRITICAL":
+		return colorError
+	case "WARNING":
+		return colorWarning
+	case "SUCCESS":
+		return colorSuccess
+	}
+	return colorInfo
+}
+
+func (d *Discord) Send(msg, msgType string) error {
+	_, err := d.post(discordEmbed{Description: msg, Color: severityColor(msgType), Timestamp: d.clock().UTC().Format(time.RFC3339)})
+	return err
+}
+
+// Progress queues the state of an engine's transfer and returns right away,
+// so a slow webhook never holds up the caller. Only the latest state of each
+// engine is kept until it is sent.
+func (d *Discord) Progress(p Progress) error {
+	if d.ProgressAfter <= 0 {
+		return nil
+	}
+	d.mu.Lock()
+	if d.queued == nil {
+		d.queued = make(map[string]Progress)
+		d.wake = make(chan struct{}, 1)
+		go d.sendProgress(d.wake)
+	}
+	d.queued[p.Engine] = p
+	wake := d.wake
+	d.mu.Unlock()
+	select {
+	case wake <- struct{}{}:
+	default: // Already signalled
+	}
+	return nil
+}
+
+// sendProgress sends the queued states one at a time
+func (d *Discord) sendProgress(wake <-chan struct{}) {
+	for range wake {
+		d.mu.Lock()
+		queued := d.queued
+		d.queued = make(map[string]Progress)
+		d.mu.Unlock()
+		for _, p := range queued {
+			if err := d.updateProgress(p); err != nil {
+				logger.Error("Progress notification failed", "engine", p.Engine, "error", err)
+			}
+		}
+	}
+}
+
+// updateProgress posts one message for a transfer once it ran ProgressAfter
+// and then edits it with the live state instead of posting new ones; when
+// the engine moves on, the message is edited a last time to show it finished
+func (d *Discord) updateProgress(p Progress) error {
+	if d.progress == nil {
+		d.progress = make(map[string]*discordProgress)
+	}
+	now := d.clock()
+	st := d.progress[p.Engine]
+	if st != nil && st.file != p.File {
+		delete(d.progress, p.Engine)
+		if st.messageID != "" {
+			done := st.last
+			done.Percent, done.ETA = 100, i18n.T("progress.done")
+			if err := d.edit(st.messageID, progressEmbed(done, true)); err != nil {
+				return err
+			}
+		}
+		st = nil
+	}
+	if p.File == "" {
+		return nil
+	}
+	if st == nil {
+		st = &discordProgress{file: p.File, started: now}
+		d.progress[p.Engine] = st
+	}
+	st.last = p
+	switch {
+	case st.messageID == "":
+		if now.Sub(st.started) < d.ProgressAfter {
+			return nil
+		}
+		id, err := d.post(progressEmbed(p, false))
+		if err != nil {
+			return err
+		}
+		st.messageID, st.edited = id, now
+	case now.Sub(st.edited) >= discordEditInterval:
+		st.edited = now
+		return d.edit(st.messageID, progressEmbed(p, false))
+	}
+	return nil
+}
+
+func progressEmbed(p Progress, done bool) discordEmbed {
+	title, color := i18n.T("progress.running"), colorInfo
+	if done {
+		title, color = i18n.T("progress.finished"), colorSuccess
+	}
+	embed := discordEmbed{Title: title, Description: p.File, Color: color, Timestamp: time.Now().UTC().Format(time.RFC3339)}
+	embed.Fields = []discordField{
+		{Name: i18n.T("progress.engine"), Value: p.Engine, Inline: true},
+		{Name: i18n.T("progress.size"), Value: p.Size, Inline: true},
+		{Name: i18n.T("progress.progress"), Value: fmt.Sprintf("%.1f%%", p.Percent), Inline: true},
+	}
+	if !done {
+		embed.Fields = append(embed.Fields, discordField{Name: i18n.T("progress.speed"), Value: p.Speed, Inline: true}, discordField{Name: i18n.T("progress.eta"), Value: p.ETA, Inline: true})
+	}
+	return embed
+}
+
+// post sends an embed and returns the ID of the created message
+func (d *Discord) post(embed discordEmbed) (string, error) {
+	jsonBody, _ := json.Marshal(map[string]interface{}{"embeds": []discordEmbed{embed}})
+	sep := "?"
+	if strings.Contains(d.WebhookURL, "?") {
+		sep = "&"
+	}
+	resp, err := discordClient.Post(d.WebhookURL+sep+"wait=true", "application/json", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return "", fmt.Errorf("discord request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("discord returned status %d", resp.StatusCode)
+	}
+	var msg struct {
+		ID string `json:"id"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&msg)
+	return msg.ID, nil
+}
+
+// edit replaces the embed of a message the webhook posted
+func (d *Discord) edit(messageID string, embed discordEmbed) error {
+	base, query, _ := strings.Cut(d.WebhookURL, "?")
+	url := base + "/messages/" + messageID
+	if query != "" {
+		url += "?" + query
+	}
+	jsonBody, _ := json.Marshal(map[string]interface{}{"embeds": []discordEmbed{embed}})
+	req, err := http.NewRequest(http.MethodPatch, url, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := discordClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("discord request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("discord returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (d *Discord) clock() time.Time {
+	if d.now != nil {
+		return d.now()
+	}
+	return time.Now()
+}
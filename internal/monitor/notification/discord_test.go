@@ -0,0 +1,101 @@
+package notification
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type discordRequest struct {
+	method, path string
+	embed        discordEmbed
+}
+
+func discordServer(t *testing.T, requests *[]discordRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Embeds []discordEmbed `json:"embeds"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.Embeds) != 1 {
+			t.Errorf("Expected one embed, got %v (%v)", body, err)
+		}
+		*requests = append(*requests, discordRequest{r.Method, r.URL.Path, body.Embeds[0]})
+		_, _ = w.Write([]byte(`{"id": "m1"}`))
+	}))
+}
+
+func TestDiscord_SendEmbed(t *testing.T) {
+	var requests []discordRequest
+	srv := discordServer(t, &requests)
+	defer srv.Close()
+
+	d := &Discord{WebhookURL: srv.URL + "/hook"}
+	if err := d.Send("disk failing", "ERROR"); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 1 || requests[0].embed.Color != colorError || requests[0].embed.Description != "disk failing" {
+		t.Errorf("Unexpected request %+v", requests)
+	}
+}
+
+func TestDiscord_ProgressEditsOneMessage(t *testing.T) {
+	var requests []discordRequest
+	srv := discordServer(t, &requests)
+	defer srv.Close()
+
+	now := time.Now()
+	d := &Discord{WebhookURL: srv.URL + "/hook", ProgressAfter: time.Minute, now: func() time.Time { return now }}
+	step := func(after time.Duration, p Progress) {
+		now = now.Add(after)
+		if err := d.updateProgress(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := Progress{Engine: "1", File: "big.mkv", Percent: 10, Size: "40 GB", Speed: "50 MB/s", ETA: "12m"}
+	step(0, p)
+	step(30*time.Second, p)
+	if len(requests) != 0 {
+		t.Fatalf("Short transfers should not post, got %+v", requests)
+	}
+	step(31*time.Second, p)
+	p.Percent = 20
+	step(10*time.Second, p) // Too soon to edit
+	step(30*time.Second, p)
+	step(3*time.Second, Progress{Engine: "1"}) // Transfer over
+
+	if len(requests) != 3 {
+		t.Fatalf("Expected post, edit and final edit, got %+v", requests)
+	}
+	if requests[0].method != "POST" || requests[1].method != "PATCH" || requests[1].path != "/hook/messages/m1" {
+		t.Errorf("Unexpected requests %+v", requests)
+	}
+	if requests[1].embed.Fields[2].Value != "20.0%" {
+		t.Errorf("Edit should carry the new progress, got %+v", requests[1].embed.Fields)
+	}
+	if last := requests[2]; last.embed.Title != "Transfer finished" || last.embed.Color != colorSuccess {
+		t.Errorf("Final edit should mark the transfer finished, got %+v", last.embed)
+	}
+}
+
+func TestDiscord_ProgressDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release // A hung webhook
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	d := &Discord{WebhookURL: srv.URL + "/hook", ProgressAfter: time.Nanosecond}
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := d.Progress(Progress{Engine: "1", File: "big.mkv", Percent: float64(i)}); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Progress should not wait for the webhook, took %v", elapsed)
+	}
+}
@@ -1,35 +1,60 @@
 package notification
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
+	"sort"
+	"time"
+
+	"schnorarr/internal/monitor/logging"
+	"schnorarr/internal/monitor/secretbox"
 )
 
+var logger = logging.For("notification")
+
 // Notifier defines the interface for sending notifications
 type Notifier interface {
 	Send(msg, msgType string) error
 }
 
+// ProgressNotifier is a notifier that can show the progress of long transfers
+type ProgressNotifier interface {
+	Progress(p Progress) error
+}
+
+// Progress is the state of the transfer an engine is running
+type Progress struct {
+	Engine  string // Engine ID
+	File    string // "" when the engine transfers nothing
+	Percent float64
+	Size    string
+	Speed   string
+	ETA     string
+}
+
 // Service handles sending notifications to multiple services
 type Service struct {
 	notifiers []Notifier
+	channels  map[string]Notifier        // Notifiers by channel name ("discord", "telegram")
+	muted     func(engineID string) bool // Suppresses notifications of an engine ("" = global) while true
 }
 
-// New creates a new notification service
+// New creates a new notification service. Credentials may be sealed by
+// secretbox; channels whose credentials do not decrypt stay disabled.
 func New(discordWebhook, telegramToken, telegramChatID string) *Service {
 	s := &Service{
 		notifiers: make([]Notifier, 0),
+		channels:  make(map[string]Notifier),
 	}
+	discordWebhook = openCredential("discord", discordWebhook)
+	telegramToken = openCredential("telegram", telegramToken)
 
 	if discordWebhook != "" {
-		s.notifiers = append(s.notifiers, &Discord{WebhookURL: discordWebhook})
+		s.add("discord", &Discord{WebhookURL: discordWebhook})
 	}
 
 	if telegramToken != "" && telegramChatID != "" {
-		s.notifiers = append(s.notifiers, &Telegram{
+		s.add("telegram", &Telegram{
 			BotToken: telegramToken,
 			ChatID:   telegramChatID,
 		})
@@ -38,8 +63,70 @@ func New(discordWebhook, telegramToken, telegramChatID string) *Service {
 	return s
 }
 
+// openCredential decrypts a sealed credential, "" when it does not decrypt
+func openCredential(channel, value string) string {
+	plain, err := secretbox.Open(value)
+	if err != nil {
+		logger.Error("Notification channel disabled, credentials do not decrypt", "channel", channel, "error", err)
+	}
+	return plain
+}
+
+func (s *Service) add(channel string, n Notifier) {
+	s.notifiers = append(s.notifiers, n)
+	s.channels[channel] = n
+}
+
+// Channels returns the names of the configured channels
+func (s *Service) Channels() []string {
+	names := make([]string, 0, len(s.channels))
+	for name := range s.channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetMute installs a check that suppresses notifications while it returns
+// true. Progress passes its engine, everything else "".
+func (s *Service) SetMute(muted func(engineID string) bool) {
+	s.muted = muted
+}
+
+// SetDiscordProgress makes the Discord notifier keep one live progress message
+// for transfers running longer than after (0 = off)
+func (s *Service) SetDiscordProgress(after time.Duration) {
+	if d, ok := s.channels["discord"].(*Discord); ok {
+		d.ProgressAfter = after
+	}
+}
+
+// Progress forwards the state of an engine's transfer to the notifiers that
+// show live progress
+func (s *Service) Progress(p Progress) {
+	if s.muted != nil && s.muted(p.Engine) {
+		return
+	}
+	for _, notifier := range s.notifiers {
+		if pn, ok := notifier.(ProgressNotifier); ok {
+			if err := pn.Progress(p); err != nil {
+				logger.Error("Progress notification failed", "error", err)
+			}
+		}
+	}
+}
+
 // Send sends a notification to all configured services
 func (s *Service) Send(msg, msgType string) {
+	s.SendTo("", msg, msgType)
+}
+
+// SendTo sends a notification to one channel, or to all with an empty or unknown channel
+func (s *Service) SendTo(channel, msg, msgType string) {
+	if s.muted != nil && s.muted("") {
+		logger.Debug("Notification suppressed", "message", msg)
+		return
+	}
 	emoji := "🔵"
 	switch msgType {
 	case "ERROR":
@@ -49,35 +136,19 @@ func (s *Service) Send(msg, msgType string) {
 	}
 	fullMsg := fmt.Sprintf("[schnorarr] %s %s", emoji, msg)
 
-	for _, notifier := range s.notifiers {
+	targets := s.notifiers
+	if n, ok := s.channels[channel]; ok {
+		targets = []Notifier{n}
+	} else if channel != "" {
+		logger.Warn("Unknown notification channel, sending to all", "channel", channel)
+	}
+	for _, notifier := range targets {
 		if err := notifier.Send(fullMsg, msgType); err != nil {
-			log.Printf("Notification Error: %v", err)
+			logger.Error("Notification failed", "error", err)
 		}
 	}
 }
 
-// Discord notifier
-type Discord struct {
-	WebhookURL string
-}
-
-func (d *Discord) Send(msg, msgType string) error {
-	payload := map[string]string{"content": msg}
-	jsonBody, _ := json.Marshal(payload)
-
-	resp, err := http.Post(d.WebhookURL, "application/json", bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return fmt.Errorf("discord request failed: %w", err)
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("discord returned status %d", resp.StatusCode)
-	}
-
-	return nil
-}
-
 // Telegram notifier
 type Telegram struct {
 	BotToken string
@@ -0,0 +1,25 @@
+package notification
+
+import "testing"
+
+type progressRecorder struct{ engines []string }
+
+func (r *progressRecorder) Send(msg, msgType string) error { return nil }
+
+func (r *progressRecorder) Progress(p Progress) error {
+	r.engines = append(r.engines, p.Engine)
+	return nil
+}
+
+func TestService_MutesEnginesInMaintenance(t *testing.T) {
+	rec := &progressRecorder{}
+	s := &Service{channels: map[string]Notifier{}}
+	s.add("test", rec)
+	s.SetMute(func(engineID string) bool { return engineID == "tv" })
+
+	s.Progress(Progress{Engine: "tv"})
+	s.Progress(Progress{Engine: "movies"})
+	if len(rec.engines) != 1 || rec.engines[0] != "movies" {
+		t.Errorf("Only the engine outside maintenance should report progress, got %v", rec.engines)
+	}
+}
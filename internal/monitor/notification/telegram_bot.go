@@ -0,0 +1,131 @@
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TelegramBot answers commands sent to the bot. Only chats in Allowed are
+// served; messages from other chats are ignored.
+type TelegramBot struct {
+	BotToken string
+	Allowed  map[string]bool // Chat IDs
+	APIURL   string          // Defaults to https://api.telegram.org
+	// Handle runs a command for user and returns the reply
+	Handle func(user, text string) string
+
+	client *http.Client
+}
+
+type telegramUpdate struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		Text string `json:"text"`
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+		From struct {
+			Username string `json:"username"`
+		} `json:"from"`
+	} `json:"message"`
+}
+
+// ParseChatIDs splits a comma separated list of chat IDs
+func ParseChatIDs(list string) map[string]bool {
+	ids := make(map[string]bool)
+	for _, id := range strings.Split(list, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+// Run polls for commands until the process exits
+func (b *TelegramBot) Run() {
+	b.client = &http.Client{Timeout: 40 * time.Second}
+	logger.Info("Telegram bot listening for commands", "chats", len(b.Allowed))
+	var offset int64
+	for {
+		next, err := b.poll(offset, 30)
+		if err != nil {
+			logger.Warn("Telegram poll failed", "error", err)
+			time.Sleep(10 * time.Second)
+			continue
+		}
+		offset = next
+	}
+}
+
+// poll fetches the updates after offset, waiting up to timeout seconds for
+// one, answers them and returns the next offset
+func (b *TelegramBot) poll(offset int64, timeout int) (int64, error) {
+	q := url.Values{"offset": {strconv.FormatInt(offset, 10)}, "timeout": {strconv.Itoa(timeout)}, "allowed_updates": {`["message"]`}}
+	resp, err := b.httpClient().Get(b.endpoint("getUpdates") + "?" + q.Encode())
+	if err != nil {
+		return offset, fmt.Errorf("telegram request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 400 {
+		return offset, fmt.Errorf("telegram returned status %d", resp.StatusCode)
+	}
+	var body struct {
+		OK     bool             `json:"ok"`
+		Result []telegramUpdate `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return offset, fmt.Errorf("telegram response unreadable: %w", err)
+	}
+	for _, u := range body.Result {
+		offset = max(offset, u.UpdateID+1)
+		if u.Message == nil || !strings.HasPrefix(u.Message.Text, "/") {
+			continue
+		}
+		chatID := strconv.FormatInt(u.Message.Chat.ID, 10)
+		if !b.Allowed[chatID] {
+			logger.Warn("Ignoring Telegram command from a chat not on the allow list", "chat", chatID)
+			continue
+		}
+		user := "telegram:" + chatID
+		if u.Message.From.Username != "" {
+			user = "telegram:" + u.Message.From.Username
+		}
+		reply := b.Handle(user, u.Message.Text)
+		if err := b.reply(chatID, reply); err != nil {
+			logger.Error("Telegram reply failed", "error", err)
+		}
+	}
+	return offset, nil
+}
+
+func (b *TelegramBot) reply(chatID, text string) error {
+	resp, err := b.httpClient().PostForm(b.endpoint("sendMessage"), url.Values{"chat_id": {chatID}, "text": {text}})
+	if err != nil {
+		return fmt.Errorf("telegram request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("telegram returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (b *TelegramBot) endpoint(method string) string {
+	base := b.APIURL
+	if base == "" {
+		base = "https://api.telegram.org"
+	}
+	return fmt.Sprintf("%s/bot%s/%s", base, b.BotToken, method)
+}
+
+func (b *TelegramBot) httpClient() *http.Client {
+	if b.client == nil {
+		return http.DefaultClient
+	}
+	return b.client
+}
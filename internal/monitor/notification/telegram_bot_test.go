@@ -0,0 +1,49 @@
+package notification
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTelegramBot_Poll(t *testing.T) {
+	var replies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/getUpdates"):
+			if r.URL.Query().Get("offset") != "5" {
+				t.Errorf("Expected offset 5, got %q", r.URL.Query().Get("offset"))
+			}
+			fmt.Fprint(w, `{"ok": true, "result": [
+				{"update_id": 5, "message": {"text": "/pause 2", "chat": {"id": 42}, "from": {"username": "alice"}}},
+				{"update_id": 6, "message": {"text": "/pause all", "chat": {"id": 99}}},
+				{"update_id": 7, "message": {"text": "hello", "chat": {"id": 42}}}
+			]}`)
+		case strings.HasSuffix(r.URL.Path, "/sendMessage"):
+			replies = append(replies, r.FormValue("chat_id")+":"+r.FormValue("text"))
+		}
+	}))
+	defer srv.Close()
+
+	var commands []string
+	bot := &TelegramBot{BotToken: "token", Allowed: ParseChatIDs("42, 43"), APIURL: srv.URL,
+		Handle: func(user, text string) string {
+			commands = append(commands, user+" "+text)
+			return "done"
+		}}
+	next, err := bot.poll(5, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != 8 {
+		t.Errorf("Expected next offset 8, got %d", next)
+	}
+	if len(commands) != 1 || commands[0] != "telegram:alice /pause 2" {
+		t.Errorf("Only the allowed chat's command should run, got %v", commands)
+	}
+	if len(replies) != 1 || replies[0] != "42:done" {
+		t.Errorf("Unexpected replies %v", replies)
+	}
+}
@@ -0,0 +1,162 @@
+package notification
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/monitor/errclass"
+	"schnorarr/internal/monitor/i18n"
+)
+
+// Notification events with their own message template
+const (
+	EventError          = "error"           // A sync error without alert rules
+	EventAlert          = "alert"           // An alert rule fired
+	EventAlertResolved  = "alert_resolved"  // A firing alert ended
+	EventAlertEscalated = "alert_escalated" // An alert stayed unresolved too long
+	EventDiskFailing    = "disk_failing"
+	EventDiskWarning    = "disk_warning"
+	EventDiskHealthy    = "disk_healthy"
+	EventQuotaExhausted = "quota_exhausted"
+	EventQuotaReset     = "quota_reset"
+	EventFailover       = "failover"
+	EventFailback       = "failback"
+	EventIntegrity      = "integrity_diverged" // A receiver report does not match the engine's target
+	EventSpeedFloor     = "speed_floor"        // An engine transferred below its speed floor for too long
+	EventSpeedRecovered = "speed_recovered"
+	EventCircuitOpen    = "circuit_open" // An engine stopped after consecutive target errors
+	EventCircuitClosed  = "circuit_closed"
+	EventAgentDenied    = "agent_denied" // A peer outside AGENT_ALLOWED_IPS or without AGENT_TOKEN called the agent API
+	EventTest           = "test"
+)
+
+// Vars are the variables a message template can use. Events fill in the
+// fields that apply to them and leave the others empty.
+type Vars struct {
+	Engine   string // Engine ID
+	Alias    string // Engine alias, the ID when none is set
+	File     string
+	Size     string // Human-readable, e.g. "1.2 GB"
+	Duration string // e.g. "12m0s"
+	Error    string
+	Problem  string // Classified Error, e.g. "Target disk full"; "" for unknown errors
+	Hint     string // What to do about a classified Error
+	Message  string // Event details without a field of their own
+	Rule     string // Alert rule name
+	Failures int    // Failures that fired an alert, 0 when one is enough
+	Window   string // Window of an alert rule, e.g. "10 min"
+	Device   string // Receiver disk device
+	Model    string // Receiver disk model
+	From, To string // Receivers of a failover or failback
+	Until    string // Date a quota resets
+}
+
+// TemplateInfo describes the template of an event
+type TemplateInfo struct {
+	Event    string `json:"event"`
+	Default  string `json:"default"`
+	Template string `json:"template"` // The custom template, "" when the default is used
+}
+
+// events lists the events with a template; the default texts are in the
+// i18n catalog under notify.<event>
+var events = []string{
+	EventError, EventAlert, EventAlertResolved, EventAlertEscalated, EventDiskFailing, EventDiskWarning,
+	EventDiskHealthy, EventQuotaExhausted, EventQuotaReset, EventFailover, EventFailback, EventIntegrity,
+	EventSpeedFloor, EventSpeedRecovered, EventCircuitOpen, EventCircuitClosed, EventAgentDenied, EventTest,
+}
+
+// defaultTemplate returns the built-in template of event in the configured locale
+func defaultTemplate(event string) string {
+	return i18n.T("notify." + event)
+}
+
+// SampleVars are the variables used to preview a template
+var SampleVars = Vars{
+	Engine: "1", Alias: "Movies", File: "Movies/Example (2024)/Example.mkv", Size: "4.2 GB", Duration: "12m0s",
+	Error: "write /mnt/tv/a.mkv: no space left on device", Problem: "Target disk full", Hint: "Free up space on the target.", Message: "details", Rule: "sync-errors", Failures: 3, Window: "10 min",
+	Device: "/dev/sda", Model: "WDC WD80EFZX", From: "192.168.1.50", To: "192.168.1.51", Until: "2024-02-01",
+}
+
+func templateKey(event string) string {
+	return "notify_template_" + event
+}
+
+// Events returns the events with a template, sorted by name
+func Events() []string {
+	sorted := append([]string(nil), events...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// KnownEvent reports whether event has a template
+func KnownEvent(event string) bool {
+	return slices.Contains(events, event)
+}
+
+// Templates describes the template of every event
+func Templates() []TemplateInfo {
+	var infos []TemplateInfo
+	for _, event := range Events() {
+		infos = append(infos, TemplateInfo{Event: event, Default: defaultTemplate(event), Template: database.GetSetting(templateKey(event), "")})
+	}
+	return infos
+}
+
+// SetTemplate stores a custom template for event; an empty text restores the default
+func SetTemplate(event, text string) error {
+	if !KnownEvent(event) {
+		return fmt.Errorf("unknown event %q", event)
+	}
+	if strings.TrimSpace(text) == "" {
+		return database.SaveSetting(templateKey(event), "")
+	}
+	if _, err := RenderTemplate(text, SampleVars); err != nil {
+		return err
+	}
+	return database.SaveSetting(templateKey(event), text)
+}
+
+// RenderTemplate executes a template text with v
+func RenderTemplate(text string, v Vars) (string, error) {
+	tmpl, err := template.New("message").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, v); err != nil {
+		return "", fmt.Errorf("template failed: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// Render builds the message of event from its custom template, or from the
+// default of the configured locale when none is set or the custom one fails. The alias is looked up
+// when v names an engine without one, and a known Error is classified into Problem and Hint.
+func Render(event string, v Vars) string {
+	if v.Engine != "" && v.Alias == "" {
+		v.Alias = database.GetSetting("alias_"+v.Engine, v.Engine)
+	}
+	if v.Error != "" && v.Problem == "" {
+		if c := errclass.Classify(v.Error); c != nil {
+			v.Problem, v.Hint = c.Problem, c.Hint
+		}
+	}
+	if custom := database.GetSetting(templateKey(event), ""); custom != "" {
+		msg, err := RenderTemplate(custom, v)
+		if err == nil {
+			return msg
+		}
+		logger.Warn("Custom notification template failed, using the default", "event", event, "error", err)
+	}
+	msg, err := RenderTemplate(defaultTemplate(event), v)
+	if err != nil {
+		return v.Message
+	}
+	return msg
+}
@@ -0,0 +1,68 @@
+package notification
+
+import (
+	"path/filepath"
+	"testing"
+
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/monitor/i18n"
+)
+
+func TestRender_Defaults(t *testing.T) {
+	cases := []struct {
+		event string
+		vars  Vars
+		want  string
+	}{
+		{EventError, Vars{Error: "boom"}, "System Error: boom"},
+		{EventAlert, Vars{Rule: "r", Error: "boom"}, `Alert "r": boom`},
+		{EventAlert, Vars{Rule: "r", Error: "boom", Failures: 3, Window: "10 min"}, `Alert "r": boom (3 failures in 10 min)`},
+		{EventFailover, Vars{From: "a", To: "b"}, "Receiver a is down, engines switched to b"},
+		{EventError, Vars{Error: "mkdir /mnt/tv: read-only file system"}, "System Error: Target is read-only. " + i18n.T("error.read_only.hint") + " (mkdir /mnt/tv: read-only file system)"},
+		{EventAlert, Vars{Rule: "r", Error: "@ERROR: auth failed on module video-sync"}, `Alert "r": @ERROR: auth failed on module video-sync. Authentication failed: ` + i18n.T("error.auth_failed.hint")},
+	}
+	for _, c := range cases {
+		if got := Render(c.event, c.vars); got != c.want {
+			t.Errorf("Render(%s) = %q, want %q", c.event, got, c.want)
+		}
+	}
+}
+
+func TestCustomTemplates(t *testing.T) {
+	database.DBPath = filepath.Join(t.TempDir(), "test.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+	_ = database.SaveSetting("alias_1", "Movies")
+
+	if err := SetTemplate(EventQuotaExhausted, "{{.Alias}} is out of quota until {{.Until}}"); err != nil {
+		t.Fatal(err)
+	}
+	if got := Render(EventQuotaExhausted, Vars{Engine: "1", Until: "2024-02-01"}); got != "Movies is out of quota until 2024-02-01" {
+		t.Errorf("Unexpected custom message %q", got)
+	}
+
+	if err := SetTemplate(EventError, "{{.Nope}}"); err == nil {
+		t.Error("Templates using unknown variables should be rejected")
+	}
+	if err := SetTemplate(EventError, "{{.Error"); err == nil {
+		t.Error("Templates that do not parse should be rejected")
+	}
+	if err := SetTemplate("nope", "x"); err == nil {
+		t.Error("Unknown events should be rejected")
+	}
+
+	// A stored template that breaks falls back to the default
+	_ = database.SaveSetting(templateKey(EventError), "{{.Nope}}")
+	if got := Render(EventError, Vars{Error: "boom"}); got != "System Error: boom" {
+		t.Errorf("Expected the default message, got %q", got)
+	}
+
+	if err := SetTemplate(EventQuotaExhausted, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := Render(EventQuotaExhausted, Vars{Engine: "1", Error: "monthly quota used", Until: "2024-02-01"}); got != "Engine 1 paused: monthly quota used, resumes 2024-02-01" {
+		t.Errorf("Clearing a template should restore the default, got %q", got)
+	}
+}
@@ -2,13 +2,16 @@ package poller
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"schnorarr/internal/monitor/logging"
 )
 
+var logger = logging.For("poller")
+
 const RsyncLog = "/tmp/rsync.log"
 
 // Poller periodically touches directories to trigger lsyncd
@@ -30,7 +33,7 @@ func New(interval time.Duration, dataDir string) *Poller {
 
 // Start begins the polling loop
 func (p *Poller) Start() {
-	log.Printf("Starting Poller (Interval: %v)...", p.interval)
+	logger.Info("Starting poller", "interval", p.interval.String())
 	ticker := time.NewTicker(p.interval)
 
 	for range ticker.C {
@@ -50,7 +53,7 @@ func (p *Poller) Start() {
 					now := time.Now()
 					e := os.Chtimes(path, now, now)
 					if e != nil {
-						log.Printf("Touch Error %s: %v", path, e)
+						logger.Warn("Touch failed", "path", path, "error", e)
 					}
 				} else {
 					return filepath.SkipDir
@@ -60,7 +63,7 @@ func (p *Poller) Start() {
 		})
 
 		if err != nil {
-			log.Printf("[ERROR] Polling failed: %v", err)
+			logger.Error("Polling failed", "error", err)
 			// Log to rsync log so tailer picks it up
 			p.logError(err)
 		}
@@ -74,7 +77,7 @@ func (p *Poller) logError(err error) {
 		defer func() { _ = f.Close() }()
 		if _, err := fmt.Fprintf(f, "%s [ERROR] Polling failed: %v\n",
 			time.Now().Format("2006/01/02 15:04:05"), err); err != nil {
-			log.Printf("Failed to write to rsync log: %v", err)
+			logger.Error("Failed to write to rsync log", "error", err)
 		}
 	}
 }
@@ -0,0 +1,47 @@
+package scheduler
+
+import (
+	"time"
+
+	"schnorarr/internal/monitor/database"
+)
+
+// ReadOnlyState tells whether a receiver refuses transfers and deletes
+type ReadOnlyState struct {
+	ReadOnly bool       `json:"read_only"`
+	Reason   string     `json:"reason,omitempty"`
+	Until    *time.Time `json:"until,omitempty"`  // End of the manual switch, if any
+	Window   string     `json:"window,omitempty"` // Scheduled window in effect
+}
+
+// ReadOnlyAt combines the manual switch and the read-only schedule at t.
+// The manual switch wins so its reason is reported.
+func ReadOnlyAt(t time.Time) ReadOnlyState {
+	if ro := database.GetReadOnly(); ro != nil {
+		reason := ro.Reason
+		if reason == "" {
+			reason = "switched on by " + ro.User
+		}
+		return ReadOnlyState{ReadOnly: true, Reason: reason, Until: ro.Until}
+	}
+	windows, err := database.GetReadOnlySchedule()
+	if err != nil {
+		logger.Error("Failed to load read-only schedule", "error", err)
+		return ReadOnlyState{}
+	}
+	for _, w := range windows {
+		if Covers(w.Days, w.Start, w.End, t) {
+			name := w.Name
+			if name == "" {
+				name = w.Days + " " + w.Start + "-" + w.End
+			}
+			return ReadOnlyState{ReadOnly: true, Reason: "scheduled window " + name, Window: name}
+		}
+	}
+	return ReadOnlyState{}
+}
+
+// ValidateReadOnly checks a read-only window before it is stored
+func ValidateReadOnly(w database.ReadOnlyWindow) error {
+	return validateSpan(w.Name, w.Days, w.Start, w.End)
+}
@@ -2,73 +2,235 @@ package scheduler
 
 import (
 	"fmt"
-	"log"
-	"os"
+	"math"
+	"strings"
+	"sync"
 	"time"
 
+	"schnorarr/internal/bandwidth"
 	"schnorarr/internal/monitor/config"
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/monitor/logging"
 )
 
-// ControlFunc is called to reload lsyncd when bandwidth limit changes
-type ControlFunc func(action string)
+var logger = logging.For("scheduler")
 
 // Scheduler handles bandwidth limit scheduling
 type Scheduler struct {
-	config      *config.Config
-	controlFunc ControlFunc
+	config *config.Config
+
+	mu      sync.Mutex
+	onLimit func(limit bandwidth.Rate)
+	current bandwidth.Rate // Applied limit, -1 before the first decision
+	window  string         // Name of the applied window, "" for the default limit
 }
 
 // New creates a new bandwidth scheduler
-func New(cfg *config.Config, controlFn ControlFunc) *Scheduler {
+func New(cfg *config.Config) *Scheduler {
 	return &Scheduler{
-		config:      cfg,
-		controlFunc: controlFn,
+		config:  cfg,
+		current: -1,
 	}
 }
 
+// SetLimitFunc registers fn to apply a new limit (0 = unlimited) to the sync
+// engines. Local copies pick it up at once; running rsync processes are
+// restarted with it and resume where they stopped.
+func (s *Scheduler) SetLimitFunc(fn func(limit bandwidth.Rate)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onLimit = fn
+	s.current = -1 // Apply the current limit on the next refresh
+}
+
 // Start begins the scheduler loop
 func (s *Scheduler) Start() {
+	s.Refresh()
 	ticker := time.NewTicker(1 * time.Minute)
 	for range ticker.C {
-		if !s.config.SchedulerEnabled {
-			continue
-		}
+		s.Refresh()
+	}
+}
 
-		now := time.Now()
-		currentHM := now.Format("15:04")
+// Status describes the limit in effect
+type Status struct {
+	Limit     bandwidth.Rate `json:"limit"`
+	LimitMbps float64        `json:"limit_mbps"`
+	Window    string         `json:"window"` // Active window name, "" for the default limit
+	Scheduled bool           `json:"scheduled"`
+}
 
-		// Simple check: is current time between Start and End?
-		// Handle crossing midnight: Start > End (e.g. 23:00 to 07:00)
-		inQuietWindow := false
-		if s.config.QuietStart <= s.config.QuietEnd {
-			inQuietWindow = currentHM >= s.config.QuietStart && currentHM < s.config.QuietEnd
-		} else {
-			inQuietWindow = currentHM >= s.config.QuietStart || currentHM < s.config.QuietEnd
-		}
+// Status returns the limit the scheduler applied last
+func (s *Scheduler) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current < 0 {
+		return Status{}
+	}
+	return Status{Limit: s.current, LimitMbps: math.Round(s.current.Mbps()*100) / 100, Window: s.window, Scheduled: true}
+}
+
+// Refresh applies the limit for the current time. It runs every minute and
+// right after the schedule was edited. Once the schedule is cleared the
+// default limit is restored and the engines are left alone.
+func (s *Scheduler) Refresh() {
+	windows, err := s.Windows()
+	if err != nil {
+		logger.Error("Failed to load bandwidth schedule", "error", err)
+		return
+	}
+	limit, window := s.target(windows, time.Now())
+
+	s.mu.Lock()
+	scheduled := len(windows) > 0
+	changed := limit != s.current
+	if !scheduled {
+		changed = s.current >= 0
+		s.current, s.window = -1, ""
+	} else {
+		s.current, s.window = limit, window
+	}
+	onLimit := s.onLimit
+	s.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	if onLimit != nil {
+		onLimit(limit)
+	}
+	if !scheduled {
+		logger.Info("Bandwidth schedule cleared, default limit restored", "limit", limit.String())
+		return
+	}
+	logger.Info("Updated bwlimit", "limit", limit.String(), "window", window)
+}
+
+// target picks the limit for now: the first matching window, otherwise the
+// default limit
+func (s *Scheduler) target(windows []database.BandwidthWindow, now time.Time) (bandwidth.Rate, string) {
+	if w := ActiveWindow(windows, now); w != nil {
+		return w.Limit, w.Name
+	}
+	return s.defaultLimit(), ""
+}
+
+// Windows returns the schedule table, or the legacy quiet window when the
+// table is empty and the scheduler is enabled in the config. Without
+// windows the scheduler does not touch the engines' limits.
+func (s *Scheduler) Windows() ([]database.BandwidthWindow, error) {
+	windows, err := database.GetBandwidthSchedule()
+	if err != nil || len(windows) > 0 {
+		return windows, err
+	}
+	if s.config == nil || !s.config.SchedulerEnabled {
+		return nil, nil
+	}
+	return []database.BandwidthWindow{{Name: "quiet", Days: "*", Start: s.config.QuietStart, End: s.config.QuietEnd, Limit: s.config.QuietLimit}}, nil
+}
 
-		targetLimit := s.config.NormalLimit
-		if inQuietWindow {
-			targetLimit = s.config.QuietLimit
+// defaultLimit applies outside every window: the configured normal limit,
+// otherwise BWLIMIT (or BWLIMIT_MBPS)
+func (s *Scheduler) defaultLimit() bandwidth.Rate {
+	if s.config != nil && s.config.NormalLimit > 0 {
+		return s.config.NormalLimit
+	}
+	limit, _, err := bandwidth.FromEnv()
+	if err != nil {
+		logger.Error("Invalid bandwidth limit, transfers are unlimited", "error", err)
+	}
+	return limit
+}
+
+// ActiveWindow returns the first window covering t, or nil. A window whose
+// end is before its start crosses midnight and belongs to the day it starts.
+func ActiveWindow(windows []database.BandwidthWindow, t time.Time) *database.BandwidthWindow {
+	for i := range windows {
+		if Covers(windows[i].Days, windows[i].Start, windows[i].End, t) {
+			return &windows[i]
 		}
+	}
+	return nil
+}
 
-		// Read current limit from file to see if update needed
-		currentFileLimit := -1
-		if b, err := os.ReadFile("/config/bwlimit"); err == nil {
-			if _, err := fmt.Sscanf(string(b), "%d", &currentFileLimit); err != nil {
-				log.Printf("Scheduler: Failed to parse bwlimit: %v", err)
+// Covers reports whether the weekly window days/start/end covers t. A window
+// whose end is before its start crosses midnight and belongs to the day it starts.
+func Covers(daySpec, start, end string, t time.Time) bool {
+	days, err := ParseDays(daySpec)
+	if err != nil {
+		return false
+	}
+	hm := t.Format("15:04")
+	if start <= end {
+		return days[t.Weekday()] && hm >= start && hm < end
+	}
+	yesterday := (t.Weekday() + 6) % 7
+	return (days[t.Weekday()] && hm >= start) || (days[yesterday] && hm < end)
+}
+
+var dayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseDays parses a day list such as "mon-fri", "sat,sun", "weekday",
+// "weekend" or "*" into the weekdays it covers
+func ParseDays(spec string) ([7]bool, error) {
+	var days [7]bool
+	for _, part := range strings.Split(strings.ToLower(spec), ",") {
+		part = strings.TrimSpace(part)
+		switch part {
+		case "*", "daily", "all":
+			return [7]bool{true, true, true, true, true, true, true}, nil
+		case "weekday", "weekdays":
+			part = "mon-fri"
+		case "weekend", "weekends":
+			part = "sat-sun"
+		}
+		from, to, isRange := strings.Cut(part, "-")
+		first, ok := dayNames[from]
+		if !ok {
+			return days, fmt.Errorf("unknown day %q", from)
+		}
+		last := first
+		if isRange {
+			if last, ok = dayNames[to]; !ok {
+				return days, fmt.Errorf("unknown day %q", to)
 			}
 		}
-
-		if targetLimit != currentFileLimit {
-			limitStr := fmt.Sprintf("%d", targetLimit)
-			if err := os.WriteFile("/config/bwlimit", []byte(limitStr), 0644); err != nil {
-				log.Printf("Scheduler: Failed to write bwlimit: %v", err)
-			} else {
-				if s.controlFunc != nil {
-					s.controlFunc("reload")
-				}
-				log.Printf("Scheduler: Updated bwlimit to %d Mbps (Quiet: %v)", targetLimit, inQuietWindow)
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
 			}
 		}
 	}
+	return days, nil
+}
+
+// Validate checks a window before it is stored
+func Validate(w database.BandwidthWindow) error {
+	if err := validateSpan(w.Name, w.Days, w.Start, w.End); err != nil {
+		return err
+	}
+	if w.Limit < 0 {
+		return fmt.Errorf("invalid limit %d B/s", int64(w.Limit))
+	}
+	return nil
+}
+
+// validateSpan checks the days and times of a weekly window
+func validateSpan(name, days, start, end string) error {
+	if _, err := ParseDays(days); err != nil {
+		return err
+	}
+	for _, hm := range []string{start, end} {
+		if _, err := time.Parse("15:04", hm); err != nil || len(hm) != 5 {
+			return fmt.Errorf("invalid time %q, expected HH:MM", hm)
+		}
+	}
+	if start == end {
+		return fmt.Errorf("window %q is empty", name)
+	}
+	return nil
 }
@@ -0,0 +1,184 @@
+package scheduler
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"schnorarr/internal/bandwidth"
+	"schnorarr/internal/monitor/config"
+	"schnorarr/internal/monitor/database"
+)
+
+func TestParseDays(t *testing.T) {
+	for spec, want := range map[string][]time.Weekday{
+		"mon-fri":  {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		"weekend":  {time.Saturday, time.Sunday},
+		"sat, SUN": {time.Saturday, time.Sunday},
+		"fri-mon":  {time.Friday, time.Saturday, time.Sunday, time.Monday},
+		"wed":      {time.Wednesday},
+		"*":        {time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday},
+	} {
+		days, err := ParseDays(spec)
+		if err != nil {
+			t.Errorf("%q: %v", spec, err)
+			continue
+		}
+		var got []time.Weekday
+		for d, on := range days {
+			if on {
+				got = append(got, time.Weekday(d))
+			}
+		}
+		if len(got) != len(want) {
+			t.Errorf("%q: expected %v, got %v", spec, want, got)
+		}
+	}
+	for _, bad := range []string{"", "someday", "mon-xyz"} {
+		if _, err := ParseDays(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestActiveWindow(t *testing.T) {
+	windows := []database.BandwidthWindow{
+		{Name: "work", Days: "mon-fri", Start: "08:00", End: "18:00", Limit: bandwidth.FromMbps(20)},
+		{Name: "night", Days: "weekday", Start: "23:00", End: "06:00", Limit: 0},
+		{Name: "weekend", Days: "sat,sun", Start: "10:00", End: "22:00", Limit: bandwidth.FromMbps(50)},
+	}
+	at := func(day, hm string) time.Time {
+		ts, err := time.Parse("2006-01-02 15:04", day+" "+hm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ts
+	}
+	// 2026-10-16 is a Friday
+	for _, tc := range []struct {
+		day, hm, want string
+	}{
+		{"2026-10-16", "09:30", "work"},
+		{"2026-10-16", "18:00", ""},
+		{"2026-10-16", "23:30", "night"},
+		{"2026-10-17", "03:00", "night"}, // Friday night continues into Saturday
+		{"2026-10-17", "12:00", "weekend"},
+		{"2026-10-18", "23:30", ""}, // Sunday night is no weekday window
+		{"2026-10-19", "03:00", ""},
+	} {
+		got := ""
+		if w := ActiveWindow(windows, at(tc.day, tc.hm)); w != nil {
+			got = w.Name
+		}
+		if got != tc.want {
+			t.Errorf("%s %s: expected %q, got %q", tc.day, tc.hm, tc.want, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(database.BandwidthWindow{Days: "weekday", Start: "23:00", End: "06:00", Limit: bandwidth.FromMbps(5)}); err != nil {
+		t.Errorf("Valid window rejected: %v", err)
+	}
+	for name, w := range map[string]database.BandwidthWindow{
+		"days":  {Days: "never", Start: "01:00", End: "02:00"},
+		"time":  {Days: "*", Start: "25:00", End: "02:00"},
+		"short": {Days: "*", Start: "1:00", End: "02:00"},
+		"empty": {Days: "*", Start: "02:00", End: "02:00"},
+		"limit": {Days: "*", Start: "01:00", End: "02:00", Limit: -1},
+	} {
+		if err := Validate(w); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestScheduler_RefreshAppliesSchedule(t *testing.T) {
+	database.DBPath = filepath.Join(t.TempDir(), "scheduler.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+
+	s := New(&config.Config{NormalLimit: bandwidth.FromMbps(100)})
+	var applied []bandwidth.Rate
+	s.SetLimitFunc(func(limit bandwidth.Rate) { applied = append(applied, limit) })
+
+	s.Refresh()
+	if len(applied) != 0 {
+		t.Fatal("Without schedule or legacy scheduler nothing should be applied")
+	}
+
+	if err := database.SaveBandwidthSchedule([]database.BandwidthWindow{{Name: "always", Days: "*", Start: "00:00", End: "23:59", Limit: bandwidth.FromMbps(10)}}); err != nil {
+		t.Fatal(err)
+	}
+	s.Refresh()
+	s.Refresh()
+	if time.Now().Format("15:04") == "23:59" {
+		t.Skip("Outside the window for one minute a day")
+	}
+	if len(applied) != 1 || applied[0] != bandwidth.FromMbps(10) {
+		t.Errorf("Expected the window limit applied once, got %v", applied)
+	}
+	if st := s.Status(); st.Limit != bandwidth.FromMbps(10) || st.LimitMbps != 10 || st.Window != "always" {
+		t.Errorf("Unexpected status %+v", st)
+	}
+
+	if err := database.SaveBandwidthSchedule([]database.BandwidthWindow{{Name: "never", Days: "*", Start: "00:00", End: "00:00"}}); err != nil {
+		t.Fatal(err)
+	}
+	s.Refresh()
+	if applied[len(applied)-1] != bandwidth.FromMbps(100) {
+		t.Errorf("Outside every window the normal limit applies, got %v", applied)
+	}
+
+	if err := database.SaveBandwidthSchedule([]database.BandwidthWindow{{Name: "always", Days: "*", Start: "00:00", End: "23:59", Limit: bandwidth.FromMbps(10)}}); err != nil {
+		t.Fatal(err)
+	}
+	s.Refresh()
+	if err := database.SaveBandwidthSchedule(nil); err != nil {
+		t.Fatal(err)
+	}
+	s.Refresh()
+	if applied[len(applied)-1] != bandwidth.FromMbps(100) || s.Status().Scheduled {
+		t.Errorf("Clearing the schedule should restore the default limit, got %v, status %+v", applied, s.Status())
+	}
+	n := len(applied)
+	s.Refresh()
+	if len(applied) != n {
+		t.Errorf("Without a schedule the limit should not be applied again, got %v", applied)
+	}
+}
+
+func TestReadOnlyAt(t *testing.T) {
+	database.DBPath = filepath.Join(t.TempDir(), "readonly.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+
+	sunday := time.Date(2024, 6, 2, 3, 0, 0, 0, time.Local)
+	if st := ReadOnlyAt(sunday); st.ReadOnly {
+		t.Fatalf("Receivers start writable, got %+v", st)
+	}
+	if err := database.SaveReadOnlySchedule([]database.ReadOnlyWindow{{Name: "scrub", Days: "sun", Start: "02:00", End: "05:00"}}); err != nil {
+		t.Fatal(err)
+	}
+	if st := ReadOnlyAt(sunday); !st.ReadOnly || st.Window != "scrub" {
+		t.Errorf("Expected the scheduled window, got %+v", st)
+	}
+	if st := ReadOnlyAt(sunday.Add(24 * time.Hour)); st.ReadOnly {
+		t.Errorf("Monday is outside the window, got %+v", st)
+	}
+
+	if err := database.StartReadOnly(0, "fsck", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if st := ReadOnlyAt(sunday); !st.ReadOnly || st.Reason != "fsck" || st.Window != "" {
+		t.Errorf("The manual switch should win over the schedule, got %+v", st)
+	}
+
+	if err := ValidateReadOnly(database.ReadOnlyWindow{Days: "sun", Start: "2:00", End: "05:00"}); err == nil {
+		t.Error("Invalid times should be rejected")
+	}
+}
@@ -0,0 +1,93 @@
+// Package secretbox encrypts sensitive settings (webhooks, tokens) at rest
+// with a master key from SETTINGS_KEY. Sealed values carry a prefix, so
+// plaintext values keep working and are sealed the next time they are saved.
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// Prefix marks sealed values
+const Prefix = "enc:v1:"
+
+var (
+	// ErrNoKey is returned when opening a sealed value without SETTINGS_KEY
+	ErrNoKey = errors.New("value is encrypted but SETTINGS_KEY is not set")
+	// ErrWrongKey is returned when a sealed value does not open with the key
+	ErrWrongKey = errors.New("value does not decrypt with SETTINGS_KEY")
+)
+
+var (
+	mu   sync.RWMutex
+	aead cipher.AEAD
+)
+
+// SetKey sets the master key; "" disables encryption. Any passphrase works,
+// the AES-256 key is its SHA-256 hash.
+func SetKey(passphrase string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if passphrase == "" {
+		aead = nil
+		return
+	}
+	key := sha256.Sum256([]byte(passphrase))
+	block, _ := aes.NewCipher(key[:]) // 32 bytes never fail
+	aead, _ = cipher.NewGCM(block)
+}
+
+// Enabled reports whether a master key is set
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return aead != nil
+}
+
+// IsSealed reports whether value was encrypted by Seal
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// Seal encrypts value. Without a key, and for empty or sealed values, it
+// returns value unchanged.
+func Seal(value string) (string, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if aead == nil || value == "" || IsSealed(value) {
+		return value, nil
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), nil)
+	return Prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a sealed value; other values are returned unchanged
+func Open(value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	if aead == nil {
+		return "", ErrNoKey
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, Prefix))
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", ErrWrongKey
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", ErrWrongKey
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,45 @@
+package secretbox
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	t.Cleanup(func() { SetKey("") })
+
+	SetKey("")
+	if v, _ := Seal("https://hook"); v != "https://hook" {
+		t.Errorf("Without a key values should stay plain, got %q", v)
+	}
+
+	SetKey("master")
+	sealed, err := Seal("https://hook")
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if !IsSealed(sealed) || sealed == "https://hook" {
+		t.Fatalf("Value should be sealed, got %q", sealed)
+	}
+	if again, _ := Seal(sealed); again != sealed {
+		t.Error("Sealing a sealed value should be a no-op")
+	}
+	if other, _ := Seal("https://hook"); other == sealed {
+		t.Error("Every seal should use a fresh nonce")
+	}
+	if plain, err := Open(sealed); err != nil || plain != "https://hook" {
+		t.Errorf("Open = %q, %v", plain, err)
+	}
+	if plain, err := Open("plain"); err != nil || plain != "plain" {
+		t.Errorf("Plain values should open unchanged, got %q, %v", plain, err)
+	}
+
+	SetKey("other")
+	if _, err := Open(sealed); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("Open with the wrong key = %v, want ErrWrongKey", err)
+	}
+	SetKey("")
+	if _, err := Open(sealed); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Open without a key = %v, want ErrNoKey", err)
+	}
+}
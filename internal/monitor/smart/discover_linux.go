@@ -0,0 +1,103 @@
+//go:build linux
+
+package smart
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DiscoverDevices returns the whole-disk device nodes backing path.
+// Partitions resolve to their disk; md/dm devices resolve to their member disks.
+func DiscoverDevices(path string) ([]string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	majMin, err := mountDevice(f, path)
+	if err != nil {
+		return nil, err
+	}
+	names := diskNames("/sys", majMin)
+	devices := make([]string, 0, len(names))
+	for _, n := range names {
+		devices = append(devices, "/dev/"+n)
+	}
+	return devices, nil
+}
+
+// mountDevice finds the MAJ:MIN of the mount that holds path
+func mountDevice(mountinfo io.Reader, path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+
+	best, bestLen := "", -1
+	scanner := bufio.NewScanner(mountinfo)
+	for scanner.Scan() {
+		// id parent MAJ:MIN root mountpoint options ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		mnt := fields[4]
+		if abs != mnt && !strings.HasPrefix(abs, strings.TrimSuffix(mnt, "/")+"/") {
+			continue
+		}
+		// Later entries of equal length shadow earlier ones (stacked mounts)
+		if len(mnt) >= bestLen {
+			best, bestLen = fields[2], len(mnt)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if best == "" {
+		return "", fmt.Errorf("no mount found for %s", path)
+	}
+	return best, nil
+}
+
+// diskNames resolves a block device to the names of its physical disks
+func diskNames(sysRoot, majMin string) []string {
+	target, err := filepath.EvalSymlinks(filepath.Join(sysRoot, "dev", "block", majMin))
+	if err != nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var walk func(dir string)
+	walk = func(dir string) {
+		// A partition's sysfs directory lives inside its disk's directory
+		if _, err := os.Stat(filepath.Join(dir, "partition")); err == nil {
+			dir = filepath.Dir(dir)
+		}
+		slaves, _ := os.ReadDir(filepath.Join(dir, "slaves"))
+		if len(slaves) == 0 {
+			seen[filepath.Base(dir)] = true
+			return
+		}
+		for _, s := range slaves {
+			if next, err := filepath.EvalSymlinks(filepath.Join(dir, "slaves", s.Name())); err == nil {
+				walk(next)
+			}
+		}
+	}
+	walk(target)
+
+	names := make([]string, 0, len(seen))
+	for n := range seen {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
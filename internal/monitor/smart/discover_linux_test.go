@@ -0,0 +1,66 @@
+//go:build linux
+
+package smart
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMountDevice(t *testing.T) {
+	mountinfo := strings.Join([]string{
+		"22 1 8:1 / / rw,relatime - ext4 /dev/sda1 rw",
+		"30 22 9:0 / /data rw,relatime - ext4 /dev/md0 rw",
+		"31 30 8:33 / /data/fast rw,relatime - ext4 /dev/sdc1 rw",
+	}, "\n")
+	tests := map[string]string{
+		"/data/movies/a.mkv": "9:0",
+		"/data/fast/x":       "8:33",
+		"/database":          "8:1",
+		"/":                  "8:1",
+	}
+	for path, expected := range tests {
+		got, err := mountDevice(strings.NewReader(mountinfo), path)
+		if err != nil || got != expected {
+			t.Errorf("mountDevice(%q) = %q, %v; want %q", path, got, err, expected)
+		}
+	}
+}
+
+func TestDiskNames(t *testing.T) {
+	sys := t.TempDir()
+	mkdir := func(p string) {
+		if err := os.MkdirAll(filepath.Join(sys, p), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := func(target, name string) {
+		if err := os.Symlink(filepath.Join(sys, target), filepath.Join(sys, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Partitions sda1/sdb1 form md0
+	mkdir("devices/sda/sda1")
+	mkdir("devices/sdb/sdb1")
+	mkdir("devices/md0/slaves")
+	mkdir("dev/block")
+	for _, p := range []string{"devices/sda/sda1/partition", "devices/sdb/sdb1/partition"} {
+		if err := os.WriteFile(filepath.Join(sys, p), []byte("1"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link("devices/sda/sda1", "devices/md0/slaves/sda1")
+	link("devices/sdb/sdb1", "devices/md0/slaves/sdb1")
+	link("devices/md0", "dev/block/9:0")
+	link("devices/sda/sda1", "dev/block/8:1")
+
+	if got := diskNames(sys, "9:0"); !reflect.DeepEqual(got, []string{"sda", "sdb"}) {
+		t.Errorf("md0 should resolve to its member disks, got %v", got)
+	}
+	if got := diskNames(sys, "8:1"); !reflect.DeepEqual(got, []string{"sda"}) {
+		t.Errorf("Partition should resolve to its disk, got %v", got)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package smart
+
+import "fmt"
+
+// DiscoverDevices is only supported on Linux; set SMART_DEVICES elsewhere
+func DiscoverDevices(path string) ([]string, error) {
+	return nil, fmt.Errorf("disk discovery is not supported on this platform")
+}
@@ -0,0 +1,190 @@
+package smart
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+
+	"schnorarr/internal/monitor/logging"
+)
+
+var logger = logging.For("smart")
+
+// Disk status values
+const (
+	StatusOK      = "ok"
+	StatusWarning = "warning"
+	StatusFailing = "failing"
+	StatusUnknown = "unknown"
+)
+
+// DefaultTempWarn is the drive temperature (°C) that raises a warning
+const DefaultTempWarn = 55
+
+// Disk is the SMART summary of a single drive
+type Disk struct {
+	Device       string `json:"device"`
+	Model        string `json:"model,omitempty"`
+	Serial       string `json:"serial,omitempty"`
+	Passed       bool   `json:"passed"`
+	Temperature  int    `json:"temperature"`
+	PowerOnHours int    `json:"power_on_hours"`
+	Reallocated  int64  `json:"reallocated_sectors"`
+	Pending      int64  `json:"pending_sectors"`
+	MediaErrors  int64  `json:"media_errors"`
+	Status       string `json:"status"`
+	Error        string `json:"error,omitempty"`
+}
+
+// evaluate derives the overall status of the disk
+func (d *Disk) evaluate(tempWarn int) {
+	switch {
+	case d.Error != "":
+		d.Status = StatusUnknown
+	case !d.Passed:
+		d.Status = StatusFailing
+	case d.Reallocated > 0 || d.Pending > 0 || d.MediaErrors > 0 || (tempWarn > 0 && d.Temperature >= tempWarn):
+		d.Status = StatusWarning
+	default:
+		d.Status = StatusOK
+	}
+}
+
+// smartctlOutput is the subset of `smartctl -j` output we use
+type smartctlOutput struct {
+	ModelName    string `json:"model_name"`
+	SerialNumber string `json:"serial_number"`
+	SmartStatus  *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	Temperature struct {
+		Current int `json:"current"`
+	} `json:"temperature"`
+	PowerOnTime struct {
+		Hours int `json:"hours"`
+	} `json:"power_on_time"`
+	ATAAttributes struct {
+		Table []struct {
+			ID  int `json:"id"`
+			Raw struct {
+				Value int64 `json:"value"`
+			} `json:"raw"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+	NVMeLog *struct {
+		MediaErrors int64 `json:"media_errors"`
+	} `json:"nvme_smart_health_information_log"`
+	Smartctl struct {
+		Messages []struct {
+			String string `json:"string"`
+		} `json:"messages"`
+	} `json:"smartctl"`
+}
+
+// parse converts smartctl JSON output into a Disk
+func parse(device string, data []byte) (Disk, error) {
+	d := Disk{Device: device}
+	var out smartctlOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return d, fmt.Errorf("invalid smartctl output: %w", err)
+	}
+	d.Model = out.ModelName
+	d.Serial = out.SerialNumber
+	d.Temperature = out.Temperature.Current
+	d.PowerOnHours = out.PowerOnTime.Hours
+	for _, attr := range out.ATAAttributes.Table {
+		switch attr.ID {
+		case 5: // Reallocated_Sector_Ct
+			d.Reallocated = attr.Raw.Value
+		case 197: // Current_Pending_Sector
+			d.Pending = attr.Raw.Value
+		}
+	}
+	if out.NVMeLog != nil {
+		d.MediaErrors = out.NVMeLog.MediaErrors
+	}
+	if out.SmartStatus == nil {
+		msg := "SMART status unavailable"
+		if len(out.Smartctl.Messages) > 0 {
+			msg = out.Smartctl.Messages[0].String
+		}
+		return d, fmt.Errorf("%s", msg)
+	}
+	d.Passed = out.SmartStatus.Passed
+	return d, nil
+}
+
+// Query runs smartctl for a single device
+func Query(device string, tempWarn int) Disk {
+	// smartctl encodes findings in its exit status, so the output is parsed even on error
+	out, err := exec.Command("smartctl", "-j", "-i", "-H", "-A", device).Output()
+	if len(out) == 0 && err != nil {
+		d := Disk{Device: device, Error: err.Error()}
+		d.evaluate(tempWarn)
+		return d
+	}
+	d, perr := parse(device, out)
+	if perr != nil {
+		d.Error = perr.Error()
+	}
+	d.evaluate(tempWarn)
+	return d
+}
+
+// Available reports whether smartctl is installed
+func Available() bool {
+	_, err := exec.LookPath("smartctl")
+	return err == nil
+}
+
+// Monitor periodically collects SMART data for the disks backing a path
+type Monitor struct {
+	path     string
+	devices  []string
+	tempWarn int
+	mu       sync.RWMutex
+	disks    []Disk
+}
+
+// NewMonitor creates a monitor for path. When devices is empty the disks are
+// discovered from the mount holding path.
+func NewMonitor(path string, devices []string, tempWarn int) *Monitor {
+	return &Monitor{path: path, devices: devices, tempWarn: tempWarn}
+}
+
+// Refresh queries all disks once
+func (m *Monitor) Refresh() {
+	devices := m.devices
+	if len(devices) == 0 {
+		var err error
+		if devices, err = DiscoverDevices(m.path); err != nil {
+			logger.Error("Failed to discover disks", "path", m.path, "error", err)
+		}
+	}
+	disks := make([]Disk, 0, len(devices))
+	for _, dev := range devices {
+		disks = append(disks, Query(dev, m.tempWarn))
+	}
+	m.mu.Lock()
+	m.disks = disks
+	m.mu.Unlock()
+}
+
+// Start refreshes immediately and then every interval
+func (m *Monitor) Start(interval time.Duration) {
+	m.Refresh()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.Refresh()
+	}
+}
+
+// Disks returns the latest SMART summaries
+func (m *Monitor) Disks() []Disk {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]Disk(nil), m.disks...)
+}
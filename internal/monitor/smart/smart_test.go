@@ -0,0 +1,60 @@
+package smart
+
+import "testing"
+
+func TestParse_ATA(t *testing.T) {
+	data := []byte(`{
+		"model_name": "WDC WD80EFAX",
+		"serial_number": "ABC123",
+		"smart_status": {"passed": true},
+		"temperature": {"current": 38},
+		"power_on_time": {"hours": 12000},
+		"ata_smart_attributes": {"table": [
+			{"id": 5, "raw": {"value": 8}},
+			{"id": 197, "raw": {"value": 0}}
+		]}
+	}`)
+	d, err := parse("/dev/sda", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Model != "WDC WD80EFAX" || !d.Passed || d.Temperature != 38 || d.PowerOnHours != 12000 || d.Reallocated != 8 {
+		t.Errorf("Unexpected parse result: %+v", d)
+	}
+	d.evaluate(DefaultTempWarn)
+	if d.Status != StatusWarning {
+		t.Errorf("Reallocated sectors should raise a warning, got %s", d.Status)
+	}
+}
+
+func TestParse_NoStatus(t *testing.T) {
+	data := []byte(`{"smartctl": {"messages": [{"string": "Permission denied"}]}}`)
+	d, err := parse("/dev/sdb", data)
+	if err == nil || err.Error() != "Permission denied" {
+		t.Fatalf("Expected smartctl message as error, got %v", err)
+	}
+	d.Error = err.Error()
+	d.evaluate(DefaultTempWarn)
+	if d.Status != StatusUnknown {
+		t.Errorf("Expected unknown status, got %s", d.Status)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		disk     Disk
+		expected string
+	}{
+		{Disk{Passed: true, Temperature: 40}, StatusOK},
+		{Disk{Passed: true, Temperature: 60}, StatusWarning},
+		{Disk{Passed: true, Pending: 1}, StatusWarning},
+		{Disk{Passed: true, MediaErrors: 2}, StatusWarning},
+		{Disk{Passed: false, Temperature: 30}, StatusFailing},
+	}
+	for _, tt := range tests {
+		tt.disk.evaluate(DefaultTempWarn)
+		if tt.disk.Status != tt.expected {
+			t.Errorf("evaluate(%+v) = %s, want %s", tt.disk, tt.disk.Status, tt.expected)
+		}
+	}
+}
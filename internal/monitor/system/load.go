@@ -0,0 +1,68 @@
+package system
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Load describes how busy the host is, for senders pacing their transfers
+type Load struct {
+	Load1       float64 `json:"load1"`        // One minute load average
+	NumCPU      int     `json:"num_cpu"`      // Load per CPU is Load1 / NumCPU
+	IOPressure  float64 `json:"io_pressure"`  // Share of time tasks waited for IO over the last 10s (PSI "some avg10"), -1 when unavailable
+	CPUPressure float64 `json:"cpu_pressure"` // The same for CPU, -1 when unavailable
+}
+
+// CollectLoad reads the load average and pressure stall information from
+// /proc; nil where /proc/loadavg does not exist
+func CollectLoad() *Load {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return nil
+	}
+	load1, ok := parseLoadavg(string(data))
+	if !ok {
+		return nil
+	}
+	return &Load{Load1: load1, NumCPU: runtime.NumCPU(), IOPressure: readPressure("io"), CPUPressure: readPressure("cpu")}
+}
+
+// readPressure returns the "some avg10" of /proc/pressure/<resource>, -1
+// without PSI (kernels before 4.20 or disabled)
+func readPressure(resource string) float64 {
+	data, err := os.ReadFile("/proc/pressure/" + resource)
+	if err != nil {
+		return -1
+	}
+	return parsePressure(string(data))
+}
+
+// parseLoadavg reads the one minute average of /proc/loadavg
+func parseLoadavg(s string) (float64, bool) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(fields[0], 64)
+	return v, err == nil
+}
+
+// parsePressure reads avg10 of the "some" line of a PSI file, -1 when missing
+func parsePressure(s string) float64 {
+	for _, line := range strings.Split(s, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "some" {
+			continue
+		}
+		for _, f := range fields[1:] {
+			if v, ok := strings.CutPrefix(f, "avg10="); ok {
+				if pct, err := strconv.ParseFloat(v, 64); err == nil {
+					return pct
+				}
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,79 @@
+package system
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Metrics describes the resource usage of the running process
+type Metrics struct {
+	Goroutines int     `json:"goroutines"`
+	HeapAlloc  uint64  `json:"heap_alloc"`
+	HeapSys    uint64  `json:"heap_sys"`
+	Sys        uint64  `json:"sys"`
+	RSS        uint64  `json:"rss"`
+	OpenFDs    int     `json:"open_fds"` // -1 when unavailable
+	CPUPercent float64 `json:"cpu_percent"`
+	NumCPU     int     `json:"num_cpu"`
+	NumGC      uint32  `json:"num_gc"`
+}
+
+// cpuSampleInterval is how often the CPU usage is sampled
+const cpuSampleInterval = 3 * time.Second
+
+var (
+	cpuOnce     sync.Once
+	cpuMu       sync.Mutex
+	lastCPUTime time.Duration
+	lastSample  time.Time
+	lastPercent float64
+)
+
+// cpuPercent returns the process CPU usage over the last sample interval, as
+// a percentage of one core (may exceed 100 on multi-core systems). One
+// goroutine samples, so any number of callers see the same value.
+func cpuPercent() float64 {
+	cpuOnce.Do(func() {
+		sampleCPU()
+		go func() {
+			for range time.Tick(cpuSampleInterval) {
+				sampleCPU()
+			}
+		}()
+	})
+	cpuMu.Lock()
+	defer cpuMu.Unlock()
+	return lastPercent
+}
+
+// sampleCPU updates the usage from the CPU time used since the previous sample
+func sampleCPU() {
+	cpuMu.Lock()
+	defer cpuMu.Unlock()
+	now := time.Now()
+	used := processCPUTime()
+	if !lastSample.IsZero() {
+		if wall := now.Sub(lastSample); wall > 0 {
+			lastPercent = float64(used-lastCPUTime) * 100 / float64(wall)
+		}
+	}
+	lastCPUTime, lastSample = used, now
+}
+
+// Collect samples the current process metrics
+func Collect() Metrics {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return Metrics{
+		Goroutines: runtime.NumGoroutine(),
+		HeapAlloc:  ms.HeapAlloc,
+		HeapSys:    ms.HeapSys,
+		Sys:        ms.Sys,
+		RSS:        residentSetSize(),
+		OpenFDs:    openFDs(),
+		CPUPercent: cpuPercent(),
+		NumCPU:     runtime.NumCPU(),
+		NumGC:      ms.NumGC,
+	}
+}
@@ -0,0 +1,48 @@
+package system
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestCollect(t *testing.T) {
+	_ = Collect()
+	// Burn a little CPU so the next sample has something to measure
+	deadline := time.Now().Add(20 * time.Millisecond)
+	for time.Now().Before(deadline) {
+	}
+	sampleCPU()
+	m := Collect()
+
+	if m.Goroutines < 1 || m.HeapAlloc == 0 || m.NumCPU != runtime.NumCPU() {
+		t.Errorf("Unexpected runtime metrics: %+v", m)
+	}
+	if m.CPUPercent <= 0 {
+		t.Errorf("CPU percent should cover the busy loop, got %f", m.CPUPercent)
+	}
+	if runtime.GOOS == "linux" {
+		if m.RSS == 0 {
+			t.Error("Expected RSS on Linux")
+		}
+		if m.OpenFDs < 3 {
+			t.Errorf("Expected at least stdio descriptors, got %d", m.OpenFDs)
+		}
+	}
+}
+
+func TestParseLoad(t *testing.T) {
+	if v, ok := parseLoadavg("1.52 0.98 0.75 2/345 12345\n"); !ok || v != 1.52 {
+		t.Errorf("Unexpected load average %v %v", v, ok)
+	}
+	if _, ok := parseLoadavg(""); ok {
+		t.Error("An empty loadavg should not parse")
+	}
+	psi := "some avg10=37.50 avg60=12.00 avg300=3.10 total=123456\nfull avg10=20.00 avg60=8.00 avg300=2.00 total=6543\n"
+	if v := parsePressure(psi); v != 37.5 {
+		t.Errorf("Expected the some avg10 of 37.5, got %v", v)
+	}
+	if v := parsePressure("full avg10=1.00\n"); v != -1 {
+		t.Errorf("Without a some line pressure is unavailable, got %v", v)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build windows
+
+package system
+
+import (
+	"syscall"
+	"time"
+)
+
+func processCPUTime() time.Duration {
+	h, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return 0
+	}
+	var creation, exit, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(h, &creation, &exit, &kernel, &user); err != nil {
+		return 0
+	}
+	// Filetime counts 100ns intervals
+	ticks := func(ft syscall.Filetime) int64 { return int64(ft.HighDateTime)<<32 | int64(ft.LowDateTime) }
+	return time.Duration((ticks(kernel) + ticks(user)) * 100)
+}
+
+func residentSetSize() uint64 { return 0 }
+
+func openFDs() int { return -1 }
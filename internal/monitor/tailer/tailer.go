@@ -3,13 +3,16 @@ package tailer
 import (
 	"bufio"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"schnorarr/internal/monitor/logging"
 )
 
+var logger = logging.For("tailer")
+
 const RsyncLog = "/tmp/rsync.log"
 
 // EventCallback is called when a sync event is parsed from logs
@@ -34,7 +37,7 @@ func New(onEvent EventCallback, onError ErrorCallback) *Tailer {
 
 // Start begins tailing the rsync log file
 func (t *Tailer) Start() {
-	log.Println("Starting rsync log tailer...")
+	logger.Info("Starting rsync log tailer")
 
 	// Wait for log file to exist
 	for {
@@ -47,14 +50,14 @@ func (t *Tailer) Start() {
 
 	file, err := os.Open(RsyncLog)
 	if err != nil {
-		log.Printf("Failed to open Log: %v", err)
+		logger.Error("Failed to open log", "error", err)
 		return
 	}
 	defer func() { _ = file.Close() }()
 
 	// Move to end
 	if _, err := file.Seek(0, 2); err != nil {
-		log.Printf("Seek Error: %v", err)
+		logger.Error("Seek failed", "error", err)
 	}
 	reader := bufio.NewReader(file)
 
@@ -65,7 +68,7 @@ func (t *Tailer) Start() {
 				time.Sleep(1 * time.Second)
 				continue
 			}
-			log.Printf("Read Error: %v", err)
+			logger.Error("Read failed", "error", err)
 			continue
 		}
 
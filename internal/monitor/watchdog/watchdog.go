@@ -1,11 +1,14 @@
 package watchdog
 
 import (
-	"log"
 	"sync"
 	"time"
+
+	"schnorarr/internal/monitor/logging"
 )
 
+var logger = logging.For("watchdog")
+
 // NotifyFunc is a callback for sending notifications
 type NotifyFunc func(msg, msgType string)
 
@@ -42,7 +45,7 @@ func (w *Watchdog) Update(queued int, speed int64) {
 
 // Start begins the watchdog monitoring loop
 func (w *Watchdog) Start() {
-	log.Println("Starting Deep Health Watchdog...")
+	logger.Info("Starting deep health watchdog")
 	ticker := time.NewTicker(2 * time.Minute)
 
 	for range ticker.C {
@@ -52,7 +55,7 @@ func (w *Watchdog) Start() {
 
 		// Threshold: 15 minutes without progress WHILE items are queued
 		if time.Since(lastProgress) > 15*time.Minute {
-			log.Println("[WATCHDOG] Sync appears stuck (Queue > 0, Speed = 0 for >15m). Restarting...")
+			logger.Warn("Sync appears stuck (queue > 0, speed = 0 for >15m), restarting")
 
 			if w.notifyFn != nil {
 				w.notifyFn("⚠️ Watchdog detected stuck sync. Restarting engine...", "ERROR")
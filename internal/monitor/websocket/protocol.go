@@ -0,0 +1,128 @@
+package websocket
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// ProtocolVersion is the version of the message schema. Bump it when a
+// payload changes incompatibly; clients older than MinProtocolVersion are
+// asked to reload.
+const (
+	ProtocolVersion    = 2
+	MinProtocolVersion = 2
+)
+
+// Hello is the payload of the init message, the first message every client
+// receives
+type Hello struct {
+	Protocol    int `json:"protocol"`
+	MinProtocol int `json:"min_protocol"`
+}
+
+// UpgradeRequired is sent before the connection is closed when the client
+// speaks a protocol version the server does not support
+type UpgradeRequired struct {
+	Protocol    int    `json:"protocol"`
+	MinProtocol int    `json:"min_protocol"`
+	Client      int    `json:"client"`
+	Reason      string `json:"reason"`
+}
+
+// LogEntry is a log line for the dashboard log view
+type LogEntry struct {
+	Msg   string `json:"msg"`
+	Level string `json:"level"`
+	Cycle string `json:"cycle,omitempty"`
+}
+
+// Subscribe is sent by clients to set how often they want status updates
+type Subscribe struct {
+	IntervalMs int `json:"interval_ms"` // 0 for the server default
+}
+
+// Topic is a broadcast message type with its payload type T
+type Topic[T any] struct {
+	Name string
+}
+
+var (
+	topicsMu     sync.Mutex
+	topics       = make(map[string]reflect.Type)
+	clientTopics = make(map[string]reflect.Type)
+)
+
+// NewTopic declares a topic and records its payload type for the schema
+func NewTopic[T any](name string) Topic[T] {
+	topicsMu.Lock()
+	defer topicsMu.Unlock()
+	if _, ok := topics[name]; ok {
+		panic("websocket: topic " + name + " declared twice")
+	}
+	topics[name] = reflect.TypeOf((*T)(nil)).Elem()
+	return Topic[T]{Name: name}
+}
+
+// NewClientTopic declares a message type clients send
+func NewClientTopic[T any](name string) Topic[T] {
+	topicsMu.Lock()
+	defer topicsMu.Unlock()
+	clientTopics[name] = reflect.TypeOf((*T)(nil)).Elem()
+	return Topic[T]{Name: name}
+}
+
+// Broadcast sends data to all clients
+func (t Topic[T]) Broadcast(h *Hub, data T) {
+	h.Broadcast(t.Name, data)
+}
+
+// Retain makes data the snapshot new clients receive on connect
+func (t Topic[T]) Retain(h *Hub, data T) {
+	h.Retain(t.Name, data)
+}
+
+// Send sends data to one client
+func (t Topic[T]) Send(c *Client, data T) {
+	c.SendDirect(t.Name, data)
+}
+
+var (
+	TopicInit            = NewTopic[Hello]("init")
+	TopicUpgradeRequired = NewTopic[UpgradeRequired]("upgrade_required")
+	TopicLog             = NewTopic[LogEntry]("log")
+	TopicSubscribe       = NewClientTopic[Subscribe]("subscribe")
+)
+
+// Topics returns the declared topics and their payload types, by name
+func Topics() []TopicSchema {
+	return sortedTopics(topics)
+}
+
+// ClientTopics returns the message types clients send, by name
+func ClientTopics() []TopicSchema {
+	return sortedTopics(clientTopics)
+}
+
+func sortedTopics(m map[string]reflect.Type) []TopicSchema {
+	topicsMu.Lock()
+	defer topicsMu.Unlock()
+	out := make([]TopicSchema, 0, len(m))
+	for name, t := range m {
+		out = append(out, TopicSchema{Name: name, Payload: t})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// TopicSchema names a topic and its payload type
+type TopicSchema struct {
+	Name    string
+	Payload reflect.Type
+}
+
+// CheckProtocol reports whether a client speaking version can be served.
+// Clients that do not send a version predate versioning and are served.
+func CheckProtocol(version int) bool {
+	return version == 0 || (version >= MinProtocolVersion && version <= ProtocolVersion)
+}
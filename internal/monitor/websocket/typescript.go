@@ -0,0 +1,152 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// WriteTypeScript writes TypeScript definitions of every topic's payload and
+// the Message (server to client) and ClientMessage unions discriminated by
+// type, following the encoding/json rules
+func WriteTypeScript(w io.Writer) error {
+	g := &tsGen{names: make(map[reflect.Type]string), taken: make(map[string]reflect.Type)}
+	var union, clientUnion []string
+	for _, t := range Topics() {
+		union = append(union, fmt.Sprintf("  | { type: %q; data: %s }", t.Name, g.ref(t.Payload)))
+	}
+	for _, t := range ClientTopics() {
+		clientUnion = append(clientUnion, fmt.Sprintf("  | { type: %q; data: %s }", t.Name, g.ref(t.Payload)))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("// Code generated by cmd/wsschema; DO NOT EDIT.\n")
+	sb.WriteString("// Messages the dashboard WebSocket (/ws) sends.\n\n")
+	fmt.Fprintf(&sb, "export const PROTOCOL_VERSION = %d;\n", ProtocolVersion)
+	fmt.Fprintf(&sb, "export const MIN_PROTOCOL_VERSION = %d;\n", MinProtocolVersion)
+	for _, decl := range g.decls {
+		sb.WriteString("\n" + decl)
+	}
+	sb.WriteString("\nexport type Message =\n" + strings.Join(union, "\n") + ";\n")
+	sb.WriteString("\n// Messages clients send\nexport type ClientMessage =\n" + strings.Join(clientUnion, "\n") + ";\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+type tsGen struct {
+	names map[reflect.Type]string
+	taken map[string]reflect.Type
+	decls []string
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// ref returns the TypeScript type of t, declaring named structs on first use
+func (g *tsGen) ref(t reflect.Type) string {
+	if t == timeType {
+		return "string"
+	}
+	if t == rawType {
+		return "unknown"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Pointer:
+		return g.ref(t.Elem()) + " | null"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string"
+		}
+		return g.array(t.Elem()) + " | null"
+	case reflect.Array:
+		return g.array(t.Elem())
+	case reflect.Map:
+		return "Record<string, " + g.ref(t.Elem()) + ">"
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t, "")
+		}
+		return g.declare(t)
+	}
+	return "unknown"
+}
+
+func (g *tsGen) array(elem reflect.Type) string {
+	s := g.ref(elem)
+	if strings.Contains(s, " ") {
+		s = "(" + s + ")"
+	}
+	return s + "[]"
+}
+
+// declare emits an interface for a named struct and returns its name. Names
+// used by two packages are qualified with the package name.
+func (g *tsGen) declare(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	if other, ok := g.taken[name]; ok && other != t {
+		pkg := path.Base(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	g.names[t], g.taken[name] = name, t
+	body := g.object(t, "")
+	g.decls = append(g.decls, "export interface "+name+" "+body+"\n")
+	return name
+}
+
+// object renders the JSON fields of a struct
+func (g *tsGen) object(t reflect.Type, indent string) string {
+	var sb strings.Builder
+	sb.WriteString("{\n")
+	g.fields(&sb, t)
+	sb.WriteString(indent + "}")
+	return sb.String()
+}
+
+func (g *tsGen) fields(sb *strings.Builder, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(sb, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		optional := ""
+		if strings.Contains(opts, "omitempty") || strings.Contains(opts, "omitzero") {
+			optional = "?"
+		}
+		fmt.Fprintf(sb, "  %s%s: %s;\n", name, optional, g.ref(f.Type))
+	}
+}
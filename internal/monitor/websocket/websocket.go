@@ -1,6 +1,9 @@
 package websocket
 
 import (
+	"encoding/json"
+	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -18,18 +21,27 @@ type Message struct {
 
 // Client represents a connected WebSocket client
 type Client struct {
-	hub  *Hub
-	conn *websocket.Conn
-	send chan interface{}
+	hub      *Hub
+	conn     *websocket.Conn
+	send     chan interface{}
+	interval time.Duration // Refresh interval the client asked for, 0 for the default
 }
 
+// Bounds of the refresh interval clients may ask for
+const (
+	MinInterval = time.Second
+	MaxInterval = time.Minute
+)
+
 // Hub manages WebSocket clients
 type Hub struct {
-	clients   map[*Client]bool
-	broadcast chan Message
-	reg       chan *Client
-	unreg     chan *Client
-	clientsMu sync.Mutex
+	clients    map[*Client]bool
+	broadcast  chan Message
+	reg        chan *Client
+	unreg      chan *Client
+	clientsMu  sync.Mutex
+	retained   []Message // Sent to every new client, in order
+	retainedMu sync.Mutex
 }
 
 // New creates a new WebSocket hub
@@ -40,6 +52,7 @@ func New() *Hub {
 		reg:       make(chan *Client),
 		unreg:     make(chan *Client),
 	}
+	h.Retain(TopicInit.Name, Hello{Protocol: ProtocolVersion, MinProtocol: MinProtocolVersion})
 	go h.run()
 	return h
 }
@@ -51,6 +64,12 @@ func (h *Hub) run() {
 			h.clientsMu.Lock()
 			h.clients[client] = true
 			h.clientsMu.Unlock()
+			// Handled here so no broadcast can overtake the snapshots
+			h.retainedMu.Lock()
+			for _, msg := range h.retained {
+				client.send <- msg
+			}
+			h.retainedMu.Unlock()
 		case client := <-h.unreg:
 			h.clientsMu.Lock()
 			if _, ok := h.clients[client]; ok {
@@ -85,6 +104,41 @@ func (h *Hub) UnregisterClient(client *Client) {
 	h.unreg <- client
 }
 
+// ClientCount returns the number of connected clients
+func (h *Hub) ClientCount() int {
+	h.clientsMu.Lock()
+	defer h.clientsMu.Unlock()
+	return len(h.clients)
+}
+
+// Interval returns the shortest refresh interval the clients asked for, or
+// def when none asked
+func (h *Hub) Interval(def time.Duration) time.Duration {
+	h.clientsMu.Lock()
+	defer h.clientsMu.Unlock()
+	var shortest time.Duration
+	for c := range h.clients {
+		if c.interval > 0 && (shortest == 0 || c.interval < shortest) {
+			shortest = c.interval
+		}
+	}
+	if shortest == 0 {
+		return def
+	}
+	return shortest
+}
+
+// SetInterval sets the refresh interval of the client, clamped to
+// MinInterval..MaxInterval; 0 restores the default
+func (c *Client) SetInterval(d time.Duration) {
+	if d != 0 {
+		d = min(max(d, MinInterval), MaxInterval)
+	}
+	c.hub.clientsMu.Lock()
+	c.interval = d
+	c.hub.clientsMu.Unlock()
+}
+
 func (c *Client) writePump() {
 	ticker := time.NewTicker(30 * time.Second)
 	defer func() {
@@ -122,6 +176,20 @@ func (h *Hub) Broadcast(msgType string, data interface{}) {
 	}
 }
 
+// Retain keeps data as the latest message of its type, which every client
+// receives when it connects. It does not broadcast.
+func (h *Hub) Retain(msgType string, data interface{}) {
+	h.retainedMu.Lock()
+	defer h.retainedMu.Unlock()
+	for i, msg := range h.retained {
+		if msg.Type == msgType {
+			h.retained[i].Data = data
+			return
+		}
+	}
+	h.retained = append(h.retained, Message{Type: msgType, Data: data})
+}
+
 // SendDirect sends a message to a specific client
 func (c *Client) SendDirect(msgType string, data interface{}) {
 	select {
@@ -142,18 +210,61 @@ func NewLogWriter(hub *Hub) *LogWriter {
 }
 
 func (w *LogWriter) Write(p []byte) (n int, err error) {
-	msg := string(p)
-	level := "info"
-	upperMsg := strings.ToUpper(msg)
-	if strings.Contains(upperMsg, "ERROR") || strings.Contains(upperMsg, "FAILED") {
-		level = "error"
-	} else if strings.Contains(upperMsg, "WARN") || strings.Contains(upperMsg, "WARNING") {
-		level = "warn"
-	}
-
-	w.hub.Broadcast("log", map[string]string{
-		"msg":   msg,
-		"level": level,
-	})
+	msg, level, cycle, ok := formatJSONRecord(p)
+	if !ok {
+		msg = string(p)
+		level = "info"
+		upperMsg := strings.ToUpper(msg)
+		if strings.Contains(upperMsg, "ERROR") || strings.Contains(upperMsg, "FAILED") {
+			level = "error"
+		} else if strings.Contains(upperMsg, "WARN") || strings.Contains(upperMsg, "WARNING") {
+			level = "warn"
+		}
+	}
+
+	TopicLog.Broadcast(w.hub, LogEntry{Msg: msg, Level: level, Cycle: cycle})
 	return len(p), nil
 }
+
+// formatJSONRecord renders a slog JSON record as "[Module] [Engine:id] msg key=value"
+// for the dashboard log view and returns its level and sync cycle
+func formatJSONRecord(p []byte) (msg, level, cycle string, ok bool) {
+	var rec map[string]interface{}
+	if err := json.Unmarshal(p, &rec); err != nil {
+		return "", "", "", false
+	}
+	text, isString := rec["msg"].(string)
+	if !isString {
+		return "", "", "", false
+	}
+	cycle, _ = rec["cycle"].(string)
+
+	var sb strings.Builder
+	if module, _ := rec["module"].(string); module != "" {
+		sb.WriteString("[" + strings.ToUpper(module[:1]) + module[1:] + "] ")
+	}
+	if engine, ok := rec["engine"]; ok {
+		sb.WriteString(fmt.Sprintf("[Engine:%v] ", engine))
+	}
+	sb.WriteString(text)
+
+	keys := make([]string, 0, len(rec))
+	for k := range rec {
+		switch k {
+		case "time", "level", "msg", "module", "engine":
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sb.WriteString(fmt.Sprintf(" %s=%v", k, rec[k]))
+	}
+
+	level, _ = rec["level"].(string)
+	level = strings.ToLower(level)
+	if level == "" {
+		level = "info"
+	}
+	return sb.String(), level, cycle, true
+}
@@ -0,0 +1,194 @@
+// Package mqtt is a minimal MQTT 3.1.1 client that publishes QoS 0 messages,
+// enough to feed engine state to home automation brokers.
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Packet types (upper nibble of the fixed header)
+const (
+	packetConnect    = 0x10
+	packetConnack    = 0x20
+	packetPublish    = 0x30
+	packetPingreq    = 0xC0
+	packetDisconnect = 0xE0
+)
+
+// Options configure a connection
+type Options struct {
+	Broker    string // host:port, tcp:// or mqtt:// prefixes are accepted; port 1883 when omitted
+	ClientID  string
+	Username  string
+	Password  string
+	KeepAlive time.Duration // Default 60s
+	// The will is published by the broker when the connection drops
+	WillTopic   string
+	WillPayload []byte
+	WillRetain  bool
+}
+
+// Client is a connection to a broker. It is safe for concurrent use.
+type Client struct {
+	mu   sync.Mutex
+	conn net.Conn
+	w    *bufio.Writer
+}
+
+// Connect dials the broker and completes the MQTT handshake
+func Connect(opts Options) (*Client, error) {
+	addr := strings.TrimPrefix(strings.TrimPrefix(opts.Broker, "tcp://"), "mqtt://")
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "1883")
+	}
+	keepAlive := opts.KeepAlive
+	if keepAlive <= 0 {
+		keepAlive = 60 * time.Second
+	}
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("mqtt dial failed: %w", err)
+	}
+	c := &Client{conn: conn, w: bufio.NewWriter(conn)}
+	if err := c.handshake(opts, keepAlive); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) handshake(opts Options, keepAlive time.Duration) error {
+	flags := byte(0x02) // Clean session
+	var payload []byte
+	payload = appendString(payload, opts.ClientID)
+	if opts.WillTopic != "" {
+		flags |= 0x04
+		if opts.WillRetain {
+			flags |= 0x20
+		}
+		payload = appendString(payload, opts.WillTopic)
+		payload = appendBytes(payload, opts.WillPayload)
+	}
+	if opts.Username != "" {
+		flags |= 0x80
+		payload = appendString(payload, opts.Username)
+		if opts.Password != "" {
+			flags |= 0x40
+			payload = appendString(payload, opts.Password)
+		}
+	}
+	header := appendString(nil, "MQTT")
+	header = append(header, 4, flags) // Protocol level 4 = 3.1.1
+	header = binary.BigEndian.AppendUint16(header, uint16(keepAlive/time.Second))
+
+	_ = c.conn.SetDeadline(time.Now().Add(10 * time.Second))
+	defer func() { _ = c.conn.SetDeadline(time.Time{}) }()
+	if err := c.send(packetConnect, append(header, payload...)); err != nil {
+		return err
+	}
+	typ, body, err := readPacket(c.conn)
+	if err != nil {
+		return fmt.Errorf("mqtt connack failed: %w", err)
+	}
+	if typ&0xF0 != packetConnack || len(body) != 2 {
+		return fmt.Errorf("mqtt: unexpected packet 0x%02x instead of CONNACK", typ)
+	}
+	if rc := body[1]; rc != 0 {
+		return fmt.Errorf("mqtt: connection refused (code %d)", rc)
+	}
+	// Nothing else is read; discard what the broker sends (PINGRESP)
+	go func() { _, _ = io.Copy(io.Discard, c.conn) }()
+	return nil
+}
+
+// Publish sends a QoS 0 message
+func (c *Client) Publish(topic string, payload []byte, retain bool) error {
+	header := byte(packetPublish)
+	if retain {
+		header |= 0x01
+	}
+	return c.send(header, append(appendString(nil, topic), payload...))
+}
+
+// Ping keeps an idle connection alive
+func (c *Client) Ping() error {
+	return c.send(packetPingreq, nil)
+}
+
+// Close disconnects cleanly, so the broker does not publish the will
+func (c *Client) Close() error {
+	_ = c.send(packetDisconnect, nil)
+	return c.conn.Close()
+}
+
+func (c *Client) send(header byte, body []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	packet := append([]byte{header}, encodeLength(len(body))...)
+	if _, err := c.w.Write(append(packet, body...)); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+// encodeLength encodes the remaining length of a packet, 7 bits per byte
+func encodeLength(n int) []byte {
+	var out []byte
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if n == 0 {
+			return out
+		}
+	}
+}
+
+func appendString(b []byte, s string) []byte {
+	return appendBytes(b, []byte(s))
+}
+
+func appendBytes(b, data []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...)
+}
+
+// readPacket reads one packet and returns its first header byte and body
+func readPacket(r io.Reader) (byte, []byte, error) {
+	var hdr [1]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	length, mult := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errors.New("mqtt: malformed remaining length")
+		}
+		var b [1]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, nil, err
+		}
+		length += int(b[0]&0x7F) * mult
+		if b[0]&0x80 == 0 {
+			break
+		}
+		mult *= 128
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return hdr[0], body, nil
+}
@@ -0,0 +1,106 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestEncodeLength(t *testing.T) {
+	for n, want := range map[int][]byte{0: {0}, 127: {0x7F}, 128: {0x80, 0x01}, 16383: {0xFF, 0x7F}, 2097152: {0x80, 0x80, 0x80, 0x01}} {
+		if got := encodeLength(n); !bytes.Equal(got, want) {
+			t.Errorf("encodeLength(%d) = %x, want %x", n, got, want)
+		}
+	}
+}
+
+func TestConnectAndPublish(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+
+	type packet struct {
+		typ  byte
+		body []byte
+	}
+	packets := make(chan packet, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		for {
+			typ, body, err := readPacket(conn)
+			if err != nil {
+				close(packets)
+				return
+			}
+			if typ&0xF0 == packetConnect {
+				_, _ = conn.Write([]byte{packetConnack, 2, 0, 0})
+			}
+			packets <- packet{typ, body}
+		}
+	}()
+
+	c, err := Connect(Options{Broker: "tcp://" + ln.Addr().String(), ClientID: "test", Username: "u", Password: "p",
+		WillTopic: "s/status", WillPayload: []byte("offline"), WillRetain: true, KeepAlive: 30 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Publish("s/engine/1/state", []byte(`{"state":"idle"}`), true); err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Close()
+
+	connect := <-packets
+	// Protocol name, level, flags, keep alive
+	if !bytes.Equal(connect.body[:7], []byte{0, 4, 'M', 'Q', 'T', 'T', 4}) {
+		t.Fatalf("Unexpected CONNECT header %x", connect.body[:7])
+	}
+	if flags := connect.body[7]; flags != 0x02|0x04|0x20|0x80|0x40 {
+		t.Errorf("Unexpected connect flags %08b", flags)
+	}
+	if ka := binary.BigEndian.Uint16(connect.body[8:10]); ka != 30 {
+		t.Errorf("Expected keep alive 30, got %d", ka)
+	}
+
+	publish := <-packets
+	if publish.typ != packetPublish|0x01 {
+		t.Errorf("Expected a retained PUBLISH, got 0x%02x", publish.typ)
+	}
+	topicLen := int(binary.BigEndian.Uint16(publish.body))
+	if topic := string(publish.body[2 : 2+topicLen]); topic != "s/engine/1/state" {
+		t.Errorf("Unexpected topic %q", topic)
+	}
+	if payload := string(publish.body[2+topicLen:]); payload != `{"state":"idle"}` {
+		t.Errorf("Unexpected payload %q", payload)
+	}
+	if disconnect := <-packets; disconnect.typ != packetDisconnect {
+		t.Errorf("Expected DISCONNECT, got 0x%02x", disconnect.typ)
+	}
+}
+
+func TestConnectRefused(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_, _, _ = readPacket(conn)
+		_, _ = conn.Write([]byte{packetConnack, 2, 0, 5}) // Not authorized
+	}()
+	if _, err := Connect(Options{Broker: ln.Addr().String(), ClientID: "test"}); err == nil {
+		t.Error("Expected a refused connection")
+	}
+}
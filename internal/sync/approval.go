@@ -0,0 +1,105 @@
+package sync
+
+import (
+	"sort"
+	"strings"
+)
+
+// PendingDir summarizes the pending changes under one directory
+type PendingDir struct {
+	Dir   string `json:"dir"` // "" for paths at the top level
+	Count int    `json:"count"`
+	Bytes int64  `json:"bytes"` // Sizes known from the plan that held them
+}
+
+// planSizes maps every path of plan to its size: the source size of
+// transfers and conflicts, the target size of deletions
+func planSizes(plan *SyncPlan, target *Manifest) map[string]int64 {
+	sizes := make(map[string]int64)
+	for _, f := range plan.FilesToSync {
+		sizes[f.Path] = f.Size
+	}
+	for _, c := range plan.Conflicts {
+		sizes[c.Path] = c.SourceSize
+	}
+	for _, p := range plan.FilesToDelete {
+		if f, ok := target.GetFile(p); ok {
+			sizes[p] = f.Size
+		}
+	}
+	for oldP := range plan.Renames {
+		if f, ok := target.GetFile(oldP); ok {
+			sizes[oldP] = f.Size
+		}
+	}
+	return sizes
+}
+
+// PendingSummary groups the pending changes by their directory at depth
+// (1 = top-level folders such as "Show X"), largest first
+func (e *Engine) PendingSummary(depth int) []PendingDir {
+	if depth < 1 {
+		depth = 1
+	}
+	e.pausedMu.RLock()
+	defer e.pausedMu.RUnlock()
+
+	byDir := make(map[string]*PendingDir)
+	for _, p := range e.pendingDeletions {
+		dir := pendingDir(p, depth)
+		d, ok := byDir[dir]
+		if !ok {
+			d = &PendingDir{Dir: dir}
+			byDir[dir] = d
+		}
+		d.Count++
+		d.Bytes += e.pendingSizes[p]
+	}
+	dirs := make([]PendingDir, 0, len(byDir))
+	for _, d := range byDir {
+		dirs = append(dirs, *d)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if dirs[i].Bytes != dirs[j].Bytes {
+			return dirs[i].Bytes > dirs[j].Bytes
+		}
+		return dirs[i].Dir < dirs[j].Dir
+	})
+	return dirs
+}
+
+// pendingDir returns the directory of path cut to depth components
+func pendingDir(path string, depth int) string {
+	parts := strings.Split(path, "/")
+	if len(parts) <= 1 {
+		return ""
+	}
+	parts = parts[:len(parts)-1]
+	if len(parts) > depth {
+		parts = parts[:depth]
+	}
+	return strings.Join(parts, "/")
+}
+
+// ResolvePendingDirs returns the pending paths inside dirs (or equal to one
+// of them), so a whole folder can be approved at once
+func (e *Engine) ResolvePendingDirs(dirs []string) []string {
+	var prefixes []string
+	for _, d := range dirs {
+		if d = strings.Trim(d, "/"); d != "" {
+			prefixes = append(prefixes, d)
+		}
+	}
+	e.pausedMu.RLock()
+	defer e.pausedMu.RUnlock()
+	var paths []string
+	for _, p := range e.pendingDeletions {
+		for _, d := range prefixes {
+			if p == d || strings.HasPrefix(p, d+"/") {
+				paths = append(paths, p)
+				break
+			}
+		}
+	}
+	return paths
+}
@@ -0,0 +1,61 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEngine_DirectoryApproval(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	write := func(root, rel, content string) {
+		full := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Folders only missing on the source are protected, so each keeps a file
+	for _, keep := range []string{"Show A/S01/keep.mkv", "Show B/keep.mkv"} {
+		write(sourceDir, keep, "keep")
+		write(targetDir, keep, "keep")
+	}
+	write(targetDir, "Show A/S01/e1.mkv", "12345")
+	write(targetDir, "Show A/S01/e2.mkv", "123")
+	write(targetDir, "Show B/e1.mkv", "1")
+
+	engine := NewEngine(SyncConfig{ID: "test-dir-approval", SourceDir: sourceDir, TargetDir: targetDir, Rule: "flat"})
+	if err := engine.RunSync(nil); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+
+	summary := engine.PendingSummary(1)
+	if len(summary) != 2 || summary[0].Dir != "Show A" || summary[0].Count != 2 || summary[0].Bytes != 8 {
+		t.Fatalf("Expected Show A (2 files, 8 bytes) first, got %+v", summary)
+	}
+	if got := engine.PendingSummary(2); got[0].Dir != "Show A/S01" {
+		t.Errorf("Depth 2 should group by season, got %+v", got)
+	}
+
+	approved := engine.ResolvePendingDirs([]string{"Show A/"})
+	if len(approved) != 2 {
+		t.Fatalf("Expected both Show A files, got %v", approved)
+	}
+	engine.pausedMu.Lock()
+	engine.deletionAllowed = true
+	engine.waitingForApproval = false
+	engine.pendingDeletions = approved
+	engine.pausedMu.Unlock()
+	if err := engine.RunSync(nil); err != nil {
+		t.Fatalf("RunSync failed after approval: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "Show A/S01/e1.mkv")); !os.IsNotExist(err) {
+		t.Error("Approved directory should have been deleted")
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "Show B/e1.mkv")); err != nil {
+		t.Error("Show B was not approved and must stay")
+	}
+}
@@ -0,0 +1,59 @@
+package sync
+
+import (
+	"errors"
+
+	"schnorarr/internal/agent"
+)
+
+// batchSize is how many changes go to a remote receiver in one call
+const batchSize = 500
+
+// runBatched applies ops on a remote target in transactional batches of
+// batchSize and reports which of them are done. Batching stops at the first
+// batch that is rolled back, on pause, and for receivers that cannot batch;
+// the caller applies the rest one by one, which also reports their errors.
+func (e *Engine) runBatched(ops []agent.Op) []bool {
+	done := make([]bool, len(ops))
+	if len(ops) < 2 || !IsRemotePath(e.config.TargetDir) {
+		return done
+	}
+	for start := 0; start < len(ops); start += batchSize {
+		if e.IsPaused() {
+			break
+		}
+		chunk := ops[start:min(start+batchSize, len(ops))]
+		paths := make([]string, 0, len(chunk))
+		for _, op := range chunk {
+			paths = append(paths, op.Path)
+			if op.To != "" {
+				paths = append(paths, op.To)
+			}
+		}
+		e.phaseStep(chunk[0].Path, 0)
+		release := e.lockTarget(paths...)
+		resp, err := e.transferer.Batch(e.config.TargetDir, chunk)
+		release()
+		if err != nil {
+			if !errors.Is(err, agent.ErrUnsupported) {
+				e.logger().Warn("Batch failed, applying changes one by one", "ops", len(chunk), "error", err)
+			}
+			return done
+		}
+		if !resp.Committed {
+			for i, r := range resp.Results {
+				if r.Error != "" {
+					e.logger().Info("Batch rolled back, applying changes one by one", "op", chunk[i].Op, "path", chunk[i].Path, "error", r.Err())
+				}
+			}
+			return done
+		}
+		for i, op := range chunk {
+			// Moves that changed nothing had no source, applied alone they fail
+			done[start+i] = op.Op != agent.OpMove || (i < len(resp.Results) && resp.Results[i].Changed)
+		}
+		e.phaseStep(chunk[len(chunk)-1].Path, len(chunk))
+		e.logger().Debug("Batch applied", "ops", len(chunk))
+	}
+	return done
+}
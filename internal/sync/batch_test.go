@@ -0,0 +1,60 @@
+package sync
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"schnorarr/internal/agent"
+)
+
+func TestEngine_RunBatched(t *testing.T) {
+	var calls [][]agent.Op
+	mux := http.NewServeMux()
+	mux.HandleFunc(agent.PathPrefix+"Batch", func(w http.ResponseWriter, r *http.Request) {
+		var req agent.BatchRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		calls = append(calls, req.Ops)
+		// The second batch is rolled back
+		resp := agent.BatchResponse{Committed: len(calls) == 1, Results: make([]agent.OpResult, len(req.Ops))}
+		if !resp.Committed {
+			resp.Results[0] = agent.OpResult{Error: agent.ErrNotEmpty.Error(), Code: "not_empty"}
+		}
+		// The first batch misses the source of its second move
+		for i := range resp.Results {
+			resp.Results[i].Changed = resp.Committed && i != 1
+		}
+		w.Header().Set(agent.HeaderVersion, "1")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	t.Setenv("RECEIVER_PORT", srv.URL[strings.LastIndex(srv.URL, ":")+1:])
+
+	engine := NewEngine(SyncConfig{ID: "test-batch", SourceDir: t.TempDir(), TargetDir: "127.0.0.1::video-sync/tv"})
+	ops := make([]agent.Op, batchSize*3)
+	for i := range ops {
+		ops[i] = agent.Op{Op: agent.OpMove, Path: "Old", To: "New"}
+	}
+	done := engine.runBatched(ops)
+
+	if len(calls) != 2 {
+		t.Fatalf("Batching should stop after the rolled back batch, got %d calls", len(calls))
+	}
+	if calls[0][0].Path != "tv/Old" || calls[0][0].To != "tv/New" {
+		t.Errorf("Paths should be relative to the receiver root, got %+v", calls[0][0])
+	}
+	for i, ok := range done {
+		if ok != (i < batchSize && i != 1) {
+			t.Fatalf("done[%d] = %v, only the moves of the committed batch are done", i, ok)
+		}
+	}
+
+	// Local targets are never batched
+	local := NewEngine(SyncConfig{ID: "test-batch-local", SourceDir: t.TempDir(), TargetDir: t.TempDir()})
+	if done := local.runBatched(ops); done[0] || len(calls) != 2 {
+		t.Error("Local targets should apply changes one by one")
+	}
+}
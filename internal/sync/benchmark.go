@@ -0,0 +1,228 @@
+package sync
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"schnorarr/internal/monitor/database"
+	"schnorarr/internal/sync/pool"
+)
+
+// DefaultBenchmarkSize is the amount of synthetic data copied per benchmark case
+const DefaultBenchmarkSize = 64 * 1024 * 1024
+
+// benchmarkDir is created below the target for the benchmark and removed afterwards
+const benchmarkDir = ".schnorarr-bench"
+
+var (
+	// DefaultBenchmarkStreams are the stream counts tried for local targets
+	DefaultBenchmarkStreams = []int{1, 2, 4, 8}
+	// DefaultBenchmarkChunkSizes are the buffer sizes tried for local targets
+	DefaultBenchmarkChunkSizes = []int{64 * 1024, 128 * 1024, 1024 * 1024}
+)
+
+// BenchmarkOptions selects the data size and settings a benchmark tries.
+// Remote targets always compare rsync with and without compression instead,
+// as stream count and chunk size only apply to local copies.
+type BenchmarkOptions struct {
+	Size       int64 // Bytes per case (default: DefaultBenchmarkSize)
+	Streams    []int // Stream counts (default: DefaultBenchmarkStreams)
+	ChunkSizes []int // Chunk sizes in bytes (default: DefaultBenchmarkChunkSizes)
+}
+
+// Benchmark copies synthetic data to the engine's target with varying stream
+// counts, chunk sizes and compression. The results are stored and, with
+// AutoTune, the fastest combination is applied to the engine right away.
+func (e *Engine) Benchmark(opts BenchmarkOptions) (*database.BenchmarkRun, error) {
+	if !e.syncMu.TryLock() {
+		return nil, fmt.Errorf("engine is busy syncing, try again later")
+	}
+	defer e.syncMu.Unlock()
+	AcquireTransferLockFor(e.config.LockGroup)
+	defer ReleaseTransferLockFor(e.config.LockGroup)
+
+	cfg := e.GetConfig()
+	run, err := runBenchmark(cfg.TargetDir, opts, cfg.IOClass, cfg.IOLevel, e.tagged(transferLog))
+	if err != nil {
+		return nil, err
+	}
+	run.EngineID = cfg.ID
+	if err := database.SaveBenchmarkRun(run); err != nil {
+		e.logger().Warn("Failed to store benchmark results", "error", err)
+	}
+
+	best := run.BestResult()
+	if best == nil {
+		return run, fmt.Errorf("every benchmark case failed")
+	}
+	e.logger().Info("Benchmark finished", "streams", best.Streams, "chunk_size", best.ChunkSize,
+		"compress", best.Compress, "speed", database.FormatBytes(best.Throughput)+"/s")
+	if cfg.AutoTune {
+		e.applyTuning(best)
+	}
+	return run, nil
+}
+
+// applyTuning switches the engine to the settings of a benchmark result
+func (e *Engine) applyTuning(r *database.BenchmarkResult) {
+	e.pausedMu.Lock()
+	e.config.NumStreams, e.config.ChunkSize, e.config.Compress = r.Streams, r.ChunkSize, r.Compress
+	e.transferer.SetTuning(r.Streams, r.ChunkSize, r.Compress)
+	e.pausedMu.Unlock()
+}
+
+// loadTuning applies the best result of the stored benchmark, if any
+func (e *Engine) loadTuning() {
+	run, err := database.LoadBenchmarkRun(e.config.ID)
+	if err != nil {
+		e.logger().Warn("Failed to load benchmark results", "error", err)
+		return
+	}
+	if run == nil {
+		return
+	}
+	if best := run.BestResult(); best != nil {
+		e.applyTuning(best)
+		e.logger().Info("Applied benchmark tuning", "streams", best.Streams, "chunk_size", best.ChunkSize, "compress", best.Compress)
+	}
+}
+
+func runBenchmark(targetDir string, opts BenchmarkOptions, ioClass, ioLevel int, logger *slog.Logger) (*database.BenchmarkRun, error) {
+	if opts.Size <= 0 {
+		opts.Size = DefaultBenchmarkSize
+	}
+	if len(opts.Streams) == 0 {
+		opts.Streams = DefaultBenchmarkStreams
+	}
+	if len(opts.ChunkSizes) == 0 {
+		opts.ChunkSizes = DefaultBenchmarkChunkSizes
+	}
+
+	src, err := writeBenchmarkData(opts.Size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create benchmark data: %w", err)
+	}
+	defer func() { _ = os.Remove(src) }()
+
+	remote := IsRemotePath(targetDir)
+	var cases []database.BenchmarkResult
+	if remote {
+		cases = []database.BenchmarkResult{{Compress: false}, {Compress: true}}
+	} else {
+		for _, streams := range opts.Streams {
+			for _, chunk := range opts.ChunkSizes {
+				if streams > 0 && chunk > 0 {
+					cases = append(cases, database.BenchmarkResult{Streams: streams, ChunkSize: chunk})
+				}
+			}
+		}
+	}
+
+	dir := filepath.Join(targetDir, benchmarkDir)
+	cleanup := NewTransferer(TransferOptions{Logger: func() *slog.Logger { return logger }})
+	defer func() {
+		if err := cleanup.DeleteDir(dir); err != nil {
+			logger.Warn("Failed to remove benchmark data", "dir", dir, "error", err)
+		}
+	}()
+	if !remote {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create benchmark directory: %w", err)
+		}
+	}
+
+	run := &database.BenchmarkRun{RunAt: time.Now().Unix()}
+	best := -1
+	for i, c := range cases {
+		t := NewTransferer(TransferOptions{
+			IOClass: ioClass, IOLevel: ioLevel,
+			NumStreams: c.Streams, ChunkSize: c.ChunkSize, Compress: c.Compress, FixedStreams: true,
+			Logger: func() *slog.Logger { return logger },
+		})
+		dst := filepath.Join(dir, fmt.Sprintf("bench-%d.bin", i))
+
+		pool.Acquire()
+		start := time.Now()
+		if remote {
+			err = t.copyRemote(src, dst)
+		} else {
+			err = t.benchmarkLocal(src, dst, opts.Size)
+		}
+		c.Seconds = time.Since(start).Seconds()
+		pool.Release()
+		if err != nil {
+			c.Error = err.Error()
+			logger.Warn("Benchmark case failed", "streams", c.Streams, "chunk_size", c.ChunkSize, "compress", c.Compress, "error", err)
+		} else {
+			c.Bytes = opts.Size
+			if c.Seconds > 0 {
+				c.Throughput = int64(float64(c.Bytes) / c.Seconds)
+			}
+			logger.Debug("Benchmark case finished", "streams", c.Streams, "chunk_size", c.ChunkSize, "compress", c.Compress, "speed", database.FormatBytes(c.Throughput)+"/s")
+			// Cases are ordered cheapest first, so only a strictly faster one wins
+			if best < 0 || c.Throughput > run.Results[best].Throughput {
+				best = i
+			}
+		}
+		if !remote {
+			_ = os.Remove(dst)
+		}
+		run.Results = append(run.Results, c)
+	}
+	if best >= 0 {
+		run.Results[best].Best = true
+	}
+	return run, nil
+}
+
+// writeBenchmarkData creates a temporary file of random, incompressible data
+// like the media files the engines usually transfer
+func writeBenchmarkData(size int64) (string, error) {
+	f, err := os.CreateTemp("", "schnorarr-bench-*.bin")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.CopyN(f, rand.Reader, size)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// benchmarkLocal copies src to dst with the configured streams and chunk size,
+// including the final sync to disk
+func (t *Transferer) benchmarkLocal(src, dst string, size int64) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = srcFile.Close() }()
+	dstFile, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if t.numStreams() > 1 {
+		_, err = t.copyParallel(filepath.Base(src), srcFile, dstFile, size)
+	} else {
+		withIOPriority(t.opts.IOClass, t.opts.IOLevel, func() {
+			_, err = t.copyWithProgress(filepath.Base(src), srcFile, dstFile, size, 0)
+		})
+	}
+	if err == nil {
+		err = dstFile.Sync()
+	}
+	if cerr := dstFile.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,52 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEngine_Benchmark(t *testing.T) {
+	targetDir := t.TempDir()
+	engine := NewEngine(SyncConfig{ID: "test-bench", SourceDir: t.TempDir(), TargetDir: targetDir, AutoTune: true})
+
+	run, err := engine.Benchmark(BenchmarkOptions{Size: 256 * 1024, Streams: []int{1, 4}, ChunkSizes: []int{32 * 1024, 64 * 1024}})
+	if err != nil {
+		t.Fatalf("Benchmark failed: %v", err)
+	}
+	if run.EngineID != "test-bench" || len(run.Results) != 4 {
+		t.Fatalf("Expected 4 results for engine test-bench, got %+v", run)
+	}
+	for _, r := range run.Results {
+		if r.Error != "" || r.Bytes != 256*1024 {
+			t.Errorf("Unexpected result %+v", r)
+		}
+	}
+
+	best := run.BestResult()
+	if best == nil {
+		t.Fatal("Expected a best result")
+	}
+	cfg := engine.GetConfig()
+	if cfg.NumStreams != best.Streams || cfg.ChunkSize != best.ChunkSize {
+		t.Errorf("AutoTune should apply the best result, got streams=%d chunk=%d", cfg.NumStreams, cfg.ChunkSize)
+	}
+	if engine.transferer.numStreams() != best.Streams || engine.transferer.chunkSize() != best.ChunkSize {
+		t.Error("Transferer should use the tuned settings")
+	}
+
+	if _, err := os.Stat(filepath.Join(targetDir, benchmarkDir)); !os.IsNotExist(err) {
+		t.Error("Benchmark directory should be removed")
+	}
+}
+
+func TestEngine_BenchmarkWithoutAutoTune(t *testing.T) {
+	engine := NewEngine(SyncConfig{ID: "test-bench-fixed", SourceDir: t.TempDir(), TargetDir: t.TempDir(), NumStreams: 2})
+
+	if _, err := engine.Benchmark(BenchmarkOptions{Size: 64 * 1024, Streams: []int{1}, ChunkSizes: []int{16 * 1024}}); err != nil {
+		t.Fatalf("Benchmark failed: %v", err)
+	}
+	if engine.GetConfig().NumStreams != 2 || engine.transferer.numStreams() != 2 {
+		t.Error("Explicit settings must not be replaced without AutoTune")
+	}
+}
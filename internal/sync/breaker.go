@@ -0,0 +1,158 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultBreakerProbeInterval is how often a tripped engine probes its target
+const DefaultBreakerProbeInterval = 5 * time.Minute
+
+// ErrCircuitOpen is returned by cycles of an engine whose circuit breaker tripped
+var ErrCircuitOpen = errors.New("circuit breaker open: target keeps failing")
+
+// BreakerStats describes the circuit breaker of an engine
+type BreakerStats struct {
+	Open      bool      `json:"open"`
+	Probing   bool      `json:"probing"` // Half-open: a probe cycle is running
+	Since     time.Time `json:"since"`
+	Failures  int       `json:"failures"` // Consecutive target errors
+	LastError string    `json:"last_error"`
+	NextProbe time.Time `json:"next_probe"`
+}
+
+// circuitBreaker counts consecutive target errors. Once BreakerThreshold is
+// reached the engine stops transferring (FAILED) instead of failing file by
+// file, and a probe cycle tries the target every BreakerProbeInterval
+// (half-open): the first target operation that succeeds closes the breaker,
+// the first that fails opens it again.
+type circuitBreaker struct {
+	failures  int
+	open      bool
+	probing   bool
+	since     time.Time
+	lastError string
+	nextProbe time.Time
+}
+
+func (e *Engine) breakerProbeInterval() time.Duration {
+	if e.config.BreakerProbeInterval > 0 {
+		return e.config.BreakerProbeInterval
+	}
+	return DefaultBreakerProbeInterval
+}
+
+// IsFailed reports whether the circuit breaker of the engine is open
+func (e *Engine) IsFailed() bool {
+	e.pausedMu.RLock()
+	defer e.pausedMu.RUnlock()
+	return e.breaker.open
+}
+
+// GetBreakerStats returns the state of the circuit breaker
+func (e *Engine) GetBreakerStats() BreakerStats {
+	e.pausedMu.RLock()
+	defer e.pausedMu.RUnlock()
+	b := e.breaker
+	return BreakerStats{Open: b.open, Probing: b.probing, Since: b.since, Failures: b.failures, LastError: b.lastError, NextProbe: b.nextProbe}
+}
+
+// breakerBlocks reports whether a cycle may not run: the breaker is open and
+// no probe is due
+func (e *Engine) breakerBlocks() bool {
+	e.pausedMu.RLock()
+	defer e.pausedMu.RUnlock()
+	return e.breaker.open && !e.breaker.probing
+}
+
+// targetFailed counts a failed target operation. It returns true when the
+// breaker is open and the cycle should stop.
+func (e *Engine) targetFailed(err error) bool {
+	if e.config.BreakerThreshold <= 0 {
+		return false
+	}
+	e.pausedMu.Lock()
+	b := &e.breaker
+	b.failures++
+	b.lastError = err.Error()
+	switch {
+	case b.probing:
+		b.probing = false
+		b.nextProbe = time.Now().Add(e.breakerProbeInterval())
+		e.pausedMu.Unlock()
+		e.logger().Warn("Circuit breaker probe failed, target still failing", "error", err, "next_probe", e.breakerProbeInterval().String())
+		return true
+	case b.open:
+		e.pausedMu.Unlock()
+		return true
+	case b.failures < e.config.BreakerThreshold:
+		e.pausedMu.Unlock()
+		return false
+	}
+	b.open = true
+	b.since = time.Now()
+	b.nextProbe = b.since.Add(e.breakerProbeInterval())
+	stats := BreakerStats{Open: true, Since: b.since, Failures: b.failures, LastError: b.lastError, NextProbe: b.nextProbe}
+	e.pausedMu.Unlock()
+
+	e.logger().Error("Circuit breaker tripped, engine FAILED until the target works again", "failures", stats.Failures, "error", err)
+	if e.config.OnBreaker != nil {
+		e.config.OnBreaker(stats)
+	} else {
+		e.reportError(fmt.Sprintf("Engine %s failed after %d consecutive target errors: %v", e.config.ID, stats.Failures, err))
+	}
+	go e.breakerProbeLoop()
+	return true
+}
+
+// targetSucceeded resets the error count and closes a probing breaker
+func (e *Engine) targetSucceeded() {
+	e.pausedMu.Lock()
+	e.breaker.failures = 0
+	e.pausedMu.Unlock()
+	e.probeSucceeded()
+}
+
+// probeSucceeded closes the breaker after a probe cycle that completed or a
+// probe transfer that worked
+func (e *Engine) probeSucceeded() {
+	e.pausedMu.Lock()
+	if !e.breaker.probing {
+		e.pausedMu.Unlock()
+		return
+	}
+	since := e.breaker.since
+	e.breaker = circuitBreaker{}
+	e.pausedMu.Unlock()
+
+	e.logger().Info("Circuit breaker closed, target works again", "failed_for", time.Since(since).Round(time.Second).String())
+	if e.config.OnBreaker != nil {
+		e.config.OnBreaker(BreakerStats{Since: since})
+	}
+}
+
+// breakerProbeLoop runs a probe cycle every probe interval until the breaker closes
+func (e *Engine) breakerProbeLoop() {
+	ticker := time.NewTicker(e.breakerProbeInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stopCh:
+			return
+		case <-ticker.C:
+			e.pausedMu.Lock()
+			if !e.breaker.open {
+				e.pausedMu.Unlock()
+				return
+			}
+			e.breaker.probing = true
+			e.breaker.nextProbe = time.Time{}
+			// The failed files are exactly what the probe should try
+			e.failedFiles = make(map[string]time.Time)
+			e.pausedMu.Unlock()
+			e.logger().Info("Circuit breaker half-open, probing the target")
+			_ = e.RunSync(nil)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package sync
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	stdsync "sync"
+	"testing"
+	"time"
+)
+
+func TestEngine_CircuitBreaker(t *testing.T) {
+	source, root := t.TempDir(), t.TempDir()
+	for _, name := range []string{"a.mkv", "b.mkv", "c.mkv"} {
+		if err := os.WriteFile(filepath.Join(source, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A file where the target's parent should be makes every copy fail
+	blocker := filepath.Join(root, "disk")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(blocker, "tv")
+
+	var mu stdsync.Mutex
+	var changes []BreakerStats
+	e := NewEngine(SyncConfig{
+		ID: "breaker", SourceDir: source, TargetDir: target, BreakerThreshold: 2, BreakerProbeInterval: 20 * time.Millisecond,
+		OnBreaker: func(stats BreakerStats) { mu.Lock(); changes = append(changes, stats); mu.Unlock() },
+	})
+	defer e.Stop()
+
+	if err := e.RunSync(nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected the breaker to trip, got %v", err)
+	}
+	stats := e.GetBreakerStats()
+	if !e.IsFailed() || stats.Failures != 2 || stats.LastError == "" {
+		t.Fatalf("Expected the engine FAILED after 2 errors, got %+v", stats)
+	}
+	mu.Lock()
+	if len(changes) != 1 || !changes[0].Open {
+		t.Fatalf("Expected one trip notified, got %+v", changes)
+	}
+	mu.Unlock()
+
+	// The target works again: a half-open probe closes the breaker
+	if err := os.Remove(blocker); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(target, 0755); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for e.IsFailed() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if e.IsFailed() {
+		t.Fatalf("The probe did not close the breaker: %+v", e.GetBreakerStats())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(changes) != 2 || changes[1].Open {
+		t.Errorf("Expected the recovery notified, got %+v", changes)
+	}
+	if _, err := os.Stat(filepath.Join(target, "a.mkv")); err != nil {
+		t.Errorf("The probe should have synced the files: %v", err)
+	}
+}
+
+func TestEngine_CircuitBreakerDisabled(t *testing.T) {
+	source, root := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "a.mkv"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	blocker := filepath.Join(root, "disk")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	e := NewEngine(SyncConfig{ID: "no-breaker", SourceDir: source, TargetDir: filepath.Join(blocker, "tv")})
+	defer e.Stop()
+	for i := 0; i < 3; i++ {
+		_ = e.RunSync(nil)
+		e.failedFiles = make(map[string]time.Time)
+	}
+	if e.IsFailed() {
+		t.Error("Without a threshold the breaker never trips")
+	}
+}
@@ -0,0 +1,270 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"schnorarr/internal/monitor/database"
+)
+
+// Checksum files written next to transferred files on the target
+const (
+	ChecksumsOff      = "off"      // No checksum files (default)
+	ChecksumsSidecar  = "sidecar"  // <file>.sha256 next to every file
+	ChecksumsManifest = "manifest" // One SHA256SUMS per directory
+)
+
+// ChecksumSidecarExt is appended to a file name for its sidecar
+const ChecksumSidecarExt = ".sha256"
+
+// ChecksumManifestName is the per-directory checksum manifest
+const ChecksumManifestName = "SHA256SUMS"
+
+// ParseChecksums validates a checksum mode; empty selects ChecksumsOff
+func ParseChecksums(s string) (string, error) {
+	switch m := strings.ToLower(strings.TrimSpace(s)); m {
+	case "", ChecksumsOff, "false":
+		return ChecksumsOff, nil
+	case ChecksumsSidecar, "true":
+		return ChecksumsSidecar, nil
+	case ChecksumsManifest:
+		return m, nil
+	}
+	return ChecksumsOff, fmt.Errorf("unknown checksum mode %q (use %s, %s or %s)", s, ChecksumsOff, ChecksumsSidecar, ChecksumsManifest)
+}
+
+// checksumExcludes are the scanner patterns of the checksum files of a mode.
+// They are the engine's own, so the target copies are neither deleted nor
+// reported, and source files with these names are not synced.
+func checksumExcludes(mode string) []string {
+	switch mode {
+	case ChecksumsSidecar:
+		return []string{"*" + ChecksumSidecarExt}
+	case ChecksumsManifest:
+		return []string{ChecksumManifestName}
+	}
+	return nil
+}
+
+// generatedPatterns are the scanner patterns of the files an engine writes
+// next to the synced files: checksums and parity
+func generatedPatterns(config SyncConfig) []string {
+	patterns := checksumExcludes(config.Checksums)
+	if config.ParityRedundancy > 0 {
+		patterns = append(patterns, parityPattern, parityTmpPattern)
+	}
+	return patterns
+}
+
+// dropGeneratedFiles removes the engine's checksum and parity files from a
+// target manifest. Local scans exclude them already; receiver manifests list them.
+func (e *Engine) dropGeneratedFiles(m *Manifest) {
+	patterns := generatedPatterns(e.config)
+	if len(patterns) == 0 {
+		return
+	}
+	drop := make(map[string]bool)
+	m.mu.RLock()
+	for p := range m.Files {
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, path.Base(p)); matched {
+				drop[p] = true
+			}
+		}
+	}
+	m.mu.RUnlock()
+	if len(drop) > 0 {
+		m.removeTrees(drop)
+	}
+}
+
+// checksumDirs returns the target directories whose checksum manifest a
+// plan changes
+func checksumDirs(plan *SyncPlan) map[string]bool {
+	dirs := make(map[string]bool)
+	add := func(p string) { dirs[path.Dir(filepath.ToSlash(p))] = true }
+	for _, f := range plan.FilesToSync {
+		add(f.Path)
+	}
+	for _, p := range plan.FilesToDelete {
+		add(p)
+	}
+	for from, to := range plan.Renames {
+		add(from)
+		add(to)
+	}
+	return dirs
+}
+
+// hashFile returns the hex SHA-256 of a file
+func hashFile(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// checksumLine is a sha256sum-compatible line for a file in the same directory
+func checksumLine(sum, rel string) string {
+	return sum + "  " + path.Base(rel) + "\n"
+}
+
+// sourceChecksum returns the checksum of a source file version, hashing it
+// unless it was stored before
+func (e *Engine) sourceChecksum(f *FileInfo) (string, error) {
+	if c, _ := database.GetFileChecksum(e.config.ID, f.Path); c != nil && c.Size == f.Size && c.ModTime == f.ModTime.Unix() {
+		return c.SHA256, nil
+	}
+	sum, err := hashFile(filepath.Join(e.config.SourceDir, f.Path))
+	if err != nil {
+		return "", err
+	}
+	c := database.FileChecksum{Path: f.Path, Size: f.Size, ModTime: f.ModTime.Unix(), SHA256: sum}
+	if err := database.SaveFileChecksum(e.config.ID, c); err != nil {
+		e.logger().Warn("Failed to save checksum", "path", f.Path, "error", err)
+	}
+	return sum, nil
+}
+
+// recordChecksum hashes a transferred file and writes its sidecar. The
+// source is hashed, so local and remote targets get the same checksums.
+func (e *Engine) recordChecksum(f *FileInfo) {
+	if e.config.Checksums != ChecksumsSidecar && e.config.Checksums != ChecksumsManifest {
+		return
+	}
+	sum, err := e.sourceChecksum(f)
+	if err != nil {
+		e.logger().Warn("Failed to hash transferred file", "path", f.Path, "error", err)
+		return
+	}
+	if e.config.Checksums != ChecksumsSidecar {
+		return // Manifests are written once the cycle is done
+	}
+	if err := e.writeTargetFile(f.Path+ChecksumSidecarExt, []byte(checksumLine(sum, f.Path))); err != nil {
+		e.logger().Warn("Failed to write checksum sidecar", "path", f.Path, "error", err)
+	}
+}
+
+// renameChecksum follows a rename on the target
+func (e *Engine) renameChecksum(oldPath, newPath string) {
+	if e.config.Checksums != ChecksumsSidecar && e.config.Checksums != ChecksumsManifest {
+		return
+	}
+	if err := database.RenameFileChecksum(e.config.ID, oldPath, newPath); err != nil {
+		e.logger().Warn("Failed to rename checksum", "path", oldPath, "error", err)
+	}
+	if e.config.Checksums != ChecksumsSidecar {
+		return
+	}
+	_ = e.transferer.DeleteFile(filepath.Join(e.config.TargetDir, oldPath+ChecksumSidecarExt))
+	c, _ := database.GetFileChecksum(e.config.ID, newPath)
+	if c == nil {
+		return
+	}
+	if err := e.writeTargetFile(newPath+ChecksumSidecarExt, []byte(checksumLine(c.SHA256, newPath))); err != nil {
+		e.logger().Warn("Failed to write checksum sidecar", "path", newPath, "error", err)
+	}
+}
+
+// removeChecksum drops the checksum of a file deleted from the target
+func (e *Engine) removeChecksum(rel string) {
+	if e.config.Checksums != ChecksumsSidecar && e.config.Checksums != ChecksumsManifest {
+		return
+	}
+	_ = database.DeleteFileChecksum(e.config.ID, rel)
+	if e.config.Checksums == ChecksumsSidecar {
+		_ = e.transferer.DeleteFile(filepath.Join(e.config.TargetDir, rel+ChecksumSidecarExt))
+	}
+}
+
+// writeChecksumManifests rewrites SHA256SUMS in the directories a cycle
+// changed, listing the source files of each directory. Files synced before
+// checksums were enabled are hashed on the way.
+func (e *Engine) writeChecksumManifests(source *Manifest, dirs map[string]bool) {
+	if e.config.Checksums != ChecksumsManifest || len(dirs) == 0 {
+		return
+	}
+	byDir := make(map[string][]*FileInfo)
+	source.mu.RLock()
+	for _, f := range source.Files {
+		if !f.IsDir {
+			dir := path.Dir(filepath.ToSlash(f.Path))
+			if dirs[dir] {
+				byDir[dir] = append(byDir[dir], f)
+			}
+		}
+	}
+	gone := make(map[string]bool)
+	for dir := range dirs {
+		gone[dir] = dir != "." && !source.Dirs[dir]
+	}
+	source.mu.RUnlock()
+
+	for dir := range dirs {
+		rel := path.Join(dir, ChecksumManifestName)
+		files := byDir[dir]
+		if len(files) == 0 {
+			// Directories gone from the source are removed by the cleanup phase
+			if !gone[dir] {
+				_ = e.transferer.DeleteFile(filepath.Join(e.config.TargetDir, rel))
+			}
+			continue
+		}
+		sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+		var b strings.Builder
+		for _, f := range files {
+			sum, err := e.sourceChecksum(f)
+			if err != nil {
+				e.logger().Warn("Failed to hash file for checksum manifest", "path", f.Path, "error", err)
+				continue
+			}
+			b.WriteString(checksumLine(sum, f.Path))
+		}
+		if err := e.writeTargetFile(rel, []byte(b.String())); err != nil {
+			e.logger().Warn("Failed to write checksum manifest", "dir", dir, "error", err)
+		}
+	}
+}
+
+// writeTargetFile publishes a small file the engine generates on the
+// target. Remote copies go through rsync from a temporary file; the old
+// copy is deleted first because rsync --append skips files of equal size.
+func (e *Engine) writeTargetFile(rel string, content []byte) error {
+	dst := filepath.Join(e.config.TargetDir, rel)
+	release := e.lockTarget(rel)
+	defer release()
+	if !IsRemotePath(e.config.TargetDir) {
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		tmp := dst + ".tmp"
+		if err := os.WriteFile(tmp, content, 0644); err != nil {
+			return err
+		}
+		return os.Rename(tmp, dst)
+	}
+	dir, err := os.MkdirTemp("", "schnorarr-checksum-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	tmp := filepath.Join(dir, path.Base(filepath.ToSlash(rel)))
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	_ = e.transferer.DeleteFile(dst)
+	return e.transferer.CopyFile(tmp, dst)
+}
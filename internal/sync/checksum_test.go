@@ -0,0 +1,103 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestEngine_ChecksumSidecars(t *testing.T) {
+	source, target := t.TempDir(), t.TempDir()
+	if err := os.MkdirAll(filepath.Join(source, "show"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"show/e01.mkv": "one", "show/e02.mkv": "two"} {
+		if err := os.WriteFile(filepath.Join(source, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e := NewEngine(SyncConfig{ID: "sums", SourceDir: source, TargetDir: target, Rule: "series", Checksums: ChecksumsSidecar, AutoApproveDeletions: true})
+	if err := e.RunSync(nil); err != nil {
+		t.Fatal(err)
+	}
+	sidecar, err := os.ReadFile(filepath.Join(target, "show", "e01.mkv"+ChecksumSidecarExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := sha256Hex("one") + "  e01.mkv\n"; string(sidecar) != want {
+		t.Errorf("sidecar = %q, want %q", sidecar, want)
+	}
+
+	plan, err := e.PreviewSync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.FilesToDelete) != 0 || len(plan.FilesToSync) != 0 {
+		t.Errorf("Sidecars must not be planned for deletion, plan = %+v", plan.FilesToDelete)
+	}
+
+	if err := os.Remove(filepath.Join(source, "show", "e02.mkv")); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.RunSync(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "show", "e02.mkv"+ChecksumSidecarExt)); !os.IsNotExist(err) {
+		t.Error("The sidecar of a deleted file should be deleted too")
+	}
+}
+
+func TestEngine_ChecksumManifest(t *testing.T) {
+	source, target := t.TempDir(), t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(source, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("b.mkv", "bee")
+	write("a.mkv", "ay")
+
+	e := NewEngine(SyncConfig{ID: "sums-manifest", SourceDir: source, TargetDir: target, Rule: "flat", Checksums: ChecksumsManifest, AutoApproveDeletions: true})
+	if err := e.RunSync(nil); err != nil {
+		t.Fatal(err)
+	}
+	sums, err := os.ReadFile(filepath.Join(target, ChecksumManifestName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := sha256Hex("ay") + "  a.mkv\n" + sha256Hex("bee") + "  b.mkv\n"; string(sums) != want {
+		t.Errorf("%s = %q, want %q", ChecksumManifestName, sums, want)
+	}
+
+	if err := os.Remove(filepath.Join(source, "a.mkv")); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.RunSync(nil); err != nil {
+		t.Fatal(err)
+	}
+	sums, _ = os.ReadFile(filepath.Join(target, ChecksumManifestName))
+	if strings.Contains(string(sums), "a.mkv") || !strings.Contains(string(sums), "b.mkv") {
+		t.Errorf("The manifest should follow deletions, got %q", sums)
+	}
+}
+
+func TestDropChecksumFiles(t *testing.T) {
+	e := NewEngine(SyncConfig{ID: "sums-drop", Checksums: ChecksumsSidecar})
+	m := NewManifest("rsync://host/module")
+	m.Add(&FileInfo{Path: "show/e01.mkv", Size: 3})
+	m.Add(&FileInfo{Path: "show/e01.mkv" + ChecksumSidecarExt, Size: 74})
+	e.dropGeneratedFiles(m)
+	if m.HasFile("show/e01.mkv"+ChecksumSidecarExt) || !m.HasFile("show/e01.mkv") {
+		t.Errorf("Receiver manifests should lose the sidecars only, got %v", m.Files)
+	}
+}
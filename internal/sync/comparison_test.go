@@ -1,6 +1,8 @@
 package sync
 
 import (
+	"sort"
+	"strings"
 	"testing"
 	"time"
 )
@@ -108,3 +110,179 @@ func TestCompareManifests_FlatSync(t *testing.T) {
 		t.Error("Expected CommonDir/to_delete.txt to be deleted")
 	}
 }
+
+func TestCompareManifests_ThreeWay(t *testing.T) {
+	now := time.Now()
+	sender := NewManifest("/sender")
+	receiver := NewManifest("/receiver")
+	base := NewManifest("/receiver")
+	for _, m := range []*Manifest{sender, receiver, base} {
+		m.Add(&FileInfo{Path: "Show", IsDir: true})
+		m.Add(&FileInfo{Path: "Show/E01.mkv", Size: 100, ModTime: now})
+	}
+	// Deleted on the source after the last sync
+	receiver.Add(&FileInfo{Path: "Show/E02.mkv", Size: 200, ModTime: now})
+	base.Add(&FileInfo{Path: "Show/E02.mkv", Size: 200, ModTime: now})
+	// Added on the target after the last sync
+	receiver.Add(&FileInfo{Path: "Show/E03.mkv", Size: 300, ModTime: now})
+	// Deleted on the target after the last sync
+	sender.Add(&FileInfo{Path: "Show/E04.mkv", Size: 400, ModTime: now})
+	base.Add(&FileInfo{Path: "Show/E04.mkv", Size: 400, ModTime: now})
+	// Added on the source
+	sender.Add(&FileInfo{Path: "Show/E05.mkv", Size: 500, ModTime: now})
+
+	twoWay := CompareManifestsWithOptions(sender, receiver, CompareOptions{Rule: "series", SkipRenames: true})
+	if len(twoWay.FilesToDelete) != 2 {
+		t.Fatalf("Two-way comparison should delete both receiver-only files, got %v", twoWay.FilesToDelete)
+	}
+
+	plan := CompareManifestsWithOptions(sender, receiver, CompareOptions{Rule: "series", SkipRenames: true, Base: base})
+	if len(plan.FilesToDelete) != 1 || plan.FilesToDelete[0] != "Show/E02.mkv" {
+		t.Errorf("Only the file deleted on the source should be deleted, got %v", plan.FilesToDelete)
+	}
+	if len(plan.TargetAdded) != 1 || plan.TargetAdded[0] != "Show/E03.mkv" {
+		t.Errorf("TargetAdded = %v, want the file added on the target", plan.TargetAdded)
+	}
+	if len(plan.TargetDeleted) != 1 || plan.TargetDeleted[0] != "Show/E04.mkv" {
+		t.Errorf("TargetDeleted = %v, want the file deleted on the target", plan.TargetDeleted)
+	}
+	if len(plan.FilesToSync) != 2 {
+		t.Errorf("Both source files missing on the target should be transferred, got %d", len(plan.FilesToSync))
+	}
+}
+
+func TestCompareManifests_IncludeFilterDeletions(t *testing.T) {
+	include := []string{"*.mkv"}
+	sender := NewManifest("/sender")
+	receiver := NewManifest("/receiver")
+
+	// The sender's scan left Movie/movie.nfo out; an unfiltered remote
+	// manifest of the receiver still lists it
+	sender.Add(&FileInfo{Path: "Movie", IsDir: true})
+	sender.Add(&FileInfo{Path: "Movie/movie.mkv", Size: 100})
+	receiver.Add(&FileInfo{Path: "Movie", IsDir: true})
+	receiver.Add(&FileInfo{Path: "Movie/movie.mkv", Size: 100})
+	receiver.Add(&FileInfo{Path: "Movie/movie.nfo", Size: 1})
+	receiver.Add(&FileInfo{Path: "Movie/old.mkv", Size: 100})
+
+	plan := CompareManifestsWithOptions(sender, receiver, CompareOptions{Rule: "flat", SkipRenames: true, Include: include})
+	if len(plan.FilesToDelete) != 1 || plan.FilesToDelete[0] != "Movie/old.mkv" {
+		t.Errorf("Only the included receiver-only file should be deleted, got %v", plan.FilesToDelete)
+	}
+
+	// Without the filter the comparison sees everything, as before
+	plan = CompareManifestsWithOptions(sender, receiver, CompareOptions{Rule: "flat", SkipRenames: true})
+	if len(plan.FilesToDelete) != 2 {
+		t.Errorf("Without include patterns both receiver-only files are candidates, got %v", plan.FilesToDelete)
+	}
+
+	reason := deletionProtection(sender, "Movie/movie.nfo", false, CompareOptions{Rule: "flat", Include: include})
+	if reason == "" {
+		t.Error("A file outside the include filter should be reported as protected")
+	}
+}
+
+func TestCompareManifests_FlatDeleteDirs(t *testing.T) {
+	sender := NewManifest("/sender")
+	receiver := NewManifest("/receiver")
+	base := NewManifest("/receiver")
+
+	sender.Add(&FileInfo{Path: "Kept", IsDir: true})
+	sender.Add(&FileInfo{Path: "Kept/kept.mkv", Size: 100})
+	for _, m := range []*Manifest{receiver, base} {
+		m.Add(&FileInfo{Path: "Kept", IsDir: true})
+		m.Add(&FileInfo{Path: "Kept/kept.mkv", Size: 100})
+		// Deleted on the source with everything in it
+		m.Add(&FileInfo{Path: "Gone", IsDir: true})
+		m.Add(&FileInfo{Path: "Gone/gone.mkv", Size: 100})
+		m.Add(&FileInfo{Path: "Gone/Extras", IsDir: true})
+		m.Add(&FileInfo{Path: "Kept/Featurettes", IsDir: true})
+	}
+	// Only ever on the receiver: not in the base
+	receiver.Add(&FileInfo{Path: "Archive", IsDir: true})
+	receiver.Add(&FileInfo{Path: "Archive/old.mkv", Size: 100})
+	// Hidden by the include filter, removed along with its directory
+	receiver.Add(&FileInfo{Path: "Gone/gone.nfo", Size: 1})
+
+	opts := CompareOptions{Rule: "flat", SkipRenames: true, Include: []string{"*.mkv"}, Base: base}
+	if plan := CompareManifestsWithOptions(sender, receiver, opts); len(plan.DirsToDelete) != 0 || len(plan.FilesToDelete) != 0 {
+		t.Errorf("The flat rule should keep directories by default, got dirs %v files %v", plan.DirsToDelete, plan.FilesToDelete)
+	}
+
+	opts.FlatDeleteDirs = true
+	plan := CompareManifestsWithOptions(sender, receiver, opts)
+	want := []string{"Gone/Extras", "Kept/Featurettes", "Gone"}
+	if strings.Join(plan.DirsToDelete, ",") != strings.Join(want, ",") {
+		t.Errorf("DirsToDelete = %v, want deepest first %v", plan.DirsToDelete, want)
+	}
+	sort.Strings(plan.FilesToDelete)
+	if strings.Join(plan.FilesToDelete, ",") != "Gone/gone.mkv,Gone/gone.nfo" {
+		t.Errorf("Files inside deleted directories go with them, got %v", plan.FilesToDelete)
+	}
+
+	// Without a base nothing tells deleted directories from receiver-only ones
+	opts.Base = nil
+	if plan := CompareManifestsWithOptions(sender, receiver, opts); len(plan.DirsToDelete) != 0 {
+		t.Errorf("Without a synced base no directory should be deleted, got %v", plan.DirsToDelete)
+	}
+
+	// Never-delete patterns inside a directory protect the whole directory
+	opts.Base, opts.NeverDelete = base, []string{"*.nfo"}
+	plan = CompareManifestsWithOptions(sender, receiver, opts)
+	if len(plan.DirsToDelete) != 1 || plan.DirsToDelete[0] != "Kept/Featurettes" {
+		t.Errorf("Gone holds a protected file and should be kept, got %v", plan.DirsToDelete)
+	}
+	if len(plan.Protected) != 1 || plan.Protected[0].Path != "Gone" {
+		t.Errorf("Protected = %v, want Gone", plan.Protected)
+	}
+}
+
+func TestCompareManifests_DirRename(t *testing.T) {
+	now := time.Now()
+	build := func(m *Manifest, dir string) {
+		m.Add(&FileInfo{Path: "Movies", IsDir: true})
+		m.Add(&FileInfo{Path: "Movies/" + dir, IsDir: true})
+		m.Add(&FileInfo{Path: "Movies/" + dir + "/movie.mkv", Size: 1000, ModTime: now})
+		m.Add(&FileInfo{Path: "Movies/" + dir + "/Extras", IsDir: true})
+		m.Add(&FileInfo{Path: "Movies/" + dir + "/Extras/trailer.mkv", Size: 10, ModTime: now})
+	}
+	sender, receiver := NewManifest("/sender"), NewManifest("/receiver")
+	build(sender, "Movie (2020)")
+	build(receiver, "Movie")
+	// Hidden by the include filter: moves along without being compared
+	receiver.Add(&FileInfo{Path: "Movies/Movie/movie.nfo", Size: 1, ModTime: now})
+	// Without FlatDeleteDirs the flat rule keeps the old directory as it is
+	opts := CompareOptions{Rule: "flat", SkipRenames: true, Include: []string{"*.mkv"}, Base: receiver}
+	if plan := CompareManifestsWithOptions(sender, receiver, opts); len(plan.Renames) != 0 {
+		t.Errorf("A directory the rule keeps should not be renamed, got %v", plan.Renames)
+	}
+	opts.FlatDeleteDirs = true
+
+	plan := CompareManifestsWithOptions(sender, receiver, opts)
+	if len(plan.Renames) != 1 || plan.Renames["Movies/Movie"] != "Movies/Movie (2020)" {
+		t.Fatalf("Renames = %v, want the directory rename", plan.Renames)
+	}
+	if len(plan.FilesToSync)+len(plan.DirsToCreate)+len(plan.FilesToDelete)+len(plan.DirsToDelete) != 0 {
+		t.Errorf("The rename should replace all other work, got %+v", plan)
+	}
+
+	// Never-delete content keeps the old directory where it is
+	protected := opts
+	protected.NeverDelete = []string{"*.nfo"}
+	if plan := CompareManifestsWithOptions(sender, receiver, protected); len(plan.Renames) != 0 {
+		t.Errorf("A protected directory should not be renamed, got %v", plan.Renames)
+	}
+
+	// Any difference in the contents means a transfer
+	sender.Add(&FileInfo{Path: "Movies/Movie (2020)/Extras/trailer.mkv", Size: 11, ModTime: now})
+	if plan := CompareManifestsWithOptions(sender, receiver, opts); len(plan.Renames) != 0 || len(plan.FilesToSync) != 2 {
+		t.Errorf("Different contents should not be a rename, got renames %v, %d transfers", plan.Renames, len(plan.FilesToSync))
+	}
+
+	// Two lookalike copies on the source are ambiguous
+	sender.Add(&FileInfo{Path: "Movies/Movie (2020)/Extras/trailer.mkv", Size: 10, ModTime: now})
+	build(sender, "Movie (Copy)")
+	if plan := CompareManifestsWithOptions(sender, receiver, opts); len(plan.Renames) != 0 {
+		t.Errorf("Ambiguous matches should not be renamed, got %v", plan.Renames)
+	}
+}
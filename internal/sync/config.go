@@ -14,28 +14,114 @@ type SyncConfig struct {
 	SourceDir string
 	// TargetDir is the destination directory
 	TargetDir string
-	// Rule describes the sync strategy (e.g., "flat", "series")
+	// Rule describes the sync strategy (e.g., "flat", "series", "move")
 	Rule string
+	// Groups tag the engine for group-level actions and filters (e.g. "4K", "offsite")
+	Groups []string
 	// ExcludePatterns are glob patterns to exclude from syncing
 	ExcludePatterns []string
 	// IncludePatterns are glob patterns to include in syncing (default: all)
 	IncludePatterns []string
+	// MirrorDirs creates every source directory on remote targets too, including
+	// ones without included files; local targets always get them
+	MirrorDirs bool
+	// FlatDeleteDirs lets the flat rule delete target directories removed from
+	// the source, including files the include patterns leave out
+	FlatDeleteDirs bool
+	// NeverDeletePatterns are path patterns that are never deleted from the target
+	NeverDeletePatterns []string
+	// NeverOverwritePatterns are path patterns whose existing target files are never replaced
+	NeverOverwritePatterns []string
+	// MtimePolicy decides when a file on both ends is transferred again:
+	// MtimeNewer (default), MtimeSizeOrMtime or MtimeSizeAndMtime
+	MtimePolicy string
+	// MtimeTolerance treats modification times this close as equal (0 = whole seconds)
+	MtimeTolerance time.Duration
+	// ScanCommand is run against every transferred file before it is published
+	// ("{}" is replaced by the path, otherwise it is appended). A nonzero exit
+	// quarantines the file: local copies go to QuarantineDirName on the target,
+	// for remote targets the source is scanned and not sent.
+	ScanCommand string
+	// ScanTimeout bounds one run of ScanCommand (0 = DefaultScanTimeout)
+	ScanTimeout time.Duration
+	// MediaValidation probes source media with ffprobe before it is sent:
+	// MediaValidationOff (default), MediaValidationSkip or MediaValidationFlag
+	MediaValidation string
+	// Checksums writes the SHA-256 of transferred files to the target:
+	// ChecksumsOff (default), ChecksumsSidecar or ChecksumsManifest
+	Checksums string
+	// ParityRedundancy is the PAR2 redundancy in percent created for every
+	// completed target directory (0 = no parity)
+	ParityRedundancy int
 	// BandwidthLimit in bytes per second (0 = unlimited)
 	BandwidthLimit int64
+	// NumStreams is the number of parallel streams for large local copies (0 = DefaultNumStreams)
+	NumStreams int
+	// ChunkSize is the copy buffer size in bytes for local copies (0 = ChunkSize)
+	ChunkSize int
+	// Compress enables rsync compression for remote targets
+	Compress bool
+	// AutoTune applies the fastest settings of the engine's latest benchmark,
+	// replacing NumStreams, ChunkSize and Compress
+	AutoTune bool
+	// LockGroup names the scan/transfer lock group this engine belongs to (default: global)
+	LockGroup string
+	// IOClass is the ionice scheduling class used for transfers (0 = unchanged, 3 = idle)
+	IOClass int
+	// IOLevel is the ionice priority level within IOClass (0 = highest, 7 = lowest)
+	IOLevel int
 	// WatchInterval is how often to perform full scans (0 = only on file changes)
 	WatchInterval time.Duration
+	// WatchFallbackInterval is how often subtrees that exceed the inotify watch limit are polled
+	WatchFallbackInterval time.Duration
 	// PollInterval is how often to poll the source directory for changes (for Docker/Windows compatibility)
 	PollInterval time.Duration
 	// DryRun when true, logs what would be synced without actually syncing
 	DryRun bool
 	// DryRunFunc optional callback to check dry run status dynamically
 	DryRunFunc func() bool
+	// DryRunActions are actions (ActionAdd, ActionRename, ActionDelete, ActionMove)
+	// only logged while the others run, e.g. to trial deletions
+	DryRunActions []string
+	// MaxDeletePercent requires approval when a plan would delete more than this share
+	// of the target's files or bytes, even with AutoApproveDeletions (0 = disabled)
+	MaxDeletePercent float64
+	// DeleteDeferScans is the number of consecutive scans a target file must be missing
+	// from the source before it is deleted (0 = no scan requirement)
+	DeleteDeferScans int
+	// DeleteDeferAge is how long a target file must be missing from the source before it is deleted
+	DeleteDeferAge time.Duration
+	// MoveAfter is how old a source file must be before the move rule removes it once mirrored
+	MoveAfter time.Duration
+	// EvictAbovePercent evicts the oldest mirrored files from the source once its disk
+	// usage passes this percentage (move rule only, 0 = disabled)
+	EvictAbovePercent float64
+	// EvictToPercent is the usage eviction stops at (default: EvictAbovePercent)
+	EvictToPercent float64
+	// UndoRetention is how long deletions and renames of a cycle can be undone; deleted
+	// files wait in TrashDirName of a local target until then (0 = disabled)
+	UndoRetention time.Duration
+	// BacklogLimit is how many changed paths are remembered while the receiver is offline (0 = DefaultBacklogLimit)
+	BacklogLimit int
+	// BreakerThreshold is how many consecutive target errors trip the engine into
+	// FAILED, where it only probes the target until it works again (0 = disabled)
+	BreakerThreshold int
+	// BreakerProbeInterval is how often a FAILED engine probes its target (0 = DefaultBreakerProbeInterval)
+	BreakerProbeInterval time.Duration
 	// AutoApproveDeletions when true, deletions are executed without waiting for manual approval
 	AutoApproveDeletions bool
-	// OnSyncEvent callback for sync events (timestamp, action, path, size)
-	OnSyncEvent func(timestamp, action, path string, size int64)
+	// SplitApproval lets additions (new and changed files, new directories) run while
+	// approval is pending; only deletions, renames and conflicts are held
+	SplitApproval bool
+	// OnSyncEvent callback for sync events (timestamp, action, path, size, cycle ID; "" outside a sync cycle)
+	OnSyncEvent func(timestamp, action, path string, size int64, cycle string)
 	// OnError callback for errors
 	OnError func(msg string)
+	// OnBreaker is called when the circuit breaker trips (stats.Open) and when it closes again
+	OnBreaker func(stats BreakerStats)
+	// WakeReceiver is called before every cycle against a remote target and
+	// returns once the receiver answers (e.g. after Wake-on-LAN)
+	WakeReceiver func() error
 }
 
 // GetConfig returns the engine configuration
@@ -44,3 +130,22 @@ func (e *Engine) GetConfig() SyncConfig {
 	defer e.pausedMu.RUnlock()
 	return e.config
 }
+
+// SetBandwidthLimit changes the bandwidth limit in bytes per second (0 = unlimited)
+func (e *Engine) SetBandwidthLimit(limit int64) {
+	e.pausedMu.Lock()
+	e.config.BandwidthLimit = limit
+	e.pausedMu.Unlock()
+	e.transferer.SetBandwidthLimit(limit)
+}
+
+// SetPacing caps bandwidth (bytes per second) and parallel streams on top of
+// the configured limit while the receiver is busy; zero values lift the caps
+func (e *Engine) SetPacing(limit int64, streams int) {
+	e.transferer.SetCaps(limit, streams)
+}
+
+// EffectiveBandwidthLimit is the limit transfers run at, pacing included (0 = unlimited)
+func (e *Engine) EffectiveBandwidthLimit() int64 {
+	return e.transferer.limiter.currentRate()
+}
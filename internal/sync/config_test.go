@@ -38,3 +38,15 @@ func TestEngine_ConfigRace(t *testing.T) {
 	close(start)
 	wg.Wait()
 }
+
+func TestEngine_SetBandwidthLimit(t *testing.T) {
+	e := NewEngine(SyncConfig{ID: "bw", SourceDir: t.TempDir(), TargetDir: t.TempDir(), BandwidthLimit: 1 << 20})
+	e.SetBandwidthLimit(5 << 20)
+	if e.GetConfig().BandwidthLimit != 5<<20 || e.transferer.limiter.currentRate() != 5<<20 {
+		t.Errorf("Limit not applied: config %d, limiter %d", e.GetConfig().BandwidthLimit, e.transferer.limiter.currentRate())
+	}
+	e.SetBandwidthLimit(0)
+	if e.transferer.limiter.limited() {
+		t.Error("0 should lift the limit")
+	}
+}
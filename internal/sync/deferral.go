@@ -0,0 +1,100 @@
+package sync
+
+import (
+	"sort"
+	"time"
+
+	"schnorarr/internal/monitor/database"
+)
+
+// DeferredDeletion is a deletion held back until the path has been missing long enough
+type DeferredDeletion struct {
+	Path         string    `json:"path"`
+	FirstMissing time.Time `json:"first_missing"`
+	Scans        int       `json:"scans"`
+}
+
+type missingEntry struct {
+	first time.Time
+	scans int
+}
+
+// deleteDeferralEnabled reports whether deletions must wait for a grace period
+func (e *Engine) deleteDeferralEnabled() bool {
+	return e.config.DeleteDeferScans > 0 || e.config.DeleteDeferAge > 0
+}
+
+// loadMissingPaths restores first-missing timestamps from persistence
+func (e *Engine) loadMissingPaths() {
+	paths, err := database.LoadMissingPaths(e.config.ID)
+	if err != nil {
+		e.logger().Error("Failed to load deferred deletions", "error", err)
+		return
+	}
+	e.pausedMu.Lock()
+	defer e.pausedMu.Unlock()
+	for _, p := range paths {
+		e.missingSince[p.Path] = &missingEntry{first: time.Unix(p.FirstMissing, 0), scans: p.Scans}
+	}
+}
+
+// deferDeletions moves deletions of paths that have not been missing for the
+// configured number of scans and duration into plan.Deferred. When record is
+// true the current comparison counts as a scan and the tracking is persisted;
+// previews evaluate the plan as if a sync ran now without recording it.
+func (e *Engine) deferDeletions(plan *SyncPlan, record bool) {
+	if !e.deleteDeferralEnabled() {
+		return
+	}
+	now := time.Now()
+
+	e.pausedMu.Lock()
+	current := make(map[string]*missingEntry)
+	ready := func(path string) bool {
+		entry := &missingEntry{first: now, scans: 1}
+		if prev, ok := e.missingSince[path]; ok {
+			entry = &missingEntry{first: prev.first, scans: prev.scans + 1}
+		}
+		current[path] = entry
+		if entry.scans >= e.config.DeleteDeferScans && now.Sub(entry.first) >= e.config.DeleteDeferAge {
+			return true
+		}
+		plan.Deferred = append(plan.Deferred, &DeferredDeletion{Path: path, FirstMissing: entry.first, Scans: entry.scans})
+		return false
+	}
+
+	files := make([]string, 0, len(plan.FilesToDelete))
+	for _, p := range plan.FilesToDelete {
+		if ready(p) {
+			files = append(files, p)
+		}
+	}
+	dirs := make([]string, 0, len(plan.DirsToDelete))
+	for _, p := range plan.DirsToDelete {
+		if ready(p) {
+			dirs = append(dirs, p)
+		}
+	}
+	plan.FilesToDelete, plan.DirsToDelete = files, dirs
+	sort.Slice(plan.Deferred, func(i, j int) bool { return plan.Deferred[i].Path < plan.Deferred[j].Path })
+
+	if !record {
+		e.pausedMu.Unlock()
+		return
+	}
+	// Paths that are no longer deletion candidates (restored or deleted) drop out
+	e.missingSince = current
+	persist := make([]database.MissingPath, 0, len(current))
+	for p, entry := range current {
+		persist = append(persist, database.MissingPath{Path: p, FirstMissing: entry.first.Unix(), Scans: entry.scans})
+	}
+	e.pausedMu.Unlock()
+
+	if err := database.SaveMissingPaths(e.config.ID, persist); err != nil {
+		e.logger().Error("Failed to save deferred deletions", "error", err)
+	}
+	if len(plan.Deferred) > 0 {
+		e.logger().Info("Deferred deletions until they have been missing long enough", "deferred", len(plan.Deferred),
+			"scans", e.config.DeleteDeferScans, "age", e.config.DeleteDeferAge.String())
+	}
+}
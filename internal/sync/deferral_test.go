@@ -0,0 +1,78 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEngine_DeleteDeferral(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(sourceDir, "keep.txt"), []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(targetDir, "keep.txt"), []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	deletePath := filepath.Join(targetDir, "gone.txt")
+	if err := os.WriteFile(deletePath, []byte("gone"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := NewEngine(SyncConfig{
+		ID:                   "test-defer",
+		SourceDir:            sourceDir,
+		TargetDir:            targetDir,
+		Rule:                 "flat",
+		AutoApproveDeletions: true,
+		DeleteDeferScans:     2,
+	})
+
+	// Preview shows the deferred deletion without counting as a scan
+	plan, err := engine.PreviewSync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.FilesToDelete) != 0 || len(plan.Deferred) != 1 || plan.Deferred[0].Path != "gone.txt" {
+		t.Fatalf("Expected gone.txt to be deferred in preview, got deletes=%v deferred=%+v", plan.FilesToDelete, plan.Deferred)
+	}
+
+	// First scan: missing once, deletion deferred
+	if err := engine.RunSync(nil); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	if _, err := os.Stat(deletePath); err != nil {
+		t.Fatal("File was deleted before the deferral window passed")
+	}
+
+	// Second consecutive scan: deletion goes ahead
+	if err := engine.RunSync(nil); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	if _, err := os.Stat(deletePath); !os.IsNotExist(err) {
+		t.Fatal("File should be deleted after two consecutive scans")
+	}
+}
+
+func TestDeferDeletions_ResetsWhenPathReturns(t *testing.T) {
+	engine := NewEngine(SyncConfig{ID: "test-defer-reset", DeleteDeferAge: time.Hour})
+
+	engine.deferDeletions(&SyncPlan{FilesToDelete: []string{"a.mkv"}}, true)
+	first := engine.missingSince["a.mkv"].first
+
+	// a.mkv reappeared on the source, so it is no longer a candidate
+	engine.deferDeletions(&SyncPlan{FilesToDelete: []string{}}, true)
+	if _, ok := engine.missingSince["a.mkv"]; ok {
+		t.Fatal("Tracking should be dropped once the path is no longer missing")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	plan := &SyncPlan{FilesToDelete: []string{"a.mkv"}}
+	engine.deferDeletions(plan, true)
+	if len(plan.Deferred) != 1 || !plan.Deferred[0].FirstMissing.After(first) || plan.Deferred[0].Scans != 1 {
+		t.Errorf("Expected a fresh deferral window, got %+v", plan.Deferred)
+	}
+}
@@ -1,89 +1,252 @@
 package sync
 
 import (
+	"path"
 	"path/filepath"
 	"sort"
+	"strings"
 )
 
-// identifyDeletions implements smart deletion logic
-// Only deletes from receiver directories that originated from sender
-func identifyDeletions(sender, receiver *Manifest, rule string) (filesToDelete, dirsToDelete []string) {
-	filesToDelete = make([]string, 0)
-	dirsToDelete = make([]string, 0)
+// isManaged checks if a path should be handled by the sync process.
+// A path is "managed" only if its parent hierarchy exists on the sender.
+// Additionally, a directory itself is only managed if it exists on the sender.
+// This "Smart Deletion" protects receiver-only folders from being deleted.
+// When the path is not managed, the returned string names the missing sender directory.
+func isManaged(sender *Manifest, path string, isDir bool) (bool, string) {
+	curr := filepath.ToSlash(path)
 
-	// isManaged checks if a path should be handled by the sync process.
-	// A path is "managed" only if its parent hierarchy exists on the sender.
-	// Additionally, a directory itself is only managed if it exists on the sender.
-	// This "Smart Deletion" protects receiver-only folders from being deleted.
-	isManaged := func(path string, isDir bool) bool {
-		curr := filepath.ToSlash(path)
-
-		// If it's a directory, the directory itself must exist on the sender to be considered managed.
-		// If it doesn't exist on the sender, we ignore it (and its contents).
-		if isDir {
-			if _, exists := sender.GetDir(curr); !exists {
-				return false
-			}
+	// If it's a directory, the directory itself must exist on the sender to be considered managed.
+	// If it doesn't exist on the sender, we ignore it (and its contents).
+	if isDir {
+		if _, exists := sender.GetDir(curr); !exists {
+			return false, curr
 		}
+	}
 
-		// All parent directory components must also exist on the sender.
-		for {
-			curr = filepath.Dir(curr)
-			if curr == "." || curr == "/" || curr == "" {
-				break
-			}
-			// Normalize for lookup
-			lookup := filepath.ToSlash(curr)
-			if _, exists := sender.GetDir(lookup); !exists {
-				return false
+	// All parent directory components must also exist on the sender.
+	for {
+		curr = filepath.Dir(curr)
+		if curr == "." || curr == "/" || curr == "" {
+			break
+		}
+		// Normalize for lookup
+		lookup := filepath.ToSlash(curr)
+		if _, exists := sender.GetDir(lookup); !exists {
+			return false, lookup
+		}
+	}
+	return true, ""
+}
+
+// deletionProtection returns why a receiver-only entry must not be deleted,
+// or an empty string if it is a deletion candidate. Both ends are judged on
+// the view the include patterns leave, like the sender's scan.
+func deletionProtection(sender *Manifest, path string, isDir bool, opts CompareOptions) string {
+	if !isDir {
+		if _, included := includeRule(opts.Include, path); !included {
+			return "matches no include pattern (not synced)"
+		}
+	}
+	if isDir && opts.Rule == "flat" && opts.FlatDeleteDirs && deletedDir(sender, opts.Base, path) {
+		return ""
+	}
+	if managed, missing := isManaged(sender, path, isDir); !managed {
+		return "receiver-only folder " + missing + " does not exist on the source (protected archive)"
+	}
+	if isDir {
+		// Don't delete directories in "flat" mode
+		if opts.Rule == "flat" {
+			return "directories are never deleted with the flat rule"
+		}
+		return ""
+	}
+
+	// Safety Check for Subdirectories:
+	// If the file is in a subdirectory (not root), check if that subdirectory exists and is empty on the sender.
+	// If the sender has the directory but zero files in it, we assume it's a "protected empty folder" (e.g. season folder)
+	// and do NOT delete the receiver's files within it.
+	parent := filepath.ToSlash(filepath.Dir(path))
+
+	// Only apply protection if we are not at root
+	if parent != "." && parent != "/" {
+		// Check if parent dir exists in sender (it should, based on isManaged, but let's be double sure)
+		if _, dirExists := sender.GetDir(parent); dirExists {
+			// Check if sender has any files in this directory
+			if sender.GetFileCountInDir(parent) == 0 {
+				// Sender has the folder but no files -> Prevent deletion of receiver contents
+				return "source folder " + parent + " exists but is empty (protected empty folder)"
 			}
 		}
+	}
+	return ""
+}
+
+// deletedDir reports whether the receiver directory path was in sync before
+// and is gone from the source while its parent is still there. Only the top
+// of a deleted tree qualifies; removing it takes everything below along.
+func deletedDir(sender, base *Manifest, path string) bool {
+	if base == nil || !base.HasDir(path) {
+		return false
+	}
+	if _, exists := sender.GetDir(path); exists {
+		return false
+	}
+	parent := filepath.ToSlash(filepath.Dir(path))
+	if parent == "." || parent == "/" {
 		return true
 	}
+	_, exists := sender.GetDir(parent)
+	return exists
+}
+
+// protectedBelow returns the never-delete pattern of the first receiver entry
+// inside dir, as deleting dir would take it along
+func protectedBelow(receiver *Manifest, dir string, patterns []string) (string, bool) {
+	if len(patterns) == 0 {
+		return "", false
+	}
+	receiver.mu.RLock()
+	defer receiver.mu.RUnlock()
+	for path := range receiver.Files {
+		if strings.HasPrefix(path, dir+"/") {
+			if pattern, ok := matchProtected(patterns, path); ok {
+				return pattern, true
+			}
+		}
+	}
+	return "", false
+}
+
+// identifyDeletions implements smart deletion logic
+// Only deletes from receiver directories that originated from sender.
+// Candidates matching a never-delete pattern are returned as violations instead.
+func identifyDeletions(sender, receiver *Manifest, opts CompareOptions) (filesToDelete, dirsToDelete []string, protected []*ProtectedViolation) {
+	filesToDelete = make([]string, 0)
+	dirsToDelete = make([]string, 0)
+	protected = make([]*ProtectedViolation, 0)
 
 	for path, receiverFile := range receiver.Files {
-		// Skip if the path is not managed by the sender
-		if !isManaged(path, receiverFile.IsDir) {
+		// If the item exists on the sender, there is nothing to delete
+		if receiverFile.IsDir {
+			if _, exists := sender.GetDir(path); exists {
+				continue
+			}
+		} else if _, exists := sender.GetFile(path); exists {
+			continue
+		}
+
+		// Skip if the path is not managed by the sender or otherwise protected
+		if deletionProtection(sender, path, receiverFile.IsDir, opts) != "" {
+			continue
+		}
+
+		// Explicit never-delete patterns win over everything else
+		pattern, ok := matchProtected(opts.NeverDelete, path)
+		if !ok && receiverFile.IsDir {
+			pattern, ok = protectedBelow(receiver, path, opts.NeverDelete)
+		}
+		if ok {
+			protected = append(protected, &ProtectedViolation{Path: path, Action: "delete", Pattern: pattern})
 			continue
 		}
 
-		// If the item doesn't exist on the sender, it's a candidate for deletion
 		if receiverFile.IsDir {
-			if _, exists := sender.GetDir(path); !exists {
-				// Don't delete directories in "flat" mode
-				if rule != "flat" {
-					dirsToDelete = append(dirsToDelete, path)
-				}
-			}
+			dirsToDelete = append(dirsToDelete, path)
 		} else {
-			if _, exists := sender.GetFile(path); !exists {
-				// Safety Check for Subdirectories:
-				// If the file is in a subdirectory (not root), check if that subdirectory exists and is empty on the sender.
-				// If the sender has the directory but zero files in it, we assume it's a "protected empty folder" (e.g. season folder)
-				// and do NOT delete the receiver's files within it.
-				parent := filepath.Dir(path)
-				parent = filepath.ToSlash(parent)
-
-				// Only apply protection if we are not at root
-				if parent != "." && parent != "/" {
-					// Check if parent dir exists in sender (it should, based on isManaged, but let's be double sure)
-					if _, dirExists := sender.GetDir(parent); dirExists {
-						// Check if sender has any files in this directory
-						if sender.GetFileCountInDir(parent) == 0 {
-							// Sender has the folder but no files -> Prevent deletion of receiver contents
-							continue
-						}
-					}
-				}
+			filesToDelete = append(filesToDelete, path)
+		}
+	}
 
+	// A deleted directory takes its contents along: plan them too, so the
+	// execution phase can tell them from content it never saw
+	if len(dirsToDelete) > 0 {
+		planned := make(map[string]bool, len(filesToDelete)+len(dirsToDelete))
+		for _, p := range filesToDelete {
+			planned[p] = true
+		}
+		for _, p := range dirsToDelete {
+			planned[p] = true
+		}
+		for path, receiverFile := range receiver.Files {
+			if planned[path] || !inTrees(path, dirsToDelete) {
+				continue
+			}
+			if receiverFile.IsDir {
+				dirsToDelete = append(dirsToDelete, path)
+			} else {
 				filesToDelete = append(filesToDelete, path)
 			}
 		}
 	}
 
-	// Sort directories to ensure consistent deletion order (lexicographical)
-	// The execution phase iterates backwards to delete leaf dirs first.
-	sort.Strings(dirsToDelete)
+	// Directories are deleted deepest first, so children go before their parents
+	sortDeepestFirst(dirsToDelete)
+	sort.Slice(protected, func(i, j int) bool { return protected[i].Path < protected[j].Path })
 
-	return filesToDelete, dirsToDelete
+	return filesToDelete, dirsToDelete, protected
+}
+
+// sortDeepestFirst orders directories by depth, deepest first, and
+// lexicographically within a depth
+func sortDeepestFirst(dirs []string) {
+	sort.Slice(dirs, func(i, j int) bool {
+		di, dj := strings.Count(dirs[i], "/"), strings.Count(dirs[j], "/")
+		if di != dj {
+			return di > dj
+		}
+		return dirs[i] < dirs[j]
+	})
+}
+
+// unplannedChild returns an entry of target below dir that is not planned
+// for deletion, and whether dir has any entries left at all. Entries in or
+// below the directories gone are removed before dir and not left.
+func unplannedChild(target *Manifest, dir string, planned, gone map[string]bool) (child string, remaining bool) {
+	target.mu.RLock()
+	defer target.mu.RUnlock()
+	for p := range target.Files {
+		if !strings.HasPrefix(p, dir+"/") {
+			continue
+		}
+		if !planned[p] {
+			return p, true
+		}
+		if !remaining && len(gone) > 0 {
+			left := true
+			for cur := p; cur != dir; cur = path.Dir(cur) {
+				if gone[cur] {
+					left = false
+					break
+				}
+			}
+			remaining = left
+			continue
+		}
+		remaining = true
+	}
+	return "", remaining
+}
+
+// deletionShare returns the percentage of target files and bytes the plan would delete
+func deletionShare(plan *SyncPlan, target *Manifest) (filePct, bytePct float64) {
+	var totalFiles, totalBytes, delBytes int64
+	for _, f := range target.Files {
+		if f.IsDir {
+			continue
+		}
+		totalFiles++
+		totalBytes += f.Size
+	}
+	for _, p := range plan.FilesToDelete {
+		if f, ok := target.Files[p]; ok {
+			delBytes += f.Size
+		}
+	}
+	if totalFiles > 0 {
+		filePct = float64(len(plan.FilesToDelete)) * 100 / float64(totalFiles)
+	}
+	if totalBytes > 0 {
+		bytePct = float64(delBytes) * 100 / float64(totalBytes)
+	}
+	return filePct, bytePct
 }
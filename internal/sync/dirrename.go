@@ -0,0 +1,153 @@
+package sync
+
+import (
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// detectDirRenames turns a target directory that reappears under a new name
+// on the source into one rename instead of transferring its files again and
+// deleting the old copy. Both directories must hold the same files (names,
+// sizes, modification times and hashes where known, on the include-filtered
+// view) and match no other directory.
+func (p *SyncPlan) detectDirRenames(sender, receiver *Manifest, opts CompareOptions) {
+	if len(p.DirsToCreate) == 0 {
+		return
+	}
+
+	// New source directories: the tops of the directories to create
+	creating := make(map[string]bool, len(p.DirsToCreate))
+	for _, d := range p.DirsToCreate {
+		creating[d] = true
+	}
+	newDirs := make(map[string]bool)
+	for _, d := range p.DirsToCreate {
+		if !creating[path.Dir(d)] {
+			newDirs[d] = true
+		}
+	}
+
+	// Old target directories: the tops of the directories planned for
+	// deletion, so directories the rule keeps (flat) are never moved
+	oldDirs := make(map[string]bool)
+	for _, d := range p.DirsToDelete {
+		if _, exists := sender.GetDir(d); exists {
+			continue
+		}
+		if parent := path.Dir(d); parent != "." {
+			if _, exists := sender.GetDir(parent); !exists {
+				continue
+			}
+		}
+		if opts.Base != nil && !opts.Base.HasDir(d) {
+			continue // Added on the target, not renamed on the source
+		}
+		if _, ok := matchProtected(opts.NeverDelete, d); ok {
+			continue
+		}
+		if _, ok := protectedBelow(receiver, d, opts.NeverDelete); ok {
+			continue
+		}
+		oldDirs[d] = true
+	}
+	if len(oldDirs) == 0 {
+		return
+	}
+
+	bySignature := make(map[string][]string)
+	for d, sig := range treeSignatures(receiver, oldDirs, opts.Include) {
+		bySignature[sig] = append(bySignature[sig], d)
+	}
+	renamed := make(map[string]string)
+	taken := make(map[string]int)
+	for d, sig := range treeSignatures(sender, newDirs, opts.Include) {
+		if old := bySignature[sig]; len(old) == 1 {
+			renamed[old[0]] = d
+			taken[old[0]]++
+		}
+	}
+	for oldDir, newDir := range renamed {
+		if taken[oldDir] > 1 {
+			continue // Several new directories look the same
+		}
+		p.Renames[oldDir] = newDir
+		p.dropTree(oldDir, newDir)
+	}
+}
+
+// dropTree removes what a directory rename makes unnecessary: transfers and
+// mkdirs below newDir, deletions of and below oldDir
+func (p *SyncPlan) dropTree(oldDir, newDir string) {
+	in := func(pth, dir string) bool { return pth == dir || strings.HasPrefix(pth, dir+"/") }
+
+	syncs := p.FilesToSync[:0]
+	for _, f := range p.FilesToSync {
+		if !in(f.Path, newDir) {
+			syncs = append(syncs, f)
+		}
+	}
+	p.FilesToSync = syncs
+	mkdirs := p.DirsToCreate[:0]
+	for _, d := range p.DirsToCreate {
+		if !in(d, newDir) {
+			mkdirs = append(mkdirs, d)
+		}
+	}
+	p.DirsToCreate = mkdirs
+	files := p.FilesToDelete[:0]
+	for _, f := range p.FilesToDelete {
+		if !in(f, oldDir) {
+			files = append(files, f)
+		}
+	}
+	p.FilesToDelete = files
+	dirs := p.DirsToDelete[:0]
+	for _, d := range p.DirsToDelete {
+		if !in(d, oldDir) {
+			dirs = append(dirs, d)
+		}
+	}
+	p.DirsToDelete = dirs
+}
+
+// treeSignatures describes the contents of each of the directories tops
+// relative to it. Directories without included files get no signature.
+func treeSignatures(m *Manifest, tops map[string]bool, include []string) map[string]string {
+	entries := make(map[string][]string)
+	hasFiles := make(map[string]bool)
+	m.mu.RLock()
+	for p, f := range m.Files {
+		top := ""
+		for cur := path.Dir(p); cur != "." && cur != "/"; cur = path.Dir(cur) {
+			if tops[cur] {
+				top = cur
+			}
+		}
+		if top == "" {
+			continue
+		}
+		rel := strings.TrimPrefix(p, top+"/")
+		if f.IsDir {
+			entries[top] = append(entries[top], rel+"/")
+			continue
+		}
+		if _, ok := includeRule(include, p); !ok {
+			continue
+		}
+		hasFiles[top] = true
+		entries[top] = append(entries[top], rel+"\x00"+strconv.FormatInt(f.Size, 10)+"\x00"+strconv.FormatInt(f.ModTime.Unix(), 10)+"\x00"+f.Hash)
+	}
+	m.mu.RUnlock()
+
+	sigs := make(map[string]string, len(entries))
+	for top, list := range entries {
+		if !hasFiles[top] {
+			continue
+		}
+		sort.Strings(list)
+		sigs[top] = strings.Join(list, "\n")
+	}
+	return sigs
+}
@@ -0,0 +1,34 @@
+//go:build !windows
+
+package sync
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// DetectDiskGroup returns a lock group name derived from the device that
+// holds path, so engines on the same physical disk share a group.
+func DetectDiskGroup(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("disk-dev%d", uint64(st.Dev))
+}
+
+// DiskUsage returns the used and total bytes of the filesystem holding path
+func DiskUsage(path string) (used, total uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	total = uint64(st.Blocks) * uint64(st.Bsize)
+	free := uint64(st.Bavail) * uint64(st.Bsize)
+	return total - free, total, nil
+}
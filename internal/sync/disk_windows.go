@@ -0,0 +1,37 @@
+//go:build windows
+
+package sync
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// DetectDiskGroup returns a lock group name derived from the volume that
+// holds path, so engines on the same drive share a group.
+func DetectDiskGroup(path string) string {
+	vol := filepath.VolumeName(path)
+	if vol == "" {
+		return ""
+	}
+	return "disk-" + strings.ToLower(strings.TrimSuffix(vol, ":"))
+}
+
+// DiskUsage returns the used and total bytes of the volume holding path
+func DiskUsage(path string) (used, total uint64, err error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	var free uint64
+	r, _, callErr := procGetDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(&free)), uintptr(unsafe.Pointer(&total)), 0)
+	if r == 0 {
+		return 0, 0, callErr
+	}
+	return total - free, total, nil
+}
@@ -0,0 +1,58 @@
+package sync
+
+import "slices"
+
+// Actions that can be kept in dry run on their own (SyncConfig.DryRunActions)
+const (
+	ActionAdd    = "add"    // Transfers and directories created on the target
+	ActionRename = "rename" // Renames on the target
+	ActionDelete = "delete" // Deletions from the target
+	ActionMove   = "move"   // Source removal of the move rule and eviction
+)
+
+// DryRunActions lists the actions that can be kept in dry run
+var DryRunActions = []string{ActionAdd, ActionRename, ActionDelete, ActionMove}
+
+// dryRunFor reports whether action is only logged this cycle: the whole
+// engine is in dry run or the action is kept in dry run
+func (e *Engine) dryRunFor(action string) bool {
+	return e.isDryRun() || e.keepsDry(action)
+}
+
+// keepsDry reports whether DryRunActions keeps action in dry run
+func (e *Engine) keepsDry(action string) bool {
+	return slices.Contains(e.config.DryRunActions, action)
+}
+
+// livePlan is plan without the actions DryRunActions keeps in dry run, what
+// a cycle outside the dry sync mode applies to the target and may need
+// approval for
+func (e *Engine) livePlan(plan *SyncPlan) *SyncPlan {
+	live := *plan
+	if e.keepsDry(ActionAdd) {
+		live.FilesToSync, live.DirsToCreate, live.ParityDirs = nil, nil, nil
+	}
+	if e.keepsDry(ActionRename) {
+		live.Renames = map[string]string{}
+	}
+	if e.keepsDry(ActionDelete) {
+		live.FilesToDelete, live.DirsToDelete = nil, nil
+	}
+	return &live
+}
+
+// withDryRun is live with the actions kept in dry run added back from full,
+// so the cycle logs them
+func (e *Engine) withDryRun(live, full *SyncPlan) *SyncPlan {
+	p := *live
+	if e.keepsDry(ActionAdd) {
+		p.FilesToSync, p.DirsToCreate, p.ParityDirs = full.FilesToSync, full.DirsToCreate, full.ParityDirs
+	}
+	if e.keepsDry(ActionRename) {
+		p.Renames = full.Renames
+	}
+	if e.keepsDry(ActionDelete) {
+		p.FilesToDelete, p.DirsToDelete = full.FilesToDelete, full.DirsToDelete
+	}
+	return &p
+}
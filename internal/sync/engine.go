@@ -3,11 +3,11 @@ package sync
 import (
 	"encoding/json"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
 	"path/filepath"
 	"strings"
 	stdsync "sync"
+	"sync/atomic"
 	"time"
 
 	"schnorarr/internal/monitor/database"
@@ -25,11 +25,22 @@ type Engine struct {
 	stopCh             chan struct{}
 	pausedMu           stdsync.RWMutex
 	paused             bool
+	startQueued        bool // Waiting for an initial scan slot
 	lastSyncTime       time.Time
-	lastSourceManifest *Manifest // Cached source manifest for quick polling comparison
+	lastSourceManifest *CompactManifest // Cached source manifest for quick polling comparison
 	syncMu             stdsync.Mutex
-	syncQueued         bool      // True if a sync is requested while one is running
-	queuedManifest     *Manifest // Store provided manifest for the queued run
+	syncQueued         bool             // True if a sync is requested while one is running
+	queuedManifest     *CompactManifest // Store provided manifest for the queued run
+	lastTarget         *CompactManifest // Target as the last cycle left it, to spot changes made outside of sync
+	lastSynced         *CompactManifest // Paths last seen in sync on both ends, the base of the three-way comparison
+	pendingTarget      string           // Target on another receiver host, applied at the next cycle
+
+	// ID of the running sync cycle (string), empty between cycles
+	cycleID atomic.Value
+	// Bytes actually transferred during the running cycle
+	cycleBytes atomic.Int64
+	// Set once a directory rename failed; later plans transfer instead
+	dirRenamesOff atomic.Bool
 
 	// Progress Tracking
 	currentSpeed       int64
@@ -42,6 +53,8 @@ type Engine struct {
 	lastLogBytes       int64
 	planRemainingBytes int64 // Sum of sizes of files in current plan yet to complete
 	isScanning         bool
+	phase              *PhaseProgress // Renames or deletions running, nil otherwise
+	phaseLogTime       time.Time
 
 	// Transfer Detail Tracking
 	fileStartTime time.Time
@@ -54,17 +67,43 @@ type Engine struct {
 
 	// Deletion Approval Safety Lock
 	pendingDeletions   []string
+	pendingSizes       map[string]int64 // Sizes of the pending paths, from the plan that held them
 	waitingForApproval bool
 	deletionAllowed    bool
 
 	// Retry Delay
 	failedFiles map[string]time.Time
+	// parityFailed holds directories whose parity failed, retried after failedRetryDelay
+	parityFailed map[string]time.Time
+	// quarantined are source versions the scan hook rejected
+	quarantined map[string]quarantineEntry
+
+	// Delete deferral: when each deletion candidate was first seen missing
+	missingSince map[string]*missingEntry
+
+	// Offline queueing: set while the receiver is unreachable
+	offline       bool
+	readOnly      bool // Offline because the receiver is write-protected, not unreachable
+	backlog       *offlineBacklog
+	probeReceiver func() error // Overrides the receiver health check (tests)
+
+	// Integrity: the target seen by the last cycle and the last receiver report
+	expectedTarget *TargetSummary
+	integrity      *IntegrityResult
+
+	// Consecutive target errors and the FAILED state they trip
+	breaker circuitBreaker
+
+	// Watch limit fallback
+	watchLimitHit bool
+	pollSubtrees  []string // Subtrees polled because inotify watches ran out
 }
 
 // NewEngine creates a new sync engine
 func NewEngine(config SyncConfig) *Engine {
 	scanner := NewScanner()
-	scanner.ExcludePatterns = config.ExcludePatterns
+	scanner.ExcludePatterns = append(append([]string{}, config.ExcludePatterns...), TrashDirName, QuarantineDirName)
+	scanner.ExcludePatterns = append(scanner.ExcludePatterns, generatedPatterns(config)...)
 	scanner.IncludePatterns = config.IncludePatterns
 
 	e := &Engine{
@@ -74,13 +113,23 @@ func NewEngine(config SyncConfig) *Engine {
 		alias:        database.GetSetting("alias_"+config.ID, "Engine #"+config.ID),
 		speedHistory: make([]int64, 60),
 		failedFiles:  make(map[string]time.Time),
+		parityFailed: make(map[string]time.Time),
+		missingSince: make(map[string]*missingEntry),
 	}
+	scanner.Logger = func() *slog.Logger { return e.tagged(scanLog) }
 
 	transferer := NewTransferer(TransferOptions{
 		BandwidthLimit: config.BandwidthLimit,
+		IOClass:        config.IOClass,
+		IOLevel:        config.IOLevel,
+		NumStreams:     config.NumStreams,
+		ChunkSize:      config.ChunkSize,
+		Compress:       config.Compress,
+		MirrorDirs:     config.MirrorDirs,
 		CheckPaused: func() bool {
 			return e.IsPaused()
 		},
+		Logger: func() *slog.Logger { return e.tagged(transferLog) },
 		OnProgress: func(path string, bytesTransferred, totalBytes int64) {
 			e.pausedMu.Lock()
 			if e.currentFile != path {
@@ -128,12 +177,7 @@ func NewEngine(config SyncConfig) *Engine {
 				e.lastLogTime = now
 				e.lastLogBytes = bytesTransferred
 			}
-			id := e.config.ID
-			e.pausedMu.Unlock() // Release lock before DB op and logging
-
-			if diff > 0 {
-				_ = database.AddTraffic(id, diff)
-			}
+			e.pausedMu.Unlock() // Release lock before logging
 
 			if shouldLog {
 				percent := 0.0
@@ -141,9 +185,14 @@ func NewEngine(config SyncConfig) *Engine {
 					percent = float64(bytesTransferred) / float64(totalBytes) * 100
 				}
 				speedStr := database.FormatBytes(speed) // Use local speed var
-				log.Printf("[%s] Syncing %s: %.1f%% (%s/s)", id, filepath.Base(path), percent, speedStr)
+				e.logger().Info("Transfer progress", "file", filepath.Base(path), "percent", fmt.Sprintf("%.1f", percent), "speed", speedStr+"/s")
 			}
 		},
+		OnTransferred: func(bytes int64) {
+			// Traffic counts bytes moved, not file sizes, so resumed and retried transfers are accurate
+			_ = database.AddTraffic(e.config.ID, bytes)
+			e.cycleBytes.Add(bytes)
+		},
 		OnComplete: func(path string, size int64, err error) {
 			e.pausedMu.Lock()
 			defer e.pausedMu.Unlock()
@@ -156,7 +205,13 @@ func NewEngine(config SyncConfig) *Engine {
 		},
 	})
 
+	if config.ScanCommand != "" {
+		transferer.opts.BeforePublish = e.beforePublish
+	}
 	e.transferer = transferer
+	if config.AutoTune {
+		e.loadTuning()
+	}
 	e.LoadState()
 	return e
 }
@@ -164,7 +219,7 @@ func NewEngine(config SyncConfig) *Engine {
 func (e *Engine) LoadState() {
 	state, err := database.LoadEngineState(e.config.ID)
 	if err != nil {
-		log.Printf("[%s] Failed to load engine state: %v", e.config.ID, err)
+		e.logger().Error("Failed to load engine state", "error", err)
 		return
 	}
 
@@ -173,18 +228,51 @@ func (e *Engine) LoadState() {
 	e.pendingDeletions = state.PendingDeletions
 	e.pausedMu.Unlock()
 
+	if e.deleteDeferralEnabled() {
+		e.loadMissingPaths()
+	}
+	if e.IsRemoteScan() {
+		e.loadBacklog()
+	}
+	e.loadTargetCache()
+	e.loadSyncedManifest()
+
 	// Handle queued sync if any
-	jsonStr, err := database.LoadEngineQueue(e.config.ID)
-	if err == nil && jsonStr != "" {
+	jsonStr, data, err := database.LoadEngineQueue(e.config.ID)
+	if err != nil {
+		return
+	}
+	var queued *CompactManifest
+	if len(data) > 0 {
+		queued, err = DecodeCompactManifest(data)
+	} else if jsonStr != "" {
 		var m Manifest
-		if err := json.Unmarshal([]byte(jsonStr), &m); err == nil {
-			e.pausedMu.Lock()
-			e.syncQueued = true
-			e.queuedManifest = &m
-			e.pausedMu.Unlock()
-			log.Printf("[%s] Restored queued sync from persistence", e.config.ID)
+		if err = json.Unmarshal([]byte(jsonStr), &m); err == nil {
+			queued, err = m.Compact()
 		}
 	}
+	if err != nil {
+		e.logger().Warn("Ignoring unreadable queued sync", "error", err)
+		return
+	}
+	if queued != nil {
+		e.pausedMu.Lock()
+		e.syncQueued = true
+		e.queuedManifest = queued
+		e.pausedMu.Unlock()
+		e.logger().Info("Restored queued sync from persistence")
+	}
+}
+
+// compactManifest encodes a manifest the engine keeps between cycles,
+// logging instead of failing since the cache is only an optimization
+func (e *Engine) compactManifest(m *Manifest) *CompactManifest {
+	c, err := m.Compact()
+	if err != nil {
+		e.logger().Warn("Failed to encode manifest", "error", err)
+		return nil
+	}
+	return c
 }
 
 func (e *Engine) savePersistentState() {
@@ -212,6 +300,15 @@ func (e *Engine) SetHealthState(s *health.State) {
 }
 
 func (e *Engine) Start() error {
+	excludeRel, err := ResolvePathOverlap(e.config.SourceDir, e.config.TargetDir)
+	if err != nil {
+		return fmt.Errorf("refusing to start: %w", err)
+	}
+	if excludeRel != "" {
+		e.logger().Warn("Target is inside source, excluding it from scans and watches", "exclude", excludeRel)
+		e.scanner.ExcludePaths = append(e.scanner.ExcludePaths, excludeRel)
+	}
+
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return fmt.Errorf("failed to create watcher: %w", err)
@@ -220,7 +317,7 @@ func (e *Engine) Start() error {
 	if err := e.addWatchRecursive(e.config.SourceDir); err != nil {
 		return fmt.Errorf("failed to add watches: %w", err)
 	}
-	go func() { _ = e.RunSync(nil) }()
+	go e.runInitialSync()
 	go e.watchLoop()
 	if e.config.WatchInterval > 0 {
 		go e.periodicSyncLoop()
@@ -229,7 +326,12 @@ func (e *Engine) Start() error {
 		go e.sourcePollLoop()
 	}
 	go e.failedRetryLoop()
-	log.Printf("Sync engine started: %s -> %s", e.config.SourceDir, e.config.TargetDir)
+	if e.evictionEnabled() {
+		go e.evictionLoop()
+	} else if e.config.EvictAbovePercent > 0 {
+		e.logger().Warn("Free-space eviction requires the move rule, ignoring it", "rule", e.config.Rule)
+	}
+	e.logger().Info("Sync engine started", "source", e.config.SourceDir, "target", e.config.TargetDir)
 	return nil
 }
 
@@ -254,24 +356,42 @@ func (e *Engine) IsBusy() bool {
 	return false
 }
 
+// PreviewSync computes the plan the next sync would run and stores it as the
+// engine's last preview for PreviewDiff
 func (e *Engine) PreviewSync() (*SyncPlan, error) {
-	AcquireScanLock()
-	sourceManifest, err := e.scanner.ScanLocal(e.config.SourceDir)
-	if err != nil {
-		ReleaseScanLock()
-		return nil, fmt.Errorf("failed to scan source: %w", err)
-	}
-
-	targetManifest, err := e.scanner.ScanLocal(e.config.TargetDir)
-	ReleaseScanLock()
+	sourceManifest, targetManifest, err := e.scanManifests()
 	if err != nil {
-		targetManifest = NewManifest(e.config.TargetDir)
+		return nil, err
 	}
 
-	plan := CompareManifests(sourceManifest, targetManifest, e.config.Rule, e.IsRemoteScan())
+	plan := e.comparePlan(sourceManifest, targetManifest)
+	e.deferDeletions(plan, false)
+	e.detectExternalChanges(targetManifest, plan)
+	e.collectSkipped(sourceManifest, targetManifest, plan)
+	e.planParity(sourceManifest, targetManifest, plan)
+	e.savePreview(plan)
 	return plan, nil
 }
 
+// comparePlan builds the sync plan using the engine's rule and protected paths
+func (e *Engine) comparePlan(source, target *Manifest) *SyncPlan {
+	return CompareManifestsWithOptions(source, target, e.compareOptions())
+}
+
+// compareOptions are the engine's settings for comparing manifests
+func (e *Engine) compareOptions() CompareOptions {
+	return CompareOptions{
+		Rule:           e.config.Rule,
+		SkipRenames:    e.IsRemoteScan(),
+		SkipDirRenames: e.dirRenamesOff.Load(),
+		NeverDelete:    e.config.NeverDeletePatterns,
+		Include:        e.config.IncludePatterns,
+		FlatDeleteDirs: e.config.FlatDeleteDirs,
+		Base:           e.syncedBase(),
+		Mtime:          e.mtimeCompare(),
+	}
+}
+
 func (e *Engine) RunSync(sourceManifest *Manifest) error {
 	e.pausedMu.RLock()
 	isPaused := e.paused
@@ -280,17 +400,31 @@ func (e *Engine) RunSync(sourceManifest *Manifest) error {
 	if isPaused {
 		return fmt.Errorf("sync is paused")
 	}
+	if e.breakerBlocks() {
+		return ErrCircuitOpen
+	}
 	if !e.syncMu.TryLock() {
+		var queued *CompactManifest
+		if sourceManifest != nil {
+			queued = e.compactManifest(sourceManifest)
+		}
 		e.pausedMu.Lock()
 		e.syncQueued = true
-		if sourceManifest != nil {
-			e.queuedManifest = sourceManifest
-			_ = database.SaveEngineQueue(e.config.ID, sourceManifest)
+		if queued != nil {
+			e.queuedManifest = queued
+			_ = database.SaveEngineQueue(e.config.ID, queued.Bytes())
 		}
 		e.pausedMu.Unlock()
 		return nil
 	}
+	e.cycleID.Store(newCycleID())
+	e.cycleBytes.Store(0)
+	e.applyPendingTarget()
 	defer func() {
+		if moved := e.cycleBytes.Swap(0); moved > 0 {
+			_ = database.SaveCycleTraffic(e.config.ID, e.CurrentCycle(), moved)
+		}
+		e.cycleID.Store("")
 		e.syncMu.Unlock()
 		e.pausedMu.Lock()
 		wasQueued := e.syncQueued
@@ -301,13 +435,22 @@ func (e *Engine) RunSync(sourceManifest *Manifest) error {
 		e.pausedMu.Unlock()
 		if wasQueued {
 			time.Sleep(1 * time.Second)
-			go func() { _ = e.RunSync(nextManifest) }()
+			go func() {
+				var m *Manifest
+				if nextManifest != nil {
+					var err error
+					if m, err = nextManifest.Expand(); err != nil {
+						e.logger().Warn("Queued manifest unreadable, rescanning source", "error", err)
+					}
+				}
+				_ = e.RunSync(m)
+			}()
 		}
 	}()
 
 	start := time.Now()
 	if sourceManifest == nil {
-		AcquireScanLock()
+		AcquireScanLockFor(e.config.LockGroup)
 		e.pausedMu.Lock()
 		e.isScanning = true
 		e.pausedMu.Unlock()
@@ -316,37 +459,91 @@ func (e *Engine) RunSync(sourceManifest *Manifest) error {
 		e.pausedMu.Lock()
 		e.isScanning = false
 		e.pausedMu.Unlock()
-		ReleaseScanLock()
+		ReleaseScanLockFor(e.config.LockGroup)
 		if err != nil {
 			return fmt.Errorf("failed to scan source: %w", err)
 		}
 	}
 
-	AcquireScanLock()
+	if wake := e.config.WakeReceiver; wake != nil && e.IsRemoteScan() {
+		if err := wake(); err != nil {
+			e.logger().Warn("Receiver did not wake up", "error", err)
+		}
+	}
+
+	// While the receiver is offline only the backlog is updated; the probe
+	// loop runs one catch-up cycle once it is reachable again
+	if e.IsOffline() {
+		e.recordBacklog(sourceManifest)
+		return nil
+	}
+	// A write-protected receiver pauses the engine the same way, without errors
+	if e.pauseIfReadOnly(sourceManifest) {
+		return nil
+	}
+
+	AcquireScanLockFor(e.config.LockGroup)
 	targetManifest, err := e.scanner.ScanLocal(e.config.TargetDir)
-	ReleaseScanLock()
+	ReleaseScanLockFor(e.config.LockGroup)
+	targetScanned := err == nil
 	if err != nil {
+		if e.receiverLost(err, sourceManifest) {
+			return fmt.Errorf("receiver offline: %w", err)
+		}
 		targetManifest = NewManifest(e.config.TargetDir)
+	} else {
+		e.expectTarget(targetManifest)
 	}
+	e.dropGeneratedFiles(targetManifest)
 
-	plan := CompareManifests(sourceManifest, targetManifest, e.config.Rule, e.IsRemoteScan())
+	plan := e.comparePlan(sourceManifest, targetManifest)
+	e.deferDeletions(plan, true)
+	if targetScanned {
+		e.detectExternalChanges(targetManifest, plan)
+		e.logExternalChanges(plan)
+	}
+	for _, v := range plan.Protected {
+		e.logger().Info("Protected: not deleting", "path", v.Path, "pattern", v.Pattern)
+	}
+
+	if targetScanned {
+		e.planParity(sourceManifest, targetManifest, plan)
+	}
 
-	if len(plan.FilesToSync) == 0 && len(plan.FilesToDelete) == 0 && len(plan.Renames) == 0 && len(plan.DirsToCreate) == 0 && len(plan.DirsToDelete) == 0 {
+	if len(plan.FilesToSync) == 0 && len(plan.FilesToDelete) == 0 && len(plan.Renames) == 0 && len(plan.DirsToCreate) == 0 && len(plan.DirsToDelete) == 0 && len(plan.ParityDirs) == 0 {
+		compact := e.compactManifest(sourceManifest)
 		e.pausedMu.Lock()
 		e.lastSyncTime = time.Now()
-		e.lastSourceManifest = sourceManifest
+		e.lastSourceManifest = compact
 		e.pausedMu.Unlock()
+		e.finishCatchUp()
+		e.probeSucceeded()
+		if targetScanned {
+			e.rememberTarget(sourceManifest, targetManifest, plan, false, start)
+		}
 		// Clear persistent state on clean sync
 		_ = database.SaveEngineState(e.config.ID, false, nil, nil)
+		// Everything is mirrored: files may still be due for removal from the source
+		if e.config.Rule == RuleMove {
+			isDry := e.dryRunFor(ActionMove)
+			if !isDry {
+				AcquireTransferLockFor(e.config.LockGroup)
+			}
+			e.executeMovePhase(sourceManifest, targetManifest)
+			if !isDry {
+				ReleaseTransferLockFor(e.config.LockGroup)
+			}
+		}
 		return nil
 	}
 
 	// SAFETY CHECK: If source is completely empty but target is not, abort to prevent catastrophic deletion.
 	// This protects against mounted drives falling off or accidental source deletion.
-	if len(sourceManifest.Files) == 0 && len(sourceManifest.Dirs) == 0 {
+	// The move rule never deletes from the target and empties the source by design.
+	if e.config.Rule != RuleMove && len(sourceManifest.Files) == 0 && len(sourceManifest.Dirs) == 0 {
 		if len(targetManifest.Files) > 0 || len(targetManifest.Dirs) > 0 {
 			msg := "Safety Check Failed: Source directory appears empty but target is not. Aborting sync to prevent total data loss."
-			log.Printf("[Engine:%s] %s", e.config.ID, msg)
+			e.logger().Error(msg)
 			database.ReportEngineError(e.config.ID, msg)
 			// We return an error so the engine logs it and retries later, effectively pausing destructive actions.
 			return fmt.Errorf("safety check failed: source is empty but target is not")
@@ -361,15 +558,47 @@ func (e *Engine) RunSync(sourceManifest *Manifest) error {
 	e.planRemainingBytes = totalPlanSize
 	e.pausedMu.Unlock()
 
-	log.Printf("[Engine:%s] Sync cycle started for %s (Rule: %s, Remote: %v)", e.config.ID, e.alias, e.config.Rule, e.IsRemoteScan())
-	log.Printf("[Engine:%s] Sync Plan: %d syncs, %d deletes, %d renames, %d mkdirs, %d conflicts",
-		e.config.ID, len(plan.FilesToSync), len(plan.FilesToDelete), len(plan.Renames), len(plan.DirsToCreate), len(plan.Conflicts))
+	e.logger().Info("Sync cycle started", "alias", e.alias, "rule", e.config.Rule, "remote", e.IsRemoteScan())
+	e.logger().Info("Sync plan", "syncs", len(plan.FilesToSync), "deletes", len(plan.FilesToDelete), "renames", len(plan.Renames),
+		"mkdirs", len(plan.DirsToCreate), "conflicts", len(plan.Conflicts))
 
-	hasChanges := len(plan.FilesToSync) > 0 || len(plan.FilesToDelete) > 0 || len(plan.Renames) > 0 || len(plan.DirsToCreate) > 0
 	syncMode := database.GetSetting("sync_mode", "dry")
+	conflictOverride := healthState == nil || healthState.IsOverrideEnabled()
+
+	// Actions kept in dry run are only logged, so they need no approval
+	fullPlan, dryMode := plan, e.isDryRun()
+	if !dryMode {
+		plan = e.livePlan(fullPlan)
+	}
 
 	e.pausedMu.Lock()
-	if hasChanges && syncMode == "manual" && !e.deletionAllowed {
+	gate := ""
+	if !e.deletionAllowed {
+		gate = e.approvalGate(plan, targetManifest, syncMode, e.config.AutoApproveDeletions, conflictOverride)
+	}
+	if gate != "" {
+		e.pendingSizes = planSizes(plan, targetManifest)
+	}
+	var limitMsg string
+	if gate != "" && e.config.SplitApproval {
+		// Additions run right away, only the held changes wait for approval
+		if gate == GateDeleteLimit && !e.waitingForApproval {
+			limitMsg = e.deleteLimitMessage(plan, targetManifest)
+		}
+		conflicts := plan.Conflicts
+		if held := splitHeldChanges(plan); len(held) > 0 {
+			e.waitingForApproval = true
+			e.pendingDeletions = held
+			if len(conflicts) > 0 {
+				e.savePersistentStateWithConflicts(conflicts)
+			} else {
+				e.savePersistentState()
+			}
+		}
+		gate = ""
+	}
+	switch gate {
+	case GateManual:
 		e.waitingForApproval = true
 		e.pendingDeletions = nil
 		for _, f := range plan.FilesToSync {
@@ -381,9 +610,9 @@ func (e *Engine) RunSync(sourceManifest *Manifest) error {
 		}
 		e.savePersistentState()
 		e.pausedMu.Unlock()
+		e.holdTarget(sourceManifest, targetManifest, plan, targetScanned)
 		return nil
-	}
-	if len(plan.Conflicts) > 0 && !e.deletionAllowed && healthState != nil && !healthState.IsOverrideEnabled() {
+	case GateConflicts, GateExternal:
 		e.waitingForApproval = true
 		e.pendingDeletions = nil
 		for _, c := range plan.Conflicts {
@@ -391,32 +620,45 @@ func (e *Engine) RunSync(sourceManifest *Manifest) error {
 		}
 		e.savePersistentStateWithConflicts(plan.Conflicts)
 		e.pausedMu.Unlock()
+		e.holdTarget(sourceManifest, targetManifest, plan, targetScanned)
 		return nil
-	}
-	hasDeletions := len(plan.FilesToDelete) > 0 || len(plan.DirsToDelete) > 0
-	autoApprove := e.config.AutoApproveDeletions
-	deletionAllowed := e.deletionAllowed
-	e.pausedMu.Unlock()
-
-	if hasDeletions && !autoApprove && !deletionAllowed {
-		e.pausedMu.Lock()
+	case GateDeleteLimit:
+		msg := e.deleteLimitMessage(plan, targetManifest)
+		alreadyWaiting := e.waitingForApproval
+		e.waitingForApproval = true
+		e.pendingDeletions = append(append([]string{}, plan.FilesToDelete...), plan.DirsToDelete...)
+		e.savePersistentState()
+		e.pausedMu.Unlock()
+		e.holdTarget(sourceManifest, targetManifest, plan, targetScanned)
+		e.logger().Warn(msg)
+		if !alreadyWaiting {
+			e.reportError(fmt.Sprintf("Engine %s: %s", e.config.ID, msg))
+		}
+		return nil
+	case GateDeletions:
 		e.waitingForApproval = true
 		e.pendingDeletions = append(plan.FilesToDelete, plan.DirsToDelete...)
 		e.savePersistentState()
 		e.pausedMu.Unlock()
+		e.holdTarget(sourceManifest, targetManifest, plan, targetScanned)
 		return nil
 	}
 
-	e.pausedMu.Lock() // Re-acquire lock for the following block
 	if e.deletionAllowed {
 		if len(e.pendingDeletions) > 0 {
 			allowed := make(map[string]bool)
 			for _, f := range e.pendingDeletions {
 				allowed[f] = true
 			}
+			// With split approval only the held changes were up for approval
+			additions := e.config.SplitApproval
+			conflicting := make(map[string]bool)
+			for _, c := range plan.Conflicts {
+				conflicting[c.Path] = true
+			}
 			var filteredSyncs []*FileInfo
 			for _, f := range plan.FilesToSync {
-				if allowed[f.Path] {
+				if allowed[f.Path] || (additions && !conflicting[f.Path]) {
 					filteredSyncs = append(filteredSyncs, f)
 				}
 			}
@@ -440,7 +682,7 @@ func (e *Engine) RunSync(sourceManifest *Manifest) error {
 
 			var filteredDirsCreate []string
 			for _, f := range plan.DirsToCreate {
-				if allowed[f] {
+				if allowed[f] || additions {
 					filteredDirsCreate = append(filteredDirsCreate, f)
 				}
 			}
@@ -457,19 +699,34 @@ func (e *Engine) RunSync(sourceManifest *Manifest) error {
 		e.deletionAllowed = false
 		e.waitingForApproval = false
 		e.pendingDeletions = nil
+		e.pendingSizes = nil
 		_ = database.SaveEngineState(e.config.ID, false, nil, nil) // Clear state once approved
 	}
 	e.pausedMu.Unlock()
+	if !dryMode {
+		plan = e.withDryRun(plan, fullPlan)
+	}
+	if limitMsg != "" {
+		e.logger().Warn(limitMsg)
+		e.reportError(fmt.Sprintf("Engine %s: %s", e.config.ID, limitMsg))
+	}
 
 	// Filter out files that failed recently (within last hour)
 	var finalFilesToSync []*FileInfo
+	invalid := e.invalidMediaSet(plan.FilesToSync)
 	e.pausedMu.Lock()
 	for _, f := range plan.FilesToSync {
+		if invalid[f.Path] {
+			continue // Sent again once the source changes
+		}
 		if failTime, exists := e.failedFiles[f.Path]; exists {
-			if time.Since(failTime) < 1*time.Hour {
+			if time.Since(failTime) < failedRetryDelay {
 				continue // Skip for now, will retry later
 			}
 		}
+		if _, rejected := e.quarantinedVersion(f); rejected {
+			continue // Sent again once the source changes
+		}
 		finalFilesToSync = append(finalFilesToSync, f)
 	}
 	plan.FilesToSync = finalFilesToSync
@@ -477,12 +734,16 @@ func (e *Engine) RunSync(sourceManifest *Manifest) error {
 
 	isDry := e.isDryRun()
 	if !isDry {
-		AcquireTransferLock()
-		defer ReleaseTransferLock()
+		AcquireTransferLockFor(e.config.LockGroup)
+		defer ReleaseTransferLockFor(e.config.LockGroup)
+		e.forgetTarget()
 	}
 
 	touchedDirs, err := e.executeSyncPhase(plan, targetManifest)
 	if err != nil {
+		if e.receiverLost(err, sourceManifest) {
+			return fmt.Errorf("receiver offline: %w", err)
+		}
 		database.ReportEngineError(e.config.ID, err.Error())
 		return fmt.Errorf("sync failed: %w", err)
 	}
@@ -490,16 +751,36 @@ func (e *Engine) RunSync(sourceManifest *Manifest) error {
 		database.ReportEngineError(e.config.ID, err.Error())
 		return fmt.Errorf("cleanup failed: %w", err)
 	}
+	if !isDry {
+		live := e.livePlan(plan)
+		e.writeChecksumManifests(sourceManifest, checksumDirs(live))
+		if targetScanned {
+			e.updateParity(sourceManifest, targetManifest, live)
+		}
+		if !e.dryRunFor(ActionAdd) {
+			e.applyDirMetadata(plan.DirsToCreate, touchedDirs)
+		}
+	}
+	if e.config.Rule == RuleMove {
+		e.executeMovePhase(sourceManifest, targetManifest)
+	}
+	e.purgeTrash()
+	if targetScanned {
+		e.rememberTarget(sourceManifest, targetManifest, e.livePlan(plan), !isDry, start)
+	}
 
 	database.ReportEngineSuccess(e.config.ID)
 
+	compact := e.compactManifest(sourceManifest)
 	e.pausedMu.Lock()
 	e.lastSyncTime = time.Now()
-	e.lastSourceManifest = sourceManifest
+	e.lastSourceManifest = compact
 	e.pausedMu.Unlock()
+	e.finishCatchUp()
+	e.probeSucceeded()
 
-	log.Printf("[Engine:%s] Sync completed in %v. Files: %d, Deletes: %d, Renames: %d",
-		e.config.ID, time.Since(start), len(plan.FilesToSync), len(plan.FilesToDelete), len(plan.Renames))
+	e.logger().Info("Sync completed", "duration", time.Since(start).String(), "files", len(plan.FilesToSync),
+		"deletes", len(plan.FilesToDelete), "renames", len(plan.Renames))
 	return nil
 }
 
@@ -512,7 +793,7 @@ func (e *Engine) isDryRun() bool {
 
 func (e *Engine) reportEvent(timestamp, action, path string, size int64) {
 	if e.config.OnSyncEvent != nil {
-		e.config.OnSyncEvent(timestamp, action, path, size)
+		e.config.OnSyncEvent(timestamp, action, path, size, e.CurrentCycle())
 	}
 }
 
@@ -538,6 +819,11 @@ func (e *Engine) watchLoop() {
 		case <-e.stopCh:
 			timer.Stop()
 			return
+		case err, ok := <-e.watcher.Errors:
+			if !ok {
+				return
+			}
+			e.logger().Error("Watcher error", "error", err)
 		case event, ok := <-e.watcher.Events:
 			if !ok {
 				return
@@ -570,23 +856,32 @@ func (e *Engine) sourcePollLoop() {
 			if e.IsPaused() {
 				continue
 			}
-			AcquireScanLock()
+			AcquireScanLockFor(e.config.LockGroup)
 			currentSource, err := e.scanner.ScanLocal(e.config.SourceDir)
-			ReleaseScanLock()
+			ReleaseScanLockFor(e.config.LockGroup)
 			if err != nil {
 				continue
 			}
-			e.pausedMu.Lock()
+			e.pausedMu.RLock()
 			lastSource := e.lastSourceManifest
+			e.pausedMu.RUnlock()
 			if lastSource == nil {
-				e.lastSourceManifest = currentSource
+				compact := e.compactManifest(currentSource)
+				e.pausedMu.Lock()
+				if e.lastSourceManifest == nil {
+					e.lastSourceManifest = compact
+				}
 				e.pausedMu.Unlock()
 				continue
 			}
-			e.pausedMu.Unlock()
 
-			plan := CompareManifests(currentSource, lastSource, e.config.Rule, false)
-			if len(plan.FilesToSync) > 0 || len(plan.FilesToDelete) > 0 || len(plan.DirsToCreate) > 0 || len(plan.DirsToDelete) > 0 || len(plan.Renames) > 0 {
+			// Streaming comparison against the encoded manifest of the last cycle
+			changed, err := lastSource.Differs(currentSource)
+			if err != nil {
+				e.logger().Warn("Failed to compare with cached source manifest", "error", err)
+				changed = true
+			}
+			if changed {
 				go func() { _ = e.RunSync(currentSource) }()
 			}
 		}
@@ -606,7 +901,7 @@ func (e *Engine) failedRetryLoop() {
 			e.pausedMu.RUnlock()
 
 			if hasFailures {
-				log.Printf("[%s] Periodic retry of failed files...", e.config.ID)
+				e.logger().Info("Periodic retry of failed files")
 				go func() { _ = e.RunSync(nil) }()
 			}
 		}
@@ -626,24 +921,6 @@ func (e *Engine) periodicSyncLoop() {
 	}
 }
 
-func (e *Engine) addWatchRecursive(path string) error {
-	return filepath.Walk(path, func(walkPath string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			relPath, _ := filepath.Rel(e.config.SourceDir, walkPath)
-			if e.scanner.shouldExclude(relPath) {
-				return filepath.SkipDir
-			}
-			if err := e.watcher.Add(walkPath); err != nil {
-				return err
-			}
-		}
-		return nil
-	})
-}
-
 func (e *Engine) Pause() { e.pausedMu.Lock(); e.paused = true; e.pausedMu.Unlock() }
 func (e *Engine) Resume() {
 	e.pausedMu.Lock()
@@ -687,12 +964,7 @@ func (e *Engine) GetQueuedStats() (count int, size int64) {
 		return 0, 0
 	}
 	if e.queuedManifest != nil {
-		for _, f := range e.queuedManifest.Files {
-			if !f.IsDir {
-				size += f.Size
-				count++
-			}
-		}
+		count, size = e.queuedManifest.FileCount(), e.queuedManifest.TotalSize()
 	} else {
 		count = 1
 	}
@@ -714,6 +986,8 @@ func (e *Engine) GetStatus() string {
 	status := "Running"
 	if e.paused {
 		status = "Paused"
+	} else if e.startQueued {
+		status = "Queued"
 	}
 	return fmt.Sprintf("[%s] %s: %s -> %s", e.config.ID, status, e.config.SourceDir, e.config.TargetDir)
 }
@@ -766,6 +1040,51 @@ func (e *Engine) GetSpeedHistory() []int64 {
 	return res
 }
 
+// SetTargetHost retargets a remote target to another receiver host with the
+// same module. The switch takes effect when the next sync cycle starts, so a
+// running cycle keeps a consistent target.
+func (e *Engine) SetTargetHost(host string) {
+	e.pausedMu.Lock()
+	defer e.pausedMu.Unlock()
+	if !IsRemotePath(e.config.TargetDir) {
+		return
+	}
+	target := UpdateTargetHost(e.config.TargetDir, host)
+	if target == e.config.TargetDir {
+		e.pendingTarget = ""
+		return
+	}
+	e.pendingTarget = target
+}
+
+// TargetHost returns the receiver host the engine syncs to, including a pending switch
+func (e *Engine) TargetHost() string {
+	e.pausedMu.RLock()
+	defer e.pausedMu.RUnlock()
+	target := e.config.TargetDir
+	if e.pendingTarget != "" {
+		target = e.pendingTarget
+	}
+	host, _ := ParseRemoteDestination(target)
+	return host
+}
+
+// applyPendingTarget switches to a target set by SetTargetHost. Called with syncMu held.
+func (e *Engine) applyPendingTarget() {
+	e.pausedMu.Lock()
+	defer e.pausedMu.Unlock()
+	if e.pendingTarget == "" {
+		return
+	}
+	e.logger().Info("Switching receiver", "from", e.config.TargetDir, "to", e.pendingTarget)
+	e.config.TargetDir = e.pendingTarget
+	e.pendingTarget = ""
+	// The cached target and synced state belong to the previous receiver
+	e.lastTarget, e.lastSynced = nil, nil
+	_ = database.ClearEngineTargetCache(e.config.ID)
+	_ = database.ClearEngineSyncedManifest(e.config.ID)
+}
+
 func (e *Engine) IsRemoteScan() bool {
 	e.pausedMu.RLock()
 	defer e.pausedMu.RUnlock()
@@ -5,6 +5,8 @@ import (
 	"path/filepath"
 	"testing"
 	"time"
+
+	"schnorarr/internal/monitor/logging"
 )
 
 func TestEngine_SafetyLock(t *testing.T) {
@@ -141,6 +143,56 @@ func TestEngine_AutoApproveDeletions(t *testing.T) {
 	}
 }
 
+func TestEngine_SplitApproval(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+
+	deletePath := filepath.Join(targetDir, "to_delete.txt")
+	if err := os.WriteFile(deletePath, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "new.txt"), []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := NewEngine(SyncConfig{ID: "test-split", SourceDir: sourceDir, TargetDir: targetDir, Rule: "flat", SplitApproval: true})
+	if err := engine.RunSync(nil); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+
+	// The addition ran, the deletion waits
+	if _, err := os.Stat(filepath.Join(targetDir, "new.txt")); err != nil {
+		t.Fatal("New file should be copied while the deletion waits for approval")
+	}
+	if _, err := os.Stat(deletePath); err != nil {
+		t.Fatal("File was deleted before approval!")
+	}
+	if !engine.IsWaitingForApproval() {
+		t.Fatal("Engine should be waiting for approval of the deletion")
+	}
+	if pending := engine.GetPendingDeletions(); len(pending) != 1 || pending[0] != "to_delete.txt" {
+		t.Errorf("Expected only to_delete.txt pending, got: %v", pending)
+	}
+
+	// Approving only the held deletion keeps later additions flowing
+	if err := os.WriteFile(filepath.Join(sourceDir, "newer.txt"), []byte("newer"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	engine.pausedMu.Lock()
+	engine.deletionAllowed = true
+	engine.waitingForApproval = false
+	engine.pausedMu.Unlock()
+	if err := engine.RunSync(nil); err != nil {
+		t.Fatalf("RunSync failed after approval: %v", err)
+	}
+	if _, err := os.Stat(deletePath); !os.IsNotExist(err) {
+		t.Fatal("File should have been deleted after approval")
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "newer.txt")); err != nil {
+		t.Fatal("Additions should not need approval")
+	}
+}
+
 func TestEngine_Safety_EmptySource(t *testing.T) {
 	// Setup temp directories
 	sourceDir := t.TempDir()
@@ -238,3 +290,97 @@ func TestEngine_SubdirectorySafety(t *testing.T) {
 		t.Fatal("Protected file in empty source subdirectory was deleted!")
 	}
 }
+
+func TestEngine_MaxDeletePercent(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(sourceDir, "keep.txt"), []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(targetDir, "keep.txt"), []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 2 of 3 target files are missing on the source
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(targetDir, name), []byte("gone"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	engine := NewEngine(SyncConfig{
+		ID:                   "test-max-delete",
+		SourceDir:            sourceDir,
+		TargetDir:            targetDir,
+		Rule:                 "flat",
+		AutoApproveDeletions: true,
+		MaxDeletePercent:     50,
+	})
+
+	if err := engine.RunSync(nil); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	if !engine.IsWaitingForApproval() {
+		t.Fatal("Engine should require approval when the deletion threshold is exceeded")
+	}
+	if pending := engine.GetPendingDeletions(); len(pending) != 2 {
+		t.Errorf("Expected 2 pending deletions, got %v", pending)
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "a.txt")); err != nil {
+		t.Fatal("File was deleted despite exceeding the threshold")
+	}
+
+	// Approval lets the plan through
+	engine.pausedMu.Lock()
+	engine.deletionAllowed = true
+	engine.pausedMu.Unlock()
+	if err := engine.RunSync(nil); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "a.txt")); !os.IsNotExist(err) {
+		t.Error("Approved deletion was not executed")
+	}
+}
+
+func TestEngine_CycleID(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	for _, name := range []string{"a.mkv", "b.mkv"} {
+		if err := os.WriteFile(filepath.Join(sourceDir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var cycles []string
+	engine := NewEngine(SyncConfig{
+		ID:        "test-cycle",
+		SourceDir: sourceDir,
+		TargetDir: targetDir,
+		OnSyncEvent: func(_, _, _ string, _ int64, cycle string) {
+			cycles = append(cycles, cycle)
+		},
+	})
+
+	if err := engine.RunSync(nil); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	if len(cycles) != 2 || cycles[0] == "" || cycles[0] != cycles[1] {
+		t.Fatalf("Expected both events tagged with the same cycle, got %q", cycles)
+	}
+	if engine.CurrentCycle() != "" {
+		t.Error("Cycle ID should be cleared after the sync")
+	}
+	if len(logging.CycleLogs(cycles[0])) == 0 {
+		t.Error("Expected log records retained for the cycle")
+	}
+
+	if err := os.WriteFile(filepath.Join(sourceDir, "c.mkv"), []byte("c"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.RunSync(nil); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	if len(cycles) != 3 || cycles[2] == cycles[0] {
+		t.Errorf("Expected a new cycle ID for the second sync, got %q", cycles)
+	}
+}
@@ -0,0 +1,136 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"schnorarr/internal/monitor/database"
+)
+
+// DefaultEvictInterval is how often the source disk usage is checked for eviction
+const DefaultEvictInterval = time.Minute
+
+// evictionEnabled reports whether free-space eviction applies to this engine.
+// Eviction removes mirrored files from the source, so it only runs with the move rule
+// where the target is never pruned to follow the source.
+func (e *Engine) evictionEnabled() bool {
+	return e.config.Rule == RuleMove && e.config.EvictAbovePercent > 0
+}
+
+// sourceUsagePercent returns the usage of the disk holding the source directory
+func (e *Engine) sourceUsagePercent() (float64, error) {
+	used, total, err := DiskUsage(e.config.SourceDir)
+	if err != nil {
+		return 0, err
+	}
+	if total == 0 {
+		return 0, fmt.Errorf("disk reports zero capacity")
+	}
+	return float64(used) * 100 / float64(total), nil
+}
+
+// evictForSpace removes the oldest mirrored files from the source until its disk
+// usage drops to EvictToPercent. The caller must hold syncMu so no transfer of
+// this engine is running, and the transfer lock unless in dry run.
+func (e *Engine) evictForSpace(sourceManifest, targetManifest *Manifest) {
+	usage, err := e.sourceUsagePercent()
+	if err != nil {
+		e.logger().Error("Eviction: failed to read source disk usage", "error", err)
+		return
+	}
+	if usage < e.config.EvictAbovePercent {
+		return
+	}
+	low := e.config.EvictToPercent
+	if low <= 0 || low > e.config.EvictAbovePercent {
+		low = e.config.EvictAbovePercent
+	}
+
+	// Oldest mirrored files first
+	var candidates []*FileInfo
+	for rel, src := range sourceManifest.Files {
+		if src.IsDir {
+			continue
+		}
+		if dst, ok := targetManifest.GetFile(rel); ok && e.mirrored(src, dst) {
+			candidates = append(candidates, src)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].ModTime.Before(candidates[j].ModTime) })
+
+	if e.dryRunFor(ActionMove) {
+		e.logger().Info("Eviction (dry run): mirrored files could be evicted", "usage_percent", usage,
+			"limit_percent", e.config.EvictAbovePercent, "candidates", len(candidates))
+		return
+	}
+
+	e.logger().Info("Eviction: evicting oldest mirrored files", "usage_percent", usage,
+		"limit_percent", e.config.EvictAbovePercent, "target_percent", low)
+	timestamp := database.Timestamp(time.Now())
+	evicted := 0
+	for _, src := range candidates {
+		if usage <= low || e.IsPaused() {
+			break
+		}
+		e.pausedMu.RLock()
+		_, failed := e.failedFiles[src.Path]
+		srcPath := filepath.Join(e.config.SourceDir, filepath.FromSlash(src.Path))
+		inFlight := e.currentFile != "" && (e.currentFile == srcPath || filepath.ToSlash(e.currentFile) == src.Path)
+		e.pausedMu.RUnlock()
+		if failed || inFlight {
+			continue
+		}
+		if err := e.verifyMirrored(src.Path, src); err != nil {
+			e.logger().Warn("Eviction: keeping file, verification failed", "path", src.Path, "error", err)
+			continue
+		}
+		if err := os.Remove(srcPath); err != nil {
+			e.logger().Error("Failed to evict", "path", src.Path, "error", err)
+			e.reportError(fmt.Sprintf("Failed to evict %s: %v", src.Path, err))
+			continue
+		}
+		delete(sourceManifest.Files, src.Path)
+		evicted++
+		e.reportEvent(timestamp, "Evicted", src.Path, src.Size)
+		if usage, err = e.sourceUsagePercent(); err != nil {
+			break
+		}
+	}
+	e.logger().Info("Eviction: removed files from the source", "evicted", evicted, "usage_percent", usage)
+	if usage > low && !e.IsPaused() {
+		e.logger().Warn("Source disk still above the limit after evicting all verified mirrored files", "usage_percent", usage)
+	}
+}
+
+// evictionLoop checks the source disk between sync cycles. It skips a round
+// while the engine is syncing; RunSync evicts on its own once transfers finish.
+func (e *Engine) evictionLoop() {
+	ticker := time.NewTicker(DefaultEvictInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stopCh:
+			return
+		case <-ticker.C:
+			if e.IsPaused() || e.dryRunFor(ActionMove) {
+				continue
+			}
+			if usage, err := e.sourceUsagePercent(); err != nil || usage < e.config.EvictAbovePercent {
+				continue
+			}
+			if !e.syncMu.TryLock() {
+				continue
+			}
+			source, target, err := e.scanManifests()
+			if err == nil {
+				AcquireTransferLockFor(e.config.LockGroup)
+				e.evictForSpace(source, target)
+				ReleaseTransferLockFor(e.config.LockGroup)
+			}
+			e.syncMu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEngine_EvictForSpace(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+
+	for _, name := range []string{"mirrored.mkv", "pending.mkv"} {
+		if err := os.WriteFile(filepath.Join(sourceDir, name), []byte("data "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(targetDir, "mirrored.mkv"), []byte("data mirrored.mkv"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := NewEngine(SyncConfig{
+		ID:        "test-evict",
+		SourceDir: sourceDir,
+		TargetDir: targetDir,
+		Rule:      RuleMove,
+		// Any real disk is above this, so every verified file is evicted
+		EvictAbovePercent: 0.0001,
+		EvictToPercent:    0.00001,
+	})
+	if !engine.evictionEnabled() {
+		t.Fatal("Eviction should be enabled for the move rule")
+	}
+
+	source, target, err := engine.scanManifests()
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.evictForSpace(source, target)
+
+	if _, err := os.Stat(filepath.Join(sourceDir, "mirrored.mkv")); !os.IsNotExist(err) {
+		t.Error("Mirrored file should have been evicted from the source")
+	}
+	if _, err := os.Stat(filepath.Join(sourceDir, "pending.mkv")); err != nil {
+		t.Error("File not yet on the target must never be evicted")
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "mirrored.mkv")); err != nil {
+		t.Error("Target copy must be kept")
+	}
+}
+
+func TestEngine_EvictionRequiresMoveRule(t *testing.T) {
+	engine := NewEngine(SyncConfig{ID: "test-evict-rule", Rule: "series", EvictAbovePercent: 90})
+	if engine.evictionEnabled() {
+		t.Error("Eviction must not run unless the target is never pruned")
+	}
+}
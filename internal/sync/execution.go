@@ -1,105 +1,208 @@
 package sync
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"os"
 	"path/filepath"
 	"time"
+
+	"schnorarr/internal/agent"
+	"schnorarr/internal/monitor/database"
 )
 
 // executeSyncPhase executes the sync part of the plan
 func (e *Engine) executeSyncPhase(plan *SyncPlan, targetManifest *Manifest) (map[string]bool, error) {
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	isDryRun := e.isDryRun()
+	timestamp := database.Timestamp(time.Now())
+	dryAdd, dryRename := e.dryRunFor(ActionAdd), e.dryRunFor(ActionRename)
 	touchedDirs := make(map[string]bool)
+	defer e.endPhase()
 
-	for _, dirPath := range plan.DirsToCreate {
+	// Remote targets get the directories, renames and deletions of a cycle
+	// in batches; whatever a batch left undone is applied one by one
+	var mkdirOps []agent.Op
+	if !dryAdd {
+		for _, dirPath := range plan.DirsToCreate {
+			mkdirOps = append(mkdirOps, agent.Op{Op: agent.OpMkdir, Path: filepath.ToSlash(dirPath)})
+		}
+	}
+	created := e.runBatched(mkdirOps)
+	for i, dirPath := range plan.DirsToCreate {
+		if !dryAdd && created[i] {
+			touchedDirs[parentOf(dirPath)] = true
+			e.targetSucceeded()
+			targetManifest.Add(&FileInfo{Path: filepath.ToSlash(dirPath), IsDir: true})
+			continue
+		}
 		if e.IsPaused() {
 			return touchedDirs, fmt.Errorf("sync interrupted by pause")
 		}
 		fullPath := filepath.Join(e.config.TargetDir, dirPath)
-		parentDir := filepath.Dir(dirPath)
-		if parentDir == "." {
-			parentDir = ""
-		}
-		touchedDirs[parentDir] = true
-		if isDryRun {
+		touchedDirs[parentOf(dirPath)] = true
+		if dryAdd {
 			e.reportEvent(timestamp, "DRY-Created", dirPath, 0)
 		} else {
 			if err := e.transferer.CreateDir(fullPath); err != nil {
-				log.Printf("[%s] Error: Failed to create dir %s: %v", e.config.ID, dirPath, err)
+				e.logger().Error("Failed to create dir", "path", dirPath, "error", err)
 				e.reportError(fmt.Sprintf("Failed to create dir %s: %v", dirPath, err))
+				if e.targetFailed(err) {
+					return touchedDirs, ErrCircuitOpen
+				}
 				continue
 			}
+			e.targetSucceeded()
 			targetManifest.Add(&FileInfo{Path: filepath.ToSlash(dirPath), IsDir: true})
 		}
 	}
 
+	renames := make([]string, 0, len(plan.Renames))
+	var moveOps []agent.Op
 	for oldPath, newPath := range plan.Renames {
-		if e.IsPaused() {
-			return touchedDirs, fmt.Errorf("sync interrupted by pause")
+		renames = append(renames, oldPath)
+		if !dryRename {
+			moveOps = append(moveOps, agent.Op{Op: agent.OpMove, Path: oldPath, To: newPath})
+		}
+	}
+	if len(renames) > 0 {
+		e.startPhase(PhaseRename, len(renames))
+	}
+	moved := e.runBatched(moveOps)
+	for i, oldPath := range renames {
+		newPath := plan.Renames[oldPath]
+		if dryRename || !moved[i] {
+			if e.IsPaused() {
+				return touchedDirs, fmt.Errorf("sync interrupted by pause")
+			}
+			e.phaseStep(oldPath, 1)
 		}
 		touchedDirs[filepath.Dir(oldPath)] = true
 		touchedDirs[filepath.Dir(newPath)] = true
-		if isDryRun {
+		if dryRename {
 			e.reportEvent(timestamp, "DRY-Renamed", fmt.Sprintf("%s -> %s", oldPath, newPath), 0)
 		} else {
 			oldFullPath, newFullPath := filepath.Join(e.config.TargetDir, oldPath), filepath.Join(e.config.TargetDir, newPath)
-			if err := e.transferer.RenameFile(oldFullPath, newFullPath); err == nil {
-				if file, exists := targetManifest.Files[oldPath]; exists {
-					delete(targetManifest.Files, oldPath)
-					file.Path = newPath
-					targetManifest.Files[newPath] = file
+			isDir := targetManifest.HasDir(oldPath)
+			var err error
+			if !moved[i] {
+				release := e.lockTarget(oldPath, newPath)
+				if isDir {
+					err = e.transferer.RenameDir(oldFullPath, newFullPath)
+				} else {
+					err = e.transferer.RenameFile(oldFullPath, newFullPath)
+				}
+				release()
+			}
+			if err == nil {
+				e.targetSucceeded()
+				e.recordRename(oldPath, newPath)
+				for from, to := range targetManifest.moveTree(oldPath, newPath) {
+					e.renameChecksum(from, to)
 				}
 				e.reportEvent(timestamp, "Renamed", fmt.Sprintf("%s -> %s", oldPath, newPath), 0)
 			} else {
-				log.Printf("[%s] Error: Failed to rename %s -> %s: %v", e.config.ID, oldPath, newPath, err)
+				e.logger().Error("Failed to rename", "from", oldPath, "to", newPath, "error", err)
 				e.reportError(fmt.Sprintf("Failed to rename %s -> %s: %v", oldPath, newPath, err))
+				if isDir && !e.dirRenamesOff.Swap(true) {
+					e.logger().Warn("Directory renames are off, later cycles transfer renamed directories instead")
+				}
+				if e.targetFailed(err) {
+					return touchedDirs, ErrCircuitOpen
+				}
 			}
 		}
 	}
 
+	e.endPhase()
+
 	for _, file := range plan.FilesToSync {
 		if e.IsPaused() {
 			return touchedDirs, fmt.Errorf("sync interrupted by pause")
 		}
 		touchedDirs[filepath.Dir(file.Path)] = true
-		if isDryRun {
+
+		// Check if this is a conflict (needs update) and delete target first for clean override
+		isConflict := false
+		for _, c := range plan.Conflicts {
+			if c.Path == file.Path {
+				isConflict = true
+				break
+			}
+		}
+
+		if isConflict {
+			if pattern, ok := matchProtected(e.config.NeverOverwritePatterns, file.Path); ok {
+				e.logger().Info("Protected: not overwriting", "path", file.Path, "pattern", pattern)
+				e.pausedMu.Lock()
+				e.planRemainingBytes -= file.Size
+				if e.planRemainingBytes < 0 {
+					e.planRemainingBytes = 0
+				}
+				e.pausedMu.Unlock()
+				continue
+			}
+		}
+
+		if dryAdd {
 			e.reportEvent(timestamp, "DRY-Added", file.Path, file.Size)
 		} else {
 			srcPath, dstPath := filepath.Join(e.config.SourceDir, file.Path), filepath.Join(e.config.TargetDir, file.Path)
 
-			// Check if this is a conflict (needs update) and delete target first for clean override
-			isConflict := false
-			for _, c := range plan.Conflicts {
-				if c.Path == file.Path {
-					isConflict = true
-					break
+			if !e.checkMedia(timestamp, file) {
+				e.pausedMu.Lock()
+				e.planRemainingBytes -= file.Size
+				if e.planRemainingBytes < 0 {
+					e.planRemainingBytes = 0
 				}
+				e.pausedMu.Unlock()
+				continue
 			}
 
-			if isConflict {
-				log.Printf("[%s] Conflict detected for %s, deleting target first to ensure override", e.config.ID, file.Path)
+			if err := e.scanRemoteSource(file.Path); err != nil {
+				if e.rejected(timestamp, file, err) {
+					continue
+				}
+				e.logger().Error("Failed to scan", "path", file.Path, "error", err)
+				e.reportError(fmt.Sprintf("Failed to scan %s: %v", file.Path, err))
+				e.pausedMu.Lock()
+				e.failedFiles[file.Path] = time.Now()
+				e.pausedMu.Unlock()
+				continue
+			}
+
+			release := e.lockTarget(file.Path)
+			// A scanned local copy replaces the target only once accepted
+			if isConflict && (e.config.ScanCommand == "" || IsRemotePath(e.config.TargetDir)) {
+				e.logger().Info("Conflict detected, deleting target first to ensure override", "path", file.Path)
 				if err := e.transferer.DeleteFile(dstPath); err != nil {
-					log.Printf("[%s] Warning: Failed to delete conflict target %s: %v", e.config.ID, file.Path, err)
+					e.logger().Warn("Failed to delete conflict target", "path", file.Path, "error", err)
 				}
 			}
 
-			if err := e.transferer.CopyFile(srcPath, dstPath); err != nil {
+			err := e.transferer.CopyFile(srcPath, dstPath)
+			release()
+			if err != nil {
 				if err.Error() == "transfer interrupted by pause" {
 					return touchedDirs, err
 				}
-				log.Printf("[%s] Error: Failed to copy %s: %v", e.config.ID, file.Path, err)
+				if e.rejected(timestamp, file, err) {
+					continue
+				}
+				e.logger().Error("Failed to copy", "path", file.Path, "error", err)
 				e.reportError(fmt.Sprintf("Failed to copy %s: %v", file.Path, err))
 				e.pausedMu.Lock()
 				e.failedFiles[file.Path] = time.Now()
 				e.pausedMu.Unlock()
+				if e.targetFailed(err) {
+					return touchedDirs, ErrCircuitOpen
+				}
 				continue
 			}
+			e.targetSucceeded()
 			e.pausedMu.Lock()
 			delete(e.failedFiles, file.Path)
 			e.pausedMu.Unlock()
 			targetManifest.Add(&FileInfo{Path: file.Path, Size: file.Size, ModTime: file.ModTime, IsDir: false})
+			e.recordChecksum(file)
 			e.reportEvent(timestamp, "Added", file.Path, file.Size)
 		}
 		e.pausedMu.Lock()
@@ -112,47 +215,256 @@ func (e *Engine) executeSyncPhase(plan *SyncPlan, targetManifest *Manifest) (map
 	return touchedDirs, nil
 }
 
+// parentOf returns the parent of a target-relative path, "" for the root
+func parentOf(rel string) string {
+	if parent := filepath.Dir(rel); parent != "." {
+		return parent
+	}
+	return ""
+}
+
+// applyDirMetadata gives the created and changed target directories the
+// permissions and mtime of their source directory, deepest first, once the
+// cycle stopped writing into them
+func (e *Engine) applyDirMetadata(created []string, touched map[string]bool) {
+	set := make(map[string]bool, len(created)+len(touched))
+	for _, d := range created {
+		set[d] = true
+	}
+	for d := range touched {
+		if d != "" && d != "." {
+			set[d] = true
+		}
+	}
+	dirs := make([]string, 0, len(set))
+	for d := range set {
+		dirs = append(dirs, d)
+	}
+	sortDeepestFirst(dirs)
+	for _, d := range dirs {
+		info, err := os.Stat(filepath.Join(e.config.SourceDir, d))
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		err = e.transferer.SetMetadata(filepath.Join(e.config.TargetDir, d), info.ModTime(), info.Mode())
+		if err != nil && !os.IsNotExist(err) {
+			e.logger().Warn("Failed to set directory metadata", "path", d, "error", err)
+		}
+	}
+}
+
 func (e *Engine) executeCleanupPhase(plan *SyncPlan, targetManifest *Manifest, touchedDirs map[string]bool) error {
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	isDryRun := e.isDryRun()
+	timestamp := database.Timestamp(time.Now())
+	isDryRun := e.dryRunFor(ActionDelete)
 	if len(plan.FilesToDelete) == 0 && len(plan.DirsToDelete) == 0 {
 		return nil
 	}
 
-	for _, filePath := range plan.FilesToDelete {
-		if e.IsPaused() {
-			return fmt.Errorf("sync interrupted by pause")
+	e.startPhase(PhaseDelete, len(plan.FilesToDelete)+len(plan.DirsToDelete))
+	defer e.endPhase()
+	var deleteOps []agent.Op
+	if !isDryRun {
+		for _, filePath := range plan.FilesToDelete {
+			deleteOps = append(deleteOps, agent.Op{Op: agent.OpDelete, Path: filePath})
+		}
+	}
+	deleted := e.runBatched(deleteOps)
+	for i, filePath := range plan.FilesToDelete {
+		if isDryRun || !deleted[i] {
+			if e.IsPaused() {
+				return fmt.Errorf("sync interrupted by pause")
+			}
+			e.phaseStep(filePath, 1)
 		}
 		if isDryRun {
 			e.reportEvent(timestamp, "DRY-Deleted", filePath, 0)
 		} else {
-			if err := e.transferer.DeleteFile(filepath.Join(e.config.TargetDir, filePath)); err == nil {
+			var err error
+			if !deleted[i] {
+				release := e.lockTarget(filePath)
+				err = e.removeFromTarget(filePath, false)
+				release()
+			}
+			if err == nil {
+				e.targetSucceeded()
 				delete(targetManifest.Files, filePath)
+				e.removeChecksum(filePath)
 				e.reportEvent(timestamp, "Deleted", filePath, 0)
 			} else {
-				log.Printf("[%s] Error: Failed to delete %s: %v", e.config.ID, filePath, err)
+				e.logger().Error("Failed to delete", "path", filePath, "error", err)
 				e.reportError(fmt.Sprintf("Failed to delete %s: %v", filePath, err))
+				if e.targetFailed(err) {
+					return ErrCircuitOpen
+				}
 			}
 		}
 	}
 
-	for i := len(plan.DirsToDelete) - 1; i >= 0; i-- {
-		if e.IsPaused() {
-			return fmt.Errorf("sync interrupted by pause")
+	// Directories go deepest first. An empty one is only rmdir'ed; one with
+	// entries left is removed recursively only if all of them, as listed on
+	// the target, were planned for deletion too, so content the plan never
+	// saw is not swept away.
+	planned := make(map[string]bool, len(plan.FilesToDelete)+len(plan.DirsToDelete))
+	for _, p := range plan.FilesToDelete {
+		planned[p] = true
+	}
+	for _, p := range plan.DirsToDelete {
+		planned[p] = true
+	}
+	dirs := append([]string(nil), plan.DirsToDelete...)
+	sortDeepestFirst(dirs)
+	var rmdirOps []agent.Op
+	removable := make(map[string]bool, len(dirs))
+	// Only rmdir'ed directories are gone before their parent: the batch
+	// stages a directory with entries left inside the parent until commit
+	emptied := make(map[string]bool, len(dirs))
+	if !isDryRun {
+		for _, dirPath := range dirs {
+			child, remaining := unplannedChild(targetManifest, dirPath, planned, emptied)
+			if child != "" {
+				e.logger().Warn("Keeping directory with content not planned for deletion", "path", dirPath, "entry", child)
+				continue
+			}
+			if remaining && IsRemotePath(e.config.TargetDir) {
+				// The batch removes the whole tree, check what is on disk first
+				if child, err := e.unplannedOnTarget(dirPath, planned); child != "" || err != nil {
+					e.logger().Warn("Keeping directory with content not planned for deletion", "path", dirPath, "entry", child, "error", err)
+					continue
+				}
+			}
+			removable[dirPath] = true
+			emptied[dirPath] = !remaining
+			rmdirOps = append(rmdirOps, agent.Op{Op: agent.OpDelete, Path: dirPath, Dir: true, Empty: !remaining})
+		}
+	}
+	removed := make(map[string]bool, len(rmdirOps))
+	for i, done := range e.runBatched(rmdirOps) {
+		removed[rmdirOps[i].Path] = done
+	}
+	for _, dirPath := range dirs {
+		if isDryRun || !removed[dirPath] {
+			if e.IsPaused() {
+				return fmt.Errorf("sync interrupted by pause")
+			}
+			e.phaseStep(dirPath, 1)
 		}
-		dirPath := plan.DirsToDelete[i]
 		if isDryRun {
 			e.reportEvent(timestamp, "DRY-Deleted", dirPath, 0)
+			continue
+		}
+		if !removable[dirPath] {
+			continue
+		}
+		var err error
+		if !removed[dirPath] {
+			_, remaining := unplannedChild(targetManifest, dirPath, planned, nil)
+			release := e.lockTarget(dirPath)
+			err = e.removeDirFromTarget(dirPath, remaining, planned)
+			release()
+		}
+		if errors.Is(err, agent.ErrNotEmpty) {
+			e.logger().Warn("Keeping directory with content not in the target scan", "path", dirPath, "reason", err)
+			continue
+		}
+		if errors.Is(err, agent.ErrUnsupported) {
+			e.logger().Warn("Keeping directory, the receiver cannot check or remove only what was planned", "path", dirPath)
+			continue
+		}
+		if err == nil {
+			e.targetSucceeded()
+			delete(targetManifest.Dirs, dirPath)
+			delete(targetManifest.Files, dirPath)
+			e.reportEvent(timestamp, "Deleted", dirPath, 0)
 		} else {
-			if err := e.transferer.DeleteDir(filepath.Join(e.config.TargetDir, dirPath)); err == nil {
-				delete(targetManifest.Dirs, dirPath)
-				delete(targetManifest.Files, dirPath)
-				e.reportEvent(timestamp, "Deleted", dirPath, 0)
-			} else {
-				log.Printf("[%s] Error: Failed to delete dir %s: %v", e.config.ID, dirPath, err)
-				e.reportError(fmt.Sprintf("Failed to delete dir %s: %v", dirPath, err))
+			e.logger().Error("Failed to delete dir", "path", dirPath, "error", err)
+			e.reportError(fmt.Sprintf("Failed to delete dir %s: %v", dirPath, err))
+			if e.targetFailed(err) {
+				return ErrCircuitOpen
 			}
 		}
 	}
 	return nil
 }
+
+// removeDirFromTarget removes a planned target directory: recursively when
+// entries the plan deleted are left (e.g. files whose deletion failed) and
+// the directory on disk holds nothing else, otherwise with rmdir. Files the
+// include patterns hide from the scan of a local target go with the
+// directory; anything else left on disk makes it agent.ErrNotEmpty. Legacy
+// receivers that only delete whole trees get agent.ErrUnsupported.
+func (e *Engine) removeDirFromTarget(dirPath string, remaining bool, planned map[string]bool) error {
+	if remaining {
+		child, err := e.unplannedOnTarget(dirPath, planned)
+		if err != nil {
+			return err
+		}
+		if child != "" {
+			return fmt.Errorf("%w: %s was not planned for deletion", agent.ErrNotEmpty, child)
+		}
+		return e.removeFromTarget(dirPath, true)
+	}
+	err := e.removeEmptyDirFromTarget(dirPath)
+	if errors.Is(err, agent.ErrNotEmpty) && e.onlyHiddenFiles(dirPath) {
+		return e.removeFromTarget(dirPath, true)
+	}
+	return err
+}
+
+// unplannedOnTarget lists dir and its subdirectories on the target and
+// returns an entry not planned for deletion, "" when there is none. Unlike
+// the scan it sees the files the include and exclude patterns leave out.
+func (e *Engine) unplannedOnTarget(dir string, planned map[string]bool) (string, error) {
+	entries, err := e.transferer.ListDir(filepath.Join(e.config.TargetDir, dir))
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		rel := dir + "/" + entry.Name
+		if !planned[rel] {
+			return rel, nil
+		}
+		if entry.IsDir {
+			if child, err := e.unplannedOnTarget(rel, planned); child != "" || err != nil {
+				return child, err
+			}
+		}
+	}
+	return "", nil
+}
+
+// onlyHiddenFiles reports whether the local target directory holds nothing
+// but files the include patterns leave out of the scan, none of them
+// protected from deletion
+func (e *Engine) onlyHiddenFiles(dirPath string) bool {
+	if IsRemotePath(e.config.TargetDir) || len(e.config.IncludePatterns) == 0 {
+		return false
+	}
+	entries, err := os.ReadDir(filepath.Join(e.config.TargetDir, dirPath))
+	if err != nil || len(entries) == 0 {
+		return false
+	}
+	for _, d := range entries {
+		rel := dirPath + "/" + d.Name()
+		if !d.Type().IsRegular() || e.scanner.shouldExclude(rel) || e.scanner.shouldInclude(rel) {
+			return false
+		}
+		if _, ok := matchProtected(e.config.NeverDeletePatterns, rel); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// rejected records a transfer the scan hook quarantined and reports whether
+// err was such a rejection
+func (e *Engine) rejected(timestamp string, file *FileInfo, err error) bool {
+	var q *QuarantineError
+	if !errors.As(err, &q) {
+		return false
+	}
+	e.logger().Warn("Scan hook rejected file, quarantined", "path", file.Path, "reason", q.Reason)
+	e.reportError(fmt.Sprintf("Quarantined %s: %s", file.Path, q.Reason))
+	e.recordQuarantine(file, q.Reason)
+	e.reportEvent(timestamp, "Quarantined", file.Path, file.Size)
+	return true
+}
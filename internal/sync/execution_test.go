@@ -0,0 +1,298 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"schnorarr/internal/agent"
+	"schnorarr/internal/monitor/database"
+)
+
+func TestEngine_CleanupDirectories(t *testing.T) {
+	targetDir := t.TempDir()
+	write := func(rel string) {
+		full := filepath.Join(targetDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, dir := range []string{"Empty/Nested", "Mixed", "Hidden", "Junk"} {
+		if err := os.MkdirAll(filepath.Join(targetDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("Mixed/old.mkv")
+	write("Mixed/unplanned.mkv")
+	write("Hidden/movie.nfo") // Left out of the scan by the include filter
+	write("Junk/.DS_Store")   // Excluded, unknown to the plan
+
+	target := NewManifest(targetDir)
+	for _, dir := range []string{"Empty", "Empty/Nested", "Mixed", "Hidden", "Junk"} {
+		target.Add(&FileInfo{Path: dir, IsDir: true})
+	}
+	target.Add(&FileInfo{Path: "Mixed/old.mkv", Size: 1})
+	target.Add(&FileInfo{Path: "Mixed/unplanned.mkv", Size: 1})
+
+	engine := NewEngine(SyncConfig{
+		ID: "test-cleanup", SourceDir: t.TempDir(), TargetDir: targetDir, Rule: "flat",
+		IncludePatterns: []string{"*.mkv"}, ExcludePatterns: []string{".DS_Store"},
+	})
+	plan := &SyncPlan{
+		FilesToDelete: []string{"Mixed/old.mkv"},
+		// Lexicographic order would remove Empty before Empty/Nested
+		DirsToDelete: []string{"Empty", "Empty/Nested", "Hidden", "Junk", "Mixed"},
+	}
+	if err := engine.executeCleanupPhase(plan, target, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for dir, kept := range map[string]bool{
+		"Empty":  false,
+		"Hidden": false, // Only files the include filter hides are left
+		"Junk":   true,  // Excluded files are not the plan's to delete
+		"Mixed":  true,  // unplanned.mkv was never up for deletion
+	} {
+		_, err := os.Stat(filepath.Join(targetDir, dir))
+		if exists := err == nil; exists != kept {
+			t.Errorf("%s exists = %v, want %v", dir, exists, kept)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "Mixed", "old.mkv")); !os.IsNotExist(err) {
+		t.Error("The planned file should be deleted before its directory")
+	}
+}
+
+func TestSortDeepestFirst(t *testing.T) {
+	dirs := []string{"A", "A/B/C", "B", "A/B", "B/A"}
+	sortDeepestFirst(dirs)
+	want := []string{"A/B/C", "A/B", "B/A", "A", "B"}
+	for i := range want {
+		if dirs[i] != want[i] {
+			t.Fatalf("sortDeepestFirst = %v, want %v", dirs, want)
+		}
+	}
+}
+
+func TestEngine_DirRename(t *testing.T) {
+	database.DBPath = filepath.Join(t.TempDir(), "rename.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+
+	sourceDir, targetDir := t.TempDir(), t.TempDir()
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, root := range []string{sourceDir, targetDir} {
+		full := filepath.Join(root, "Movie", "movie.mkv")
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte("movie"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(full, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(targetDir, "Movie", "movie.nfo"), []byte("info"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []string
+	engine := NewEngine(SyncConfig{
+		ID: "test-dir-rename", SourceDir: sourceDir, TargetDir: targetDir, Rule: "flat",
+		IncludePatterns: []string{"*.mkv"}, FlatDeleteDirs: true,
+		OnSyncEvent: func(_, action, path string, _ int64, _ string) { events = append(events, action+" "+path) },
+	})
+	// Only a directory seen in sync is deleted, and so renamed
+	if err := engine.RunSync(nil); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	if err := os.Rename(filepath.Join(sourceDir, "Movie"), filepath.Join(sourceDir, "Movie (2020)")); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.RunSync(nil); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	for _, rel := range []string{"Movie (2020)/movie.mkv", "Movie (2020)/movie.nfo"} {
+		if _, err := os.Stat(filepath.Join(targetDir, rel)); err != nil {
+			t.Errorf("%s should exist after the rename: %v", rel, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "Movie")); !os.IsNotExist(err) {
+		t.Error("The old directory should be gone")
+	}
+	if len(events) != 1 || events[0] != "Renamed Movie -> Movie (2020)" {
+		t.Errorf("Expected a single rename and no transfer, got %v", events)
+	}
+}
+
+func TestEngine_DirMetadata(t *testing.T) {
+	database.DBPath = filepath.Join(t.TempDir(), "meta.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+
+	sourceDir, targetDir := t.TempDir(), t.TempDir()
+	for _, dir := range []string{"Show/Season 1", "Show/Season 2"} {
+		if err := os.MkdirAll(filepath.Join(sourceDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "Show", "Season 1", "e01.mkv"), []byte("episode"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	for _, dir := range []string{"Show/Season 1", "Show/Season 2", "Show"} {
+		full := filepath.Join(sourceDir, dir)
+		if err := os.Chmod(full, 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(full, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	engine := NewEngine(SyncConfig{ID: "test-dir-meta", SourceDir: sourceDir, TargetDir: targetDir, Rule: "series"})
+	if err := engine.RunSync(nil); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	for _, dir := range []string{"Show", "Show/Season 1", "Show/Season 2"} {
+		info, err := os.Stat(filepath.Join(targetDir, dir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0750 || !info.ModTime().Equal(mtime) {
+			t.Errorf("%s has %o %v, want the source's 750 %v", dir, info.Mode().Perm(), info.ModTime(), mtime)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(targetDir, "Show", "Season 1", "e01.mkv")); err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("Copied files should keep their permissions: %v, %v", info, err)
+	}
+}
+
+func TestEngine_CleanupProgress(t *testing.T) {
+	targetDir := t.TempDir()
+	target := NewManifest(targetDir)
+	for _, f := range []string{"Show/a.mkv", "Show/b.mkv", "c.mkv"} {
+		full := filepath.Join(targetDir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		target.Add(&FileInfo{Path: f, Size: 1})
+	}
+	target.Add(&FileInfo{Path: "Show", IsDir: true})
+
+	var engine *Engine
+	var seen []string
+	engine = NewEngine(SyncConfig{
+		ID: "test-cleanup-progress", SourceDir: t.TempDir(), TargetDir: targetDir,
+		OnSyncEvent: func(timestamp, action, path string, size int64, cycle string) {
+			if p := engine.GetPhaseProgress(); p != nil {
+				seen = append(seen, fmt.Sprintf("%s %d/%d %s", p.Phase, p.Done, p.Total, p.Path))
+			}
+		},
+	})
+	plan := &SyncPlan{FilesToDelete: []string{"Show/a.mkv", "Show/b.mkv", "c.mkv"}, DirsToDelete: []string{"Show"}}
+	if err := engine.executeCleanupPhase(plan, target, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"delete 1/4 Show/a.mkv", "delete 2/4 Show/b.mkv", "delete 3/4 c.mkv", "delete 4/4 Show"}
+	if strings.Join(seen, ",") != strings.Join(want, ",") {
+		t.Errorf("Progress = %v, want %v", seen, want)
+	}
+	if engine.GetPhaseProgress() != nil {
+		t.Error("Progress should be cleared once the deletions are done")
+	}
+}
+
+func TestEngine_DryRunActions(t *testing.T) {
+	database.DBPath = filepath.Join(t.TempDir(), "dry.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+
+	sourceDir, targetDir := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(sourceDir, "new.mkv"), []byte("new episode"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(targetDir, "old.mkv"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []string
+	engine := NewEngine(SyncConfig{
+		ID: "test-dry-actions", SourceDir: sourceDir, TargetDir: targetDir, Rule: "flat",
+		DryRunActions: []string{ActionDelete}, // Deletions are not auto-approved
+		OnSyncEvent: func(_, action, path string, _ int64, _ string) {
+			events = append(events, action+" "+path)
+		},
+	})
+	for cycle := 1; cycle <= 2; cycle++ {
+		events = nil
+		if err := engine.RunSync(nil); err != nil {
+			t.Fatalf("RunSync failed: %v", err)
+		}
+		if !slices.Contains(events, "DRY-Deleted old.mkv") {
+			t.Errorf("Cycle %d: deletion should only be logged, got %v", cycle, events)
+		}
+		if engine.IsWaitingForApproval() {
+			t.Errorf("Cycle %d: deletions kept in dry run should not wait for approval", cycle)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "new.mkv")); err != nil {
+		t.Errorf("Additions should run: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "old.mkv")); err != nil {
+		t.Errorf("Deletions kept in dry run should not touch the target: %v", err)
+	}
+}
+
+func TestEngine_RemoveDirChecksDisk(t *testing.T) {
+	targetDir := t.TempDir()
+	for _, rel := range []string{"Show/Season 1/e01.mkv", "Show/Season 1/poster.jpg"} {
+		full := filepath.Join(targetDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	engine := NewEngine(SyncConfig{
+		ID: "test-rmdir-disk", SourceDir: t.TempDir(), TargetDir: targetDir, IncludePatterns: []string{"*.mkv"},
+	})
+	planned := map[string]bool{"Show": true, "Show/Season 1": true, "Show/Season 1/e01.mkv": true}
+
+	// poster.jpg is hidden from the scan by the include filter, not planned
+	err := engine.removeDirFromTarget("Show", true, planned)
+	if !errors.Is(err, agent.ErrNotEmpty) {
+		t.Fatalf("Expected ErrNotEmpty for unplanned content on disk, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "Show", "Season 1", "poster.jpg")); err != nil {
+		t.Fatal("Content not planned for deletion must be kept")
+	}
+
+	planned["Show/Season 1/poster.jpg"] = true
+	if err := engine.removeDirFromTarget("Show", true, planned); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "Show")); !os.IsNotExist(err) {
+		t.Error("A directory holding only planned entries should be removed")
+	}
+}
@@ -0,0 +1,188 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// PathExplanation describes how the engine's rules and plan treat a single path
+type PathExplanation struct {
+	Path              string `json:"path"`
+	ExistsOnSource    bool   `json:"exists_on_source"`
+	InSourceManifest  bool   `json:"in_source_manifest"`
+	InTargetManifest  bool   `json:"in_target_manifest"`
+	IsDir             bool   `json:"is_dir"`
+	Excluded          bool   `json:"excluded"`
+	ExcludeRule       string `json:"exclude_rule,omitempty"`
+	Included          bool   `json:"included"`
+	IncludeRule       string `json:"include_rule,omitempty"`
+	Managed           bool   `json:"managed"`
+	DeletionProtected bool   `json:"deletion_protected"`
+	ProtectionReason  string `json:"protection_reason,omitempty"`
+	Action            string `json:"action"`
+	Reason            string `json:"reason"`
+}
+
+// scanManifests scans source and target under the engine's scan lock.
+// A failing target scan yields an empty target manifest.
+func (e *Engine) scanManifests() (source, target *Manifest, err error) {
+	AcquireScanLockFor(e.config.LockGroup)
+	defer ReleaseScanLockFor(e.config.LockGroup)
+	source, err = e.scanner.ScanLocal(e.config.SourceDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to scan source: %w", err)
+	}
+	target, err = e.scanner.ScanLocal(e.config.TargetDir)
+	if err != nil {
+		target = NewManifest(e.config.TargetDir)
+	}
+	e.dropGeneratedFiles(target)
+	return source, target, nil
+}
+
+// Explain reports which include/exclude rule matched path, whether deletion
+// protection applies and what the current plan would do with it.
+func (e *Engine) Explain(path string) (*PathExplanation, error) {
+	rel := strings.Trim(filepath.ToSlash(filepath.Clean(path)), "/")
+	if rel == "" || rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return nil, fmt.Errorf("invalid path %q", path)
+	}
+
+	source, target, err := e.scanManifests()
+	if err != nil {
+		return nil, err
+	}
+	plan := e.comparePlan(source, target)
+	e.deferDeletions(plan, false)
+	return e.explainPath(rel, source, target, plan), nil
+}
+
+func (e *Engine) explainPath(rel string, source, target *Manifest, plan *SyncPlan) *PathExplanation {
+	ex := &PathExplanation{Path: rel}
+
+	if info, err := os.Stat(filepath.Join(e.config.SourceDir, filepath.FromSlash(rel))); err == nil {
+		ex.ExistsOnSource = true
+		ex.IsDir = info.IsDir()
+	}
+	if f, ok := source.GetFile(rel); ok {
+		ex.InSourceManifest = true
+		ex.IsDir = f.IsDir
+	}
+	if f, ok := target.GetFile(rel); ok {
+		ex.InTargetManifest = true
+		if !ex.InSourceManifest {
+			ex.IsDir = f.IsDir
+		}
+	}
+
+	ex.ExcludeRule, ex.Excluded = e.scanner.matchExclude(rel)
+	if ex.IsDir {
+		ex.Included = true // Include patterns only apply to files
+	} else {
+		ex.IncludeRule, ex.Included = e.scanner.matchInclude(rel)
+	}
+	ex.Managed, _ = isManaged(source, rel, ex.IsDir)
+	if ex.InTargetManifest && !ex.InSourceManifest {
+		ex.ProtectionReason = deletionProtection(source, rel, ex.IsDir, e.compareOptions())
+		if pattern, ok := matchProtected(e.config.NeverDeletePatterns, rel); ok && ex.ProtectionReason == "" {
+			ex.ProtectionReason = "matches never-delete pattern " + pattern
+		}
+		ex.DeletionProtected = ex.ProtectionReason != ""
+	}
+
+	ex.Action, ex.Reason = e.planAction(rel, plan)
+	if ex.Action != "none" {
+		return ex
+	}
+
+	switch {
+	case ex.Excluded:
+		ex.Reason = "excluded by rule " + ex.ExcludeRule
+	case ex.ExistsOnSource && !ex.Included:
+		ex.Reason = "does not match any include pattern"
+	case ex.InSourceManifest && ex.InTargetManifest:
+		ex.Reason = "up to date on target"
+	case ex.DeletionProtected:
+		ex.Reason = "kept on target: " + ex.ProtectionReason
+	case slices.Contains(plan.TargetAdded, rel):
+		ex.Reason = "kept on target: added on the target after the last sync"
+	case !ex.ExistsOnSource && !ex.InTargetManifest:
+		ex.Reason = "not found on source or target"
+	default:
+		ex.Reason = "no change planned"
+	}
+	return ex
+}
+
+// planAction locates rel in plan and returns the planned action and why
+func (e *Engine) planAction(rel string, plan *SyncPlan) (action, reason string) {
+	for _, d := range plan.DirsToCreate {
+		if d == rel {
+			return "mkdir", "directory missing on target"
+		}
+	}
+	for oldP, newP := range plan.Renames {
+		if oldP == rel {
+			return "rename", "matches new source file " + newP + " by size and mtime"
+		}
+		if newP == rel {
+			return "rename", "will be renamed from target file " + oldP
+		}
+		if strings.HasPrefix(rel, oldP+"/") || strings.HasPrefix(rel, newP+"/") {
+			return "rename", "moves along with directory " + oldP + " -> " + newP
+		}
+	}
+	for _, f := range plan.FilesToSync {
+		if f.Path != rel {
+			continue
+		}
+		if v, ok := e.invalidMedia(f); ok {
+			return "invalid-media", "failed media validation (" + v.Error + "), sent again once the source changes"
+		}
+		e.pausedMu.RLock()
+		failTime, failed := e.failedFiles[rel]
+		q, quarantined := e.quarantinedVersion(f)
+		e.pausedMu.RUnlock()
+		if quarantined {
+			return "quarantined", "rejected by the scan hook at " + q.At.Format(time.RFC3339) + " (" + q.Reason + "), sent again once the source changes"
+		}
+		if failed && time.Since(failTime) < failedRetryDelay {
+			return "retry-delayed", "transfer failed at " + failTime.Format(time.RFC3339) + ", retry is delayed"
+		}
+		for _, c := range plan.Conflicts {
+			if c.Path == rel {
+				if pattern, ok := matchProtected(e.config.NeverOverwritePatterns, rel); ok {
+					return "protected", "source differs from target but the path matches never-overwrite pattern " + pattern
+				}
+				return "update", "source differs from target (mtime policy " + e.mtimePolicyName() + ")"
+			}
+		}
+		for _, p := range plan.TargetDeleted {
+			if p == rel {
+				return "add", "deleted on target since the last sync, restored from source"
+			}
+		}
+		return "add", "missing on target"
+	}
+	for _, f := range plan.FilesToDelete {
+		if f == rel {
+			return "delete", "missing on source inside a managed folder"
+		}
+	}
+	for _, d := range plan.DirsToDelete {
+		if d == rel {
+			return "rmdir", "directory missing on source"
+		}
+	}
+	for _, d := range plan.Deferred {
+		if d.Path == rel {
+			return "delete-deferred", fmt.Sprintf("missing on source since %s (%d scans), deletion waits for the deferral window",
+				d.FirstMissing.Format(time.RFC3339), d.Scans)
+		}
+	}
+	return "none", ""
+}
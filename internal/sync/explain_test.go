@@ -0,0 +1,80 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEngine_Explain(t *testing.T) {
+	src := t.TempDir()
+	tgt := t.TempDir()
+
+	write := func(root, rel, content string) {
+		full := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(src, "Show/ep1.mkv", "new episode")
+	write(src, "Show/ep2.mkv", "same")
+	write(tgt, "Show/ep2.mkv", "same")
+	write(src, "Show/notes.txt", "not included")
+	write(src, ".git/config", "excluded")
+	write(tgt, "Show/old.mkv", "removed from source")
+	write(tgt, "Archive/movie.mkv", "receiver only")
+
+	e := NewEngine(SyncConfig{
+		ID: "explain", SourceDir: src, TargetDir: tgt, Rule: "series",
+		ExcludePatterns: []string{".git"},
+		IncludePatterns: []string{"*.mkv"},
+	})
+
+	tests := []struct {
+		path      string
+		action    string
+		excluded  bool
+		included  bool
+		protected bool
+	}{
+		{path: "Show/ep1.mkv", action: "add", included: true},
+		{path: "Show/ep2.mkv", action: "none", included: true},
+		{path: "Show/notes.txt", action: "none"},
+		{path: ".git/config", action: "none", excluded: true},
+		{path: "Show/old.mkv", action: "delete", included: true},
+		{path: "Archive/movie.mkv", action: "none", included: true, protected: true},
+		{path: "Archive", action: "none", included: true, protected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			ex, err := e.Explain(tt.path)
+			if err != nil {
+				t.Fatalf("Explain failed: %v", err)
+			}
+			if ex.Action != tt.action {
+				t.Errorf("Action = %q, want %q (reason: %s)", ex.Action, tt.action, ex.Reason)
+			}
+			if ex.Excluded != tt.excluded {
+				t.Errorf("Excluded = %v, want %v", ex.Excluded, tt.excluded)
+			}
+			if ex.Included != tt.included {
+				t.Errorf("Included = %v, want %v", ex.Included, tt.included)
+			}
+			if ex.DeletionProtected != tt.protected {
+				t.Errorf("DeletionProtected = %v, want %v (%s)", ex.DeletionProtected, tt.protected, ex.ProtectionReason)
+			}
+			if ex.Reason == "" {
+				t.Error("Expected a reason")
+			}
+		})
+	}
+
+	if _, err := e.Explain("../etc/passwd"); err == nil {
+		t.Error("Expected error for path escaping the source")
+	}
+}
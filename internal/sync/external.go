@@ -0,0 +1,274 @@
+package sync
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"time"
+
+	"schnorarr/internal/monitor/database"
+)
+
+// Kinds of target changes made outside of sync
+const (
+	ExternalAdded    = "added"
+	ExternalModified = "modified"
+	ExternalRemoved  = "removed"
+)
+
+// ExternalChange is a target file that changed since the last cycle without
+// the engine changing it, e.g. a file someone copied onto the receiver
+type ExternalChange struct {
+	Path    string    `json:"path"`
+	Kind    string    `json:"kind"`
+	Size    int64     `json:"size"`    // Current size, the last known one for removed files
+	ModTime time.Time `json:"modTime"` // Current modification time, the last known one for removed files
+}
+
+// detectExternalChanges compares the target with the one the last cycle left
+// behind and records what changed outside of sync in plan. Added or modified
+// files the plan would overwrite or delete become conflicts marked External,
+// which always need a decision: the conflict override does not cover them.
+func (e *Engine) detectExternalChanges(target *Manifest, plan *SyncPlan) {
+	e.pausedMu.RLock()
+	last := e.lastTarget
+	e.pausedMu.RUnlock()
+	if last == nil {
+		return
+	}
+	changes, err := externalChanges(last, target, e.config.MtimeTolerance)
+	if err != nil {
+		e.logger().Warn("Cannot compare target with the last cycle", "error", err)
+		return
+	}
+	if len(changes) == 0 {
+		return
+	}
+	plan.External = changes
+	classifyExternal(plan)
+}
+
+// logExternalChanges warns about the external changes found in plan
+func (e *Engine) logExternalChanges(plan *SyncPlan) {
+	if len(plan.External) == 0 {
+		return
+	}
+	counts := make(map[string]int)
+	for _, c := range plan.External {
+		counts[c.Kind]++
+	}
+	held := 0
+	for _, c := range plan.Conflicts {
+		if c.External != "" {
+			held++
+		}
+	}
+	e.logger().Warn("Target changed outside of sync", "added", counts[ExternalAdded], "modified", counts[ExternalModified],
+		"removed", counts[ExternalRemoved], "conflicts", held)
+}
+
+// externalChanges lists the files that differ between the last and the
+// current target, by path. Modification times within tolerance are equal,
+// since the remembered times come from the source.
+func externalChanges(last *CompactManifest, target *Manifest, tolerance time.Duration) ([]*ExternalChange, error) {
+	mtime := MtimeCompare{Tolerance: tolerance}
+	target.mu.RLock()
+	defer target.mu.RUnlock()
+
+	var changes []*ExternalChange
+	seen := make(map[string]bool)
+	err := last.Each(func(old *FileInfo) error {
+		if old.IsDir {
+			return nil
+		}
+		seen[old.Path] = true
+		cur, ok := target.Files[old.Path]
+		switch {
+		case !ok || cur.IsDir:
+			changes = append(changes, &ExternalChange{Path: old.Path, Kind: ExternalRemoved, Size: old.Size, ModTime: old.ModTime})
+		case cur.Size != old.Size || mtime.differs(cur.ModTime, old.ModTime):
+			changes = append(changes, &ExternalChange{Path: old.Path, Kind: ExternalModified, Size: cur.Size, ModTime: cur.ModTime})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for p, cur := range target.Files {
+		if !cur.IsDir && !seen[p] {
+			changes = append(changes, &ExternalChange{Path: p, Kind: ExternalAdded, Size: cur.Size, ModTime: cur.ModTime})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// classifyExternal marks the conflicts of plan that would undo an external
+// change and adds conflicts for external files the plan would delete. Removed
+// files are only reported: the sync restores them and nothing is lost.
+func classifyExternal(plan *SyncPlan) {
+	conflicts := make(map[string]*ConflictDetail)
+	for _, c := range plan.Conflicts {
+		conflicts[c.Path] = c
+	}
+	deleting := make(map[string]bool)
+	for _, p := range plan.FilesToDelete {
+		deleting[p] = true
+	}
+	for oldP := range plan.Renames {
+		deleting[oldP] = true
+	}
+
+	for _, c := range plan.External {
+		if c.Kind == ExternalRemoved {
+			continue
+		}
+		if cd, ok := conflicts[c.Path]; ok {
+			cd.External = c.Kind
+			continue
+		}
+		if deleting[c.Path] || inTrees(c.Path, plan.DirsToDelete) {
+			plan.Conflicts = append(plan.Conflicts, &ConflictDetail{Path: c.Path, ReceiverSize: c.Size, ReceiverTime: c.ModTime, External: c.Kind})
+		}
+	}
+}
+
+// hasExternalConflicts reports whether plan would undo changes made on the
+// target outside of sync
+func hasExternalConflicts(plan *SyncPlan) bool {
+	for _, c := range plan.Conflicts {
+		if c.External != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// inTrees reports whether path lies below one of dirs
+func inTrees(path string, dirs []string) bool {
+	for _, d := range dirs {
+		if strings.HasPrefix(path, d+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// rememberTarget keeps the target as this cycle leaves it for the next
+// comparison. With applied the plan's deletions, renames and transfers (but
+// not those that failed since start) are applied to the scanned target.
+// External conflicts the cycle did not resolve keep their previous entry, so
+// they are detected again until they are approved.
+func (e *Engine) rememberTarget(source, target *Manifest, plan *SyncPlan, applied bool, start time.Time) {
+	e.pausedMu.RLock()
+	last := e.lastTarget
+	e.pausedMu.RUnlock()
+
+	next := NewManifest(target.Root)
+	target.mu.RLock()
+	for p, fi := range target.Files {
+		next.Files[p] = fi
+	}
+	for p := range target.Dirs {
+		next.Dirs[p] = true
+	}
+	target.mu.RUnlock()
+
+	resolved := make(map[string]bool)
+	if applied {
+		for _, p := range plan.FilesToDelete {
+			resolved[p] = true
+			delete(next.Files, p)
+		}
+		dirs := make(map[string]bool)
+		for _, d := range plan.DirsToDelete {
+			dirs[d] = true
+		}
+		next.removeTrees(dirs)
+		for oldP, newP := range plan.Renames {
+			resolved[oldP] = true
+			next.moveTree(oldP, newP)
+		}
+		e.pausedMu.RLock()
+		for _, f := range plan.FilesToSync {
+			if failed, ok := e.failedFiles[f.Path]; ok && !failed.Before(start) {
+				continue
+			}
+			resolved[f.Path] = true
+			next.Files[f.Path] = f
+		}
+		e.pausedMu.RUnlock()
+	}
+
+	pending := make(map[string]bool)
+	for _, c := range plan.Conflicts {
+		if c.External != "" && !resolved[c.Path] && !(applied && inTrees(c.Path, plan.DirsToDelete)) {
+			pending[c.Path] = true
+		}
+	}
+	if len(pending) > 0 && last != nil {
+		previous := make(map[string]*FileInfo)
+		_ = last.Each(func(fi *FileInfo) error {
+			if pending[fi.Path] {
+				cp := *fi
+				previous[fi.Path] = &cp
+			}
+			return nil
+		})
+		for p := range pending {
+			if fi, ok := previous[p]; ok {
+				next.Files[p] = fi
+			} else {
+				delete(next.Files, p)
+			}
+		}
+	}
+
+	e.rememberSynced(source, next)
+
+	c := e.compactManifest(next)
+	if c == nil {
+		return
+	}
+	e.pausedMu.Lock()
+	e.lastTarget = c
+	e.pausedMu.Unlock()
+	if last != nil && bytes.Equal(last.Bytes(), c.Bytes()) {
+		return
+	}
+	if err := database.SaveEngineTargetCache(e.config.ID, c.Bytes()); err != nil {
+		e.logger().Warn("Failed to save target cache", "error", err)
+	}
+}
+
+// holdTarget remembers the target of a cycle an approval gate held
+func (e *Engine) holdTarget(source, target *Manifest, plan *SyncPlan, scanned bool) {
+	if scanned {
+		e.rememberTarget(source, target, plan, false, time.Time{})
+	}
+}
+
+// forgetTargetCache drops the remembered target when the engine changes the
+// target outside of a cycle or switches to another one
+func (e *Engine) forgetTargetCache() {
+	e.pausedMu.Lock()
+	e.lastTarget = nil
+	e.pausedMu.Unlock()
+	_ = database.ClearEngineTargetCache(e.config.ID)
+}
+
+// loadTargetCache restores the remembered target after a restart
+func (e *Engine) loadTargetCache() {
+	data, err := database.LoadEngineTargetCache(e.config.ID)
+	if err != nil || data == nil {
+		return
+	}
+	c, err := DecodeCompactManifest(data)
+	if err != nil {
+		e.logger().Warn("Ignoring unreadable target cache", "error", err)
+		return
+	}
+	e.pausedMu.Lock()
+	e.lastTarget = c
+	e.pausedMu.Unlock()
+}
@@ -0,0 +1,91 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEngine_ExternalTargetChanges(t *testing.T) {
+	source, target := t.TempDir(), t.TempDir()
+	write := func(dir, name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		data, _ := os.ReadFile(filepath.Join(target, name))
+		return string(data)
+	}
+	write(source, "keep.txt", "keep")
+	write(source, "a.txt", "original")
+
+	e := NewEngine(SyncConfig{ID: "external", SourceDir: source, TargetDir: target, Rule: "flat", AutoApproveDeletions: true})
+	if err := e.RunSync(nil); err != nil {
+		t.Fatal(err)
+	}
+	if read("a.txt") != "original" {
+		t.Fatal("First cycle should mirror the source")
+	}
+
+	// Someone edits a file and drops another one on the receiver
+	write(target, "a.txt", "edited on the receiver")
+	write(target, "extra.txt", "mine")
+
+	plan, err := e.PreviewSync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := make(map[string]string)
+	for _, c := range plan.External {
+		kinds[c.Path] = c.Kind
+	}
+	if kinds["a.txt"] != ExternalModified || kinds["extra.txt"] != ExternalAdded || len(kinds) != 2 {
+		t.Errorf("External changes = %v", kinds)
+	}
+
+	// Even with auto-approved deletions the external changes wait for a decision
+	if err := e.RunSync(nil); err != nil {
+		t.Fatal(err)
+	}
+	if !e.IsWaitingForApproval() {
+		t.Fatal("External changes should hold the cycle for approval")
+	}
+	if read("a.txt") != "edited on the receiver" || read("extra.txt") != "mine" {
+		t.Fatal("External changes must not be overwritten or deleted before approval")
+	}
+
+	// They are still detected in the next cycle
+	if err := e.RunSync(nil); err != nil {
+		t.Fatal(err)
+	}
+	if !e.IsWaitingForApproval() || read("extra.txt") != "mine" {
+		t.Fatal("A held cycle must not forget the external changes")
+	}
+
+	e.pausedMu.Lock()
+	e.deletionAllowed = true
+	e.pausedMu.Unlock()
+	if err := e.RunSync(nil); err != nil {
+		t.Fatal(err)
+	}
+	if read("a.txt") != "original" {
+		t.Fatal("Approved external changes should be resolved in favour of the source")
+	}
+	// The file added on the receiver was never synced, so it is not deleted
+	if read("extra.txt") != "mine" {
+		t.Fatal("Files added on the target should be kept")
+	}
+
+	// A file removed on the receiver is restored without asking
+	if err := os.Remove(filepath.Join(target, "keep.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.RunSync(nil); err != nil {
+		t.Fatal(err)
+	}
+	if e.IsWaitingForApproval() || read("keep.txt") != "keep" {
+		t.Error("A removed file should be restored without approval")
+	}
+}
@@ -0,0 +1,92 @@
+package sync
+
+import (
+	"sort"
+	"strings"
+
+	"schnorarr/internal/monitor/database"
+)
+
+// GroupSummary aggregates the engines tagged with one group
+type GroupSummary struct {
+	Name    string   `json:"name"`
+	Engines []string `json:"engines"`
+	Paused  int      `json:"paused"`
+	Active  int      `json:"active"` // Engines transferring right now
+	Speed   int64    `json:"speed"`  // Bytes per second
+	Today   int64    `json:"today"`
+	Total   int64    `json:"total"`
+}
+
+// ParseGroups splits a comma-separated group list, dropping blanks and duplicates
+func ParseGroups(list string) []string {
+	var groups []string
+	seen := make(map[string]bool)
+	for _, g := range strings.Split(list, ",") {
+		g = strings.TrimSpace(g)
+		if g == "" || seen[strings.ToLower(g)] {
+			continue
+		}
+		seen[strings.ToLower(g)] = true
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+// HasGroup reports whether the engine is tagged with group (case-insensitive)
+func (e *Engine) HasGroup(group string) bool {
+	for _, g := range e.GetConfig().Groups {
+		if strings.EqualFold(g, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// EnginesInGroup returns the engines tagged with group
+func EnginesInGroup(engines []*Engine, group string) []*Engine {
+	var members []*Engine
+	for _, e := range engines {
+		if e.HasGroup(group) {
+			members = append(members, e)
+		}
+	}
+	return members
+}
+
+// SummarizeGroups aggregates state and traffic per group, sorted by name
+func SummarizeGroups(engines []*Engine) []GroupSummary {
+	byName := make(map[string]*GroupSummary)
+	for _, e := range engines {
+		cfg := e.GetConfig()
+		if len(cfg.Groups) == 0 {
+			continue
+		}
+		stats := database.GetEngineTrafficStats(cfg.ID)
+		_, _, _, speed, _, _ := e.GetTransferStatsExtended()
+		for _, g := range cfg.Groups {
+			key := strings.ToLower(g)
+			s, ok := byName[key]
+			if !ok {
+				s = &GroupSummary{Name: g}
+				byName[key] = s
+			}
+			s.Engines = append(s.Engines, cfg.ID)
+			if e.IsPaused() {
+				s.Paused++
+			}
+			if speed > 0 {
+				s.Active++
+			}
+			s.Speed += speed
+			s.Today += stats.Today
+			s.Total += stats.Total
+		}
+	}
+	groups := make([]GroupSummary, 0, len(byName))
+	for _, s := range byName {
+		groups = append(groups, *s)
+	}
+	sort.Slice(groups, func(i, j int) bool { return strings.ToLower(groups[i].Name) < strings.ToLower(groups[j].Name) })
+	return groups
+}
@@ -0,0 +1,40 @@
+package sync
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGroups(t *testing.T) {
+	got := ParseGroups(" 4K, offsite,,4k ,Anime ")
+	want := []string{"4K", "offsite", "Anime"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseGroups = %v, want %v", got, want)
+	}
+	if got := ParseGroups(""); got != nil {
+		t.Errorf("Empty list should have no groups, got %v", got)
+	}
+}
+
+func TestSummarizeGroups(t *testing.T) {
+	a := NewEngine(SyncConfig{ID: "1", SourceDir: t.TempDir(), TargetDir: t.TempDir(), Groups: []string{"4K", "offsite"}})
+	b := NewEngine(SyncConfig{ID: "2", SourceDir: t.TempDir(), TargetDir: t.TempDir(), Groups: []string{"offsite"}})
+	c := NewEngine(SyncConfig{ID: "3", SourceDir: t.TempDir(), TargetDir: t.TempDir()})
+	b.Pause()
+	engines := []*Engine{a, b, c}
+
+	if got := EnginesInGroup(engines, "OFFSITE"); len(got) != 2 {
+		t.Errorf("Group lookup should ignore case, got %d engines", len(got))
+	}
+	if got := EnginesInGroup(engines, "missing"); len(got) != 0 {
+		t.Errorf("Unknown group should have no engines, got %d", len(got))
+	}
+
+	groups := SummarizeGroups(engines)
+	if len(groups) != 2 || groups[0].Name != "4K" || groups[1].Name != "offsite" {
+		t.Fatalf("Expected groups 4K and offsite, got %+v", groups)
+	}
+	if off := groups[1]; !reflect.DeepEqual(off.Engines, []string{"1", "2"}) || off.Paused != 1 {
+		t.Errorf("offsite should list engines 1 and 2 with one paused, got %+v", off)
+	}
+}
@@ -0,0 +1,135 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Integrity check outcomes
+const (
+	IntegrityMatch    = "match"
+	IntegrityDiverged = "diverged"
+	IntegrityUnknown  = "unknown" // No expectation to compare with
+)
+
+// TargetSummary condenses a target tree: receivers report it for their
+// paths and engines remember the one of the target they last saw
+type TargetSummary struct {
+	Path    string    `json:"path"`
+	Digest  string    `json:"digest"` // Manifest.Digest, the receiver's manifest ETag
+	Files   int       `json:"files"`
+	Bytes   int64     `json:"bytes"`
+	Scanned time.Time `json:"scanned"`
+}
+
+// Summarize returns the summary of a manifest scanned at the given time
+func Summarize(path string, m *Manifest, scanned time.Time) TargetSummary {
+	s := TargetSummary{Path: path, Digest: m.Digest(), Scanned: scanned}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, fi := range m.Files {
+		if !fi.IsDir {
+			s.Files++
+			s.Bytes += fi.Size
+		}
+	}
+	return s
+}
+
+// IntegrityResult is the comparison of a receiver report with what the
+// engine expects its target to look like
+type IntegrityResult struct {
+	Status   string         `json:"status"`
+	Reason   string         `json:"reason,omitempty"`
+	Expected *TargetSummary `json:"expected,omitempty"`
+	Reported TargetSummary  `json:"reported"`
+	Checked  time.Time      `json:"checked"`
+}
+
+// expectTarget remembers the target manifest of the running cycle. Until the
+// engine changes the target itself, receiver reports should match it.
+func (e *Engine) expectTarget(m *Manifest) {
+	if !e.IsRemoteScan() {
+		return
+	}
+	s := Summarize("", m, time.Now())
+	e.pausedMu.Lock()
+	e.expectedTarget = &s
+	e.pausedMu.Unlock()
+}
+
+// forgetTarget drops the expectation before the engine changes the target
+func (e *Engine) forgetTarget() {
+	e.pausedMu.Lock()
+	e.expectedTarget = nil
+	e.pausedMu.Unlock()
+}
+
+// CoversReport reports whether a receiver path is this engine's remote target
+func (e *Engine) CoversReport(path string) bool {
+	host, remotePath := ParseRemoteDestination(e.GetConfig().TargetDir)
+	if host == "" || path == "" {
+		return false
+	}
+	path = strings.Trim(path, "/")
+	remotePath = strings.Trim(remotePath, "/")
+	return remotePath == path || strings.HasSuffix(remotePath, "/"+path)
+}
+
+// CheckIntegrity compares a receiver report of the target with the target
+// the engine saw in its last cycle and keeps the result
+func (e *Engine) CheckIntegrity(report TargetSummary) IntegrityResult {
+	res := IntegrityResult{Status: IntegrityUnknown, Reported: report, Checked: time.Now()}
+	if !e.syncMu.TryLock() {
+		res.Reason = "sync running"
+	} else {
+		e.pausedMu.RLock()
+		expected := e.expectedTarget
+		e.pausedMu.RUnlock()
+		e.syncMu.Unlock()
+
+		switch {
+		case expected == nil:
+			res.Reason = "no sync cycle since the engine last changed the target"
+		case report.Scanned.Before(expected.Scanned):
+			res.Reason = "report predates the last sync cycle"
+			res.Expected = expected
+		case report.Digest == expected.Digest:
+			res.Status = IntegrityMatch
+			res.Expected = expected
+		default:
+			res.Status = IntegrityDiverged
+			res.Expected = expected
+			res.Reason = fmt.Sprintf("target has %d files (%d bytes), expected %d files (%d bytes)",
+				report.Files, report.Bytes, expected.Files, expected.Bytes)
+			if report.Files == expected.Files && report.Bytes == expected.Bytes {
+				res.Reason = "files on the target were modified or replaced"
+			}
+		}
+	}
+	e.pausedMu.Lock()
+	e.integrity = &res
+	e.pausedMu.Unlock()
+	return res
+}
+
+// GetIntegrity returns the result of the last receiver report, or nil
+func (e *Engine) GetIntegrity() *IntegrityResult {
+	e.pausedMu.RLock()
+	defer e.pausedMu.RUnlock()
+	return e.integrity
+}
+
+// TargetDiverged reports whether the last integrity check found the target
+// changed outside of sync
+func (e *Engine) TargetDiverged() bool {
+	r := e.GetIntegrity()
+	return r != nil && r.Status == IntegrityDiverged
+}
+
+// IntegrityReport is what a receiver pushes to its sender
+type IntegrityReport struct {
+	Receiver string          `json:"receiver"`
+	Targets  []TargetSummary `json:"targets"`
+}
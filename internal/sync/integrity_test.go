@@ -0,0 +1,52 @@
+package sync
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEngine_CheckIntegrity(t *testing.T) {
+	e := NewEngine(SyncConfig{ID: "integrity", SourceDir: t.TempDir(), TargetDir: "127.0.0.1::video-sync/tv"})
+
+	if !e.CoversReport("tv") || !e.CoversReport("/tv/") || e.CoversReport("movies") {
+		t.Error("CoversReport should match the module-relative target path only")
+	}
+
+	m := NewManifest("/data/tv")
+	mod := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	m.Add(&FileInfo{Path: "Show/S01E01.mkv", Size: 100, ModTime: mod})
+	m.Add(&FileInfo{Path: "Show/S01E02.mkv", Size: 200, ModTime: mod})
+
+	report := Summarize("tv", m, time.Now().Add(time.Minute))
+	if report.Files != 2 || report.Bytes != 300 || report.Digest != m.Digest() {
+		t.Fatalf("Summarize = %+v", report)
+	}
+
+	if res := e.CheckIntegrity(report); res.Status != IntegrityUnknown {
+		t.Errorf("without expectation: status %q, want unknown", res.Status)
+	}
+
+	e.expectTarget(m)
+	if res := e.CheckIntegrity(report); res.Status != IntegrityMatch {
+		t.Errorf("same tree: status %q (%s), want match", res.Status, res.Reason)
+	}
+	if res := e.CheckIntegrity(Summarize("tv", m, time.Now().Add(-time.Hour))); res.Status != IntegrityUnknown {
+		t.Errorf("stale report: status %q, want unknown", res.Status)
+	}
+
+	changed := NewManifest("/data/tv")
+	changed.Add(&FileInfo{Path: "Show/S01E01.mkv", Size: 100, ModTime: mod})
+	changed.Add(&FileInfo{Path: "Show/S01E02.mkv", Size: 200, ModTime: mod.Add(time.Hour)})
+	res := e.CheckIntegrity(Summarize("tv", changed, time.Now().Add(time.Minute)))
+	if res.Status != IntegrityDiverged || res.Reason != "files on the target were modified or replaced" {
+		t.Errorf("modified file: got %q (%s), want diverged", res.Status, res.Reason)
+	}
+	if !e.TargetDiverged() {
+		t.Error("TargetDiverged should report the last result")
+	}
+
+	e.forgetTarget()
+	if res := e.CheckIntegrity(report); res.Status != IntegrityUnknown || e.TargetDiverged() {
+		t.Errorf("after the engine changed the target: status %q, want unknown", res.Status)
+	}
+}
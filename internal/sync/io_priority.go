@@ -0,0 +1,62 @@
+package sync
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// IO scheduling classes as understood by ionice(1) and ioprio_set(2)
+const (
+	IOClassNone       = 0
+	IOClassRealtime   = 1
+	IOClassBestEffort = 2
+	IOClassIdle       = 3
+)
+
+// ParseIOClass converts an ionice class name or number into its numeric value.
+// An empty string yields IOClassNone (leave priority untouched).
+func ParseIOClass(s string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "none":
+		return IOClassNone, nil
+	case "realtime", "rt", "1":
+		return IOClassRealtime, nil
+	case "best-effort", "besteffort", "be", "2":
+		return IOClassBestEffort, nil
+	case "idle", "3":
+		return IOClassIdle, nil
+	}
+	return IOClassNone, fmt.Errorf("invalid IO class %q (use idle, best-effort or realtime)", s)
+}
+
+// ParseIOLevel validates an ionice priority level (0 = highest, 7 = lowest)
+func ParseIOLevel(s string) (int, error) {
+	if strings.TrimSpace(s) == "" {
+		return 4, nil // Kernel default for best-effort
+	}
+	level, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || level < 0 || level > 7 {
+		return 0, fmt.Errorf("invalid IO level %q (must be 0-7)", s)
+	}
+	return level, nil
+}
+
+// ioniceCommand wraps name/args with ionice when a class is configured and
+// the ionice binary is available. Otherwise the command is returned unchanged.
+func ioniceCommand(class, level int, name string, args []string) (string, []string) {
+	if class == IOClassNone {
+		return name, args
+	}
+	ionice, err := exec.LookPath("ionice")
+	if err != nil {
+		return name, args
+	}
+	wrapped := []string{"-c", strconv.Itoa(class)}
+	if class != IOClassIdle {
+		wrapped = append(wrapped, "-n", strconv.Itoa(level))
+	}
+	wrapped = append(wrapped, name)
+	return ionice, append(wrapped, args...)
+}
@@ -0,0 +1,105 @@
+//go:build linux
+
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+)
+
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+)
+
+// withIOPriority runs fn on a locked OS thread whose IO priority is lowered
+// to class/level. The previous priority is restored before the thread is released.
+func withIOPriority(class, level int, fn func()) {
+	if class == IOClassNone {
+		fn()
+		return
+	}
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	tid := syscall.Gettid()
+	prev, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_GET, ioprioWhoProcess, uintptr(tid), 0)
+	if errno != 0 {
+		fn()
+		return
+	}
+	prio := uintptr(class<<ioprioClassShift | level)
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), prio); errno != 0 {
+		fn()
+		return
+	}
+	defer func() {
+		_, _, _ = syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), prev)
+	}()
+	fn()
+}
+
+// ApplyIOMax writes a cgroup v2 io.max limit (e.g. "wbps=52428800") for the
+// block device backing path into the cgroup this process runs in, so it
+// covers all IO of the process on that disk.
+func ApplyIOMax(path, spec string) error {
+	if spec == "" {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("unsupported stat result for %s", path)
+	}
+	device := blockDevice(uint64(st.Dev))
+
+	cgroup, err := ownCgroupPath()
+	if err != nil {
+		return err
+	}
+	ioMax := filepath.Join("/sys/fs/cgroup", cgroup, "io.max")
+	if err := os.WriteFile(ioMax, []byte(device+" "+spec), 0644); err != nil {
+		return fmt.Errorf("failed to write %s (is the cgroup v2 io controller delegated to the container?): %w", ioMax, err)
+	}
+	return nil
+}
+
+// blockDevice returns MAJ:MIN for dev, resolving partitions to their parent
+// disk because io.max only accepts whole devices.
+func blockDevice(dev uint64) string {
+	major := (dev>>8)&0xfff | (dev>>32)&^uint64(0xfff)
+	minor := dev&0xff | (dev>>12)&^uint64(0xff)
+	device := fmt.Sprintf("%d:%d", major, minor)
+
+	sysPath := filepath.Join("/sys/dev/block", device)
+	if _, err := os.Stat(filepath.Join(sysPath, "partition")); err == nil {
+		// The link points into the disk's directory in /sys/devices
+		if resolved, err := filepath.EvalSymlinks(sysPath); err == nil {
+			if parent, err := os.ReadFile(filepath.Join(filepath.Dir(resolved), "dev")); err == nil {
+				return strings.TrimSpace(string(parent))
+			}
+		}
+	}
+	return device
+}
+
+// ownCgroupPath reads the unified (v2) cgroup of the current process
+func ownCgroupPath() (string, error) {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", fmt.Errorf("failed to read cgroup membership: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "0::") {
+			return strings.TrimPrefix(line, "0::"), nil
+		}
+	}
+	return "", fmt.Errorf("cgroup v2 not available")
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package sync
+
+import "fmt"
+
+// withIOPriority runs fn unchanged; IO priorities are Linux-only
+func withIOPriority(class, level int, fn func()) {
+	fn()
+}
+
+// ApplyIOMax is unsupported outside Linux
+func ApplyIOMax(path, spec string) error {
+	if spec == "" {
+		return nil
+	}
+	return fmt.Errorf("io.max limits require Linux cgroup v2")
+}
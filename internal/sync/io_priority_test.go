@@ -0,0 +1,74 @@
+package sync
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestParseIOClass(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+		wantErr  bool
+	}{
+		{"", IOClassNone, false},
+		{"idle", IOClassIdle, false},
+		{"IDLE", IOClassIdle, false},
+		{"best-effort", IOClassBestEffort, false},
+		{"be", IOClassBestEffort, false},
+		{"2", IOClassBestEffort, false},
+		{"realtime", IOClassRealtime, false},
+		{"lowest", IOClassNone, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseIOClass(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseIOClass(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+		}
+		if got != tt.expected {
+			t.Errorf("ParseIOClass(%q) = %d, want %d", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestParseIOLevel(t *testing.T) {
+	if level, err := ParseIOLevel(""); err != nil || level != 4 {
+		t.Errorf("Expected default level 4, got %d (%v)", level, err)
+	}
+	if level, err := ParseIOLevel("7"); err != nil || level != 7 {
+		t.Errorf("Expected level 7, got %d (%v)", level, err)
+	}
+	if _, err := ParseIOLevel("8"); err == nil {
+		t.Error("Expected error for out-of-range level")
+	}
+}
+
+func TestIoniceCommand(t *testing.T) {
+	name, args := ioniceCommand(IOClassNone, 0, "rsync", []string{"-a"})
+	if name != "rsync" || len(args) != 1 {
+		t.Errorf("Expected unchanged command, got %s %v", name, args)
+	}
+
+	if _, err := exec.LookPath("ionice"); err != nil {
+		t.Skip("ionice not installed")
+	}
+	_, args = ioniceCommand(IOClassIdle, 4, "rsync", []string{"-a"})
+	expected := []string{"-c", "3", "rsync", "-a"}
+	if len(args) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, args)
+	}
+	for i := range expected {
+		if args[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, args)
+			break
+		}
+	}
+}
+
+func TestWithIOPriority_RunsFunction(t *testing.T) {
+	ran := false
+	withIOPriority(IOClassIdle, 7, func() { ran = true })
+	if !ran {
+		t.Error("withIOPriority did not run the function")
+	}
+}
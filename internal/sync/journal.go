@@ -0,0 +1,209 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	stdsync "sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultJournalCapacity is how many changes a journal retains for cursors
+const DefaultJournalCapacity = 10000
+
+// ChangeJournal watches a receiver tree and records changed paths under an
+// increasing sequence number, so senders can ask for everything that changed
+// since a cursor instead of pulling a full manifest every cycle.
+//
+// Cursors have the form "<epoch>-<seq>". The epoch changes with every start,
+// so a cursor from before a restart always requires a full resync, as do
+// cursors older than the retained window and every cursor after the journal
+// failed to watch part of the tree.
+type ChangeJournal struct {
+	root     string
+	epoch    string
+	capacity int
+	watcher  *fsnotify.Watcher
+
+	mu       stdsync.Mutex
+	seq      uint64
+	entries  []journalEntry // Oldest first, at most capacity entries
+	complete bool           // False once a subtree could not be watched
+}
+
+type journalEntry struct {
+	seq  uint64
+	path string // Relative to root, slash separated
+}
+
+// NewChangeJournal starts watching root recursively
+func NewChangeJournal(root string, capacity int) (*ChangeJournal, error) {
+	if capacity <= 0 {
+		capacity = DefaultJournalCapacity
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watcher: %w", err)
+	}
+	j := &ChangeJournal{root: root, epoch: newCycleID(), capacity: capacity, watcher: watcher, complete: true}
+	if err := j.watchTree(root); err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+	go j.run()
+	scanLog.Info("Change journal started", "root", root, "complete", j.Complete())
+	return j, nil
+}
+
+// Root returns the watched directory
+func (j *ChangeJournal) Root() string { return j.root }
+
+// Complete reports whether the whole tree is watched
+func (j *ChangeJournal) Complete() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.complete
+}
+
+// Close stops watching
+func (j *ChangeJournal) Close() error { return j.watcher.Close() }
+
+func (j *ChangeJournal) watchTree(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil // Removed while walking
+			}
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if err := j.watcher.Add(path); err != nil {
+			if isWatchLimitError(err) {
+				j.mu.Lock()
+				first := j.complete
+				j.complete = false
+				j.mu.Unlock()
+				if first {
+					scanLog.Warn("inotify watch limit reached, change journal disabled; senders fall back to full manifests",
+						"max_user_watches", readMaxUserWatches())
+				}
+				return filepath.SkipAll
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+func (j *ChangeJournal) run() {
+	for {
+		select {
+		case event, ok := <-j.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := j.watchTree(event.Name); err != nil {
+						scanLog.Warn("Failed to watch new directory", "path", event.Name, "error", err)
+					}
+				}
+			}
+			j.record(event.Name)
+		case err, ok := <-j.watcher.Errors:
+			if !ok {
+				return
+			}
+			// Dropped events (queue overflow) leave the journal with gaps
+			scanLog.Warn("Change journal watcher error", "error", err)
+			j.mu.Lock()
+			j.complete = false
+			j.mu.Unlock()
+		}
+	}
+}
+
+func (j *ChangeJournal) record(path string) {
+	rel, err := filepath.Rel(j.root, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.seq++
+	j.entries = append(j.entries, journalEntry{seq: j.seq, path: filepath.ToSlash(rel)})
+	if len(j.entries) > j.capacity {
+		j.entries = append(j.entries[:0], j.entries[len(j.entries)-j.capacity:]...)
+	}
+}
+
+// Changes returns the paths below prefix (relative to it) that changed after
+// cursor, reduced to the topmost changed paths, and the cursor to pass next
+// time. reset means the caller must fetch the full manifest instead.
+func (j *ChangeJournal) Changes(prefix, cursor string) (changes []string, next string, reset bool) {
+	prefix = strings.Trim(filepath.ToSlash(prefix), "/")
+	if prefix == "." {
+		prefix = ""
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	next = j.epoch + "-" + strconv.FormatUint(j.seq, 10)
+
+	epoch, seqStr, ok := strings.Cut(cursor, "-")
+	since, err := strconv.ParseUint(seqStr, 10, 64)
+	if !ok || err != nil || epoch != j.epoch || since > j.seq || !j.complete {
+		return nil, next, true
+	}
+	if len(j.entries) > 0 && since+1 < j.entries[0].seq {
+		return nil, next, true // Older than the retained window
+	}
+
+	seen := make(map[string]bool)
+	for _, e := range j.entries {
+		if e.seq <= since {
+			continue
+		}
+		rel := e.path
+		if prefix != "" {
+			if rel == prefix || strings.HasPrefix(prefix, rel+"/") {
+				return nil, next, true // The prefix itself or a parent was replaced
+			}
+			if !strings.HasPrefix(rel, prefix+"/") {
+				continue
+			}
+			rel = rel[len(prefix)+1:]
+		}
+		seen[rel] = true
+	}
+	return topmostPaths(seen), next, false
+}
+
+// topmostPaths drops every path that has an ancestor in the set
+func topmostPaths(set map[string]bool) []string {
+	var paths []string
+	for p := range set {
+		covered := false
+		for parent := p; ; {
+			idx := strings.LastIndex(parent, "/")
+			if idx < 0 {
+				break
+			}
+			parent = parent[:idx]
+			if set[parent] {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
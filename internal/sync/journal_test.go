@@ -0,0 +1,82 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChangeJournal_Changes(t *testing.T) {
+	j := &ChangeJournal{root: "/data", epoch: "e1", capacity: 3, complete: true}
+	_, start, reset := j.Changes("", "")
+	if !reset {
+		t.Error("An empty cursor should require a full manifest")
+	}
+
+	j.record("/data/tv/Show/S01/e1.mkv")
+	j.record("/data/tv/Show/S01")
+	j.record("/data/movies/A/a.mkv")
+	changes, next, reset := j.Changes("tv", start)
+	if reset || !reflect.DeepEqual(changes, []string{"Show/S01"}) {
+		t.Errorf("Expected [Show/S01], got %v (reset %v)", changes, reset)
+	}
+	if changes, _, reset := j.Changes("tv", next); reset || len(changes) != 0 {
+		t.Errorf("Expected no changes since the latest cursor, got %v", changes)
+	}
+
+	j.record("/data/tv")
+	if _, _, reset := j.Changes("tv/Show", next); !reset {
+		t.Error("A changed parent of the prefix should reset")
+	}
+	if _, _, reset := j.Changes("", start); !reset {
+		t.Error("A cursor older than the retained window should reset")
+	}
+	if _, _, reset := j.Changes("", "e0-1"); !reset {
+		t.Error("A cursor from another epoch should reset")
+	}
+	j.complete = false
+	if _, _, reset := j.Changes("", next); !reset {
+		t.Error("An incomplete journal should always reset")
+	}
+}
+
+func TestTopmostPaths(t *testing.T) {
+	got := topmostPaths(map[string]bool{"a": true, "a/b": true, "a/b/c": true, "ab": true, "x/y": true})
+	if want := []string{"a", "ab", "x/y"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestChangeJournal_Watch(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "tv", "Show"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	j, err := NewChangeJournal(root, 0)
+	if err != nil {
+		t.Fatalf("Failed to start journal: %v", err)
+	}
+	defer func() { _ = j.Close() }()
+	_, cursor, _ := j.Changes("tv", "")
+
+	if err := os.WriteFile(filepath.Join(root, "tv", "Show", "e1.mkv"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		changes, _, reset := j.Changes("tv", cursor)
+		if reset {
+			t.Fatal("Unexpected reset")
+		}
+		if len(changes) > 0 {
+			if changes[0] != "Show/e1.mkv" {
+				t.Errorf("Expected Show/e1.mkv, got %v", changes)
+			}
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("Change was not recorded")
+}
@@ -0,0 +1,42 @@
+package sync
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+
+	"schnorarr/internal/monitor/logging"
+)
+
+// Module loggers of the sync package
+var (
+	syncLog     = logging.For("sync")
+	scanLog     = logging.For("scanner")
+	transferLog = logging.For("transfer")
+)
+
+// newCycleID returns a short random ID that correlates everything one sync cycle does
+func newCycleID() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// CurrentCycle returns the ID of the running sync cycle, or "" between cycles
+func (e *Engine) CurrentCycle() string {
+	cycle, _ := e.cycleID.Load().(string)
+	return cycle
+}
+
+// tagged adds the engine ID and, while a sync is running, the ID of the current cycle
+func (e *Engine) tagged(l *slog.Logger) *slog.Logger {
+	if cycle := e.CurrentCycle(); cycle != "" {
+		return l.With("engine", e.config.ID, "cycle", cycle)
+	}
+	return l.With("engine", e.config.ID)
+}
+
+// logger returns the engine logger
+func (e *Engine) logger() *slog.Logger {
+	return e.tagged(syncLog)
+}
@@ -25,6 +25,8 @@ type Manifest struct {
 	Root  string               `json:"root"`
 	Files map[string]*FileInfo `json:"files"`
 	Dirs  map[string]bool      `json:"dirs"`
+	// Skipped lists entries the scan left out, for the plan preview
+	Skipped []*SkippedItem `json:"-"`
 
 	// Non-exported case-insensitive index
 	lowerFiles map[string]string
@@ -55,6 +57,56 @@ func (m *Manifest) Add(info *FileInfo) {
 	m.lowerDirs = nil
 }
 
+// removeTrees deletes every entry that equals or lies below one of the paths
+func (m *Manifest) removeTrees(paths map[string]bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for p := range m.Files {
+		for cur := p; ; {
+			if paths[cur] {
+				delete(m.Files, p)
+				delete(m.Dirs, p)
+				break
+			}
+			idx := strings.LastIndex(cur, "/")
+			if idx < 0 {
+				break
+			}
+			cur = cur[:idx]
+		}
+	}
+	m.lowerFiles = nil
+	m.lowerDirs = nil
+}
+
+// moveTree moves the entry at oldPath and everything below it to newPath.
+// It returns the old and new paths of the files moved.
+func (m *Manifest) moveTree(oldPath, newPath string) map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	moved := make(map[string]string)
+	for p, fi := range m.Files {
+		rel, ok := strings.CutPrefix(p, oldPath)
+		if !ok || (rel != "" && rel[0] != '/') {
+			continue
+		}
+		to := newPath + rel
+		copied := *fi
+		copied.Path = to
+		delete(m.Files, p)
+		delete(m.Dirs, p)
+		m.Files[to] = &copied
+		if copied.IsDir {
+			m.Dirs[to] = true
+		} else {
+			moved[p] = to
+		}
+	}
+	m.lowerFiles = nil
+	m.lowerDirs = nil
+	return moved
+}
+
 // HasFile checks if a file exists in the manifest (exact match)
 func (m *Manifest) HasFile(path string) bool {
 	m.mu.RLock()
@@ -156,20 +208,7 @@ func (fi *FileInfo) ComputeHash(fullPath string) error {
 // NeedsUpdate determines if a file should be updated based on size/mtime comparison.
 // It uses a 1-second threshold to ignore sub-second differences.
 func (fi *FileInfo) NeedsUpdate(other *FileInfo) bool {
-	if fi.IsDir != other.IsDir {
-		return true
-	}
-
-	if fi.IsDir {
-		return false // Directories don't need updates
-	}
-
-	if fi.Size != other.Size {
-		return true
-	}
-
-	// Truncate to seconds for comparison to avoid precision mismatches
-	return fi.ModTime.Unix() > other.ModTime.Unix()
+	return MtimeCompare{}.NeedsUpdate(fi, other)
 }
 
 // GetFileCountInDir counts how many files (not directories) are directly inside the given directory path.
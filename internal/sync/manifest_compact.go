@@ -0,0 +1,322 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// compactMagic starts every encoded manifest, followed by the format version
+const compactMagic = "SCMF\x01"
+
+const (
+	compactDir = 1 << iota
+	compactHash
+	compactZeroTime
+)
+
+// CompactManifest is an immutable, gzip compressed encoding of a manifest.
+// Entries are sorted by path; each path only stores the suffix that differs
+// from its predecessor and modification times are stored as deltas. A
+// library of millions of files takes a few bytes per entry instead of a map
+// entry with its own strings and pointers, so engines keep manifests they
+// hold between sync cycles (the last scanned source, a queued manifest) in
+// this form and only expand them when a cycle actually runs. Scans and
+// comparisons still work on full manifests, so a running cycle needs the
+// memory of both of them.
+type CompactManifest struct {
+	Root  string
+	data  []byte
+	files int   // Entries that are not directories
+	bytes int64 // Total size of those files
+}
+
+// Compact encodes the manifest
+func (m *Manifest) Compact() (*CompactManifest, error) {
+	m.mu.RLock()
+	paths := make([]string, 0, len(m.Files))
+	for p := range m.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	c := &CompactManifest{Root: m.Root}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	w := bufio.NewWriter(zw)
+	scratch := make([]byte, binary.MaxVarintLen64)
+	putUvarint := func(v uint64) { _, _ = w.Write(scratch[:binary.PutUvarint(scratch, v)]) }
+	putVarint := func(v int64) { _, _ = w.Write(scratch[:binary.PutVarint(scratch, v)]) }
+	putString := func(s string) {
+		putUvarint(uint64(len(s)))
+		_, _ = w.WriteString(s)
+	}
+
+	_, _ = w.WriteString(compactMagic)
+	putString(m.Root)
+	putUvarint(uint64(len(paths)))
+	prevPath, prevTime := "", int64(0)
+	for _, p := range paths {
+		fi := m.Files[p]
+		shared := commonPrefixLen(prevPath, p)
+		putUvarint(uint64(shared))
+		putString(p[shared:])
+		prevPath = p
+
+		var flags byte
+		if fi.IsDir {
+			flags |= compactDir
+		} else {
+			c.files++
+			c.bytes += fi.Size
+		}
+		if fi.Hash != "" {
+			flags |= compactHash
+		}
+		if fi.ModTime.IsZero() {
+			flags |= compactZeroTime
+		}
+		_ = w.WriteByte(flags)
+		putVarint(fi.Size)
+		if !fi.ModTime.IsZero() {
+			t := fi.ModTime.UnixNano()
+			putVarint(t - prevTime)
+			prevTime = t
+		}
+		if fi.Hash != "" {
+			putString(fi.Hash)
+		}
+	}
+	m.mu.RUnlock()
+
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	c.data = buf.Bytes()
+	return c, nil
+}
+
+// DecodeCompactManifest reads a manifest encoded with CompactManifest.Bytes
+func DecodeCompactManifest(data []byte) (*CompactManifest, error) {
+	c := &CompactManifest{data: data}
+	err := c.decode(func(root string) { c.Root = root }, func(fi *FileInfo) error {
+		if !fi.IsDir {
+			c.files++
+			c.bytes += fi.Size
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Bytes returns the encoded manifest
+func (c *CompactManifest) Bytes() []byte { return c.data }
+
+// FileCount returns the number of files, excluding directories
+func (c *CompactManifest) FileCount() int { return c.files }
+
+// TotalSize returns the total size of all files
+func (c *CompactManifest) TotalSize() int64 { return c.bytes }
+
+// Each streams the entries in path order without materializing the manifest.
+// Returning an error from fn stops the iteration and returns that error.
+func (c *CompactManifest) Each(fn func(*FileInfo) error) error {
+	return c.decode(nil, fn)
+}
+
+func (c *CompactManifest) decode(onRoot func(string), fn func(*FileInfo) error) error {
+	zr, err := gzip.NewReader(bytes.NewReader(c.data))
+	if err != nil {
+		return fmt.Errorf("invalid compact manifest: %w", err)
+	}
+	defer func() { _ = zr.Close() }()
+	r := bufio.NewReader(zr)
+
+	magic := make([]byte, len(compactMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != compactMagic {
+		return errors.New("invalid compact manifest: bad header")
+	}
+	readString := func() (string, error) {
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return "", err
+		}
+		b := make([]byte, n)
+		_, err = io.ReadFull(r, b)
+		return string(b), err
+	}
+
+	root, err := readString()
+	if err != nil {
+		return fmt.Errorf("invalid compact manifest: %w", err)
+	}
+	if onRoot != nil {
+		onRoot(root)
+	}
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return fmt.Errorf("invalid compact manifest: %w", err)
+	}
+
+	prevPath, prevTime := "", int64(0)
+	for i := uint64(0); i < count; i++ {
+		fi, err := readCompactEntry(r, readString, &prevPath, &prevTime)
+		if err != nil {
+			return fmt.Errorf("invalid compact manifest entry %d: %w", i, err)
+		}
+		if err := fn(fi); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readCompactEntry(r *bufio.Reader, readString func() (string, error), prevPath *string, prevTime *int64) (*FileInfo, error) {
+	shared, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if shared > uint64(len(*prevPath)) {
+		return nil, errors.New("prefix longer than previous path")
+	}
+	suffix, err := readString()
+	if err != nil {
+		return nil, err
+	}
+	fi := &FileInfo{Path: (*prevPath)[:shared] + suffix}
+	*prevPath = fi.Path
+
+	flags, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	fi.IsDir = flags&compactDir != 0
+	if fi.Size, err = binary.ReadVarint(r); err != nil {
+		return nil, err
+	}
+	if flags&compactZeroTime == 0 {
+		delta, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, err
+		}
+		*prevTime += delta
+		fi.ModTime = time.Unix(0, *prevTime)
+	}
+	if flags&compactHash != 0 {
+		if fi.Hash, err = readString(); err != nil {
+			return nil, err
+		}
+	}
+	return fi, nil
+}
+
+// Expand decodes the full manifest
+func (c *CompactManifest) Expand() (*Manifest, error) {
+	m := NewManifest(c.Root)
+	if err := c.Each(func(fi *FileInfo) error {
+		m.Add(fi)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// errManifestChanged stops the streaming comparison at the first difference
+var errManifestChanged = errors.New("manifest changed")
+
+// Differs reports whether current contains any entry that was added, removed or
+// changed in type, size or modification time. It walks both manifests in path
+// order and stops at the first difference, holding only the sorted path list
+// of current in addition to the manifests themselves.
+func (c *CompactManifest) Differs(current *Manifest) (bool, error) {
+	current.mu.RLock()
+	defer current.mu.RUnlock()
+	paths := make([]string, 0, len(current.Files))
+	for p := range current.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	i := 0
+	err := c.Each(func(old *FileInfo) error {
+		if i >= len(paths) || paths[i] != old.Path {
+			return errManifestChanged // Added before this path, or removed
+		}
+		fi := current.Files[paths[i]]
+		i++
+		if fi.IsDir != old.IsDir || (!fi.IsDir && (fi.Size != old.Size || fi.ModTime.Unix() != old.ModTime.Unix())) {
+			return errManifestChanged
+		}
+		return nil
+	})
+	if errors.Is(err, errManifestChanged) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return i != len(paths), nil
+}
+
+// Changes calls fn for every path that was added, modified or removed in
+// current, in path order. size is the current size, 0 for removed paths.
+func (c *CompactManifest) Changes(current *Manifest, fn func(path string, size int64)) error {
+	current.mu.RLock()
+	defer current.mu.RUnlock()
+	paths := make([]string, 0, len(current.Files))
+	for p := range current.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	i := 0
+	err := c.Each(func(old *FileInfo) error {
+		for i < len(paths) && paths[i] < old.Path {
+			fn(paths[i], current.Files[paths[i]].Size) // Added
+			i++
+		}
+		if i >= len(paths) || paths[i] != old.Path {
+			fn(old.Path, 0) // Removed
+			return nil
+		}
+		fi := current.Files[paths[i]]
+		i++
+		if fi.IsDir != old.IsDir || (!fi.IsDir && (fi.Size != old.Size || fi.ModTime.Unix() != old.ModTime.Unix())) {
+			fn(fi.Path, fi.Size)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for ; i < len(paths); i++ {
+		fn(paths[i], current.Files[paths[i]].Size)
+	}
+	return nil
+}
+
+func commonPrefixLen(a, b string) int {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
@@ -0,0 +1,142 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func testManifest() *Manifest {
+	m := NewManifest("/data/tv")
+	base := time.Unix(1700000000, 0)
+	m.Add(&FileInfo{Path: "Show", IsDir: true, ModTime: base})
+	m.Add(&FileInfo{Path: "Show/Season 1", IsDir: true})
+	for i := 1; i <= 3; i++ {
+		m.Add(&FileInfo{Path: fmt.Sprintf("Show/Season 1/E%02d.mkv", i), Size: int64(i) << 30, ModTime: base.Add(time.Duration(i) * time.Hour)})
+	}
+	m.Add(&FileInfo{Path: "Movie.mkv", Size: 42, ModTime: base.Add(-time.Hour), Hash: "abc123"})
+	return m
+}
+
+func TestCompactManifest_RoundTrip(t *testing.T) {
+	m := testManifest()
+	c, err := m.Compact()
+	if err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if c.FileCount() != 4 || c.TotalSize() != 6<<30+42 {
+		t.Errorf("Unexpected summary: %d files, %d bytes", c.FileCount(), c.TotalSize())
+	}
+
+	decoded, err := DecodeCompactManifest(c.Bytes())
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if decoded.Root != "/data/tv" || decoded.FileCount() != c.FileCount() || decoded.TotalSize() != c.TotalSize() {
+		t.Errorf("Decoded summary mismatch: %+v", decoded)
+	}
+	expanded, err := decoded.Expand()
+	if err != nil {
+		t.Fatalf("Expand failed: %v", err)
+	}
+	if len(expanded.Files) != len(m.Files) || len(expanded.Dirs) != len(m.Dirs) {
+		t.Fatalf("Expected %d entries, got %d", len(m.Files), len(expanded.Files))
+	}
+	for p, want := range m.Files {
+		got, ok := expanded.Files[p]
+		if !ok {
+			t.Errorf("Missing %s", p)
+			continue
+		}
+		if got.Size != want.Size || got.IsDir != want.IsDir || got.Hash != want.Hash || !got.ModTime.Equal(want.ModTime) {
+			t.Errorf("%s: got %+v, want %+v", p, got, want)
+		}
+	}
+}
+
+func TestCompactManifest_Differs(t *testing.T) {
+	c, err := testManifest().Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if changed, err := c.Differs(testManifest()); err != nil || changed {
+		t.Errorf("Identical manifest reported as changed (err=%v)", err)
+	}
+
+	for name, mutate := range map[string]func(m *Manifest){
+		"added":    func(m *Manifest) { m.Add(&FileInfo{Path: "Show/Season 1/E04.mkv", Size: 1}) },
+		"appended": func(m *Manifest) { m.Add(&FileInfo{Path: "Zzz.mkv", Size: 1}) },
+		"removed":  func(m *Manifest) { delete(m.Files, "Movie.mkv") },
+		"resized":  func(m *Manifest) { m.Files["Movie.mkv"].Size = 43 },
+		"touched":  func(m *Manifest) { m.Files["Movie.mkv"].ModTime = time.Now() },
+	} {
+		m := testManifest()
+		mutate(m)
+		if changed, err := c.Differs(m); err != nil || !changed {
+			t.Errorf("%s: expected a difference (err=%v)", name, err)
+		}
+	}
+}
+
+func TestCompactManifest_Size(t *testing.T) {
+	m := NewManifest("/data")
+	base := time.Unix(1700000000, 0)
+	for i := 0; i < 10000; i++ {
+		m.Add(&FileInfo{Path: fmt.Sprintf("Library/Series %03d/Season %02d/Episode %04d.mkv", i/100, i/10%10, i), Size: int64(i) * 1000, ModTime: base.Add(time.Duration(i) * time.Second)})
+	}
+	c, err := m.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonData, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Bytes())*10 > len(jsonData) {
+		t.Errorf("Compact encoding should be at least 10x smaller than JSON: %d vs %d bytes", len(c.Bytes()), len(jsonData))
+	}
+}
+
+func TestDecodeCompactManifest_Invalid(t *testing.T) {
+	c, err := testManifest().Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := c.Bytes()
+	for name, input := range map[string][]byte{
+		"empty":     nil,
+		"not gzip":  []byte(`{"files":{}}`),
+		"truncated": data[:len(data)/2],
+	} {
+		if _, err := DecodeCompactManifest(input); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestCompactManifest_Changes(t *testing.T) {
+	c, err := testManifest().Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := testManifest()
+	m.Add(&FileInfo{Path: "Show/Season 1/E04.mkv", Size: 7})
+	m.Files["Movie.mkv"].Size = 43
+	delete(m.Files, "Show/Season 1/E01.mkv")
+
+	got := make(map[string]int64)
+	if err := c.Changes(m, func(path string, size int64) { got[path] = size }); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int64{"Show/Season 1/E04.mkv": 7, "Movie.mkv": 43, "Show/Season 1/E01.mkv": 0}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for p, size := range want {
+		if s, ok := got[p]; !ok || s != size {
+			t.Errorf("%s: expected size %d, got %d (reported %v)", p, size, s, ok)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package sync
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"schnorarr/internal/agent"
+)
+
+// ManifestNDJSON is the content type of streamed manifests: a header line
+// followed by one FileInfo per line, so neither side has to hold the whole
+// JSON document in memory
+const ManifestNDJSON = agent.ManifestNDJSON
+
+// manifestHeader is the first line of a streamed manifest
+type manifestHeader struct {
+	Root  string `json:"root"`
+	Count int    `json:"count"`
+}
+
+// Digest returns a hash over every path, type, size and modification time.
+// Identical trees yield the same digest, which receivers use as ETag.
+func (m *Manifest) Digest() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	paths := make([]string, 0, len(m.Files))
+	for p := range m.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	h := sha256.New()
+	buf := make([]byte, 0, 256)
+	for _, p := range paths {
+		fi := m.Files[p]
+		buf = append(buf[:0], p...)
+		buf = append(buf, 0)
+		buf = strconv.AppendBool(buf, fi.IsDir)
+		buf = append(buf, 0)
+		buf = strconv.AppendInt(buf, fi.Size, 10)
+		buf = append(buf, 0)
+		buf = strconv.AppendInt(buf, fi.ModTime.Unix(), 10)
+		buf = append(buf, '\n')
+		_, _ = h.Write(buf)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// WriteManifestNDJSON streams the manifest as ManifestNDJSON
+func WriteManifestNDJSON(w io.Writer, m *Manifest) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	if err := enc.Encode(manifestHeader{Root: m.Root, Count: len(m.Files)}); err != nil {
+		return err
+	}
+	for _, fi := range m.Files {
+		if err := enc.Encode(fi); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadManifestNDJSON decodes a manifest written by WriteManifestNDJSON
+func ReadManifestNDJSON(r io.Reader) (*Manifest, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	var header manifestHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, fmt.Errorf("failed to read manifest header: %w", err)
+	}
+	m := NewManifest(header.Root)
+	for i := 0; i < header.Count; i++ {
+		fi := &FileInfo{}
+		if err := dec.Decode(fi); err != nil {
+			return nil, fmt.Errorf("failed to read manifest entry %d of %d: %w", i+1, header.Count, err)
+		}
+		m.Add(fi)
+	}
+	return m, nil
+}
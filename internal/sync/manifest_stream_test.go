@@ -0,0 +1,150 @@
+package sync
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"schnorarr/internal/agent"
+)
+
+func TestManifestNDJSON_RoundTrip(t *testing.T) {
+	m := testManifest()
+	var buf bytes.Buffer
+	if err := WriteManifestNDJSON(&buf, m); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	decoded, err := ReadManifestNDJSON(&buf)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if decoded.Root != m.Root || len(decoded.Files) != len(m.Files) || len(decoded.Dirs) != len(m.Dirs) {
+		t.Errorf("Round trip mismatch: %d files, %d dirs", len(decoded.Files), len(decoded.Dirs))
+	}
+	if decoded.Digest() != m.Digest() {
+		t.Error("Decoded manifest should have the same digest")
+	}
+
+	m.Files["Movie.mkv"].Size++
+	if decoded.Digest() == m.Digest() {
+		t.Error("Digest should change with the tree")
+	}
+
+	var truncated bytes.Buffer
+	_ = WriteManifestNDJSON(&truncated, m)
+	if _, err := ReadManifestNDJSON(bytes.NewReader(truncated.Bytes()[:truncated.Len()-20])); err == nil {
+		t.Error("Truncated stream should fail")
+	}
+}
+
+func TestScanner_FetchManifestRevalidates(t *testing.T) {
+	m := testManifest()
+	etag := `"` + m.Digest() + `"`
+	var full, notModified int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/agent/") {
+			http.NotFound(w, r) // Legacy receiver
+			return
+		}
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", ManifestNDJSON)
+		_ = WriteManifestNDJSON(w, m)
+	}))
+	defer srv.Close()
+
+	s := NewScanner()
+	for i := 0; i < 2; i++ {
+		got, err := s.fetchManifest(agent.NewClient(srv.URL), "tv", "")
+		if err != nil {
+			t.Fatalf("Fetch %d failed: %v", i, err)
+		}
+		if len(got.Files) != len(m.Files) || got.Digest() != m.Digest() {
+			t.Errorf("Fetch %d returned a different manifest", i)
+		}
+	}
+	if full != 1 || notModified != 1 {
+		t.Errorf("Expected one full transfer and one 304, got %d and %d", full, notModified)
+	}
+}
+
+func TestScanner_FetchRemoteAppliesChanges(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, data string) {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("A/a.mkv", "a")
+	write("B/b.mkv", "b")
+
+	cursor, changes := "e-1", []string{}
+	var full, subtrees int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/agent/") {
+			http.NotFound(w, r) // Legacy receiver
+			return
+		}
+		if r.URL.Path == "/api/changes" {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"cursor": cursor, "reset": false, "changes": changes,
+			})
+			return
+		}
+		var m *Manifest
+		var err error
+		if sub := r.URL.Query().Get("sub"); sub != "" {
+			subtrees++
+			m, err = NewScanner().ScanSubtree(root, sub)
+		} else {
+			full++
+			m, err = NewScanner().ScanLocal(root)
+		}
+		if err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", ManifestNDJSON)
+		_ = WriteManifestNDJSON(w, m)
+	}))
+	defer srv.Close()
+
+	s, client := NewScanner(), agent.NewClient(srv.URL)
+	if _, err := s.fetchRemote(client, "tv"); err != nil {
+		t.Fatalf("Initial fetch failed: %v", err)
+	}
+
+	write("A/c.mkv", "c")
+	if err := os.RemoveAll(filepath.Join(root, "B")); err != nil {
+		t.Fatal(err)
+	}
+	cursor, changes = "e-3", []string{"A/c.mkv", "B"}
+	got, err := s.fetchRemote(client, "tv")
+	if err != nil {
+		t.Fatalf("Delta fetch failed: %v", err)
+	}
+	if full != 1 || subtrees != 2 {
+		t.Errorf("Expected one full manifest and two subtrees, got %d and %d", full, subtrees)
+	}
+	if !got.HasFile("A/a.mkv") || !got.HasFile("A/c.mkv") || got.HasFile("B/b.mkv") || got.HasDir("B") {
+		t.Errorf("Unexpected patched manifest: %v", got.Files)
+	}
+
+	changes = []string{}
+	if got, err := s.fetchRemote(client, "tv"); err != nil || !got.HasFile("A/c.mkv") || full != 1 {
+		t.Errorf("Unchanged tree should be served from cache (err %v, full %d)", err, full)
+	}
+}
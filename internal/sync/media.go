@@ -0,0 +1,162 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"schnorarr/internal/monitor/database"
+)
+
+// What happens to source media that fails validation
+const (
+	MediaValidationOff  = "off"  // No validation (default)
+	MediaValidationSkip = "skip" // Invalid files are not transferred
+	MediaValidationFlag = "flag" // Invalid files are transferred and reported
+)
+
+// mediaExtensions are the files validated with ffprobe
+var mediaExtensions = map[string]bool{
+	".mkv": true, ".mp4": true, ".m4v": true, ".avi": true, ".mov": true,
+	".ts": true, ".m2ts": true, ".wmv": true, ".webm": true, ".mpg": true, ".mpeg": true,
+}
+
+// ffprobeCommand is the ffprobe binary; a variable so tests can replace it
+var ffprobeCommand = "ffprobe"
+
+// mediaProbeTimeout bounds a single ffprobe run
+const mediaProbeTimeout = 2 * time.Minute
+
+// ParseMediaValidation validates a mode name; empty selects MediaValidationOff
+func ParseMediaValidation(s string) (string, error) {
+	switch m := strings.ToLower(strings.TrimSpace(s)); m {
+	case "", MediaValidationOff, "false":
+		return MediaValidationOff, nil
+	case MediaValidationSkip, "true":
+		return MediaValidationSkip, nil
+	case MediaValidationFlag:
+		return m, nil
+	}
+	return MediaValidationOff, fmt.Errorf("unknown media validation mode %q (use %s, %s or %s)", s, MediaValidationOff, MediaValidationSkip, MediaValidationFlag)
+}
+
+// mediaValidationEnabled reports whether the engine validates media
+func (e *Engine) mediaValidationEnabled() bool {
+	return e.config.MediaValidation == MediaValidationSkip || e.config.MediaValidation == MediaValidationFlag
+}
+
+// isMedia reports whether a path is validated
+func isMedia(p string) bool {
+	return mediaExtensions[strings.ToLower(filepath.Ext(p))]
+}
+
+// probeMedia runs ffprobe on a file and returns its duration in seconds. A
+// file ffprobe cannot read, or one without duration, is reported as
+// invalid with the reason; err is only set when ffprobe could not run.
+func probeMedia(path string) (duration float64, invalid string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mediaProbeTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, ffprobeCommand, "-v", "error", "-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1", path)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	runErr := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		return 0, "", fmt.Errorf("ffprobe timed out after %s", mediaProbeTimeout)
+	case errors.As(runErr, &exitErr):
+		reason := lastLine(stderr.String())
+		if reason == "" {
+			reason = fmt.Sprintf("ffprobe exit status %d", exitErr.ExitCode())
+		}
+		return 0, reason, nil
+	case runErr != nil:
+		return 0, "", runErr
+	}
+	duration, parseErr := strconv.ParseFloat(strings.TrimSpace(stdout.String()), 64)
+	if parseErr != nil || duration <= 0 {
+		return 0, "no duration", nil
+	}
+	return duration, "", nil
+}
+
+// validateMedia returns the validation result of a source file, probing it
+// unless this version was checked before. It returns nil for files that
+// are not validated, and an error when ffprobe could not run.
+func (e *Engine) validateMedia(f *FileInfo) (*database.MediaValidation, error) {
+	if !e.mediaValidationEnabled() || f.IsDir || !isMedia(f.Path) {
+		return nil, nil
+	}
+	if v, _ := e.storedValidation(f); v != nil {
+		return v, nil
+	}
+	duration, invalid, err := probeMedia(filepath.Join(e.config.SourceDir, f.Path))
+	if err != nil {
+		return nil, err
+	}
+	v := &database.MediaValidation{EngineID: e.config.ID, Path: f.Path, Size: f.Size, ModTime: f.ModTime.Unix(),
+		Valid: invalid == "", Duration: duration, Error: invalid, Checked: time.Now().Unix()}
+	if err := database.SaveMediaValidation(*v); err != nil {
+		e.logger().Warn("Failed to save media validation", "path", f.Path, "error", err)
+	}
+	return v, nil
+}
+
+// storedValidation returns the stored result for this version of a file
+func (e *Engine) storedValidation(f *FileInfo) (*database.MediaValidation, error) {
+	v, err := database.GetMediaValidation(e.config.ID, f.Path)
+	if err != nil || v == nil || v.Size != f.Size || v.ModTime != f.ModTime.Unix() {
+		return nil, err
+	}
+	return v, nil
+}
+
+// invalidMedia returns the stored failed validation of a file that the
+// engine does not transfer, so plans leave it out until the file changes
+func (e *Engine) invalidMedia(f *FileInfo) (*database.MediaValidation, bool) {
+	if e.config.MediaValidation != MediaValidationSkip || !isMedia(f.Path) {
+		return nil, false
+	}
+	v, _ := e.storedValidation(f)
+	return v, v != nil && !v.Valid
+}
+
+// checkMedia validates a file before its transfer and reports whether it
+// may be sent. Files ffprobe cannot check are sent with a warning.
+func (e *Engine) checkMedia(timestamp string, f *FileInfo) bool {
+	v, err := e.validateMedia(f)
+	if err != nil {
+		e.logger().Warn("Media validation unavailable, sending unchecked", "path", f.Path, "error", err)
+		return true
+	}
+	if v == nil || v.Valid {
+		return true
+	}
+	if e.config.MediaValidation == MediaValidationFlag {
+		e.logger().Warn("Invalid media, sending anyway", "path", f.Path, "reason", v.Error)
+		e.reportError(fmt.Sprintf("Invalid media %s: %s", f.Path, v.Error))
+		return true
+	}
+	e.logger().Warn("Invalid media, not sending", "path", f.Path, "reason", v.Error)
+	e.reportError(fmt.Sprintf("Invalid media %s not sent: %s", f.Path, v.Error))
+	e.reportEvent(timestamp, "Invalid", f.Path, f.Size)
+	return false
+}
+
+// invalidMediaSet returns the paths of files whose stored validation failed
+func (e *Engine) invalidMediaSet(files []*FileInfo) map[string]bool {
+	invalid := make(map[string]bool)
+	for _, f := range files {
+		if _, ok := e.invalidMedia(f); ok {
+			invalid[f.Path] = true
+		}
+	}
+	return invalid
+}
@@ -0,0 +1,134 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"schnorarr/internal/monitor/database"
+)
+
+func TestEngine_MediaValidation(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as ffprobe")
+	}
+	database.DBPath = filepath.Join(t.TempDir(), "media.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+
+	// Fails files containing "broken", reports no duration for empty ones
+	bin := t.TempDir()
+	probe := filepath.Join(bin, "ffprobe")
+	script := "#!/bin/sh\nfor f; do :; done\necho probed >> \"" + filepath.Join(bin, "calls") + "\"\n" +
+		"if grep -q broken \"$f\"; then echo \"$f: Invalid data found when processing input\" >&2; exit 1; fi\n" +
+		"if [ -s \"$f\" ]; then echo 42.5; else echo N/A; fi\n"
+	if err := os.WriteFile(probe, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := ffprobeCommand
+	ffprobeCommand = probe
+	defer func() { ffprobeCommand = old }()
+
+	source, target := t.TempDir(), t.TempDir()
+	for name, content := range map[string]string{"good.mkv": "video", "broken.mkv": "broken", "empty.mp4": "", "notes.txt": "broken"} {
+		if err := os.WriteFile(filepath.Join(source, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e := NewEngine(SyncConfig{ID: "media", SourceDir: source, TargetDir: target, Rule: "flat", MediaValidation: MediaValidationSkip})
+	if err := e.RunSync(nil); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{"good.mkv": true, "broken.mkv": false, "empty.mp4": false, "notes.txt": true} {
+		if _, err := os.Stat(filepath.Join(target, name)); (err == nil) != want {
+			t.Errorf("%s on target = %v, want %v", name, err == nil, want)
+		}
+	}
+
+	invalid, err := database.ListInvalidMedia("media")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invalid) != 2 {
+		t.Fatalf("Expected 2 invalid files, got %+v", invalid)
+	}
+	if v, _ := database.GetMediaValidation("media", "good.mkv"); v == nil || !v.Valid || v.Duration != 42.5 {
+		t.Errorf("Valid result should be stored with the duration, got %+v", v)
+	}
+
+	plan, err := e.PreviewSync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.FilesToSync) != 0 {
+		t.Errorf("Invalid versions should not be planned again, plan = %+v", plan.FilesToSync)
+	}
+	skipped := 0
+	for _, s := range plan.Skipped {
+		if s.Reason == SkipInvalidMedia {
+			skipped++
+		}
+	}
+	if skipped != 2 {
+		t.Errorf("The preview should list both invalid files as skipped, got %d", skipped)
+	}
+	if err := e.RunSync(nil); err != nil {
+		t.Fatal(err)
+	}
+	calls, _ := os.ReadFile(filepath.Join(bin, "calls"))
+	if n := len(calls) / len("probed\n"); n != 3 {
+		t.Errorf("Stored results should be reused, ffprobe ran %d times", n)
+	}
+
+	// A fixed file is checked again and sent
+	if err := os.WriteFile(filepath.Join(source, "broken.mkv"), []byte("fixed video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.RunSync(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "broken.mkv")); err != nil {
+		t.Error("A changed file should be validated again and sent")
+	}
+}
+
+func TestEngine_MediaValidationFlagSends(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as ffprobe")
+	}
+	bin := t.TempDir()
+	probe := filepath.Join(bin, "ffprobe")
+	if err := os.WriteFile(probe, []byte("#!/bin/sh\necho 'moov atom not found' >&2\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := ffprobeCommand
+	ffprobeCommand = probe
+	defer func() { ffprobeCommand = old }()
+
+	source, target := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "broken.mp4"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e := NewEngine(SyncConfig{ID: "flag", SourceDir: source, TargetDir: target, Rule: "flat", MediaValidation: MediaValidationFlag})
+	if err := e.RunSync(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "broken.mp4")); err != nil {
+		t.Error("Flagged files should still be sent")
+	}
+}
+
+func TestParseMediaValidation(t *testing.T) {
+	for in, want := range map[string]string{"": MediaValidationOff, "SKIP": MediaValidationSkip, "flag": MediaValidationFlag, "true": MediaValidationSkip} {
+		if got, err := ParseMediaValidation(in); err != nil || got != want {
+			t.Errorf("ParseMediaValidation(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseMediaValidation("strict"); err == nil {
+		t.Error("Unknown modes should be rejected")
+	}
+}
@@ -0,0 +1,121 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"schnorarr/internal/agent"
+	"schnorarr/internal/monitor/database"
+)
+
+// RuleMove mirrors files to the target and then removes them from the source.
+// Files missing on the source are expected in this mode, so the target is never pruned.
+const RuleMove = "move"
+
+// moveHashTimeout bounds how long the receiver may take to hash a moved file
+const moveHashTimeout = 30 * time.Minute
+
+// verifyMirrored checks that the target copy of a source file is complete.
+// Both sides are compared by SHA256, remote targets with the hash the receiver
+// computes. Receivers that cannot hash fail the check, so the source is kept.
+func (e *Engine) verifyMirrored(rel string, src *FileInfo) error {
+	srcPath := filepath.Join(e.config.SourceDir, filepath.FromSlash(rel))
+
+	// The source must not have changed since it was scanned (still being written or seeded)
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		return fmt.Errorf("source unavailable: %w", err)
+	}
+	if info.Size() != src.Size || info.ModTime().Unix() != src.ModTime.Unix() {
+		return fmt.Errorf("source changed since the last scan")
+	}
+
+	srcHash := &FileInfo{Path: rel}
+	if err := srcHash.ComputeHash(srcPath); err != nil {
+		return err
+	}
+
+	if IsRemotePath(e.config.TargetDir) {
+		host, remoteBase := ParseRemoteDestination(e.config.TargetDir)
+		ctx, cancel := context.WithTimeout(context.Background(), moveHashTimeout)
+		defer cancel()
+		resp, err := agent.ForHost(host).Hash(ctx, &agent.HashRequest{Path: path.Join(remoteBase, rel)})
+		if errors.Is(err, agent.ErrUnsupported) {
+			return fmt.Errorf("receiver cannot hash files, update it to verify moves")
+		}
+		if err != nil {
+			return fmt.Errorf("receiver hash failed: %w", err)
+		}
+		if resp.Size != src.Size {
+			return fmt.Errorf("receiver reports %d bytes, expected %d", resp.Size, src.Size)
+		}
+		if resp.Sum != srcHash.Hash {
+			return fmt.Errorf("checksum mismatch")
+		}
+		return nil
+	}
+
+	dstHash := &FileInfo{Path: rel}
+	if err := dstHash.ComputeHash(filepath.Join(e.config.TargetDir, filepath.FromSlash(rel))); err != nil {
+		return err
+	}
+	if srcHash.Hash != dstHash.Hash {
+		return fmt.Errorf("checksum mismatch")
+	}
+	return nil
+}
+
+// executeMovePhase removes source files that are verified on the target and
+// older than MoveAfter. It must run with the transfer lock held unless in dry run.
+func (e *Engine) executeMovePhase(sourceManifest, targetManifest *Manifest) {
+	timestamp := database.Timestamp(time.Now())
+	isDryRun := e.dryRunFor(ActionMove)
+	moved := 0
+
+	for rel, src := range sourceManifest.Files {
+		if e.IsPaused() {
+			return
+		}
+		if src.IsDir || time.Since(src.ModTime) < e.config.MoveAfter {
+			continue
+		}
+		dst, ok := targetManifest.GetFile(rel)
+		if !ok || !e.mirrored(src, dst) {
+			continue
+		}
+		e.pausedMu.RLock()
+		_, failed := e.failedFiles[rel]
+		e.pausedMu.RUnlock()
+		if failed {
+			continue
+		}
+
+		if isDryRun {
+			e.reportEvent(timestamp, "DRY-Moved", rel, src.Size)
+			continue
+		}
+		if err := e.verifyMirrored(rel, src); err != nil {
+			e.logger().Warn("Move: keeping source, verification failed", "path", rel, "error", err)
+			continue
+		}
+		if err := os.Remove(filepath.Join(e.config.SourceDir, filepath.FromSlash(rel))); err != nil {
+			e.logger().Error("Failed to remove moved source", "path", rel, "error", err)
+			e.reportError(fmt.Sprintf("Failed to remove moved source %s: %v", rel, err))
+			continue
+		}
+		delete(sourceManifest.Files, rel)
+		moved++
+		e.reportEvent(timestamp, "Moved", rel, src.Size)
+	}
+	if moved > 0 {
+		e.logger().Info("Move: removed verified files from the source", "moved", moved)
+	}
+	if e.evictionEnabled() {
+		e.evictForSpace(sourceManifest, targetManifest)
+	}
+}
@@ -0,0 +1,146 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"schnorarr/internal/agent"
+)
+
+func TestEngine_MoveRule(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+
+	old := time.Now().Add(-48 * time.Hour)
+	for _, name := range []string{"old.mkv", "fresh.mkv"} {
+		p := filepath.Join(sourceDir, name)
+		if err := os.WriteFile(p, []byte("video "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if name == "old.mkv" {
+			if err := os.Chtimes(p, old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// Already archived earlier, no longer on the source: must stay on the target
+	if err := os.WriteFile(filepath.Join(targetDir, "archived.mkv"), []byte("archived"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := NewEngine(SyncConfig{
+		ID:        "test-move",
+		SourceDir: sourceDir,
+		TargetDir: targetDir,
+		Rule:      RuleMove,
+		MoveAfter: 24 * time.Hour,
+	})
+
+	if err := engine.RunSync(nil); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	if engine.IsWaitingForApproval() {
+		t.Fatal("Move rule should not plan target deletions")
+	}
+
+	for _, name := range []string{"old.mkv", "fresh.mkv", "archived.mkv"} {
+		if _, err := os.Stat(filepath.Join(targetDir, name)); err != nil {
+			t.Errorf("Expected %s on target: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(sourceDir, "old.mkv")); !os.IsNotExist(err) {
+		t.Error("Verified file older than MoveAfter should be removed from the source")
+	}
+	if _, err := os.Stat(filepath.Join(sourceDir, "fresh.mkv")); err != nil {
+		t.Error("File younger than MoveAfter must stay on the source")
+	}
+}
+
+func TestEngine_MoveRule_KeepsUnverified(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+
+	mtime := time.Now().Add(-time.Hour)
+	src := filepath.Join(sourceDir, "a.mkv")
+	dst := filepath.Join(targetDir, "a.mkv")
+	if err := os.WriteFile(src, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Same size and mtime but different content: looks mirrored, fails the checksum
+	if err := os.WriteFile(dst, []byte("corrupt!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{src, dst} {
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	engine := NewEngine(SyncConfig{ID: "test-move-verify", SourceDir: sourceDir, TargetDir: targetDir, Rule: RuleMove})
+	if err := engine.RunSync(nil); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	if _, err := os.Stat(src); err != nil {
+		t.Fatal("Source must be kept when the target copy fails verification")
+	}
+}
+
+func TestEngine_VerifyMirroredRemote(t *testing.T) {
+	sourceDir := t.TempDir()
+	content := []byte("video")
+	sum := sha256.Sum256(content)
+	for _, name := range []string{"good.mkv", "bad.mkv", "legacy.mkv"} {
+		if err := os.WriteFile(filepath.Join(sourceDir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(agent.PathPrefix+"Hash", func(w http.ResponseWriter, r *http.Request) {
+		var req agent.HashRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set(agent.HeaderVersion, "1")
+		switch req.Path {
+		case "tv/legacy.mkv":
+			w.WriteHeader(http.StatusNotImplemented)
+		case "tv/good.mkv":
+			_ = json.NewEncoder(w).Encode(agent.HashResponse{Algorithm: "sha256", Sum: hex.EncodeToString(sum[:]), Size: int64(len(content))})
+		default:
+			_ = json.NewEncoder(w).Encode(agent.HashResponse{Algorithm: "sha256", Sum: "corrupt", Size: int64(len(content))})
+		}
+	})
+	// A host of its own, agent clients are shared per host across tests
+	ln, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("No second loopback address: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(mux)
+	srv.Listener = ln
+	srv.Start()
+	defer srv.Close()
+	t.Setenv("RECEIVER_PORT", strconv.Itoa(ln.Addr().(*net.TCPAddr).Port))
+
+	engine := NewEngine(SyncConfig{ID: "test-move-remote", SourceDir: sourceDir, TargetDir: "127.0.0.2::video-sync/tv", Rule: RuleMove})
+	for name, ok := range map[string]bool{"good.mkv": true, "bad.mkv": false, "legacy.mkv": false} {
+		info, err := os.Stat(filepath.Join(sourceDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = engine.verifyMirrored(name, &FileInfo{Path: name, Size: info.Size(), ModTime: info.ModTime()})
+		if ok && err != nil {
+			t.Errorf("%s should verify: %v", name, err)
+		}
+		if !ok && err == nil {
+			t.Errorf("%s must not verify", name)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Policies deciding when a file present on both ends is transferred again
+const (
+	MtimeNewer        = "newer"          // Size differs or the source is newer (default)
+	MtimeSizeOrMtime  = "size_or_mtime"  // Size or modification time differs, in either direction
+	MtimeSizeAndMtime = "size_and_mtime" // Size and modification time both differ
+)
+
+// MtimeCompare compares files present on both ends. The zero value is the
+// MtimeNewer policy with modification times truncated to seconds.
+type MtimeCompare struct {
+	Policy string
+	// Tolerance treats modification times this close as equal, e.g. 2s for
+	// FAT targets (0 = compare whole seconds)
+	Tolerance time.Duration
+}
+
+// ParseMtimePolicy validates a policy name; empty selects MtimeNewer
+func ParseMtimePolicy(s string) (string, error) {
+	switch p := strings.ToLower(strings.TrimSpace(s)); p {
+	case "":
+		return MtimeNewer, nil
+	case MtimeNewer, MtimeSizeOrMtime, MtimeSizeAndMtime:
+		return p, nil
+	}
+	return MtimeNewer, fmt.Errorf("unknown mtime policy %q (use %s, %s or %s)", s, MtimeNewer, MtimeSizeOrMtime, MtimeSizeAndMtime)
+}
+
+// NeedsUpdate reports whether dst has to be replaced by src
+func (c MtimeCompare) NeedsUpdate(src, dst *FileInfo) bool {
+	if src.IsDir != dst.IsDir {
+		return true
+	}
+	if src.IsDir {
+		return false
+	}
+	sizeDiffers := src.Size != dst.Size
+	switch c.Policy {
+	case MtimeSizeOrMtime:
+		return sizeDiffers || c.differs(src.ModTime, dst.ModTime)
+	case MtimeSizeAndMtime:
+		return sizeDiffers && c.differs(src.ModTime, dst.ModTime)
+	}
+	return sizeDiffers || c.newer(src.ModTime, dst.ModTime)
+}
+
+// newer reports whether a is newer than b beyond the tolerance
+func (c MtimeCompare) newer(a, b time.Time) bool {
+	if c.Tolerance <= 0 {
+		return a.Unix() > b.Unix()
+	}
+	return a.Sub(b) > c.Tolerance
+}
+
+// differs reports whether a and b are further apart than the tolerance
+func (c MtimeCompare) differs(a, b time.Time) bool {
+	return c.newer(a, b) || c.newer(b, a)
+}
+
+// mtimeCompare returns the engine's comparison settings
+func (e *Engine) mtimeCompare() MtimeCompare {
+	return MtimeCompare{Policy: e.config.MtimePolicy, Tolerance: e.config.MtimeTolerance}
+}
+
+// mtimePolicyName returns the engine's policy with its tolerance for messages
+func (e *Engine) mtimePolicyName() string {
+	policy, _ := ParseMtimePolicy(e.config.MtimePolicy)
+	if e.config.MtimeTolerance > 0 {
+		return policy + ", tolerance " + e.config.MtimeTolerance.String()
+	}
+	return policy
+}
+
+// mirrored reports whether dst is a complete copy of src, before the source
+// copy is removed. Only the tolerance applies: a policy that ignores a size
+// change must not let a file count as mirrored.
+func (e *Engine) mirrored(src, dst *FileInfo) bool {
+	return !dst.IsDir && !MtimeCompare{Tolerance: e.config.MtimeTolerance}.NeedsUpdate(src, dst)
+}
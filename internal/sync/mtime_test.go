@@ -0,0 +1,46 @@
+package sync
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMtimeCompare_NeedsUpdate(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	file := func(size int64, mod time.Time) *FileInfo { return &FileInfo{Size: size, ModTime: mod} }
+
+	tests := []struct {
+		name     string
+		cmp      MtimeCompare
+		src, dst *FileInfo
+		want     bool
+	}{
+		{"newer: source newer", MtimeCompare{}, file(1, now.Add(time.Second)), file(1, now), true},
+		{"newer: touched back", MtimeCompare{}, file(1, now.Add(-time.Hour)), file(1, now), false},
+		{"newer: FAT rounding churns", MtimeCompare{}, file(1, now.Add(time.Second)), file(1, now), true},
+		{"newer: FAT rounding within tolerance", MtimeCompare{Tolerance: 2 * time.Second}, file(1, now.Add(time.Second)), file(1, now), false},
+		{"size_or_mtime: touched back", MtimeCompare{Policy: MtimeSizeOrMtime}, file(1, now.Add(-time.Hour)), file(1, now), true},
+		{"size_or_mtime: within tolerance", MtimeCompare{Policy: MtimeSizeOrMtime, Tolerance: 2 * time.Second}, file(1, now.Add(-2*time.Second)), file(1, now), false},
+		{"size_or_mtime: size", MtimeCompare{Policy: MtimeSizeOrMtime}, file(2, now), file(1, now), true},
+		{"size_and_mtime: size only", MtimeCompare{Policy: MtimeSizeAndMtime}, file(2, now), file(1, now), false},
+		{"size_and_mtime: mtime only", MtimeCompare{Policy: MtimeSizeAndMtime}, file(1, now.Add(time.Hour)), file(1, now), false},
+		{"size_and_mtime: both", MtimeCompare{Policy: MtimeSizeAndMtime}, file(2, now.Add(-time.Hour)), file(1, now), true},
+	}
+	for _, tt := range tests {
+		if got := tt.cmp.NeedsUpdate(tt.src, tt.dst); got != tt.want {
+			t.Errorf("%s: NeedsUpdate = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseMtimePolicy(t *testing.T) {
+	if p, err := ParseMtimePolicy(""); err != nil || p != MtimeNewer {
+		t.Errorf("empty policy = %q, %v; want the default", p, err)
+	}
+	if p, err := ParseMtimePolicy(" Size_Or_Mtime "); err != nil || p != MtimeSizeOrMtime {
+		t.Errorf("ParseMtimePolicy = %q, %v", p, err)
+	}
+	if _, err := ParseMtimePolicy("hash"); err == nil {
+		t.Error("unknown policy should be rejected")
+	}
+}
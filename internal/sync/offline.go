@@ -0,0 +1,268 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"schnorarr/internal/agent"
+	"schnorarr/internal/monitor/database"
+)
+
+// DefaultBacklogLimit is how many changed paths an offline engine remembers
+const DefaultBacklogLimit = 10000
+
+// offlineProbeInterval is how often an offline engine checks whether its receiver is back
+var offlineProbeInterval = 30 * time.Second
+
+// BacklogStats summarizes the changes queued while the receiver is offline
+type BacklogStats struct {
+	Offline  bool      `json:"offline"`
+	ReadOnly bool      `json:"read_only"` // The receiver is reachable but write-protected
+	Since    time.Time `json:"since"`
+	Paths    int       `json:"paths"`
+	Bytes    int64     `json:"bytes"`
+	Overflow bool      `json:"overflow"`
+}
+
+// offlineBacklog is the set of source paths changed since the last synced
+// source manifest, bounded by the backlog limit
+type offlineBacklog struct {
+	since    time.Time
+	paths    map[string]int64
+	overflow bool
+}
+
+func (b *offlineBacklog) bytes() int64 {
+	var n int64
+	for _, size := range b.paths {
+		n += size
+	}
+	return n
+}
+
+func (b *offlineBacklog) persisted() *database.Backlog {
+	paths := make([]string, 0, len(b.paths))
+	for p := range b.paths {
+		paths = append(paths, p)
+	}
+	return &database.Backlog{Since: b.since, Paths: paths, Bytes: b.bytes(), Overflow: b.overflow}
+}
+
+// IsOffline reports whether the engine is waiting for its receiver to come back
+func (e *Engine) IsOffline() bool {
+	e.pausedMu.RLock()
+	defer e.pausedMu.RUnlock()
+	return e.offline
+}
+
+// GetBacklogStats returns the offline state and the size of the backlog
+func (e *Engine) GetBacklogStats() BacklogStats {
+	e.pausedMu.RLock()
+	defer e.pausedMu.RUnlock()
+	stats := BacklogStats{Offline: e.offline, ReadOnly: e.offline && e.readOnly}
+	if e.backlog != nil {
+		stats.Since = e.backlog.since
+		stats.Paths = len(e.backlog.paths)
+		stats.Bytes = e.backlog.bytes()
+		stats.Overflow = e.backlog.overflow
+	}
+	return stats
+}
+
+// receiverReachable checks the receiver of a remote target
+func (e *Engine) receiverReachable() error {
+	e.pausedMu.RLock()
+	probe := e.probeReceiver
+	e.pausedMu.RUnlock()
+	if probe != nil {
+		return probe()
+	}
+	host, _ := ParseRemoteDestination(e.GetConfig().TargetDir)
+	if host == "" {
+		host = defaultDestHost()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := agent.ForHost(host).Health(ctx)
+	if err != nil {
+		return err
+	}
+	if resp.ReadOnly {
+		return fmt.Errorf("%w: %s", agent.ErrReadOnly, resp.ReadOnlyReason)
+	}
+	return nil
+}
+
+// pauseIfReadOnly checks whether the receiver of a remote target is
+// write-protected. If so the engine goes offline and only queues changes
+// until the receiver accepts writes again.
+func (e *Engine) pauseIfReadOnly(source *Manifest) bool {
+	if !e.IsRemoteScan() {
+		return false
+	}
+	err := e.receiverReachable()
+	if !errors.Is(err, agent.ErrReadOnly) {
+		return false
+	}
+	e.goOffline(err)
+	e.recordBacklog(source)
+	return true
+}
+
+// goOffline switches the engine to OFFLINE after the receiver became
+// unreachable. Cycles then only record source changes until it is back.
+func (e *Engine) goOffline(cause error) {
+	e.pausedMu.Lock()
+	if e.offline {
+		e.pausedMu.Unlock()
+		return
+	}
+	e.offline = true
+	e.readOnly = errors.Is(cause, agent.ErrReadOnly)
+	if e.backlog == nil {
+		e.backlog = &offlineBacklog{since: time.Now(), paths: make(map[string]int64)}
+	}
+	backlog := e.backlog.persisted()
+	e.pausedMu.Unlock()
+
+	_ = database.SaveBacklog(e.config.ID, backlog)
+	if errors.Is(cause, agent.ErrReadOnly) {
+		// Planned maintenance on the receiver is not an error
+		e.logger().Info("Receiver read-only, engine paused; queueing changes until it is writable", "reason", cause)
+	} else {
+		e.logger().Warn("Receiver unreachable, engine offline; queueing changes until it is back", "error", cause)
+		e.reportError(fmt.Sprintf("Engine %s: receiver unreachable, changes are queued until it is back (%v)", e.config.ID, cause))
+	}
+	go e.offlineProbeLoop()
+}
+
+// receiverLost probes the receiver after a failed remote scan or transfer
+// and switches to OFFLINE when it is unreachable
+func (e *Engine) receiverLost(cause error, source *Manifest) bool {
+	if !e.IsRemoteScan() {
+		return false
+	}
+	probeErr := e.receiverReachable()
+	if probeErr == nil {
+		return false
+	}
+	if errors.Is(probeErr, agent.ErrReadOnly) {
+		// The transfer was refused by the write protection
+		cause = probeErr
+	}
+	e.goOffline(cause)
+	e.recordBacklog(source)
+	return true
+}
+
+// recordBacklog adds the source changes since the last synced manifest to the backlog
+func (e *Engine) recordBacklog(source *Manifest) {
+	e.pausedMu.RLock()
+	last := e.lastSourceManifest
+	e.pausedMu.RUnlock()
+	if last == nil || source == nil {
+		// Nothing to diff against: the catch-up compares everything anyway
+		e.pausedMu.Lock()
+		if e.backlog != nil {
+			e.backlog.overflow = true
+		}
+		e.pausedMu.Unlock()
+		return
+	}
+
+	limit := e.config.BacklogLimit
+	if limit <= 0 {
+		limit = DefaultBacklogLimit
+	}
+	changes := make(map[string]int64)
+	overflow := false
+	err := last.Changes(source, func(path string, size int64) {
+		if len(changes) < limit {
+			changes[path] = size
+		} else {
+			overflow = true
+		}
+	})
+	if err != nil {
+		e.logger().Warn("Failed to diff source for the offline backlog", "error", err)
+		overflow = true
+	}
+
+	e.pausedMu.Lock()
+	if e.backlog == nil {
+		e.pausedMu.Unlock()
+		return
+	}
+	// Changes are relative to the last synced manifest, so the newest diff replaces older ones
+	e.backlog.paths = changes
+	e.backlog.overflow = e.backlog.overflow || overflow
+	backlog := e.backlog.persisted()
+	e.pausedMu.Unlock()
+	_ = database.SaveBacklog(e.config.ID, backlog)
+	e.logger().Debug("Offline backlog updated", "paths", len(changes), "overflow", backlog.Overflow)
+}
+
+// offlineProbeLoop waits for the receiver and then runs one catch-up cycle
+func (e *Engine) offlineProbeLoop() {
+	ticker := time.NewTicker(offlineProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stopCh:
+			return
+		case <-ticker.C:
+			if err := e.receiverReachable(); err != nil {
+				e.pausedMu.Lock()
+				e.readOnly = errors.Is(err, agent.ErrReadOnly)
+				e.pausedMu.Unlock()
+				continue
+			}
+			e.pausedMu.Lock()
+			e.offline = false
+			e.readOnly = false
+			// Files that failed while the receiver was down get retried right away
+			e.failedFiles = make(map[string]time.Time)
+			// Failures that tripped the breaker were the receiver being down: the catch-up probes the target
+			e.breaker.probing = e.breaker.open
+			e.pausedMu.Unlock()
+			stats := e.GetBacklogStats()
+			e.logger().Info("Receiver reachable again, running catch-up sync", "backlog", stats.Paths, "bytes", stats.Bytes)
+			go func() { _ = e.RunSync(nil) }()
+			return
+		}
+	}
+}
+
+// finishCatchUp clears the backlog after the first successful cycle back online
+func (e *Engine) finishCatchUp() {
+	e.pausedMu.Lock()
+	backlog := e.backlog
+	e.backlog = nil
+	e.pausedMu.Unlock()
+	if backlog == nil {
+		return
+	}
+	_ = database.ClearBacklog(e.config.ID)
+	e.logger().Info("Catch-up sync completed", "backlog", len(backlog.paths), "offline_for", time.Since(backlog.since).Round(time.Second).String())
+}
+
+// loadBacklog restores a backlog persisted before a restart. The engine
+// starts offline and the probe decides whether to catch up immediately.
+func (e *Engine) loadBacklog() {
+	b, err := database.LoadBacklog(e.config.ID)
+	if err != nil || b == nil {
+		return
+	}
+	paths := make(map[string]int64, len(b.Paths))
+	for _, p := range b.Paths {
+		paths[p] = 0
+	}
+	e.pausedMu.Lock()
+	e.offline = true
+	e.backlog = &offlineBacklog{since: b.Since, paths: paths, overflow: b.Overflow}
+	e.pausedMu.Unlock()
+	e.logger().Info("Restored offline backlog", "paths", len(paths), "since", b.Since.Format(time.RFC3339))
+	go e.offlineProbeLoop()
+}
@@ -0,0 +1,153 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"schnorarr/internal/agent"
+)
+
+func TestEngine_OfflineBacklogAndCatchUp(t *testing.T) {
+	prevInterval := offlineProbeInterval
+	offlineProbeInterval = 10 * time.Millisecond
+	defer func() { offlineProbeInterval = prevInterval }()
+	source, target := t.TempDir(), t.TempDir()
+	write := func(name string) {
+		if err := os.WriteFile(filepath.Join(source, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.mkv")
+
+	// Nothing answers on the receiver port, so the target scan fails
+	e := NewEngine(SyncConfig{ID: "offline", SourceDir: source, TargetDir: "127.0.0.1::video-sync/tv", Rule: "flat"})
+	down := errors.New("connection refused")
+	e.probeReceiver = func() error { return down }
+	defer e.Stop()
+	initial, err := e.scanner.ScanLocal(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.lastSourceManifest = e.compactManifest(initial)
+
+	write("b.mkv")
+	if err := e.RunSync(nil); err == nil {
+		t.Fatal("A sync against an unreachable receiver should fail")
+	}
+	if stats := e.GetBacklogStats(); !stats.Offline || stats.Paths != 1 || stats.Bytes != 5 {
+		t.Fatalf("Expected the engine offline with one queued path, got %+v", stats)
+	}
+
+	write("c.mkv")
+	if err := e.RunSync(nil); err != nil {
+		t.Fatalf("Offline cycles should only queue changes, got %v", err)
+	}
+	if stats := e.GetBacklogStats(); stats.Paths != 2 || stats.Overflow {
+		t.Fatalf("Expected two queued paths, got %+v", stats)
+	}
+
+	// The receiver comes back at a local path: one catch-up cycle clears the backlog
+	e.pausedMu.Lock()
+	e.config.TargetDir = target
+	e.probeReceiver = func() error { return nil }
+	e.pausedMu.Unlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if stats := e.GetBacklogStats(); !stats.Offline && stats.Paths == 0 && stats.Since.IsZero() {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Catch-up did not clear the backlog: %+v", e.GetBacklogStats())
+}
+
+func TestEngine_OfflineBacklogLimit(t *testing.T) {
+	source := t.TempDir()
+	e := NewEngine(SyncConfig{ID: "offline-limit", SourceDir: source, TargetDir: "127.0.0.1::video-sync/tv", BacklogLimit: 2})
+	initial, _ := e.scanner.ScanLocal(source)
+	e.lastSourceManifest = e.compactManifest(initial)
+	for _, name := range []string{"a", "b", "c"} {
+		if err := os.WriteFile(filepath.Join(source, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e.offline = true
+	e.backlog = &offlineBacklog{since: time.Now(), paths: make(map[string]int64)}
+	current, _ := e.scanner.ScanLocal(source)
+	e.recordBacklog(current)
+	if stats := e.GetBacklogStats(); stats.Paths != 2 || !stats.Overflow {
+		t.Errorf("Expected the backlog capped at 2 with overflow, got %+v", stats)
+	}
+}
+
+func TestEngine_WakeReceiverBeforeRemoteCycle(t *testing.T) {
+	source := t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "a.mkv"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wakes := 0
+	wake := func() error { wakes++; return nil }
+
+	local := NewEngine(SyncConfig{ID: "wake-local", SourceDir: source, TargetDir: t.TempDir(), Rule: "flat", WakeReceiver: wake})
+	if err := local.RunSync(nil); err != nil {
+		t.Fatal(err)
+	}
+	if wakes != 0 {
+		t.Error("Local targets have no receiver to wake")
+	}
+
+	remote := NewEngine(SyncConfig{ID: "wake-remote", SourceDir: source, TargetDir: "127.0.0.1::video-sync/tv", Rule: "flat", WakeReceiver: wake})
+	remote.probeReceiver = func() error { return errors.New("down") }
+	defer remote.Stop()
+	_ = remote.RunSync(nil)
+	if wakes != 1 {
+		t.Errorf("Expected one wake-up before the remote cycle, got %d", wakes)
+	}
+}
+
+func TestEngine_ReadOnlyReceiverPausesQuietly(t *testing.T) {
+	prevInterval := offlineProbeInterval
+	offlineProbeInterval = 10 * time.Millisecond
+	defer func() { offlineProbeInterval = prevInterval }()
+	source, target := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "a.mkv"), []byte("movie"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var reported []string
+	e := NewEngine(SyncConfig{ID: "read-only", SourceDir: source, TargetDir: "127.0.0.1::video-sync/tv", Rule: "flat",
+		OnError: func(msg string) { reported = append(reported, msg) }})
+	e.probeReceiver = func() error { return fmt.Errorf("%w: fsck", agent.ErrReadOnly) }
+	defer e.Stop()
+
+	if err := e.RunSync(nil); err != nil {
+		t.Fatalf("A read-only receiver should pause the engine without failing, got %v", err)
+	}
+	if stats := e.GetBacklogStats(); !stats.Offline || !stats.ReadOnly {
+		t.Fatalf("Expected the engine paused for the read-only receiver, got %+v", stats)
+	}
+	if len(reported) != 0 {
+		t.Errorf("Read-only receivers should not report errors, got %v", reported)
+	}
+
+	e.pausedMu.Lock()
+	e.config.TargetDir = target
+	e.probeReceiver = func() error { return nil }
+	e.pausedMu.Unlock()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(filepath.Join(target, "a.mkv")); err == nil {
+			if stats := e.GetBacklogStats(); stats.Offline || stats.ReadOnly {
+				t.Errorf("Engine should be back online, got %+v", stats)
+			}
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("No catch-up once the receiver was writable: %+v", e.GetBacklogStats())
+}
@@ -0,0 +1,196 @@
+package sync
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	stdsync "sync"
+)
+
+// Ways two engine configurations can overlap
+const (
+	OverlapTargets        = "targets"          // Both engines write below the same path
+	OverlapTargetInSource = "target_in_source" // One engine writes into the other's source
+	OverlapMovedSource    = "moved_source"     // Shared source that the move rule empties
+)
+
+// PathOverlap describes two engines whose configured paths overlap
+type PathOverlap struct {
+	A    string `json:"a"`
+	B    string `json:"b"`
+	Kind string `json:"kind"`
+	Path string `json:"path"`
+}
+
+func (o PathOverlap) String() string {
+	switch o.Kind {
+	case OverlapTargets:
+		return fmt.Sprintf("engines %s and %s both write below %s", o.A, o.B, o.Path)
+	case OverlapTargetInSource:
+		return fmt.Sprintf("engine %s writes into the source of engine %s (%s)", o.A, o.B, o.Path)
+	case OverlapMovedSource:
+		return fmt.Sprintf("engines %s and %s share the source %s and the move rule removes files from it", o.A, o.B, o.Path)
+	}
+	return fmt.Sprintf("engines %s and %s overlap at %s", o.A, o.B, o.Path)
+}
+
+// FindOverlaps reports engine configurations whose paths overlap. Such
+// engines still run; writes to the same destination are serialised by the
+// per-path locks, but the engines may keep undoing each other's work.
+func FindOverlaps(configs []SyncConfig) []PathOverlap {
+	var out []PathOverlap
+	for i := range configs {
+		a := configs[i]
+		for j := range configs {
+			if i == j {
+				continue
+			}
+			b := configs[j]
+			if j > i {
+				if p, ok := nestedPaths(destinationKey(a.TargetDir), destinationKey(b.TargetDir)); ok {
+					out = append(out, PathOverlap{A: a.ID, B: b.ID, Kind: OverlapTargets, Path: p})
+				}
+				if a.MoveAfter > 0 || b.MoveAfter > 0 {
+					if p, ok := nestedPaths(destinationKey(a.SourceDir), destinationKey(b.SourceDir)); ok {
+						out = append(out, PathOverlap{A: a.ID, B: b.ID, Kind: OverlapMovedSource, Path: p})
+					}
+				}
+			}
+			if p, ok := nestedPaths(destinationKey(a.TargetDir), destinationKey(b.SourceDir)); ok {
+				out = append(out, PathOverlap{A: a.ID, B: b.ID, Kind: OverlapTargetInSource, Path: p})
+			}
+		}
+	}
+	return out
+}
+
+// nestedPaths returns the deeper of two paths when one contains the other
+func nestedPaths(a, b string) (string, bool) {
+	if a == "" || b == "" {
+		return "", false
+	}
+	if pathWithin(a, b) {
+		return a, true
+	}
+	if pathWithin(b, a) {
+		return b, true
+	}
+	return "", false
+}
+
+// pathWithin reports whether p is dir or below it
+func pathWithin(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// destinationKey normalises a local or rsync path so that the same location
+// compares equal however it was spelled: rsync destinations become
+// rsync://host/module/path without user and port, local paths are absolute.
+func destinationKey(p string) string {
+	if p == "" {
+		return ""
+	}
+	p = filepath.ToSlash(p)
+	host, rest, remote := "", "", false
+	if r, ok := strings.CutPrefix(p, "rsync:/"); ok {
+		// filepath.Join collapses the double slash of rsync://
+		host, rest, _ = strings.Cut(strings.TrimPrefix(r, "/"), "/")
+		remote = true
+	} else if h, r, ok := strings.Cut(p, "::"); ok {
+		host, rest, remote = h, r, true
+	}
+	if remote {
+		if i := strings.LastIndex(host, "@"); i != -1 {
+			host = host[i+1:]
+		}
+		if i := strings.Index(host, ":"); i != -1 {
+			host = host[:i]
+		}
+		return "rsync://" + strings.ToLower(host) + path.Clean("/"+rest)
+	}
+	if abs, err := filepath.Abs(filepath.FromSlash(p)); err == nil {
+		p = filepath.ToSlash(abs)
+	}
+	return path.Clean(p)
+}
+
+// destinationLock is a set of destination paths held by one engine
+type destinationLock struct {
+	owner string
+	keys  []string
+	done  chan struct{}
+}
+
+var (
+	// destinationLocks are the advisory per-path locks held while an engine
+	// writes or removes a destination. A path conflicts with the paths below
+	// it, so a directory removal waits for writes into that directory.
+	destinationLocks   []*destinationLock
+	destinationLocksMu stdsync.Mutex
+)
+
+// lockDestinations acquires all keys at once, waiting while another holder
+// has one of them (or a path above or below it). Acquiring the whole set
+// atomically keeps engines that lock several paths from deadlocking.
+// onWait is called with the holder's name before each wait.
+func lockDestinations(owner string, keys []string, onWait func(holder, key string)) func() {
+	sort.Strings(keys)
+	l := &destinationLock{owner: owner, keys: keys, done: make(chan struct{})}
+	for {
+		destinationLocksMu.Lock()
+		blocker, key := conflictingDestination(keys)
+		if blocker == nil {
+			destinationLocks = append(destinationLocks, l)
+			destinationLocksMu.Unlock()
+			break
+		}
+		destinationLocksMu.Unlock()
+		if onWait != nil {
+			onWait(blocker.owner, key)
+		}
+		<-blocker.done
+	}
+	var once stdsync.Once
+	return func() {
+		once.Do(func() {
+			destinationLocksMu.Lock()
+			for i, held := range destinationLocks {
+				if held == l {
+					destinationLocks = append(destinationLocks[:i], destinationLocks[i+1:]...)
+					break
+				}
+			}
+			destinationLocksMu.Unlock()
+			close(l.done)
+		})
+	}
+}
+
+// conflictingDestination returns the held lock covering one of keys. The
+// caller holds destinationLocksMu.
+func conflictingDestination(keys []string) (*destinationLock, string) {
+	for _, held := range destinationLocks {
+		for _, h := range held.keys {
+			for _, k := range keys {
+				if _, ok := nestedPaths(h, k); ok {
+					return held, k
+				}
+			}
+		}
+	}
+	return nil, ""
+}
+
+// lockTarget locks target-relative paths against writes by other engines
+// and returns the release function
+func (e *Engine) lockTarget(rels ...string) func() {
+	keys := make([]string, 0, len(rels))
+	for _, rel := range rels {
+		keys = append(keys, destinationKey(filepath.Join(e.config.TargetDir, rel)))
+	}
+	return lockDestinations(e.config.ID, keys, func(holder, key string) {
+		e.logger().Info("Waiting for another engine writing the same destination", "path", key, "holder", holder)
+	})
+}
@@ -0,0 +1,67 @@
+package sync
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFindOverlaps(t *testing.T) {
+	configs := []SyncConfig{
+		{ID: "tv", SourceDir: "/data/tv", TargetDir: "rsync://user@nas:873/video/tv"},
+		{ID: "anime", SourceDir: "/data/anime", TargetDir: "nas::video/tv/anime"},
+		{ID: "relay", SourceDir: "/data/relay", TargetDir: "/data/tv/incoming"},
+		{ID: "movies", SourceDir: "/data/movies", TargetDir: "rsync://nas/video/movies", MoveAfter: time.Hour},
+		{ID: "backup", SourceDir: "/data/movies/", TargetDir: "/backup/movies"},
+	}
+	got := make(map[string]PathOverlap)
+	for _, o := range FindOverlaps(configs) {
+		got[o.Kind+" "+o.A+" "+o.B] = o
+	}
+	want := map[string]string{
+		OverlapTargets + " tv anime":          "rsync://nas/video/tv/anime",
+		OverlapTargetInSource + " relay tv":   "/data/tv/incoming",
+		OverlapMovedSource + " movies backup": "/data/movies",
+	}
+	for k, p := range want {
+		if o, ok := got[k]; !ok || o.Path != p {
+			t.Errorf("Overlap %q = %+v, want path %s", k, o, p)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("Unexpected overlaps: %v", got)
+	}
+}
+
+func TestLockDestinations(t *testing.T) {
+	release := lockDestinations("a", []string{"/t/dir"}, nil)
+
+	acquired := make(chan string, 2)
+	waited := make(chan string, 2)
+	go func() {
+		r := lockDestinations("b", []string{"/t/dir/file.mkv"}, func(holder, _ string) { waited <- holder })
+		acquired <- "b"
+		r()
+	}()
+	// Unrelated paths are not blocked
+	lockDestinations("c", []string{"/t/other"}, nil)()
+
+	select {
+	case holder := <-waited:
+		if holder != "a" {
+			t.Errorf("Waiting on %q, want a", holder)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("A path below a held directory should wait")
+	}
+	select {
+	case <-acquired:
+		t.Fatal("The lock must not be granted while the directory is held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("The lock should be granted once released")
+	}
+}
@@ -0,0 +1,194 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"os"
+	stdsync "sync"
+	"sync/atomic"
+	"time"
+
+	"schnorarr/internal/monitor/database"
+)
+
+const (
+	// MaxNumStreams caps the adaptive stream count of parallel copies
+	MaxNumStreams = 16
+	// maxSegmentSize is the largest byte range a stream claims at once
+	maxSegmentSize = 16 * 1024 * 1024
+)
+
+// streamAdjustInterval is how often a parallel copy re-evaluates its stream count
+var streamAdjustInterval = 2 * time.Second
+
+// streamTuner hill-climbs the stream count: it keeps stepping in one direction
+// while throughput improves by more than 10%, reverses when it drops by more
+// than 10% and holds otherwise. A bandwidth limited copy therefore settles
+// instead of adding streams that cannot go any faster.
+type streamTuner struct {
+	streams, max int
+	dir          int
+	lastRate     float64
+	sampled      bool
+}
+
+func newStreamTuner(initial, max int) *streamTuner {
+	if initial > max {
+		max = initial
+	}
+	return &streamTuner{streams: initial, max: max, dir: 1}
+}
+
+// next returns the stream count for the throughput (bytes/s) of the last interval
+func (st *streamTuner) next(rate float64) int {
+	switch {
+	case !st.sampled:
+		st.streams += st.dir // Probe upwards after the first sample
+		st.sampled = true
+	case rate > st.lastRate*1.1:
+		st.streams += st.dir
+	case rate < st.lastRate*0.9:
+		st.dir = -st.dir
+		st.streams += st.dir
+	}
+	if st.streams >= st.max {
+		st.streams, st.dir = st.max, -1
+	}
+	if st.streams <= 1 {
+		st.streams, st.dir = 1, 1
+	}
+	st.lastRate = rate
+	return st.streams
+}
+
+// copyParallel copies a file in segments claimed by a varying number of
+// streams. It starts with numStreams streams and, unless FixedStreams is set,
+// adjusts the count every streamAdjustInterval based on observed throughput.
+func (t *Transferer) copyParallel(filename string, srcFile, dstFile *os.File, totalSize int64) (int64, error) {
+	chunkSize := t.chunkSize()
+	segSize := totalSize / (MaxNumStreams * 4)
+	if segSize > maxSegmentSize {
+		segSize = maxSegmentSize
+	}
+	if segSize < int64(chunkSize) {
+		segSize = int64(chunkSize)
+	}
+	segments := (totalSize + segSize - 1) / segSize
+
+	var (
+		nextSegment  atomic.Int64
+		active       atomic.Int32
+		totalWritten atomic.Int64
+		failed       atomic.Bool
+		errOnce      stdsync.Once
+		firstErr     error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() { firstErr = err })
+		failed.Store(true)
+	}
+
+	// worker copies segments until none are left or its ID exceeds the active count
+	worker := func(id int32) {
+		withIOPriority(t.opts.IOClass, t.opts.IOLevel, func() {
+			buf := make([]byte, chunkSize)
+			for !failed.Load() && id < active.Load() {
+				seg := nextSegment.Add(1) - 1
+				if seg >= segments {
+					return
+				}
+				start := seg * segSize
+				end := start + segSize
+				if end > totalSize {
+					end = totalSize
+				}
+
+				for offset := start; offset < end; {
+					if t.opts.CheckPaused != nil && t.opts.CheckPaused() {
+						fail(fmt.Errorf("transfer interrupted by pause"))
+						return
+					}
+
+					toRead := int64(len(buf))
+					if offset+toRead > end {
+						toRead = end - offset
+					}
+
+					nr, err := srcFile.ReadAt(buf[:toRead], offset)
+					if nr > 0 {
+						t.limiter.wait(nr)
+						nw, ew := dstFile.WriteAt(buf[:nr], offset)
+						if ew != nil {
+							fail(ew)
+							return
+						}
+						currentTotal := totalWritten.Add(int64(nw))
+						t.transferred(int64(nw))
+						if t.opts.OnProgress != nil {
+							t.opts.OnProgress(filename, currentTotal, totalSize)
+						}
+						offset += int64(nw)
+					}
+					if err != nil && err != io.EOF && offset < end {
+						fail(err)
+						return
+					}
+					if nr == 0 {
+						break
+					}
+				}
+			}
+		})
+	}
+
+	tuner := newStreamTuner(t.numStreams(), MaxNumStreams)
+	active.Store(t.capStreams(tuner.streams))
+	t.logger().Info("Starting parallel transfer", "streams", tuner.streams, "adaptive", !t.opts.FixedStreams, "file", filename)
+
+	// Only this goroutine starts workers and tracks which are running
+	alive := make([]bool, tuner.max)
+	exits := make(chan int32, tuner.max)
+	running := 0
+	spawn := func() {
+		for id := int32(0); id < active.Load(); id++ {
+			if !alive[id] && nextSegment.Load() < segments {
+				alive[id] = true
+				running++
+				go func(id int32) {
+					worker(id)
+					exits <- id
+				}(id)
+			}
+		}
+	}
+	spawn()
+
+	ticker := time.NewTicker(streamAdjustInterval)
+	defer ticker.Stop()
+	lastBytes, lastTime := int64(0), time.Now()
+	for running > 0 {
+		select {
+		case id := <-exits:
+			alive[id] = false
+			running--
+		case now := <-ticker.C:
+			if failed.Load() {
+				continue
+			}
+			written := totalWritten.Load()
+			rate := float64(written-lastBytes) / now.Sub(lastTime).Seconds()
+			lastBytes, lastTime = written, now
+			streams := tuner.streams
+			if !t.opts.FixedStreams {
+				streams = tuner.next(rate)
+			}
+			if n := t.capStreams(streams); n != active.Load() {
+				t.logger().Debug("Adjusting parallel streams", "file", filename, "streams", n, "speed", database.FormatBytes(int64(rate))+"/s")
+				active.Store(n)
+				spawn()
+			}
+		}
+	}
+
+	return totalWritten.Load(), firstErr
+}
@@ -0,0 +1,276 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"schnorarr/internal/monitor/database"
+)
+
+// ParityBaseName names the PAR2 files of a directory:
+// schnorarr-parity.par2 and its schnorarr-parity.volNN+NN.par2 volumes
+const ParityBaseName = "schnorarr-parity"
+
+// parityPattern matches the parity files the engine writes
+const parityPattern = ParityBaseName + "*.par2"
+
+// parityTmpPattern matches the directories par2 works in on local targets
+const parityTmpPattern = ".schnorarr-parity-*"
+
+// par2Command is the par2cmdline binary; a variable so tests can replace it
+var par2Command = "par2"
+
+// parityEnabled reports whether the engine keeps PAR2 parity on the target
+func (e *Engine) parityEnabled() bool {
+	return e.config.ParityRedundancy > 0
+}
+
+// parityFiles returns the files of each target directory, by slash-separated
+// directory ("." for the root)
+func parityFiles(target *Manifest) map[string][]*FileInfo {
+	byDir := make(map[string][]*FileInfo)
+	target.mu.RLock()
+	defer target.mu.RUnlock()
+	for _, f := range target.Files {
+		if !f.IsDir {
+			dir := path.Dir(filepath.ToSlash(f.Path))
+			byDir[dir] = append(byDir[dir], f)
+		}
+	}
+	for _, files := range byDir {
+		sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	}
+	return byDir
+}
+
+// parityDigest identifies the contents of a directory by name, size and mtime
+func parityDigest(files []*FileInfo) string {
+	h := sha256.New()
+	for _, f := range files {
+		_, _ = fmt.Fprintf(h, "%s\x00%d\x00%d\n", path.Base(f.Path), f.Size, f.ModTime.Unix())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// planParity fills plan.ParityDirs: directories the plan changes, plus
+// complete directories whose parity no longer matches the target, e.g.
+// after parity was enabled or a run was interrupted. Directories whose
+// parity failed are retried after the retry delay.
+func (e *Engine) planParity(source, target *Manifest, plan *SyncPlan) {
+	if !e.parityEnabled() {
+		return
+	}
+	dirs := checksumDirs(plan)
+	sets, err := database.LoadParitySets(e.config.ID)
+	if err != nil {
+		e.logger().Warn("Failed to load parity sets", "error", err)
+	}
+	byDir := parityFiles(target)
+	sourceByDir := parityFiles(source)
+	e.pausedMu.RLock()
+	for dir, files := range byDir {
+		if p, ok := sets[dir]; ok && p.Digest == parityDigest(files) {
+			continue
+		}
+		if failed, ok := e.parityFailed[dir]; ok && time.Since(failed) < failedRetryDelay {
+			continue
+		}
+		if e.parityReady(files, sourceByDir[dir], source, target) == "" {
+			dirs[dir] = true
+		}
+	}
+	e.pausedMu.RUnlock()
+	for dir := range sets {
+		if len(byDir[dir]) == 0 {
+			dirs[dir] = true
+		}
+	}
+	plan.ParityDirs = nil
+	for dir := range dirs {
+		plan.ParityDirs = append(plan.ParityDirs, dir)
+	}
+	sort.Strings(plan.ParityDirs)
+}
+
+// parityReady returns why the parity of a directory cannot be created yet,
+// or "" when the directory is complete
+func (e *Engine) parityReady(files, sourceFiles []*FileInfo, source, target *Manifest) string {
+	if pending := incompleteFile(sourceFiles, target); pending != "" {
+		return pending + " is not on the target yet"
+	}
+	// Remote parity is computed from the source copies
+	if missing := incompleteFile(files, source); missing != "" && IsRemotePath(e.config.TargetDir) {
+		return missing + " is not on the source"
+	}
+	return ""
+}
+
+// updateParity creates the parity of the planned directories once they are
+// complete, i.e. every source file of the directory is on the target, and
+// removes the parity of directories without files. target is the target
+// manifest as updated by the cycle.
+func (e *Engine) updateParity(source, target *Manifest, plan *SyncPlan) {
+	if !e.parityEnabled() || len(plan.ParityDirs) == 0 {
+		return
+	}
+	sets, err := database.LoadParitySets(e.config.ID)
+	if err != nil {
+		e.logger().Warn("Failed to load parity sets", "error", err)
+		return
+	}
+	byDir := parityFiles(target)
+	sourceByDir := parityFiles(source)
+	for _, dir := range plan.ParityDirs {
+		if e.IsPaused() {
+			return
+		}
+		old, hasOld := sets[dir]
+		files := byDir[dir]
+		if len(files) == 0 {
+			if hasOld {
+				e.removeParityFiles(dir, old.Files)
+				_ = database.DeleteParitySet(e.config.ID, dir)
+			}
+			continue
+		}
+		digest := parityDigest(files)
+		if hasOld && old.Digest == digest {
+			continue
+		}
+		if reason := e.parityReady(files, sourceByDir[dir], source, target); reason != "" {
+			e.logger().Debug("Parity deferred", "dir", dir, "reason", reason)
+			continue
+		}
+		created, err := e.createParity(dir, files)
+		e.pausedMu.Lock()
+		if err != nil {
+			e.parityFailed[dir] = time.Now()
+		} else {
+			delete(e.parityFailed, dir)
+		}
+		e.pausedMu.Unlock()
+		if err != nil {
+			e.logger().Warn("Failed to create parity", "dir", dir, "error", err)
+			e.reportError(fmt.Sprintf("Failed to create parity for %s: %v", dir, err))
+			continue
+		}
+		if hasOld {
+			e.removeParityFiles(dir, stale(old.Files, created))
+		}
+		if err := database.SaveParitySet(e.config.ID, database.ParitySet{Dir: dir, Digest: digest, Files: created}); err != nil {
+			e.logger().Warn("Failed to save parity set", "dir", dir, "error", err)
+		}
+		e.logger().Info("Parity created", "dir", dir, "files", len(files), "parity_files", len(created))
+	}
+}
+
+// incompleteFile returns a source file that is not on the target yet
+func incompleteFile(sourceFiles []*FileInfo, target *Manifest) string {
+	for _, f := range sourceFiles {
+		if t, ok := target.GetFile(f.Path); !ok || t.Size != f.Size {
+			return f.Path
+		}
+	}
+	return ""
+}
+
+// stale returns the names of old that are not in current
+func stale(old, current []string) []string {
+	keep := make(map[string]bool, len(current))
+	for _, n := range current {
+		keep[n] = true
+	}
+	var out []string
+	for _, n := range old {
+		if !keep[n] {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// createParity runs par2 for the files of a target directory and publishes
+// the parity files, returning their names. Local targets are protected from
+// their own copies; for remote targets the parity is computed from the
+// source and sent, so every file must still be on the source.
+func (e *Engine) createParity(dir string, files []*FileInfo) ([]string, error) {
+	remote := IsRemotePath(e.config.TargetDir)
+	base := filepath.Join(e.config.TargetDir, filepath.FromSlash(dir))
+	if remote {
+		base = filepath.Join(e.config.SourceDir, filepath.FromSlash(dir))
+	}
+	args := []string{"create", "-q", fmt.Sprintf("-r%d", e.config.ParityRedundancy), "-B" + base}
+
+	// Local parity is built next to the files, so publishing it is a rename
+	tmpRoot := os.TempDir()
+	if !remote {
+		tmpRoot = base
+	}
+	tmp, err := os.MkdirTemp(tmpRoot, strings.TrimSuffix(parityTmpPattern, "*"))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	args = append(args, filepath.Join(tmp, ParityBaseName+".par2"))
+	for _, f := range files {
+		args = append(args, filepath.Join(base, path.Base(f.Path)))
+	}
+
+	start := time.Now()
+	out, err := exec.Command(par2Command, args...).CombinedOutput()
+	if err != nil {
+		if msg := lastLine(string(out)); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	entries, err := os.ReadDir(tmp)
+	if err != nil {
+		return nil, err
+	}
+	var created []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if matched, _ := path.Match(parityPattern, name); !matched {
+			continue
+		}
+		rel := path.Join(dir, name)
+		if remote {
+			_ = e.transferer.DeleteFile(filepath.Join(e.config.TargetDir, rel))
+			err = e.transferer.CopyFile(filepath.Join(tmp, name), filepath.Join(e.config.TargetDir, rel))
+		} else {
+			release := e.lockTarget(rel)
+			err = os.Rename(filepath.Join(tmp, name), filepath.Join(base, name))
+			release()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to publish %s: %w", name, err)
+		}
+		created = append(created, name)
+	}
+	if len(created) == 0 {
+		return nil, fmt.Errorf("par2 created no parity files")
+	}
+	e.logger().Debug("par2 finished", "dir", dir, "duration", time.Since(start).String())
+	return created, nil
+}
+
+// removeParityFiles deletes parity files of a directory from the target
+func (e *Engine) removeParityFiles(dir string, names []string) {
+	for _, name := range names {
+		if strings.ContainsAny(name, `/\`) {
+			continue
+		}
+		if err := e.transferer.DeleteFile(filepath.Join(e.config.TargetDir, filepath.FromSlash(dir), name)); err != nil {
+			e.logger().Warn("Failed to delete parity file", "dir", dir, "file", name, "error", err)
+		}
+	}
+}
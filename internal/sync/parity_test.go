@@ -0,0 +1,141 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"schnorarr/internal/monitor/database"
+)
+
+func TestEngine_ParityFollowsDirectories(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as par2")
+	}
+	database.DBPath = filepath.Join(t.TempDir(), "parity.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+
+	// Writes the index and one volume next to the given .par2 file and logs the protected files
+	bin := t.TempDir()
+	script := "#!/bin/sh\nfor a; do case \"$a\" in *.par2) out=\"$a\";; /*) echo \"$(basename \"$a\")\" >> \"" + filepath.Join(bin, "log") + "\";; esac; done\n" +
+		"echo index > \"$out\"\necho volume > \"${out%.par2}.vol00+01.par2\"\n"
+	if err := os.WriteFile(filepath.Join(bin, "par2"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := par2Command
+	par2Command = filepath.Join(bin, "par2")
+	defer func() { par2Command = old }()
+
+	source, target := t.TempDir(), t.TempDir()
+	season := filepath.Join(source, "Show", "Season 1")
+	if err := os.MkdirAll(season, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(season, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("e01.mkv", "one")
+	write("e02.mkv", "two")
+
+	e := NewEngine(SyncConfig{ID: "parity", SourceDir: source, TargetDir: target, Rule: "flat", ParityRedundancy: 10, AutoApproveDeletions: true})
+	if err := e.RunSync(nil); err != nil {
+		t.Fatal(err)
+	}
+	targetSeason := filepath.Join(target, "Show", "Season 1")
+	for _, name := range []string{ParityBaseName + ".par2", ParityBaseName + ".vol00+01.par2"} {
+		if _, err := os.Stat(filepath.Join(targetSeason, name)); err != nil {
+			t.Errorf("%s should be created: %v", name, err)
+		}
+	}
+	if log, _ := os.ReadFile(filepath.Join(bin, "log")); string(log) != "e01.mkv\ne02.mkv\n" {
+		t.Errorf("par2 should protect the directory's files, got %q", log)
+	}
+	if entries, _ := os.ReadDir(targetSeason); len(entries) != 4 {
+		t.Errorf("The work directory should be removed, target has %d entries", len(entries))
+	}
+
+	plan, err := e.PreviewSync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.ParityDirs) != 0 || len(plan.FilesToDelete) != 0 {
+		t.Errorf("Up to date parity should not be planned or deleted, plan = %+v", plan)
+	}
+
+	// A changed directory is planned and its parity rebuilt
+	write("e03.mkv", "three")
+	plan, err = e.PreviewSync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(plan.ParityDirs, ",") != "Show/Season 1" {
+		t.Errorf("ParityDirs = %v, want the changed directory", plan.ParityDirs)
+	}
+	if err := e.RunSync(nil); err != nil {
+		t.Fatal(err)
+	}
+	if log, _ := os.ReadFile(filepath.Join(bin, "log")); !strings.HasSuffix(string(log), "e01.mkv\ne02.mkv\ne03.mkv\n") {
+		t.Errorf("Parity should be rebuilt with the new file, got %q", log)
+	}
+
+	// A deleted file is dropped from the parity
+	if err := os.Remove(filepath.Join(season, "e03.mkv")); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.RunSync(nil); err != nil {
+		t.Fatal(err)
+	}
+	if log, _ := os.ReadFile(filepath.Join(bin, "log")); !strings.HasSuffix(string(log), "e03.mkv\ne01.mkv\ne02.mkv\n") {
+		t.Errorf("Parity should be rebuilt without the deleted file, got %q", log)
+	}
+
+	// A directory without files loses its parity
+	gone := filepath.Join(target, "Gone")
+	if err := os.MkdirAll(gone, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(gone, ParityBaseName+".par2"), []byte("index"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_ = database.SaveParitySet("parity", database.ParitySet{Dir: "Gone", Digest: "x", Files: []string{ParityBaseName + ".par2"}})
+	plan = &SyncPlan{}
+	src, _ := e.scanner.ScanLocal(source)
+	dst, _ := e.scanner.ScanLocal(target)
+	e.planParity(src, dst, plan)
+	if strings.Join(plan.ParityDirs, ",") != "Gone" {
+		t.Fatalf("ParityDirs = %v, want the directory without files", plan.ParityDirs)
+	}
+	e.updateParity(src, dst, plan)
+	if _, err := os.Stat(filepath.Join(gone, ParityBaseName+".par2")); !os.IsNotExist(err) {
+		t.Error("Parity of a directory without files should be removed")
+	}
+	if sets, _ := database.LoadParitySets("parity"); len(sets) != 1 {
+		t.Errorf("Only the parity of the season should be left, got %v", sets)
+	}
+}
+
+func TestEngine_ParityWaitsForIncompleteDirectory(t *testing.T) {
+	e := NewEngine(SyncConfig{ID: "parity-wait", ParityRedundancy: 5})
+	source, target := NewManifest("/src"), NewManifest("/dst")
+	source.Add(&FileInfo{Path: "a/1.mkv", Size: 1})
+	source.Add(&FileInfo{Path: "a/2.mkv", Size: 2})
+	target.Add(&FileInfo{Path: "a/1.mkv", Size: 1})
+	plan := &SyncPlan{}
+	e.planParity(source, target, plan)
+	if len(plan.ParityDirs) != 0 {
+		t.Errorf("Incomplete directories should not be planned, got %v", plan.ParityDirs)
+	}
+	target.Add(&FileInfo{Path: "a/2.mkv", Size: 2})
+	e.planParity(source, target, plan)
+	if strings.Join(plan.ParityDirs, ",") != "a" {
+		t.Errorf("Complete directories should be planned, got %v", plan.ParityDirs)
+	}
+}
@@ -1,6 +1,9 @@
 package sync
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 )
@@ -56,6 +59,25 @@ func ResolveTargetPath(target, destHost, destModule string) string {
 	return target
 }
 
+// DestHosts returns the prioritized receiver hosts listed in DEST_HOST
+func DestHosts() []string {
+	var hosts []string
+	for _, h := range strings.Split(os.Getenv("DEST_HOST"), ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// defaultDestHost is the primary receiver, used when a target URI has no host
+func defaultDestHost() string {
+	if hosts := DestHosts(); len(hosts) > 0 {
+		return hosts[0]
+	}
+	return ""
+}
+
 // UpdateTargetHost replaces the host part of an rsync URI with destHost
 func UpdateTargetHost(target, destHost string) string {
 	if destHost == "" {
@@ -93,3 +115,41 @@ func UpdateTargetHost(target, destHost string) string {
 
 	return target
 }
+
+// IsRemotePath reports whether path is an rsync daemon target
+func IsRemotePath(path string) bool {
+	return strings.Contains(path, "::") || strings.HasPrefix(path, "rsync://")
+}
+
+// canonicalPath returns an absolute, symlink-resolved form of path where possible
+func canonicalPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = filepath.Clean(path)
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved
+	}
+	return abs
+}
+
+// ResolvePathOverlap checks a source/target pair for nesting that would make
+// the engine sync its own output forever. If the target lives inside the source
+// it returns the target's path relative to the source so it can be excluded.
+// Identical paths, or a source nested inside the target, are refused.
+func ResolvePathOverlap(source, target string) (excludeRel string, err error) {
+	if source == "" || target == "" || IsRemotePath(target) {
+		return "", nil
+	}
+	src, tgt := canonicalPath(source), canonicalPath(target)
+	if src == tgt {
+		return "", fmt.Errorf("source and target are the same directory (%s)", src)
+	}
+	if rel, err := filepath.Rel(src, tgt); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(rel), nil
+	}
+	if rel, err := filepath.Rel(tgt, src); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("source %s is nested inside target %s; syncing would delete or re-copy the source itself", src, tgt)
+	}
+	return "", nil
+}
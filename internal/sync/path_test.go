@@ -1,6 +1,11 @@
 package sync
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
 
 func TestResolveTargetPath(t *testing.T) {
 	tests := []struct {
@@ -99,3 +104,110 @@ func TestUpdateTargetHost(t *testing.T) {
 		})
 	}
 }
+
+func TestResolvePathOverlap(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "media")
+	nestedTgt := filepath.Join(src, "backup")
+	sibling := filepath.Join(root, "mirror")
+	for _, d := range []string{nestedTgt, sibling} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		source      string
+		target      string
+		wantExclude string
+		wantErr     bool
+	}{
+		{name: "disjoint", source: src, target: sibling},
+		{name: "prefix but not nested", source: src, target: src + "-old"},
+		{name: "target nested in source", source: src, target: nestedTgt, wantExclude: "backup"},
+		{name: "target nested via unclean path", source: src + "/", target: src + "/./backup/", wantExclude: "backup"},
+		{name: "source nested in target", source: nestedTgt, target: src, wantErr: true},
+		{name: "same directory", source: src, target: src + "/", wantErr: true},
+		{name: "remote target", source: src, target: "user@host::module/media"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exclude, err := ResolvePathOverlap(tt.source, tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolvePathOverlap() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if exclude != tt.wantExclude {
+				t.Errorf("ResolvePathOverlap() exclude = %q, want %q", exclude, tt.wantExclude)
+			}
+		})
+	}
+}
+
+func TestEngine_StartExcludesNestedTarget(t *testing.T) {
+	src := t.TempDir()
+	tgt := filepath.Join(src, "backup")
+	if err := os.Mkdir(tgt, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "movie.mkv"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tgt, "movie.mkv"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewEngine(SyncConfig{ID: "overlap", SourceDir: src, TargetDir: tgt})
+	if err := e.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer e.Stop()
+
+	m, err := e.scanner.ScanLocal(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.HasDir("backup") || m.HasFile("backup/movie.mkv") {
+		t.Error("Nested target should be excluded from source scans")
+	}
+
+	refused := NewEngine(SyncConfig{ID: "overlap-refused", SourceDir: tgt, TargetDir: src})
+	if err := refused.Start(); err == nil {
+		refused.Stop()
+		t.Error("Expected Start to refuse a source nested inside its target")
+	}
+}
+
+func TestDestHosts(t *testing.T) {
+	t.Setenv("DEST_HOST", " nas1 , nas2,,nas3")
+	if got := DestHosts(); !reflect.DeepEqual(got, []string{"nas1", "nas2", "nas3"}) {
+		t.Errorf("Unexpected hosts: %v", got)
+	}
+	if got := defaultDestHost(); got != "nas1" {
+		t.Errorf("Expected the primary host, got %q", got)
+	}
+}
+
+func TestEngine_SetTargetHost(t *testing.T) {
+	e := NewEngine(SyncConfig{ID: "failover", SourceDir: t.TempDir(), TargetDir: "syncuser@nas1::video-sync/movies"})
+	e.SetTargetHost("nas2")
+	if got := e.TargetHost(); got != "nas2" {
+		t.Errorf("Expected pending host nas2, got %q", got)
+	}
+	if got := e.GetConfig().TargetDir; got != "syncuser@nas1::video-sync/movies" {
+		t.Errorf("Target should only change at the next cycle, got %q", got)
+	}
+	e.applyPendingTarget()
+	if got := e.GetConfig().TargetDir; got != "syncuser@nas2::video-sync/movies" {
+		t.Errorf("Expected retargeted URI, got %q", got)
+	}
+
+	local := NewEngine(SyncConfig{ID: "local", SourceDir: t.TempDir(), TargetDir: t.TempDir()})
+	target := local.GetConfig().TargetDir
+	local.SetTargetHost("nas2")
+	local.applyPendingTarget()
+	if local.GetConfig().TargetDir != target {
+		t.Error("Local targets should not be retargeted")
+	}
+}
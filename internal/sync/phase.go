@@ -0,0 +1,67 @@
+package sync
+
+import "time"
+
+// Phases of a cycle that report PhaseProgress
+const (
+	PhaseRename = "rename"
+	PhaseDelete = "delete"
+)
+
+// PhaseProgress is how far a cycle got with its renames or deletions
+type PhaseProgress struct {
+	Phase string `json:"phase"`
+	Done  int    `json:"done"` // Changes handled, including the current one
+	Total int    `json:"total"`
+	Path  string `json:"path"` // The change being handled
+}
+
+// startPhase starts reporting the progress of total changes
+func (e *Engine) startPhase(phase string, total int) {
+	e.pausedMu.Lock()
+	e.phase = &PhaseProgress{Phase: phase, Total: total}
+	e.phaseLogTime = time.Now()
+	e.pausedMu.Unlock()
+}
+
+// phaseStep records that n more changes of the running phase are handled,
+// the last of them at path. It does nothing outside a phase.
+func (e *Engine) phaseStep(path string, n int) {
+	e.pausedMu.Lock()
+	p := e.phase
+	if p == nil {
+		e.pausedMu.Unlock()
+		return
+	}
+	p.Done = min(p.Done+n, p.Total)
+	p.Path = path
+	progress := *p
+	shouldLog := time.Since(e.phaseLogTime) >= 5*time.Second
+	if shouldLog {
+		e.phaseLogTime = time.Now()
+	}
+	e.pausedMu.Unlock()
+
+	if shouldLog {
+		e.logger().Info("Cleanup progress", "phase", progress.Phase, "done", progress.Done, "total", progress.Total, "path", progress.Path)
+	}
+}
+
+// endPhase stops reporting progress
+func (e *Engine) endPhase() {
+	e.pausedMu.Lock()
+	e.phase = nil
+	e.pausedMu.Unlock()
+}
+
+// GetPhaseProgress returns the progress of the renames or deletions being
+// applied, nil when none are
+func (e *Engine) GetPhaseProgress() *PhaseProgress {
+	e.pausedMu.RLock()
+	defer e.pausedMu.RUnlock()
+	if e.phase == nil {
+		return nil
+	}
+	p := *e.phase
+	return &p
+}
@@ -9,6 +9,9 @@ type ConflictDetail struct {
 	SourceTime   time.Time `json:"sourceTime"`
 	ReceiverSize int64     `json:"receiverSize"`
 	ReceiverTime time.Time `json:"receiverTime"`
+	// External is the kind of change made on the target outside of sync
+	// that the plan would undo; such conflicts always need a decision
+	External string `json:"external,omitempty"`
 }
 
 // SyncPlan describes the actions needed to sync sender to receiver
@@ -19,10 +22,56 @@ type SyncPlan struct {
 	DirsToDelete  []string          `json:"dirsToDelete"`
 	Renames       map[string]string `json:"renames"`
 	Conflicts     []*ConflictDetail `json:"conflicts"`
+	// Protected lists deletions that were dropped because they match a never-delete pattern
+	Protected []*ProtectedViolation `json:"protected"`
+	// Deferred lists deletions held back by the delete deferral window
+	Deferred []*DeferredDeletion `json:"deferred"`
+	// External lists target changes made outside of sync since the last cycle
+	External []*ExternalChange `json:"external,omitempty"`
+	// TargetAdded lists receiver-only paths the last sync never saw: they were
+	// added on the target rather than deleted on the source, so they are kept
+	TargetAdded []string `json:"targetAdded,omitempty"`
+	// TargetDeleted lists transfers restoring files deleted on the target
+	TargetDeleted []string `json:"targetDeleted,omitempty"`
+	// ParityDirs are target directories whose PAR2 parity is created or
+	// removed once they are complete
+	ParityDirs []string `json:"parityDirs,omitempty"`
+	// Skipped explains what the plan leaves alone; only filled for previews
+	Skipped []*SkippedItem `json:"skipped,omitempty"`
+}
+
+// CompareOptions controls how manifests are compared
+type CompareOptions struct {
+	// Rule is the sync strategy (e.g., "flat", "series")
+	Rule string
+	// SkipRenames disables rename detection
+	SkipRenames bool
+	// SkipDirRenames disables directory rename detection, which unlike file
+	// renames also works for remote targets
+	SkipDirRenames bool
+	// NeverDelete are path patterns that must never be deleted from the receiver
+	NeverDelete []string
+	// Include are the include patterns of the scan. Receiver files outside them
+	// are not part of the synced view and never deleted, like on the sender.
+	Include []string
+	// FlatDeleteDirs lets the flat rule delete receiver directories that were
+	// in sync (see Base) and are gone from the source, with all their contents
+	FlatDeleteDirs bool
+	// Mtime decides when files on both ends differ (zero value: size or newer source)
+	Mtime MtimeCompare
+	// Base holds the paths last seen in sync on both ends. With a base the
+	// comparison is three-way: receiver-only paths missing from it were added
+	// on the target and are not deleted.
+	Base *Manifest
 }
 
 // CompareManifests compares sender and receiver manifests and creates a sync plan
 func CompareManifests(sender, receiver *Manifest, rule string, skipRenames bool) *SyncPlan {
+	return CompareManifestsWithOptions(sender, receiver, CompareOptions{Rule: rule, SkipRenames: skipRenames})
+}
+
+// CompareManifestsWithOptions compares sender and receiver manifests and creates a sync plan
+func CompareManifestsWithOptions(sender, receiver *Manifest, opts CompareOptions) *SyncPlan {
 	plan := &SyncPlan{
 		FilesToSync:   make([]*FileInfo, 0),
 		FilesToDelete: make([]string, 0),
@@ -30,6 +79,8 @@ func CompareManifests(sender, receiver *Manifest, rule string, skipRenames bool)
 		DirsToDelete:  make([]string, 0),
 		Renames:       make(map[string]string),
 		Conflicts:     make([]*ConflictDetail, 0),
+		Protected:     make([]*ProtectedViolation, 0),
+		Deferred:      make([]*DeferredDeletion, 0),
 	}
 
 	for path, senderFile := range sender.Files {
@@ -41,7 +92,7 @@ func CompareManifests(sender, receiver *Manifest, rule string, skipRenames bool)
 			receiverFile, exists := receiver.GetFile(path)
 			if !exists {
 				plan.FilesToSync = append(plan.FilesToSync, senderFile)
-			} else if senderFile.NeedsUpdate(receiverFile) {
+			} else if opts.Mtime.NeedsUpdate(senderFile, receiverFile) {
 				plan.FilesToSync = append(plan.FilesToSync, senderFile)
 				plan.Conflicts = append(plan.Conflicts, &ConflictDetail{
 					Path:         path,
@@ -54,13 +105,58 @@ func CompareManifests(sender, receiver *Manifest, rule string, skipRenames bool)
 		}
 	}
 
-	plan.FilesToDelete, plan.DirsToDelete = identifyDeletions(sender, receiver, rule)
-	if !skipRenames {
+	// In move mode files leave the sender on purpose, so the receiver is never pruned
+	if opts.Rule != RuleMove {
+		plan.FilesToDelete, plan.DirsToDelete, plan.Protected = identifyDeletions(sender, receiver, opts)
+		if !opts.SkipDirRenames {
+			plan.detectDirRenames(sender, receiver, opts)
+		}
+	}
+	if opts.Base != nil {
+		plan.attribute(receiver, opts.Base)
+	}
+	if !opts.SkipRenames {
 		plan.detectRenames(receiver)
 	}
 	return plan
 }
 
+// attribute splits the two-way differences using base: a deletion stands
+// only for paths that were in sync before, i.e. were deleted on the source.
+// Transfers of paths that were in sync restore files deleted on the target.
+func (p *SyncPlan) attribute(receiver, base *Manifest) {
+	// Whatever is inside a directory deleted on the source goes with it
+	var deleted []string
+	for _, path := range p.DirsToDelete {
+		if base.HasDir(path) {
+			deleted = append(deleted, path)
+		}
+	}
+	files := make([]string, 0, len(p.FilesToDelete))
+	for _, path := range p.FilesToDelete {
+		if base.HasFile(path) || inTrees(path, deleted) {
+			files = append(files, path)
+		} else {
+			p.TargetAdded = append(p.TargetAdded, path)
+		}
+	}
+	dirs := make([]string, 0, len(p.DirsToDelete))
+	for _, path := range p.DirsToDelete {
+		if base.HasDir(path) || inTrees(path, deleted) {
+			dirs = append(dirs, path)
+		} else {
+			p.TargetAdded = append(p.TargetAdded, path)
+		}
+	}
+	p.FilesToDelete, p.DirsToDelete = files, dirs
+
+	for _, f := range p.FilesToSync {
+		if _, ok := receiver.GetFile(f.Path); !ok && base.HasFile(f.Path) {
+			p.TargetDeleted = append(p.TargetDeleted, f.Path)
+		}
+	}
+}
+
 func (p *SyncPlan) detectRenames(receiver *Manifest) {
 	if len(p.FilesToDelete) == 0 || len(p.FilesToSync) == 0 {
 		return
@@ -0,0 +1,140 @@
+package sync
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"schnorarr/internal/monitor/database"
+)
+
+// ErrNoPreview is returned by PreviewDiff when the engine was never previewed
+var ErrNoPreview = errors.New("no previous preview")
+
+// PlanDiff is what changed between the last previewed plan and the current one
+type PlanDiff struct {
+	Previous     time.Time `json:"previous"` // When the previous preview was taken
+	Transfers    DiffSet   `json:"transfers"`
+	Deletes      DiffSet   `json:"deletes"`
+	Renames      DiffSet   `json:"renames"` // Entries are "old -> new"
+	DirsToCreate DiffSet   `json:"dirs_to_create"`
+	DirsToDelete DiffSet   `json:"dirs_to_delete"`
+	Conflicts    DiffSet   `json:"conflicts"`
+	Unchanged    int       `json:"unchanged"` // Entries in both plans
+}
+
+// DiffSet lists the entries of one plan category that appeared or went away
+type DiffSet struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed,omitempty"` // Transfers whose size changed
+}
+
+// savePreview stores plan as the engine's last preview
+func (e *Engine) savePreview(plan *SyncPlan) {
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return
+	}
+	if err := database.SavePlanSnapshot(e.config.ID, data); err != nil {
+		e.logger().Warn("Failed to store preview", "error", err)
+	}
+}
+
+// PreviewDiff compares the current plan with the last preview, leaving the
+// stored preview untouched so the diff keeps growing until the next preview
+func (e *Engine) PreviewDiff() (*PlanDiff, error) {
+	data, taken, err := database.LoadPlanSnapshot(e.config.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoPreview
+	}
+	if err != nil {
+		return nil, err
+	}
+	var prev SyncPlan
+	if err := json.Unmarshal(data, &prev); err != nil {
+		return nil, fmt.Errorf("stored preview unreadable: %w", err)
+	}
+
+	source, target, err := e.scanManifests()
+	if err != nil {
+		return nil, err
+	}
+	cur := e.comparePlan(source, target)
+	e.deferDeletions(cur, false)
+
+	diff := DiffPlans(&prev, cur)
+	diff.Previous = taken
+	return diff, nil
+}
+
+// DiffPlans compares two plans category by category
+func DiffPlans(prev, cur *SyncPlan) *PlanDiff {
+	diff := &PlanDiff{}
+	sizes := func(plan *SyncPlan) map[string]string {
+		m := make(map[string]string, len(plan.FilesToSync))
+		for _, f := range plan.FilesToSync {
+			m[f.Path] = fmt.Sprint(f.Size)
+		}
+		return m
+	}
+	renames := func(plan *SyncPlan) map[string]string {
+		m := make(map[string]string, len(plan.Renames))
+		for oldP, newP := range plan.Renames {
+			m[oldP+" -> "+newP] = ""
+		}
+		return m
+	}
+	conflicts := func(plan *SyncPlan) map[string]string {
+		m := make(map[string]string, len(plan.Conflicts))
+		for _, c := range plan.Conflicts {
+			m[c.Path] = ""
+		}
+		return m
+	}
+
+	diff.Transfers = diff.compare(sizes(prev), sizes(cur))
+	diff.Deletes = diff.compare(pathSet(prev.FilesToDelete), pathSet(cur.FilesToDelete))
+	diff.Renames = diff.compare(renames(prev), renames(cur))
+	diff.DirsToCreate = diff.compare(pathSet(prev.DirsToCreate), pathSet(cur.DirsToCreate))
+	diff.DirsToDelete = diff.compare(pathSet(prev.DirsToDelete), pathSet(cur.DirsToDelete))
+	diff.Conflicts = diff.compare(conflicts(prev), conflicts(cur))
+	return diff
+}
+
+// compare diffs two entry sets; entries present in both but with different
+// values count as changed
+func (d *PlanDiff) compare(prev, cur map[string]string) DiffSet {
+	set := DiffSet{Added: []string{}, Removed: []string{}}
+	for k, v := range cur {
+		old, ok := prev[k]
+		switch {
+		case !ok:
+			set.Added = append(set.Added, k)
+		case old != v:
+			set.Changed = append(set.Changed, k)
+		default:
+			d.Unchanged++
+		}
+	}
+	for k := range prev {
+		if _, ok := cur[k]; !ok {
+			set.Removed = append(set.Removed, k)
+		}
+	}
+	sort.Strings(set.Added)
+	sort.Strings(set.Removed)
+	sort.Strings(set.Changed)
+	return set
+}
+
+func pathSet(paths []string) map[string]string {
+	m := make(map[string]string, len(paths))
+	for _, p := range paths {
+		m[p] = ""
+	}
+	return m
+}
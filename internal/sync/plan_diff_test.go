@@ -0,0 +1,39 @@
+package sync
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffPlans(t *testing.T) {
+	prev := &SyncPlan{
+		FilesToSync:   []*FileInfo{{Path: "Show/ep1.mkv", Size: 100}, {Path: "Show/ep2.mkv", Size: 200}},
+		FilesToDelete: []string{"Old/a.mkv"},
+		Renames:       map[string]string{"x.mkv": "y.mkv"},
+	}
+	cur := &SyncPlan{
+		FilesToSync:   []*FileInfo{{Path: "Show/ep2.mkv", Size: 250}, {Path: "Show/ep3.mkv", Size: 300}},
+		FilesToDelete: []string{"Old/a.mkv", "Old/b.mkv"},
+		Conflicts:     []*ConflictDetail{{Path: "Movie.mkv"}},
+	}
+
+	diff := DiffPlans(prev, cur)
+	check := func(name string, got, want []string) {
+		t.Helper()
+		if len(got) == 0 && len(want) == 0 {
+			return
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+	check("transfers added", diff.Transfers.Added, []string{"Show/ep3.mkv"})
+	check("transfers removed", diff.Transfers.Removed, []string{"Show/ep1.mkv"})
+	check("transfers changed", diff.Transfers.Changed, []string{"Show/ep2.mkv"})
+	check("deletes added", diff.Deletes.Added, []string{"Old/b.mkv"})
+	check("renames removed", diff.Renames.Removed, []string{"x.mkv -> y.mkv"})
+	check("conflicts added", diff.Conflicts.Added, []string{"Movie.mkv"})
+	if diff.Unchanged != 1 {
+		t.Errorf("Unchanged = %d, want 1 (Old/a.mkv)", diff.Unchanged)
+	}
+}
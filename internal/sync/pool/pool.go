@@ -3,6 +3,15 @@ package pool
 // Semaphore is a simple channel-based semaphore to limit concurrency
 var GlobalTransferPool = make(chan struct{}, 1)
 
+// SetSize changes the number of concurrent transfers allowed.
+// It must be called before any transfer acquires a slot.
+func SetSize(n int) {
+	if n < 1 {
+		n = 1
+	}
+	GlobalTransferPool = make(chan struct{}, n)
+}
+
 // Acquire takes a slot in the pool
 func Acquire() {
 	GlobalTransferPool <- struct{}{}
@@ -0,0 +1,39 @@
+package sync
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// ProtectedViolation records a planned action that was blocked by a protected path pattern
+type ProtectedViolation struct {
+	Path    string `json:"path"`
+	Action  string `json:"action"`
+	Pattern string `json:"pattern"`
+}
+
+// matchProtected reports the first pattern that protects rel.
+// A pattern matches the relative path itself or any of its parent directories,
+// so "Archive" protects everything below it. Patterns without a slash are also
+// matched against each path component, like exclude patterns.
+func matchProtected(patterns []string, rel string) (string, bool) {
+	rel = filepath.ToSlash(rel)
+	for _, p := range patterns {
+		p = strings.Trim(filepath.ToSlash(strings.TrimSpace(p)), "/")
+		if p == "" {
+			continue
+		}
+		component := !strings.Contains(p, "/")
+		for curr := rel; curr != "." && curr != "/" && curr != ""; curr = filepath.ToSlash(filepath.Dir(curr)) {
+			if m, _ := filepath.Match(p, curr); m {
+				return p, true
+			}
+			if component {
+				if m, _ := filepath.Match(p, filepath.Base(curr)); m {
+					return p, true
+				}
+			}
+		}
+	}
+	return "", false
+}
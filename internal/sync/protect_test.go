@@ -0,0 +1,97 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMatchProtected(t *testing.T) {
+	patterns := []string{"Archive", "Movies/Keep/*", "*.nfo"}
+	tests := []struct {
+		path    string
+		pattern string
+		match   bool
+	}{
+		{"Archive/old.mkv", "Archive", true},
+		{"Shows/Archive/s01e01.mkv", "Archive", true},
+		{"Movies/Keep/film.mkv", "Movies/Keep/*", true},
+		{"Movies/Keep/Extras/clip.mkv", "Movies/Keep/*", true},
+		{"Shows/show.nfo", "*.nfo", true},
+		{"Movies/film.mkv", "", false},
+		{"Archived/file.mkv", "", false},
+	}
+	for _, tt := range tests {
+		pattern, ok := matchProtected(patterns, tt.path)
+		if ok != tt.match || pattern != tt.pattern {
+			t.Errorf("matchProtected(%q) = %q, %v; want %q, %v", tt.path, pattern, ok, tt.pattern, tt.match)
+		}
+	}
+}
+
+func TestCompareManifests_NeverDelete(t *testing.T) {
+	sender := NewManifest("/src")
+	sender.Add(&FileInfo{Path: "Show", IsDir: true})
+	sender.Add(&FileInfo{Path: "Show/current.mkv", Size: 100})
+
+	receiver := NewManifest("/dst")
+	receiver.Add(&FileInfo{Path: "Show", IsDir: true})
+	receiver.Add(&FileInfo{Path: "Show/current.mkv", Size: 100})
+	receiver.Add(&FileInfo{Path: "Show/old.mkv", Size: 50})
+	receiver.Add(&FileInfo{Path: "Show/poster.jpg", Size: 10})
+
+	plan := CompareManifestsWithOptions(sender, receiver, CompareOptions{Rule: "series", NeverDelete: []string{"*.jpg"}})
+
+	if len(plan.FilesToDelete) != 1 || plan.FilesToDelete[0] != "Show/old.mkv" {
+		t.Errorf("Expected only Show/old.mkv to be deleted, got %v", plan.FilesToDelete)
+	}
+	if len(plan.Protected) != 1 || plan.Protected[0].Path != "Show/poster.jpg" || plan.Protected[0].Pattern != "*.jpg" {
+		t.Errorf("Expected Show/poster.jpg to be reported as protected, got %+v", plan.Protected)
+	}
+}
+
+func TestEngine_ProtectedPaths(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+
+	write := func(path, content string, mtime time.Time) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-time.Hour)
+	write(filepath.Join(sourceDir, "keep.txt"), "keep", old)
+	write(filepath.Join(targetDir, "keep.txt"), "keep", old)
+	// Newer and larger on the source: would normally be overwritten
+	write(filepath.Join(sourceDir, "custom.srt"), "new subtitle content", time.Now())
+	write(filepath.Join(targetDir, "custom.srt"), "hand edited", old)
+	// Missing on the source: would normally be deleted
+	write(filepath.Join(targetDir, "notes.txt"), "notes", old)
+
+	engine := NewEngine(SyncConfig{
+		ID:                     "test-protected",
+		SourceDir:              sourceDir,
+		TargetDir:              targetDir,
+		Rule:                   "flat",
+		AutoApproveDeletions:   true,
+		NeverDeletePatterns:    []string{"notes.txt"},
+		NeverOverwritePatterns: []string{"*.srt"},
+	})
+	engine.deletionAllowed = true
+
+	if err := engine.RunSync(nil); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(targetDir, "notes.txt")); err != nil {
+		t.Errorf("Protected file was deleted: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(targetDir, "custom.srt"))
+	if err != nil || string(data) != "hand edited" {
+		t.Errorf("Protected file was overwritten: %q, %v", data, err)
+	}
+}
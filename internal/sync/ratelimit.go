@@ -0,0 +1,104 @@
+package sync
+
+import (
+	stdsync "sync"
+	"time"
+)
+
+// rateLimiter is a token bucket shared by every stream of a transferer, so
+// parallel copies stay within the bandwidth limit as a whole. The rate is
+// read for every chunk, so a new limit applies to running copies at once.
+type rateLimiter struct {
+	mu      stdsync.Mutex
+	rate    int64 // Bytes per second, 0 = unlimited
+	ceiling int64 // Temporary cap on top of rate, 0 = none
+	tokens  float64
+	last    time.Time
+	changed chan struct{} // Closed when the rate changes, wakes sleeping streams
+}
+
+func newRateLimiter(rate int64) *rateLimiter {
+	l := &rateLimiter{changed: make(chan struct{})}
+	l.setRate(rate)
+	return l
+}
+
+// setRate changes the limit; 0 disables it. Streams sleeping off their
+// reservation wake up and reserve again at the new rate.
+func (l *rateLimiter) setRate(rate int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.reset()
+}
+
+// setCeiling caps the limit temporarily without changing it; 0 lifts the cap
+func (l *rateLimiter) setCeiling(ceiling int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ceiling = ceiling
+	l.reset()
+}
+
+// reset empties the bucket and wakes sleeping streams; l.mu must be held
+func (l *rateLimiter) reset() {
+	l.tokens = 0
+	l.last = time.Now()
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// effective is the lower of rate and ceiling, 0 when unlimited; l.mu must be held
+func (l *rateLimiter) effective() int64 {
+	if l.ceiling > 0 && (l.rate <= 0 || l.ceiling < l.rate) {
+		return l.ceiling
+	}
+	return l.rate
+}
+
+// currentRate returns the limit in bytes per second, 0 when unlimited
+func (l *rateLimiter) currentRate() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.effective()
+}
+
+func (l *rateLimiter) limited() bool {
+	return l.currentRate() > 0
+}
+
+// wait blocks until n more bytes may be sent. Callers reserve their bytes
+// up front and sleep off the debt, which keeps concurrent streams fair.
+func (l *rateLimiter) wait(n int) {
+	for {
+		l.mu.Lock()
+		rate := l.effective()
+		if rate <= 0 {
+			l.mu.Unlock()
+			return
+		}
+		now := time.Now()
+		l.tokens += now.Sub(l.last).Seconds() * float64(rate)
+		if burst := float64(rate); l.tokens > burst {
+			l.tokens = burst // At most one second of saved up bandwidth
+		}
+		l.last = now
+		l.tokens -= float64(n)
+		var sleep time.Duration
+		if l.tokens < 0 {
+			sleep = time.Duration(-l.tokens / float64(rate) * float64(time.Second))
+		}
+		changed := l.changed
+		l.mu.Unlock()
+		if sleep <= 0 {
+			return
+		}
+		timer := time.NewTimer(sleep)
+		select {
+		case <-timer.C:
+			return
+		case <-changed:
+			timer.Stop() // The debt was reserved at the old rate
+		}
+	}
+}
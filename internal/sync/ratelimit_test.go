@@ -0,0 +1,62 @@
+package sync
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_SetRateWakesWaiters(t *testing.T) {
+	l := newRateLimiter(1024)
+	l.wait(1024) // Uses up the first second
+
+	done := make(chan struct{})
+	go func() {
+		l.wait(60 * 1024) // A minute of debt at the old rate
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	l.setRate(0)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Lifting the limit should release a sleeping stream")
+	}
+}
+
+func TestRateLimiter_LowerRateAppliesToRunningStream(t *testing.T) {
+	l := newRateLimiter(1 << 30)
+	start := time.Now()
+	l.wait(1 << 20)
+	if time.Since(start) > 100*time.Millisecond {
+		t.Fatal("A chunk well within the limit should not wait")
+	}
+
+	l.setRate(4 << 20) // 4 MiB/s
+	start = time.Now()
+	for i := 0; i < 4; i++ {
+		l.wait(1 << 20)
+	}
+	if elapsed := time.Since(start); elapsed < 700*time.Millisecond {
+		t.Errorf("4 MiB at 4 MiB/s took %v, the new limit was not applied", elapsed)
+	}
+}
+
+func TestRateLimiter_Ceiling(t *testing.T) {
+	l := newRateLimiter(0)
+	l.setCeiling(1000)
+	if l.currentRate() != 1000 {
+		t.Errorf("A ceiling should limit an unlimited transfer, got %d", l.currentRate())
+	}
+	l.setRate(500)
+	if l.currentRate() != 500 {
+		t.Errorf("The lower of limit and ceiling applies, got %d", l.currentRate())
+	}
+	l.setRate(5000)
+	if l.currentRate() != 1000 {
+		t.Errorf("The lower of limit and ceiling applies, got %d", l.currentRate())
+	}
+	l.setCeiling(0)
+	if l.currentRate() != 5000 {
+		t.Errorf("Lifting the ceiling should restore the limit, got %d", l.currentRate())
+	}
+}
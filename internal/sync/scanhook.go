@@ -0,0 +1,139 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// QuarantineDirName holds the files a scan hook rejected, below a local
+// target. The engine never syncs or deletes anything inside it.
+const QuarantineDirName = ".schnorarr-quarantine"
+
+// DefaultScanTimeout bounds a single run of the scan hook
+const DefaultScanTimeout = 10 * time.Minute
+
+// QuarantineError is returned for a transfer the scan hook rejected
+type QuarantineError struct {
+	Reason string
+}
+
+func (q *QuarantineError) Error() string { return "rejected by scan hook: " + q.Reason }
+
+// quarantineEntry remembers a rejected source version so it is not sent
+// again until it changes
+type quarantineEntry struct {
+	Size    int64
+	ModTime time.Time
+	Reason  string
+	At      time.Time
+}
+
+// scanCommand returns the hook command line for path: "{}" in the
+// configured command is replaced by the path, otherwise it is appended
+func scanCommand(command, path string) []string {
+	args := strings.Fields(command)
+	replaced := false
+	for i, a := range args {
+		if strings.Contains(a, "{}") {
+			args[i] = strings.ReplaceAll(a, "{}", path)
+			replaced = true
+		}
+	}
+	if !replaced {
+		args = append(args, path)
+	}
+	return args
+}
+
+// runScanHook runs the scan hook against path. A nonzero exit is a
+// QuarantineError; failing to run the hook at all is a plain error, so the
+// transfer is retried later like any failed transfer.
+func (e *Engine) runScanHook(path string) error {
+	timeout := e.config.ScanTimeout
+	if timeout <= 0 {
+		timeout = DefaultScanTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	args := scanCommand(e.config.ScanCommand, path)
+	out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	var exitErr *exec.ExitError
+	if ctx.Err() != nil || !errors.As(err, &exitErr) {
+		return fmt.Errorf("scan hook failed: %w", err)
+	}
+	reason := fmt.Sprintf("exit status %d", exitErr.ExitCode())
+	if msg := lastLine(string(out)); msg != "" {
+		reason += ": " + msg
+	}
+	return &QuarantineError{Reason: reason}
+}
+
+// lastLine returns the last non-empty line of a command's output, shortened
+func lastLine(out string) string {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	line := strings.TrimSpace(lines[len(lines)-1])
+	if len(line) > 200 {
+		line = line[:200] + "..."
+	}
+	return line
+}
+
+// beforePublish is the transferer hook of engines with a scan command: it
+// scans the complete temporary copy of a local transfer and moves rejected
+// copies into the quarantine instead of publishing them
+func (e *Engine) beforePublish(tmp, dst string) error {
+	err := e.runScanHook(tmp)
+	var q *QuarantineError
+	if !errors.As(err, &q) {
+		return err
+	}
+	rel, relErr := filepath.Rel(e.config.TargetDir, dst)
+	if relErr != nil || strings.HasPrefix(rel, "..") {
+		return err
+	}
+	quarantined := filepath.Join(e.config.TargetDir, QuarantineDirName, rel)
+	if mkErr := os.MkdirAll(filepath.Dir(quarantined), 0755); mkErr == nil {
+		if mvErr := os.Rename(tmp, quarantined); mvErr != nil {
+			e.logger().Warn("Failed to quarantine rejected copy", "path", rel, "error", mvErr)
+		}
+	}
+	return err
+}
+
+// scanRemoteSource runs the scan hook against the source file before it is
+// sent to a remote target, where the engine cannot check the copy itself
+func (e *Engine) scanRemoteSource(rel string) error {
+	if e.config.ScanCommand == "" || !IsRemotePath(e.config.TargetDir) {
+		return nil
+	}
+	return e.runScanHook(filepath.Join(e.config.SourceDir, rel))
+}
+
+// recordQuarantine remembers a rejected source version
+func (e *Engine) recordQuarantine(f *FileInfo, reason string) {
+	e.pausedMu.Lock()
+	defer e.pausedMu.Unlock()
+	if e.quarantined == nil {
+		e.quarantined = make(map[string]quarantineEntry)
+	}
+	e.quarantined[f.Path] = quarantineEntry{Size: f.Size, ModTime: f.ModTime, Reason: reason, At: time.Now()}
+}
+
+// quarantinedVersion returns the entry of a source file that was rejected
+// in this exact version. The caller holds e.pausedMu.
+func (e *Engine) quarantinedVersion(f *FileInfo) (quarantineEntry, bool) {
+	q, ok := e.quarantined[f.Path]
+	if !ok || q.Size != f.Size || !q.ModTime.Equal(f.ModTime) {
+		return quarantineEntry{}, false
+	}
+	return q, true
+}
@@ -0,0 +1,57 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestEngine_ScanHookQuarantines(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as scan hook")
+	}
+	source, target, bin := t.TempDir(), t.TempDir(), t.TempDir()
+	// Rejects files containing "EICAR"
+	hook := filepath.Join(bin, "scan.sh")
+	if err := os.WriteFile(hook, []byte("#!/bin/sh\nif grep -q EICAR \"$1\"; then echo \"$1: Eicar-Signature FOUND\"; exit 1; fi\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(source, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("clean.mkv", "video")
+	write("infected.mkv", "EICAR")
+
+	e := NewEngine(SyncConfig{ID: "scan", SourceDir: source, TargetDir: target, Rule: "flat", ScanCommand: hook + " {}"})
+	if err := e.RunSync(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "clean.mkv")); err != nil {
+		t.Error("Accepted files should be published")
+	}
+	if _, err := os.Stat(filepath.Join(target, "infected.mkv")); !os.IsNotExist(err) {
+		t.Error("Rejected files must not be published")
+	}
+	if _, err := os.Stat(filepath.Join(target, QuarantineDirName, "infected.mkv")); err != nil {
+		t.Error("Rejected copy should be quarantined on the target")
+	}
+
+	plan, err := e.PreviewSync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.FilesToSync) != 0 || len(plan.FilesToDelete) != 0 {
+		t.Errorf("The quarantined version should not be planned again, plan = %+v", plan)
+	}
+	found := false
+	for _, s := range plan.Skipped {
+		found = found || (s.Reason == SkipQuarantined && s.Path == "infected.mkv")
+	}
+	if !found {
+		t.Error("The preview should list the quarantined file as skipped")
+	}
+}
@@ -4,14 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
-	"net/http"
-	"net/url"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
+
+	"schnorarr/internal/agent"
 )
 
 // Scanner handles directory traversal and manifest building
@@ -20,8 +20,32 @@ type Scanner struct {
 	ExcludePatterns []string
 	// IncludePatterns defines glob patterns to include in scanning
 	IncludePatterns []string
+	// ExcludePaths are relative subtree paths that are always skipped (e.g. a nested target)
+	ExcludePaths []string
 	// ComputeHashes enables hash computation (slower but more accurate)
 	ComputeHashes bool
+	// Logger returns the logger for scan messages (default: the scanner module logger)
+	Logger func() *slog.Logger
+
+	// Last manifest received per receiver URL, revalidated with ETag/If-Modified-Since
+	// or patched from the receiver's change journal
+	remoteMu    sync.Mutex
+	remoteCache map[string]*remoteManifest
+}
+
+// remoteManifest is a receiver manifest together with its validators
+type remoteManifest struct {
+	etag         string
+	lastModified string
+	cursor       string // Change journal position the manifest is current for
+	manifest     *CompactManifest
+}
+
+func (s *Scanner) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger()
+	}
+	return scanLog
 }
 
 // NewScanner creates a new scanner with default settings
@@ -42,7 +66,7 @@ func (s *Scanner) ScanLocal(root string) (*Manifest, error) {
 		return s.ScanRemote(root)
 	}
 	manifest := NewManifest(root)
-	log.Printf("[Scanner] Starting parallel scan of %s", root)
+	s.logger().Info("Starting parallel scan", "root", root)
 
 	// Mutex for manifest map writes
 	var mu sync.Mutex
@@ -94,11 +118,15 @@ func (s *Scanner) ScanLocal(root string) (*Manifest, error) {
 						continue
 					}
 
-					if s.shouldExclude(relPath) {
+					if rule, excluded := s.matchExclude(relPath); excluded {
+						if rule != TrashDirName {
+							manifest.addSkipped(filepath.ToSlash(relPath), SkipExcluded, "matches exclude rule "+rule)
+						}
 						continue
 					}
 
 					if !d.IsDir() && !s.shouldInclude(relPath) {
+						manifest.addSkipped(filepath.ToSlash(relPath), SkipNotIncluded, "matches no include pattern")
 						continue
 					}
 
@@ -116,7 +144,7 @@ func (s *Scanner) ScanLocal(root string) (*Manifest, error) {
 
 					if s.ComputeHashes && !d.IsDir() {
 						if err := fileInfo.ComputeHash(fullPath); err != nil {
-							log.Printf("[Scanner] Hash error for %s: %v", fullPath, err)
+							s.logger().Warn("Hash error", "path", fullPath, "error", err)
 						}
 					}
 
@@ -169,39 +197,102 @@ func (s *Scanner) ScanLocal(root string) (*Manifest, error) {
 		return nil, <-errCh
 	}
 
-	log.Printf("[Scanner] Finished scan of %s: found %d items", root, len(manifest.Files)+len(manifest.Dirs))
+	s.logger().Info("Finished scan", "root", root, "items", len(manifest.Files)+len(manifest.Dirs))
+	return manifest, nil
+}
+
+// ScanSubtree scans root/sub and returns its entries with paths relative to
+// root, including sub itself. A missing or excluded sub yields an empty manifest.
+func (s *Scanner) ScanSubtree(root, sub string) (*Manifest, error) {
+	sub = strings.Trim(filepath.ToSlash(filepath.Clean(sub)), "/")
+	manifest := NewManifest(root)
+	if sub == "" || sub == "." {
+		return s.ScanLocal(root)
+	}
+	if s.shouldExclude(sub) {
+		return manifest, nil
+	}
+	fullPath := filepath.Join(root, filepath.FromSlash(sub))
+	info, err := os.Stat(fullPath)
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		if s.shouldInclude(sub) {
+			manifest.Add(&FileInfo{Path: sub, Size: info.Size(), ModTime: info.ModTime()})
+		}
+		return manifest, nil
+	}
+
+	manifest.Add(&FileInfo{Path: sub, ModTime: info.ModTime(), IsDir: true})
+	subManifest, err := s.ScanLocal(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, fi := range subManifest.Files {
+		fi.Path = sub + "/" + fi.Path
+		manifest.Add(fi)
+	}
 	return manifest, nil
 }
 
 // shouldExclude checks if a path matches any exclusion pattern
 func (s *Scanner) shouldExclude(path string) bool {
+	_, excluded := s.matchExclude(path)
+	return excluded
+}
+
+// matchExclude returns the exclusion rule that matches path, if any
+func (s *Scanner) matchExclude(path string) (string, bool) {
+	slashed := filepath.ToSlash(path)
+	for _, excluded := range s.ExcludePaths {
+		if slashed == excluded || strings.HasPrefix(slashed, excluded+"/") {
+			return "path:" + excluded, true
+		}
+	}
 	for _, pattern := range s.ExcludePatterns {
 		if matched, _ := filepath.Match(pattern, filepath.Base(path)); matched {
-			return true
+			return pattern, true
 		}
-		parts := strings.Split(filepath.ToSlash(path), "/")
+		parts := strings.Split(slashed, "/")
 		for _, part := range parts {
 			if matched, _ := filepath.Match(pattern, part); matched {
-				return true
+				return pattern, true
 			}
 		}
 	}
-	return false
+	return "", false
 }
 
 // shouldInclude checks if a path matches any inclusion pattern
 // If IncludePatterns is empty, it returns true (include everything)
 func (s *Scanner) shouldInclude(path string) bool {
-	if len(s.IncludePatterns) == 0 {
-		return true
+	_, included := s.matchInclude(path)
+	return included
+}
+
+// matchInclude returns the inclusion rule that matches path.
+// An empty pattern list includes everything and reports "*".
+func (s *Scanner) matchInclude(path string) (string, bool) {
+	return includeRule(s.IncludePatterns, path)
+}
+
+// includeRule returns the pattern of patterns that matches the base name of
+// path. No patterns include everything and report "*".
+func includeRule(patterns []string, path string) (string, bool) {
+	if len(patterns) == 0 {
+		return "*", true
 	}
 	base := filepath.Base(path)
-	for _, pattern := range s.IncludePatterns {
+	for _, pattern := range patterns {
 		if matched, _ := filepath.Match(pattern, base); matched {
-			return true
+			return pattern, true
 		}
 	}
-	return false
+	return "", false
 }
 
 // ScanRemote scans a remote target via the Agent API
@@ -211,7 +302,7 @@ func (s *Scanner) ScanRemote(uri string) (*Manifest, error) {
 
 	destHost := uriHost
 	if destHost == "" {
-		destHost = os.Getenv("DEST_HOST")
+		destHost = defaultDestHost()
 	}
 
 	if destHost == "" {
@@ -235,39 +326,159 @@ func (s *Scanner) ScanRemote(uri string) (*Manifest, error) {
 		}
 	}
 
-	apiURL := fmt.Sprintf("http://%s:8080/api/manifest?path=%s", destHost, url.QueryEscape(remotePath))
+	return s.fetchRemote(agent.ForHost(destHost), remotePath)
+}
 
-	log.Printf("[Scanner] Requesting remote manifest from API: %s", apiURL)
+// maxDeltaChanges is the number of changed subtrees above which a full manifest is cheaper
+const maxDeltaChanges = 200
+
+// fetchRemote returns the receiver manifest of remotePath. With a cached
+// manifest it asks the change journal what changed since the cached cursor
+// and only fetches those subtrees; otherwise, or when the receiver has no
+// change journal, it downloads the full manifest.
+func (s *Scanner) fetchRemote(client *agent.Client, remotePath string) (*Manifest, error) {
+	key := client.BaseURL() + "?path=" + remotePath
+	s.remoteMu.Lock()
+	cached := s.remoteCache[key]
+	s.remoteMu.Unlock()
+
+	var since string
+	if cached != nil {
+		since = cached.cursor
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	changes, err := client.Changes(ctx, &agent.ChangesRequest{Path: remotePath, Since: since})
+	cancel()
+	if err != nil {
+		s.logger().Debug("Change journal unavailable, fetching full manifest", "error", err)
+		return s.fetchManifest(client, remotePath, "")
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
-	defer cancel()
+	if !changes.Reset && cached != nil && since != "" {
+		if len(changes.Changes) == 0 {
+			s.logger().Info("Remote tree unchanged", "receiver", client.BaseURL(), "path", remotePath, "files", cached.manifest.FileCount())
+			s.storeRemote(key, &remoteManifest{etag: cached.etag, lastModified: cached.lastModified, cursor: changes.Cursor, manifest: cached.manifest})
+			return cached.manifest.Expand()
+		}
+		if len(changes.Changes) <= maxDeltaChanges {
+			manifest, err := s.applyChanges(cached.manifest, client, remotePath, changes.Changes)
+			if err == nil {
+				if compact, err := manifest.Compact(); err == nil {
+					// The ETag no longer matches the patched manifest
+					s.storeRemote(key, &remoteManifest{cursor: changes.Cursor, manifest: compact})
+				}
+				s.logger().Info("Applied remote changes", "receiver", client.BaseURL(), "path", remotePath, "subtrees", len(changes.Changes), "items", len(manifest.Files))
+				return manifest, nil
+			}
+			s.logger().Warn("Failed to apply remote changes, fetching full manifest", "error", err)
+		}
+	}
+	return s.fetchManifest(client, remotePath, changes.Cursor)
+}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+// applyChanges replaces the changed subtrees of a cached manifest with fresh
+// subtree manifests from the receiver
+func (s *Scanner) applyChanges(cached *CompactManifest, client *agent.Client, remotePath string, changes []string) (*Manifest, error) {
+	manifest, err := cached.Expand()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
+	}
+	set := make(map[string]bool, len(changes))
+	for _, c := range changes {
+		set[c] = true
 	}
+	manifest.removeTrees(set)
+	for _, sub := range changes {
+		subManifest, _, err := s.download(client, &agent.ManifestRequest{Path: remotePath, Sub: sub})
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch subtree %s: %w", sub, err)
+		}
+		for _, fi := range subManifest.Files {
+			manifest.Add(fi)
+		}
+	}
+	return manifest, nil
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// storeRemote caches a receiver manifest for revalidation
+func (s *Scanner) storeRemote(key string, entry *remoteManifest) {
+	s.remoteMu.Lock()
+	defer s.remoteMu.Unlock()
+	if s.remoteCache == nil {
+		s.remoteCache = make(map[string]*remoteManifest)
+	}
+	s.remoteCache[key] = entry
+}
+
+// fetchManifest downloads a receiver manifest, streamed as NDJSON (gzip
+// compressed by the HTTP transport). An unchanged tree is answered with
+// Not Modified and served from the cached copy. cursor is the change
+// journal position the manifest is current for.
+func (s *Scanner) fetchManifest(client *agent.Client, remotePath, cursor string) (*Manifest, error) {
+	key := client.BaseURL() + "?path=" + remotePath
+	s.logger().Info("Requesting remote manifest", "receiver", client.BaseURL(), "path", remotePath)
+
+	s.remoteMu.Lock()
+	cached := s.remoteCache[key]
+	s.remoteMu.Unlock()
+	req := &agent.ManifestRequest{Path: remotePath}
+	if cached != nil {
+		req.IfNoneMatch = cached.etag
+		req.IfModifiedSince = cached.lastModified
+	}
+
+	manifest, stream, err := s.download(client, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to contact receiver API at %s: %w", destHost, err)
+		return nil, err
 	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Printf("[Scanner] Error closing response body: %v", err)
+	if manifest == nil {
+		if cached == nil {
+			return nil, fmt.Errorf("receiver returned Not Modified without a cached manifest")
 		}
-	}()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("receiver API returned status %s", resp.Status)
+		s.logger().Info("Remote manifest unchanged", "receiver", client.BaseURL(), "path", remotePath, "files", cached.manifest.FileCount())
+		s.storeRemote(key, &remoteManifest{etag: cached.etag, lastModified: cached.lastModified, cursor: cursor, manifest: cached.manifest})
+		return cached.manifest.Expand()
 	}
 
-	manifest := &Manifest{}
-	if err := json.NewDecoder(resp.Body).Decode(manifest); err != nil {
-		log.Printf("[Scanner] Failed to decode manifest from %s: %v", apiURL, err)
-		return nil, fmt.Errorf("failed to decode manifest JSON: %w", err)
+	if stream.ETag != "" || stream.LastModified != "" || cursor != "" {
+		if compact, err := manifest.Compact(); err == nil {
+			s.storeRemote(key, &remoteManifest{etag: stream.ETag, lastModified: stream.LastModified, cursor: cursor, manifest: compact})
+		}
 	}
 
-	log.Printf("[Scanner] Successfully received %d items from %s", len(manifest.Files)+len(manifest.Dirs), apiURL)
+	s.logger().Info("Received remote manifest", "receiver", client.BaseURL(), "path", remotePath, "items", len(manifest.Files)+len(manifest.Dirs))
 	return manifest, nil
 }
+
+// download performs a manifest call. It returns a nil manifest for Not Modified.
+func (s *Scanner) download(client *agent.Client, req *agent.ManifestRequest) (*Manifest, *agent.ManifestStream, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	stream, err := client.Manifest(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if stream.NotModified {
+		return nil, stream, nil
+	}
+	defer func() {
+		if err := stream.Body.Close(); err != nil {
+			s.logger().Warn("Error closing response body", "error", err)
+		}
+	}()
+
+	var manifest *Manifest
+	if strings.HasPrefix(stream.ContentType, ManifestNDJSON) {
+		manifest, err = ReadManifestNDJSON(stream.Body)
+	} else {
+		// Receivers without streaming support send one JSON document
+		manifest = &Manifest{}
+		err = json.NewDecoder(stream.Body).Decode(manifest)
+	}
+	if err != nil {
+		s.logger().Error("Failed to decode manifest", "path", req.Path, "error", err)
+		return nil, nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	return manifest, stream, nil
+}
@@ -0,0 +1,203 @@
+package sync
+
+import (
+	"fmt"
+	"maps"
+)
+
+// Approval gates: what holds a sync plan until it is approved
+const (
+	GateManual      = "manual"       // Manual sync mode holds every change
+	GateConflicts   = "conflicts"    // Conflicts need a decision unless the override is on
+	GateExternal    = "external"     // Changes made on the target outside of sync need a decision, even with the override
+	GateDeleteLimit = "delete_limit" // Deletions exceed MaxDeletePercent
+	GateDeletions   = "deletions"    // Deletions without auto-approval
+)
+
+// Simulation is the plan of an engine as it would run under another sync
+// mode and approval setting
+type Simulation struct {
+	Mode          string              `json:"mode"`
+	AutoApprove   bool                `json:"auto_approve"`
+	DryRun        bool                `json:"dry_run"`                   // Automatic changes are only logged
+	DryRunActions []string            `json:"dry_run_actions,omitempty"` // Actions only logged while the others run
+	Gate          string              `json:"gate,omitempty"`            // Why the plan needs approval
+	Reason        string              `json:"reason,omitempty"`
+	Automatic     SimulatedChanges    `json:"automatic"`
+	NeedsApproval SimulatedChanges    `json:"needs_approval"`
+	Deferred      []*DeferredDeletion `json:"deferred"`
+}
+
+// SimulatedChanges groups the changes of a plan that share a fate
+type SimulatedChanges struct {
+	Transfers     []string          `json:"transfers"`
+	TransferBytes int64             `json:"transfer_bytes"`
+	Deletes       []string          `json:"deletes"` // Files and directories
+	Renames       map[string]string `json:"renames"`
+	DirsToCreate  []string          `json:"dirs_to_create"`
+	Conflicts     []string          `json:"conflicts"`
+}
+
+// approvalGate returns the gate that holds plan for approval under mode
+// ("dry", "manual" or "auto") and the approval settings, "" when it runs
+// automatically. Callers holding an approval skip the gates.
+func (e *Engine) approvalGate(plan *SyncPlan, target *Manifest, mode string, autoApprove, conflictOverride bool) string {
+	hasChanges := len(plan.FilesToSync) > 0 || len(plan.FilesToDelete) > 0 || len(plan.Renames) > 0 || len(plan.DirsToCreate) > 0
+	hasDeletions := len(plan.FilesToDelete) > 0 || len(plan.DirsToDelete) > 0
+	switch {
+	case hasChanges && mode == "manual":
+		return GateManual
+	case hasExternalConflicts(plan):
+		return GateExternal
+	case len(plan.Conflicts) > 0 && !conflictOverride:
+		return GateConflicts
+	case e.config.MaxDeletePercent > 0 && len(plan.FilesToDelete) > 0 && deletionShareExceeds(plan, target, e.config.MaxDeletePercent):
+		return GateDeleteLimit
+	case hasDeletions && !autoApprove:
+		return GateDeletions
+	}
+	return ""
+}
+
+// deleteLimitMessage explains why plan exceeds MaxDeletePercent
+func (e *Engine) deleteLimitMessage(plan *SyncPlan, target *Manifest) string {
+	filePct, bytePct := deletionShare(plan, target)
+	return fmt.Sprintf("Safety Check: plan would delete %d files (%.1f%% of files, %.1f%% of bytes on target), above the %.1f%% limit. Approval required.",
+		len(plan.FilesToDelete), filePct, bytePct, e.config.MaxDeletePercent)
+}
+
+// splitHeldChanges strips the deletions, renames and conflicts from plan,
+// leaving the additions, and returns the paths that now wait for approval
+func splitHeldChanges(plan *SyncPlan) []string {
+	var held []string
+	conflicting := make(map[string]bool)
+	for _, c := range plan.Conflicts {
+		conflicting[c.Path] = true
+		held = append(held, c.Path)
+	}
+	held = append(held, plan.FilesToDelete...)
+	held = append(held, plan.DirsToDelete...)
+	for oldP := range plan.Renames {
+		held = append(held, oldP)
+	}
+
+	var additions []*FileInfo
+	for _, f := range plan.FilesToSync {
+		if !conflicting[f.Path] {
+			additions = append(additions, f)
+		}
+	}
+	plan.FilesToSync = additions
+	plan.FilesToDelete, plan.DirsToDelete, plan.Conflicts = nil, nil, nil
+	plan.Renames = make(map[string]string)
+	return held
+}
+
+func deletionShareExceeds(plan *SyncPlan, target *Manifest, limit float64) bool {
+	filePct, bytePct := deletionShare(plan, target)
+	return filePct > limit || bytePct > limit
+}
+
+// Simulate computes the current plan and reports which changes would run
+// automatically and which would wait for approval under mode and autoApprove,
+// without touching the engine's state
+func (e *Engine) Simulate(mode string, autoApprove bool) (*Simulation, error) {
+	if mode != "dry" && mode != "manual" && mode != "auto" {
+		return nil, fmt.Errorf("invalid mode %q", mode)
+	}
+	source, target, err := e.scanManifests()
+	if err != nil {
+		return nil, err
+	}
+	plan := e.comparePlan(source, target)
+	e.deferDeletions(plan, false)
+	e.detectExternalChanges(target, plan)
+
+	e.pausedMu.RLock()
+	healthState := e.healthState
+	e.pausedMu.RUnlock()
+	conflictOverride := healthState == nil || healthState.IsOverrideEnabled()
+
+	sim := &Simulation{Mode: mode, AutoApprove: autoApprove, DryRun: mode == "dry", Deferred: plan.Deferred}
+	if sim.Deferred == nil {
+		sim.Deferred = []*DeferredDeletion{}
+	}
+	// Actions kept in dry run are only logged, so they need no approval
+	full := plan
+	if !sim.DryRun {
+		sim.DryRunActions = e.config.DryRunActions
+		plan = e.livePlan(full)
+	}
+	sim.Gate = e.approvalGate(plan, target, mode, autoApprove, conflictOverride)
+	switch sim.Gate {
+	case GateManual:
+		sim.Reason = "manual mode holds changes for approval"
+	case GateConflicts:
+		sim.Reason = fmt.Sprintf("%d conflicts need a decision", len(plan.Conflicts))
+	case GateExternal:
+		sim.Reason = "files changed on the target outside of sync would be overwritten or deleted"
+	case GateDeleteLimit:
+		filePct, bytePct := deletionShare(plan, target)
+		sim.Reason = fmt.Sprintf("deletions cover %.1f%% of files and %.1f%% of bytes, above the %.1f%% limit", filePct, bytePct, e.config.MaxDeletePercent)
+	case GateDeletions:
+		sim.Reason = "deletions are not auto-approved"
+	}
+
+	// A gate holds the whole cycle, unless split approval lets the additions through
+	held := &sim.Automatic
+	if sim.Gate != "" {
+		held = &sim.NeedsApproval
+	}
+	additions := held
+	if sim.Gate != "" && e.config.SplitApproval {
+		additions = &sim.Automatic
+	}
+	conflicting := make(map[string]bool)
+	for _, c := range plan.Conflicts {
+		conflicting[c.Path] = true
+		held.Conflicts = append(held.Conflicts, c.Path)
+	}
+	for _, f := range plan.FilesToSync {
+		changes := additions
+		if conflicting[f.Path] {
+			changes = held
+		}
+		changes.Transfers = append(changes.Transfers, f.Path)
+		changes.TransferBytes += f.Size
+	}
+	additions.DirsToCreate = plan.DirsToCreate
+	held.Deletes = append(append(held.Deletes, plan.FilesToDelete...), plan.DirsToDelete...)
+	held.Renames = plan.Renames
+	if !sim.DryRun {
+		dry := e.withDryRun(&SyncPlan{}, full)
+		for _, f := range dry.FilesToSync {
+			sim.Automatic.Transfers = append(sim.Automatic.Transfers, f.Path)
+			sim.Automatic.TransferBytes += f.Size
+		}
+		sim.Automatic.DirsToCreate = append(sim.Automatic.DirsToCreate, dry.DirsToCreate...)
+		sim.Automatic.Deletes = append(append(sim.Automatic.Deletes, dry.FilesToDelete...), dry.DirsToDelete...)
+		if len(dry.Renames) > 0 {
+			renames := maps.Clone(sim.Automatic.Renames)
+			if renames == nil {
+				renames = make(map[string]string)
+			}
+			maps.Copy(renames, dry.Renames)
+			sim.Automatic.Renames = renames
+		}
+	}
+	sim.Automatic.normalize()
+	sim.NeedsApproval.normalize()
+	return sim, nil
+}
+
+// normalize replaces nil lists so they encode as [] rather than null
+func (c *SimulatedChanges) normalize() {
+	for _, list := range []*[]string{&c.Transfers, &c.Deletes, &c.DirsToCreate, &c.Conflicts} {
+		if *list == nil {
+			*list = []string{}
+		}
+	}
+	if c.Renames == nil {
+		c.Renames = map[string]string{}
+	}
+}
@@ -0,0 +1,77 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEngine_Simulate(t *testing.T) {
+	src := t.TempDir()
+	tgt := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "new.mkv"), []byte("new episode"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tgt, "old.mkv"), []byte("removed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e := NewEngine(SyncConfig{ID: "sim", SourceDir: src, TargetDir: tgt, Rule: "flat"})
+
+	tests := []struct {
+		mode        string
+		autoApprove bool
+		gate        string
+		automatic   int // Transfers + deletes that run without approval
+	}{
+		{mode: "auto", autoApprove: true, automatic: 2},
+		{mode: "auto", gate: GateDeletions},
+		{mode: "manual", autoApprove: true, gate: GateManual},
+		{mode: "dry", autoApprove: true, automatic: 2},
+	}
+	for _, tt := range tests {
+		sim, err := e.Simulate(tt.mode, tt.autoApprove)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.mode, err)
+		}
+		if sim.Gate != tt.gate {
+			t.Errorf("%s/%v: gate = %q, want %q", tt.mode, tt.autoApprove, sim.Gate, tt.gate)
+		}
+		auto := len(sim.Automatic.Transfers) + len(sim.Automatic.Deletes)
+		held := len(sim.NeedsApproval.Transfers) + len(sim.NeedsApproval.Deletes)
+		if auto != tt.automatic || auto+held != 2 {
+			t.Errorf("%s/%v: %d automatic and %d held, want %d automatic of 2", tt.mode, tt.autoApprove, auto, held, tt.automatic)
+		}
+		if sim.DryRun != (tt.mode == "dry") {
+			t.Errorf("%s: dry_run = %v", tt.mode, sim.DryRun)
+		}
+	}
+
+	// Split approval lets the transfer through while the deletion waits
+	e.config.SplitApproval = true
+	sim, err := e.Simulate("auto", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sim.Automatic.Transfers) != 1 || len(sim.NeedsApproval.Deletes) != 1 || len(sim.NeedsApproval.Transfers) != 0 {
+		t.Errorf("Split approval: automatic %+v, needs approval %+v", sim.Automatic, sim.NeedsApproval)
+	}
+	e.config.SplitApproval = false
+
+	// A deletion kept in dry run needs no approval
+	e.config.DryRunActions = []string{ActionDelete}
+	sim, err = e.Simulate("auto", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sim.Gate != "" || len(sim.Automatic.Transfers) != 1 || len(sim.Automatic.Deletes) != 1 || len(sim.DryRunActions) != 1 {
+		t.Errorf("Dry deletions: gate %q, automatic %+v", sim.Gate, sim.Automatic)
+	}
+	e.config.DryRunActions = nil
+
+	if _, err := e.Simulate("yolo", false); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+	if e.IsWaitingForApproval() {
+		t.Error("Simulating must not change the engine's approval state")
+	}
+}
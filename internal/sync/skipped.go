@@ -0,0 +1,149 @@
+package sync
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Reasons a path is left alone by a plan
+const (
+	SkipExcluded       = "excluded"          // Matches an exclude pattern or path
+	SkipNotIncluded    = "not_included"      // Matches no include pattern
+	SkipFailedRecently = "failed_recently"   // Transfer failed within the retry delay
+	SkipReceiverOnly   = "receiver_only"     // Receiver-only path protected by smart deletion
+	SkipNeverDelete    = "never_delete"      // Deletion blocked by a never-delete pattern
+	SkipNeverOverwrite = "never_overwrite"   // Update blocked by a never-overwrite pattern
+	SkipDeferred       = "deletion_deferred" // Deletion waits for the deferral window
+	SkipTargetAdded    = "target_added"      // Added on the target after the last sync, kept
+	SkipQuarantined    = "quarantined"       // Rejected by the scan hook, sent again once changed
+	SkipInvalidMedia   = "invalid_media"     // Failed media validation, sent again once changed
+)
+
+// failedRetryDelay is how long a failed transfer is left out of the plans
+const failedRetryDelay = time.Hour
+
+// maxSkippedPerScan bounds the excluded entries a scan records
+const maxSkippedPerScan = 1000
+
+// SkippedItem is a path the plan does not act on, with a machine-readable
+// reason. Receiver-only protections are grouped: Path is the first path of
+// the group and Count the number of entries it covers.
+type SkippedItem struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+	Detail string `json:"detail,omitempty"`
+	Count  int    `json:"count,omitempty"`
+}
+
+// addSkipped records an entry the scan left out
+func (m *Manifest) addSkipped(p, reason, detail string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.Skipped) < maxSkippedPerScan {
+		m.Skipped = append(m.Skipped, &SkippedItem{Path: p, Reason: reason, Detail: detail})
+	}
+}
+
+// collectSkipped fills plan.Skipped with what the plan leaves alone and why.
+// Recently failed transfers move from the transfers to the skipped items.
+func (e *Engine) collectSkipped(source, target *Manifest, plan *SyncPlan) {
+	var skipped []*SkippedItem
+	source.mu.RLock()
+	skipped = append(skipped, source.Skipped...)
+	source.mu.RUnlock()
+
+	// Like the sync cycle, the preview leaves out transfers that failed recently
+	transfers := plan.FilesToSync[:0]
+	invalid := make(map[string]string)
+	for _, f := range plan.FilesToSync {
+		if v, ok := e.invalidMedia(f); ok {
+			invalid[f.Path] = v.Error
+		}
+	}
+	e.pausedMu.RLock()
+	for _, f := range plan.FilesToSync {
+		if reason, ok := invalid[f.Path]; ok {
+			skipped = append(skipped, &SkippedItem{Path: f.Path, Reason: SkipInvalidMedia, Detail: "failed media validation: " + reason})
+			continue
+		}
+		if q, ok := e.quarantinedVersion(f); ok {
+			skipped = append(skipped, &SkippedItem{Path: f.Path, Reason: SkipQuarantined,
+				Detail: "rejected by the scan hook at " + q.At.Format(time.RFC3339) + ": " + q.Reason})
+			continue
+		}
+		if failed, ok := e.failedFiles[f.Path]; ok && time.Since(failed) < failedRetryDelay {
+			skipped = append(skipped, &SkippedItem{Path: f.Path, Reason: SkipFailedRecently,
+				Detail: "transfer failed at " + failed.Format(time.RFC3339) + ", retried after " + failed.Add(failedRetryDelay).Format(time.RFC3339)})
+			continue
+		}
+		transfers = append(transfers, f)
+	}
+	e.pausedMu.RUnlock()
+	plan.FilesToSync = transfers
+
+	for _, c := range plan.Conflicts {
+		if pattern, ok := matchProtected(e.config.NeverOverwritePatterns, c.Path); ok {
+			skipped = append(skipped, &SkippedItem{Path: c.Path, Reason: SkipNeverOverwrite, Detail: "matches never-overwrite pattern " + pattern})
+		}
+	}
+	for _, v := range plan.Protected {
+		skipped = append(skipped, &SkippedItem{Path: v.Path, Reason: SkipNeverDelete, Detail: "matches never-delete pattern " + v.Pattern})
+	}
+	for _, d := range plan.Deferred {
+		skipped = append(skipped, &SkippedItem{Path: d.Path, Reason: SkipDeferred,
+			Detail: fmt.Sprintf("missing on source since %s (%d scans)", d.FirstMissing.Format(time.RFC3339), d.Scans)})
+	}
+	for _, p := range plan.TargetAdded {
+		skipped = append(skipped, &SkippedItem{Path: p, Reason: SkipTargetAdded, Detail: "added on the target after the last sync"})
+	}
+	skipped = append(skipped, receiverOnly(source, target, e.compareOptions())...)
+
+	sort.SliceStable(skipped, func(i, j int) bool {
+		if skipped[i].Reason != skipped[j].Reason {
+			return skipped[i].Reason < skipped[j].Reason
+		}
+		return skipped[i].Path < skipped[j].Path
+	})
+	plan.Skipped = skipped
+}
+
+// receiverOnly groups the receiver entries smart deletion protects by reason
+func receiverOnly(source, target *Manifest, opts CompareOptions) []*SkippedItem {
+	target.mu.RLock()
+	entries := make([]*FileInfo, 0, len(target.Files))
+	for _, f := range target.Files {
+		entries = append(entries, f)
+	}
+	target.mu.RUnlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+
+	groups := make(map[string]*SkippedItem)
+	var out []*SkippedItem
+	for _, f := range entries {
+		if f.IsDir {
+			if _, ok := source.GetDir(f.Path); ok {
+				continue
+			}
+		} else if _, ok := source.GetFile(f.Path); ok {
+			continue
+		}
+		// Entries below the trash are the engine's own
+		if f.Path == TrashDirName || strings.HasPrefix(f.Path, TrashDirName+"/") {
+			continue
+		}
+		reason := deletionProtection(source, f.Path, f.IsDir, opts)
+		if reason == "" {
+			continue
+		}
+		if g, ok := groups[reason]; ok {
+			g.Count++
+			continue
+		}
+		g := &SkippedItem{Path: f.Path, Reason: SkipReceiverOnly, Detail: reason, Count: 1}
+		groups[reason] = g
+		out = append(out, g)
+	}
+	return out
+}
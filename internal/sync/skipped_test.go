@@ -0,0 +1,61 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEngine_PreviewSkipped(t *testing.T) {
+	source, target := t.TempDir(), t.TempDir()
+	mkfile := func(dir, name string) {
+		t.Helper()
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mkfile(source, "Show/E01.mkv")
+	mkfile(source, "Show/E02.mkv")
+	mkfile(source, "Show/cover.tmp")
+	mkfile(source, "Show/notes.txt")
+	mkfile(target, "Show/E01.mkv")
+	mkfile(target, "Show/old.nfo")
+	mkfile(target, "Archive/a.mkv")
+	mkfile(target, "Archive/b.mkv")
+
+	e := NewEngine(SyncConfig{ID: "skipped", SourceDir: source, TargetDir: target, Rule: "series",
+		ExcludePatterns: []string{"*.tmp"}, IncludePatterns: []string{"*.mkv", "*.nfo"}, NeverDeletePatterns: []string{"*.nfo"}})
+	e.failedFiles["Show/E02.mkv"] = time.Now()
+
+	plan, err := e.PreviewSync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reasons := make(map[string]*SkippedItem)
+	for _, s := range plan.Skipped {
+		reasons[s.Reason+" "+s.Path] = s
+	}
+	for _, want := range []string{
+		SkipExcluded + " Show/cover.tmp",
+		SkipNotIncluded + " Show/notes.txt",
+		SkipFailedRecently + " Show/E02.mkv",
+		SkipNeverDelete + " Show/old.nfo",
+	} {
+		if reasons[want] == nil {
+			t.Errorf("Missing skipped item %q in %v", want, reasons)
+		}
+	}
+	if g := reasons[SkipReceiverOnly+" Archive"]; g == nil || g.Count != 3 {
+		t.Errorf("Receiver-only folder should be grouped with its 3 entries, got %+v", g)
+	}
+	for _, f := range plan.FilesToSync {
+		if f.Path == "Show/E02.mkv" {
+			t.Error("A recently failed transfer should not be in the preview's transfers")
+		}
+	}
+}
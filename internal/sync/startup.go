@@ -0,0 +1,55 @@
+package sync
+
+import stdsync "sync"
+
+// initialScans limits how many engines run their first full scan at the same
+// time; nil means no limit
+var (
+	initialScansMu stdsync.Mutex
+	initialScans   chan struct{}
+)
+
+// SetInitialScanConcurrency limits the initial full scans engines run after
+// Start to n at a time (0 = unlimited). It must be called before engines start.
+func SetInitialScanConcurrency(n int) {
+	initialScansMu.Lock()
+	defer initialScansMu.Unlock()
+	if n <= 0 {
+		initialScans = nil
+		return
+	}
+	initialScans = make(chan struct{}, n)
+}
+
+// runInitialSync runs the first sync after Start once a scan slot is free.
+// The engine reports itself as queued until then.
+func (e *Engine) runInitialSync() {
+	initialScansMu.Lock()
+	slots := initialScans
+	initialScansMu.Unlock()
+	if slots != nil {
+		e.setStartQueued(true)
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+		case <-e.stopCh:
+			e.setStartQueued(false)
+			return
+		}
+		e.setStartQueued(false)
+	}
+	_ = e.RunSync(nil)
+}
+
+func (e *Engine) setStartQueued(queued bool) {
+	e.pausedMu.Lock()
+	e.startQueued = queued
+	e.pausedMu.Unlock()
+}
+
+// IsStartQueued reports whether the engine still waits for its initial scan
+func (e *Engine) IsStartQueued() bool {
+	e.pausedMu.RLock()
+	defer e.pausedMu.RUnlock()
+	return e.startQueued
+}
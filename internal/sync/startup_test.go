@@ -0,0 +1,44 @@
+package sync
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInitialScanConcurrency(t *testing.T) {
+	SetInitialScanConcurrency(1)
+	defer SetInitialScanConcurrency(0)
+	initialScans <- struct{}{} // Another engine holds the only slot
+
+	engine := NewEngine(SyncConfig{ID: "test-startup", SourceDir: t.TempDir(), TargetDir: t.TempDir()})
+	done := make(chan struct{})
+	go func() {
+		engine.runInitialSync()
+		close(done)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !engine.IsStartQueued() {
+		if time.Now().After(deadline) {
+			t.Fatal("Engine never queued for its initial scan")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if status := engine.GetStatus(); !strings.Contains(status, "Queued") {
+		t.Errorf("Expected a queued status, got %q", status)
+	}
+
+	<-initialScans // Free the slot
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Initial sync did not run once the slot was free")
+	}
+	if engine.IsStartQueued() {
+		t.Error("Engine still queued after its initial scan")
+	}
+	if engine.GetLastSyncTime().IsZero() {
+		t.Error("Initial sync did not run")
+	}
+}
@@ -0,0 +1,35 @@
+package sync
+
+import "schnorarr/internal/monitor/database"
+
+// Engine states reported by State, as MQTT, Home Assistant and the chat
+// bots show them
+const (
+	StateIdle            = "idle"
+	StateSyncing         = "syncing"
+	StatePaused          = "paused"
+	StateFailed          = "failed"      // The circuit breaker is open
+	StateMaintenance     = "maintenance" // The engine or all engines are in maintenance
+	StateOffline         = "offline"     // The receiver is offline, changes are queued
+	StateWaitingApproval = "waiting_approval"
+)
+
+// State returns what the engine is doing, the first that applies of paused,
+// failed, maintenance, offline, waiting_approval, syncing and idle
+func (e *Engine) State() string {
+	switch {
+	case e.IsPaused():
+		return StatePaused
+	case e.IsFailed():
+		return StateFailed
+	case database.InMaintenance(e.config.ID):
+		return StateMaintenance
+	case e.IsOffline():
+		return StateOffline
+	case e.IsWaitingForApproval():
+		return StateWaitingApproval
+	case e.IsBusy():
+		return StateSyncing
+	}
+	return StateIdle
+}
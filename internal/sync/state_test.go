@@ -0,0 +1,18 @@
+package sync
+
+import "testing"
+
+func TestEngine_State(t *testing.T) {
+	e := NewEngine(SyncConfig{ID: "test-state", SourceDir: t.TempDir(), TargetDir: t.TempDir()})
+	if got := e.State(); got != StateIdle {
+		t.Errorf("A new engine should be idle, got %s", got)
+	}
+	e.breaker.open = true
+	if got := e.State(); got != StateFailed {
+		t.Errorf("An open breaker should report failed, got %s", got)
+	}
+	e.Pause()
+	if got := e.State(); got != StatePaused {
+		t.Errorf("Paused should win over failed, got %s", got)
+	}
+}
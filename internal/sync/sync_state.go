@@ -1,37 +1,227 @@
 package sync
 
 import (
+	"sort"
 	"sync"
+	"time"
 )
 
+// GlobalLockGroup is the lock group shared by every engine when no
+// dedicated group is configured. It reproduces the original behaviour:
+// scans may run concurrently, transfers are exclusive across all engines.
+const GlobalLockGroup = "global"
+
 var (
-	// globalSyncMu coordinates scanning and transferring across all engines.
-	// We use an RWMutex:
-	// - Multiple engines can scan simultaneously (RLock).
-	// - Only one engine can transfer at a time (Lock).
-	// - No engine can scan while any engine is transferring.
-	// - No engine can transfer while any engine is scanning.
-	globalSyncMu sync.RWMutex
+	// lockGroups coordinates scanning and transferring per lock group.
+	// Each group is a fair reader/writer lock:
+	// - Multiple engines in a group can scan simultaneously (read).
+	// - Only one engine in a group can transfer at a time (write).
+	// - No engine can scan while another engine in the group is transferring.
+	// - Waiters are served in FIFO order so a busy engine cannot starve the rest.
+	lockGroups   = make(map[string]*fairRWLock)
+	lockGroupsMu sync.Mutex
 )
 
+// LockStats describes the contention observed on a single lock group
+type LockStats struct {
+	Group     string        `json:"group"`
+	Readers   int           `json:"readers"`
+	Writer    bool          `json:"writer"`
+	Queued    int           `json:"queued"`
+	Waits     int64         `json:"waits"`
+	TotalWait time.Duration `json:"total_wait"`
+	MaxWait   time.Duration `json:"max_wait"`
+}
+
+// AvgWait returns the mean time spent waiting for the group
+func (s LockStats) AvgWait() time.Duration {
+	if s.Waits == 0 {
+		return 0
+	}
+	return s.TotalWait / time.Duration(s.Waits)
+}
+
+type lockWaiter struct {
+	write bool
+	ready chan struct{}
+}
+
+// fairRWLock is a reader/writer lock that grants access in arrival order.
+// Consecutive readers at the head of the queue are admitted together.
+type fairRWLock struct {
+	mu      sync.Mutex
+	readers int
+	writer  bool
+	queue   []*lockWaiter
+	stats   LockStats
+}
+
+func (l *fairRWLock) acquire(write bool) {
+	start := time.Now()
+	l.mu.Lock()
+	if len(l.queue) == 0 && l.compatible(write) {
+		l.grant(write)
+		l.mu.Unlock()
+		return
+	}
+	w := &lockWaiter{write: write, ready: make(chan struct{})}
+	l.queue = append(l.queue, w)
+	l.mu.Unlock()
+
+	<-w.ready
+
+	waited := time.Since(start)
+	l.mu.Lock()
+	l.stats.Waits++
+	l.stats.TotalWait += waited
+	if waited > l.stats.MaxWait {
+		l.stats.MaxWait = waited
+	}
+	l.mu.Unlock()
+}
+
+func (l *fairRWLock) release(write bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if write {
+		l.writer = false
+	} else if l.readers > 0 {
+		l.readers--
+	}
+	for len(l.queue) > 0 {
+		head := l.queue[0]
+		if !l.compatible(head.write) {
+			break
+		}
+		l.grant(head.write)
+		l.queue = l.queue[1:]
+		close(head.ready)
+		if head.write {
+			break
+		}
+	}
+}
+
+func (l *fairRWLock) compatible(write bool) bool {
+	if write {
+		return !l.writer && l.readers == 0
+	}
+	return !l.writer
+}
+
+func (l *fairRWLock) grant(write bool) {
+	if write {
+		l.writer = true
+	} else {
+		l.readers++
+	}
+}
+
+func (l *fairRWLock) snapshot(group string) LockStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := l.stats
+	s.Group = group
+	s.Readers = l.readers
+	s.Writer = l.writer
+	s.Queued = len(l.queue)
+	return s
+}
+
+func getLockGroup(group string) *fairRWLock {
+	if group == "" {
+		group = GlobalLockGroup
+	}
+	lockGroupsMu.Lock()
+	defer lockGroupsMu.Unlock()
+	l, ok := lockGroups[group]
+	if !ok {
+		l = &fairRWLock{}
+		lockGroups[group] = l
+	}
+	return l
+}
+
+// AcquireScanLockFor acquires the scan lock of the given group.
+// It allows concurrent scans within the group but blocks while the group is transferring.
+func AcquireScanLockFor(group string) {
+	getLockGroup(group).acquire(false)
+}
+
+// ReleaseScanLockFor releases the scan lock of the given group.
+func ReleaseScanLockFor(group string) {
+	getLockGroup(group).release(false)
+}
+
+// AcquireTransferLockFor acquires the exclusive transfer lock of the given group.
+func AcquireTransferLockFor(group string) {
+	getLockGroup(group).acquire(true)
+}
+
+// ReleaseTransferLockFor releases the transfer lock of the given group.
+func ReleaseTransferLockFor(group string) {
+	getLockGroup(group).release(true)
+}
+
 // AcquireScanLock acquires the global lock for scanning.
 // It allows multiple concurrent scans but blocks if a transfer is in progress.
 func AcquireScanLock() {
-	globalSyncMu.RLock()
+	AcquireScanLockFor(GlobalLockGroup)
 }
 
 // ReleaseScanLock releases the global lock for scanning.
 func ReleaseScanLock() {
-	globalSyncMu.RUnlock()
+	ReleaseScanLockFor(GlobalLockGroup)
 }
 
 // AcquireTransferLock acquires the global lock for transferring.
 // It ensures only one transfer happens at a time and blocks if any scan is in progress.
 func AcquireTransferLock() {
-	globalSyncMu.Lock()
+	AcquireTransferLockFor(GlobalLockGroup)
 }
 
 // ReleaseTransferLock releases the global lock for transferring.
 func ReleaseTransferLock() {
-	globalSyncMu.Unlock()
+	ReleaseTransferLockFor(GlobalLockGroup)
+}
+
+// GetLockStats returns wait-time metrics for every lock group that has been used
+func GetLockStats() []LockStats {
+	lockGroupsMu.Lock()
+	names := make([]string, 0, len(lockGroups))
+	locks := make(map[string]*fairRWLock, len(lockGroups))
+	for name, l := range lockGroups {
+		names = append(names, name)
+		locks[name] = l
+	}
+	lockGroupsMu.Unlock()
+
+	sort.Strings(names)
+	stats := make([]LockStats, 0, len(names))
+	for _, name := range names {
+		stats = append(stats, locks[name].snapshot(name))
+	}
+	return stats
+}
+
+// ResolveLockGroup determines the lock group for an engine.
+// Precedence: explicit group, disk tag, then mode. Mode "engine" isolates every
+// engine, mode "disk" groups engines by the device holding sourceDir, and any
+// other mode shares the global group.
+func ResolveLockGroup(mode, engineID, explicit, diskTag, sourceDir string) string {
+	if explicit != "" {
+		return explicit
+	}
+	if diskTag != "" {
+		return "disk-" + diskTag
+	}
+	switch mode {
+	case "engine":
+		return "engine-" + engineID
+	case "disk":
+		if group := DetectDiskGroup(sourceDir); group != "" {
+			return group
+		}
+	}
+	return GlobalLockGroup
 }
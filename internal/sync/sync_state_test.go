@@ -0,0 +1,129 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockGroups_Independent(t *testing.T) {
+	AcquireTransferLockFor("test-indep-a")
+	defer ReleaseTransferLockFor("test-indep-a")
+
+	done := make(chan struct{})
+	go func() {
+		AcquireTransferLockFor("test-indep-b")
+		ReleaseTransferLockFor("test-indep-b")
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Transfer in group b was blocked by group a")
+	}
+}
+
+func TestLockGroups_FIFOPreventsWriterStarvation(t *testing.T) {
+	group := "test-fifo"
+	AcquireScanLockFor(group)
+
+	writerDone := make(chan struct{})
+	go func() {
+		AcquireTransferLockFor(group)
+		close(writerDone)
+	}()
+
+	// Wait until the writer is queued behind the active reader
+	deadline := time.Now().Add(time.Second)
+	for {
+		if s := getLockGroup(group).snapshot(group); s.Queued == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Writer never queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// A late reader must queue behind the writer instead of joining the active reader
+	readerDone := make(chan struct{})
+	go func() {
+		AcquireScanLockFor(group)
+		close(readerDone)
+	}()
+
+	select {
+	case <-readerDone:
+		t.Fatal("Late reader overtook the queued writer")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	ReleaseScanLockFor(group)
+	<-writerDone
+
+	select {
+	case <-readerDone:
+		t.Fatal("Reader admitted while writer holds the lock")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	ReleaseTransferLockFor(group)
+	<-readerDone
+	ReleaseScanLockFor(group)
+
+	var stats LockStats
+	for _, s := range GetLockStats() {
+		if s.Group == group {
+			stats = s
+		}
+	}
+	if stats.Waits != 2 {
+		t.Errorf("Expected 2 recorded waits, got %d", stats.Waits)
+	}
+	if stats.MaxWait <= 0 || stats.AvgWait() <= 0 {
+		t.Errorf("Expected positive wait metrics, got max=%v avg=%v", stats.MaxWait, stats.AvgWait())
+	}
+}
+
+func TestResolveLockGroup(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		mode, id, explicit, disk, expected string
+	}{
+		{"", "1", "", "", GlobalLockGroup},
+		{"global", "1", "", "", GlobalLockGroup},
+		{"engine", "3", "", "", "engine-3"},
+		{"engine", "3", "nas", "", "nas"},
+		{"global", "2", "nas", "hdd1", "nas"},
+		{"global", "2", "", "hdd1", "disk-hdd1"},
+		{"engine", "2", "", "hdd1", "disk-hdd1"},
+	}
+	for _, tt := range tests {
+		if got := ResolveLockGroup(tt.mode, tt.id, tt.explicit, tt.disk, dir); got != tt.expected {
+			t.Errorf("ResolveLockGroup(%q, %q, %q, %q) = %q, want %q", tt.mode, tt.id, tt.explicit, tt.disk, got, tt.expected)
+		}
+	}
+}
+
+func TestResolveLockGroup_DiskModeSharesDevice(t *testing.T) {
+	root := t.TempDir()
+	a := filepath.Join(root, "a")
+	b := filepath.Join(root, "b")
+	for _, d := range []string{a, b} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	groupA := ResolveLockGroup("disk", "1", "", "", a)
+	groupB := ResolveLockGroup("disk", "2", "", "", b)
+	if groupA != groupB {
+		t.Errorf("Engines on the same device should share a group, got %q and %q", groupA, groupB)
+	}
+
+	if got := ResolveLockGroup("disk", "3", "", "", filepath.Join(root, "missing")); got != GlobalLockGroup {
+		t.Errorf("Undetectable source should fall back to %q, got %q", GlobalLockGroup, got)
+	}
+}
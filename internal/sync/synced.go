@@ -0,0 +1,95 @@
+package sync
+
+import (
+	"bytes"
+
+	"schnorarr/internal/monitor/database"
+)
+
+// syncedBase returns the paths last seen in sync on both ends, or nil before
+// the first cycle, in which case plans fall back to the two-way comparison
+func (e *Engine) syncedBase() *Manifest {
+	e.pausedMu.RLock()
+	c := e.lastSynced
+	e.pausedMu.RUnlock()
+	if c == nil {
+		return nil
+	}
+	m, err := c.Expand()
+	if err != nil {
+		e.logger().Warn("Ignoring unreadable synced manifest", "error", err)
+		return nil
+	}
+	return m
+}
+
+// rememberSynced updates the base of the three-way comparison after a cycle:
+// paths on both ends are in sync, and paths of the previous base that are
+// still on one end keep their place until the change that removed them from
+// the other end is synced (or approved). The first base holds the whole
+// target, so receiver-only files from before it are handled as before.
+func (e *Engine) rememberSynced(source, target *Manifest) {
+	e.pausedMu.RLock()
+	last := e.lastSynced
+	e.pausedMu.RUnlock()
+
+	next := NewManifest(target.Root)
+	source.mu.RLock()
+	target.mu.RLock()
+	for p, fi := range source.Files {
+		if tf, ok := target.Files[p]; ok && tf.IsDir == fi.IsDir {
+			next.Add(fi)
+		}
+	}
+	if last == nil {
+		for _, fi := range target.Files {
+			if next.Files[fi.Path] == nil {
+				next.Add(fi)
+			}
+		}
+	} else {
+		err := last.Each(func(fi *FileInfo) error {
+			_, onSource := source.Files[fi.Path]
+			_, onTarget := target.Files[fi.Path]
+			if (onSource || onTarget) && next.Files[fi.Path] == nil {
+				next.Add(fi)
+			}
+			return nil
+		})
+		if err != nil {
+			e.logger().Warn("Ignoring unreadable synced manifest", "error", err)
+		}
+	}
+	target.mu.RUnlock()
+	source.mu.RUnlock()
+
+	c := e.compactManifest(next)
+	if c == nil {
+		return
+	}
+	e.pausedMu.Lock()
+	e.lastSynced = c
+	e.pausedMu.Unlock()
+	if last != nil && bytes.Equal(last.Bytes(), c.Bytes()) {
+		return
+	}
+	if err := database.SaveEngineSyncedManifest(e.config.ID, c.Bytes()); err != nil {
+		e.logger().Warn("Failed to save synced manifest", "error", err)
+	}
+}
+
+// loadSyncedManifest restores the base of the three-way comparison after a restart
+func (e *Engine) loadSyncedManifest() {
+	data, err := database.LoadEngineSyncedManifest(e.config.ID)
+	if err != nil || data == nil {
+		return
+	}
+	c, err := DecodeCompactManifest(data)
+	if err != nil {
+		e.logger().Warn("Ignoring unreadable synced manifest", "error", err)
+		return
+	}
+	e.pausedMu.Lock()
+	e.lastSynced = c
+	e.pausedMu.Unlock()
+}
@@ -2,26 +2,27 @@ package sync
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
-	"net/http"
-	"net/url"
+	"log/slog"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 
+	"schnorarr/internal/agent"
+	"schnorarr/internal/bandwidth"
 	"schnorarr/internal/sync/pool"
 )
 
 const (
 	// ParallelThreshold is the file size (100MB) above which we use multi-streaming
 	ParallelThreshold = 100 * 1024 * 1024
-	// DefaultNumStreams is the number of parallel streams for large files
+	// DefaultNumStreams is the initial number of parallel streams for large files
 	DefaultNumStreams = 4
 	// ChunkSize is the read/write buffer size
 	ChunkSize = 128 * 1024 // 128KB
@@ -33,20 +34,84 @@ type TransferOptions struct {
 	BandwidthLimit int64
 	// OnProgress callback for transfer progress updates
 	OnProgress func(path string, bytesTransferred, totalBytes int64)
+	// OnTransferred is called with the bytes actually moved, excluding data a
+	// resumed transfer found already in place on the target
+	OnTransferred func(bytes int64)
 	// OnComplete callback when transfer completes
 	OnComplete func(path string, size int64, err error)
 	// CheckPaused returns true if the transfer should be interrupted
 	CheckPaused func() bool
+	// IOClass is the ionice scheduling class for transfers (0 = unchanged)
+	IOClass int
+	// IOLevel is the ionice priority level within IOClass (0-7)
+	IOLevel int
+	// Logger returns the logger for transfer messages (default: the transfer module logger)
+	Logger func() *slog.Logger
+	// NumStreams is the initial number of parallel streams for large local copies (0 = DefaultNumStreams)
+	NumStreams int
+	// FixedStreams keeps NumStreams instead of adapting it to the observed throughput
+	FixedStreams bool
+	// ChunkSize is the read/write buffer size of local copies (0 = ChunkSize)
+	ChunkSize int
+	// Compress enables rsync compression for remote transfers
+	Compress bool
+	// MirrorDirs creates directories on remote targets through the receiver
+	// agent; otherwise only the parents of transferred files appear there
+	MirrorDirs bool
+	// BeforePublish checks the complete temporary copy of a local transfer
+	// before it replaces dst. On error the copy is not published; the hook
+	// may move it elsewhere, otherwise it is removed.
+	BeforePublish func(tmp, dst string) error
 }
 
 // Transferer handles file transfer operations
 type Transferer struct {
-	opts TransferOptions
+	opts       TransferOptions
+	limiter    *rateLimiter
+	streamsCap atomic.Int32 // Temporary cap on parallel streams, 0 = none
+	noMkdir    atomic.Bool  // The receiver agent cannot create directories
+	noMeta     atomic.Bool  // The receiver agent cannot set metadata
+	noBatch    atomic.Bool  // The receiver agent cannot run batches
+}
+
+func (t *Transferer) logger() *slog.Logger {
+	if t.opts.Logger != nil {
+		return t.opts.Logger()
+	}
+	return transferLog
+}
+
+func (t *Transferer) transferred(n int64) {
+	if n > 0 && t.opts.OnTransferred != nil {
+		t.opts.OnTransferred(n)
+	}
+}
+
+func (t *Transferer) numStreams() int {
+	if t.opts.NumStreams > 0 {
+		return t.opts.NumStreams
+	}
+	return DefaultNumStreams
+}
+
+// capStreams applies the temporary stream cap to a stream count
+func (t *Transferer) capStreams(streams int) int32 {
+	if limit := int(t.streamsCap.Load()); limit > 0 && streams > limit {
+		return int32(limit)
+	}
+	return int32(streams)
+}
+
+func (t *Transferer) chunkSize() int {
+	if t.opts.ChunkSize > 0 {
+		return t.opts.ChunkSize
+	}
+	return ChunkSize
 }
 
 // NewTransferer creates a new file transferer
 func NewTransferer(opts TransferOptions) *Transferer {
-	return &Transferer{opts: opts}
+	return &Transferer{opts: opts, limiter: newRateLimiter(opts.BandwidthLimit)}
 }
 
 // CopyFile copies a file from src to dst with bandwidth limiting and progress reporting
@@ -57,7 +122,7 @@ func (t *Transferer) CopyFile(src, dst string) error {
 	pool.Acquire()
 	defer pool.Release()
 
-	log.Printf("[Transferer] Copying %s -> %s", src, dst)
+	t.logger().Info("Copying", "src", src, "dst", dst)
 
 	// Check for remote destination
 	if strings.Contains(dst, "::") || strings.HasPrefix(dst, "rsync://") {
@@ -70,7 +135,7 @@ func (t *Transferer) CopyFile(src, dst string) error {
 	}
 	defer func() {
 		if err := srcFile.Close(); err != nil {
-			log.Printf("[Transferer] Error closing source file: %v", err)
+			t.logger().Warn("Error closing source file", "error", err)
 		}
 	}()
 
@@ -89,7 +154,7 @@ func (t *Transferer) CopyFile(src, dst string) error {
 
 	// We only support parallel transfers for new files > threshold
 	// Resumption currently falls back to sequential for simplicity
-	useParallel := totalSize > ParallelThreshold && t.opts.BandwidthLimit == 0
+	useParallel := totalSize > ParallelThreshold
 
 	var bytesTransferred int64
 	var copyErr error
@@ -99,7 +164,7 @@ func (t *Transferer) CopyFile(src, dst string) error {
 	for i := 0; i <= maxRetries; i++ {
 		if i > 0 {
 			sleep := time.Duration(1<<uint(i)) * time.Second
-			log.Printf("[Transferer] Retry %d/%d for %s...", i, maxRetries, src)
+			t.logger().Warn("Retrying copy", "attempt", i, "max_retries", maxRetries, "src", src)
 			time.Sleep(sleep)
 
 			// Reset for retry
@@ -119,19 +184,17 @@ func (t *Transferer) CopyFile(src, dst string) error {
 		if useParallel {
 			bytesTransferred, copyErr = t.copyParallel(filepath.Base(src), srcFile, dstFile, totalSize)
 		} else {
-			// Sequential copy (used for small files or bandwidth limited transfers)
-			if t.opts.BandwidthLimit > 0 {
-				bytesTransferred, copyErr = t.copyWithBandwidthLimit(filepath.Base(src), srcFile, dstFile, totalSize, 0)
-			} else {
+			// Sequential copy for small files
+			withIOPriority(t.opts.IOClass, t.opts.IOLevel, func() {
 				bytesTransferred, copyErr = t.copyWithProgress(filepath.Base(src), srcFile, dstFile, totalSize, 0)
-			}
+			})
 		}
 
 		if err := dstFile.Sync(); err != nil {
-			log.Printf("[Transferer] Warning: failed to sync destination file: %v", err)
+			t.logger().Warn("Failed to sync destination file", "error", err)
 		}
 		if err := dstFile.Close(); err != nil {
-			log.Printf("[Transferer] Error closing destination file: %v", err)
+			t.logger().Warn("Error closing destination file", "error", err)
 		}
 
 		if copyErr == nil {
@@ -141,7 +204,7 @@ func (t *Transferer) CopyFile(src, dst string) error {
 		if copyErr.Error() == "transfer interrupted by pause" {
 			break
 		}
-		log.Printf("[Transferer] Attempt %d failed: %v", i+1, copyErr)
+		t.logger().Warn("Copy attempt failed", "attempt", i+1, "error", copyErr)
 	}
 
 	if copyErr != nil {
@@ -152,14 +215,27 @@ func (t *Transferer) CopyFile(src, dst string) error {
 		return copyErr
 	}
 
+	// Like rsync -a for remote targets, keep the permissions and mtime
+	if err := os.Chmod(tmpDst, srcInfo.Mode().Perm()); err != nil {
+		t.logger().Warn("Failed to set file permissions", "error", err)
+	}
 	if err := os.Chtimes(tmpDst, srcInfo.ModTime(), srcInfo.ModTime()); err != nil {
-		log.Printf("[Transferer] Warning: failed to set file times: %v", err)
+		t.logger().Warn("Failed to set file times", "error", err)
+	}
+	if t.opts.BeforePublish != nil {
+		if err := t.opts.BeforePublish(tmpDst, dst); err != nil {
+			_ = os.Remove(tmpDst)
+			if t.opts.OnComplete != nil {
+				t.opts.OnComplete(filepath.Base(src), bytesTransferred, err)
+			}
+			return err
+		}
 	}
 	if err := os.Rename(tmpDst, dst); err != nil {
 		return fmt.Errorf("failed to rename temp file: %w", err)
 	}
 
-	log.Printf("[Transferer] Successfully copied %s (%d bytes)", src, bytesTransferred)
+	t.logger().Info("Copied", "src", src, "bytes", bytesTransferred)
 	if t.opts.OnComplete != nil {
 		t.opts.OnComplete(filepath.Base(src), bytesTransferred, nil)
 	}
@@ -201,30 +277,49 @@ func (t *Transferer) copyRemote(src, dst string) error {
 	// --protect-args: handles spaces and special chars in paths correctly with daemon protocol
 	// --mkpath: create missing parent directories on destination (rsync 3.2.3+)
 	args := []string{"-a", "--inplace", "--append-verify", "--protect-args", "--mkpath"}
-
-	if t.opts.BandwidthLimit > 0 {
-		kbps := t.opts.BandwidthLimit / 1024
-		if kbps > 0 {
-			args = append(args, fmt.Sprintf("--bwlimit=%d", kbps))
-		}
+	if t.opts.Compress {
+		args = append(args, "-z")
 	}
-	args = append(args, src, dst)
 
 	// Parse destination to get host and remote path for size monitoring
 	destHost, remotePath := ParseRemoteDestination(dst)
-	log.Printf("[Transferer] DEBUG: Parsed destination - host: %q, path: %q", destHost, remotePath)
+	t.logger().Debug("Parsed destination", "host", destHost, "path", remotePath)
+
+	// --append-verify resumes from what is already on the receiver, only growth past it is moved
+	var moved int64
+	if destHost != "" && remotePath != "" {
+		moved = getRemoteFileSize(destHost, remotePath)
+	}
+	account := func(size int64) {
+		if size > moved {
+			t.transferred(size - moved)
+			moved = size
+		}
+	}
 
 	maxRetries := 3
 	stuckThreshold := 60 * time.Second
+	restart := false // The previous attempt was stopped for a new bandwidth limit
 
 	for attempt := 0; attempt <= maxRetries; attempt++ {
-		if attempt > 0 {
-			log.Printf("[Transferer] Retry %d/%d for %s (previous attempt stuck or failed)...", attempt, maxRetries, src)
+		if attempt > 0 && !restart {
+			t.logger().Warn("Retrying rsync, previous attempt stuck or failed", "attempt", attempt, "max_retries", maxRetries, "src", src)
 			time.Sleep(time.Duration(attempt) * 2 * time.Second)
 		}
+		restart = false
+
+		// rsync cannot change --bwlimit while it runs, so a new limit restarts
+		// it; --append-verify resumes where the previous process stopped
+		rate := t.limiter.currentRate()
+		attemptArgs := append([]string(nil), args...)
+		if kbps := rate / 1024; kbps > 0 {
+			attemptArgs = append(attemptArgs, fmt.Sprintf("--bwlimit=%d", kbps))
+		}
+		attemptArgs = append(attemptArgs, src, dst)
 
-		log.Printf("[Transferer] Executing rsync: %s", strings.Join(args, " "))
-		cmd := exec.Command("rsync", args...)
+		name, cmdArgs := ioniceCommand(t.opts.IOClass, t.opts.IOLevel, "rsync", attemptArgs)
+		t.logger().Debug("Executing", "command", filepath.Base(name), "args", strings.Join(cmdArgs, " "))
+		cmd := exec.Command(name, cmdArgs...)
 		cmd.Env = os.Environ()
 		if pass := os.Getenv("RSYNC_PASSWORD"); pass != "" {
 			cmd.Env = append(cmd.Env, "RSYNC_PASSWORD="+pass)
@@ -260,7 +355,7 @@ func (t *Transferer) copyRemote(src, dst string) error {
 				cancel()
 				// Rsync completed
 				if err != nil {
-					log.Printf("[Transferer] Rsync failed for %s: %v", src, err)
+					t.logger().Error("Rsync failed", "src", src, "error", err)
 					if attempt == maxRetries {
 						if t.opts.OnComplete != nil {
 							t.opts.OnComplete(filepath.Base(src), 0, fmt.Errorf("rsync error: %w", err))
@@ -276,8 +371,9 @@ func (t *Transferer) copyRemote(src, dst string) error {
 				if t.opts.OnProgress != nil && totalSize > lastReportedSize {
 					t.opts.OnProgress(src, totalSize, totalSize)
 				}
+				account(totalSize)
 
-				log.Printf("[Transferer] Successfully transferred %s", src)
+				t.logger().Info("Transferred", "src", src)
 				if t.opts.OnComplete != nil {
 					t.opts.OnComplete(filepath.Base(src), totalSize, nil)
 				}
@@ -292,6 +388,7 @@ func (t *Transferer) copyRemote(src, dst string) error {
 						if t.opts.OnProgress != nil {
 							t.opts.OnProgress(src, currentSize, totalSize)
 						}
+						account(currentSize)
 						lastReportedSize = currentSize
 						lastProgressTime = time.Now()
 					}
@@ -302,14 +399,27 @@ func (t *Transferer) copyRemote(src, dst string) error {
 				if lastReportedSize >= totalSize {
 					lastProgressTime = time.Now()
 					if time.Since(lastLogTime) > 10*time.Second {
-						log.Printf("[Transferer] Rsync finalizing %s (data transfer 100%% complete)", filepath.Base(src))
+						t.logger().Info("Rsync finalizing, data transfer complete", "file", filepath.Base(src))
 						lastLogTime = time.Now()
 					}
 				}
 
+				if newRate := t.limiter.currentRate(); newRate != rate && lastReportedSize < totalSize {
+					t.logger().Info("Bandwidth limit changed, restarting rsync", "src", src, "limit", bandwidth.Rate(newRate).String())
+					if cmd.Process != nil {
+						_ = cmd.Process.Kill()
+					}
+					<-done
+					restart = true
+					attempt-- // Not a failure
+					isStuck = true
+					ticker.Stop()
+					continue
+				}
+
 				// Check if stuck
 				if time.Since(lastProgressTime) > stuckThreshold {
-					log.Printf("[Transferer] WARNING: rsync seems stuck for %s (no progress for %v). Killing process...", src, stuckThreshold)
+					t.logger().Warn("Rsync seems stuck, killing process", "src", src, "no_progress_for", stuckThreshold.String())
 					if cmd.Process != nil {
 						_ = cmd.Process.Kill()
 					}
@@ -323,41 +433,17 @@ func (t *Transferer) copyRemote(src, dst string) error {
 	return fmt.Errorf("rsync failed after %d retries", maxRetries)
 }
 
-// getRemoteFileSizeWithContext queries the receiver's /api/stat endpoint with support for cancellation
+// getRemoteFileSizeWithContext asks the receiver agent for a file size with support for cancellation
 func getRemoteFileSizeWithContext(ctx context.Context, host, path string) int64 {
-	apiURL := fmt.Sprintf("http://%s:8080/api/stat?path=%s", host, url.QueryEscape(path))
-
-	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	resp, err := agent.ForHost(host).Stat(ctx, &agent.StatRequest{Path: path})
 	if err != nil {
+		transferLog.Debug("Stat request failed", "error", err)
 		return 0
 	}
-
-	client := &http.Client{
-		Timeout: 5 * time.Second,
-	}
-
-	resp, err := client.Do(req)
-
-	if err != nil {
-		return 0
-	}
-	defer func() {
-		if closeErr := resp.Body.Close(); closeErr != nil {
-			log.Printf("[Transferer] Failed to close response body: %v", closeErr)
-		}
-	}()
-
-	var response struct {
-		Exists bool  `json:"exists"`
-		Size   int64 `json:"size"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return 0
-	}
-
-	if response.Exists {
-		return response.Size
+	if resp.Exists {
+		return resp.Size
 	}
 	return 0
 }
@@ -418,121 +504,13 @@ func ParseRemoteDestination(dst string) (host, remotePath string) {
 	return "", ""
 }
 
-// getRemoteFileSize queries the receiver's /api/stat endpoint for file size
+// getRemoteFileSize asks the receiver agent for a file size
 func getRemoteFileSize(host, path string) int64 {
-	apiURL := fmt.Sprintf("http://%s:8080/api/stat?path=%s", host, url.QueryEscape(path))
-	log.Printf("[Transferer] DEBUG: Querying stat API: %s", apiURL)
-
-	client := &http.Client{
-		Timeout: 5 * time.Second,
-	}
-
-	resp, err := client.Get(apiURL)
-	if err != nil {
-		log.Printf("[Transferer] DEBUG: API request failed: %v", err)
-		return 0
-	}
-	defer func() {
-		if closeErr := resp.Body.Close(); closeErr != nil {
-			log.Printf("[Transferer] Failed to close response body: %v", closeErr)
-		}
-	}()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("[Transferer] DEBUG: API returned status %d", resp.StatusCode)
-		return 0
-	}
-
-	var statResp struct {
-		Size   int64 `json:"size"`
-		Exists bool  `json:"exists"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&statResp); err != nil {
-		log.Printf("[Transferer] DEBUG: Failed to decode response: %v", err)
-		return 0
-	}
-
-	log.Printf("[Transferer] DEBUG: API response - exists: %v, size: %d", statResp.Exists, statResp.Size)
-	return statResp.Size
-}
-
-func (t *Transferer) copyParallel(filename string, srcFile, dstFile *os.File, totalSize int64) (int64, error) {
-	numStreams := DefaultNumStreams
-	chunkSize := (totalSize + int64(numStreams) - 1) / int64(numStreams)
-
-	var wg sync.WaitGroup
-	var errOnce sync.Once
-	var firstErr error
-	var totalWritten int64
-	var mu sync.Mutex
-
-	log.Printf("[Transferer] Starting parallel transfer with %d streams for %s", numStreams, filename)
-
-	for i := 0; i < numStreams; i++ {
-		wg.Add(1)
-		go func(streamID int) {
-			defer wg.Done()
-
-			start := int64(streamID) * chunkSize
-			end := start + chunkSize
-			if end > totalSize {
-				end = totalSize
-			}
-
-			if start >= totalSize {
-				return
-			}
-
-			buf := make([]byte, ChunkSize)
-			offset := start
-
-			for offset < end {
-				if t.opts.CheckPaused != nil && t.opts.CheckPaused() {
-					errOnce.Do(func() { firstErr = fmt.Errorf("transfer interrupted by pause") })
-					return
-				}
-
-				toRead := int64(len(buf))
-				if offset+toRead > end {
-					toRead = end - offset
-				}
-
-				nr, err := srcFile.ReadAt(buf[:toRead], offset)
-				if nr > 0 {
-					nw, ew := dstFile.WriteAt(buf[:nr], offset)
-					if ew != nil {
-						errOnce.Do(func() { firstErr = ew })
-						return
-					}
-
-					mu.Lock()
-					totalWritten += int64(nw)
-					currentTotal := totalWritten
-					mu.Unlock()
-
-					if t.opts.OnProgress != nil {
-						t.opts.OnProgress(filename, currentTotal, totalSize)
-					}
-					offset += int64(nw)
-				}
-				if err != nil && err != io.EOF && offset < end {
-					errOnce.Do(func() { firstErr = err })
-					return
-				}
-				if nr == 0 {
-					break
-				}
-			}
-		}(i)
-	}
-
-	wg.Wait()
-	return totalWritten, firstErr
+	return getRemoteFileSizeWithContext(context.Background(), host, path)
 }
 
 func (t *Transferer) copyWithProgress(filename string, src io.Reader, dst io.Writer, totalSize, offset int64) (int64, error) {
-	buf := make([]byte, ChunkSize)
+	buf := make([]byte, t.chunkSize())
 	var written int64
 	for {
 		if t.opts.CheckPaused != nil && t.opts.CheckPaused() {
@@ -540,9 +518,11 @@ func (t *Transferer) copyWithProgress(filename string, src io.Reader, dst io.Wri
 		}
 		nr, err := src.Read(buf)
 		if nr > 0 {
+			t.limiter.wait(nr)
 			nw, ew := dst.Write(buf[0:nr])
 			if nw > 0 {
 				written += int64(nw)
+				t.transferred(int64(nw))
 				if t.opts.OnProgress != nil {
 					t.opts.OnProgress(filename, offset+written, totalSize)
 				}
@@ -561,56 +541,87 @@ func (t *Transferer) copyWithProgress(filename string, src io.Reader, dst io.Wri
 	return written, nil
 }
 
-func (t *Transferer) copyWithBandwidthLimit(filename string, src io.Reader, dst io.Writer, totalSize, offset int64) (int64, error) {
-	buf := make([]byte, 32*1024)
-	var written int64
-	sleepDuration := time.Duration(float64(len(buf)) / float64(t.opts.BandwidthLimit) * float64(time.Second))
-	lastTime := time.Now()
-
-	for {
-		if t.opts.CheckPaused != nil && t.opts.CheckPaused() {
-			return written, fmt.Errorf("transfer interrupted by pause")
+func (t *Transferer) CreateDir(path string) error {
+	if strings.Contains(path, "::") || strings.HasPrefix(path, "rsync://") {
+		if !t.opts.MirrorDirs {
+			return nil
 		}
-		nr, err := src.Read(buf)
-		if nr > 0 {
-			nw, ew := dst.Write(buf[0:nr])
-			if nw > 0 {
-				written += int64(nw)
-				if t.opts.OnProgress != nil {
-					t.opts.OnProgress(filename, offset+written, totalSize)
-				}
-				elapsed := time.Since(lastTime)
-				if elapsed < sleepDuration {
-					time.Sleep(sleepDuration - elapsed)
-				}
-				lastTime = time.Now()
-			}
-			if ew != nil {
-				return written, ew
+		return t.mkdirRemote(path)
+	}
+	return os.MkdirAll(path, 0755)
+}
+
+// mkdirRemote creates a directory on the receiver. Legacy receivers are left
+// to rsync --mkpath, which only creates the parents of transferred files.
+func (t *Transferer) mkdirRemote(uri string) error {
+	if t.noMkdir.Load() {
+		return nil
+	}
+	destHost, remotePath := ParseRemoteDestination(uri)
+	if destHost == "" {
+		destHost = defaultDestHost()
+	}
+	if destHost == "" || remotePath == "" {
+		return fmt.Errorf("remote mkdir failed: could not determine host and path from URI %q", uri)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, err := agent.ForHost(destHost).Mkdir(ctx, &agent.MkdirRequest{Path: remotePath})
+	if errors.Is(err, agent.ErrUnsupported) {
+		t.noMkdir.Store(true)
+		t.logger().Warn("Receiver cannot create directories, update it to get empty directories too", "host", destHost)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("remote mkdir failed: %w", err)
+	}
+	return nil
+}
+
+// SetMetadata sets the modification time and permission bits of a target
+// file or directory; zero values leave them unchanged. Legacy receivers are
+// skipped with a warning.
+func (t *Transferer) SetMetadata(path string, modTime time.Time, mode os.FileMode) error {
+	if !IsRemotePath(path) {
+		if mode != 0 {
+			if err := os.Chmod(path, mode.Perm()); err != nil {
+				return err
 			}
 		}
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return written, err
+		if modTime.IsZero() {
+			return nil
 		}
+		return os.Chtimes(path, modTime, modTime)
+	}
+	if t.noMeta.Load() {
+		return nil
+	}
+	destHost, remotePath := ParseRemoteDestination(path)
+	if destHost == "" {
+		destHost = defaultDestHost()
+	}
+	if destHost == "" || remotePath == "" {
+		return fmt.Errorf("remote metadata failed: could not determine host and path from URI %q", path)
 	}
-	return written, nil
-}
 
-func (t *Transferer) CreateDir(path string) error {
-	if strings.Contains(path, "::") || strings.HasPrefix(path, "rsync://") {
-		// Rsync creates dirs implicitly during transfer, or we can assume it exists?
-		// Explicit mkdir is hard without ssh.
-		// Usually we can skip mkdir for rsync targets as rsync -r handles it.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, err := agent.ForHost(destHost).SetMetadata(ctx, &agent.MetadataRequest{Path: remotePath, ModTime: modTime, Mode: uint32(mode.Perm())})
+	if errors.Is(err, agent.ErrUnsupported) {
+		t.noMeta.Store(true)
+		t.logger().Warn("Receiver cannot set metadata, update it to keep directory times and permissions", "host", destHost)
 		return nil
 	}
-	return os.MkdirAll(path, 0755)
+	if err != nil {
+		return fmt.Errorf("remote metadata failed: %w", err)
+	}
+	return nil
 }
+
 func (t *Transferer) DeleteFile(path string) error {
 	if strings.Contains(path, "::") || strings.HasPrefix(path, "rsync://") {
-		return t.deleteRemote(path, false)
+		return t.deleteRemote(path, false, false)
 	}
 	err := os.Remove(path)
 	if err != nil && os.IsNotExist(err) {
@@ -621,7 +632,7 @@ func (t *Transferer) DeleteFile(path string) error {
 
 func (t *Transferer) DeleteDir(path string) error {
 	if strings.Contains(path, "::") || strings.HasPrefix(path, "rsync://") {
-		return t.deleteRemote(path, true)
+		return t.deleteRemote(path, true, false)
 	}
 	err := os.RemoveAll(path)
 	if err != nil && os.IsNotExist(err) {
@@ -630,11 +641,68 @@ func (t *Transferer) DeleteDir(path string) error {
 	return err
 }
 
-func (t *Transferer) deleteRemote(uri string, isDir bool) error {
+// RemoveEmptyDir removes a directory only if it is empty and returns
+// agent.ErrNotEmpty otherwise
+func (t *Transferer) RemoveEmptyDir(path string) error {
+	if IsRemotePath(path) {
+		return t.deleteRemote(path, true, true)
+	}
+	entries, err := os.ReadDir(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return agent.ErrNotEmpty
+	}
+	return os.Remove(path)
+}
+
+// ListDir returns the entries of a target directory as they are on disk.
+// Remote targets are listed through the receiver agent.
+func (t *Transferer) ListDir(path string) ([]agent.ListEntry, error) {
+	if !IsRemotePath(path) {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]agent.ListEntry, 0, len(entries))
+		for _, d := range entries {
+			out = append(out, agent.ListEntry{Name: d.Name(), IsDir: d.IsDir()})
+		}
+		return out, nil
+	}
+	destHost, remotePath := ParseRemoteDestination(path)
+	if destHost == "" {
+		destHost = defaultDestHost()
+	}
+	if destHost == "" || remotePath == "" {
+		return nil, fmt.Errorf("remote list failed: could not determine host and path from URI %q", path)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	client := agent.ForHost(destHost)
+	var out []agent.ListEntry
+	for {
+		resp, err := client.List(ctx, &agent.ListRequest{Path: remotePath, Offset: len(out)})
+		if err != nil {
+			return nil, fmt.Errorf("remote list failed: %w", err)
+		}
+		out = append(out, resp.Entries...)
+		if len(resp.Entries) == 0 || len(out) >= resp.Total {
+			return out, nil
+		}
+	}
+}
+
+func (t *Transferer) deleteRemote(uri string, isDir, empty bool) error {
 	destHost, remotePath := ParseRemoteDestination(uri)
 	if destHost == "" {
 		// Fallback to Env if URI parsing fails to get host
-		destHost = os.Getenv("DEST_HOST")
+		destHost = defaultDestHost()
 	}
 
 	if destHost == "" {
@@ -645,29 +713,21 @@ func (t *Transferer) deleteRemote(uri string, isDir bool) error {
 		return fmt.Errorf("remote delete failed: could not determine remote path from URI %q", uri)
 	}
 
-	apiURL := fmt.Sprintf("http://%s:8080/api/delete?path=%s&dir=%v",
-		destHost, url.QueryEscape(remotePath), isDir)
+	t.logger().Info("Requesting remote delete", "host", destHost, "path", remotePath, "dir", isDir, "empty", empty)
 
-	log.Printf("[Transferer] Requesting remote delete: %s", apiURL)
-
-	resp, err := http.Post(apiURL, "application/json", nil)
-	if err != nil {
-		return fmt.Errorf("failed to contact receiver API: %w", err)
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("receiver API returned status %s", resp.Status)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	if _, err := agent.ForHost(destHost).Delete(ctx, &agent.DeleteRequest{Path: remotePath, Dir: isDir, Empty: empty}); err != nil {
+		return fmt.Errorf("remote delete failed: %w", err)
 	}
 
-	log.Printf("[Transferer] Remote delete successful: %s", remotePath)
+	t.logger().Info("Remote delete successful", "path", remotePath)
 	return nil
 }
 
 func (t *Transferer) RenameFile(oldPath, newPath string) error {
-	if strings.Contains(oldPath, "::") || strings.HasPrefix(oldPath, "rsync://") ||
-		strings.Contains(newPath, "::") || strings.HasPrefix(newPath, "rsync://") {
-		return fmt.Errorf("rename not supported for remote targets")
+	if IsRemotePath(oldPath) || IsRemotePath(newPath) {
+		return t.moveRemote(oldPath, newPath)
 	}
 
 	dstDir := filepath.Dir(newPath)
@@ -681,11 +741,110 @@ func (t *Transferer) RenameFile(oldPath, newPath string) error {
 	}
 
 	// Fallback for cross-device rename: Copy then Delete
-	log.Printf("[Transferer] Rename failed (%v), falling back to copy+delete for %s -> %s", err, oldPath, newPath)
+	t.logger().Warn("Rename failed, falling back to copy+delete", "from", oldPath, "to", newPath, "error", err)
 	if err := t.CopyFile(oldPath, newPath); err != nil {
 		return fmt.Errorf("fallback copy failed: %w", err)
 	}
 
 	return os.Remove(oldPath)
 }
-func (t *Transferer) SetBandwidthLimit(limit int64) { t.opts.BandwidthLimit = limit }
+
+// RenameDir moves a target directory with everything in it. Unlike files,
+// directories are not copied when the rename fails.
+func (t *Transferer) RenameDir(oldPath, newPath string) error {
+	if IsRemotePath(oldPath) {
+		return t.moveRemote(oldPath, newPath)
+	}
+	if _, err := os.Lstat(newPath); err == nil {
+		return fmt.Errorf("rename target %s already exists", newPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return err
+	}
+	return os.Rename(oldPath, newPath)
+}
+
+// moveRemote renames a file or directory on the receiver. A missing source
+// fails the move, so the engine transfers the new path instead.
+func (t *Transferer) moveRemote(oldURI, newURI string) error {
+	destHost, oldPath := ParseRemoteDestination(oldURI)
+	newHost, newPath := ParseRemoteDestination(newURI)
+	if destHost == "" {
+		destHost = defaultDestHost()
+	}
+	if newHost == "" {
+		newHost = defaultDestHost()
+	}
+	if destHost == "" || oldPath == "" || newPath == "" || newHost != destHost {
+		return fmt.Errorf("remote move failed: could not determine host and paths from %q and %q", oldURI, newURI)
+	}
+
+	t.logger().Info("Requesting remote move", "host", destHost, "from", oldPath, "to", newPath)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	resp, err := agent.ForHost(destHost).Move(ctx, &agent.MoveRequest{From: oldPath, To: newPath})
+	if err != nil {
+		return fmt.Errorf("remote move failed: %w", err)
+	}
+	if !resp.Moved {
+		return fmt.Errorf("remote move failed: %s not found on the receiver", oldPath)
+	}
+	return nil
+}
+
+// Batch runs ops on the receiver of the remote target as one transaction.
+// Their paths are relative to target. Receivers that cannot batch return
+// agent.ErrUnsupported, once and from then on.
+func (t *Transferer) Batch(target string, ops []agent.Op) (*agent.BatchResponse, error) {
+	if t.noBatch.Load() {
+		return nil, agent.ErrUnsupported
+	}
+	destHost, root := ParseRemoteDestination(target)
+	if destHost == "" {
+		destHost = defaultDestHost()
+	}
+	if destHost == "" {
+		return nil, fmt.Errorf("remote batch failed: could not determine host from URI %q", target)
+	}
+	req := &agent.BatchRequest{Ops: make([]agent.Op, len(ops))}
+	for i, op := range ops {
+		op.Path = path.Join(root, op.Path)
+		if op.To != "" {
+			op.To = path.Join(root, op.To)
+		}
+		req.Ops[i] = op
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	resp, err := agent.ForHost(destHost).Batch(ctx, req)
+	if errors.Is(err, agent.ErrUnsupported) {
+		t.noBatch.Store(true)
+		t.logger().Info("Receiver cannot run batches, sending changes one by one", "host", destHost)
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("remote batch failed: %w", err)
+	}
+	return resp, nil
+}
+
+// SetBandwidthLimit changes the limit in bytes per second (0 = unlimited).
+// Running copies adapt with their next chunk; running rsync processes are
+// restarted with the new limit and resume where they stopped.
+func (t *Transferer) SetBandwidthLimit(limit int64) {
+	t.limiter.setRate(limit)
+}
+
+// SetCaps caps bandwidth (bytes per second) and parallel streams on top of
+// the configured limit and tuning, e.g. while the receiver is busy. Zero
+// lifts a cap; running copies adapt within seconds.
+func (t *Transferer) SetCaps(limit int64, streams int) {
+	t.streamsCap.Store(int32(streams))
+	t.limiter.setCeiling(limit)
+}
+
+// SetTuning changes stream count, chunk size and compression; zero values restore the defaults
+func (t *Transferer) SetTuning(streams, chunkSize int, compress bool) {
+	t.opts.NumStreams, t.opts.ChunkSize, t.opts.Compress = streams, chunkSize, compress
+}
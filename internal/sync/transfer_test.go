@@ -3,9 +3,17 @@ package sync
 import (
 	"bytes"
 	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"schnorarr/internal/agent"
 )
 
 func TestTransferer_CopyParallel(t *testing.T) {
@@ -64,6 +72,153 @@ func TestTransferer_CopyParallel(t *testing.T) {
 	}
 }
 
+func TestTransferer_CopyParallelBandwidthLimit(t *testing.T) {
+	// Adjust the stream count several times during the copy
+	defer func(d time.Duration) { streamAdjustInterval = d }(streamAdjustInterval)
+	streamAdjustInterval = 20 * time.Millisecond
+
+	tmpDir := t.TempDir()
+	size := int64(1024 * 1024)
+	data := make([]byte, int(size))
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	srcPath, dstPath := filepath.Join(tmpDir, "src.dat"), filepath.Join(tmpDir, "dst.dat")
+	if err := os.WriteFile(srcPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	srcFile, err := os.Open(srcPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = srcFile.Close() }()
+	dstFile, err := os.Create(dstPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = dstFile.Close() }()
+
+	// 2MB/s shared by all streams: 1MB takes about half a second
+	tr := NewTransferer(TransferOptions{BandwidthLimit: 2 * 1024 * 1024, ChunkSize: 16 * 1024})
+	start := time.Now()
+	written, err := tr.copyParallel("test.dat", srcFile, dstFile, size)
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("copyParallel failed: %v", err)
+	}
+	if written != size {
+		t.Errorf("Expected %d bytes written, got %d", size, written)
+	}
+	if elapsed < 400*time.Millisecond {
+		t.Errorf("Bandwidth limit exceeded: copied %d bytes in %v", size, elapsed)
+	}
+
+	dstData, err := os.ReadFile(dstPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, dstData) {
+		t.Error("Destination content mismatch")
+	}
+}
+
+func TestStreamTuner(t *testing.T) {
+	tuner := newStreamTuner(4, MaxNumStreams)
+	steps := []struct {
+		rate float64
+		want int
+	}{
+		{100, 5}, // First sample probes upwards
+		{130, 6}, // Improvement keeps the direction
+		{100, 5}, // Drop reverses it
+		{102, 5}, // Within 10% holds
+		{150, 4}, // Improvement continues downwards
+	}
+	for i, step := range steps {
+		if got := tuner.next(step.rate); got != step.want {
+			t.Fatalf("Step %d: expected %d streams, got %d", i, step.want, got)
+		}
+	}
+
+	capped := newStreamTuner(MaxNumStreams, MaxNumStreams)
+	if got := capped.next(100); got != MaxNumStreams {
+		t.Errorf("Stream count must not exceed %d, got %d", MaxNumStreams, got)
+	}
+	if got := capped.next(200); got != MaxNumStreams-1 {
+		t.Errorf("Tuner should turn around at the cap, got %d", got)
+	}
+}
+
 // Todo: Test CopyFile retry logic
 // This requires mocking os.Open/Create or filesystem fault injection, which is complex.
 // For now, we rely on the manual verification of the seek reset fix.
+
+func TestTransferer_OnTransferredCountsMovedBytes(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcPath := filepath.Join(tmpDir, "src.dat")
+	data := make([]byte, 300*1024)
+	if err := os.WriteFile(srcPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var moved int64
+	tr := NewTransferer(TransferOptions{OnTransferred: func(n int64) { moved += n }})
+	if err := tr.CopyFile(srcPath, filepath.Join(tmpDir, "dst.dat")); err != nil {
+		t.Fatal(err)
+	}
+	if moved != int64(len(data)) {
+		t.Errorf("Expected %d bytes transferred, got %d", len(data), moved)
+	}
+}
+
+func TestTransferer_RemoteDirs(t *testing.T) {
+	var created, touched []string
+	mux := http.NewServeMux()
+	mux.HandleFunc(agent.PathPrefix+"Mkdir", func(w http.ResponseWriter, r *http.Request) {
+		var req agent.MkdirRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		created = append(created, req.Path)
+		w.Header().Set(agent.HeaderVersion, "1")
+		_ = json.NewEncoder(w).Encode(agent.MkdirResponse{Created: true})
+	})
+	mux.HandleFunc(agent.PathPrefix+"SetMetadata", func(w http.ResponseWriter, r *http.Request) {
+		var req agent.MetadataRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		touched = append(touched, fmt.Sprintf("%s %o %d", req.Path, req.Mode, req.ModTime.Unix()))
+		w.Header().Set(agent.HeaderVersion, "1")
+		_ = json.NewEncoder(w).Encode(agent.MetadataResponse{Exists: true})
+	})
+	mux.HandleFunc(agent.PathPrefix+"Move", func(w http.ResponseWriter, r *http.Request) {
+		var req agent.MoveRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set(agent.HeaderVersion, "1")
+		_ = json.NewEncoder(w).Encode(agent.MoveResponse{Moved: req.From != "tv/Gone"})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	t.Setenv("RECEIVER_PORT", srv.URL[strings.LastIndex(srv.URL, ":")+1:])
+
+	uri := "localhost::video-sync/tv/Show/Season 2"
+	if err := NewTransferer(TransferOptions{}).CreateDir(uri); err != nil || len(created) != 0 {
+		t.Fatalf("Without MirrorDirs rsync targets get no directories, got %v, %v", created, err)
+	}
+	tr := NewTransferer(TransferOptions{MirrorDirs: true})
+	if err := tr.CreateDir(uri); err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 1 || created[0] != "tv/Show/Season 2" {
+		t.Errorf("Expected the receiver to create tv/Show/Season 2, got %v", created)
+	}
+	if err := tr.SetMetadata(uri, time.Unix(1700000000, 0), os.ModeDir|0750); err != nil {
+		t.Fatal(err)
+	}
+	if len(touched) != 1 || touched[0] != "tv/Show/Season 2 750 1700000000" {
+		t.Errorf("Expected permissions and mtime for tv/Show/Season 2, got %v", touched)
+	}
+	if err := tr.RenameDir("localhost::video-sync/tv/Show", "localhost::video-sync/tv/Renamed"); err != nil {
+		t.Errorf("Expected the receiver to move tv/Show: %v", err)
+	}
+	if err := tr.RenameDir("localhost::video-sync/tv/Gone", "localhost::video-sync/tv/Renamed"); err == nil {
+		t.Error("A move whose source is missing on the receiver must fail")
+	}
+}
@@ -0,0 +1,205 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"schnorarr/internal/monitor/database"
+)
+
+// TrashDirName is the folder in the target that keeps deleted files while
+// their cycle can still be undone. Scans never enter it.
+const TrashDirName = ".schnorarr-trash"
+
+// ErrNothingToUndo is returned by UndoLastCycle when no cycle within the
+// retention window deleted or renamed anything
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// UndoResult reports what undoing a cycle reversed
+type UndoResult struct {
+	Cycle    string   `json:"cycle"`
+	Restored int      `json:"restored"` // Deleted files and directories put back
+	Renamed  int      `json:"renamed"`  // Renames reversed
+	Failed   []string `json:"failed"`
+}
+
+// undoEnabled reports whether deletions and renames are kept for undo;
+// remote targets have no local trash
+func (e *Engine) undoEnabled() bool {
+	return e.config.UndoRetention > 0 && !IsRemotePath(e.config.TargetDir)
+}
+
+// removeFromTarget deletes a file or directory of the target, moving it to
+// the trash of the current cycle when undo is enabled
+func (e *Engine) removeFromTarget(rel string, isDir bool) error {
+	full := filepath.Join(e.config.TargetDir, rel)
+	if !e.undoEnabled() {
+		if isDir {
+			return e.transferer.DeleteDir(full)
+		}
+		return e.transferer.DeleteFile(full)
+	}
+	info, err := os.Lstat(full)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	action := database.UndoAction{EngineID: e.config.ID, CycleID: e.CurrentCycle(), Path: rel}
+	if isDir && info.IsDir() {
+		if entries, err := os.ReadDir(full); err == nil && len(entries) == 0 {
+			if err := os.Remove(full); err != nil {
+				return err
+			}
+			action.Action = database.UndoRmdir
+			e.recordUndo(action)
+			return nil
+		}
+	}
+	trash := filepath.Join(e.config.TargetDir, TrashDirName, action.CycleID, rel)
+	if err := os.MkdirAll(filepath.Dir(trash), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(full, trash); err != nil {
+		return err
+	}
+	action.Action, action.TrashPath = database.UndoDelete, trash
+	e.recordUndo(action)
+	return nil
+}
+
+// removeEmptyDirFromTarget removes a target directory only if it is empty,
+// returning agent.ErrNotEmpty otherwise
+func (e *Engine) removeEmptyDirFromTarget(rel string) error {
+	full := filepath.Join(e.config.TargetDir, rel)
+	if !e.undoEnabled() {
+		return e.transferer.RemoveEmptyDir(full)
+	}
+	if _, err := os.Lstat(full); os.IsNotExist(err) {
+		return nil
+	}
+	if err := e.transferer.RemoveEmptyDir(full); err != nil {
+		return err
+	}
+	e.recordUndo(database.UndoAction{EngineID: e.config.ID, CycleID: e.CurrentCycle(), Path: rel, Action: database.UndoRmdir})
+	return nil
+}
+
+// recordRename keeps a rename of the current cycle for undo
+func (e *Engine) recordRename(oldPath, newPath string) {
+	if e.undoEnabled() {
+		e.recordUndo(database.UndoAction{EngineID: e.config.ID, CycleID: e.CurrentCycle(), Action: database.UndoRename, Path: oldPath, NewPath: newPath})
+	}
+}
+
+func (e *Engine) recordUndo(a database.UndoAction) {
+	if err := database.RecordUndoAction(a); err != nil {
+		e.logger().Warn("Failed to record undo information", "path", a.Path, "error", err)
+	}
+}
+
+// purgeTrash permanently removes what cycles older than the retention window deleted
+func (e *Engine) purgeTrash() {
+	if e.config.UndoRetention <= 0 || IsRemotePath(e.config.TargetDir) {
+		return
+	}
+	cycles, err := database.ExpiredUndoCycles(e.config.ID, time.Now().Add(-e.config.UndoRetention))
+	if err != nil {
+		e.logger().Warn("Failed to list expired undo records", "error", err)
+		return
+	}
+	for _, cycle := range cycles {
+		if cycle != "" {
+			if err := os.RemoveAll(filepath.Join(e.config.TargetDir, TrashDirName, cycle)); err != nil {
+				e.logger().Warn("Failed to empty trash", "cycle", cycle, "error", err)
+				continue
+			}
+		}
+		_ = database.DeleteUndoActions(e.config.ID, cycle)
+	}
+}
+
+// UndoLastCycle reverses the deletions and renames of the most recent cycle
+// within the retention window, newest change first, and records each
+// reversal to history under the cycle "undo-<cycle>"
+func (e *Engine) UndoLastCycle() (*UndoResult, error) {
+	if e.config.UndoRetention <= 0 {
+		return nil, fmt.Errorf("undo is disabled, set UNDO_RETENTION_HOURS")
+	}
+	if !e.syncMu.TryLock() {
+		return nil, fmt.Errorf("engine is syncing, try again when it is idle")
+	}
+	defer e.syncMu.Unlock()
+
+	cycle, actions, err := database.GetLastUndoCycle(e.config.ID, time.Now().Add(-e.config.UndoRetention))
+	if err != nil {
+		return nil, err
+	}
+	if cycle == "" {
+		return nil, ErrNothingToUndo
+	}
+
+	e.forgetTarget()
+	e.forgetTargetCache()
+	res := &UndoResult{Cycle: cycle, Failed: []string{}}
+	timestamp := database.Timestamp(time.Now())
+	report := func(action, path string, size int64) {
+		if e.config.OnSyncEvent != nil {
+			e.config.OnSyncEvent(timestamp, action, path, size, "undo-"+cycle)
+		}
+	}
+	for i := len(actions) - 1; i >= 0; i-- {
+		a := actions[i]
+		full := filepath.Join(e.config.TargetDir, a.Path)
+		var err error
+		switch a.Action {
+		case database.UndoRmdir:
+			if err = os.MkdirAll(full, 0755); err == nil {
+				res.Restored++
+				report("Restored", a.Path, 0)
+			}
+		case database.UndoDelete:
+			if _, statErr := os.Lstat(full); statErr == nil {
+				err = fmt.Errorf("%s exists again", a.Path)
+				break
+			}
+			var size int64
+			if info, statErr := os.Lstat(a.TrashPath); statErr == nil && !info.IsDir() {
+				size = info.Size()
+			}
+			if err = os.MkdirAll(filepath.Dir(full), 0755); err == nil {
+				err = os.Rename(a.TrashPath, full)
+			}
+			if err == nil {
+				res.Restored++
+				report("Restored", a.Path, size)
+			}
+		case database.UndoRename:
+			newFull := filepath.Join(e.config.TargetDir, a.NewPath)
+			if err = os.MkdirAll(filepath.Dir(full), 0755); err == nil {
+				err = os.Rename(newFull, full)
+			}
+			if err == nil {
+				res.Renamed++
+				report("Undo-Renamed", fmt.Sprintf("%s -> %s", a.NewPath, a.Path), 0)
+			}
+		}
+		if err != nil {
+			e.logger().Warn("Failed to undo", "action", a.Action, "path", a.Path, "error", err)
+			res.Failed = append(res.Failed, a.Path)
+			continue
+		}
+		_ = database.DeleteUndoAction(a.ID)
+	}
+
+	// Failed reversals keep their trash copies and can be retried
+	if len(res.Failed) == 0 {
+		_ = os.RemoveAll(filepath.Join(e.config.TargetDir, TrashDirName, cycle))
+	}
+	e.logger().Info("Cycle undone", "undone_cycle", cycle, "restored", res.Restored, "renamed", res.Renamed, "failed", len(res.Failed))
+	return res, nil
+}
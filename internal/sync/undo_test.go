@@ -0,0 +1,72 @@
+package sync
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"schnorarr/internal/monitor/database"
+)
+
+func TestEngine_UndoLastCycle(t *testing.T) {
+	database.DBPath = filepath.Join(t.TempDir(), "undo.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = database.DB.Close(); database.DB = nil }()
+
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	for _, root := range []string{sourceDir, targetDir} {
+		if err := os.MkdirAll(filepath.Join(root, "Show"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, "Show", "keep.mkv"), []byte("keep"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deleted := filepath.Join(targetDir, "Show", "old.mkv")
+	if err := os.WriteFile(deleted, []byte("gone from source"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []string
+	engine := NewEngine(SyncConfig{
+		ID: "test-undo", SourceDir: sourceDir, TargetDir: targetDir, Rule: "flat",
+		AutoApproveDeletions: true, UndoRetention: time.Hour,
+		OnSyncEvent: func(_, action, path string, _ int64, _ string) { events = append(events, action+" "+path) },
+	})
+	if err := engine.RunSync(nil); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	if _, err := os.Stat(deleted); !os.IsNotExist(err) {
+		t.Fatal("old.mkv should have been deleted")
+	}
+
+	// The trash is invisible to the next cycle
+	if plan, err := engine.PreviewSync(); err != nil || len(plan.FilesToDelete)+len(plan.DirsToDelete) != 0 {
+		t.Fatalf("Trash should not show up in the plan: %+v, %v", plan, err)
+	}
+
+	res, err := engine.UndoLastCycle()
+	if err != nil {
+		t.Fatalf("UndoLastCycle failed: %v", err)
+	}
+	if res.Restored != 1 || len(res.Failed) != 0 {
+		t.Errorf("Expected 1 restored file, got %+v", res)
+	}
+	if data, err := os.ReadFile(deleted); err != nil || string(data) != "gone from source" {
+		t.Fatalf("old.mkv should be back: %v", err)
+	}
+	if last := events[len(events)-1]; last != "Restored Show/old.mkv" {
+		t.Errorf("Expected a compensating history event, got %q", last)
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, TrashDirName, res.Cycle)); !os.IsNotExist(err) {
+		t.Error("Trash of the undone cycle should be emptied")
+	}
+	if _, err := engine.UndoLastCycle(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("Expected ErrNothingToUndo, got %v", err)
+	}
+}
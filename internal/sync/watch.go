@@ -0,0 +1,161 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultWatchFallbackInterval is how often subtrees without inotify watches are polled
+const DefaultWatchFallbackInterval = 2 * time.Minute
+
+// inotifyLimitPath exposes the kernel's per-user watch limit on Linux
+const inotifyLimitPath = "/proc/sys/fs/inotify/max_user_watches"
+
+// WatchStats describes the state of an engine's filesystem watcher
+type WatchStats struct {
+	EngineID       string   `json:"engine_id"`
+	Watches        int      `json:"watches"`
+	LimitReached   bool     `json:"limit_reached"`
+	PolledSubtrees []string `json:"polled_subtrees"`
+	MaxUserWatches int      `json:"max_user_watches"`
+}
+
+// isWatchLimitError reports whether err means the inotify watch limit is exhausted
+func isWatchLimitError(err error) bool {
+	return errors.Is(err, syscall.ENOSPC)
+}
+
+// readMaxUserWatches returns the kernel watch limit, or 0 if unknown
+func readMaxUserWatches() int {
+	data, err := os.ReadFile(inotifyLimitPath)
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return n
+}
+
+func (e *Engine) addWatchRecursive(path string) error {
+	return filepath.Walk(path, func(walkPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			relPath, _ := filepath.Rel(e.config.SourceDir, walkPath)
+			if e.scanner.shouldExclude(relPath) {
+				return filepath.SkipDir
+			}
+			if err := e.watcher.Add(walkPath); err != nil {
+				if isWatchLimitError(err) {
+					e.addPollFallback(walkPath)
+					return filepath.SkipDir
+				}
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// addPollFallback marks a subtree that could not be watched so it is polled instead.
+// The first occurrence logs actionable guidance and starts the fallback poller.
+func (e *Engine) addPollFallback(dir string) {
+	e.pausedMu.Lock()
+	for _, existing := range e.pollSubtrees {
+		if dir == existing || strings.HasPrefix(dir, existing+string(filepath.Separator)) {
+			e.pausedMu.Unlock()
+			return
+		}
+	}
+	e.pollSubtrees = append(e.pollSubtrees, dir)
+	first := !e.watchLimitHit
+	e.watchLimitHit = true
+	e.pausedMu.Unlock()
+
+	if !first {
+		return
+	}
+	limit := readMaxUserWatches()
+	msg := fmt.Sprintf("inotify watch limit reached (fs.inotify.max_user_watches=%d). "+
+		"Falling back to polling every %v for unwatched folders. Raise the limit on the host, e.g. "+
+		"'sysctl -w fs.inotify.max_user_watches=524288', and restart to restore instant change detection.",
+		limit, e.watchFallbackInterval())
+	e.logger().Warn(msg)
+	e.reportError(msg)
+	go e.fallbackPollLoop()
+}
+
+func (e *Engine) watchFallbackInterval() time.Duration {
+	if e.config.WatchFallbackInterval > 0 {
+		return e.config.WatchFallbackInterval
+	}
+	return DefaultWatchFallbackInterval
+}
+
+// fallbackPollLoop periodically fingerprints the unwatched subtrees and
+// triggers a sync whenever one of them changes.
+func (e *Engine) fallbackPollLoop() {
+	ticker := time.NewTicker(e.watchFallbackInterval())
+	defer ticker.Stop()
+	last := e.subtreeFingerprint()
+	for {
+		select {
+		case <-e.stopCh:
+			return
+		case <-ticker.C:
+			if e.IsPaused() {
+				continue
+			}
+			current := e.subtreeFingerprint()
+			if current != last {
+				last = current
+				e.logger().Info("Change detected in polled subtree, triggering sync")
+				go func() { _ = e.RunSync(nil) }()
+			}
+		}
+	}
+}
+
+// subtreeFingerprint summarizes entry count, total size and newest mtime of all polled subtrees
+func (e *Engine) subtreeFingerprint() string {
+	e.pausedMu.RLock()
+	roots := make([]string, len(e.pollSubtrees))
+	copy(roots, e.pollSubtrees)
+	e.pausedMu.RUnlock()
+
+	var count, size, newest int64
+	for _, root := range roots {
+		_ = filepath.Walk(root, func(_ string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			count++
+			size += info.Size()
+			if mt := info.ModTime().UnixNano(); mt > newest {
+				newest = mt
+			}
+			return nil
+		})
+	}
+	return fmt.Sprintf("%d:%d:%d", count, size, newest)
+}
+
+// GetWatchStats returns the watch count and fallback state for diagnostics
+func (e *Engine) GetWatchStats() WatchStats {
+	stats := WatchStats{EngineID: e.config.ID, MaxUserWatches: readMaxUserWatches()}
+	if e.watcher != nil {
+		stats.Watches = len(e.watcher.WatchList())
+	}
+	e.pausedMu.RLock()
+	stats.LimitReached = e.watchLimitHit
+	stats.PolledSubtrees = make([]string, len(e.pollSubtrees))
+	copy(stats.PolledSubtrees, e.pollSubtrees)
+	e.pausedMu.RUnlock()
+	return stats
+}
@@ -0,0 +1,59 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsWatchLimitError(t *testing.T) {
+	wrapped := fmt.Errorf("add watch: %w", syscall.ENOSPC)
+	if !isWatchLimitError(wrapped) {
+		t.Error("Expected wrapped ENOSPC to be detected as watch limit error")
+	}
+	if isWatchLimitError(os.ErrNotExist) {
+		t.Error("Unexpected watch limit detection for ErrNotExist")
+	}
+}
+
+func TestEngine_PollFallback(t *testing.T) {
+	src := t.TempDir()
+	sub := filepath.Join(src, "Shows")
+	if err := os.MkdirAll(filepath.Join(sub, "Season 1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var reported []string
+	e := NewEngine(SyncConfig{
+		ID: "watch-fallback", SourceDir: src, TargetDir: t.TempDir(),
+		WatchFallbackInterval: time.Hour,
+		OnError:               func(msg string) { reported = append(reported, msg) },
+	})
+	defer e.Stop()
+
+	e.addPollFallback(sub)
+	e.addPollFallback(filepath.Join(sub, "Season 1")) // Nested, already covered
+	e.addPollFallback(sub)                            // Duplicate
+
+	stats := e.GetWatchStats()
+	if !stats.LimitReached {
+		t.Error("Expected LimitReached after fallback")
+	}
+	if len(stats.PolledSubtrees) != 1 || stats.PolledSubtrees[0] != sub {
+		t.Errorf("Expected only %s to be polled, got %v", sub, stats.PolledSubtrees)
+	}
+	if len(reported) != 1 {
+		t.Errorf("Expected guidance to be reported once, got %d", len(reported))
+	}
+
+	before := e.subtreeFingerprint()
+	if err := os.WriteFile(filepath.Join(sub, "Season 1", "ep1.mkv"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if after := e.subtreeFingerprint(); after == before {
+		t.Error("Fingerprint did not change after adding a file to a polled subtree")
+	}
+}
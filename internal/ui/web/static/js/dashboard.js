@@ -3,6 +3,8 @@ let currentLogLevel = 'all';
 let logScrollLocked = false;
 let currentPreviewId = null;
 let lastTrafficTotal = 0;
+// Path prefix behind a reverse proxy (BASE_PATH), "" at the root
+const BASE_PATH = document.body.dataset.basePath || '';
 
 function escapeHtml(text) {
     if (!text) return text;
@@ -84,7 +86,7 @@ function addLogLine(data) {
         // Let's stick to: Escape whole string first.
 
         if (content.includes('Scanner')) cls = 'log-comp-scanner';
-        else if (content.includes('Transferer')) cls = 'log-comp-transferer';
+        else if (content.includes('Transfer')) cls = 'log-comp-transferer';
         else if (content.includes('Database')) cls = 'log-comp-database';
         else if (content.includes('Health')) cls = 'log-comp-health';
         else if (content.includes('SYSTEM')) cls = 'log-comp-error';
@@ -331,70 +333,224 @@ function updateProgress(data) {
         const totalEl = document.getElementById('stat-total');
         if (totalEl) totalEl.innerText = data.traffic_total;
     }
+    if (Object.prototype.hasOwnProperty.call(data, 'quota')) {
+        const quotaEl = document.getElementById('stat-quota');
+        const quotaVal = document.getElementById('stat-quota-val');
+        if (quotaEl && quotaVal) {
+            quotaEl.style.display = data.quota && data.quota.limit > 0 ? 'block' : 'none';
+            if (data.quota && data.quota.limit > 0) {
+                quotaVal.innerText = `${data.quota.label} (${Math.round(data.quota.percent)}%)`;
+                quotaVal.style.color = data.quota.exhausted ? 'var(--accent-error)' : (data.quota.percent >= 80 ? 'var(--accent-warning)' : '');
+            }
+        }
+    }
+    if (Object.prototype.hasOwnProperty.call(data, 'cost_today')) {
+        const costEl = document.getElementById('stat-cost');
+        const costVal = document.getElementById('stat-cost-val');
+        const ceilingEl = document.getElementById('stat-cost-ceiling');
+        if (costEl && costVal) {
+            costEl.style.display = data.cost_today ? 'block' : 'none';
+            costVal.innerText = data.cost_today;
+        }
+        if (ceilingEl) {
+            const c = data.cost_ceiling;
+            ceilingEl.innerText = c ? ` · month ${c.label}` : '';
+            ceilingEl.style.color = c && c.exhausted ? 'var(--accent-error)' : (c && c.percent >= 80 ? 'var(--accent-warning)' : '');
+        }
+    }
     if (data.latency) { updateLatencySparkline(data.latency); }
+    const latencySl = document.getElementById('latency-sparkline');
+    if (latencySl && data.latency_stats) {
+        const s = data.latency_stats;
+        latencySl.title = `Last ${s.samples} checks: min ${s.min_ms}ms · avg ${s.avg_ms}ms · p95 ${s.p95_ms}ms · loss ${s.loss.toFixed(1)}%`;
+    }
     if (data.hasOwnProperty('receiver_healthy')) {
         const receiverBadge = document.getElementById('receiver-badge');
         if (receiverBadge) {
-            receiverBadge.className = `status-pill ${data.receiver_healthy ? 'pill-active' : 'pill-critical'}`;
-            receiverBadge.innerText = data.receiver_healthy ? 'ONLINE' : 'OFFLINE';
+            const busy = data.receiver_healthy && data.receiver_busy;
+            receiverBadge.className = `status-pill ${busy ? 'pill-paused' : (data.receiver_healthy ? 'pill-active' : 'pill-critical')}`;
+            receiverBadge.innerText = busy ? 'BUSY' : (data.receiver_healthy ? 'ONLINE' : 'OFFLINE');
             let title = `Ver: ${data.receiver_version || 'N/A'} | Up: ${data.receiver_uptime || 'N/A'}`;
             if (data.receiver_msg) title += `\nStatus: ${data.receiver_msg}`;
+            if (data.receiver_error) title += `\n${data.receiver_error.problem}: ${data.receiver_error.hint}`;
+            const load = data.receiver_load;
+            if (load) {
+                title += `\nLoad: ${load.load1.toFixed(2)} on ${load.num_cpu} CPUs`;
+                if (load.io_pressure >= 0) title += ` | IO pressure: ${load.io_pressure.toFixed(0)}%`;
+            }
+            if (busy) title += `\nTransfers paced: ${data.receiver_busy}`;
             receiverBadge.title = title;
         }
+        const hostEl = document.getElementById('receiver-host');
+        if (hostEl) hostEl.innerText = data.receiver_host || '';
     }
-    if (data.engines) {
-        data.engines.forEach(eng => {
-            const container = document.getElementById(`engine-progress-container-${eng.id}`);
-            const bar = document.getElementById(`engine-progress-bar-${eng.id}`);
-            const fileText = document.getElementById(`engine-current-file-${eng.id}`);
-            const speedText = document.getElementById(`engine-current-speed-${eng.id}`);
-            const statusPill = document.getElementById(`engine-status-${eng.id}`);
-            const radar = document.getElementById(`engine-radar-${eng.id}`);
-            const remoteBadge = document.getElementById(`engine-remote-${eng.id}`);
-            const todayText = document.getElementById(`engine-today-${eng.id}`);
-            const totalText = document.getElementById(`engine-total-${eng.id}`);
-            const elapsedEl = document.getElementById(`engine-elapsed-${eng.id}`);
-            const avgEl = document.getElementById(`engine-avg-${eng.id}`);
-            const lastSyncEl = document.getElementById(`engine-lastsync-${eng.id}`);
-
-            if (lastSyncEl && eng.last_sync) {
-                lastSyncEl.setAttribute('data-time', eng.last_sync);
-                lastSyncEl.innerText = timeAgo(eng.last_sync);
-            }
-            if (todayText) todayText.innerText = eng.today;
-            if (totalText) totalText.innerText = eng.total;
-            if (radar) radar.style.display = eng.is_scanning ? 'flex' : 'none';
-            if (remoteBadge) remoteBadge.style.display = eng.is_remote_scan ? 'block' : 'none';
-            if (statusPill) {
-                if (eng.is_waiting_approval) {
-                    statusPill.innerText = 'WAITING APPROVAL';
-                    statusPill.className = 'status-pill pill-waiting';
-                }
-                else if (eng.is_paused) {
-                    statusPill.innerText = 'PAUSED';
-                    statusPill.className = 'status-pill pill-paused';
-                }
-                else if (eng.is_active) {
-                    statusPill.innerText = 'SYNCING';
-                    statusPill.className = 'status-pill pill-syncing';
-                }
-                else {
-                    statusPill.innerText = 'ACTIVE';
-                    statusPill.className = 'status-pill pill-active';
-                }
-            }
-            if (container && eng.is_active) {
-                container.style.display = 'block';
-                if (bar) bar.style.width = eng.percent + '%';
-                if (fileText) fileText.innerText = eng.file || '...';
-                if (speedText) speedText.innerText = `${eng.speed} (${eng.eta})`;
-                if (elapsedEl) elapsedEl.innerText = `Elapsed: ${eng.elapsed}`;
-                if (avgEl) avgEl.innerText = `Avg: ${eng.avg_speed}`;
-                const sl = document.getElementById(`sparkline-${eng.id}`);
-                if (sl && eng.speed_history) { sl.setAttribute('data-history', eng.speed_history.join(',')); drawSparkline(`sparkline-${eng.id}`, eng.speed_history, '#00ffad', 1024); }
-            } else if (container) container.style.display = 'none';
-        });
+    if (data.hasOwnProperty('receiver_disks')) { updateReceiverDisks(data.receiver_disks || []); }
+    const errorBadge = document.getElementById('error-badge');
+    if (errorBadge) {
+        window.lastError = data.last_error ? { message: data.last_error, class: data.last_error_class } : null;
+        errorBadge.style.display = data.last_error ? 'inline-block' : 'none';
+        errorBadge.innerText = data.last_error_class ? data.last_error_class.problem.toUpperCase() : 'ERROR';
+        errorBadge.title = data.last_error_class ? data.last_error_class.hint : (data.last_error || '');
+    }
+    if (data.hasOwnProperty('maintenance')) {
+        const badge = document.getElementById('maintenance-badge');
+        const windows = data.maintenance || [];
+        if (badge) {
+            badge.style.display = windows.length ? 'inline-block' : 'none';
+            badge.innerText = windows.some(m => !m.engine_id) ? 'MAINTENANCE' : `MAINTENANCE (${windows.length})`;
+            badge.title = windows.map(m => {
+                let line = m.engine_id ? `Engine ${m.engine_id}` : 'All engines';
+                line += m.until ? ` until ${new Date(m.until).toLocaleString()}` : ' until stopped';
+                if (m.reason) line += `: ${m.reason}`;
+                return line;
+            }).join('\n');
+        }
+    }
+}
+
+// Engine states by ID, built from the engines snapshot and engine_delta updates
+let engineState = {};
+
+function applyEngineSnapshot(engines) {
+    engineState = {};
+    (engines || []).forEach(eng => {
+        engineState[eng.id] = eng;
+        updateEngine(eng);
+    });
+}
+
+function applyEngineDeltas(deltas) {
+    (deltas || []).forEach(d => {
+        const eng = Object.assign(engineState[d.id] || { id: d.id }, d.changes);
+        engineState[d.id] = eng;
+        updateEngine(eng);
+    });
+}
+
+function updateEngine(eng) {
+    const container = document.getElementById(`engine-progress-container-${eng.id}`);
+    const bar = document.getElementById(`engine-progress-bar-${eng.id}`);
+    const fileText = document.getElementById(`engine-current-file-${eng.id}`);
+    const speedText = document.getElementById(`engine-current-speed-${eng.id}`);
+    const statusPill = document.getElementById(`engine-status-${eng.id}`);
+    const radar = document.getElementById(`engine-radar-${eng.id}`);
+    const remoteBadge = document.getElementById(`engine-remote-${eng.id}`);
+    const divergedBadge = document.getElementById(`engine-diverged-${eng.id}`);
+    const slowBadge = document.getElementById(`engine-slow-${eng.id}`);
+    const failedBanner = document.getElementById(`engine-failed-${eng.id}`);
+    const todayText = document.getElementById(`engine-today-${eng.id}`);
+    const totalText = document.getElementById(`engine-total-${eng.id}`);
+    const elapsedEl = document.getElementById(`engine-elapsed-${eng.id}`);
+    const avgEl = document.getElementById(`engine-avg-${eng.id}`);
+    const lastSyncEl = document.getElementById(`engine-lastsync-${eng.id}`);
+    const quotaRow = document.getElementById(`engine-quota-row-${eng.id}`);
+    const quotaEl = document.getElementById(`engine-quota-${eng.id}`);
+    const bwlimitRow = document.getElementById(`engine-bwlimit-row-${eng.id}`);
+    const bwlimitEl = document.getElementById(`engine-bwlimit-${eng.id}`);
+
+    if (lastSyncEl && eng.last_sync) {
+        lastSyncEl.setAttribute('data-time', eng.last_sync);
+        lastSyncEl.innerText = timeAgo(eng.last_sync);
+    }
+    if (quotaRow && quotaEl) {
+        quotaRow.style.display = eng.quota ? 'flex' : 'none';
+        if (eng.quota) {
+            quotaEl.innerText = `${eng.quota} (${Math.round(eng.quota_percent)}%)`;
+            quotaEl.style.color = eng.quota_percent >= 100 ? 'var(--accent-error)' : (eng.quota_percent >= 80 ? 'var(--accent-warning)' : 'var(--text-main)');
+        }
+    }
+    if (bwlimitRow && bwlimitEl) {
+        bwlimitRow.style.display = eng.bandwidth_limit ? 'flex' : 'none';
+        bwlimitEl.innerText = eng.bandwidth_limit || '';
+    }
+    if (todayText) todayText.innerText = eng.today;
+    if (totalText) totalText.innerText = eng.total;
+    if (radar) radar.style.display = eng.is_scanning ? 'flex' : 'none';
+    if (remoteBadge) remoteBadge.style.display = eng.is_remote_scan ? 'block' : 'none';
+    if (divergedBadge) divergedBadge.style.display = eng.target_diverged ? 'block' : 'none';
+    if (slowBadge) {
+        slowBadge.style.display = eng.speed_floor ? 'block' : 'none';
+        slowBadge.title = eng.speed_floor ? `Below the speed floor: ${eng.speed_floor}` : '';
+    }
+    if (failedBanner) {
+        const b = eng.breaker;
+        failedBanner.style.display = b ? 'block' : 'none';
+        if (b) {
+            const probe = b.probing ? 'probing the target now' : `next probe at ${new Date(b.next_probe).toLocaleTimeString()}`;
+            let text = `FAILED after ${b.failures} target errors in a row: ${b.last_error} (${probe})`;
+            if (eng.breaker_error) text = `FAILED: ${eng.breaker_error.problem}. ${eng.breaker_error.hint}\n${b.failures} target errors in a row, last: ${b.last_error} (${probe})`;
+            failedBanner.innerText = text;
+        }
+    }
+    if (statusPill) {
+        // Only the states below that explain themselves set a tooltip
+        statusPill.title = '';
+        if (eng.breaker) {
+            statusPill.innerText = 'FAILED';
+            statusPill.title = `Stopped since ${new Date(eng.breaker.since).toLocaleString()}, the target is probed until it works again`;
+            statusPill.className = 'status-pill pill-critical';
+        }
+        else if (eng.is_waiting_approval) {
+            statusPill.innerText = 'WAITING APPROVAL';
+            statusPill.className = 'status-pill pill-waiting';
+        }
+        else if (eng.is_offline && eng.is_read_only) {
+            statusPill.innerText = `READ-ONLY (${eng.backlog}${eng.backlog_overflow ? '+' : ''} queued)`;
+            statusPill.title = `Receiver is write-protected, ${eng.backlog_size} waiting until it is writable`;
+            statusPill.className = 'status-pill pill-paused';
+        }
+        else if (eng.is_offline) {
+            statusPill.innerText = `OFFLINE (${eng.backlog}${eng.backlog_overflow ? '+' : ''} queued)`;
+            statusPill.title = `Receiver unreachable, ${eng.backlog_size} waiting for catch-up`;
+            statusPill.className = 'status-pill pill-offline';
+        }
+        else if (eng.container_paused && eng.is_paused) {
+            statusPill.innerText = 'CONTAINER RESTART';
+            statusPill.title = `Paused until ${eng.container_paused} is running and healthy`;
+            statusPill.className = 'status-pill pill-paused';
+        }
+        else if (eng.quota_paused && eng.is_paused) {
+            statusPill.innerText = 'QUOTA REACHED';
+            statusPill.className = 'status-pill pill-paused';
+        }
+        else if (eng.is_paused) {
+            statusPill.innerText = 'PAUSED';
+            statusPill.className = 'status-pill pill-paused';
+        }
+        else if (eng.in_maintenance && !eng.is_active) {
+            statusPill.innerText = 'MAINTENANCE';
+            statusPill.className = 'status-pill pill-maintenance';
+        }
+        else if (eng.cleanup) {
+            statusPill.innerText = eng.cleanup.phase === 'rename' ? 'RENAMING' : 'DELETING';
+            statusPill.title = `${eng.cleanup.done} of ${eng.cleanup.total}: ${eng.cleanup.path}`;
+            statusPill.className = 'status-pill pill-syncing';
+        }
+        else if (eng.is_active) {
+            statusPill.innerText = 'SYNCING';
+            statusPill.className = 'status-pill pill-syncing';
+        }
+        else {
+            statusPill.innerText = 'ACTIVE';
+            statusPill.className = 'status-pill pill-active';
+        }
     }
+    if (container && eng.is_active) {
+        container.style.display = 'block';
+        if (bar) bar.style.width = eng.percent + '%';
+        if (fileText) fileText.innerText = eng.file || '...';
+        if (speedText) speedText.innerText = `${eng.speed} (${eng.eta})`;
+        if (elapsedEl) elapsedEl.innerText = `Elapsed: ${eng.elapsed}`;
+        if (avgEl) avgEl.innerText = `Avg: ${eng.avg_speed}`;
+        const sl = document.getElementById(`sparkline-${eng.id}`);
+        if (sl && eng.speed_history) { sl.setAttribute('data-history', eng.speed_history.join(',')); drawSparkline(`sparkline-${eng.id}`, eng.speed_history, '#00ffad', 1024); }
+    } else if (container && eng.cleanup) {
+        const c = eng.cleanup;
+        container.style.display = 'block';
+        if (bar) bar.style.width = (c.total > 0 ? c.done / c.total * 100 : 0) + '%';
+        if (fileText) fileText.innerText = c.path || '...';
+        if (speedText) speedText.innerText = `${c.phase === 'rename' ? 'Renaming' : 'Deleting'} ${c.done}/${c.total}`;
+    } else if (container) container.style.display = 'none';
 }
 
 function timeAgo(date) {
@@ -436,22 +592,40 @@ function parseDuration(str) {
 // --- 3. WebSocket Setup ---
 let socket;
 let reconnectDelay = 1000;
+// Message schema version this script was written for (see protocol.d.ts)
+const WS_PROTOCOL = 2;
+let wsRejected = false;
 
 function connectWS() {
-    socket = new WebSocket((window.location.protocol === 'https:' ? 'wss://' : 'ws://') + window.location.host + '/ws');
+    socket = new WebSocket((window.location.protocol === 'https:' ? 'wss://' : 'ws://') + window.location.host + BASE_PATH + '/ws?protocol=' + WS_PROTOCOL);
 
     socket.onopen = function () {
         console.log("WebSocket Connected");
         reconnectDelay = 1000; // Reset delay on success
+        subscribeWS();
     };
 
     socket.onmessage = function (event) {
         try {
             const msg = JSON.parse(event.data);
-            if (msg.type === 'progress') {
+            if (msg.type === 'upgrade_required') {
+                // The server was updated: reload once to fetch the matching dashboard
+                if (sessionStorage.getItem('schnorarr-ws-reload') !== String(msg.data.protocol)) {
+                    sessionStorage.setItem('schnorarr-ws-reload', String(msg.data.protocol));
+                    window.location.reload();
+                } else {
+                    console.error("WebSocket protocol mismatch:", msg.data.reason);
+                    wsRejected = true;
+                }
+                return;
+            }
+            if (msg.type === 'init') sessionStorage.removeItem('schnorarr-ws-reload');
+            else if (msg.type === 'progress') {
                 updateProgress(msg.data);
                 if (msg.data.top_files) updateTopFiles(msg.data.top_files);
             }
+            else if (msg.type === 'engines') applyEngineSnapshot(msg.data);
+            else if (msg.type === 'engine_delta') applyEngineDeltas(msg.data);
             else if (msg.type === 'history') addHistoryItem(msg.data);
             else if (msg.type === 'log') addLogLine(msg.data);
         } catch (e) {
@@ -462,6 +636,7 @@ function connectWS() {
     };
 
     socket.onclose = function (e) {
+        if (wsRejected) return;
         console.log(`WebSocket closed: ${e.reason}. Reconnecting in ${reconnectDelay}ms...`);
         setTimeout(connectWS, reconnectDelay);
         reconnectDelay = Math.min(reconnectDelay * 1.5, 30000); // Exponential backoff
@@ -473,6 +648,14 @@ function connectWS() {
     };
 }
 
+// Hidden tabs ask for slow updates; 0 is the server default
+function subscribeWS() {
+    if (!socket || socket.readyState !== WebSocket.OPEN) return;
+    const interval = document.hidden ? 30000 : parseInt(localStorage.getItem('schnorarr-refresh-ms') || '0');
+    socket.send(JSON.stringify({ type: 'subscribe', data: { interval_ms: interval } }));
+}
+
+document.addEventListener('visibilitychange', subscribeWS);
 connectWS();
 
 // --- 4. Sidebar & Settings ---
@@ -510,7 +693,7 @@ async function cycleSyncMode() {
 
     const formData = new FormData(); formData.append('mode', next);
     try {
-        const resp = await fetch('/settings/sync-mode', { method: 'POST', body: formData });
+        const resp = await fetch(BASE_PATH + '/settings/sync-mode', { method: 'POST', body: formData });
         if (resp.ok) {
             el.setAttribute('data-val', next);
             toast(`Mode: ${next.toUpperCase()}`, 'info');
@@ -527,7 +710,7 @@ async function updateAutoApprove(checkbox) {
     const val = checkbox.checked ? 'on' : 'off';
     const formData = new FormData(); formData.append('auto_approve', val);
     try {
-        const resp = await fetch('/settings/auto-approve', { method: 'POST', body: formData });
+        const resp = await fetch(BASE_PATH + '/settings/auto-approve', { method: 'POST', body: formData });
         if (resp.ok) {
             toast(`Auto-Approve: ${val.toUpperCase()}`, 'info');
         } else {
@@ -548,7 +731,7 @@ async function cycleOverrideMode() {
 
     const formData = new FormData(); formData.append('enabled', next === 'override');
     try {
-        const resp = await fetch('/settings/sender-override', { method: 'POST', body: formData });
+        const resp = await fetch(BASE_PATH + '/settings/sender-override', { method: 'POST', body: formData });
         if (resp.ok) {
             el.setAttribute('data-val', next);
             toast(`Conflicts: ${next.toUpperCase()}`, 'info');
@@ -561,6 +744,21 @@ async function cycleOverrideMode() {
     }
 }
 
+async function logoutAllSessions() {
+    if (!window.confirm('Log out all sessions, including this one?')) return;
+    try {
+        const resp = await fetch(BASE_PATH + '/api/admin/sessions', { method: 'DELETE' });
+        if (resp.ok) {
+            window.location.href = BASE_PATH + '/login';
+        } else {
+            const txt = await resp.text();
+            toast(`Error: ${txt}`, 'error');
+        }
+    } catch (e) {
+        toast(`Request failed: ${e.message}`, 'error');
+    }
+}
+
 // --- 5. Engine Actions ---
 function onEngineSelect() {
     const selected = document.querySelectorAll('.engine-select:checked');
@@ -582,13 +780,13 @@ async function executeBulkAction(action) {
     const selected = Array.from(document.querySelectorAll('.engine-select:checked')).map(cb => cb.value);
     if (selected.length === 0) return;
     try {
-        const resp = await fetch('/api/engines/bulk', { method: 'POST', headers: { 'Content-Type': 'application/json' }, body: JSON.stringify({ ids: selected, action: action }) });
+        const resp = await fetch(BASE_PATH + '/api/engines/bulk', { method: 'POST', headers: { 'Content-Type': 'application/json' }, body: JSON.stringify({ ids: selected, action: action }) });
         if (resp.ok) { toast(`Bulk ${action.toUpperCase()} Success`, 'success'); if (action !== 'sync') setTimeout(() => window.location.reload(), 800); else deselectAll(); }
     } catch (e) { toast('Bulk action failed', 'error'); }
 }
 
 function engineAction(id, action) {
-    fetch(`/api/engine/${id}/${action}`, { method: 'POST' })
+    fetch(`${BASE_PATH}/api/engine/${id}/${action}`, { method: 'POST' })
         .then(async resp => {
             if (resp.ok) {
                 toast(`${action.toUpperCase()} Signal Sent`, 'success');
@@ -610,7 +808,7 @@ function editAlias(id) {
     const next = prompt("Enter new alias:", current);
     if (next !== null && next.trim() !== "" && next !== current) {
         const formData = new FormData(); formData.append('alias', next.trim());
-        fetch(`/api/engine/${id}/alias`, { method: 'POST', body: formData }).then(r => { if (r.ok) { if (el) el.innerText = next.trim(); toast("Alias Updated", "success"); } });
+        fetch(`${BASE_PATH}/api/engine/${id}/alias`, { method: 'POST', body: formData }).then(r => { if (r.ok) { if (el) el.innerText = next.trim(); toast("Alias Updated", "success"); } });
     }
 }
 
@@ -630,7 +828,7 @@ async function showPreview(id, mode = 'preview') {
     document.getElementById('preview-id').innerText = id;
     if (modal) modal.style.display = 'flex'; if (loading) loading.style.display = 'block'; if (body) body.style.display = 'none';
     try {
-        const resp = await fetch(`/api/engine/${id}/preview`);
+        const resp = await fetch(`${BASE_PATH}/api/engine/${id}/preview`);
         if (!resp.ok) {
             throw new Error(`Preview failed: ${resp.statusText}`);
         }
@@ -640,7 +838,7 @@ async function showPreview(id, mode = 'preview') {
         let totalCount = plan.filesToSync.length + plan.filesToDelete.length + plan.conflicts.length + (plan.renames ? Object.keys(plan.renames).length : 0);
         let deleteCount = plan.filesToDelete.length + plan.dirsToDelete.length;
 
-        if (stats) stats.innerHTML = `<div class="stat-card" style="padding:15px;"><div class="stat-label">Changes</div><div class="stat-value" style="font-size:20px;">${totalCount}</div></div><div class="stat-card" style="padding:15px;"><div class="stat-label">Sync</div><div class="stat-value" style="font-size:20px;">${plan.filesToSync.length}</div></div><div class="stat-card" style="padding:15px;"><div class="stat-label">Delete</div><div class="stat-value" style="font-size:20px; color:var(--accent-error);">${deleteCount}</div></div><div class="stat-card" style="padding:15px;"><div class="stat-label">Conflicts</div><div class="stat-value" style="font-size:20px; color:var(--accent-warning);">${plan.conflicts.length}</div></div>`;
+        if (stats) stats.innerHTML = `<div class="stat-card" style="padding:15px;"><div class="stat-label">Changes</div><div class="stat-value" style="font-size:20px;">${totalCount}</div></div><div class="stat-card" style="padding:15px;"><div class="stat-label">Sync</div><div class="stat-value" style="font-size:20px;">${plan.filesToSync.length}</div></div><div class="stat-card" style="padding:15px;"><div class="stat-label">Delete</div><div class="stat-value" style="font-size:20px; color:var(--accent-error);">${deleteCount}</div></div><div class="stat-card" style="padding:15px;"><div class="stat-label">Conflicts</div><div class="stat-value" style="font-size:20px; color:var(--accent-warning);">${plan.conflicts.length}</div></div><div class="stat-card" style="padding:15px;"><div class="stat-label">Transfer</div><div class="stat-value" style="font-size:20px;">${formatBytes(plan.transferBytes || 0)}</div>${plan.estimatedCost ? `<div style="font-size:10px; color:var(--text-muted); margin-top:5px;">Est. cost: ${escapeHtml(plan.estimatedCost)}</div>` : ''}</div>`;
 
         let html = '<table style="width:100%; border-collapse: collapse; font-size:12px;">';
         html += '<tr style="text-align:left; color:var(--text-muted); border-bottom:1px solid var(--border-glass);">';
@@ -657,6 +855,10 @@ async function showPreview(id, mode = 'preview') {
         };
 
         plan.conflicts.forEach(c => {
+            if (c.external) {
+                html += renderRow("EXTERNAL", c.path, `<div style="font-size:10px; color:var(--accent-error);">${c.external === 'added' ? 'Added' : 'Modified'} on the receiver outside of sync</div><div style="font-size:9px; opacity:0.6;">Sync would ${plan.filesToDelete.includes(c.path) ? 'delete' : 'overwrite'} it (${formatBytes(c.receiverSize)})</div>`, "badge-deleted", false);
+                return;
+            }
             const isSourceNewer = new Date(c.sourceTime) > new Date(c.receiverTime);
             html += renderRow("DIFF", c.path, `<div style="font-size:10px; color:var(--accent-warning);">${isSourceNewer ? 'Sender is NEWER' : 'Sender is OLDER'}</div><div style="font-size:9px; opacity:0.6;">Size diff: ${formatBytes(Math.abs(c.sourceSize - c.receiverSize))}</div>`, "badge-renamed", true);
         });
@@ -673,6 +875,7 @@ async function showPreview(id, mode = 'preview') {
         }
 
         plan.filesToDelete.forEach(p => {
+            if (plan.conflicts.some(c => c.path === p)) return;
             html += renderRow("DEL", p, "-", "badge-deleted", true);
         });
 
@@ -687,6 +890,18 @@ async function showPreview(id, mode = 'preview') {
         }
 
         html += '</table>';
+        if (plan.skipped && plan.skipped.length > 0) {
+            html += `<details style="margin-top:15px;"><summary style="cursor:pointer; color:var(--text-muted); font-size:12px;">Skipped (${plan.skipped.length})</summary>`;
+            html += '<table style="width:100%; border-collapse: collapse; font-size:11px; margin-top:8px;">';
+            plan.skipped.forEach(s => {
+                html += `<tr style="border-bottom:1px solid rgba(255,255,255,0.05);">
+                    <td style="padding:6px; white-space:nowrap;"><span class="action-badge">${escapeHtml(s.reason)}</span></td>
+                    <td style="word-break: break-all;">${escapeHtml(s.path)}${s.count > 1 ? ` <span style="opacity:0.6;">(+${s.count - 1} more)</span>` : ''}</td>
+                    <td style="opacity:0.7;">${escapeHtml(s.detail || '')}</td>
+                </tr>`;
+            });
+            html += '</table></details>';
+        }
         if (details) details.innerHTML = html;
     } catch (e) { if (details) details.innerHTML = `Error loading preview: ${e.message}`; }
 }
@@ -707,7 +922,7 @@ async function confirmSyncFromPreview() {
     if (btn) btn.disabled = true;
 
     try {
-        const resp = await fetch(`/api/engine/${currentPreviewId}/approve-list`, {
+        const resp = await fetch(`${BASE_PATH}/api/engine/${currentPreviewId}/approve-list`, {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({ files: selected })
@@ -726,6 +941,53 @@ async function confirmSyncFromPreview() {
         if (btn) btn.disabled = false;
     }
 }
+const BROWSE_PAGE = 200;
+
+async function browseRemote(id, path = '', offset = 0) {
+    const modal = document.getElementById('browse-modal');
+    const list = document.getElementById('browse-list');
+    const page = document.getElementById('browse-page');
+    const prev = document.getElementById('browse-prev');
+    const next = document.getElementById('browse-next');
+    document.getElementById('browse-path').innerText = `${id}:/${path}`;
+    if (modal) modal.style.display = 'flex';
+    if (list) list.innerHTML = 'Loading...';
+    try {
+        const q = new URLSearchParams({ engine: id, path: path, offset: offset, limit: BROWSE_PAGE });
+        const resp = await fetch(`${BASE_PATH}/api/remote/list?${q}`);
+        if (!resp.ok) throw new Error(await resp.text());
+        const data = await resp.json();
+
+        let html = '<table style="width:100%; border-collapse: collapse; font-size:12px;">';
+        html += '<tr style="text-align:left; color:var(--text-muted); border-bottom:1px solid var(--border-glass);"><th style="padding:8px;">Name</th><th>Size</th><th>Modified</th></tr>';
+        const row = (name, target, size, mtime, note) => `<tr style="border-bottom:1px solid rgba(255,255,255,0.05);">
+            <td style="padding:8px; word-break: break-all;">${target !== null ? `<a href="#" data-path="${encodeURIComponent(target)}" class="browse-dir">📁 ${escapeHtml(name)}</a>` : escapeHtml(name)}${note}</td>
+            <td>${size}</td><td>${mtime}</td></tr>`;
+        if (path) {
+            html += row('..', path.split('/').slice(0, -1).join('/'), '', '', '');
+        }
+        data.entries.forEach(e => {
+            const child = path ? `${path}/${e.name}` : e.name;
+            const note = e.partial ? ' <span class="action-badge badge-renamed" title="Transfer in progress">partial</span>' : '';
+            const mtime = e.mod_time ? new Date(e.mod_time).toLocaleString() : '';
+            html += row(e.name, e.is_dir ? child : null, e.is_dir ? '-' : formatBytes(e.size), mtime, note);
+        });
+        html += '</table>';
+        if (list) list.innerHTML = data.entries.length || path ? html : 'Empty directory.';
+        list.querySelectorAll('.browse-dir').forEach(a => a.onclick = (ev) => {
+            ev.preventDefault();
+            browseRemote(id, decodeURIComponent(a.dataset.path));
+        });
+
+        const last = Math.min(data.offset + data.entries.length, data.total);
+        if (page) page.innerText = data.total ? `${data.offset + 1}-${last} of ${data.total}` : '';
+        if (prev) { prev.disabled = data.offset === 0; prev.onclick = () => browseRemote(id, path, Math.max(0, data.offset - BROWSE_PAGE)); }
+        if (next) { next.disabled = last >= data.total; next.onclick = () => browseRemote(id, path, last); }
+    } catch (e) { if (list) list.innerHTML = `Error loading directory: ${escapeHtml(e.message)}`; }
+}
+
+function closeBrowse() { const el = document.getElementById('browse-modal'); if (el) el.style.display = 'none'; }
+
 // --- 7. UI Helpers ---
 function formatBytes(b) { b = Math.abs(b); if (b === 0) return '0 B'; const k = 1024, s = ['B', 'KB', 'MB', 'GB', 'TB'], i = Math.floor(Math.log(b) / Math.log(k)); return parseFloat((b / Math.pow(k, i)).toFixed(2)) + ' ' + s[i]; }
 function parseBytes(str) {
@@ -771,6 +1033,7 @@ function addHistoryItem(data) {
     if (!list) return;
     const li = document.createElement('li');
     li.className = 'activity-item';
+    if (data.cycle) li.title = `Sync cycle ${data.cycle} (/api/cycles/${data.cycle})`;
     const actionClass = data.action.toLowerCase().trim().replace(/\s+/g, '-');
     li.innerHTML = `<span class="action-badge badge-${actionClass}">${escapeHtml(data.action)}</span>
         <div style="flex: 1; white-space: nowrap;">${escapeHtml(data.path)}
@@ -781,7 +1044,7 @@ function addHistoryItem(data) {
 
     // Mirror to live log
     addLogLine({
-        msg: `[Event] ${data.action}: ${data.path}`,
+        msg: `[Event] ${data.action}: ${data.path}` + (data.cycle ? ` cycle=${data.cycle}` : ''),
         level: 'info'
     });
 }
@@ -818,8 +1081,8 @@ document.addEventListener('DOMContentLoaded', () => {
 
 window.addEventListener('keydown', e => {
     if (e.target.tagName === 'INPUT') return;
-    if (e.key.toLowerCase() === 's') window.location.href = '/sync';
-    if (e.key.toLowerCase() === 'p') window.location.href = '/pause';
+    if (e.key.toLowerCase() === 's') window.location.href = BASE_PATH + '/sync';
+    if (e.key.toLowerCase() === 'p') window.location.href = BASE_PATH + '/pause';
     if (e.key === '/') { e.preventDefault(); const s = document.getElementById('engine-search'); if (s) s.focus(); }
     if (e.key === '?') toast("Shortcuts: S (Sync), P (Pause), / (Search)", "info");
 });
@@ -827,6 +1090,13 @@ window.addEventListener('keydown', e => {
 if (Notification.permission !== "granted" && Notification.permission !== "denied") Notification.requestPermission();
 
 // --- 8. Error & Receiver Modals ---
+function showLastError() {
+    const err = window.lastError;
+    if (!err) return;
+    if (err.class) showErrorModal(err.class.problem, `${err.class.hint}\n\n${err.message}`);
+    else showErrorModal("Sync Error", err.message);
+}
+
 function showReceiverError() {
     const badge = document.getElementById('receiver-badge');
     if (!badge) return;
@@ -834,6 +1104,39 @@ function showReceiverError() {
     showErrorModal("Receiver Status", msg);
 }
 
+function updateReceiverDisks(disks) {
+    const badge = document.getElementById('receiver-disks-badge');
+    if (!badge) return;
+    if (disks.length === 0) { badge.style.display = 'none'; return; }
+    const failing = disks.filter(d => d.status === 'failing').length;
+    const warning = disks.filter(d => d.status === 'warning' || d.status === 'unknown').length;
+    badge.style.display = 'inline-block';
+    if (failing > 0) {
+        badge.className = 'status-pill pill-critical';
+        badge.innerText = `${failing} DISK${failing > 1 ? 'S' : ''} FAILING`;
+    } else if (warning > 0) {
+        badge.className = 'status-pill pill-paused';
+        badge.innerText = `${warning} DISK WARNING${warning > 1 ? 'S' : ''}`;
+    } else {
+        badge.className = 'status-pill pill-active';
+        badge.innerText = 'DISKS OK';
+    }
+    badge.title = disks.map(d => {
+        let line = `${d.device} ${d.model || ''}: ${d.status.toUpperCase()}`;
+        if (d.error) return `${line} (${d.error})`;
+        line += ` | ${d.temperature}°C | ${d.power_on_hours}h`;
+        if (d.reallocated_sectors || d.pending_sectors) line += ` | realloc ${d.reallocated_sectors}, pending ${d.pending_sectors}`;
+        if (d.media_errors) line += ` | media errors ${d.media_errors}`;
+        return line;
+    }).join('\n');
+}
+
+function showReceiverDisks() {
+    const badge = document.getElementById('receiver-disks-badge');
+    if (!badge) return;
+    showErrorModal("Receiver Disk Health", badge.title || "No SMART data reported.");
+}
+
 function showErrorModal(title, msg) {
     const modal = document.getElementById('error-modal');
     const titleEl = document.getElementById('error-title');
@@ -845,6 +1148,50 @@ function showErrorModal(title, msg) {
     }
 }
 
+async function showLatencyHistory() {
+    const modal = document.getElementById('latency-modal');
+    if (!modal) return;
+    modal.style.display = 'flex';
+    const summary = document.getElementById('latency-summary');
+    try {
+        const res = await fetch(BASE_PATH + '/api/latency?hours=24');
+        if (!res.ok) throw new Error(await res.text());
+        const data = await res.json();
+        const history = data.history || [];
+        if (summary) {
+            const c = data.current;
+            summary.innerText = c ? `Now: min ${c.min_ms}ms · avg ${c.avg_ms}ms · p95 ${c.p95_ms}ms · loss ${c.loss.toFixed(1)}% (${c.host})` : 'No checks yet.';
+            if (!history.length) summary.innerText += ' No history stored yet.';
+        }
+        drawLatencyChart(history);
+    } catch (e) {
+        if (summary) summary.innerText = 'Failed to load latency history: ' + e.message;
+    }
+}
+
+// drawLatencyChart plots avg and p95 per bucket over the loss percentage
+function drawLatencyChart(history) {
+    const width = 600, height = 200;
+    const ids = ['latency-chart-avg', 'latency-chart-p95', 'latency-chart-loss'];
+    const [avgPath, p95Path, lossPath] = ids.map(id => document.getElementById(id));
+    if (!avgPath || !p95Path || !lossPath) return;
+    if (history.length < 2) { ids.forEach(id => document.getElementById(id).setAttribute('d', '')); return; }
+
+    const max = Math.max(100, ...history.map(h => h.p95_ms)) * 1.1;
+    const x = i => (i / (history.length - 1)) * width;
+    const y = ms => height - (ms / max) * height;
+    const line = key => history.map((h, i) => `${i ? 'L' : 'M'} ${x(i).toFixed(1)} ${y(h[key]).toFixed(1)}`).join(' ');
+    avgPath.setAttribute('d', line('avg_ms'));
+    p95Path.setAttribute('d', line('p95_ms'));
+    const loss = history.map((h, i) => `L ${x(i).toFixed(1)} ${(height - (h.loss / 100) * height).toFixed(1)}`).join(' ');
+    lossPath.setAttribute('d', `M 0 ${height} ${loss} L ${width} ${height} Z`);
+}
+
+function closeLatencyModal() {
+    const modal = document.getElementById('latency-modal');
+    if (modal) modal.style.display = 'none';
+}
+
 function closeErrorModal() {
     const modal = document.getElementById('error-modal');
     if (modal) modal.style.display = 'none';
@@ -0,0 +1,236 @@
+// Code generated by cmd/wsschema; DO NOT EDIT.
+// Messages the dashboard WebSocket (/ws) sends.
+
+export const PROTOCOL_VERSION = 2;
+export const MIN_PROTOCOL_VERSION = 2;
+
+export interface DailyTraffic {
+  Date: string;
+  Bytes: number;
+  Size: string;
+  Cost: string;
+  HeightPercent: number;
+}
+
+export interface EngineDelta {
+  id: string;
+  changes: Record<string, unknown>;
+}
+
+export interface BreakerStats {
+  open: boolean;
+  probing: boolean;
+  since: string;
+  failures: number;
+  last_error: string;
+  next_probe: string;
+}
+
+export interface Class {
+  code: string;
+  problem: string;
+  hint: string;
+}
+
+export interface PhaseProgress {
+  phase: string;
+  done: number;
+  total: number;
+  path: string;
+}
+
+export interface EngineProgress {
+  id: string;
+  file: string;
+  percent: number;
+  speed: string;
+  today: string;
+  total: string;
+  is_active: boolean;
+  eta: string;
+  queue_count: number;
+  is_scanning: boolean;
+  avg_speed: string;
+  elapsed: string;
+  speed_history: number[] | null;
+  is_paused: boolean;
+  last_sync: string;
+  is_remote_scan: boolean;
+  is_waiting_approval: boolean;
+  cycle?: string;
+  is_offline: boolean;
+  is_read_only: boolean;
+  target_diverged: boolean;
+  backlog: number;
+  backlog_size: string;
+  backlog_overflow: boolean;
+  quota?: string;
+  quota_percent: number;
+  quota_paused: boolean;
+  container_paused?: string;
+  in_maintenance: boolean;
+  groups?: string[] | null;
+  bandwidth_limit?: string;
+  speed_floor?: string;
+  breaker?: BreakerStats | null;
+  breaker_error?: Class | null;
+  cleanup?: PhaseProgress | null;
+}
+
+export interface HistoryItem {
+  time: string;
+  action: string;
+  path: string;
+  size: string;
+  cycle?: string;
+}
+
+export interface Hello {
+  protocol: number;
+  min_protocol: number;
+}
+
+export interface LogEntry {
+  msg: string;
+  level: string;
+  cycle?: string;
+}
+
+export interface LatencyStats {
+  time: string;
+  host: string;
+  samples: number;
+  lost: number;
+  loss: number;
+  min_ms: number;
+  avg_ms: number;
+  p95_ms: number;
+  max_ms: number;
+}
+
+export interface Disk {
+  device: string;
+  model?: string;
+  serial?: string;
+  passed: boolean;
+  temperature: number;
+  power_on_hours: number;
+  reallocated_sectors: number;
+  pending_sectors: number;
+  media_errors: number;
+  status: string;
+  error?: string;
+}
+
+export interface Metrics {
+  goroutines: number;
+  heap_alloc: number;
+  heap_sys: number;
+  sys: number;
+  rss: number;
+  open_fds: number;
+  cpu_percent: number;
+  num_cpu: number;
+  num_gc: number;
+}
+
+export interface Load {
+  load1: number;
+  num_cpu: number;
+  io_pressure: number;
+  cpu_pressure: number;
+}
+
+export interface QuotaStatus {
+  engine_id: string;
+  used: number;
+  limit: number;
+  percent: number;
+  exhausted: boolean;
+  resets: string;
+  label: string;
+}
+
+export interface Maintenance {
+  engine_id: string;
+  started: string;
+  until?: string | null;
+  reason: string;
+  user: string;
+}
+
+export interface GroupSummary {
+  name: string;
+  engines: string[] | null;
+  paused: number;
+  active: number;
+  speed: number;
+  today: number;
+  total: number;
+}
+
+export interface Progress {
+  speed: string;
+  state: string;
+  eta: string;
+  latency: number;
+  latency_stats: LatencyStats | null;
+  top_files: HistoryItem[] | null;
+  receiver_healthy: boolean;
+  receiver_msg: string;
+  receiver_error?: Class | null;
+  receiver_version: string;
+  receiver_uptime: string;
+  receiver_host: string;
+  receiver_disks: Disk[] | null;
+  system: Metrics;
+  receiver_system: Metrics | null;
+  receiver_load: Load | null;
+  receiver_busy?: string;
+  traffic_today: string;
+  traffic_total: string;
+  quota: QuotaStatus;
+  cost_today: string;
+  cost_ceiling: QuotaStatus | null;
+  maintenance: Maintenance[] | null;
+  groups: GroupSummary[] | null;
+  last_error?: string;
+  last_error_class?: Class | null;
+}
+
+export interface TrafficStats {
+  Today: number;
+  Total: number;
+}
+
+export interface SyncStatus {
+  status: string;
+  engines: number;
+}
+
+export interface UpgradeRequired {
+  protocol: number;
+  min_protocol: number;
+  client: number;
+  reason: string;
+}
+
+export interface Subscribe {
+  interval_ms: number;
+}
+
+export type Message =
+  | { type: "daily"; data: DailyTraffic[] | null }
+  | { type: "engine_delta"; data: EngineDelta[] | null }
+  | { type: "engines"; data: EngineProgress[] | null }
+  | { type: "history"; data: HistoryItem }
+  | { type: "init"; data: Hello }
+  | { type: "log"; data: LogEntry }
+  | { type: "progress"; data: Progress }
+  | { type: "stats"; data: TrafficStats }
+  | { type: "sync_status"; data: SyncStatus }
+  | { type: "upgrade_required"; data: UpgradeRequired };
+
+// Messages clients send
+export type ClientMessage =
+  | { type: "subscribe"; data: Subscribe };
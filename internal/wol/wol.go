@@ -0,0 +1,55 @@
+// Package wol sends Wake-on-LAN magic packets.
+package wol
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+)
+
+// DefaultBroadcast is where magic packets go when no address is configured
+const DefaultBroadcast = "255.255.255.255:9"
+
+// MagicPacket builds the payload waking the NIC with the given MAC address:
+// six 0xFF bytes followed by the address repeated 16 times.
+func MagicPacket(mac string) ([]byte, error) {
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAC address %q: %w", mac, err)
+	}
+	if len(hw) != 6 {
+		return nil, fmt.Errorf("invalid MAC address %q: expected 6 bytes", mac)
+	}
+	packet := bytes.Repeat([]byte{0xFF}, 6)
+	for i := 0; i < 16; i++ {
+		packet = append(packet, hw...)
+	}
+	return packet, nil
+}
+
+// Send broadcasts a magic packet for mac to broadcast (host:port, port 9 when omitted)
+func Send(mac, broadcast string) error {
+	packet, err := MagicPacket(mac)
+	if err != nil {
+		return err
+	}
+	if broadcast == "" {
+		broadcast = DefaultBroadcast
+	}
+	if _, _, err := net.SplitHostPort(broadcast); err != nil {
+		broadcast = net.JoinHostPort(broadcast, "9")
+	}
+	addr, err := net.ResolveUDPAddr("udp4", broadcast)
+	if err != nil {
+		return fmt.Errorf("invalid broadcast address %q: %w", broadcast, err)
+	}
+	conn, err := net.DialUDP("udp4", nil, addr)
+	if err != nil {
+		return fmt.Errorf("failed to open UDP socket: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+	if _, err := conn.Write(packet); err != nil {
+		return fmt.Errorf("failed to send magic packet: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package wol
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMagicPacket(t *testing.T) {
+	packet, err := MagicPacket("00:11:22:aa:bb:cc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packet) != 102 || !bytes.Equal(packet[:6], bytes.Repeat([]byte{0xFF}, 6)) {
+		t.Fatalf("Unexpected packet header: %x", packet[:6])
+	}
+	mac := []byte{0x00, 0x11, 0x22, 0xaa, 0xbb, 0xcc}
+	for i := 0; i < 16; i++ {
+		if got := packet[6+i*6 : 12+i*6]; !bytes.Equal(got, mac) {
+			t.Fatalf("Repetition %d is %x", i, got)
+		}
+	}
+
+	for _, bad := range []string{"", "zz:11:22:33:44:55", "00:11:22:33:44:55:66:77"} {
+		if _, err := MagicPacket(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skipf("UDP not available: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if err := Send("00-11-22-aa-bb-cc", conn.LocalAddr().String()); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 200)
+	n, _, err := conn.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := MagicPacket("00:11:22:aa:bb:cc")
+	if !bytes.Equal(buf[:n], want) {
+		t.Errorf("Received %x", buf[:n])
+	}
+}
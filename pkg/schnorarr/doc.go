@@ -0,0 +1,38 @@
+// Package schnorarr embeds the schnorarr sync engine in other Go programs,
+// without the dashboard, database or notifications of the monitor.
+//
+// An Engine mirrors a source directory to a local directory or an rsync
+// daemon target ("host::module/path" or "rsync://host/module/path"):
+//
+//	engine, err := schnorarr.NewEngine(schnorarr.Config{
+//		ID:        "movies",
+//		SourceDir: "/data/movies",
+//		TargetDir: "/mnt/backup/movies",
+//		OnEvent:   func(ev schnorarr.Event) { log.Println(ev.Action, ev.Path) },
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	plan, err := engine.Preview() // What the next cycle would do
+//	...
+//	err = engine.SyncOnce()       // Run one cycle now
+//
+// Start runs the engine in the background instead: it syncs once, then
+// follows filesystem events and polls until Stop.
+//
+// Deletions, renames and conflicts wait for ApproveChanges unless
+// Config.AutoApproveDeletions is set. A cycle with such changes is held as
+// a whole and reports Status.WaitingForApproval; with Config.SplitApproval
+// its transfers run while the rest waits.
+//
+// Embedded engines keep their state (pending approvals, the last synced
+// state used to tell additions on the target from deletions on the source,
+// failed transfers) in memory, so it starts over with every process.
+//
+// Scanner and Transport expose the building blocks on their own: a Scanner
+// lists a tree as a Manifest, Compare turns two manifests into a Plan and a
+// Transport copies, renames and deletes files.
+//
+// The types in this package are the stable API; everything under internal/
+// may change between releases.
+package schnorarr
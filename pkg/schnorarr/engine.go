@@ -0,0 +1,188 @@
+package schnorarr
+
+import (
+	"errors"
+	"time"
+
+	"schnorarr/internal/sync"
+)
+
+// Sync rules
+const (
+	RuleStandard = "standard" // Mirror the source tree
+	RuleSeries   = "series"   // Mirror, keeping series folders on the receiver intact
+	RuleFlat     = "flat"     // Sync every file into the target root
+	RuleMove     = "move"     // Mirror, then remove source files once MoveAfter has passed
+)
+
+// Policies deciding when a file present on both ends is transferred again
+const (
+	MtimeNewer        = sync.MtimeNewer
+	MtimeSizeOrMtime  = sync.MtimeSizeOrMtime
+	MtimeSizeAndMtime = sync.MtimeSizeAndMtime
+)
+
+// Config configures an Engine. Only ID, SourceDir and TargetDir are required.
+type Config struct {
+	// ID names the engine in logs and events
+	ID string
+	// SourceDir is the local directory synced from
+	SourceDir string
+	// TargetDir is a local directory or an rsync daemon target
+	TargetDir string
+	// Rule is the sync strategy (default: RuleStandard)
+	Rule string
+	// Include limits the sync to files matching one of these glob patterns (default: all)
+	Include []string
+	// Exclude skips files and directories matching one of these glob patterns
+	Exclude []string
+	// NeverDelete are path patterns that are never deleted from the target
+	NeverDelete []string
+	// NeverOverwrite are path patterns whose existing target files are never replaced
+	NeverOverwrite []string
+	// MtimePolicy decides when a file on both ends is transferred again (default: MtimeNewer)
+	MtimePolicy string
+	// MtimeTolerance treats modification times this close as equal (0 = whole seconds)
+	MtimeTolerance time.Duration
+	// ScanCommand is run against every file before it is published ("{}" is
+	// replaced by the path, otherwise it is appended); a nonzero exit
+	// quarantines the file instead
+	ScanCommand string
+	// BandwidthLimit in bytes per second (0 = unlimited)
+	BandwidthLimit int64
+	// PollInterval is how often a started engine polls the source (0 = events only)
+	PollInterval time.Duration
+	// FullScanInterval is how often a started engine compares both ends in full (0 = never)
+	FullScanInterval time.Duration
+	// MoveAfter is how old a source file must be before RuleMove removes it
+	MoveAfter time.Duration
+	// DryRun reports the changes as events without making them
+	DryRun bool
+	// AutoApproveDeletions runs deletions, renames and conflicts without ApproveChanges
+	AutoApproveDeletions bool
+	// SplitApproval lets the transfers of a held cycle run; only deletions,
+	// renames and conflicts wait
+	SplitApproval bool
+	// OnEvent receives every change the engine makes (optional)
+	OnEvent func(Event)
+	// OnError receives errors of background cycles and single files (optional)
+	OnError func(msg string)
+}
+
+// Event is a change made (or, in a dry run, planned) by an engine
+type Event struct {
+	Time time.Time
+	// Action is e.g. "Added", "Deleted", "Renamed", "DRY-Added"
+	Action string
+	Path   string
+	Size   int64
+	// Cycle identifies the sync cycle the change belongs to
+	Cycle string
+}
+
+// Status is a snapshot of an engine's state
+type Status struct {
+	Paused             bool
+	Scanning           bool
+	WaitingForApproval bool
+	// PendingChanges are the paths of the changes waiting for approval
+	PendingChanges []string
+	// LastSync is when the last cycle completed (zero before the first one)
+	LastSync time.Time
+	// File is the file being transferred, empty when idle
+	File string
+	// Transferred and Total are the bytes of File done and in total
+	Transferred, Total int64
+	// Speed is the current transfer rate in bytes per second
+	Speed int64
+}
+
+// Engine syncs one source directory to one target
+type Engine struct {
+	e *sync.Engine
+}
+
+// NewEngine creates an engine. Nothing is synced before Start or SyncOnce.
+func NewEngine(cfg Config) (*Engine, error) {
+	if cfg.ID == "" || cfg.SourceDir == "" || cfg.TargetDir == "" {
+		return nil, errors.New("schnorarr: ID, SourceDir and TargetDir are required")
+	}
+	policy, err := sync.ParseMtimePolicy(cfg.MtimePolicy)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := sync.ResolvePathOverlap(cfg.SourceDir, cfg.TargetDir); err != nil {
+		return nil, err
+	}
+	sc := sync.SyncConfig{
+		ID: cfg.ID, SourceDir: cfg.SourceDir, TargetDir: cfg.TargetDir, Rule: cfg.Rule,
+		ExcludePatterns: cfg.Exclude, IncludePatterns: cfg.Include,
+		NeverDeletePatterns: cfg.NeverDelete, NeverOverwritePatterns: cfg.NeverOverwrite,
+		MtimePolicy: policy, MtimeTolerance: cfg.MtimeTolerance, ScanCommand: cfg.ScanCommand,
+		BandwidthLimit: cfg.BandwidthLimit, PollInterval: cfg.PollInterval, WatchInterval: cfg.FullScanInterval,
+		MoveAfter: cfg.MoveAfter, DryRun: cfg.DryRun, AutoApproveDeletions: cfg.AutoApproveDeletions, SplitApproval: cfg.SplitApproval,
+		OnError: cfg.OnError,
+	}
+	if cfg.OnEvent != nil {
+		sc.OnSyncEvent = func(ts, action, path string, size int64, cycle string) {
+			t, err := time.Parse("2006-01-02 15:04:05", ts)
+			if err != nil {
+				t = time.Now().UTC()
+			}
+			cfg.OnEvent(Event{Time: t, Action: action, Path: path, Size: size, Cycle: cycle})
+		}
+	}
+	return &Engine{e: sync.NewEngine(sc)}, nil
+}
+
+// Start syncs once in the background and keeps the target in sync with
+// filesystem events, polls and full scans until Stop
+func (e *Engine) Start() error { return e.e.Start() }
+
+// Stop ends the background syncing of a started engine
+func (e *Engine) Stop() { e.e.Stop() }
+
+// SyncOnce scans both ends and runs one sync cycle. Changes that need
+// approval are held (see Status) and the error is nil.
+func (e *Engine) SyncOnce() error { return e.e.RunSync(nil) }
+
+// Preview returns what the next cycle would do without changing anything
+func (e *Engine) Preview() (*Plan, error) {
+	p, err := e.e.PreviewSync()
+	if err != nil {
+		return nil, err
+	}
+	return newPlan(p), nil
+}
+
+// Pause interrupts running transfers and holds further cycles
+func (e *Engine) Pause() { e.e.Pause() }
+
+// Resume lets a paused engine sync again
+func (e *Engine) Resume() { e.e.Resume() }
+
+// ApproveChanges runs the held deletions, renames and conflicts in a new
+// background cycle. With paths only those changes are approved.
+func (e *Engine) ApproveChanges(paths ...string) {
+	if len(paths) == 0 {
+		e.e.ApproveDeletions()
+		return
+	}
+	e.e.ApproveSpecificChanges(paths)
+}
+
+// Status returns a snapshot of the engine's state
+func (e *Engine) Status() Status {
+	file, done, total, speed := e.e.GetTransferStats()
+	return Status{
+		Paused:             e.e.IsPaused(),
+		Scanning:           e.e.IsScanning(),
+		WaitingForApproval: e.e.IsWaitingForApproval(),
+		PendingChanges:     e.e.GetPendingDeletions(),
+		LastSync:           e.e.GetLastSyncTime(),
+		File:               file,
+		Transferred:        done,
+		Total:              total,
+		Speed:              speed,
+	}
+}
@@ -0,0 +1,102 @@
+package schnorarr
+
+import (
+	"sort"
+	"time"
+
+	"schnorarr/internal/sync"
+)
+
+// Plan lists the changes a sync cycle makes to the target. Paths are
+// relative to the source and target roots and use forward slashes.
+type Plan struct {
+	// Transfers are files copied to the target, new or replacing an older copy
+	Transfers []File
+	// Deletions are target files missing from the source
+	Deletions []string
+	// NewDirs are directories created on the target
+	NewDirs []string
+	// DeletedDirs are target directories missing from the source
+	DeletedDirs []string
+	// Renames maps target paths to their new path, instead of a transfer and a deletion
+	Renames map[string]string
+	// Conflicts are target files that differ from the source and are replaced
+	Conflicts []Conflict
+	// Skipped are paths the plan leaves alone with the reason; only previews fill it
+	Skipped []Skipped
+}
+
+// Conflict is a file that exists on both ends with different contents
+type Conflict struct {
+	Path       string
+	SourceSize int64
+	SourceTime time.Time
+	TargetSize int64
+	TargetTime time.Time
+	// External is set when the target copy was changed outside of sync
+	External bool
+}
+
+// Skipped is a path a plan does not act on
+type Skipped struct {
+	Path string
+	// Reason is machine-readable, e.g. "excluded", "never_delete", "target_added"
+	Reason string
+	Detail string
+	// Count is the number of entries a grouped item covers
+	Count int
+}
+
+// Empty reports whether the plan changes nothing
+func (p *Plan) Empty() bool {
+	return len(p.Transfers) == 0 && len(p.Deletions) == 0 && len(p.NewDirs) == 0 &&
+		len(p.DeletedDirs) == 0 && len(p.Renames) == 0
+}
+
+// CompareOptions controls Compare
+type CompareOptions struct {
+	// Rule is the sync strategy (default: RuleStandard)
+	Rule string
+	// SkipRenames transfers and deletes instead of detecting renames
+	SkipRenames bool
+	// NeverDelete are path patterns that are never deleted from the target
+	NeverDelete []string
+	// MtimePolicy decides when a file on both ends is transferred again (default: MtimeNewer)
+	MtimePolicy string
+	// MtimeTolerance treats modification times this close as equal (0 = whole seconds)
+	MtimeTolerance time.Duration
+}
+
+// Compare returns the plan that makes target match source. Unlike an
+// Engine it has no previous state, so every file only on the target is
+// planned for deletion.
+func Compare(source, target *Manifest, opts CompareOptions) *Plan {
+	return newPlan(sync.CompareManifestsWithOptions(source.m, target.m, sync.CompareOptions{
+		Rule: opts.Rule, SkipRenames: opts.SkipRenames, NeverDelete: opts.NeverDelete,
+		Mtime: sync.MtimeCompare{Policy: opts.MtimePolicy, Tolerance: opts.MtimeTolerance},
+	}))
+}
+
+func newPlan(p *sync.SyncPlan) *Plan {
+	out := &Plan{
+		Deletions:   append([]string(nil), p.FilesToDelete...),
+		NewDirs:     append([]string(nil), p.DirsToCreate...),
+		DeletedDirs: append([]string(nil), p.DirsToDelete...),
+		Renames:     make(map[string]string, len(p.Renames)),
+	}
+	for _, f := range p.FilesToSync {
+		out.Transfers = append(out.Transfers, newFile(f))
+	}
+	for from, to := range p.Renames {
+		out.Renames[from] = to
+	}
+	for _, c := range p.Conflicts {
+		out.Conflicts = append(out.Conflicts, Conflict{Path: c.Path, SourceSize: c.SourceSize, SourceTime: c.SourceTime,
+			TargetSize: c.ReceiverSize, TargetTime: c.ReceiverTime, External: c.External != ""})
+	}
+	for _, s := range p.Skipped {
+		out.Skipped = append(out.Skipped, Skipped{Path: s.Path, Reason: s.Reason, Detail: s.Detail, Count: s.Count})
+	}
+	sort.Slice(out.Transfers, func(i, j int) bool { return out.Transfers[i].Path < out.Transfers[j].Path })
+	return out
+}
@@ -0,0 +1,74 @@
+package schnorarr
+
+import (
+	"sort"
+	"time"
+
+	"schnorarr/internal/sync"
+)
+
+// File is a file or directory of a Manifest
+type File struct {
+	// Path is relative to the manifest root, with forward slashes
+	Path    string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+}
+
+func newFile(f *sync.FileInfo) File {
+	return File{Path: f.Path, Size: f.Size, ModTime: f.ModTime, IsDir: f.IsDir}
+}
+
+// Manifest is the file tree of one end at the time of a scan
+type Manifest struct {
+	m *sync.Manifest
+}
+
+// Root returns the scanned directory or rsync target
+func (m *Manifest) Root() string { return m.m.Root }
+
+// Len returns the number of files and directories
+func (m *Manifest) Len() int { return len(m.m.Files) }
+
+// Files returns the files and directories sorted by path
+func (m *Manifest) Files() []File {
+	var files []File
+	for _, f := range m.m.Files {
+		files = append(files, newFile(f))
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
+
+// Lookup returns the entry at path
+func (m *Manifest) Lookup(path string) (File, bool) {
+	if f, ok := m.m.GetFile(path); ok {
+		return newFile(f), true
+	}
+	return File{}, false
+}
+
+// Scanner lists directories as manifests. A Scanner may be reused; it
+// keeps the last manifest of each rsync target to revalidate it cheaply.
+type Scanner struct {
+	s *sync.Scanner
+}
+
+// NewScanner returns a scanner that skips entries matching exclude and,
+// when include is not empty, files matching none of its glob patterns
+func NewScanner(include, exclude []string) *Scanner {
+	s := sync.NewScanner()
+	s.IncludePatterns = include
+	s.ExcludePatterns = append(s.ExcludePatterns, exclude...)
+	return &Scanner{s: s}
+}
+
+// Scan lists a local directory or, through its receiver agent, an rsync target
+func (s *Scanner) Scan(root string) (*Manifest, error) {
+	m, err := s.s.ScanLocal(root)
+	if err != nil {
+		return nil, err
+	}
+	return &Manifest{m: m}, nil
+}
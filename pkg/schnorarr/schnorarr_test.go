@@ -0,0 +1,91 @@
+package schnorarr
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEngine_SyncOnce(t *testing.T) {
+	source, target := t.TempDir(), t.TempDir()
+	if err := os.MkdirAll(filepath.Join(source, "Show"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "Show", "E01.mkv"), []byte("episode"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(target, "old.mkv"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewEngine(Config{ID: "x", SourceDir: source}); err == nil {
+		t.Error("A config without target should be rejected")
+	}
+	var events []Event
+	engine, err := NewEngine(Config{ID: "embedded", SourceDir: source, TargetDir: target, SplitApproval: true,
+		OnEvent: func(ev Event) { events = append(events, ev) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := engine.Preview()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Transfers) != 1 || plan.Transfers[0].Path != "Show/E01.mkv" || len(plan.Deletions) != 1 {
+		t.Fatalf("Preview = %+v", plan)
+	}
+
+	if err := engine.SyncOnce(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(target, "Show", "E01.mkv")); string(data) != "episode" {
+		t.Error("SyncOnce should copy the new file")
+	}
+	st := engine.Status()
+	if !st.WaitingForApproval || len(st.PendingChanges) != 1 {
+		t.Errorf("The deletion should wait for approval, status = %+v", st)
+	}
+	if _, err := os.Stat(filepath.Join(target, "old.mkv")); err != nil {
+		t.Error("Deletions must not run before approval")
+	}
+	if len(events) == 0 || events[0].Action != "Added" {
+		t.Errorf("Events = %+v, want the transfer", events)
+	}
+}
+
+func TestCompareAndTransport(t *testing.T) {
+	source, target := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "a.mkv"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	scanner := NewScanner([]string{"*.mkv"}, nil)
+	src, err := scanner.Scan(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := scanner.Scan(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := Compare(src, dst, CompareOptions{})
+	if len(plan.Transfers) != 1 || plan.Empty() {
+		t.Fatalf("Compare = %+v", plan)
+	}
+
+	transport := NewTransport(TransportOptions{})
+	for _, f := range plan.Transfers {
+		if err := transport.Copy(filepath.Join(source, f.Path), filepath.Join(target, f.Path)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if dst, err = scanner.Scan(target); err != nil {
+		t.Fatal(err)
+	}
+	if f, ok := dst.Lookup("a.mkv"); !ok || f.Size != 1 {
+		t.Errorf("Lookup(a.mkv) = %+v, %v", f, ok)
+	}
+	if plan := Compare(src, dst, CompareOptions{}); !plan.Empty() {
+		t.Errorf("Both ends should be in sync, plan = %+v", plan)
+	}
+}
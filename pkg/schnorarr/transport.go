@@ -0,0 +1,53 @@
+package schnorarr
+
+import "schnorarr/internal/sync"
+
+// TransportOptions configures a Transport
+type TransportOptions struct {
+	// BandwidthLimit in bytes per second (0 = unlimited)
+	BandwidthLimit int64
+	// Streams is the number of parallel streams for large local copies (0 = adaptive)
+	Streams int
+	// Compress enables rsync compression for remote targets
+	Compress bool
+	// OnProgress is called while a file is copied (optional)
+	OnProgress func(path string, transferred, total int64)
+}
+
+// Transport copies, renames and deletes files on a local or rsync target.
+// Paths are full paths: a local path or an rsync daemon URI. Local copies
+// are written to a temporary file that replaces the destination once
+// complete; failed copies are retried three times.
+type Transport struct {
+	t *sync.Transferer
+}
+
+// NewTransport returns a transport; it may be shared by goroutines
+func NewTransport(opts TransportOptions) *Transport {
+	return &Transport{t: sync.NewTransferer(sync.TransferOptions{
+		BandwidthLimit: opts.BandwidthLimit,
+		NumStreams:     opts.Streams,
+		Compress:       opts.Compress,
+		OnProgress:     opts.OnProgress,
+	})}
+}
+
+// Copy copies the file src to dst, creating missing parent directories
+func (t *Transport) Copy(src, dst string) error { return t.t.CopyFile(src, dst) }
+
+// Rename moves oldPath to newPath on a local target; rsync targets do not
+// support renames
+func (t *Transport) Rename(oldPath, newPath string) error { return t.t.RenameFile(oldPath, newPath) }
+
+// Mkdir creates a directory and its parents; rsync targets create them
+// with the first copy
+func (t *Transport) Mkdir(path string) error { return t.t.CreateDir(path) }
+
+// Remove deletes a file; a missing file is not an error
+func (t *Transport) Remove(path string) error { return t.t.DeleteFile(path) }
+
+// RemoveDir deletes a directory with its contents
+func (t *Transport) RemoveDir(path string) error { return t.t.DeleteDir(path) }
+
+// SetBandwidthLimit changes the limit in bytes per second (0 = unlimited)
+func (t *Transport) SetBandwidthLimit(limit int64) { t.t.SetBandwidthLimit(limit) }
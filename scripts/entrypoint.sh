@@ -1,6 +1,20 @@
 #!/bin/bash
 set -e
 
+# file_env VAR reads VAR from the file named by VAR_FILE (Docker secrets)
+file_env() {
+    local var="$1" file_var="${1}_FILE"
+    if [ -n "${!file_var}" ]; then
+        if [ ! -r "${!file_var}" ]; then
+            echo "Error: $file_var points to unreadable file ${!file_var}"
+            exit 1
+        fi
+        export "$var"="$(cat "${!file_var}")"
+    fi
+}
+file_env RSYNC_PASSWORD
+file_env TAILSCALE_AUTHKEY
+
 # Handle Tailscale if AUTHKEY is provided
 if [ -n "$TAILSCALE_AUTHKEY" ]; then
     echo "Starting Tailscale..."
@@ -0,0 +1,12 @@
+#!/bin/bash
+# rsyncd pre-xfer exec: refuses uploads while the receiver is read-only.
+# The monitor writes the reason to the flag file (READ_ONLY_FLAG) while the
+# read-only switch or a scheduled window is active. Downloads stay allowed.
+FLAG="${READ_ONLY_FLAG:-/config/read-only}"
+
+[ -f "$FLAG" ] || exit 0
+if env | grep -q '^RSYNC_ARG[0-9]*=--sender$'; then
+    exit 0
+fi
+echo "receiver is read-only: $(cat "$FLAG")"
+exit 1